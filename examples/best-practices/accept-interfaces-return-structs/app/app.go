@@ -0,0 +1,57 @@
+// Package app assembles the users API served by cmd/http: config, logger,
+// tracing, the store behind its decorators, the service, the event stream,
+// and the HTTP server, in the order each needs the others.
+//
+// It does so twice, to compare the two ways Go programs wire dependencies.
+// NewApp is written by hand, InitializeApp is generated by Google's wire
+// from the injector in wire.go. Both call the same providers in
+// providers.go, so they build the same App, and the tests check that they
+// do. After changing a provider's parameters, regenerate wire_gen.go:
+//
+//	go generate ./app
+package app
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/config"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/health"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/shutdown"
+	httptransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/http"
+)
+
+// App is the assembled program. Fields are exported for wire.Struct, Run
+// is the only thing main needs.
+type App struct {
+	Config config.Config
+	Logger *slog.Logger
+	Users  *service.UserService
+	// Handler is every route behind the middleware, Server serves it.
+	Handler http.Handler
+	Server  *httptransport.Server
+	Health  *health.Health
+	// Shutdown holds the cleanups, registered as each resource was made.
+	Shutdown *shutdown.Stack
+}
+
+// Run serves until a signal arrives, ctx is done, or the listener fails,
+// then runs the cleanups: the server drains, the store closes, and the
+// tracer flushes the spans of both.
+func (a *App) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	served := make(chan error, 1)
+	go func() {
+		served <- a.Server.Run(ctx)
+		// a failed listen shuts the rest down too
+		cancel()
+	}()
+	a.Shutdown.Add("http", func(context.Context) error {
+		cancel()
+		return <-served
+	})
+	return a.Shutdown.Wait(ctx)
+}
@@ -0,0 +1,161 @@
+package app_test
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/app"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/config"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/middleware"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/secret"
+)
+
+// request is one call made against both apps, as tenant acme unless it
+// names another.
+type request struct {
+	method, path, body string
+	tenant             string
+}
+
+// response is what the comparison looks at: the status and the JSON keys
+// of the body, IDs and timestamps differ between any two runs.
+type response struct {
+	status int
+	keys   []string
+}
+
+func (r response) String() string {
+	return fmt.Sprintf("%d %v", r.status, r.keys)
+}
+
+// call sends r to a's handler.
+func call(a *app.App, r request) response {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(r.method, r.path, strings.NewReader(r.body))
+	req.Header.Set(middleware.TenantHeader, cmp.Or(r.tenant, "acme"))
+	a.Handler.ServeHTTP(rec, req)
+	var body map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	return response{status: rec.Code, keys: slices.Sorted(maps.Keys(body))}
+}
+
+// TestInjectorsAgree builds the users API with the hand-written NewApp and
+// with the wire generated InitializeApp, each on its own sqlite file. Both
+// must answer the same requests the same way, and shut down the same
+// resources.
+func TestInjectorsAgree(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	build := func(name string, inject func(context.Context, config.Config) (*app.App, error)) *app.App {
+		cfg := config.Default()
+		cfg.DB.DSN = secret.New(filepath.Join(dir, name+".db"))
+		// keep the apps' logs out of the test output
+		cfg.Log.Level = slog.LevelError + 4
+		a, err := inject(ctx, cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return a
+	}
+	manual, wired := build("manual", app.NewApp), build("wire", app.InitializeApp)
+	apps := map[string]*app.App{"manual": manual, "wire": wired}
+	// compare sends every request to both apps, they must answer alike
+	compare := func(t *testing.T, requests ...request) {
+		t.Helper()
+		for _, r := range requests {
+			if m, w := call(manual, r), call(wired, r); m.String() != w.String() {
+				t.Errorf("%s %s: manual %s, wire %s", r.method, r.path, m, w)
+			}
+		}
+	}
+
+	t.Run("same API", func(t *testing.T) {
+		compare(t,
+			request{"POST", "/users", `{"id":"ada","email":"ada@example.com"}`, ""},
+			request{"GET", "/users/ada", "", ""},
+			request{"GET", "/v2/users/ada", "", ""},
+			request{"POST", "/users", `{"id":"ada","email":"ada@example.com"}`, ""},
+			request{"GET", "/users/missing", "", ""},
+			request{"GET", "/openapi.json", "", ""},
+		)
+	})
+
+	t.Run("tenants kept apart", func(t *testing.T) {
+		// few requests, the rate limit's burst is shared with the others
+		for name, a := range apps {
+			if got := call(a, request{"GET", "/users/ada", "", "globex"}); got.status != http.StatusNotFound {
+				t.Errorf("%s: acme's user read as globex: %s, want a 404", name, got)
+			}
+			rec := httptest.NewRecorder()
+			a.Handler.ServeHTTP(rec, httptest.NewRequest("GET", "/users/ada", nil))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("%s: a read without X-Tenant-ID = %d, want a 400", name, rec.Code)
+			}
+		}
+	})
+
+	t.Run("same dependencies checked", func(t *testing.T) {
+		compare(t, request{"GET", "/readyz", "", ""})
+		for name, a := range apps {
+			report := a.Health.Run(ctx)
+			if _, ok := report.Checks["sqlite"]; !ok || report.Status != "ok" {
+				t.Errorf("%s: Health = %+v, want sqlite checked and ok", name, report)
+			}
+		}
+	})
+
+	t.Run("shutdown closes the store", func(t *testing.T) {
+		for name, a := range apps {
+			if err := a.Shutdown.Shutdown(); err != nil {
+				t.Fatalf("%s: Shutdown: %v", name, err)
+			}
+		}
+		compare(t, request{"GET", "/users/ada", "", ""})
+		if got := call(wired, request{"GET", "/users/ada", "", ""}); got.status != http.StatusInternalServerError {
+			t.Errorf("a read after shutdown: %s, want a 500", got)
+		}
+	})
+}
+
+// TestBackendFromConfig checks the store is picked by a string in config,
+// and that only the sqlite store adds a readiness check.
+func TestBackendFromConfig(t *testing.T) {
+	ctx := context.Background()
+	for _, tt := range []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"default", nil, "memory"},
+		{"a path", []string{"-db", filepath.Join(t.TempDir(), "app.db")}, "sqlite"},
+		{"a driver", []string{"-db-driver", "memory", "-db", "ignored"}, "memory"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := config.Load("app", append(tt.args, "-log-level", "error"))
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if got := cfg.DB.Backend(); got != tt.want {
+				t.Errorf("Backend = %s, want %s", got, tt.want)
+			}
+			a, err := app.NewApp(ctx, cfg)
+			if err != nil {
+				t.Fatalf("NewApp: %v", err)
+			}
+			t.Cleanup(func() { a.Shutdown.Shutdown() })
+			if _, checked := a.Health.Run(ctx).Checks["sqlite"]; checked != (tt.want == "sqlite") {
+				t.Errorf("sqlite readiness checked = %t, want %t", checked, tt.want == "sqlite")
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package app
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/config"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/eventbus"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/health"
+)
+
+// NewApp is the hand-written injector: the providers called in dependency
+// order, each result passed on by name. It is what wire generates, plus one
+// thing wire cannot: when a provider fails, the cleanups registered before
+// it run, so a bad DSN does not leave tracing unflushed.
+func NewApp(ctx context.Context, cfg config.Config) (*App, error) {
+	logger := NewLogger(cfg)
+	stack, err := NewShutdown(ctx, cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	checks := health.New()
+	store, err := NewStore(ctx, cfg, logger, checks, stack)
+	if err != nil {
+		return nil, errors.Join(err, stack.Shutdown())
+	}
+	bus := eventbus.New()
+	users := NewUserService(store, logger, bus)
+	stream := NewEventStream(bus, logger)
+	handler := NewHandler(users, stream, checks, logger)
+	return &App{
+		Config:   cfg,
+		Logger:   logger,
+		Users:    users,
+		Handler:  handler,
+		Server:   NewServer(cfg, handler, checks, stream, logger),
+		Health:   checks,
+		Shutdown: stack,
+	}, nil
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/wire"
+	"go.opentelemetry.io/otel"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/config"
+	// the stores NewStore can open by name without a plugin
+	_ "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/logged"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/registry"
+	_ "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/sqlite"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/tenanted"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/traced"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/eventbus"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/events"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/health"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/idgen"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/logging"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/middleware"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/shutdown"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/tracing"
+	httptransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/http"
+)
+
+// ProviderSet is every provider InitializeApp draws on. The Bind says the
+// bus is the service's events.Publisher, wire only matches exact types.
+var ProviderSet = wire.NewSet(
+	NewLogger,
+	NewShutdown,
+	health.New,
+	NewStore,
+	eventbus.New,
+	wire.Bind(new(events.Publisher), new(*eventbus.Bus)),
+	NewUserService,
+	NewEventStream,
+	NewHandler,
+	NewServer,
+	wire.Struct(new(App), "*"),
+)
+
+var (
+	v1Deprecated = time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	v1Sunset     = time.Date(2027, 6, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func NewLogger(cfg config.Config) *slog.Logger {
+	return logging.New(os.Stderr, cfg.Log)
+}
+
+// NewShutdown starts the cleanup stack with tracing, the first thing set
+// up, so it is the last cleaned up and flushes everyone's spans.
+func NewShutdown(ctx context.Context, cfg config.Config, logger *slog.Logger) (*shutdown.Stack, error) {
+	stack := shutdown.New(shutdown.WithLogger(logger), shutdown.WithTimeout(cfg.HTTP.ShutdownTimeout.Duration+5*time.Second))
+	flush, err := tracing.Setup(ctx, "users-api", tracing.Exporter(cfg.Tracing.Exporter))
+	if err != nil {
+		return nil, err
+	}
+	stack.Add("tracing", flush)
+	return stack, nil
+}
+
+// NewStore loads cfg.DB.Plugin if there is one, opens the store
+// cfg.DB.Backend names in db/registry, and wraps it in tenanted.Store, then
+// the logging and tracing decorators. A store that does not keep tenants
+// apart is refused. A store that can be checked is a readiness check, one
+// that can be closed a cleanup.
+//
+//returnstructs:allow the backend is chosen by config at run time
+func NewStore(ctx context.Context, cfg config.Config, logger *slog.Logger, checks *health.Health, stack *shutdown.Stack) (service.UserStorer, error) {
+	if cfg.DB.Plugin != "" {
+		names, err := registry.LoadPlugin(cfg.DB.Plugin)
+		if err != nil {
+			return nil, err
+		}
+		logger.InfoContext(ctx, "plugin loaded", slog.String("path", cfg.DB.Plugin), slog.Any("stores", names))
+	}
+	system := cfg.DB.Backend()
+	store, err := registry.Open(ctx, system, cfg.DB.DSN.Reveal())
+	if err != nil {
+		return nil, err
+	}
+	if checker, ok := store.(health.Checker); ok {
+		checks.Register(system, checker, cfg.DB.PingTimeout.Duration)
+	}
+	if closer, ok := store.(io.Closer); ok {
+		stack.Add(system, shutdown.Closer(closer))
+	}
+	isolating, ok := store.(tenanted.Isolating)
+	if !ok {
+		return nil, errs.Wrap("app.NewStore", fmt.Errorf("store %s does not keep tenants apart: %w", system, errors.ErrUnsupported))
+	}
+	return traced.New(logged.New(tenanted.New(isolating), logger), otel.GetTracerProvider(), system), nil
+}
+
+func NewUserService(store service.UserStorer, logger *slog.Logger, pub events.Publisher) *service.UserService {
+	return service.NewUserService(store,
+		service.WithLogger(logger),
+		service.WithIDGenerator(idgen.UUIDv7{}),
+		service.WithPublisher(pub),
+	)
+}
+
+func NewEventStream(bus *eventbus.Bus, logger *slog.Logger) *httptransport.EventStream {
+	return httptransport.NewEventStream(bus, httptransport.WithStreamLogger(logger))
+}
+
+// NewHandler routes both API versions, the event stream, and the docs
+// behind the middleware, and the probes around it. The users and their
+// events are behind middleware.Tenant too, a request reaches them only for
+// the tenant in its X-Tenant-ID.
+//
+//returnstructs:allow wire provides by type, and NewServer takes an http.Handler
+func NewHandler(users *service.UserService, stream *httptransport.EventStream, checks *health.Health, logger *slog.Logger) http.Handler {
+	tenant := middleware.Tenant()
+	api := http.NewServeMux()
+	api.Handle("GET /users/events", tenant(stream))
+	api.Handle("GET /openapi.json", httptransport.DocsHandler())
+	api.Handle("GET /docs", httptransport.DocsHandler())
+	// v1 stays reachable unversioned for old clients, both shapes share
+	// one service so a user created through either reads back through both
+	// a long poll of the list wakes on the events the stream relays
+	longPoll := httptransport.WithLongPoll(stream)
+	v1 := middleware.Deprecation(v1Deprecated, v1Sunset, "/v2/users")(tenant(httptransport.NewHandler(users, logger, longPoll)))
+	api.Handle("/v1/", http.StripPrefix("/v1", v1))
+	api.Handle("/v2/", http.StripPrefix("/v2", tenant(httptransport.NewV2Handler(users, logger, longPoll))))
+	api.Handle("/", v1)
+
+	// probes skip the middleware, a rate limited or logged-to-death
+	// readiness check helps nobody
+	mux := http.NewServeMux()
+	mux.Handle("GET /healthz", checks.Handler())
+	mux.Handle("GET /readyz", checks.Handler())
+	mux.Handle("/", middleware.Chain(
+		middleware.Trace("users-api"),
+		middleware.RequestID(idgen.UUIDv4{}),
+		middleware.Traceparent(),
+		middleware.Logger(logger),
+		middleware.Recover(logger),
+		middleware.RateLimit(middleware.WithRate(5, 10), middleware.WithKeyFunc(middleware.KeyByHeader("X-API-Key"))),
+		middleware.Timing(),
+	)(api))
+	return mux
+}
+
+// NewServer drains the readiness check and closes the event stream before
+// it stops serving, so load balancers and SSE clients move on first.
+func NewServer(cfg config.Config, handler http.Handler, checks *health.Health, stream *httptransport.EventStream, logger *slog.Logger) *httptransport.Server {
+	return httptransport.NewServer(cfg.HTTP.Addr, handler,
+		httptransport.WithServerLogger(logger),
+		httptransport.WithReadHeaderTimeout(cfg.HTTP.ReadHeaderTimeout.Duration),
+		httptransport.WithShutdownTimeout(cfg.HTTP.ShutdownTimeout.Duration),
+		httptransport.WithOnShutdown(checks.Drain),
+		httptransport.WithOnShutdown(func() { stream.Close() }),
+	)
+}
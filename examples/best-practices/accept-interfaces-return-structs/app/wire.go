@@ -0,0 +1,19 @@
+//go:build wireinject
+
+package app
+
+import (
+	"context"
+
+	"github.com/google/wire"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/config"
+)
+
+// InitializeApp is NewApp as wire generates it from ProviderSet. In wire.go
+// the body only names the providers, wire_gen.go holds the calls in
+// dependency order.
+func InitializeApp(ctx context.Context, cfg config.Config) (*App, error) {
+	wire.Build(ProviderSet)
+	return nil, nil
+}
@@ -0,0 +1,197 @@
+// Package archive bundles every user into one download: users.csv, the
+// same file GET /users.csv serves, and users/<id>.json for each user, the
+// DTO the serialization package gives it. Write produces a zip or a
+// gzipped tar into any io.Writer, Open the same as an io.ReadCloser for
+// callers that want to read an archive rather than have one written to
+// them, an upload to a blob store say.
+//
+// Neither holds the archive in memory. Users come from ListAll a page at a
+// time and each entry is written as it is read, so a slow writer holds up
+// the next page fetch. The exception is tar, whose headers carry each
+// entry's size ahead of its bytes: users.csv is spooled to a temporary file
+// to measure it, and each JSON file, a few hundred bytes, is encoded whole
+// before it is written.
+//
+// users.csv and the JSON files are two reads, not one snapshot. A user
+// created or deleted between them is in one and not the other.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/serialization"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Source is what an archive is built from, *service.UserService has it.
+type Source interface {
+	ListAll(ctx context.Context, opts ...service.ReadOption) iter.Seq2[*service.User, error]
+	ExportUsersCSV(ctx context.Context, w io.Writer) error
+}
+
+// Format is an archive's container.
+type Format string
+
+const (
+	Zip   Format = "zip"
+	TarGz Format = "tar.gz"
+)
+
+// ContentType is the media type to serve f as.
+func (f Format) ContentType() string {
+	if f == TarGz {
+		return "application/gzip"
+	}
+	return "application/zip"
+}
+
+// Filename is the name to offer a download of f under.
+func (f Format) Filename() string {
+	return "users." + string(f)
+}
+
+// Name is the entry a user's JSON file is stored under. The ID is path
+// escaped, so one with a slash in it is still one file in users/.
+func Name(id string) string {
+	return "users/" + url.PathEscape(id) + ".json"
+}
+
+// Write writes every live user to w as an archive in format. A failure
+// part way leaves w holding a truncated archive, which neither format's
+// readers take for a complete one.
+func Write(ctx context.Context, w io.Writer, src Source, format Format) error {
+	switch format {
+	case Zip:
+		return errs.Wrap("archive.Write", writeZip(ctx, w, src))
+	case TarGz:
+		return errs.Wrap("archive.Write", writeTarGz(ctx, w, src))
+	default:
+		return errs.Wrap("archive.Write", fmt.Errorf("format %q: %w", format, errs.ErrInvalidInput))
+	}
+}
+
+func writeZip(ctx context.Context, w io.Writer, src Source) error {
+	zw := zip.NewWriter(w)
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: "users.csv", Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	if err := src.ExportUsersCSV(ctx, f); err != nil {
+		return err
+	}
+	for user, err := range src.ListAll(ctx) {
+		if err != nil {
+			return err
+		}
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: Name(user.ID), Method: zip.Deflate, Modified: user.UpdatedAt})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(serialization.FromUser(user)); err != nil {
+			return err
+		}
+	}
+	// Close writes the central directory, without it there is no archive
+	return zw.Close()
+}
+
+func writeTarGz(ctx context.Context, w io.Writer, src Source) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	if err := writeTarCSV(ctx, tw, src); err != nil {
+		return err
+	}
+	for user, err := range src.ListAll(ctx) {
+		if err != nil {
+			return err
+		}
+		b, err := json.MarshalIndent(serialization.FromUser(user), "", "  ")
+		if err != nil {
+			return err
+		}
+		b = append(b, '\n')
+		hdr := &tar.Header{Name: Name(user.ID), Mode: 0o644, Size: int64(len(b)), ModTime: user.UpdatedAt, Format: tar.FormatPAX}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(b); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// writeTarCSV spools the CSV export to a temporary file for its size and
+// copies it into tw from there.
+func writeTarCSV(ctx context.Context, tw *tar.Writer, src Source) error {
+	tmp, err := os.CreateTemp("", "users-*.csv")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if err := src.ExportUsersCSV(ctx, tmp); err != nil {
+		return err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: "users.csv", Mode: 0o644, Size: size, ModTime: time.Now(), Format: tar.FormatPAX}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, tmp)
+	return err
+}
+
+// Open returns the archive Write would write, produced by a goroutine as
+// it is read through an io.Pipe. Nothing is read ahead: the goroutine
+// blocks on each write until the reader takes it. A failure reaches the
+// reader as the error from Read, in place of io.EOF.
+//
+// Close the reader, read to the end or not. Closing it early stops the
+// goroutine, it cancels the goroutine's context and fails its next write,
+// and Close returns once the goroutine has, temporary file removed.
+func Open(ctx context.Context, src Source, format Format) io.ReadCloser {
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pw.CloseWithError(Write(ctx, pw, src, format))
+	}()
+	return &reader{PipeReader: pr, cancel: cancel, done: done}
+}
+
+type reader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (r *reader) Close() error {
+	r.cancel()
+	err := r.PipeReader.Close()
+	<-r.done
+	return err
+}
@@ -0,0 +1,252 @@
+package archive_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/archive"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/blob"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/query"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/serialization"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+const total = 2_000
+
+// pages is how many pages an archive reads for total users, one pass for
+// users.csv and one for the JSON files.
+const pages = 2 * (total/100 + 1)
+
+// countingStore counts the pages the archive reads, and fails the page
+// after failAfter when that is set.
+type countingStore struct {
+	*db.MemoryStore
+	pages     atomic.Int64
+	failAfter atomic.Int64
+}
+
+func (s *countingStore) Query(ctx context.Context, q query.Query) ([]*service.User, error) {
+	n := s.pages.Add(1)
+	if f := s.failAfter.Load(); f > 0 && n > f {
+		return nil, errors.New("store went away")
+	}
+	return s.MemoryStore.Query(ctx, q)
+}
+
+// seeded returns total users, one with a slash in its ID and one deleted,
+// and the live ones in order.
+func seeded(t *testing.T) (*countingStore, *service.UserService, []*service.User) {
+	t.Helper()
+	ctx := context.Background()
+	store := &countingStore{MemoryStore: db.NewMemoryStore()}
+	users := service.NewUserService(store)
+	batch := make([]*service.User, 0, total)
+	for i := range total - 1 {
+		id := fmt.Sprintf("user-%06d", i)
+		batch = append(batch, &service.User{ID: id, Email: id + "@example.com", Name: "Ada Lovelace"})
+	}
+	// a slash in an ID must not make a directory of the name
+	batch = append(batch, &service.User{ID: "team/grace", Email: "grace@example.com", Name: "Grace Hopper"})
+	if _, err := users.CreateUsers(ctx, batch); err != nil {
+		t.Fatalf("CreateUsers: %v", err)
+	}
+	if err := users.DeleteUser(ctx, "user-000007"); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	var live []*service.User
+	for user, err := range users.ListAll(ctx) {
+		if err != nil {
+			t.Fatalf("ListAll: %v", err)
+		}
+		live = append(live, user)
+	}
+	store.pages.Store(0)
+	return store, users, live
+}
+
+var formats = []struct {
+	format archive.Format
+	unpack func([]byte) (map[string][]byte, error)
+}{
+	{archive.Zip, unzip},
+	{archive.TarGz, untar},
+}
+
+// unzip reads every file out of a zip.
+func unzip(b []byte) (map[string][]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		files[f.Name] = data
+	}
+	return files, nil
+}
+
+// untar reads every file out of a gzipped tar.
+func untar(b []byte) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	files := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+		files[hdr.Name] = data
+	}
+}
+
+// verify checks an unpacked archive holds users.csv with a row for each
+// of want and a JSON file for each, and nothing else.
+func verify(t *testing.T, files map[string][]byte, want []*service.User) {
+	t.Helper()
+	if len(files) != len(want)+1 {
+		t.Fatalf("%d files, want users.csv and %d users", len(files), len(want))
+	}
+	rows, err := csv.NewReader(bytes.NewReader(files["users.csv"])).ReadAll()
+	if err != nil {
+		t.Fatalf("users.csv: %v", err)
+	}
+	if len(rows) != len(want)+1 || rows[0][0] != "id" {
+		t.Fatalf("users.csv has %d rows, want a header and %d users", len(rows), len(want))
+	}
+	for i, user := range want {
+		if rows[i+1][0] != user.ID || rows[i+1][1] != user.Email {
+			t.Fatalf("users.csv row %d = %v, want %s", i+1, rows[i+1], user.ID)
+		}
+		data, ok := files[archive.Name(user.ID)]
+		if !ok {
+			t.Fatalf("no %s", archive.Name(user.ID))
+		}
+		var got serialization.User
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("%s: %v", archive.Name(user.ID), err)
+		}
+		if got.ID != user.ID || got.Email != user.Email || got.Name != user.Name || got.Version != user.Version {
+			t.Fatalf("%s = %+v, want %+v", archive.Name(user.ID), got, serialization.FromUser(user))
+		}
+	}
+	if _, ok := files["users/team%2Fgrace.json"]; !ok {
+		t.Error("no users/team%2Fgrace.json")
+	}
+}
+
+// TestOpen streams each format into a blob store, the way a background
+// export would, and unpacks what was stored.
+func TestOpen(t *testing.T) {
+	ctx := context.Background()
+	_, users, want := seeded(t)
+	for _, f := range formats {
+		t.Run(string(f.format), func(t *testing.T) {
+			blobs := blob.NewMemory()
+			r := archive.Open(ctx, users, f.format)
+			defer r.Close()
+			key := "exports/" + f.format.Filename()
+			if err := blobs.Put(ctx, key, r, f.format.ContentType()); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			rc, info, err := blobs.Get(ctx, key)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			defer rc.Close()
+			body, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.ContentType != f.format.ContentType() {
+				t.Errorf("stored as %q, want %q", info.ContentType, f.format.ContentType())
+			}
+			files, err := f.unpack(body)
+			if err != nil {
+				t.Fatalf("unpacking: %v", err)
+			}
+			verify(t, files, want)
+		})
+	}
+}
+
+// TestCloseEarly checks a reader that stops early stops the archive being
+// built: Close returns once the goroutine writing it has, and not every
+// page was read.
+func TestCloseEarly(t *testing.T) {
+	store, users, _ := seeded(t)
+	for _, f := range formats {
+		t.Run(string(f.format), func(t *testing.T) {
+			store.pages.Store(0)
+			r := archive.Open(context.Background(), users, f.format)
+			if _, err := io.ReadFull(r, make([]byte, 1024)); err != nil {
+				t.Fatalf("reading: %v", err)
+			}
+			if err := r.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			if n := store.pages.Load(); n >= pages {
+				t.Errorf("%d pages read, want fewer than all %d", n, pages)
+			}
+		})
+	}
+}
+
+// TestFailurePartWay checks a store failing past users.csv, into the JSON
+// files, fails the read and leaves an archive that does not unpack.
+func TestFailurePartWay(t *testing.T) {
+	store, users, _ := seeded(t)
+	for _, f := range formats {
+		t.Run(string(f.format), func(t *testing.T) {
+			store.pages.Store(0)
+			store.failAfter.Store(pages/2 + 3)
+			defer store.failAfter.Store(0)
+			var buf bytes.Buffer
+			if err := archive.Write(context.Background(), &buf, users, f.format); err == nil {
+				t.Fatal("Write succeeded")
+			}
+			if _, err := f.unpack(buf.Bytes()); err == nil {
+				t.Error("the truncated archive unpacks")
+			}
+		})
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, users, _ := seeded(t)
+	r := archive.Open(context.Background(), users, "rar")
+	defer r.Close()
+	if _, err := io.ReadAll(r); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("read: err = %v, want ErrInvalidInput", err)
+	}
+}
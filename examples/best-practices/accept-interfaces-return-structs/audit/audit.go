@@ -0,0 +1,144 @@
+// Package audit records who changed which user, when, and what changed.
+// Entries are written once and never updated: a sink only appends, and what
+// it hands back are copies, so nothing downstream can rewrite history.
+//
+// The service writes an entry after every stored mutation, see
+// service.WithAuditSink. Like events, entries carry their own snapshot of
+// the user instead of a service.User, so this package stays free of service
+// imports and the service can depend on it.
+package audit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+)
+
+// Action is the kind of mutation an entry records.
+type Action string
+
+const (
+	Created  Action = "create"
+	Updated  Action = "update"
+	Deleted  Action = "delete"
+	Restored Action = "restore"
+	Purged   Action = "purge"
+	Renamed  Action = "rename"
+)
+
+// Snapshot is a user as it was just before or just after a change.
+type Snapshot struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Version   int64     `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	DeletedAt time.Time `json:"deleted_at,omitzero"`
+}
+
+// Change is one field that differs between Before and After, values are
+// formatted as strings, the empty string where there was no user.
+type Change struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// Entry is one recorded mutation. Before is nil for a create, After for a
+// purge. Actor is the authenticated caller's subject, empty for calls made
+// without one, such as background jobs.
+type Entry struct {
+	Action    Action    `json:"action"`
+	Tenant    string    `json:"tenant,omitempty"`
+	UserID    string    `json:"user_id"`
+	Actor     string    `json:"actor,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	At        time.Time `json:"at"`
+	Before    *Snapshot `json:"before,omitempty"`
+	After     *Snapshot `json:"after,omitempty"`
+	Changes   []Change  `json:"changes,omitempty"`
+}
+
+// About reports whether the entry concerns the user id, under the ID it had
+// before the change or after it, so a rename is found under both.
+func (e Entry) About(id string) bool {
+	return e.UserID == id || (e.Before != nil && e.Before.ID == id) || (e.After != nil && e.After.ID == id)
+}
+
+// clone copies the snapshots and changes, so the copy shares no memory
+// with e.
+func (e Entry) clone() Entry {
+	if e.Before != nil {
+		before := *e.Before
+		e.Before = &before
+	}
+	if e.After != nil {
+		after := *e.After
+		e.After = &after
+	}
+	e.Changes = append([]Change(nil), e.Changes...)
+	return e
+}
+
+// Sink stores entries. Write must copy whatever of e it keeps, so the
+// caller's later edits cannot reach the record.
+type Sink interface {
+	Write(ctx context.Context, e Entry) error
+}
+
+// Querier reads entries back. ByUser returns every entry About id in the
+// tenant of ctx, see ctxutil.Tenant, oldest first.
+type Querier interface {
+	ByUser(ctx context.Context, id string) ([]Entry, error)
+}
+
+// tenant is the tenant ByUser reads entries of, the default one, "", for
+// a ctx without one.
+func tenant(ctx context.Context) string {
+	id, _ := ctxutil.Tenant(ctx)
+	return id
+}
+
+// Diff lists the fields that differ between before and after, either of
+// which may be nil. Timestamps other than DeletedAt are left out, every
+// change moves UpdatedAt and the entry's At already says when.
+func Diff(before, after *Snapshot) []Change {
+	var b, a Snapshot
+	if before != nil {
+		b = *before
+	}
+	if after != nil {
+		a = *after
+	}
+	var changes []Change
+	add := func(field, from, to string) {
+		if from != to {
+			changes = append(changes, Change{Field: field, From: from, To: to})
+		}
+	}
+	add("id", b.ID, a.ID)
+	add("email", b.Email, a.Email)
+	add("name", b.Name, a.Name)
+	add("status", b.Status, a.Status)
+	add("version", version(before, b.Version), version(after, a.Version))
+	add("deleted_at", timestamp(b.DeletedAt), timestamp(a.DeletedAt))
+	return changes
+}
+
+func version(s *Snapshot, v int64) string {
+	if s == nil {
+		return ""
+	}
+	return strconv.FormatInt(v, 10)
+}
+
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
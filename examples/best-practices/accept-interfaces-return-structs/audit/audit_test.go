@@ -0,0 +1,55 @@
+package audit_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/audit"
+)
+
+func TestDiff(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	ada := &audit.Snapshot{ID: "ada", Email: "ada@example.com", Name: "Ada", Status: "active", Version: 1, CreatedAt: created, UpdatedAt: created}
+	renamed := *ada
+	renamed.Email, renamed.Version, renamed.UpdatedAt = "ada@lovelace.example", 2, created.Add(time.Hour)
+	deleted := renamed
+	deleted.Version, deleted.DeletedAt = 3, created.Add(2*time.Hour)
+
+	for _, tt := range []struct {
+		name          string
+		before, after *audit.Snapshot
+		want          []audit.Change
+	}{
+		{"create", nil, ada, []audit.Change{
+			{Field: "id", To: "ada"},
+			{Field: "email", To: "ada@example.com"},
+			{Field: "name", To: "Ada"},
+			{Field: "status", To: "active"},
+			{Field: "version", To: "1"},
+		}},
+		{"update leaves UpdatedAt out", ada, &renamed, []audit.Change{
+			{Field: "email", From: "ada@example.com", To: "ada@lovelace.example"},
+			{Field: "version", From: "1", To: "2"},
+		}},
+		{"delete", &renamed, &deleted, []audit.Change{
+			{Field: "version", From: "2", To: "3"},
+			{Field: "deleted_at", To: "2026-01-02T05:04:05Z"},
+		}},
+		{"purge", ada, nil, []audit.Change{
+			{Field: "id", From: "ada"},
+			{Field: "email", From: "ada@example.com"},
+			{Field: "name", From: "Ada"},
+			{Field: "status", From: "active"},
+			{Field: "version", From: "1"},
+		}},
+		{"no change", ada, ada, nil},
+		{"version 0 is not no user", nil, &audit.Snapshot{}, []audit.Change{{Field: "version", To: "0"}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := audit.Diff(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// maxLine bounds one encoded entry, far more than two snapshots need.
+const maxLine = 1 << 20
+
+// File appends entries to a file as JSON lines. The file is opened
+// append-only, so even a bug here cannot overwrite earlier entries, and
+// each entry is a single write, so a crash loses at most the line being
+// written. ByUser skips that torn line, and OpenFile ends it with a
+// newline so the next entry starts a line of its own.
+//
+// ByUser reads the whole file, fine for an audit trail this size. A real
+// deployment ships the file to something indexed and queries that.
+type File struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenFile opens or creates the log at path.
+func OpenFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, errs.Wrap("audit.OpenFile", err)
+	}
+	if err := endLine(f); err != nil {
+		f.Close()
+		return nil, errs.Wrap("audit.OpenFile", err)
+	}
+	return &File{path: path, f: f}, nil
+}
+
+// endLine writes a newline after a last line that has none, one torn by a
+// crash mid-write, which would otherwise run into the next entry and take
+// it down too.
+func endLine(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	_, err = f.Write([]byte{'\n'})
+	return err
+}
+
+func (s *File) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+func (s *File) Write(ctx context.Context, e Entry) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("audit.File.Write", err)
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return errs.Wrap("audit.File.Write", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.f.Write(append(line, '\n'))
+	return errs.Wrap("audit.File.Write", err)
+}
+
+func (s *File) ByUser(ctx context.Context, id string) ([]Entry, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, errs.Wrap("audit.File.ByUser", err)
+	}
+	defer f.Close()
+	in := tenant(ctx)
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, maxLine)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return nil, errs.Wrap("audit.File.ByUser", err)
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// a torn last line from a crash mid-write
+			continue
+		}
+		if e.About(id) && e.Tenant == in {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errs.Wrap("audit.File.ByUser", err)
+	}
+	return entries, nil
+}
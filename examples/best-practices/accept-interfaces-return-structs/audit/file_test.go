@@ -0,0 +1,123 @@
+package audit_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/audit"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+)
+
+// open opens the log at path, closed when the test ends.
+func open(t *testing.T, path string) *audit.File {
+	t.Helper()
+	f, err := audit.OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// write records action on id in ctx's tenant, as the service does.
+func write(t *testing.T, ctx context.Context, sink audit.Sink, action audit.Action, id string) {
+	t.Helper()
+	tenant, _ := ctxutil.Tenant(ctx)
+	if err := sink.Write(ctx, audit.Entry{Action: action, Tenant: tenant, UserID: id, At: time.Now()}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+}
+
+// actions lists what ByUser returns for id.
+func actions(t *testing.T, ctx context.Context, q audit.Querier, id string) string {
+	t.Helper()
+	entries, err := q.ByUser(ctx, id)
+	if err != nil {
+		t.Fatalf("ByUser: %v", err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, string(e.Action))
+	}
+	return strings.Join(got, " ")
+}
+
+// TestFileReopen checks entries written before a reopen are read back
+// after it, in order, and per tenant.
+func TestFileReopen(t *testing.T) {
+	ctx := context.Background()
+	acme := ctxutil.WithTenant(ctx, "acme")
+	path := filepath.Join(t.TempDir(), "audit.log")
+	first := open(t, path)
+	write(t, ctx, first, audit.Created, "ada")
+	write(t, acme, first, audit.Created, "ada")
+	write(t, ctx, first, audit.Updated, "ada")
+	if err := first.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	second := open(t, path)
+	write(t, ctx, second, audit.Deleted, "ada")
+	write(t, ctx, second, audit.Created, "bob")
+	if got := actions(t, ctx, second, "ada"); got != "create update delete" {
+		t.Errorf("ada's entries = %q, want create update delete", got)
+	}
+	if got := actions(t, acme, second, "ada"); got != "create" {
+		t.Errorf("ada's entries in acme = %q, want acme's create alone", got)
+	}
+}
+
+// TestFileTornLine starts from a log whose last line a crash cut short:
+// that line is skipped, and the entries after it are all read back.
+func TestFileTornLine(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	f := open(t, path)
+	write(t, ctx, f, audit.Created, "ada")
+	f.Close()
+	log, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.WriteString(`{"action":"update","user_id":"ada","at":"2026-`)
+	log.Close()
+
+	reopened := open(t, path)
+	write(t, ctx, reopened, audit.Deleted, "ada")
+	write(t, ctx, reopened, audit.Restored, "ada")
+	if got := actions(t, ctx, reopened, "ada"); got != "create delete restore" {
+		t.Errorf("ada's entries = %q, want the torn update skipped and the rest kept", got)
+	}
+	// a log that already ends a line is left as it is
+	reopened.Close()
+	before, _ := os.ReadFile(path)
+	open(t, path).Close()
+	if after, _ := os.ReadFile(path); len(after) != len(before) {
+		t.Errorf("reopening a whole log grew it from %d to %d bytes", len(before), len(after))
+	}
+}
+
+func TestContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sinks := map[string]interface {
+		audit.Sink
+		audit.Querier
+	}{
+		"file":   open(t, filepath.Join(t.TempDir(), "audit.log")),
+		"memory": audit.NewMemory(),
+	}
+	for name, sink := range sinks {
+		t.Run(name, func(t *testing.T) {
+			err := sink.Write(ctx, audit.Entry{Action: audit.Created, UserID: "ada"})
+			if !errors.Is(err, context.Canceled) || !strings.HasPrefix(err.Error(), "audit.") {
+				t.Errorf("Write: err = %v, want context.Canceled wrapped with the op", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// Memory keeps entries in a slice, for tests and single process demos. It
+// is safe for concurrent use.
+type Memory struct {
+	mu      sync.RWMutex
+	entries []Entry
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Write(ctx context.Context, e Entry) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("audit.Memory.Write", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, e.clone())
+	return nil
+}
+
+func (m *Memory) ByUser(ctx context.Context, id string) ([]Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Wrap("audit.Memory.ByUser", err)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	in := tenant(ctx)
+	var entries []Entry
+	for _, e := range m.entries {
+		if e.About(id) && e.Tenant == in {
+			entries = append(entries, e.clone())
+		}
+	}
+	return entries, nil
+}
+
+// All returns every entry, oldest first.
+func (m *Memory) All() []Entry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entries := make([]Entry, len(m.entries))
+	for i, e := range m.entries {
+		entries[i] = e.clone()
+	}
+	return entries
+}
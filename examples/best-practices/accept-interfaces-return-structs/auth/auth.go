@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+const (
+	defaultAccessTTL  = 15 * time.Minute
+	defaultRefreshTTL = 7 * 24 * time.Hour
+
+	tokenAccess  = "access"
+	tokenRefresh = "refresh"
+)
+
+// Principal is the authenticated caller. Middleware stores it in the request
+// context, read it back with ctxutil.PrincipalFrom.
+type Principal = ctxutil.Principal
+
+// TokenPair is what a login or refresh hands back. The short lived access
+// token goes on every request, the refresh token only to Refresh.
+type TokenPair struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// claims adds a token type to the registered claims so a refresh token,
+// which lives much longer, cannot be replayed as an access token.
+type claims struct {
+	jwt.RegisteredClaims
+	Type  string   `json:"typ"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// Service issues and validates JWTs. The signing method is fixed when the
+// Service is built and validation rejects every other method, which closes
+// the classic "alg":"none" and RS256-to-HS256 confusion attacks.
+type Service struct {
+	method     jwt.SigningMethod
+	signKey    any
+	verifyKey  any
+	issuer     string
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	now        func() time.Time
+}
+
+type Option func(*Service)
+
+// WithIssuer sets the iss claim, tokens from any other issuer are rejected.
+func WithIssuer(issuer string) Option {
+	return func(s *Service) {
+		s.issuer = issuer
+	}
+}
+
+// WithTTL sets the access and refresh token lifetimes, 15 minutes and
+// 7 days by default.
+func WithTTL(access, refresh time.Duration) Option {
+	return func(s *Service) {
+		s.accessTTL, s.refreshTTL = access, refresh
+	}
+}
+
+// WithNow swaps the clock used for issuing and validating, e.g. to a
+// clock.Fake's Now to expire tokens without waiting.
+func WithNow(now func() time.Time) Option {
+	return func(s *Service) {
+		s.now = now
+	}
+}
+
+// NewHS256 signs with a shared secret. Everything that validates can also
+// mint tokens, so it suits a single service that does both.
+func NewHS256(secret []byte, opts ...Option) (*Service, error) {
+	// RFC 7518 requires a key at least as long as the hash output
+	if len(secret) < 32 {
+		return nil, errs.Wrap("auth.NewHS256", fmt.Errorf("secret must be at least 32 bytes: %w", errs.ErrInvalidInput))
+	}
+	return newService(jwt.SigningMethodHS256, secret, secret, opts), nil
+}
+
+// NewRS256 signs with an RSA private key. Validators only need the public
+// half, see NewRS256Verifier, so they cannot mint tokens of their own.
+func NewRS256(key *rsa.PrivateKey, opts ...Option) (*Service, error) {
+	if key == nil {
+		return nil, errs.Wrap("auth.NewRS256", errs.ErrInvalidInput)
+	}
+	return newService(jwt.SigningMethodRS256, key, &key.PublicKey, opts), nil
+}
+
+// NewRS256Verifier validates RS256 tokens but cannot issue them.
+func NewRS256Verifier(key *rsa.PublicKey, opts ...Option) (*Service, error) {
+	if key == nil {
+		return nil, errs.Wrap("auth.NewRS256Verifier", errs.ErrInvalidInput)
+	}
+	return newService(jwt.SigningMethodRS256, nil, key, opts), nil
+}
+
+func newService(method jwt.SigningMethod, signKey, verifyKey any, opts []Option) *Service {
+	s := &Service{
+		method:     method,
+		signKey:    signKey,
+		verifyKey:  verifyKey,
+		accessTTL:  defaultAccessTTL,
+		refreshTTL: defaultRefreshTTL,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Issue mints an access and refresh token for subject, granting roles.
+// Authenticating the subject and deciding its roles first is the caller's job.
+func (s *Service) Issue(subject string, roles ...string) (TokenPair, error) {
+	if s.signKey == nil {
+		return TokenPair{}, errs.Wrap("auth.Service.Issue", errors.ErrUnsupported)
+	}
+	if subject == "" {
+		return TokenPair{}, errs.Wrap("auth.Service.Issue", errs.ErrInvalidInput)
+	}
+	now := s.now()
+	access, err := s.sign(subject, roles, tokenAccess, now, s.accessTTL)
+	if err != nil {
+		return TokenPair{}, errs.Wrap("auth.Service.Issue", err)
+	}
+	refresh, err := s.sign(subject, roles, tokenRefresh, now, s.refreshTTL)
+	if err != nil {
+		return TokenPair{}, errs.Wrap("auth.Service.Issue", err)
+	}
+	return TokenPair{AccessToken: access, RefreshToken: refresh, ExpiresAt: now.Add(s.accessTTL)}, nil
+}
+
+// Validate checks an access token and returns its principal. Every failure
+// wraps errs.ErrUnauthenticated. Expiry is also matched by jwt.ErrTokenExpired,
+// which is the client's cue to call Refresh.
+func (s *Service) Validate(token string) (Principal, error) {
+	c, err := s.parse(token, tokenAccess)
+	if err != nil {
+		return Principal{}, errs.Wrap("auth.Service.Validate", err)
+	}
+	return Principal{Subject: c.Subject, ExpiresAt: c.ExpiresAt.Time, Roles: c.Roles}, nil
+}
+
+// Refresh exchanges a valid refresh token for a new pair with the same
+// roles. The old refresh token stays valid until it expires, revoking it
+// would need server side state this example does not keep, and so would
+// picking up a change of roles before then.
+func (s *Service) Refresh(refreshToken string) (TokenPair, error) {
+	c, err := s.parse(refreshToken, tokenRefresh)
+	if err != nil {
+		return TokenPair{}, errs.Wrap("auth.Service.Refresh", err)
+	}
+	return s.Issue(c.Subject, c.Roles...)
+}
+
+func (s *Service) sign(subject string, roles []string, typ string, now time.Time, ttl time.Duration) (string, error) {
+	c := claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Subject:   subject,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Type:  typ,
+		Roles: roles,
+	}
+	return jwt.NewWithClaims(s.method, c).SignedString(s.signKey)
+}
+
+func (s *Service) parse(token, typ string) (*claims, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{s.method.Alg()}),
+		jwt.WithTimeFunc(s.now),
+		jwt.WithExpirationRequired(),
+	}
+	if s.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(s.issuer))
+	}
+	var c claims
+	_, err := jwt.ParseWithClaims(token, &c, func(*jwt.Token) (any, error) {
+		return s.verifyKey, nil
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errs.ErrUnauthenticated, err)
+	}
+	if c.Type != typ || c.Subject == "" {
+		return nil, fmt.Errorf("%w: not an %s token", errs.ErrUnauthenticated, typ)
+	}
+	return &c, nil
+}
@@ -0,0 +1,181 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/auth"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+var (
+	secret = []byte("0123456789abcdef0123456789abcdef")
+	epoch  = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+)
+
+func rsaKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// mustService returns a function unwrapping a constructor's results, so
+// a service can be built inline: mustService(t)(auth.NewHS256(secret)).
+func mustService(t *testing.T) func(*auth.Service, error) *auth.Service {
+	return func(s *auth.Service, err error) *auth.Service {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+}
+
+// forged signs claims the way an attacker might, outside auth.Service.
+func forged(t *testing.T, method jwt.SigningMethod, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestValidate(t *testing.T) {
+	must := mustService(t)
+	c := clock.NewFake(epoch)
+	key := rsaKey(t)
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub})
+
+	hs := must(auth.NewHS256(secret, auth.WithIssuer("users"), auth.WithNow(c.Now)))
+	rs := must(auth.NewRS256(key, auth.WithIssuer("users"), auth.WithNow(c.Now)))
+	verifier := must(auth.NewRS256Verifier(&key.PublicKey, auth.WithIssuer("users"), auth.WithNow(c.Now)))
+	other := must(auth.NewHS256([]byte("another secret of at least 32 bytes"), auth.WithIssuer("users"), auth.WithNow(c.Now)))
+	elsewhere := must(auth.NewHS256(secret, auth.WithIssuer("billing"), auth.WithNow(c.Now)))
+
+	issue := func(s *auth.Service) auth.TokenPair {
+		t.Helper()
+		pair, err := s.Issue("ada", "admin")
+		if err != nil {
+			t.Fatalf("Issue: %v", err)
+		}
+		return pair
+	}
+	hsPair, rsPair := issue(hs), issue(rs)
+	valid := jwt.MapClaims{"iss": "users", "sub": "ada", "typ": "access", "exp": epoch.Add(time.Hour).Unix()}
+
+	for _, tt := range []struct {
+		name    string
+		s       *auth.Service
+		token   string
+		ok      bool
+		expired bool
+	}{
+		{"HS256", hs, hsPair.AccessToken, true, false},
+		{"RS256", rs, rsPair.AccessToken, true, false},
+		{"RS256 by the public key alone", verifier, rsPair.AccessToken, true, false},
+		{"refresh token as access", hs, hsPair.RefreshToken, false, false},
+		{"another secret", other, hsPair.AccessToken, false, false},
+		{"another issuer", elsewhere, hsPair.AccessToken, false, false},
+		{"HS256 token to an RS256 service", rs, hsPair.AccessToken, false, false},
+		{"alg none", hs, forged(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, valid), false, false},
+		{"HS256 keyed with the RSA public key", verifier, forged(t, jwt.SigningMethodHS256, pubPEM, valid), false, false},
+		{"no expiry", hs, forged(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"iss": "users", "sub": "ada", "typ": "access"}), false, false},
+		{"no subject", hs, forged(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"iss": "users", "typ": "access", "exp": epoch.Add(time.Hour).Unix()}), false, false},
+		{"not yet valid", hs, forged(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"iss": "users", "sub": "ada", "typ": "access", "exp": epoch.Add(2 * time.Hour).Unix(), "nbf": epoch.Add(time.Hour).Unix()}), false, false},
+		{"expired", hs, forged(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"iss": "users", "sub": "ada", "typ": "access", "exp": epoch.Add(-time.Second).Unix()}), false, true},
+		{"not a JWT", hs, "not.a.jwt", false, false},
+		{"empty", hs, "", false, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := tt.s.Validate(tt.token)
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				if p.Subject != "ada" || !slices.Equal(p.Roles, []string{"admin"}) || !p.ExpiresAt.Equal(epoch.Add(15*time.Minute)) {
+					t.Errorf("Principal = %+v, want ada, admin, expiring in 15 minutes", p)
+				}
+				return
+			}
+			if !errors.Is(err, errs.ErrUnauthenticated) {
+				t.Fatalf("Validate: err = %v, want ErrUnauthenticated", err)
+			}
+			if errors.Is(err, jwt.ErrTokenExpired) != tt.expired {
+				t.Errorf("Validate: err = %v, want it to match ErrTokenExpired: %t", err, tt.expired)
+			}
+		})
+	}
+}
+
+func TestExpiryAndRefresh(t *testing.T) {
+	c := clock.NewFake(epoch)
+	s := mustService(t)(auth.NewHS256(secret, auth.WithTTL(time.Minute, time.Hour), auth.WithNow(c.Now)))
+	pair, err := s.Issue("ada", "reader")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if !pair.ExpiresAt.Equal(epoch.Add(time.Minute)) {
+		t.Errorf("ExpiresAt = %v, want a minute from now", pair.ExpiresAt)
+	}
+
+	c.Advance(time.Minute + time.Second)
+	if _, err := s.Validate(pair.AccessToken); !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Fatalf("Validate after the access TTL: err = %v, want ErrTokenExpired", err)
+	}
+	if _, err := s.Refresh(pair.AccessToken); !errors.Is(err, errs.ErrUnauthenticated) {
+		t.Errorf("Refresh with an access token: err = %v, want ErrUnauthenticated", err)
+	}
+	fresh, err := s.Refresh(pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	p, err := s.Validate(fresh.AccessToken)
+	if err != nil {
+		t.Fatalf("Validate the refreshed token: %v", err)
+	}
+	if p.Subject != "ada" || !slices.Equal(p.Roles, []string{"reader"}) {
+		t.Errorf("refreshed Principal = %+v, want ada keeping reader", p)
+	}
+
+	c.Advance(time.Hour)
+	if _, err := s.Refresh(pair.RefreshToken); !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Errorf("Refresh after the refresh TTL: err = %v, want ErrTokenExpired", err)
+	}
+}
+
+func TestConstructors(t *testing.T) {
+	if _, err := auth.NewHS256([]byte("short")); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("NewHS256 with a short secret: err = %v, want ErrInvalidInput", err)
+	}
+	if _, err := auth.NewRS256(nil); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("NewRS256(nil): err = %v, want ErrInvalidInput", err)
+	}
+	if _, err := auth.NewRS256Verifier(nil); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("NewRS256Verifier(nil): err = %v, want ErrInvalidInput", err)
+	}
+	verifier := mustService(t)(auth.NewRS256Verifier(&rsaKey(t).PublicKey))
+	if _, err := verifier.Issue("ada"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Issue on a verifier: err = %v, want ErrUnsupported", err)
+	}
+	hs := mustService(t)(auth.NewHS256(secret))
+	if _, err := hs.Issue(""); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Issue with no subject: err = %v, want ErrInvalidInput", err)
+	}
+}
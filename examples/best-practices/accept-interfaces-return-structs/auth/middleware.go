@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/middleware"
+)
+
+// Middleware requires a valid bearer access token and stores its Principal
+// in the request context for the handlers behind it. Requests without one
+// get 401 and a WWW-Authenticate header, an expired token is called out as
+// such so clients know to refresh rather than log in again.
+func Middleware(s *Service) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearer(r)
+			if !ok {
+				unauthorized(w, `Bearer`)
+				return
+			}
+			p, err := s.Validate(token)
+			if errors.Is(err, jwt.ErrTokenExpired) {
+				unauthorized(w, `Bearer error="invalid_token", error_description="token expired"`)
+				return
+			}
+			if err != nil {
+				unauthorized(w, `Bearer error="invalid_token"`)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ctxutil.WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+func bearer(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+func unauthorized(w http.ResponseWriter, challenge string) {
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
@@ -0,0 +1,68 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/auth"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+)
+
+func TestMiddleware(t *testing.T) {
+	c := clock.NewFake(epoch)
+	s := mustService(t)(auth.NewHS256(secret, auth.WithNow(c.Now)))
+	fresh, err := s.Issue("ada")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	c.Advance(-time.Hour)
+	stale, err := s.Issue("ada")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	c.Advance(time.Hour)
+
+	var subject string
+	h := auth.Middleware(s)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := ctxutil.PrincipalFrom(r.Context())
+		subject = p.Subject
+	}))
+	for _, tt := range []struct {
+		name, header, challenge string
+		want                    int
+	}{
+		{name: "valid", header: "Bearer " + fresh.AccessToken, want: http.StatusOK},
+		{name: "lower case scheme", header: "bearer " + fresh.AccessToken, want: http.StatusOK},
+		{name: "no header", want: http.StatusUnauthorized, challenge: `Bearer`},
+		{name: "basic auth", header: "Basic YWRhOnB3", want: http.StatusUnauthorized, challenge: `Bearer`},
+		{name: "empty token", header: "Bearer ", want: http.StatusUnauthorized, challenge: `Bearer`},
+		{name: "refresh token", header: "Bearer " + fresh.RefreshToken, want: http.StatusUnauthorized, challenge: `Bearer error="invalid_token"`},
+		{name: "expired", header: "Bearer " + stale.AccessToken, want: http.StatusUnauthorized, challenge: `Bearer error="invalid_token", error_description="token expired"`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			subject = ""
+			r := httptest.NewRequest("GET", "/users", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if got := w.Header().Get("WWW-Authenticate"); got != tt.challenge {
+				t.Errorf("WWW-Authenticate = %q, want %q", got, tt.challenge)
+			}
+			want := ""
+			if tt.want == http.StatusOK {
+				want = "ada"
+			}
+			if subject != want {
+				t.Errorf("handler saw subject %q, want %q", subject, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Package authz decides what a caller may do with users, from the roles on
+// the principal in the context, and enforces it in front of a UserService:
+//
+//	users := authz.New(userService)
+//	httptransport.NewHandler(users, logger)
+//
+// Authentication is the auth package's job, authz only reads the principal
+// auth.Middleware stored. A call without one fails with
+// errs.ErrUnauthenticated, a call the principal's roles do not grant with
+// errs.ErrForbidden, and neither reaches the wrapped service.
+package authz
+
+import (
+	"slices"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+)
+
+// Role names a set of grants in a Policy and is what a token's roles
+// claim lists.
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"
+	RoleMember Role = "member"
+)
+
+// Permission is one kind of call on users, Service maps each method to one.
+type Permission string
+
+const (
+	CreateUser  Permission = "users:create"
+	ReadUser    Permission = "users:read"
+	ListUsers   Permission = "users:list"
+	UpdateUser  Permission = "users:update"
+	DeleteUser  Permission = "users:delete"
+	RestoreUser Permission = "users:restore"
+	PurgeUser   Permission = "users:purge"
+	RenameUser  Permission = "users:rename"
+	SetPassword Permission = "users:set_password"
+)
+
+// Grant allows a Permission on every user, or with Own set only on the
+// principal's own, the user whose ID is their Subject.
+type Grant struct {
+	Permission Permission
+	Own        bool
+}
+
+// Any grants p on every user.
+func Any(p Permission) Grant {
+	return Grant{Permission: p}
+}
+
+// Own grants p on the principal's own user only.
+func Own(p Permission) Grant {
+	return Grant{Permission: p, Own: true}
+}
+
+// Policy lists each role's grants, a principal holds the union of its
+// roles'. Roles a policy does not list grant nothing.
+type Policy map[Role][]Grant
+
+// DefaultPolicy lets admins do everything and members read and update
+// themselves and set their own password.
+//
+//	permission          admin  member
+//	users:create        any    -
+//	users:read          any    own
+//	users:list          any    -
+//	users:update        any    own
+//	users:delete        any    -
+//	users:restore       any    -
+//	users:purge         any    -
+//	users:rename        any    -
+//	users:set_password  any    own
+var DefaultPolicy = Policy{
+	RoleAdmin: {
+		Any(CreateUser), Any(ReadUser), Any(ListUsers), Any(UpdateUser), Any(DeleteUser),
+		Any(RestoreUser), Any(PurgeUser), Any(RenameUser), Any(SetPassword),
+	},
+	RoleMember: {
+		Own(ReadUser), Own(UpdateUser), Own(SetPassword),
+	},
+}
+
+// Allows reports whether p may use perm on the user with ID target. Pass
+// an empty target for calls not about one user, such as listing, only an
+// Any grant covers those.
+func (policy Policy) Allows(p ctxutil.Principal, perm Permission, target string) bool {
+	for _, role := range p.Roles {
+		allowed := slices.ContainsFunc(policy[Role(role)], func(g Grant) bool {
+			return g.Permission == perm && (!g.Own || target != "" && target == p.Subject)
+		})
+		if allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package authz_test
+
+import (
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/authz"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+)
+
+func TestAllows(t *testing.T) {
+	policy := authz.Policy{
+		"support": {authz.Any(authz.ReadUser)},
+		"self":    {authz.Own(authz.UpdateUser)},
+	}
+	for _, tt := range []struct {
+		name   string
+		roles  []string
+		perm   authz.Permission
+		target string
+		want   bool
+	}{
+		{"any grant, someone else", []string{"support"}, authz.ReadUser, "grace", true},
+		{"any grant, no target", []string{"support"}, authz.ReadUser, "", true},
+		{"own grant, self", []string{"self"}, authz.UpdateUser, "ada", true},
+		{"own grant, someone else", []string{"self"}, authz.UpdateUser, "grace", false},
+		// an empty target is not the principal's own, even with no Subject
+		{"own grant, no target", []string{"self"}, authz.UpdateUser, "", false},
+		{"another permission", []string{"support"}, authz.UpdateUser, "ada", false},
+		{"the union of roles", []string{"self", "support"}, authz.ReadUser, "grace", true},
+		{"a role the policy does not list", []string{"admin"}, authz.ReadUser, "ada", false},
+		{"no roles", nil, authz.ReadUser, "ada", false},
+	} {
+		p := ctxutil.Principal{Subject: "ada", Roles: tt.roles}
+		if got := policy.Allows(p, tt.perm, tt.target); got != tt.want {
+			t.Errorf("%s: Allows(%s, %q) = %t, want %t", tt.name, tt.perm, tt.target, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package authz
+
+import (
+	"context"
+	"io"
+	"iter"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// UserService is the part of *service.UserService that Service guards.
+// VerifyPassword is left out on purpose: it is how a caller logs in, before
+// there is a principal to check.
+type UserService interface {
+	CreateUser(ctx context.Context, user *service.User) error
+	CreateUsers(ctx context.Context, users []*service.User) (service.BatchResult, error)
+	RetrieveUser(ctx context.Context, id string, opts ...service.ReadOption) (*service.User, error)
+	RetrieveUserByEmail(ctx context.Context, email string, opts ...service.ReadOption) (*service.User, error)
+	RetrieveUsers(ctx context.Context, ids []string, opts ...service.ReadOption) ([]*service.User, error)
+	ListUsers(ctx context.Context, req service.PageRequest, opts ...service.ReadOption) (service.Page[service.User], error)
+	ListAll(ctx context.Context, opts ...service.ReadOption) iter.Seq2[*service.User, error]
+	UpdateUser(ctx context.Context, user *service.User) error
+	DeleteUser(ctx context.Context, id string) error
+	RestoreUser(ctx context.Context, id string) error
+	PurgeUser(ctx context.Context, id string) error
+	RenameUser(ctx context.Context, oldID, newID string) error
+	SetPassword(ctx context.Context, id, password string) error
+	ImportUsersCSV(ctx context.Context, r io.Reader) (service.ImportResult, error)
+	ExportUsersCSV(ctx context.Context, w io.Writer) error
+}
+
+type Option func(*Service)
+
+// WithPolicy replaces DefaultPolicy.
+func WithPolicy(policy Policy) Option {
+	return func(s *Service) {
+		s.policy = policy
+	}
+}
+
+// Service decorates a UserService, checking every call against its policy
+// before passing it on. A lookup by email and the list calls are not about
+// a user known up front, so Own grants do not cover them.
+type Service struct {
+	next   UserService
+	policy Policy
+}
+
+func New(next UserService, opts ...Option) *Service {
+	s := &Service{
+		next:   next,
+		policy: DefaultPolicy,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// check fails with errs.ErrUnauthenticated without a principal and with
+// errs.ErrForbidden when the policy does not grant perm on target.
+func (s *Service) check(ctx context.Context, op string, perm Permission, target string) error {
+	p, ok := ctxutil.PrincipalFrom(ctx)
+	if !ok {
+		return errs.Wrap(op, errs.ErrUnauthenticated)
+	}
+	if !s.policy.Allows(p, perm, target) {
+		return errs.Wrap(op, errs.ErrForbidden)
+	}
+	return nil
+}
+
+func (s *Service) CreateUser(ctx context.Context, user *service.User) error {
+	if err := s.check(ctx, "authz.Service.CreateUser", CreateUser, userID(user)); err != nil {
+		return err
+	}
+	return s.next.CreateUser(ctx, user)
+}
+
+// CreateUsers refuses the whole batch when any user in it is not allowed.
+func (s *Service) CreateUsers(ctx context.Context, users []*service.User) (service.BatchResult, error) {
+	for _, user := range users {
+		if err := s.check(ctx, "authz.Service.CreateUsers", CreateUser, userID(user)); err != nil {
+			return service.BatchResult{}, err
+		}
+	}
+	return s.next.CreateUsers(ctx, users)
+}
+
+func (s *Service) RetrieveUser(ctx context.Context, id string, opts ...service.ReadOption) (*service.User, error) {
+	if err := s.check(ctx, "authz.Service.RetrieveUser", ReadUser, id); err != nil {
+		return nil, err
+	}
+	return s.next.RetrieveUser(ctx, id, opts...)
+}
+
+func (s *Service) RetrieveUserByEmail(ctx context.Context, email string, opts ...service.ReadOption) (*service.User, error) {
+	if err := s.check(ctx, "authz.Service.RetrieveUserByEmail", ReadUser, ""); err != nil {
+		return nil, err
+	}
+	return s.next.RetrieveUserByEmail(ctx, email, opts...)
+}
+
+func (s *Service) RetrieveUsers(ctx context.Context, ids []string, opts ...service.ReadOption) ([]*service.User, error) {
+	for _, id := range ids {
+		if err := s.check(ctx, "authz.Service.RetrieveUsers", ReadUser, id); err != nil {
+			return nil, err
+		}
+	}
+	return s.next.RetrieveUsers(ctx, ids, opts...)
+}
+
+func (s *Service) ListUsers(ctx context.Context, req service.PageRequest, opts ...service.ReadOption) (service.Page[service.User], error) {
+	if err := s.check(ctx, "authz.Service.ListUsers", ListUsers, ""); err != nil {
+		return service.Page[service.User]{}, err
+	}
+	return s.next.ListUsers(ctx, req, opts...)
+}
+
+// ListAll checks once, when the loop starts, and yields the refusal as the
+// sequence's only error.
+func (s *Service) ListAll(ctx context.Context, opts ...service.ReadOption) iter.Seq2[*service.User, error] {
+	return func(yield func(*service.User, error) bool) {
+		if err := s.check(ctx, "authz.Service.ListAll", ListUsers, ""); err != nil {
+			yield(nil, err)
+			return
+		}
+		for user, err := range s.next.ListAll(ctx, opts...) {
+			if !yield(user, err) {
+				return
+			}
+		}
+	}
+}
+
+func (s *Service) UpdateUser(ctx context.Context, user *service.User) error {
+	if err := s.check(ctx, "authz.Service.UpdateUser", UpdateUser, userID(user)); err != nil {
+		return err
+	}
+	return s.next.UpdateUser(ctx, user)
+}
+
+func (s *Service) DeleteUser(ctx context.Context, id string) error {
+	if err := s.check(ctx, "authz.Service.DeleteUser", DeleteUser, id); err != nil {
+		return err
+	}
+	return s.next.DeleteUser(ctx, id)
+}
+
+func (s *Service) RestoreUser(ctx context.Context, id string) error {
+	if err := s.check(ctx, "authz.Service.RestoreUser", RestoreUser, id); err != nil {
+		return err
+	}
+	return s.next.RestoreUser(ctx, id)
+}
+
+func (s *Service) PurgeUser(ctx context.Context, id string) error {
+	if err := s.check(ctx, "authz.Service.PurgeUser", PurgeUser, id); err != nil {
+		return err
+	}
+	return s.next.PurgeUser(ctx, id)
+}
+
+// RenameUser checks the user being renamed, an Own grant lets a principal
+// rename themselves away from their own Subject.
+func (s *Service) RenameUser(ctx context.Context, oldID, newID string) error {
+	if err := s.check(ctx, "authz.Service.RenameUser", RenameUser, oldID); err != nil {
+		return err
+	}
+	return s.next.RenameUser(ctx, oldID, newID)
+}
+
+func (s *Service) SetPassword(ctx context.Context, id, password string) error {
+	if err := s.check(ctx, "authz.Service.SetPassword", SetPassword, id); err != nil {
+		return err
+	}
+	return s.next.SetPassword(ctx, id, password)
+}
+
+// ImportUsersCSV needs CreateUser on any user, the rows are not known until
+// the file is read.
+func (s *Service) ImportUsersCSV(ctx context.Context, r io.Reader) (service.ImportResult, error) {
+	if err := s.check(ctx, "authz.Service.ImportUsersCSV", CreateUser, ""); err != nil {
+		return service.ImportResult{}, err
+	}
+	return s.next.ImportUsersCSV(ctx, r)
+}
+
+func (s *Service) ExportUsersCSV(ctx context.Context, w io.Writer) error {
+	if err := s.check(ctx, "authz.Service.ExportUsersCSV", ListUsers, ""); err != nil {
+		return err
+	}
+	return s.next.ExportUsersCSV(ctx, w)
+}
+
+// userID guards against a nil user, which the wrapped service rejects as
+// invalid input once it is let through.
+func userID(user *service.User) string {
+	if user == nil {
+		return ""
+	}
+	return user.ID
+}
@@ -0,0 +1,190 @@
+package authz_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/authz"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/credentials"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+type outcome string
+
+const (
+	allowed   outcome = "ok"
+	forbidden outcome = "forbidden"
+	anonymous outcome = "unauthenticated"
+)
+
+// caller is who makes the call and on which user.
+type caller struct {
+	name   string
+	ctx    context.Context
+	target string
+}
+
+func principal(subject string, roles ...authz.Role) context.Context {
+	p := ctxutil.Principal{Subject: subject}
+	for _, role := range roles {
+		p.Roles = append(p.Roles, string(role))
+	}
+	return ctxutil.WithPrincipal(context.Background(), p)
+}
+
+var callers = []caller{
+	{"admin", principal("root", authz.RoleAdmin), "ada"},
+	{"member on self", principal("ada", authz.RoleMember), "ada"},
+	{"member on other", principal("ada", authz.RoleMember), "grace"},
+	{"no roles", principal("ada"), "ada"},
+	{"anonymous", context.Background(), "ada"},
+}
+
+// method calls one UserService method on target. users is the undecorated
+// service, for setting up what the call needs.
+type method struct {
+	name string
+	// want is the outcome for each of callers, in order
+	want []outcome
+	call func(ctx context.Context, s *authz.Service, users *service.UserService, target string) error
+}
+
+var (
+	adminOnly = []outcome{allowed, forbidden, forbidden, forbidden, anonymous}
+	ownToo    = []outcome{allowed, allowed, forbidden, forbidden, anonymous}
+)
+
+var methods = []method{
+	{"CreateUser", adminOnly, func(ctx context.Context, s *authz.Service, _ *service.UserService, target string) error {
+		return s.CreateUser(ctx, &service.User{ID: target + "-2", Email: target + "-2@example.com"})
+	}},
+	{"CreateUsers", adminOnly, func(ctx context.Context, s *authz.Service, _ *service.UserService, target string) error {
+		_, err := s.CreateUsers(ctx, []*service.User{{ID: target + "-2", Email: target + "-2@example.com"}})
+		return err
+	}},
+	{"RetrieveUser", ownToo, func(ctx context.Context, s *authz.Service, _ *service.UserService, target string) error {
+		_, err := s.RetrieveUser(ctx, target)
+		return err
+	}},
+	{"RetrieveUsers", ownToo, func(ctx context.Context, s *authz.Service, _ *service.UserService, target string) error {
+		_, err := s.RetrieveUsers(ctx, []string{target})
+		return err
+	}},
+	{"RetrieveUserByEmail", adminOnly, func(ctx context.Context, s *authz.Service, _ *service.UserService, target string) error {
+		_, err := s.RetrieveUserByEmail(ctx, target+"@example.com")
+		return err
+	}},
+	{"ListUsers", adminOnly, func(ctx context.Context, s *authz.Service, _ *service.UserService, _ string) error {
+		_, err := s.ListUsers(ctx, service.PageRequest{})
+		return err
+	}},
+	{"ListAll", adminOnly, func(ctx context.Context, s *authz.Service, _ *service.UserService, _ string) error {
+		for _, err := range s.ListAll(ctx) {
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{"UpdateUser", ownToo, func(ctx context.Context, s *authz.Service, users *service.UserService, target string) error {
+		user, err := users.RetrieveUser(ctx, target)
+		if err != nil {
+			return err
+		}
+		user.Name = "Renamed"
+		return s.UpdateUser(ctx, user)
+	}},
+	{"DeleteUser", adminOnly, func(ctx context.Context, s *authz.Service, _ *service.UserService, target string) error {
+		return s.DeleteUser(ctx, target)
+	}},
+	{"RestoreUser", adminOnly, func(ctx context.Context, s *authz.Service, users *service.UserService, target string) error {
+		if err := users.DeleteUser(ctx, target); err != nil {
+			return err
+		}
+		return s.RestoreUser(ctx, target)
+	}},
+	{"PurgeUser", adminOnly, func(ctx context.Context, s *authz.Service, _ *service.UserService, target string) error {
+		return s.PurgeUser(ctx, target)
+	}},
+	{"RenameUser", adminOnly, func(ctx context.Context, s *authz.Service, _ *service.UserService, target string) error {
+		return s.RenameUser(ctx, target, target+"-renamed")
+	}},
+	{"SetPassword", ownToo, func(ctx context.Context, s *authz.Service, _ *service.UserService, target string) error {
+		return s.SetPassword(ctx, target, "correct horse")
+	}},
+	{"ImportUsersCSV", adminOnly, func(ctx context.Context, s *authz.Service, _ *service.UserService, target string) error {
+		_, err := s.ImportUsersCSV(ctx, strings.NewReader("id,email\n"+target+"-2,"+target+"-2@example.com\n"))
+		return err
+	}},
+	{"ExportUsersCSV", adminOnly, func(ctx context.Context, s *authz.Service, _ *service.UserService, _ string) error {
+		return s.ExportUsersCSV(ctx, io.Discard)
+	}},
+}
+
+// TestService runs every UserService method as every kind of caller
+// through a Service with the DefaultPolicy, and checks each is let through
+// or refused as the policy's table says.
+func TestService(t *testing.T) {
+	for _, m := range methods {
+		t.Run(m.name, func(t *testing.T) {
+			for i, c := range callers {
+				t.Run(c.name, func(t *testing.T) {
+					if got := call(t, m, c); got != m.want[i] {
+						t.Errorf("got %s, want %s", got, m.want[i])
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestWithPolicy(t *testing.T) {
+	policy := authz.Policy{authz.RoleMember: {authz.Any(authz.ReadUser)}}
+	for _, tt := range []struct {
+		method string
+		c      caller
+		want   outcome
+	}{
+		{"RetrieveUser", callers[2], allowed},
+		{"UpdateUser", callers[1], forbidden},
+		// the admin role is not in this policy
+		{"DeleteUser", callers[0], forbidden},
+	} {
+		i := slices.IndexFunc(methods, func(m method) bool { return m.name == tt.method })
+		if got := call(t, methods[i], tt.c, authz.WithPolicy(policy)); got != tt.want {
+			t.Errorf("%s as %s: got %s, want %s", tt.method, tt.c.name, got, tt.want)
+		}
+	}
+}
+
+// call calls m as c through a Service built with opts over a fresh
+// service holding ada and grace. Any failure other than a refusal fails the
+// test, an allowed call should go through.
+func call(t *testing.T, m method, c caller, opts ...authz.Option) outcome {
+	t.Helper()
+	ctx := context.Background()
+	users := service.NewUserService(db.NewMemoryStore(), service.WithPasswords(credentials.NewMemory(), credentials.Bcrypt{Cost: 4}))
+	for _, id := range []string{"ada", "grace"} {
+		if err := users.CreateUser(ctx, &service.User{ID: id, Email: id + "@example.com"}); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+	err := m.call(c.ctx, authz.New(users, opts...), users, c.target)
+	switch {
+	case err == nil:
+		return allowed
+	case errors.Is(err, errs.ErrForbidden):
+		return forbidden
+	case errors.Is(err, errs.ErrUnauthenticated):
+		return anonymous
+	}
+	t.Fatalf("%s: %v", m.name, err)
+	return ""
+}
@@ -0,0 +1,33 @@
+// Package blob stores opaque byte streams by key, for what is too big or
+// too binary to sit next to a user in the database, like avatars. Memory
+// keeps them in a map, Disk in files under one directory.
+//
+// Put streams: a blob is never held in memory whole on its way to Disk,
+// and a Put whose reader fails part way, a request body over its limit
+// say, stores nothing and leaves any earlier blob under the key in place.
+//
+// Keys are slash-separated relative paths as io/fs has them, "avatars/42".
+// Anything else, "../etc/passwd" included, is errs.ErrInvalidInput.
+package blob
+
+import (
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// Info describes a stored blob.
+type Info struct {
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+}
+
+func checkKey(key string) error {
+	if !fs.ValidPath(key) || key == "." {
+		return fmt.Errorf("key %q: %w", key, errs.ErrInvalidInput)
+	}
+	return nil
+}
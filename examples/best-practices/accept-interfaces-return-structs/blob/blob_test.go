@@ -0,0 +1,95 @@
+package blob_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/blob"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// store is what Memory and Disk have in common.
+type store interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, blob.Info, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// forEachStore runs fn against a new Memory and a new Disk.
+func forEachStore(t *testing.T, fn func(t *testing.T, s store)) {
+	t.Run("memory", func(t *testing.T) { fn(t, blob.NewMemory()) })
+	t.Run("disk", func(t *testing.T) {
+		d, err := blob.NewDisk(t.TempDir())
+		if err != nil {
+			t.Fatalf("NewDisk: %v", err)
+		}
+		t.Cleanup(func() { d.Close() })
+		fn(t, d)
+	})
+}
+
+// content reads the blob under key.
+func content(t *testing.T, s store, key string) (string, blob.Info) {
+	t.Helper()
+	r, info, err := s.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get %s: %v", key, err)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading %s: %v", key, err)
+	}
+	return string(b), info
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, s store) {
+		if err := s.Put(ctx, "avatars/ada", strings.NewReader("png bytes"), "image/png"); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		if got, info := content(t, s, "avatars/ada"); got != "png bytes" || info.ContentType != "image/png" || info.Size != 9 {
+			t.Errorf("Get = %q, %+v, want the png bytes", got, info)
+		}
+		if err := s.Delete(ctx, "avatars/ada"); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, _, err := s.Get(ctx, "avatars/ada"); !errors.Is(err, errs.ErrNotFound) {
+			t.Errorf("Get after Delete: err = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+// TestFailedPutKeepsBlob checks a reader failing part way stores nothing.
+func TestFailedPutKeepsBlob(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, s store) {
+		if err := s.Put(ctx, "avatars/ada", strings.NewReader("first"), "image/png"); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		boom := errors.New("boom")
+		r := io.MultiReader(strings.NewReader("second, cut"), iotest.ErrReader(boom))
+		if err := s.Put(ctx, "avatars/ada", r, "image/jpeg"); !errors.Is(err, boom) {
+			t.Errorf("Put: err = %v, want the reader's", err)
+		}
+		if got, info := content(t, s, "avatars/ada"); got != "first" || info.ContentType != "image/png" {
+			t.Errorf("Get = %q, %s, want the first blob", got, info.ContentType)
+		}
+	})
+}
+
+func TestBadKeys(t *testing.T) {
+	ctx := context.Background()
+	forEachStore(t, func(t *testing.T, s store) {
+		for _, key := range []string{"../escape", "/abs", "a/../../b", ".", ""} {
+			if err := s.Put(ctx, key, strings.NewReader("x"), "text/plain"); !errors.Is(err, errs.ErrInvalidInput) {
+				t.Errorf("Put %q: err = %v, want ErrInvalidInput", key, err)
+			}
+		}
+	})
+}
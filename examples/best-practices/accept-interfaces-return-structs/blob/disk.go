@@ -0,0 +1,148 @@
+package blob
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// Disk keeps each blob in a file under one directory, named by its key.
+// The file holds the content type on its first line and the blob after
+// it, so the two are written, and replaced, together.
+//
+// Every path goes through an os.Root, which refuses to leave the
+// directory by any route, symlinks included, so a key is only ever a
+// name in it even if checkKey had a hole.
+type Disk struct {
+	root *os.Root
+}
+
+// NewDisk stores blobs under dir, creating it if need be. Close the Disk
+// when done with it.
+func NewDisk(dir string) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errs.Wrap("blob.NewDisk", err)
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, errs.Wrap("blob.NewDisk", err)
+	}
+	return &Disk{root: root}, nil
+}
+
+func (d *Disk) Close() error {
+	return d.root.Close()
+}
+
+// Put streams r into a temporary file next to the blob's and renames it
+// over the blob once r is done, so a reader never sees half a blob and a
+// failed Put leaves the old one.
+func (d *Disk) Put(ctx context.Context, key string, r io.Reader, contentType string) (err error) {
+	defer func() { err = errs.Wrap("blob.Disk.Put", err) }()
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	if strings.ContainsAny(contentType, "\r\n") {
+		return fmt.Errorf("content type %q: %w", contentType, errs.ErrInvalidInput)
+	}
+	if err := d.root.MkdirAll(path.Dir(key), 0o755); err != nil {
+		return err
+	}
+	tmp := key + ".tmp-" + rand.Text()
+	f, err := d.root.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			d.root.Remove(tmp)
+		}
+	}()
+	if _, err := io.WriteString(f, contentType+"\n"); err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, contextReader{ctx: ctx, r: r}); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return d.root.Rename(tmp, key)
+}
+
+// Get's reader is the open file, close it. The blob can be replaced while
+// it is read, the open file is still the old one.
+func (d *Disk) Get(ctx context.Context, key string) (_ io.ReadCloser, _ Info, err error) {
+	defer func() { err = errs.Wrap("blob.Disk.Get", err) }()
+	if err := checkKey(key); err != nil {
+		return nil, Info{}, err
+	}
+	f, err := d.root.Open(key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, Info{}, errs.ErrNotFound
+	}
+	if err != nil {
+		return nil, Info{}, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, Info{}, err
+	}
+	if stat.IsDir() {
+		f.Close()
+		return nil, Info{}, errs.ErrNotFound
+	}
+	br := bufio.NewReader(f)
+	contentType, err := br.ReadString('\n')
+	if err != nil {
+		f.Close()
+		return nil, Info{}, fmt.Errorf("%s has no content type line: %w", key, err)
+	}
+	info := Info{
+		ContentType: strings.TrimSuffix(contentType, "\n"),
+		Size:        stat.Size() - int64(len(contentType)),
+		ModTime:     stat.ModTime(),
+	}
+	return readCloser{Reader: br, Closer: f}, info, nil
+}
+
+func (d *Disk) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return errs.Wrap("blob.Disk.Delete", err)
+	}
+	err := d.root.Remove(key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return errs.Wrap("blob.Disk.Delete", errs.ErrNotFound)
+	}
+	return errs.Wrap("blob.Disk.Delete", err)
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// contextReader stops a long copy once ctx is done, io.Copy itself never
+// looks.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
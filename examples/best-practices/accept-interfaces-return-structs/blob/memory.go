@@ -0,0 +1,75 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// Memory keeps blobs in a map, for tests and demos. It is safe for
+// concurrent use.
+type Memory struct {
+	mu    sync.RWMutex
+	blobs map[string]memoryBlob
+	now   func() time.Time
+}
+
+type memoryBlob struct {
+	data []byte
+	info Info
+}
+
+func NewMemory() *Memory {
+	return &Memory{blobs: make(map[string]memoryBlob), now: time.Now}
+}
+
+// Put reads r to the end before storing anything, the map is only touched
+// once the whole blob is in.
+func (m *Memory) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	if err := checkKey(key); err != nil {
+		return errs.Wrap("blob.Memory.Put", err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return errs.Wrap("blob.Memory.Put", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("blob.Memory.Put", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blobs[key] = memoryBlob{data: data, info: Info{ContentType: contentType, Size: int64(len(data)), ModTime: m.now()}}
+	return nil
+}
+
+// Get's reader is over the stored bytes, which a later Put replaces
+// rather than changes, so it needs no lock.
+func (m *Memory) Get(ctx context.Context, key string) (io.ReadCloser, Info, error) {
+	if err := checkKey(key); err != nil {
+		return nil, Info{}, errs.Wrap("blob.Memory.Get", err)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, ok := m.blobs[key]
+	if !ok {
+		return nil, Info{}, errs.Wrap("blob.Memory.Get", errs.ErrNotFound)
+	}
+	return io.NopCloser(bytes.NewReader(b.data)), b.info, nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return errs.Wrap("blob.Memory.Delete", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.blobs[key]; !ok {
+		return errs.Wrap("blob.Memory.Delete", errs.ErrNotFound)
+	}
+	delete(m.blobs, key)
+	return nil
+}
@@ -0,0 +1,103 @@
+// Package bloom is a generic Bloom filter: a set that can say a value was
+// definitely never added, or was probably added, in a fixed number of bits
+// however large the values are. It never says "no" to a value it was
+// given, it says "yes" to one it was not at the rate it was sized for.
+//
+// That makes it a guard for lookups expected to miss, see db/guarded: a
+// "no" needs no trip to the store, a "yes" goes to the store as before.
+// Nothing can be taken out of a filter, a removed value stays a "maybe".
+package bloom
+
+import (
+	"hash/maphash"
+	"math"
+	"math/bits"
+	"sync/atomic"
+)
+
+// Filter is safe for concurrent use, its bits are set and read atomically
+// and no call takes a lock.
+type Filter[T comparable] struct {
+	seed  maphash.Seed
+	words []atomic.Uint64
+	m     uint64
+	k     int
+	added atomic.Uint64
+}
+
+// New sizes a filter for n values at false positive rate p, which it keeps
+// to while no more than n are added and drifts above after. It panics
+// unless n > 0 and 0 < p < 1, a programming error rather than an input.
+func New[T comparable](n int, p float64) *Filter[T] {
+	if n <= 0 || p <= 0 || p >= 1 {
+		panic("bloom: New needs n > 0 and 0 < p < 1")
+	}
+	m, k := Optimal(n, p)
+	return &Filter[T]{
+		seed:  maphash.MakeSeed(),
+		words: make([]atomic.Uint64, (m+63)/64),
+		m:     uint64(m),
+		k:     k,
+	}
+}
+
+// Optimal returns the bits m and hash functions k that hold n values at
+// false positive rate p in the fewest bits: m = -n ln p / (ln 2)², about
+// 9.6 bits a value at 1%, and k = m/n ln 2.
+func Optimal(n int, p float64) (m, k int) {
+	m = int(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k = max(1, int(math.Round(float64(m)/float64(n)*math.Ln2)))
+	return m, k
+}
+
+// Add puts v in the filter.
+func (f *Filter[T]) Add(v T) {
+	h1, h2 := f.hash(v)
+	for i := range f.k {
+		bit := (h1 + uint64(i)*h2) % f.m
+		f.words[bit/64].Or(1 << (bit % 64))
+	}
+	f.added.Add(1)
+}
+
+// Test reports whether v may have been added. False is certain, true is
+// wrong at the filter's false positive rate.
+func (f *Filter[T]) Test(v T) bool {
+	h1, h2 := f.hash(v)
+	for i := range f.k {
+		bit := (h1 + uint64(i)*h2) % f.m
+		if f.words[bit/64].Load()&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hash derives the k bit positions from one 64 bit hash split in two,
+// h1 + i*h2, which is as good as k independent hashes for a Bloom filter
+// (Kirsch and Mitzenmacher). h2 is made odd so it is never zero, which
+// would put all k on one bit.
+func (f *Filter[T]) hash(v T) (h1, h2 uint64) {
+	h := maphash.Comparable(f.seed, v)
+	return h & math.MaxUint32, h>>32 | 1
+}
+
+// Bits is the filter's size m, Hashes the bit positions per value k.
+func (f *Filter[T]) Bits() int   { return int(f.m) }
+func (f *Filter[T]) Hashes() int { return f.k }
+
+// Added counts the calls to Add, a value added twice counts twice.
+func (f *Filter[T]) Added() int {
+	return int(f.added.Load())
+}
+
+// FalsePositiveRate estimates the rate Test is wrong at now, from the share
+// of bits set: a value never added passes only if all k of its bits are
+// set. It rises past the rate New was given once more than n are added.
+func (f *Filter[T]) FalsePositiveRate() float64 {
+	set := 0
+	for i := range f.words {
+		set += bits.OnesCount64(f.words[i].Load())
+	}
+	return math.Pow(float64(set)/float64(f.m), float64(f.k))
+}
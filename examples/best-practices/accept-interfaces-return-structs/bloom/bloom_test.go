@@ -0,0 +1,70 @@
+package bloom_test
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/bloom"
+)
+
+// TestFalsePositives checks the one promise, nothing added is missed, and
+// measures the false positive rate against the one the filter was sized
+// for.
+func TestFalsePositives(t *testing.T) {
+	const n = 100_000
+	for _, p := range []float64{0.1, 0.01, 0.001} {
+		t.Run(fmt.Sprint(p), func(t *testing.T) {
+			f := bloom.New[string](n, p)
+			for i := range n {
+				f.Add("in-" + strconv.Itoa(i))
+			}
+			for i := range n {
+				if !f.Test("in-" + strconv.Itoa(i)) {
+					t.Fatalf("in-%d was added and tests false", i)
+				}
+			}
+			wrong := 0
+			for i := range n {
+				if f.Test("out-" + strconv.Itoa(i)) {
+					wrong++
+				}
+			}
+			rate := float64(wrong) / n
+			t.Logf("%d bits, %.1f a value, %d hashes, measured %.4f, estimated %.4f",
+				f.Bits(), float64(f.Bits())/n, f.Hashes(), rate, f.FalsePositiveRate())
+			if rate > p*1.3 {
+				t.Errorf("false positive rate %.4f, sized for %g", rate, p)
+			}
+		})
+	}
+}
+
+func TestOptimal(t *testing.T) {
+	for _, tt := range []struct {
+		p            float64
+		bitsPerValue float64
+		k            int
+	}{
+		{0.01, 9.6, 7},
+		{0.001, 14.4, 10},
+	} {
+		m, k := bloom.Optimal(1000, tt.p)
+		if perValue := math.Round(float64(m)/100) / 10; perValue != tt.bitsPerValue || k != tt.k {
+			t.Errorf("Optimal(1000, %g) = %.1f bits a value, %d hashes, want %.1f and %d", tt.p, perValue, k, tt.bitsPerValue, tt.k)
+		}
+	}
+}
+
+func TestComparableKeys(t *testing.T) {
+	type key struct {
+		tenant string
+		id     int
+	}
+	f := bloom.New[key](100, 0.01)
+	f.Add(key{"acme", 1})
+	if !f.Test(key{"acme", 1}) || f.Added() != 1 {
+		t.Errorf("Test = %v with %d added, want an added struct key found", f.Test(key{"acme", 1}), f.Added())
+	}
+}
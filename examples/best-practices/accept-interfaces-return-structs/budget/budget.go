@@ -0,0 +1,127 @@
+// Package budget splits the time left on a context across the steps of an
+// operation that run one after another, validate, store, publish, so one
+// slow dependency cannot spend the whole deadline and leave the steps
+// after it none.
+//
+// A step's share is worked out as it starts, from the time left then: its
+// weight against the weights of the steps still to run, after setting
+// aside their minimums. A step that finishes early leaves its unused time
+// to the rest, one that runs to its deadline ends there. The last step
+// has everything left. Nothing is split when the context has no deadline.
+package budget
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+)
+
+// Step is one step of a Plan.
+type Step struct {
+	Name string
+	// Weight is the step's share against the steps after it, zero or less
+	// counts as 1.
+	Weight float64
+	// Min is set aside for the step while the steps before it run, so it
+	// starts with at least Min left unless the parent's deadline is
+	// already closer than that.
+	Min time.Duration
+}
+
+// Option configures a Plan.
+type Option func(*Plan)
+
+// WithClock sets the clock steps are timed by, the default is clock.Real.
+// On a *clock.Fake, a parent context made with clock.WithDeadline on the
+// same fake is what gives the steps a deadline to split.
+func WithClock(c clock.Clock) Option {
+	return func(p *Plan) {
+		p.clock = c
+	}
+}
+
+// Plan is the steps of an operation, built once and started per call.
+type Plan struct {
+	steps []Step
+	clock clock.Clock
+}
+
+// NewPlan returns a plan for steps in the order they run. It panics on a
+// step without a name or two with one name, the steps are fixed in the
+// code that runs them.
+func NewPlan(steps []Step, opts ...Option) *Plan {
+	p := &Plan{steps: make([]Step, len(steps)), clock: clock.Real}
+	seen := make(map[string]bool, len(steps))
+	for i, s := range steps {
+		if s.Name == "" || seen[s.Name] {
+			panic(fmt.Sprintf("budget: step %d has an empty or repeated name %q", i, s.Name))
+		}
+		seen[s.Name] = true
+		if s.Weight <= 0 {
+			s.Weight = 1
+		}
+		s.Min = max(s.Min, 0)
+		p.steps[i] = s
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Start begins a run of the plan against ctx's deadline. A nil *Plan
+// splits nothing, each step gets ctx's deadline as it is.
+func (p *Plan) Start(ctx context.Context) *Budget {
+	return &Budget{plan: p, ctx: ctx}
+}
+
+// Budget is one run of a Plan. It is not safe for concurrent use, the
+// steps are sequential.
+type Budget struct {
+	plan *Plan
+	ctx  context.Context
+	// next is the index of the first step not started
+	next int
+}
+
+// Step returns the context for the named step, derived from the one given
+// to Start, with a deadline of the step's share of the time left. Steps
+// are started in plan order, those between the last one and name are
+// skipped and their time goes to the rest. It panics for a name that is
+// not a later step of the plan.
+func (b *Budget) Step(name string) (context.Context, context.CancelFunc) {
+	if b.plan == nil {
+		return context.WithCancel(b.ctx)
+	}
+	i := b.next
+	for i < len(b.plan.steps) && b.plan.steps[i].Name != name {
+		i++
+	}
+	if i == len(b.plan.steps) {
+		panic(fmt.Sprintf("budget: %q is not a step after the last one started", name))
+	}
+	b.next = i + 1
+	deadline, ok := b.ctx.Deadline()
+	if !ok {
+		return context.WithCancel(b.ctx)
+	}
+	now := b.plan.clock.Now()
+	return clock.WithDeadline(b.ctx, b.plan.clock, now.Add(b.plan.allot(i, deadline.Sub(now))))
+}
+
+// allot is step i's share of left.
+func (p *Plan) allot(i int, left time.Duration) time.Duration {
+	if i == len(p.steps)-1 || left <= 0 {
+		return left
+	}
+	var reserve time.Duration
+	weights := p.steps[i].Weight
+	for _, s := range p.steps[i+1:] {
+		reserve += s.Min
+		weights += s.Weight
+	}
+	share := time.Duration(float64(left-reserve) * p.steps[i].Weight / weights)
+	return min(left, max(share, p.steps[i].Min))
+}
@@ -0,0 +1,147 @@
+package budget_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/budget"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+)
+
+var start = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// steps is a create's validate, store and publish, as service splits them.
+var steps = []budget.Step{
+	{Name: "validate", Weight: 1},
+	{Name: "store", Weight: 2, Min: 250 * time.Millisecond},
+	{Name: "publish", Weight: 1, Min: 100 * time.Millisecond},
+}
+
+// started starts plan on fake with a parent deadline of timeout.
+func started(t *testing.T, fake *clock.Fake, timeout time.Duration, plan []budget.Step) (context.Context, *budget.Budget) {
+	t.Helper()
+	parent, cancel := clock.WithTimeout(context.Background(), fake, timeout)
+	t.Cleanup(cancel)
+	return parent, budget.NewPlan(plan, budget.WithClock(fake)).Start(parent)
+}
+
+// stepLeft starts the named step and returns how long it has.
+func stepLeft(t *testing.T, b *budget.Budget, c clock.Clock, name string) (time.Duration, context.CancelFunc) {
+	t.Helper()
+	ctx, cancel := b.Step(name)
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		cancel()
+		t.Fatalf("step %s has no deadline", name)
+	}
+	return deadline.Sub(c.Now()), cancel
+}
+
+// TestShares checks steps share the time left and early finishes pass
+// theirs on.
+func TestShares(t *testing.T) {
+	fake := clock.NewFake(start)
+	_, b := started(t, fake, 2*time.Second, steps)
+	// 2s less the 350ms set aside for store and publish, a quarter of it
+	left, done := stepLeft(t, b, fake, "validate")
+	if want := 412500 * time.Microsecond; left != want {
+		t.Errorf("validate has %s, want %s", left, want)
+	}
+	fake.Advance(100 * time.Millisecond)
+	done()
+	// 1.9s less publish's 100ms, two thirds of it
+	left, done = stepLeft(t, b, fake, "store")
+	if want := 1200 * time.Millisecond; left != want {
+		t.Errorf("store has %s, want %s", left, want)
+	}
+	fake.Advance(time.Second)
+	done()
+	left, done = stepLeft(t, b, fake, "publish")
+	defer done()
+	if want := 900 * time.Millisecond; left != want {
+		t.Errorf("publish has %s, want all of the %s left", left, want)
+	}
+}
+
+// TestMin checks a later step's minimum is kept when its share is less.
+func TestMin(t *testing.T) {
+	fake := clock.NewFake(start)
+	_, b := started(t, fake, time.Second, []budget.Step{
+		{Name: "a", Weight: 3},
+		{Name: "b", Min: 300 * time.Millisecond},
+		{Name: "c"},
+	})
+	var got []time.Duration
+	for _, name := range []string{"a", "b", "c"} {
+		left, done := stepLeft(t, b, fake, name)
+		got = append(got, left)
+		// every step runs to its deadline
+		fake.Advance(left)
+		done()
+	}
+	// b's half of what a leaves is 290ms, under its minimum
+	if want := []time.Duration{420 * time.Millisecond, 300 * time.Millisecond, 280 * time.Millisecond}; !slices.Equal(got, want) {
+		t.Errorf("steps had %v, want %v", got, want)
+	}
+}
+
+// TestSkipped checks a skipped step's time goes to the rest.
+func TestSkipped(t *testing.T) {
+	fake := clock.NewFake(start)
+	_, b := started(t, fake, 2*time.Second, steps)
+	left, done := stepLeft(t, b, fake, "store")
+	defer done()
+	if want := 1900 * time.Millisecond * 2 / 3; left != want {
+		t.Errorf("store has %s, want %s", left, want)
+	}
+}
+
+// TestNoDeadline checks nothing is split without a deadline, or without a
+// plan.
+func TestNoDeadline(t *testing.T) {
+	ctx := context.Background()
+	step, cancel := budget.NewPlan(steps).Start(ctx).Step("validate")
+	defer cancel()
+	if deadline, ok := step.Deadline(); ok {
+		t.Errorf("validate has a deadline of %s", deadline)
+	}
+	var none *budget.Plan
+	parent, cancelParent := context.WithTimeout(ctx, time.Minute)
+	defer cancelParent()
+	want, _ := parent.Deadline()
+	step, cancel = none.Start(parent).Step("anything")
+	defer cancel()
+	if got, _ := step.Deadline(); !got.Equal(want) {
+		t.Errorf("a nil plan's step has %s, want the parent's %s", got, want)
+	}
+}
+
+// TestExpires checks a step expires on Advance, and so do contexts made
+// from it, while the parent goes on.
+func TestExpires(t *testing.T) {
+	fake := clock.NewFake(start)
+	parent, b := started(t, fake, 2*time.Second, steps)
+	step, done := b.Step("validate")
+	defer done()
+	child, cancelChild := context.WithCancel(step)
+	defer cancelChild()
+	fake.Advance(412 * time.Millisecond)
+	if err := step.Err(); err != nil {
+		t.Fatalf("step done 0.5ms early: %v", err)
+	}
+	fake.Advance(time.Millisecond)
+	select {
+	case <-child.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the step's child never expired")
+	}
+	if !errors.Is(step.Err(), context.DeadlineExceeded) || !errors.Is(child.Err(), context.DeadlineExceeded) {
+		t.Errorf("step %v, child %v, want both DeadlineExceeded", step.Err(), child.Err())
+	}
+	if err := parent.Err(); err != nil {
+		t.Errorf("the parent ended with its step: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+// Package bufpool reuses the buffers a request needs for as long as it
+// runs and not after: the bytes.Buffer a web page is rendered into before
+// it is sent, and the 4 KiB bufio.Readers and Writers that encoding/csv
+// would otherwise allocate for every file.
+//
+// A pool only pays where the allocation is per call and the value dies
+// with it, and where nothing pools it already. encoding/json does pool its
+// encode buffers, which is why the NDJSON export reuses its DTO instead.
+//
+// What goes back to a pool must not be used again, by the caller or by
+// anything it handed the value to. Put resets each value before pooling
+// it, so a bufio.Reader in the pool holds no reference to the last
+// request's body.
+package bufpool
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sync"
+)
+
+// maxBuffer is the largest bytes.Buffer PutBuffer keeps. A pool holds on
+// to what it is given, and one huge response would otherwise pin its
+// buffer for every small one after it.
+const maxBuffer = 64 << 10
+
+var (
+	buffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+	readers = sync.Pool{New: func() any { return bufio.NewReader(nil) }}
+	writers = sync.Pool{New: func() any { return bufio.NewWriter(nil) }}
+)
+
+// GetBuffer returns an empty buffer, give it back with PutBuffer.
+func GetBuffer() *bytes.Buffer {
+	return buffers.Get().(*bytes.Buffer)
+}
+
+// PutBuffer returns b to the pool, unless it has grown past maxBuffer.
+func PutBuffer(b *bytes.Buffer) {
+	if b.Cap() > maxBuffer {
+		return
+	}
+	b.Reset()
+	buffers.Put(b)
+}
+
+// GetReader returns a bufio.Reader of the default size reading r, give
+// it back with PutReader. encoding/csv's NewReader uses it as it is
+// rather than wrapping it in a bufio.Reader of its own.
+func GetReader(r io.Reader) *bufio.Reader {
+	br := readers.Get().(*bufio.Reader)
+	br.Reset(r)
+	return br
+}
+
+// PutReader returns br to the pool, anything it has buffered is dropped.
+func PutReader(br *bufio.Reader) {
+	br.Reset(nil)
+	readers.Put(br)
+}
+
+// GetWriter returns a bufio.Writer of the default size writing to w, give
+// it back with PutWriter once it is flushed. encoding/csv's NewWriter
+// uses it as it is.
+func GetWriter(w io.Writer) *bufio.Writer {
+	bw := writers.Get().(*bufio.Writer)
+	bw.Reset(w)
+	return bw
+}
+
+// PutWriter returns bw to the pool, anything it has not flushed is
+// dropped.
+func PutWriter(bw *bufio.Writer) {
+	bw.Reset(nil)
+	writers.Put(bw)
+}
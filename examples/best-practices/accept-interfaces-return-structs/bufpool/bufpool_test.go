@@ -0,0 +1,224 @@
+package bufpool_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/bufpool"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	httptransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/http"
+	webtransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/web"
+)
+
+func TestBufferEmpty(t *testing.T) {
+	buf := bufpool.GetBuffer()
+	buf.WriteString("left over")
+	bufpool.PutBuffer(buf)
+	if buf := bufpool.GetBuffer(); buf.Len() != 0 {
+		t.Errorf("GetBuffer = a buffer holding %q, want an empty one", buf.String())
+	}
+}
+
+// TestReaderOwnSource checks what a reader had buffered does not survive
+// the trip through the pool.
+func TestReaderOwnSource(t *testing.T) {
+	br := bufpool.GetReader(strings.NewReader("first\nsecond\n"))
+	br.ReadString('\n')
+	bufpool.PutReader(br)
+	br = bufpool.GetReader(strings.NewReader("third\n"))
+	defer bufpool.PutReader(br)
+	if got, _ := io.ReadAll(br); string(got) != "third\n" {
+		t.Errorf("read %q, want only the new source", got)
+	}
+}
+
+func TestWriterOwnDestination(t *testing.T) {
+	var first, second bytes.Buffer
+	bw := bufpool.GetWriter(&first)
+	bw.WriteString("never flushed")
+	bufpool.PutWriter(bw)
+	bw = bufpool.GetWriter(&second)
+	bw.WriteString("flushed")
+	bw.Flush()
+	bufpool.PutWriter(bw)
+	if first.Len() != 0 || second.String() != "flushed" {
+		t.Errorf("first %q, second %q, want nothing and flushed", first.String(), second.String())
+	}
+}
+
+// userLine has the shape of the NDJSON export's v1 DTO.
+type userLine struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name,omitempty"`
+	Version   int64     `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newUserLine(u *service.User) userLine {
+	return userLine{ID: u.ID, Email: u.Email, Name: u.Name, Version: u.Version, CreatedAt: u.CreatedAt, UpdatedAt: u.UpdatedAt}
+}
+
+// smallCSV is a users file of 10 rows.
+func smallCSV() string {
+	var b strings.Builder
+	b.WriteString("id,email,name\n")
+	for i := range 10 {
+		fmt.Fprintf(&b, "user-%02d,user%d@example.com,Ada Lovelace\n", i, i)
+	}
+	return b.String()
+}
+
+// BenchmarkChanges measures each change on its own, the way the code did
+// it before against the way it does it now.
+func BenchmarkChanges(b *testing.B) {
+	file := smallCSV()
+	record := []string{"user-01", "user1@example.com", "Ada Lovelace", "2026-01-02T03:04:05Z", "2026-01-02T03:04:05Z", "1"}
+	user := &service.User{ID: "user-01", Email: "user1@example.com", Name: "Ada Lovelace", Version: 1, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	page := template.Must(template.New("page").Parse(`<ul>{{range .}}<li>{{.ID}} {{.Email}} {{.Name}}</li>{{end}}</ul>`))
+	pageUsers := make([]*service.User, 20)
+	for i := range pageUsers {
+		pageUsers[i] = user
+	}
+	readAll := func(cr *csv.Reader) {
+		cr.ReuseRecord = true
+		for {
+			if _, err := cr.Read(); err != nil {
+				return
+			}
+		}
+	}
+
+	for _, c := range []struct {
+		name          string
+		before, after func()
+	}{
+		{"csv import", func() {
+			readAll(csv.NewReader(strings.NewReader(file)))
+		}, func() {
+			br := bufpool.GetReader(strings.NewReader(file))
+			readAll(csv.NewReader(br))
+			bufpool.PutReader(br)
+		}},
+		{"csv export", func() {
+			cw := csv.NewWriter(io.Discard)
+			for range 10 {
+				cw.Write(record)
+			}
+			cw.Flush()
+		}, func() {
+			bw := bufpool.GetWriter(io.Discard)
+			cw := csv.NewWriter(bw)
+			for range 10 {
+				cw.Write(record)
+			}
+			cw.Flush()
+			bufpool.PutWriter(bw)
+		}},
+		{"ndjson", func() {
+			enc := json.NewEncoder(io.Discard)
+			for range 10 {
+				enc.Encode(newUserLine(user))
+			}
+		}, func() {
+			enc := json.NewEncoder(io.Discard)
+			var line userLine
+			for range 10 {
+				line = newUserLine(user)
+				enc.Encode(&line)
+			}
+		}},
+		{"web page", func() {
+			var buf bytes.Buffer
+			page.Execute(&buf, pageUsers)
+			buf.WriteTo(io.Discard)
+		}, func() {
+			buf := bufpool.GetBuffer()
+			page.Execute(buf, pageUsers)
+			buf.WriteTo(io.Discard)
+			bufpool.PutBuffer(buf)
+		}},
+	} {
+		for _, v := range []struct {
+			name string
+			fn   func()
+		}{{"before", c.before}, {"after", c.after}} {
+			b.Run(c.name+"/"+v.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for b.Loop() {
+					v.fn()
+				}
+			})
+		}
+	}
+}
+
+// discard is a ResponseWriter that keeps nothing, so the benchmarks
+// measure the handler rather than a recorder growing its body.
+type discard struct{ header http.Header }
+
+func (d discard) Header() http.Header         { return d.header }
+func (d discard) Write(p []byte) (int, error) { return len(p), nil }
+func (d discard) WriteHeader(int)             {}
+func (d discard) Flush()                      {}
+
+// SetWriteDeadline is there because exportNDJSON sets one per line, and
+// the error for a writer without it is an allocation of its own.
+func (d discard) SetWriteDeadline(time.Time) error { return nil }
+
+// BenchmarkPaths measures the paths the changes sit on whole, over 1000
+// users, where the saving is a share of everything else a request does.
+func BenchmarkPaths(b *testing.B) {
+	ctx := context.Background()
+	logger := slog.New(slog.DiscardHandler)
+	users := service.NewUserService(db.NewMemoryStore())
+	for i := range 1_000 {
+		id := fmt.Sprintf("user-%04d", i)
+		if err := users.CreateUser(ctx, &service.User{ID: id, Email: id + "@example.com", Name: "Ada Lovelace"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	api := httptransport.NewHandler(users, logger)
+	web, err := webtransport.NewHandler(users, logger)
+	if err != nil {
+		b.Fatal(err)
+	}
+	serve := func(h http.Handler, path string) func() {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		return func() { h.ServeHTTP(discard{header: http.Header{}}, req) }
+	}
+	file := smallCSV()
+	for _, p := range []struct {
+		name string
+		fn   func()
+	}{
+		{"ImportUsersCSV", func() {
+			// every row a duplicate after the first run, the import is
+			// all reading and validating
+			users.ImportUsersCSV(ctx, strings.NewReader(file))
+		}},
+		{"users.csv", serve(api, "/users.csv")},
+		{"export", serve(api, "/users/export")},
+		{"web", serve(web, "/")},
+	} {
+		b.Run(p.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				p.fn()
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+// Package cache is a generic, size bounded LRU with optional expiry. Once
+// full, setting a new key evicts the least recently used one, and an entry
+// past its TTL is treated as missing and dropped on the next access.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+)
+
+// Reason says why an entry left the cache.
+type Reason int
+
+const (
+	// Evicted entries were the least recently used when the cache was full.
+	Evicted Reason = iota
+	// Expired entries outlived their TTL.
+	Expired
+	// Removed entries were deleted by Remove or Purge, or replaced by Set.
+	Removed
+)
+
+func (r Reason) String() string {
+	switch r {
+	case Evicted:
+		return "evicted"
+	case Expired:
+		return "expired"
+	case Removed:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// Option configures an LRU.
+type Option func(*options)
+
+type options struct {
+	ttl   time.Duration
+	clock clock.Clock
+}
+
+// WithTTL sets the lifetime of entries added with Set, the default is no
+// expiry.
+func WithTTL(d time.Duration) Option {
+	return func(o *options) {
+		o.ttl = d
+	}
+}
+
+// WithClock sets the time source for expiry, the default is clock.Real.
+func WithClock(c clock.Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+type eviction[K comparable, V any] struct {
+	key    K
+	value  V
+	reason Reason
+}
+
+// LRU is safe for concurrent use. It takes a full lock even for Get, a hit
+// moves the entry to the front of the recency list.
+type LRU[K comparable, V any] struct {
+	capacity int
+	ttl      time.Duration
+	clock    clock.Clock
+
+	mu      sync.Mutex
+	items   map[K]*list.Element
+	order   *list.List // front is most recently used
+	onEvict func(K, V, Reason)
+}
+
+// New creates an LRU holding up to capacity entries, zero or less means
+// unbounded and only TTLs remove entries.
+func New[K comparable, V any](capacity int, opts ...Option) *LRU[K, V] {
+	o := options{clock: clock.Real}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &LRU[K, V]{
+		capacity: capacity,
+		ttl:      o.ttl,
+		clock:    o.clock,
+		items:    make(map[K]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// OnEvict registers fn to be called for every entry that leaves the cache,
+// replacing any earlier hook. fn runs after the cache's lock is released,
+// so it may call back into the cache.
+func (c *LRU[K, V]) OnEvict(fn func(key K, value V, reason Reason)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onEvict = fn
+}
+
+// Get returns the value for key and marks it recently used. An expired
+// entry is removed and reported as missing.
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	var zero V
+	c.mu.Lock()
+	el, ok := c.items[key]
+	if !ok {
+		c.mu.Unlock()
+		return zero, false
+	}
+	e := el.Value.(*entry[K, V])
+	if c.expired(e) {
+		evicted := c.remove(el, Expired)
+		c.mu.Unlock()
+		c.notify(evicted)
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	c.mu.Unlock()
+	return e.value, true
+}
+
+// Peek is Get without touching the recency order.
+func (c *LRU[K, V]) Peek(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		if e := el.Value.(*entry[K, V]); !c.expired(e) {
+			return e.value, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// Set adds or replaces key with the cache's default TTL.
+func (c *LRU[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+// SetWithTTL adds or replaces key, expiring it after ttl, zero or less
+// means never. Adding to a full cache evicts the least recently used entry.
+func (c *LRU[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	var expires time.Time
+	if ttl > 0 {
+		expires = c.clock.Now().Add(ttl)
+	}
+	var evicted []eviction[K, V]
+	c.mu.Lock()
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[K, V])
+		evicted = append(evicted, eviction[K, V]{key, e.value, Removed})
+		e.value, e.expires = value, expires
+		c.order.MoveToFront(el)
+	} else {
+		c.items[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expires: expires})
+		for c.capacity > 0 && c.order.Len() > c.capacity {
+			// the back is the least recently used, but an expired entry
+			// is reported as such
+			back := c.order.Back()
+			reason := Evicted
+			if c.expired(back.Value.(*entry[K, V])) {
+				reason = Expired
+			}
+			evicted = append(evicted, c.remove(back, reason)...)
+		}
+	}
+	c.mu.Unlock()
+	c.notify(evicted)
+}
+
+// Remove deletes key, reporting whether it was present.
+func (c *LRU[K, V]) Remove(key K) bool {
+	c.mu.Lock()
+	el, ok := c.items[key]
+	if !ok {
+		c.mu.Unlock()
+		return false
+	}
+	evicted := c.remove(el, Removed)
+	c.mu.Unlock()
+	c.notify(evicted)
+	return true
+}
+
+// Purge empties the cache.
+func (c *LRU[K, V]) Purge() {
+	var evicted []eviction[K, V]
+	c.mu.Lock()
+	for el := c.order.Front(); el != nil; el = el.Next() {
+		e := el.Value.(*entry[K, V])
+		evicted = append(evicted, eviction[K, V]{e.key, e.value, Removed})
+	}
+	c.items = make(map[K]*list.Element)
+	c.order.Init()
+	c.mu.Unlock()
+	c.notify(evicted)
+}
+
+// Len counts the entries held, including expired ones not yet dropped.
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// expired must be called with c.mu held.
+func (c *LRU[K, V]) expired(e *entry[K, V]) bool {
+	return !e.expires.IsZero() && !c.clock.Now().Before(e.expires)
+}
+
+// remove must be called with c.mu held.
+func (c *LRU[K, V]) remove(el *list.Element, reason Reason) []eviction[K, V] {
+	e := c.order.Remove(el).(*entry[K, V])
+	delete(c.items, e.key)
+	return []eviction[K, V]{{e.key, e.value, reason}}
+}
+
+func (c *LRU[K, V]) notify(evicted []eviction[K, V]) {
+	if len(evicted) == 0 {
+		return
+	}
+	c.mu.Lock()
+	fn := c.onEvict
+	c.mu.Unlock()
+	if fn == nil {
+		return
+	}
+	for _, ev := range evicted {
+		fn(ev.key, ev.value, ev.reason)
+	}
+}
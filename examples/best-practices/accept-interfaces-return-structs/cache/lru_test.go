@@ -0,0 +1,384 @@
+package cache_test
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/cache"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+)
+
+// testCase is a random sequence of operations. quick.Check builds them
+// through Generate, smaller gives every case with one operation dropped,
+// which is all shrinking needs.
+type testCase[C any] interface {
+	quick.Generator
+	smaller() []C
+	String() string
+}
+
+// property checks fn against random cases. testing/quick reports the first
+// failing case as generated, often dozens of operations long, so it is
+// shrunk before reporting: drop operations while the check still fails.
+// The seed is in the report, a failure replays with the same one.
+func property[C testCase[C]](t *testing.T, fn func(C) error) {
+	t.Helper()
+	seed := time.Now().UnixNano()
+	err := quick.Check(func(c C) bool { return fn(c) == nil }, &quick.Config{MaxCount: 2000, Rand: rand.New(rand.NewSource(seed))})
+	if err == nil {
+		return
+	}
+	var failure *quick.CheckError
+	if !errors.As(err, &failure) {
+		t.Fatal(err)
+	}
+	c := failure.In[0].(C)
+	for shrunk := true; shrunk; {
+		shrunk = false
+		for _, smaller := range c.smaller() {
+			if fn(smaller) != nil {
+				c, shrunk = smaller, true
+				break
+			}
+		}
+	}
+	t.Errorf("seed %d, case %d shrunk to\n%s%s", seed, failure.Count, c, fn(c))
+}
+
+// keys is kept small so operations keep running into each other.
+const keys = 6
+
+type lruOp struct {
+	kind  string // set, setTTL, get, peek, remove, purge, advance
+	key   int
+	value int
+	d     time.Duration
+}
+
+func (op lruOp) String() string {
+	switch op.kind {
+	case "set":
+		return fmt.Sprintf("Set(%d, %d)", op.key, op.value)
+	case "setTTL":
+		return fmt.Sprintf("SetWithTTL(%d, %d, %s)", op.key, op.value, op.d)
+	case "get", "peek", "remove":
+		return fmt.Sprintf("%s%s(%d)", strings.ToUpper(op.kind[:1]), op.kind[1:], op.key)
+	case "purge":
+		return "Purge()"
+	}
+	return fmt.Sprintf("clock.Advance(%s)", op.d)
+}
+
+type lruCase struct {
+	capacity int
+	ttl      time.Duration
+	ops      []lruOp
+}
+
+func (lruCase) Generate(r *rand.Rand, size int) reflect.Value {
+	c := lruCase{capacity: r.Intn(5)}
+	if r.Intn(2) == 0 {
+		c.ttl = 2 * time.Second
+	}
+	for range r.Intn(size + 1) {
+		op := lruOp{key: r.Intn(keys), value: r.Intn(100), d: time.Duration(1+r.Intn(4)) * 500 * time.Millisecond}
+		switch p := r.Intn(20); {
+		case p < 6:
+			op.kind = "set"
+		case p < 8:
+			op.kind = "setTTL"
+		case p < 12:
+			op.kind = "get"
+		case p < 14:
+			op.kind = "peek"
+		case p < 16:
+			op.kind = "remove"
+		case p < 17:
+			op.kind = "purge"
+		default:
+			op.kind = "advance"
+		}
+		c.ops = append(c.ops, op)
+	}
+	return reflect.ValueOf(c)
+}
+
+func (c lruCase) smaller() []lruCase {
+	cases := make([]lruCase, len(c.ops))
+	for i := range c.ops {
+		cases[i] = lruCase{c.capacity, c.ttl, slices.Delete(slices.Clone(c.ops), i, i+1)}
+	}
+	return cases
+}
+
+func (c lruCase) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "    cache.New(%d, cache.WithTTL(%s))\n", c.capacity, c.ttl)
+	for _, op := range c.ops {
+		fmt.Fprintf(&b, "    %s\n", op)
+	}
+	return b.String()
+}
+
+type eviction struct {
+	key, value int
+	reason     cache.Reason
+}
+
+func (e eviction) String() string {
+	return fmt.Sprintf("%d=%d %s", e.key, e.value, e.reason)
+}
+
+// lruModel is the LRU as a slice, most recently used first, and does
+// everything the slow, obvious way.
+type lruModel struct {
+	capacity int
+	now      time.Time
+	entries  []modelEntry
+	evicted  []eviction
+}
+
+type modelEntry struct {
+	key, value int
+	expires    time.Time
+}
+
+func (m *lruModel) expired(e modelEntry) bool {
+	return !e.expires.IsZero() && !m.now.Before(e.expires)
+}
+
+func (m *lruModel) find(key int) int {
+	return slices.IndexFunc(m.entries, func(e modelEntry) bool { return e.key == key })
+}
+
+func (m *lruModel) remove(i int, reason cache.Reason) {
+	e := m.entries[i]
+	m.entries = slices.Delete(m.entries, i, i+1)
+	m.evicted = append(m.evicted, eviction{e.key, e.value, reason})
+}
+
+func (m *lruModel) set(key, value int, ttl time.Duration) {
+	e := modelEntry{key: key, value: value}
+	if ttl > 0 {
+		e.expires = m.now.Add(ttl)
+	}
+	if i := m.find(key); i >= 0 {
+		m.evicted = append(m.evicted, eviction{key, m.entries[i].value, cache.Removed})
+		m.entries = slices.Delete(m.entries, i, i+1)
+		m.entries = slices.Insert(m.entries, 0, e)
+		return
+	}
+	m.entries = slices.Insert(m.entries, 0, e)
+	for m.capacity > 0 && len(m.entries) > m.capacity {
+		back := len(m.entries) - 1
+		reason := cache.Evicted
+		if m.expired(m.entries[back]) {
+			reason = cache.Expired
+		}
+		m.remove(back, reason)
+	}
+}
+
+func (m *lruModel) get(key int) (int, bool) {
+	i := m.find(key)
+	if i < 0 {
+		return 0, false
+	}
+	e := m.entries[i]
+	if m.expired(e) {
+		m.remove(i, cache.Expired)
+		return 0, false
+	}
+	m.entries = slices.Delete(m.entries, i, i+1)
+	m.entries = slices.Insert(m.entries, 0, e)
+	return e.value, true
+}
+
+func (m *lruModel) peek(key int) (int, bool) {
+	if i := m.find(key); i >= 0 && !m.expired(m.entries[i]) {
+		return m.entries[i].value, true
+	}
+	return 0, false
+}
+
+// replay runs the case against a real LRU and the model side by side and
+// calls check after every operation.
+func replay(c lruCase, check func(i int, lru *cache.LRU[int, int], m *lruModel, got []eviction, result, want string) error) error {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	lru := cache.New[int, int](c.capacity, cache.WithTTL(c.ttl), cache.WithClock(clk))
+	var got []eviction
+	lru.OnEvict(func(key, value int, reason cache.Reason) {
+		got = append(got, eviction{key, value, reason})
+	})
+	m := &lruModel{capacity: c.capacity, now: start}
+	for i, op := range c.ops {
+		got, m.evicted = nil, nil
+		var result, want string
+		switch op.kind {
+		case "set":
+			lru.Set(op.key, op.value)
+			m.set(op.key, op.value, c.ttl)
+		case "setTTL":
+			lru.SetWithTTL(op.key, op.value, op.d)
+			m.set(op.key, op.value, op.d)
+		case "get":
+			result, want = fmt.Sprint(lru.Get(op.key)), fmt.Sprint(m.get(op.key))
+		case "peek":
+			result, want = fmt.Sprint(lru.Peek(op.key)), fmt.Sprint(m.peek(op.key))
+		case "remove":
+			present := m.find(op.key) >= 0
+			if present {
+				m.remove(m.find(op.key), cache.Removed)
+			}
+			result, want = fmt.Sprint(lru.Remove(op.key)), fmt.Sprint(present)
+		case "purge":
+			lru.Purge()
+			for len(m.entries) > 0 {
+				m.remove(0, cache.Removed)
+			}
+		case "advance":
+			clk.Advance(op.d)
+			m.now = m.now.Add(op.d)
+		}
+		if err := check(i, lru, m, got, result, want); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkLRU(c lruCase) error {
+	return replay(c, func(i int, lru *cache.LRU[int, int], m *lruModel, got []eviction, result, want string) error {
+		op := c.ops[i]
+		if result != want {
+			return fmt.Errorf("op %d, %s = %s, model says %s", i+1, op, result, want)
+		}
+		if !slices.Equal(got, m.evicted) {
+			return fmt.Errorf("op %d, %s evicted %v, model says %v", i+1, op, got, m.evicted)
+		}
+		if lru.Len() != len(m.entries) {
+			return fmt.Errorf("op %d, %s left Len %d, model says %d", i+1, op, lru.Len(), len(m.entries))
+		}
+		if c.capacity > 0 && lru.Len() > c.capacity {
+			return fmt.Errorf("op %d, %s left Len %d over capacity %d", i+1, op, lru.Len(), c.capacity)
+		}
+		return nil
+	})
+}
+
+// TestLRUMatchesModel checks the LRU against a simple model under random
+// sequences of operations: after every one they must agree on the result,
+// the length, and which entries were evicted and why.
+func TestLRUMatchesModel(t *testing.T) {
+	property(t, checkLRU)
+}
+
+// TestLenCountsExpired pins down what Len means: an entry that expired but
+// has not been touched since is still counted, Len is not the live count.
+func TestLenCountsExpired(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	lru := cache.New[int, int](4, cache.WithTTL(time.Second), cache.WithClock(clk))
+	lru.Set(1, 1)
+	lru.Set(2, 2)
+	clk.Advance(time.Second)
+	if got := lru.Len(); got != 2 {
+		t.Errorf("Len after both expired = %d, want 2", got)
+	}
+	if _, ok := lru.Get(1); ok {
+		t.Error("Get of an expired entry = ok")
+	}
+	if got := lru.Len(); got != 1 {
+		t.Errorf("Len after a Get dropped one = %d, want 1", got)
+	}
+}
+
+// mapCache is the baseline: a map behind a RWMutex, unbounded, reads share
+// the lock.
+type mapCache struct {
+	mu sync.RWMutex
+	m  map[string]int
+}
+
+func (c *mapCache) Get(k string) (int, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	v, ok := c.m[k]
+	return v, ok
+}
+
+func (c *mapCache) Set(k string, v int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.m[k] = v
+}
+
+type getSetter interface {
+	Get(string) (int, bool)
+	Set(string, int)
+}
+
+const (
+	benchKeys = 100_000
+	benchSize = 10_000
+)
+
+// zipfLoad runs a read mostly workload, nine gets to one set, over keys
+// drawn from a skewed distribution so a small LRU still serves most reads.
+// A miss is filled with a set, like a read-through cache. It reports the
+// hit rate.
+func zipfLoad(b *testing.B, c getSetter) {
+	keys := make([]string, benchKeys)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	var seed atomic.Int64
+	var hits, gets atomic.Int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewSource(seed.Add(1)))
+		zipf := rand.NewZipf(r, 1.1, 1, benchKeys-1)
+		var h, g int64
+		for i := 0; pb.Next(); i++ {
+			k := keys[zipf.Uint64()]
+			if i%10 == 0 {
+				c.Set(k, i)
+				continue
+			}
+			g++
+			if _, ok := c.Get(k); ok {
+				h++
+			} else {
+				c.Set(k, i)
+			}
+		}
+		hits.Add(h)
+		gets.Add(g)
+	})
+	if n := gets.Load(); n > 0 {
+		b.ReportMetric(float64(hits.Load())/float64(n)*100, "hit%")
+	}
+}
+
+// BenchmarkLRU and BenchmarkMapMutex compare the bounded LRU, which takes
+// its one lock even to read, with an unbounded map whose reads share it.
+//
+//	go test ./cache -run '^$' -bench . -cpu 1,8
+func BenchmarkLRU(b *testing.B) {
+	zipfLoad(b, cache.New[string, int](benchSize))
+}
+
+func BenchmarkMapMutex(b *testing.B) {
+	zipfLoad(b, &mapCache{m: make(map[string]int)})
+}
@@ -0,0 +1,231 @@
+// Package circuitbreaker stops calling a dependency that keeps failing.
+//
+// Closed is normal operation, failures are counted. After FailureThreshold
+// consecutive failures the breaker opens and every call fails fast with
+// ErrOpen, giving the dependency room to recover instead of a queue of
+// timeouts. Once ResetTimeout has passed it goes half-open and lets a few
+// trial calls through: a success closes it, a failure opens it again.
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// ErrOpen is returned, without calling through, while the breaker is open.
+// It wraps errs.ErrUnavailable, so transports report 503 / UNAVAILABLE.
+var ErrOpen = fmt.Errorf("circuit breaker open: %w", errs.ErrUnavailable)
+
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+// Clock tells the breaker the time, clock.Real and *clock.Fake satisfy it.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+type Option func(*Breaker)
+
+// WithFailureThreshold sets how many consecutive failures open the
+// breaker, the default is 5.
+func WithFailureThreshold(n int) Option {
+	return func(b *Breaker) {
+		b.threshold = max(n, 1)
+	}
+}
+
+// WithResetTimeout sets how long the breaker stays open before trying
+// again, the default is 30 seconds.
+func WithResetTimeout(d time.Duration) Option {
+	return func(b *Breaker) {
+		b.resetTimeout = d
+	}
+}
+
+// WithHalfOpenCalls sets how many trial calls may run at once while
+// half-open, the default is 1. The rest keep failing fast.
+func WithHalfOpenCalls(n int) Option {
+	return func(b *Breaker) {
+		b.halfOpenMax = max(n, 1)
+	}
+}
+
+// WithIsFailure decides which errors count against the dependency, the
+// default is IsFailure.
+func WithIsFailure(f func(error) bool) Option {
+	return func(b *Breaker) {
+		b.isFailure = f
+	}
+}
+
+// WithOnStateChange is called, with the breaker's lock held, on every
+// transition. Keep it quick: log it or bump a metric.
+func WithOnStateChange(f func(from, to State)) Option {
+	return func(b *Breaker) {
+		b.onChange = f
+	}
+}
+
+func WithClock(clock Clock) Option {
+	return func(b *Breaker) {
+		b.clock = clock
+	}
+}
+
+// IsFailure counts every error except the errs taxonomy and context errors.
+// A missing user or a canceled request says nothing about the database's
+// health, and must not open the breaker.
+func IsFailure(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, errs.ErrNotFound),
+		errors.Is(err, errs.ErrConflict),
+		errors.Is(err, errs.ErrInvalidInput),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return false
+	}
+	return true
+}
+
+// Breaker is safe for concurrent use, share one per dependency.
+type Breaker struct {
+	threshold    int
+	resetTimeout time.Duration
+	halfOpenMax  int
+	isFailure    func(error) bool
+	onChange     func(from, to State)
+	clock        Clock
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	trials   int
+}
+
+func New(opts ...Option) *Breaker {
+	b := &Breaker{
+		threshold:    5,
+		resetTimeout: 30 * time.Second,
+		halfOpenMax:  1,
+		isFailure:    IsFailure,
+		clock:        systemClock{},
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Do calls fn unless the breaker is open and records the outcome.
+func (b *Breaker) Do(fn func() error) error {
+	state, err := b.allow()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	b.record(state, err)
+	return err
+}
+
+// State reports the current state, moving from open to half-open when the
+// reset timeout has passed.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expireLocked()
+	return b.state
+}
+
+func (b *Breaker) allow() (State, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expireLocked()
+	switch b.state {
+	case Open:
+		return b.state, ErrOpen
+	case HalfOpen:
+		if b.trials >= b.halfOpenMax {
+			return b.state, ErrOpen
+		}
+		b.trials++
+	}
+	return b.state, nil
+}
+
+// record applies the outcome of a call allowed in state. A call that
+// started in another state than the current one is stale and ignored,
+// e.g. a slow call from before the breaker opened.
+func (b *Breaker) record(state State, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if state != b.state {
+		return
+	}
+	failed := b.isFailure(err)
+	switch b.state {
+	case Closed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.threshold {
+			b.setLocked(Open)
+		}
+	case HalfOpen:
+		b.trials--
+		if failed {
+			b.setLocked(Open)
+			return
+		}
+		b.setLocked(Closed)
+	}
+}
+
+func (b *Breaker) expireLocked() {
+	if b.state == Open && !b.clock.Now().Before(b.openedAt.Add(b.resetTimeout)) {
+		b.setLocked(HalfOpen)
+	}
+}
+
+func (b *Breaker) setLocked(to State) {
+	from := b.state
+	b.state = to
+	b.failures = 0
+	b.trials = 0
+	if to == Open {
+		b.openedAt = b.clock.Now()
+	}
+	if b.onChange != nil && from != to {
+		b.onChange(from, to)
+	}
+}
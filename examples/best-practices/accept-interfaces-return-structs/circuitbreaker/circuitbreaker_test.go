@@ -0,0 +1,154 @@
+package circuitbreaker_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/circuitbreaker"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+var (
+	errDown = errors.New("connection refused")
+	epoch   = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+)
+
+func fail() error    { return errDown }
+func succeed() error { return nil }
+
+// TestTransitions walks the breaker through every state on a fake clock,
+// recording the transitions it reports.
+func TestTransitions(t *testing.T) {
+	c := clock.NewFake(epoch)
+	var changes []string
+	b := circuitbreaker.New(
+		circuitbreaker.WithClock(c),
+		circuitbreaker.WithFailureThreshold(3),
+		circuitbreaker.WithResetTimeout(time.Minute),
+		circuitbreaker.WithOnStateChange(func(from, to circuitbreaker.State) {
+			changes = append(changes, fmt.Sprintf("%s->%s", from, to))
+		}),
+	)
+
+	// failures must be consecutive, a success starts the count again
+	b.Do(fail)
+	b.Do(fail)
+	b.Do(succeed)
+	b.Do(fail)
+	b.Do(fail)
+	if b.State() != circuitbreaker.Closed {
+		t.Fatalf("State = %s after two failures in a row, want closed", b.State())
+	}
+	if err := b.Do(fail); !errors.Is(err, errDown) {
+		t.Fatalf("third failure: err = %v, want the call's own error", err)
+	}
+	if b.State() != circuitbreaker.Open {
+		t.Fatalf("State = %s after three failures in a row, want open", b.State())
+	}
+
+	called := false
+	err := b.Do(func() error { called = true; return nil })
+	if !errors.Is(err, circuitbreaker.ErrOpen) || !errors.Is(err, errs.ErrUnavailable) || called {
+		t.Fatalf("Do while open: err = %v, called %t, want ErrOpen without calling", err, called)
+	}
+
+	c.Advance(time.Minute - time.Second)
+	if b.State() != circuitbreaker.Open {
+		t.Fatalf("State = %s before the reset timeout, want open", b.State())
+	}
+	c.Advance(time.Second)
+	if b.State() != circuitbreaker.HalfOpen {
+		t.Fatalf("State = %s at the reset timeout, want half-open", b.State())
+	}
+	// a failed trial opens it again, for another full timeout
+	b.Do(fail)
+	if b.State() != circuitbreaker.Open {
+		t.Fatalf("State = %s after a failed trial, want open", b.State())
+	}
+	c.Advance(time.Minute)
+	if err := b.Do(succeed); err != nil {
+		t.Fatalf("trial call: %v", err)
+	}
+	if b.State() != circuitbreaker.Closed {
+		t.Fatalf("State = %s after a successful trial, want closed", b.State())
+	}
+
+	want := []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}
+	if !slices.Equal(changes, want) {
+		t.Errorf("transitions = %v, want %v", changes, want)
+	}
+}
+
+// TestHalfOpenTrials holds trial calls open to check only the allowed
+// number run at once, the others fail fast.
+func TestHalfOpenTrials(t *testing.T) {
+	c := clock.NewFake(epoch)
+	b := circuitbreaker.New(
+		circuitbreaker.WithClock(c),
+		circuitbreaker.WithFailureThreshold(1),
+		circuitbreaker.WithResetTimeout(time.Second),
+		circuitbreaker.WithHalfOpenCalls(2),
+	)
+	b.Do(fail)
+	c.Advance(time.Second)
+
+	release := make(chan struct{})
+	running := make(chan struct{})
+	done := make(chan error, 2)
+	for range 2 {
+		go func() {
+			done <- b.Do(func() error {
+				running <- struct{}{}
+				<-release
+				return nil
+			})
+		}()
+		<-running
+	}
+	if err := b.Do(succeed); !errors.Is(err, circuitbreaker.ErrOpen) {
+		t.Errorf("a third trial: err = %v, want ErrOpen", err)
+	}
+	close(release)
+	for range 2 {
+		if err := <-done; err != nil {
+			t.Errorf("trial: %v", err)
+		}
+	}
+	if b.State() != circuitbreaker.Closed {
+		t.Errorf("State = %s after the trials succeeded, want closed", b.State())
+	}
+}
+
+func TestIsFailure(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errs.ErrNotFound, false},
+		{errs.ErrVersionConflict, false},
+		{errs.ErrInvalidInput, false},
+		{context.Canceled, false},
+		{fmt.Errorf("get: %w", context.DeadlineExceeded), false},
+		{errs.ErrUnavailable, true},
+		{errDown, true},
+	} {
+		if got := circuitbreaker.IsFailure(tt.err); got != tt.want {
+			t.Errorf("IsFailure(%v) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+
+	// errors that are not failures never open the breaker
+	b := circuitbreaker.New(circuitbreaker.WithFailureThreshold(1))
+	for range 10 {
+		b.Do(func() error { return errs.ErrNotFound })
+	}
+	if b.State() != circuitbreaker.Closed {
+		t.Errorf("State = %s after not found errors, want closed", b.State())
+	}
+}
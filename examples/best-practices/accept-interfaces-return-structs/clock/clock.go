@@ -0,0 +1,167 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the part of the time package that code under test needs to
+// control. Production code takes a Clock and is handed Real, tests hand it a
+// *Fake and move time forward explicitly instead of sleeping.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer mirrors *time.Timer, with C as a method so fakes can implement it.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Real is the wall clock.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) NewTimer(d time.Duration) Timer         { return &realTimer{t: time.NewTimer(d)} }
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (r *realTimer) C() <-chan time.Time        { return r.t.C }
+func (r *realTimer) Stop() bool                 { return r.t.Stop() }
+func (r *realTimer) Reset(d time.Duration) bool { return r.t.Reset(d) }
+
+// Fake is a Clock that only moves when told to. Timers and After channels
+// fire during Advance or Set once the fake time reaches their deadline.
+type Fake struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTimer{clock: f, c: make(chan time.Time, 1)}
+	f.schedule(t, d)
+	return t
+}
+
+// Advance moves the clock forward by d, firing every timer due on the way.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	target := f.now.Add(d)
+	f.mu.Unlock()
+	f.Set(target)
+}
+
+// Set moves the clock to t, firing every timer due at or before t in
+// deadline order. Setting the clock backwards fires nothing.
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.set(t)
+}
+
+// set must be called with f.mu held.
+func (f *Fake) set(t time.Time) {
+	if t.Before(f.now) {
+		f.now = t
+		return
+	}
+	sort.Slice(f.timers, func(i, j int) bool {
+		return f.timers[i].deadline.Before(f.timers[j].deadline)
+	})
+	remaining := f.timers[:0]
+	for _, timer := range f.timers {
+		if timer.deadline.After(t) {
+			remaining = append(remaining, timer)
+			continue
+		}
+		timer.active = false
+		// the channel is buffered by one, like time.Timer a slow reader
+		// misses a tick rather than blocking the clock
+		select {
+		case timer.c <- timer.deadline:
+		default:
+		}
+	}
+	f.timers = remaining
+	f.now = t
+}
+
+// Waiters reports how many timers are pending, handy for a test to wait
+// until the code under test has started waiting before calling Advance.
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.timers)
+}
+
+// schedule must be called with f.mu held.
+func (f *Fake) schedule(t *fakeTimer, d time.Duration) {
+	t.deadline = f.now.Add(d)
+	t.active = true
+	f.timers = append(f.timers, t)
+	if d <= 0 {
+		f.set(f.now)
+	}
+}
+
+// unschedule must be called with f.mu held.
+func (f *Fake) unschedule(t *fakeTimer) bool {
+	for i, timer := range f.timers {
+		if timer == t {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			break
+		}
+	}
+	wasActive := t.active
+	t.active = false
+	return wasActive
+}
+
+type fakeTimer struct {
+	clock    *Fake
+	c        chan time.Time
+	deadline time.Time
+	active   bool
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.unschedule(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	wasActive := t.clock.unschedule(t)
+	t.clock.schedule(t, d)
+	return wasActive
+}
@@ -0,0 +1,132 @@
+package clock_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+)
+
+var epoch = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// fired reports whether c has a value ready, without waiting for one.
+func fired(c <-chan time.Time) (time.Time, bool) {
+	select {
+	case at := <-c:
+		return at, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestFakeNow(t *testing.T) {
+	c := clock.NewFake(epoch)
+	if got := c.Now(); !got.Equal(epoch) {
+		t.Fatalf("Now = %v, want %v", got, epoch)
+	}
+	c.Advance(90 * time.Minute)
+	if got, want := c.Now(), epoch.Add(90*time.Minute); !got.Equal(want) {
+		t.Errorf("Now after Advance = %v, want %v", got, want)
+	}
+	c.Set(epoch)
+	if got := c.Now(); !got.Equal(epoch) {
+		t.Errorf("Now after Set back = %v, want %v", got, epoch)
+	}
+}
+
+func TestFakeTimers(t *testing.T) {
+	c := clock.NewFake(epoch)
+	soon := c.After(time.Second)
+	later := c.NewTimer(time.Minute)
+	if c.Waiters() != 2 {
+		t.Fatalf("Waiters = %d, want 2", c.Waiters())
+	}
+
+	c.Advance(999 * time.Millisecond)
+	if _, ok := fired(soon); ok {
+		t.Fatal("a one second timer fired before a second passed")
+	}
+	c.Advance(time.Millisecond)
+	if at, ok := fired(soon); !ok || !at.Equal(epoch.Add(time.Second)) {
+		t.Fatalf("After(1s) = %v, %t, want it to fire at its deadline", at, ok)
+	}
+	if _, ok := fired(later.C()); ok {
+		t.Fatal("a one minute timer fired after a second")
+	}
+
+	// one big jump fires it with its own deadline, not the clock's
+	c.Advance(time.Hour)
+	if at, ok := fired(later.C()); !ok || !at.Equal(epoch.Add(time.Minute)) {
+		t.Errorf("NewTimer(1m) = %v, %t, want it to fire at its deadline", at, ok)
+	}
+	if c.Waiters() != 0 {
+		t.Errorf("Waiters = %d after every timer fired, want 0", c.Waiters())
+	}
+}
+
+func TestFakeTimerStopReset(t *testing.T) {
+	c := clock.NewFake(epoch)
+	timer := c.NewTimer(time.Second)
+	if !timer.Stop() {
+		t.Fatal("Stop of a pending timer = false, want true")
+	}
+	c.Advance(time.Hour)
+	if _, ok := fired(timer.C()); ok {
+		t.Fatal("a stopped timer fired")
+	}
+	if timer.Stop() {
+		t.Error("Stop of a stopped timer = true, want false")
+	}
+
+	if timer.Reset(time.Second) {
+		t.Error("Reset of a stopped timer = true, want false")
+	}
+	if !timer.Reset(time.Minute) {
+		t.Error("Reset of a pending timer = false, want true")
+	}
+	c.Advance(time.Second)
+	if _, ok := fired(timer.C()); ok {
+		t.Fatal("a timer fired at the deadline it was reset away from")
+	}
+	c.Advance(time.Minute)
+	if _, ok := fired(timer.C()); !ok {
+		t.Error("a reset timer did not fire at its new deadline")
+	}
+
+	// a timer for now or the past fires at once, with no Advance
+	if _, ok := fired(c.After(0)); !ok {
+		t.Error("After(0) did not fire")
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	c := clock.NewFake(epoch)
+	ctx, cancel := clock.WithTimeout(context.Background(), c, time.Minute)
+	defer cancel()
+	if d, ok := ctx.Deadline(); !ok || !d.Equal(epoch.Add(time.Minute)) {
+		t.Fatalf("Deadline = %v, %t, want the fake clock's now plus a minute", d, ok)
+	}
+	// the context's goroutine has started waiting once the timer exists
+	if c.Waiters() != 1 {
+		t.Fatalf("Waiters = %d, want the context's timer", c.Waiters())
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("Err before the deadline = %v, want nil", ctx.Err())
+	}
+	c.Advance(time.Minute)
+	<-ctx.Done()
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		t.Errorf("Err = %v, want DeadlineExceeded", ctx.Err())
+	}
+
+	ctx, cancel = clock.WithTimeout(context.Background(), c, time.Minute)
+	cancel()
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		t.Errorf("Err after cancel = %v, want Canceled", ctx.Err())
+	}
+	if c.Waiters() != 0 {
+		t.Errorf("Waiters = %d after cancel, want the timer stopped", c.Waiters())
+	}
+}
@@ -0,0 +1,70 @@
+package clock
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WithDeadline is context.WithDeadline on c's time: the context is done
+// once c reaches d, so on a *Fake it expires during the Advance that gets
+// there and never on its own. On Real it is context.WithDeadline.
+func WithDeadline(parent context.Context, c Clock, d time.Time) (context.Context, context.CancelFunc) {
+	if c == Real {
+		return context.WithDeadline(parent, d)
+	}
+	if cur, ok := parent.Deadline(); ok && !cur.After(d) {
+		return context.WithCancel(parent)
+	}
+	inner, cancel := context.WithCancelCause(parent)
+	ctx := &deadlineCtx{Context: inner, deadline: d, done: make(chan struct{})}
+	timer := c.NewTimer(d.Sub(c.Now()))
+	go func() {
+		select {
+		case <-timer.C():
+			cancel(context.DeadlineExceeded)
+		case <-inner.Done():
+			timer.Stop()
+		}
+		close(ctx.done)
+	}()
+	return ctx, func() {
+		cancel(context.Canceled)
+		<-ctx.done
+	}
+}
+
+// WithTimeout is WithDeadline at c.Now() plus d.
+func WithTimeout(parent context.Context, c Clock, d time.Duration) (context.Context, context.CancelFunc) {
+	return WithDeadline(parent, c, c.Now().Add(d))
+}
+
+// deadlineCtx reports the deadline a timer on another clock enforces. Its
+// Done is its own channel, not the inner context's, so contexts derived
+// from it ask its Err, context.DeadlineExceeded once the timer fires,
+// rather than copying the inner one's context.Canceled.
+type deadlineCtx struct {
+	context.Context
+	deadline time.Time
+	done     chan struct{}
+}
+
+func (c *deadlineCtx) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func (c *deadlineCtx) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *deadlineCtx) Err() error {
+	select {
+	case <-c.done:
+	default:
+		return nil
+	}
+	if errors.Is(context.Cause(c.Context), context.DeadlineExceeded) {
+		return context.DeadlineExceeded
+	}
+	return c.Context.Err()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/audit"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Takes one user through every mutation as two different callers, then
+// prints its audit trail from the file sink, a rename included under the
+// old ID. -log keeps the JSON lines file around for a look with jq.
+//
+//	go run ./cmd/audit
+//	go run ./cmd/audit -log audit.jsonl && jq . audit.jsonl
+func main() {
+	path := flag.String("log", "", "audit log file, a temporary one by default")
+	flag.Parse()
+
+	if *path == "" {
+		dir, err := os.MkdirTemp("", "audit")
+		if err != nil {
+			fmt.Println(fmt.Errorf("error: %s", err))
+			return
+		}
+		defer os.RemoveAll(dir)
+		*path = filepath.Join(dir, "audit.jsonl")
+	}
+	sink, err := audit.OpenFile(*path)
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	defer sink.Close()
+
+	userService := service.NewUserService(db.NewMemoryStore(), service.WithAuditSink(sink))
+	ada := ctxutil.WithRequestID(ctxutil.WithPrincipal(context.Background(), ctxutil.Principal{Subject: "ada"}), "req-1")
+	admin := ctxutil.WithRequestID(ctxutil.WithPrincipal(context.Background(), ctxutil.Principal{Subject: "admin"}), "req-2")
+
+	user := &service.User{ID: "1", Email: "ada@example.com"}
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"ada signs up", func() error { return userService.CreateUser(ada, user) }},
+		{"ada sets her name", func() error {
+			user.Name = "Ada Lovelace"
+			return userService.UpdateUser(ada, user)
+		}},
+		{"admin deletes her", func() error { return userService.DeleteUser(admin, "1") }},
+		{"admin restores her", func() error { return userService.RestoreUser(admin, "1") }},
+		{"admin renames her", func() error { return userService.RenameUser(admin, "1", "ada") }},
+		{"a job with no caller purges her", func() error { return userService.PurgeUser(context.Background(), "ada") }},
+	}
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			fmt.Println(fmt.Errorf("error: %s: %s", step.name, err))
+			return
+		}
+	}
+
+	for _, id := range []string{"1", "ada"} {
+		entries, err := sink.ByUser(context.Background(), id)
+		if err != nil {
+			fmt.Println(fmt.Errorf("error: %s", err))
+			return
+		}
+		fmt.Printf("entries about %s: %d\n", id, len(entries))
+		for _, e := range entries {
+			actor := e.Actor
+			if actor == "" {
+				actor = "-"
+			}
+			changes := make([]string, len(e.Changes))
+			for i, c := range e.Changes {
+				changes[i] = fmt.Sprintf("%s %q -> %q", c.Field, c.From, c.To)
+			}
+			fmt.Printf("  %-7s %-3s by %-5s %-5s %s\n", e.Action, e.UserID, actor, e.RequestID, strings.Join(changes, ", "))
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/auth"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+)
+
+// Walks through issuing, validating, expiring, and refreshing tokens, then
+// puts auth.Middleware in front of a handler that reads the principal.
+func main() {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	hs, err := auth.NewHS256(secret, auth.WithIssuer("notebook"), auth.WithTTL(time.Minute, time.Hour), auth.WithNow(fake.Now))
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+
+	pair, _ := hs.Issue("ada")
+	p, err := hs.Validate(pair.AccessToken)
+	fmt.Println("HS256 access token for", p.Subject, "valid:", err == nil)
+
+	_, err = hs.Validate(pair.RefreshToken)
+	fmt.Println("refresh token used as access token:", err)
+
+	fake.Advance(2 * time.Minute)
+	_, err = hs.Validate(pair.AccessToken)
+	fmt.Println("after 2m, expired:", errors.Is(err, jwt.ErrTokenExpired))
+
+	pair, err = hs.Refresh(pair.RefreshToken)
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	_, err = hs.Validate(pair.AccessToken)
+	fmt.Println("refreshed access token valid:", err == nil)
+
+	// RS256: the issuer keeps the private key, validators get only the public one
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	issuer, _ := auth.NewRS256(key, auth.WithNow(fake.Now))
+	verifier, _ := auth.NewRS256Verifier(&key.PublicKey, auth.WithNow(fake.Now))
+	rsPair, _ := issuer.Issue("grace")
+	p, err = verifier.Validate(rsPair.AccessToken)
+	fmt.Println("RS256 token for", p.Subject, "valid at the verifier:", err == nil)
+	_, err = verifier.Issue("mallory")
+	fmt.Println("verifier issuing:", err)
+	_, err = verifier.Validate(pair.AccessToken)
+	fmt.Println("HS256 token at the RS256 verifier:", errors.Is(err, jwt.ErrTokenSignatureInvalid))
+
+	h := auth.Middleware(hs)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := ctxutil.PrincipalFrom(r.Context())
+		fmt.Fprintf(w, "hello %s", p.Subject)
+	}))
+	for _, token := range []string{"", "not-a-jwt", pair.AccessToken} {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		fmt.Printf("GET /me -> %d %q %s\n", rec.Code, rec.Header().Get("WWW-Authenticate"), rec.Body.String())
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/codec"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/cached"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/instrumented"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/logged"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/sharded"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/sqlite"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storebench"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+type target struct {
+	name     string
+	newStore storebench.NewStore
+}
+
+var targets = []target{
+	{"memory", func(*testing.B) service.UserStorer {
+		return db.NewMemoryStore()
+	}},
+	{"sharded", func(*testing.B) service.UserStorer {
+		return sharded.New(0)
+	}},
+	{"syncmap", func(*testing.B) service.UserStorer {
+		return sharded.NewSyncMap()
+	}},
+	{"cached", func(*testing.B) service.UserStorer {
+		return cached.New(db.NewMemoryStore())
+	}},
+	{"cached-msgpack", func(*testing.B) service.UserStorer {
+		return cached.New(db.NewMemoryStore(), cached.WithCodec(codec.MsgPack{}))
+	}},
+	{"logged", func(*testing.B) service.UserStorer {
+		return logged.New(db.NewMemoryStore(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	}},
+	{"instrumented", func(b *testing.B) service.UserStorer {
+		store, err := instrumented.New(db.NewMemoryStore(), prometheus.NewRegistry())
+		if err != nil {
+			b.Fatal(err)
+		}
+		return store
+	}},
+	{"sqlite", openSQLite},
+	{"cached-sqlite", func(b *testing.B) service.UserStorer {
+		return cached.New(openSQLite(b))
+	}},
+}
+
+func openSQLite(b *testing.B) service.UserStorer {
+	store, err := sqlite.Open(context.Background(), filepath.Join(b.TempDir(), "users.db"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { store.Close() })
+	return store
+}
+
+// Runs every storebench workload against the stores and decorators in the
+// repo through testing.Benchmark, the same benchmarks each store's own
+// BenchmarkStore runs under go test -bench, and prints one table per
+// workload. The last column is ns/op relative
+// to the first store listed, so decorators read as their overhead on the
+// store they wrap.
+//
+//	go run ./cmd/bench
+//	go run ./cmd/bench -stores sqlite,cached-sqlite -bench GetHot,GetCold -benchtime 3s
+//	go run ./cmd/bench -stores memory,sharded,syncmap -bench ParallelGet,ParallelUpdate
+func main() {
+	testing.Init()
+	stores := flag.String("stores", "memory,sharded,syncmap,cached,cached-msgpack,logged,instrumented,sqlite,cached-sqlite", "comma separated stores to measure")
+	benches := flag.String("bench", "Insert,GetHot,GetCold,Mixed", "comma separated workloads to run")
+	benchtime := flag.Duration("benchtime", time.Second, "time to spend on each workload and store")
+	flag.Parse()
+	flag.Set("test.benchtime", benchtime.String())
+
+	failed := 0
+	for _, bench := range storebench.Benchmarks() {
+		if !contains(strings.Split(*benches, ","), bench.Name) {
+			continue
+		}
+		fmt.Printf("%s\n%-14s %10s %12s %10s %11s %8s\n", bench.Name, "store", "ops", "ns/op", "B/op", "allocs/op", "relative")
+		var base float64
+		for _, t := range targets {
+			if !contains(strings.Split(*stores, ","), t.name) {
+				continue
+			}
+			var ok bool
+			res := testing.Benchmark(func(b *testing.B) {
+				defer func() { ok = !b.Failed() }()
+				bench.Run(b, t.newStore)
+			})
+			if !ok || res.N == 0 {
+				failed++
+				fmt.Printf("%-14s FAIL, run it under go test -bench for the error\n", t.name)
+				continue
+			}
+			ns := float64(res.T.Nanoseconds()) / float64(res.N)
+			if base == 0 {
+				base = ns
+			}
+			fmt.Printf("%-14s %10d %12.0f %10d %11d %7.1fx\n", t.name, res.N, ns, res.AllocedBytesPerOp(), res.AllocsPerOp(), ns/base)
+		}
+		fmt.Println()
+	}
+	if failed > 0 {
+		fmt.Printf("FAIL %d\n", failed)
+		os.Exit(1)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if strings.TrimSpace(v) == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/circuitbreaker"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/breaker"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+var errDown = errors.New("connection refused")
+
+// flakyStore fails every call while down is set, the way a store does when
+// its database goes away, and counts the calls that reach it.
+type flakyStore struct {
+	*db.MemoryStore
+	down  atomic.Bool
+	calls atomic.Int64
+}
+
+func (s *flakyStore) Get(ctx context.Context, id string) (*service.User, error) {
+	s.calls.Add(1)
+	if s.down.Load() {
+		return nil, errDown
+	}
+	return s.MemoryStore.Get(ctx, id)
+}
+
+// Walks the breaker through closed, open, half-open, and back with a fake
+// clock standing in for the 30 second reset timeout.
+//
+//	go run ./cmd/circuitbreaker
+func main() {
+	ctx := context.Background()
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	cb := circuitbreaker.New(
+		circuitbreaker.WithFailureThreshold(3),
+		circuitbreaker.WithResetTimeout(30*time.Second),
+		circuitbreaker.WithClock(fake),
+		circuitbreaker.WithOnStateChange(func(from, to circuitbreaker.State) {
+			fmt.Printf("  breaker %s -> %s\n", from, to)
+		}),
+	)
+	backend := &flakyStore{MemoryStore: db.NewMemoryStore()}
+	userService := service.NewUserService(breaker.New(backend, cb))
+	userService.CreateUser(ctx, &service.User{ID: "1", Email: "ada@example.com"})
+
+	// a missing user is a normal answer, it does not count as a failure
+	for range 5 {
+		userService.RetrieveUser(ctx, "missing")
+	}
+	fmt.Println("after 5 not found:", cb.State())
+
+	backend.calls.Store(0)
+	backend.down.Store(true)
+	for i := range 5 {
+		_, err := userService.RetrieveUser(ctx, "1")
+		fmt.Printf("get %d: %s, unavailable=%t\n", i+1, err, errors.Is(err, errs.ErrUnavailable))
+	}
+	fmt.Println("calls that reached the store:", backend.calls.Swap(0))
+
+	// still down when the timeout passes: the one trial call reopens it
+	fake.Advance(30 * time.Second)
+	fmt.Println("after reset timeout:", cb.State())
+	_, err := userService.RetrieveUser(ctx, "1")
+	fmt.Println("trial call:", err)
+
+	// back up: the next trial closes it and traffic flows again
+	backend.down.Store(false)
+	fake.Advance(30 * time.Second)
+	_, err = userService.RetrieveUser(ctx, "1")
+	fmt.Println("trial call:", err, "state:", cb.State())
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/codec"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+var codecs = []codec.Codec{codec.JSON{}, codec.MsgPack{}}
+
+// Compares the codecs the redis store and cached decorator can take on the
+// record they actually hold, a service.User: the bytes one takes in redis,
+// and the time and allocations to encode and decode it, measured with
+// testing.Benchmark, side by side. go test ./codec checks every codec
+// gives the user back as it was, a fast codec that loses a field is no
+// use, and go test -bench . ./codec runs the same benchmarks.
+//
+//	go run ./cmd/codec
+//	go run ./cmd/codec -benchtime 3s
+func main() {
+	testing.Init()
+	benchtime := flag.Duration("benchtime", time.Second, "time to spend on each codec and direction")
+	flag.Parse()
+	flag.Set("test.benchtime", benchtime.String())
+
+	created := time.Date(2026, 1, 2, 3, 4, 5, 678901234, time.UTC)
+	users := []struct {
+		name string
+		user service.User
+	}{
+		{"a new user", service.User{ID: "user-0000001", Email: "ada@example.com", Version: 1, CreatedAt: created, UpdatedAt: created}},
+		{"a deleted user", service.User{
+			ID: "0198c2a4-5b7e-7d3c-9f1a-2b4c6d8e0f12", Email: "grace.hopper@navy.example.mil", Name: "Grace Brewster Murray Hopper",
+			Version: 42, CreatedAt: created, UpdatedAt: created.Add(time.Hour), DeletedAt: created.Add(48 * time.Hour),
+		}},
+	}
+
+	for _, tc := range users {
+		fmt.Printf("\n%s\n%-8s %6s %10s %8s %7s %10s %8s %7s\n", tc.name, "codec", "bytes", "encode", "B/op", "allocs", "decode", "B/op", "allocs")
+		for _, c := range codecs {
+			b, _ := c.Marshal(tc.user)
+			enc := testing.Benchmark(func(bb *testing.B) {
+				bb.ReportAllocs()
+				for bb.Loop() {
+					c.Marshal(tc.user)
+				}
+			})
+			dec := testing.Benchmark(func(bb *testing.B) {
+				bb.ReportAllocs()
+				for bb.Loop() {
+					var u service.User
+					c.Unmarshal(b, &u)
+				}
+			})
+			fmt.Printf("%-8s %6d %8dns %8d %7d %8dns %8d %7d\n", c.Name(), len(b),
+				enc.NsPerOp(), enc.AllocedBytesPerOp(), enc.AllocsPerOp(),
+				dec.NsPerOp(), dec.AllocedBytesPerOp(), dec.AllocsPerOp())
+		}
+	}
+}
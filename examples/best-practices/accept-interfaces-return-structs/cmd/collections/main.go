@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/collections"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// The three containers on user data: a Set of email domains, an OrderedMap
+// tracking the most recently active users, and a Deque used as an undo
+// stack for deletes.
+//
+//	go run ./cmd/collections
+func main() {
+	ctx := context.Background()
+	userService := service.NewUserService(db.NewMemoryStore())
+	emails := []string{"ada@example.com", "grace@navy.mil", "linus@example.com", "ken@bell-labs.com", "rob@bell-labs.com"}
+	for i, email := range emails {
+		userService.CreateUser(ctx, &service.User{ID: fmt.Sprint(i + 1), Email: email})
+	}
+
+	domains := collections.NewSet[string]()
+	for _, email := range emails {
+		_, domain, _ := strings.Cut(email, "@")
+		domains.Add(domain)
+	}
+	allowed := collections.NewSet("example.com", "bell-labs.com")
+	fmt.Println("domains:", slices.Sorted(domains.All()))
+	fmt.Println("not allowed:", slices.Sorted(domains.Difference(allowed).All()))
+
+	// most recently active, capped at 3: touching a user moves it to the
+	// back, the oldest falls off the front
+	const keep = 3
+	recent := collections.NewOrderedMap[string, int]()
+	for i, id := range []string{"1", "2", "3", "1", "4", "2", "5"} {
+		recent.Delete(id)
+		recent.Set(id, i)
+		if recent.Len() > keep {
+			oldest, _, _ := recent.Oldest()
+			recent.Delete(oldest)
+		}
+	}
+	fmt.Println("recently active, oldest first:", slices.Collect(recent.Keys()))
+
+	// deletes pushed onto a stack, undo pops the latest
+	var undo collections.Deque[string]
+	for _, id := range []string{"2", "4", "5"} {
+		if err := userService.DeleteUser(ctx, id); err == nil {
+			undo.PushBack(id)
+		}
+	}
+	for range 2 {
+		id, _ := undo.PopBack()
+		userService.RestoreUser(ctx, id)
+		fmt.Println("undid delete of", id)
+	}
+	page, _ := userService.ListUsers(ctx, service.PageRequest{Limit: 10})
+	var live []string
+	for _, user := range page.Items {
+		live = append(live, user.ID)
+	}
+	fmt.Println("live users:", live, "still undoable:", undo.Len())
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	httptransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/http"
+)
+
+// Imports a large synthetic CSV file through POST /users.csv and exports it
+// again through GET /users.csv, timing both. The file is generated as it is
+// uploaded and never exists whole, on either side: rows go into the request
+// through a pipe, and the service reads them a batch at a time. Every
+// thousandth row is bad in one of three ways and comes back as a row error
+// with its line number, go test ./transport/http checks each of them.
+//
+// The import scales with the file. The export pages through
+// db.MemoryStore, whose List sorts every ID for each page, so large -rows
+// spend most of their time there rather than in the CSV.
+//
+//	go run ./cmd/csv
+//	go run ./cmd/csv -rows 200000
+func main() {
+	rows := flag.Int("rows", 50_000, "rows in the synthetic import")
+	flag.Parse()
+	if err := run(*rows); err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		os.Exit(1)
+	}
+}
+
+// badEvery is how often a synthetic row is bad, badKinds how many ways.
+const (
+	badEvery = 1000
+	badKinds = 3
+)
+
+// synthetic streams a header and n rows as CSV, with row i bad when
+// i%badEvery is badEvery-1: an invalid email, a field too many, or the ID of
+// the row before it. Row i is on line i+2.
+func synthetic(n int) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		w := csv.NewWriter(pw)
+		w.Write([]string{"id", "email", "name"})
+		for i := range n {
+			id := fmt.Sprintf("user-%07d", i)
+			row := []string{id, id + "@example.com", fmt.Sprintf("User %d, the %dth", i, i)}
+			if i%badEvery == badEvery-1 {
+				switch (i / badEvery) % badKinds {
+				case 0:
+					row[1] = "not-an-email"
+				case 1:
+					row = append(row, "extra")
+				case 2:
+					row[0] = fmt.Sprintf("user-%07d", i-1)
+					row[1] = "dup-" + row[1]
+				}
+			}
+			w.Write(row)
+		}
+		w.Flush()
+		pw.CloseWithError(w.Error())
+	}()
+	return pr
+}
+
+type importResponse struct {
+	Created int `json:"created"`
+	Failed  int `json:"failed"`
+	Errors  []struct {
+		Line  int    `json:"line"`
+		ID    string `json:"id"`
+		Error string `json:"error"`
+	} `json:"errors"`
+}
+
+func run(rows int) error {
+	users := service.NewUserService(db.NewMemoryStore())
+	api := httptest.NewServer(httptransport.NewHandler(users, nil))
+	defer api.Close()
+
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	resp, err := http.Post(api.URL+"/users.csv", "text/csv", synthetic(rows))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("POST /users.csv = %s", resp.Status)
+	}
+	var result importResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	fmt.Printf("POST /users.csv: %d rows in %s, %d MiB allocated in all\n", rows, time.Since(start).Round(time.Millisecond), (after.TotalAlloc-before.TotalAlloc)>>20)
+	fmt.Printf("    created %d, failed %d\n", result.Created, result.Failed)
+	for _, e := range result.Errors[:min(len(result.Errors), badKinds)] {
+		fmt.Printf("    line %d, %s: %s\n", e.Line, e.ID, e.Error)
+	}
+
+	start = time.Now()
+	resp, err = http.Get(api.URL + "/users.csv")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	n, err := io.Copy(io.Discard, resp.Body)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("GET /users.csv: %d KiB in %s\n", n>>10, time.Since(start).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/debounce"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Search-as-you-type with Debounce, a bulk import reporting progress with
+// Throttle, and the channel forms on a burst of change notifications.
+//
+//	go run ./cmd/debounce
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	userService := service.NewUserService(db.NewMemoryStore())
+
+	// one lookup per pause in typing instead of one per keystroke
+	var searches sync.WaitGroup
+	search := debounce.Debounce(ctx, 100*time.Millisecond, func(prefix string) {
+		defer searches.Done()
+		fmt.Printf("search %q\n", prefix)
+	})
+	typed := ""
+	for _, word := range []string{"gopher", "s"} {
+		searches.Add(1)
+		for _, r := range word {
+			typed += string(r)
+			search(typed)
+			time.Sleep(20 * time.Millisecond)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	searches.Wait()
+	fmt.Printf("%d keystrokes, 2 searches\n\n", len(typed))
+
+	// progress every 50ms however fast the import goes, the final count
+	// arrives on the trailing edge
+	progress := debounce.Throttle(ctx, 50*time.Millisecond, func(n int) {
+		fmt.Printf("imported %d users\n", n)
+	})
+	const total = 2000
+	for i := 1; i <= total; i++ {
+		userService.CreateUser(ctx, &service.User{ID: fmt.Sprint(i), Email: fmt.Sprintf("user%d@example.com", i)})
+		progress(i)
+		if i%100 == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	fmt.Println()
+
+	// the same on channels: a burst of change notifications, e.g. an editor
+	// saving a config file, becomes one reload
+	changes := make(chan string)
+	reloads := debounce.DebounceChan(ctx, 50*time.Millisecond, changes)
+	go func() {
+		defer close(changes)
+		for _, burst := range [][]string{{"write", "chmod", "write"}, {"rename", "create"}} {
+			for _, op := range burst {
+				changes <- op
+				time.Sleep(5 * time.Millisecond)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}()
+	var seen []string
+	for op := range reloads {
+		seen = append(seen, op)
+	}
+	fmt.Printf("5 change events, reloaded after: %s\n", strings.Join(seen, ", "))
+}
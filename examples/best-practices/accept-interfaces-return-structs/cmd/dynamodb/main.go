@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	awsdynamodb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/dynamodb"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Runs against dynamodb-local or localstack, no AWS account needed.
+//
+//	docker run -p 8000:8000 amazon/dynamodb-local
+//	go run ./cmd/dynamodb -endpoint http://localhost:8000
+func main() {
+	endpoint := flag.String("endpoint", "http://localhost:8000", "dynamodb endpoint, empty uses the AWS default")
+	table := flag.String("table", "users", "table name")
+	flag.Parse()
+
+	ctx := context.Background()
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion("us-east-1"),
+		// dynamodb-local accepts any static credentials
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("local", "local", "")),
+	)
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		os.Exit(1)
+	}
+
+	client := awsdynamodb.NewFromConfig(cfg, func(o *awsdynamodb.Options) {
+		if *endpoint != "" {
+			o.BaseEndpoint = aws.String(*endpoint)
+		}
+	})
+
+	if err := dynamodb.CreateTable(ctx, client, *table); err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		os.Exit(1)
+	}
+
+	userService := service.NewUserService(dynamodb.New(client, *table))
+
+	user := &service.User{ID: "1", Email: "gopher@example.com"}
+	if err := userService.CreateUser(ctx, user); err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+	}
+
+	found, err := userService.RetrieveUser(ctx, user.ID)
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		os.Exit(1)
+	}
+	fmt.Printf("User retrieved: %+v\n", found)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/eventbus"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/events"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Two consumers of the same bus: an audit trail that must see every event
+// (Block) and a slow dashboard that would rather skip events than slow the
+// service down (Drop).
+func main() {
+	ctx := context.Background()
+
+	bus := eventbus.New()
+	audit := bus.Subscribe(eventbus.AllTopics, eventbus.WithBuffer(64))
+	dashboard := bus.Subscribe(events.NameUserCreated, eventbus.WithBuffer(1), eventbus.WithPolicy(eventbus.Drop))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		n := 0
+		for range audit.Events() {
+			n++
+		}
+		fmt.Printf("audit: received %d events\n", n)
+	}()
+	go func() {
+		defer wg.Done()
+		n := 0
+		for range dashboard.Events() {
+			n++
+			time.Sleep(10 * time.Millisecond)
+		}
+		fmt.Printf("dashboard: received %d events, dropped %d\n", n, dashboard.Dropped())
+	}()
+
+	userService := service.NewUserService(db.NewMemoryStore(), service.WithPublisher(bus))
+	for i := 0; i < 20; i++ {
+		userService.CreateUser(ctx, &service.User{ID: fmt.Sprint(i), Email: fmt.Sprintf("user%d@example.com", i)})
+	}
+	userService.DeleteUser(ctx, "0")
+
+	// Close stops new publishes and closes the channels, both consumers
+	// drain what is buffered and then their range loops end.
+	bus.Close()
+	wg.Wait()
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/events"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// directory is a projection: a read model kept up to date purely from
+// events, it never queries the store.
+type directory struct {
+	mu     sync.Mutex
+	emails map[string]string
+}
+
+func (d *directory) handle(ctx context.Context, event events.Event) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch e := event.(type) {
+	case events.UserCreated:
+		d.emails[e.UserID] = e.Email
+	case events.UserUpdated:
+		d.emails[e.UserID] = e.Email
+	case events.UserDeleted:
+		delete(d.emails, e.UserID)
+	}
+	return nil
+}
+
+func (d *directory) print() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ids := make([]string, 0, len(d.emails))
+	for id := range d.emails {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		fmt.Printf("  %s -> %s\n", id, d.emails[id])
+	}
+}
+
+// Publishes the users service's events on an in-process bus to a logger
+// and a directory projection, then prints the directory, which has
+// followed every create, update, and delete without reading the store.
+//
+//	go run ./cmd/events
+func main() {
+	ctx := context.Background()
+
+	bus := events.NewBus()
+	dir := &directory{emails: make(map[string]string)}
+	bus.Subscribe(dir.handle)
+	bus.Subscribe(func(ctx context.Context, event events.Event) error {
+		fmt.Printf("event: %s %+v\n", event.Name(), event)
+		return nil
+	})
+
+	userService := service.NewUserService(db.NewMemoryStore(), service.WithPublisher(bus))
+
+	userService.CreateUser(ctx, &service.User{ID: "1", Email: "one@example.com"})
+	userService.CreateUser(ctx, &service.User{ID: "2", Email: "two@example.com"})
+	if user, err := userService.RetrieveUser(ctx, "1"); err == nil {
+		user.Email = "uno@example.com"
+		userService.UpdateUser(ctx, user)
+	}
+	userService.DeleteUser(ctx, "2")
+
+	fmt.Println("directory projection:")
+	dir.print()
+}
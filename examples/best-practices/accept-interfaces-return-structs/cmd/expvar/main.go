@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/retry"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/runner"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	httptransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/http"
+)
+
+// expvar values are process globals, published once at init and safe for
+// concurrent use, the standard library alternative to a metrics client.
+var (
+	usersCreated = expvar.NewInt("users_created")
+	storeErrors  = expvar.NewMap("store_errors")
+	start        = time.Now()
+)
+
+func init() {
+	// memstats and cmdline are published by the expvar package itself
+	expvar.Publish("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+	expvar.Publish("uptime_seconds", expvar.Func(func() any { return int64(time.Since(start).Seconds()) }))
+}
+
+// countingStore counts failed calls per op in store_errors.
+type countingStore struct {
+	*db.MemoryStore
+}
+
+func (s countingStore) Insert(ctx context.Context, user *service.User) error {
+	return count("insert", s.MemoryStore.Insert(ctx, user))
+}
+
+func (s countingStore) Get(ctx context.Context, id string) (*service.User, error) {
+	user, err := s.MemoryStore.Get(ctx, id)
+	return user, count("get", err)
+}
+
+func count(op string, err error) error {
+	if err != nil {
+		storeErrors.Add(op, 1)
+	}
+	return err
+}
+
+// Serves /debug/vars while a background loop drives the user service, or
+// with -poll scrapes another process and prints what changed.
+//
+//	go run ./cmd/expvar -addr :8080
+//	curl localhost:8080/debug/vars
+//	go run ./cmd/expvar -poll http://localhost:8080/debug/vars
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	poll := flag.String("poll", "", "scrape this /debug/vars URL instead of serving")
+	interval := flag.Duration("interval", 2*time.Second, "poll interval")
+	flag.Parse()
+
+	if *poll != "" {
+		poller(*poll, *interval)
+		return
+	}
+
+	userService := service.NewUserService(countingStore{db.NewMemoryStore()})
+	userService.OnUserCreated(func(context.Context, *service.User) error {
+		usersCreated.Add(1)
+		return nil
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	fmt.Printf("serving expvars on %s/debug/vars\n", *addr)
+	group := runner.New()
+	group.Add("http", httptransport.NewServer(*addr, mux))
+	group.Add("simulate", runner.Func(func(ctx context.Context) error {
+		return simulate(ctx, userService)
+	}))
+	if err := group.Run(context.Background()); err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+	}
+}
+
+// simulate creates users, every tenth one a duplicate, and reads a mix of
+// existing and missing IDs so both counters move.
+func simulate(ctx context.Context, userService *service.UserService) error {
+	for i := 0; ctx.Err() == nil; i++ {
+		id := strconv.Itoa(i)
+		if i%10 == 9 {
+			id = strconv.Itoa(i - 1)
+		}
+		userService.CreateUser(ctx, &service.User{ID: id, Email: id + "@example.com"})
+		userService.RetrieveUser(ctx, strconv.Itoa(rand.Intn(i+10)))
+		time.Sleep(20 * time.Millisecond)
+	}
+	return ctx.Err()
+}
+
+// vars is the subset of /debug/vars the poller prints, memstats is large.
+type vars struct {
+	UsersCreated  int64            `json:"users_created"`
+	StoreErrors   map[string]int64 `json:"store_errors"`
+	Goroutines    int              `json:"goroutines"`
+	UptimeSeconds int64            `json:"uptime_seconds"`
+	MemStats      struct {
+		HeapAlloc uint64 `json:"HeapAlloc"`
+		NumGC     uint32 `json:"NumGC"`
+	} `json:"memstats"`
+}
+
+func poller(url string, interval time.Duration) {
+	client := &http.Client{Timeout: interval}
+	// a restarting target is retried within the interval, a 404 is not
+	policy := retry.Policy{
+		MaxElapsed: interval,
+		Backoff:    retry.Jitter(retry.Exponential(100*time.Millisecond, interval/2)),
+		Retryable:  retryableScrape,
+	}
+	var prev vars
+	for ; ; time.Sleep(interval) {
+		cur, err := retry.DoValue(context.Background(), policy, func(ctx context.Context) (vars, error) {
+			return scrape(ctx, client, url)
+		})
+		if err != nil {
+			fmt.Println(fmt.Errorf("error: %s", err))
+			continue
+		}
+		ops := make([]string, 0, len(cur.StoreErrors))
+		for op := range cur.StoreErrors {
+			ops = append(ops, op)
+		}
+		sort.Strings(ops)
+		fmt.Printf("up %4ds  users %6d (+%d)  goroutines %3d  heap %6.1f MiB  gc %d\n",
+			cur.UptimeSeconds, cur.UsersCreated, cur.UsersCreated-prev.UsersCreated,
+			cur.Goroutines, float64(cur.MemStats.HeapAlloc)/(1<<20), cur.MemStats.NumGC)
+		for _, op := range ops {
+			fmt.Printf("  store_errors[%s] %d (+%d)\n", op, cur.StoreErrors[op], cur.StoreErrors[op]-prev.StoreErrors[op])
+		}
+		prev = cur
+	}
+}
+
+// statusError is a response that arrived but was not a 200.
+type statusError struct {
+	url  string
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s: %d %s", e.url, e.code, http.StatusText(e.code))
+}
+
+// retryableScrape retries connection failures, 5xx, and 429, anything
+// else will fail the same way next time.
+func retryableScrape(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= 500 || se.code == http.StatusTooManyRequests
+	}
+	return !errors.Is(err, context.Canceled)
+}
+
+func scrape(ctx context.Context, client *http.Client, url string) (vars, error) {
+	var v vars
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return v, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return v, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return v, &statusError{url: url, code: resp.StatusCode}
+	}
+	return v, json.NewDecoder(resp.Body).Decode(&v)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/featureflag"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Flips the strict validation flag by rewriting the flag file while the
+// service runs, no restart and no redeploy.
+//
+//	go run ./cmd/featureflag
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir, err := os.MkdirTemp("", "flags")
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "flags.json")
+	write(path, `{"users.strict-validation": false}`)
+
+	flags := featureflag.NewMemory(nil)
+	if err := flags.Watch(ctx, path, 50*time.Millisecond, nil); err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	userService := service.NewUserService(db.NewMemoryStore(), service.WithFlags(flags))
+
+	fmt.Println("flag off, no name:", result(userService.CreateUser(ctx, &service.User{ID: "1", Email: "ada@example.com"})))
+
+	write(path, `{"users.strict-validation": true}`)
+	waitFor(ctx, flags, true)
+	fmt.Println("flag on, no name:", result(userService.CreateUser(ctx, &service.User{ID: "2", Email: "grace@example.com"})))
+	fmt.Println("flag on, named:", result(userService.CreateUser(ctx, &service.User{ID: "3", Email: "alan@example.com", Name: "Alan Turing"})))
+
+	// a broken edit keeps the last good flags instead of turning them all off
+	write(path, `{"users.strict-validation": tru`)
+	time.Sleep(200 * time.Millisecond)
+	fmt.Println("after a bad edit, still on:", flags.Enabled(ctx, service.FlagStrictValidation))
+
+	// rolling back is the same edit in reverse
+	write(path, `{"users.strict-validation": false}`)
+	waitFor(ctx, flags, false)
+	fmt.Println("flag off again, no name:", result(userService.CreateUser(ctx, &service.User{ID: "4", Email: "edsger@example.com"})))
+}
+
+func write(path, flags string) {
+	if err := os.WriteFile(path, []byte(flags), 0o644); err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+	}
+}
+
+// waitFor polls until the watcher has picked up the new file.
+func waitFor(ctx context.Context, flags featureflag.Provider, on bool) {
+	for flags.Enabled(ctx, service.FlagStrictValidation) != on {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func result(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return "created"
+}
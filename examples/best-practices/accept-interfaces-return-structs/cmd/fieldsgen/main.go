@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/fieldsgen"
+)
+
+// Generates a Fields method for each of the -type structs in the package
+// in the working directory, or in the directory given, into
+// <first type>_fields.go there. Meant for go:generate, which runs it in the
+// package's directory:
+//
+//	//go:generate go run ../cmd/fieldsgen -type User
+func main() {
+	types := flag.String("type", "", "comma-separated struct type names, required")
+	output := flag.String("output", "", "output file name, default <first type>_fields.go")
+	flag.Parse()
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	if *types == "" {
+		fmt.Fprintln(os.Stderr, "usage: fieldsgen -type T[,T...] [-output file] [dir]")
+		os.Exit(2)
+	}
+	names := strings.Split(*types, ",")
+	src, err := fieldsgen.Generate(dir, names)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("error: %s", err))
+		os.Exit(1)
+	}
+	if *output == "" {
+		*output = strings.ToLower(names[0]) + "_fields.go"
+	}
+	if err := os.WriteFile(filepath.Join(dir, *output), src, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("error: %s", err))
+		os.Exit(1)
+	}
+}
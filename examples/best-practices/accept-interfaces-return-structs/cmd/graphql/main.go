@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/shutdown"
+	graphqltransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/graphql"
+)
+
+// countingStore counts Gets so the demo can show what the loader saved.
+type countingStore struct {
+	*db.MemoryStore
+	gets atomic.Int64
+}
+
+func (s *countingStore) Get(ctx context.Context, id string) (*service.User, error) {
+	s.gets.Add(1)
+	return s.MemoryStore.Get(ctx, id)
+}
+
+// Runs a few queries in process and then serves /graphql.
+//
+//	go run ./cmd/graphql -addr :8080
+//	curl localhost:8080/graphql -d '{"query":"{ users { nodes { id email } } }"}'
+func main() {
+	addr := flag.String("addr", "", "serve /graphql on this address after the demo")
+	flag.Parse()
+
+	store := &countingStore{MemoryStore: db.NewMemoryStore()}
+	handler, err := graphqltransport.NewHandler(service.NewUserService(store))
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+
+	query(handler, `mutation { createUser(input: {id: "1", email: "ada@example.com"}) { id version } }`)
+	query(handler, `mutation { createUser(input: {id: "2", email: "grace@example.com"}) { id version } }`)
+	query(handler, `mutation { createUser(input: {id: "3", email: "ada@example.com"}) { id } }`)
+
+	// five lookups of three distinct IDs, the loader turns them into three Gets
+	store.gets.Store(0)
+	query(handler, `{
+		a: user(id: "1") { email }
+		b: user(id: "1") { email }
+		c: user(id: "2") { email }
+		d: user(id: "2") { email }
+		e: user(id: "missing") { email }
+	}`)
+	fmt.Println("store gets for 5 lookups:", store.gets.Load())
+
+	query(handler, `{ users(first: 1) { nodes { id email } nextCursor } }`)
+
+	if *addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/graphql", handler)
+		srv := &http.Server{Addr: *addr, Handler: mux}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				fmt.Println(fmt.Errorf("error: %s", err))
+				cancel()
+			}
+		}()
+		fmt.Printf("serving graphql on %s/graphql\n", *addr)
+		cleanup := shutdown.New()
+		cleanup.Add("http", srv.Shutdown)
+		cleanup.Wait(ctx)
+	}
+}
+
+func query(h http.Handler, q string) {
+	body := fmt.Sprintf(`{"query": %q}`, q)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body)))
+	out, _ := io.ReadAll(rec.Body)
+	fmt.Println(string(out))
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	grpctransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/grpc"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/grpc/userspb"
+)
+
+// Starts the gRPC server on a free port and drives it with the generated
+// client, the way a separate service would.
+func main() {
+	ctx := context.Background()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		grpctransport.LoggingInterceptor(nil),
+		grpctransport.RecoveryInterceptor(nil),
+	))
+	userspb.RegisterUsersServer(srv, grpctransport.NewServer(service.NewUserService(db.NewMemoryStore())))
+	go srv.Serve(ln)
+	defer srv.GracefulStop()
+
+	conn, err := grpc.NewClient(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	defer conn.Close()
+	client := userspb.NewUsersClient(conn)
+
+	user, err := client.CreateUser(ctx, &userspb.CreateUserRequest{Id: "1", Email: "ada@example.com", Name: "Ada Lovelace"})
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	fmt.Println("created:", user.GetId(), user.GetEmail(), user.GetName(), "version", user.GetVersion())
+
+	user, err = client.UpdateUser(ctx, &userspb.UpdateUserRequest{Id: "1", Email: "ada@lovelace.dev", Name: user.GetName(), Version: user.GetVersion()})
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	fmt.Println("updated:", user.GetEmail(), user.GetName(), "version", user.GetVersion())
+
+	// status codes travel instead of Go errors, errors.Is does not cross the wire
+	_, err = client.UpdateUser(ctx, &userspb.UpdateUserRequest{Id: "1", Email: "stale@example.com", Version: 1})
+	fmt.Println("stale update:", status.Code(err))
+	_, err = client.GetUser(ctx, &userspb.GetUserRequest{Id: "missing"})
+	fmt.Println("missing user:", status.Code(err))
+	_, err = client.CreateUser(ctx, &userspb.CreateUserRequest{Id: "2", Email: "not-an-email"})
+	fmt.Println("bad email:", status.Code(err), status.Convert(err).Message())
+
+	list, err := client.ListUsers(ctx, &userspb.ListUsersRequest{PageSize: 10})
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	fmt.Println("listed:", len(list.GetUsers()), "users")
+}
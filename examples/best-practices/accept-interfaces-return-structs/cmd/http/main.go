@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/app"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/config"
+)
+
+// Serves the users REST API until Ctrl-C, then drains, closes the store,
+// and flushes traces.
+//
+//	go run ./cmd/http -addr :8080 -db users.db
+//	go run ./cmd/http -trace stdout
+//	USERS_LOG_FORMAT=text go run ./cmd/http -config users.yaml -log-level debug
+//	curl -i -X POST localhost:8080/users -H "X-Tenant-ID: acme" -d '{"email":"ada@example.com"}'
+//	curl -i localhost:8080/users -H "X-Tenant-ID: acme"
+//	curl -i -X POST localhost:8080/v2/users -H "X-Tenant-ID: acme" -d '{"email":"grace@example.com","first_name":"Grace","last_name":"Hopper"}'
+//	curl -N localhost:8080/users/events -H "X-Tenant-ID: acme"
+//	curl -i localhost:8080/readyz
+//	open http://localhost:8080/docs
+//
+// The wiring lives in package app, see NewApp and its wire generated twin.
+func main() {
+	cfg, err := config.Load("http", os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+
+	ctx := context.Background()
+	a, err := app.NewApp(ctx, cfg)
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	a.Logger.Info("config loaded", slog.String("config", cfg.String()))
+	if err := a.Run(ctx); err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+	}
+}
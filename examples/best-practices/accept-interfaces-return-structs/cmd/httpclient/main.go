@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/circuitbreaker"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/emailverify"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/httpclient"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/logging"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/retry"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// verifyAPI stands in for a third-party email verification service. Every
+// third request fails with a 503 while flaky is set, every request while
+// down is set.
+type verifyAPI struct {
+	flaky, down atomic.Bool
+	requests    atomic.Int64
+}
+
+func (a *verifyAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := a.requests.Add(1)
+	if a.down.Load() || (a.flaky.Load() && n%3 == 1) {
+		http.Error(w, "upstream overloaded", http.StatusServiceUnavailable)
+		return
+	}
+	email := r.URL.Query().Get("email")
+	res := emailverify.Result{Deliverable: true}
+	if strings.HasSuffix(email, ".invalid") {
+		res = emailverify.Result{Reason: "domain has no mail server"}
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+// Creates users while a before-create hook checks each address against a
+// verification API through httpclient: a flaky API is retried, an
+// undeliverable address is rejected, a dead API opens the breaker and
+// signups go through unverified. Last, the same verifier runs against a
+// fake Doer, no server at all.
+//
+//	go run ./cmd/httpclient
+func main() {
+	ctx := context.Background()
+	logger := logging.New(os.Stderr, logging.FromEnv())
+	api := &verifyAPI{}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	cb := circuitbreaker.New(
+		circuitbreaker.WithFailureThreshold(2),
+		circuitbreaker.WithOnStateChange(func(from, to circuitbreaker.State) {
+			fmt.Printf("  breaker %s -> %s\n", from, to)
+		}),
+	)
+	client := httpclient.New(
+		httpclient.WithTransport(server.Client().Transport),
+		httpclient.WithTimeout(time.Second),
+		httpclient.WithPolicy(retry.Policy{
+			MaxAttempts: 3,
+			Backoff:     retry.Jitter(retry.Exponential(20*time.Millisecond, 200*time.Millisecond)),
+		}),
+		httpclient.WithBreaker(cb),
+		httpclient.WithLogger(logger),
+		httpclient.WithResponseHook(func(req *http.Request, resp *http.Response, err error, elapsed time.Duration) {
+			if err != nil {
+				fmt.Printf("  %s %s: %s\n", req.Method, req.URL.Path, err)
+				return
+			}
+			fmt.Printf("  %s %s: %d in %s\n", req.Method, req.URL.Path, resp.StatusCode, elapsed.Round(10*time.Microsecond))
+		}),
+	)
+	verifier := emailverify.New(client, server.URL+"/v1/check", emailverify.WithLogger(logger))
+	userService := service.NewUserService(db.NewMemoryStore())
+	userService.OnBeforeUserCreated(verifier.Hook())
+
+	create := func(email string) {
+		fmt.Printf("create %s\n", email)
+		id, _, _ := strings.Cut(email, "@")
+		err := userService.CreateUser(ctx, &service.User{ID: id, Email: email})
+		switch {
+		case errors.Is(err, errs.ErrInvalidInput):
+			fmt.Printf("  rejected: %s\n", err)
+		case err != nil:
+			fmt.Println(fmt.Errorf("error: %s", err))
+		default:
+			fmt.Println("  created")
+		}
+	}
+
+	fmt.Println("-- flaky API, the 503s are retried")
+	api.flaky.Store(true)
+	create("ada@example.com")
+	create("grace@example.com")
+	create("alan@mail.invalid")
+
+	fmt.Println("-- API down, a POST is not idempotent and is sent once")
+	api.down.Store(true)
+	api.requests.Store(0)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/v1/check", strings.NewReader(`{}`))
+	if _, err := client.Do(req); err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+	}
+	fmt.Println("-- retries run out, the breaker opens, signups go through unverified")
+	create("edsger@example.com")
+	create("barbara@example.com")
+	create("donald@example.com")
+	fmt.Printf("%d requests reached the API\n", api.requests.Load())
+
+	fmt.Println("-- fake Doer")
+	fake := httpclient.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		body := `{"deliverable":true}`
+		if strings.HasPrefix(req.URL.Query().Get("email"), "bounce") {
+			body = `{"deliverable":false,"reason":"mailbox full"}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})
+	offline := emailverify.New(fake, "https://verify.example.com/v1/check")
+	for _, email := range []string{"linus@example.com", "bounce@example.com"} {
+		res, err := offline.Verify(ctx, email)
+		fmt.Printf("%s: %+v %v\n", email, res, err)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/query"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/seq"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// countingStore counts Query calls, one per page fetched, and fails them
+// once failAfter calls have been made.
+type countingStore struct {
+	*db.MemoryStore
+	lists     atomic.Int64
+	failAfter int64
+}
+
+func (s *countingStore) Query(ctx context.Context, q query.Query) ([]*service.User, error) {
+	if n := s.lists.Add(1); s.failAfter > 0 && n > s.failAfter {
+		return nil, errors.New("connection reset")
+	}
+	return s.MemoryStore.Query(ctx, q)
+}
+
+// Streams 250 users through UserService.ListAll, which pages 100 at a
+// time. Breaking early fetches only the pages it needed, combinators stay
+// lazy, and a failure mid-stream arrives as the loop's error.
+//
+//	go run ./cmd/iter
+func main() {
+	ctx := context.Background()
+	store := &countingStore{MemoryStore: db.NewMemoryStore()}
+	users := service.NewUserService(store)
+	for i := range 250 {
+		domain := "example.com"
+		if i%4 == 0 {
+			domain = "navy.mil"
+		}
+		users.CreateUser(ctx, &service.User{ID: fmt.Sprintf("%03d", i), Email: fmt.Sprintf("user%d@%s", i, domain)})
+	}
+
+	n := 0
+	for _, err := range users.ListAll(ctx) {
+		if err != nil {
+			fmt.Println(fmt.Errorf("error: %s", err))
+			return
+		}
+		n++
+	}
+	fmt.Printf("ranged over all %d users with %d page fetches\n", n, store.lists.Swap(0))
+
+	for user, err := range users.ListAll(ctx) {
+		if err != nil {
+			fmt.Println(fmt.Errorf("error: %s", err))
+			return
+		}
+		if user.ID == "042" {
+			break
+		}
+	}
+	fmt.Printf("broke at user 042 after %d page fetch\n", store.lists.Swap(0))
+
+	// errors pass through the filter, only users are judged
+	navy := seq.Filter2(users.ListAll(ctx), func(user *service.User, err error) bool {
+		return err != nil || strings.HasSuffix(user.Email, "@navy.mil")
+	})
+	emails := seq.Map2(seq.Take2(navy, 30), func(user *service.User, err error) (string, error) {
+		if err != nil {
+			return "", err
+		}
+		return user.Email, nil
+	})
+	fmt.Printf("built the pipeline, %d page fetches so far\n", store.lists.Load())
+	first, err := seq.Collect2(emails)
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	fmt.Printf("first 30 navy.mil addresses, %s .. %s, took %d page fetches\n", first[0], first[len(first)-1], store.lists.Swap(0))
+
+	store.failAfter = 1
+	all, err := seq.Collect2(users.ListAll(ctx))
+	fmt.Printf("store failing on page 2: got %d users, error: %v\n", len(all), err)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/events"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/jobqueue"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+type welcomeEmail struct {
+	UserID string
+	To     string
+}
+
+// mailer stands in for an SMTP relay: every third send is rejected with a
+// temporary error, and addresses on bounce.example never exist.
+type mailer struct {
+	calls atomic.Int64
+	mu    sync.Mutex
+	sent  []string
+}
+
+func (m *mailer) Send(ctx context.Context, email welcomeEmail) error {
+	n := m.calls.Add(1)
+	select {
+	case <-time.After(5 * time.Millisecond):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if strings.HasSuffix(email.To, "@bounce.example") {
+		return errs.Wrap("mailer.Send", fmt.Errorf("%w: 550 no such mailbox %s", errs.ErrInvalidInput, email.To))
+	}
+	if n%3 == 0 {
+		return errors.New("mailer.Send: 421 try again later")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, email.To)
+	return nil
+}
+
+// Sends a welcome email for every UserCreated event through a job queue, so
+// the request that created the user never waits on the mail relay. Temporary
+// rejections are retried, bounces go straight to the dead-letter channel,
+// and Shutdown drains what is left before the program exits. A short
+// -shutdown cancels whatever has not finished by then, those jobs are
+// dead-lettered too.
+//
+//	go run ./cmd/jobqueue -users 20 -workers 4
+//	go run ./cmd/jobqueue -shutdown 10ms
+func main() {
+	users := flag.Int("users", 20, "users to create")
+	workers := flag.Int("workers", 4, "queue workers")
+	timeout := flag.Duration("shutdown", 5*time.Second, "how long Shutdown waits for queued jobs")
+	flag.Parse()
+
+	ctx := context.Background()
+	m := &mailer{}
+	queue := jobqueue.New(*workers, m.Send, jobqueue.WithPolicy(jobqueue.Policy{
+		MaxAttempts: 3,
+		BaseDelay:   10 * time.Millisecond,
+		MaxDelay:    100 * time.Millisecond,
+	}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for job := range queue.DeadLetters() {
+			fmt.Printf("dead letter: job %d to %s after %d attempt(s): %s\n", job.ID, job.Payload.To, job.Attempts, job.Err)
+		}
+	}()
+
+	bus := events.NewBus()
+	bus.Subscribe(func(ctx context.Context, event events.Event) error {
+		created, ok := event.(events.UserCreated)
+		if !ok {
+			return nil
+		}
+		_, err := queue.Enqueue(ctx, welcomeEmail{UserID: created.UserID, To: created.Email})
+		return err
+	})
+
+	userService := service.NewUserService(db.NewMemoryStore(), service.WithPublisher(bus))
+	start := time.Now()
+	for i := range *users {
+		email := fmt.Sprintf("user%d@example.com", i)
+		if i%7 == 6 {
+			email = fmt.Sprintf("user%d@bounce.example", i)
+		}
+		if err := userService.CreateUser(ctx, &service.User{ID: fmt.Sprint(i), Email: email}); err != nil {
+			fmt.Println(fmt.Errorf("error: %s", err))
+		}
+	}
+	fmt.Printf("created %d users in %s\n", *users, time.Since(start).Round(time.Millisecond))
+
+	// a one-off job that must not be repeated gets its own policy
+	queue.Enqueue(ctx, welcomeEmail{UserID: "admin", To: "admin@example.com"}, jobqueue.WithRetry(jobqueue.Policy{MaxAttempts: 1}))
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	if err := queue.Shutdown(shutdownCtx); err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+	}
+	wg.Wait()
+
+	if _, err := queue.Enqueue(ctx, welcomeEmail{To: "late@example.com"}); err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+	}
+	fmt.Printf("sent %d of %d emails with %d relay calls in %s\n", len(m.sent), *users+1, m.calls.Load(), time.Since(start).Round(time.Millisecond))
+}
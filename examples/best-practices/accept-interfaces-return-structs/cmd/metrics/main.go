@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/instrumented"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Serves /metrics while a background loop drives the user service.
+//
+//	go run ./cmd/metrics -addr :9090
+//	curl localhost:9090/metrics | grep users_store
+func main() {
+	addr := flag.String("addr", ":9090", "listen address")
+	flag.Parse()
+
+	reg := prometheus.NewRegistry()
+	store, err := instrumented.New(db.NewMemoryStore(), reg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	userService := service.NewUserService(store)
+
+	go simulate(context.Background(), userService)
+
+	http.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	fmt.Printf("serving metrics on %s/metrics\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+// simulate creates users and reads a mix of existing and missing IDs so
+// both the ok and error series have data.
+func simulate(ctx context.Context, userService *service.UserService) {
+	for i := 0; ; i++ {
+		id := strconv.Itoa(i)
+		userService.CreateUser(ctx, &service.User{ID: id, Email: id + "@example.com"})
+		userService.RetrieveUser(ctx, strconv.Itoa(rand.Intn(i+10)))
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/query"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	httptransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/http"
+)
+
+// countingStore counts the pages the export reads.
+type countingStore struct {
+	*db.MemoryStore
+	pages atomic.Int64
+}
+
+func (s *countingStore) Query(ctx context.Context, q query.Query) ([]*service.User, error) {
+	s.pages.Add(1)
+	return s.MemoryStore.Query(ctx, q)
+}
+
+type user struct {
+	ID        string `json:"id"`
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+}
+
+// exportUsers is the consuming side: it reads GET /users/export one line
+// at a time and hands each user to fn as it arrives, never holding more
+// than the one. Returning an error from fn, or canceling ctx, hangs up,
+// which is what stops the server reading further.
+func exportUsers(ctx context.Context, url string, fn func(user) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"/users/export", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET /users/export = %d", resp.StatusCode)
+	}
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var u user
+		err := dec.Decode(&u)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			// a stream the server aborted ends without its last line
+			return fmt.Errorf("export broken off: %w", err)
+		}
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+}
+
+// Streams every user out of GET /users/export as newline-delimited JSON
+// and reads it back with exportUsers, a client that handles each line as
+// it arrives. The store counts the pages read, which shows the server only
+// reads as fast as the client does: a client that pauses holds the export
+// up, and hanging up ends it. go test ./transport/http checks both, and the
+// export's failures.
+//
+//	go run ./cmd/ndjson
+func main() {
+	if err := run(); err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	const total = 10_000
+	// pages is how many pages ListAll reads for total users
+	const pages = total / 100
+
+	ctx := context.Background()
+	store := &countingStore{MemoryStore: db.NewMemoryStore()}
+	users := service.NewUserService(store)
+	batch := make([]*service.User, 0, total)
+	for i := range total {
+		id := fmt.Sprintf("user-%06d", i)
+		batch = append(batch, &service.User{ID: id, Email: id + "@example.com", Name: "Ada Lovelace"})
+	}
+	if _, err := users.CreateUsers(ctx, batch); err != nil {
+		return err
+	}
+	srv := httptest.NewServer(httptransport.NewHandler(users, nil))
+	defer srv.Close()
+
+	start, n := time.Now(), 0
+	err := exportUsers(ctx, srv.URL, func(user) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("read %d users from %d pages in %s\n", n, store.pages.Load(), time.Since(start).Round(time.Millisecond))
+
+	// pause after the first user to see how far ahead the server gets, then
+	// hang up
+	store.pages.Store(0)
+	stop := errors.New("stop")
+	err = exportUsers(ctx, srv.URL, func(u user) error {
+		time.Sleep(500 * time.Millisecond)
+		fmt.Printf("paused at %s, the server has read %d of %d pages\n", u.ID, store.pages.Load(), pages)
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/notebook"
+)
+
+const usage = `usage: notebook [-root dir] <command> [arguments]
+
+commands:
+  list [filter]                        list the examples, or those whose name contains filter
+  doc <name>                           print an example's whole doc comment
+  run [-race] [-tags t] <name> [args]  build and run an example, args are passed on to it
+
+A name is an example's path under examples/, or any unique end of it:
+"accept-interfaces-return-structs", "cmd/tls", or just "tls".
+`
+
+// Lists the runnable examples in the repository, each with the first
+// sentence of its doc comment, and builds and runs one by name, passing on
+// its flags and its exit code.
+//
+//	go run ./cmd/notebook list
+//	go run ./cmd/notebook run accept-interfaces-return-structs
+//	go run ./cmd/notebook run -race workerpool
+//	go run ./cmd/notebook run http -addr :9090
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("notebook", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, usage) }
+	root := fs.String("root", "", "repository root, found from the working directory when empty")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if *root == "" {
+		var err error
+		if *root, err = notebook.Root("."); err != nil {
+			fmt.Fprintln(stderr, fmt.Errorf("error: %s", err))
+			return 1
+		}
+	}
+	examples, err := notebook.Discover(*root)
+	if err != nil {
+		fmt.Fprintln(stderr, fmt.Errorf("error: %s", err))
+		return 1
+	}
+
+	cmd, rest := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "list":
+		filter := strings.Join(rest, " ")
+		w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		for _, e := range examples {
+			if strings.Contains(e.Name, filter) {
+				fmt.Fprintf(w, "%s\t%s\n", e.Name, e.Synopsis())
+			}
+		}
+		w.Flush()
+		return 0
+	case "doc":
+		if len(rest) != 1 {
+			fs.Usage()
+			return 2
+		}
+		e, err := notebook.Find(examples, rest[0])
+		if err != nil {
+			fmt.Fprintln(stderr, fmt.Errorf("error: %s", err))
+			return 1
+		}
+		fmt.Fprintf(stdout, "%s\n\n%s", e.Name, e.Doc)
+		return 0
+	case "run":
+		return runExample(examples, rest, stderr)
+	default:
+		fmt.Fprintf(stderr, "notebook: unknown command %q\n", cmd)
+		fs.Usage()
+		return 2
+	}
+}
+
+// runExample builds the example into a temporary directory and runs the
+// binary itself rather than go run, which reports every failure as exit
+// status 1 and does not pass SIGTERM on.
+func runExample(examples []notebook.Example, args []string, stderr io.Writer) int {
+	fs := flag.NewFlagSet("notebook run", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	race := fs.Bool("race", false, "build with the race detector")
+	tags := fs.String("tags", "", "comma separated build tags")
+	// flags stop at the name, everything after it is the example's
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	e, err := notebook.Find(examples, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, fmt.Errorf("error: %s", err))
+		return 1
+	}
+
+	tmp, err := os.MkdirTemp("", "notebook")
+	if err != nil {
+		fmt.Fprintln(stderr, fmt.Errorf("error: %s", err))
+		return 1
+	}
+	defer os.RemoveAll(tmp)
+	bin := filepath.Join(tmp, filepath.Base(e.Dir))
+	if runtime.GOOS == "windows" {
+		bin += ".exe"
+	}
+	build := []string{"build", "-o", bin}
+	if *race {
+		build = append(build, "-race")
+	}
+	if *tags != "" {
+		build = append(build, "-tags", *tags)
+	}
+	compile := exec.Command("go", append(build, ".")...)
+	// the example's own directory, so its go.mod is the one used
+	compile.Dir = e.Dir
+	compile.Stdout, compile.Stderr = stderr, stderr
+	if err := compile.Run(); err != nil {
+		fmt.Fprintln(stderr, fmt.Errorf("error: building %s: %s", e.Name, err))
+		return 1
+	}
+
+	example := exec.Command(bin, fs.Args()[1:]...)
+	// examples open files like users.db relative to where they live
+	example.Dir = e.Dir
+	example.Stdin, example.Stdout, example.Stderr = os.Stdin, os.Stdout, os.Stderr
+	// a terminal's Ctrl-C reaches the example on its own, being in the same
+	// process group, a kill only reaches us: catch both and pass them on
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := example.Start(); err != nil {
+		fmt.Fprintln(stderr, fmt.Errorf("error: %s", err))
+		return 1
+	}
+	done := make(chan error, 1)
+	go func() { done <- example.Wait() }()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		example.Process.Signal(syscall.SIGTERM)
+		err = <-done
+	}
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		if code := exit.ExitCode(); code >= 0 {
+			return code
+		}
+		// killed by a signal
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, fmt.Errorf("error: %s", err))
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/idgen"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/oidc"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/oidc/oidctest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/secret"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/session"
+	sessionredis "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/session/redis"
+)
+
+// Logs in through the authorization code flow against oidctest's fake
+// provider, with a cookie jar standing in for the browser: the login
+// creates the user from the ID token's claims and starts a session, kept
+// server side by the session package, that logging out ends. -v logs why
+// a login failed, -redis keeps the sessions in Redis instead of memory.
+// The refused logins and the session timeouts are covered by go test
+// ./oidc.
+//
+//	go run ./cmd/oidc
+//	go run ./cmd/oidc -v
+//	go run ./cmd/oidc -redis localhost:6379
+func main() {
+	verbose := flag.Bool("v", false, "log why a login failed")
+	redisAddr := flag.String("redis", "", "keep sessions in the Redis at this address")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if *verbose {
+		logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+	}
+	var store session.Store = session.NewMemory()
+	if *redisAddr != "" {
+		client := goredis.NewClient(&goredis.Options{Addr: *redisAddr})
+		defer client.Close()
+		store = sessionredis.New(client)
+	}
+	if err := run(logger, store); err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		os.Exit(1)
+	}
+}
+
+func run(logger *slog.Logger, store session.Store) error {
+	ctx := context.Background()
+	provider := oidctest.NewProvider("users-api", "s3cret")
+	defer provider.Close()
+
+	users := service.NewUserService(db.NewMemoryStore(), service.WithIDGenerator(idgen.UUIDv7{}))
+	key := make([]byte, 32)
+	rand.Read(key)
+	codec, err := session.NewCodec(key)
+	if err != nil {
+		return err
+	}
+	sessions := session.New(store, codec,
+		session.WithIdleTimeout(30*time.Minute),
+		session.WithAbsoluteTimeout(12*time.Hour),
+		session.WithLogger(logger),
+	)
+
+	// the app's URL is the redirect URL, so start it before discovering
+	mux := http.NewServeMux()
+	app := httptest.NewUnstartedServer(sessions.Middleware()(mux))
+	app.StartTLS()
+	defer app.Close()
+	oidcProvider, err := oidc.Discover(ctx, oidc.Config{
+		Issuer:       provider.Issuer(),
+		ClientID:     "users-api",
+		ClientSecret: secret.New("s3cret"),
+		RedirectURL:  app.URL + "/callback",
+		HTTPClient:   provider.Client(),
+	})
+	if err != nil {
+		return err
+	}
+	mux.Handle("/", oidc.NewHandler(oidcProvider, users, sessions, codec, oidc.WithLogger(logger)))
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "home")
+	})
+	mux.HandleFunc("GET /me", func(w http.ResponseWriter, r *http.Request) {
+		s, ok := session.From(r.Context())
+		if !ok {
+			http.Error(w, "not logged in", http.StatusUnauthorized)
+			return
+		}
+		user, err := users.RetrieveUser(r.Context(), s.UserID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id": user.ID, "email": user.Email, "name": user.Name})
+	})
+
+	// a cookie jar stands in for the browser, following the redirects
+	// between the app and the provider
+	jar, _ := cookiejar.New(nil)
+	browser := &http.Client{Transport: provider.Client().Transport, Jar: jar}
+	show := func(method, path string) error {
+		req, _ := http.NewRequestWithContext(ctx, method, app.URL+path, nil)
+		resp, err := browser.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Printf("%s %s -> %s %s\n", method, path, resp.Status, strings.TrimSpace(string(body)))
+		return nil
+	}
+	for _, step := range []struct{ method, path string }{
+		{"GET", "/me"},
+		// the provider logs Ada straight in, the callback creates her
+		{"GET", "/login?next=/me"},
+		{"POST", "/logout"},
+		{"GET", "/me"},
+	} {
+		if err := show(step.method, step.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
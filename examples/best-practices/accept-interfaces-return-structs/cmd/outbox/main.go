@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/sqlite"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/eventbus"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/outbox"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/runner"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// The transactional outbox: CreateUser writes the user row and its
+// user.created event in one sqlite transaction, and a relay goroutine
+// publishes the stored events to the bus afterwards. A create that fails
+// leaves neither the user nor an event behind.
+func main() {
+	ctx := context.Background()
+
+	store, err := sqlite.Open(ctx, ":memory:")
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	defer store.Close()
+
+	bus := eventbus.New()
+	sub := bus.Subscribe(eventbus.AllTopics)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for event := range sub.Events() {
+			fmt.Printf("relayed %s: %+v\n", event.Name(), event)
+		}
+	}()
+
+	relay := outbox.NewRelay(store, bus, nil)
+	relay.Interval = 50 * time.Millisecond
+
+	userService := service.NewUserService(store, service.WithOutbox())
+	group := runner.New()
+	group.Add("relay", relay)
+	group.Add("writes", runner.Func(func(ctx context.Context) error {
+		userService.CreateUser(ctx, &service.User{ID: "1", Email: "ada@example.com"})
+		userService.CreateUser(ctx, &service.User{ID: "2", Email: "grace@example.com"})
+		// rolled back along with its event, the relay never sees it
+		if err := userService.CreateUser(ctx, &service.User{ID: "3", Email: "ada@example.com"}); err != nil {
+			fmt.Println(fmt.Errorf("error: %s", err))
+		}
+		userService.DeleteUser(ctx, "2")
+		// give the relay a few polls, returning then stops the group
+		time.Sleep(200 * time.Millisecond)
+		return nil
+	}))
+	if err := group.Run(ctx); err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+	}
+	bus.Close()
+	wg.Wait()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/pipeline"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// result carries a stage's error downstream as data, channels of plain
+// values keep the stages generic.
+type result struct {
+	id  string
+	err error
+}
+
+// Imports "id,email" records through parse, filter, and a fanned out
+// create stage, reads part of the output, then cancels to show that every
+// goroutine exits. BenchmarkFanOut in package pipeline measures the widths.
+//
+//	go run ./cmd/pipeline -records 500 -width 8
+func main() {
+	records := flag.Int("records", 500, "records to import")
+	width := flag.Int("width", 8, "copies of the create stage")
+	flag.Parse()
+
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	userService := service.NewUserService(slowStore{db.NewMemoryStore()})
+	out := stages(ctx, userService, *records, *width)
+	created, failed := 0, 0
+	for range 10 {
+		if res := <-out; res.err != nil {
+			failed++
+		} else {
+			created++
+		}
+	}
+	fmt.Printf("read %d results: %d created, %d failed\n", created+failed, created, failed)
+	// stop reading and cancel, the blocked senders all see ctx.Done
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	fmt.Printf("goroutines before %d, after cancel %d\n", before, runtime.NumGoroutine())
+}
+
+// stages wires generate -> parse -> filter -> fan out create -> merge.
+func stages(ctx context.Context, userService *service.UserService, records, width int) <-chan result {
+	i := 0
+	lines := pipeline.Generate(ctx, func() (string, bool) {
+		i++
+		// every 40th record has no email and is dropped by the filter
+		if i%40 == 0 {
+			return strconv.Itoa(i) + ",", i <= records
+		}
+		return fmt.Sprintf("%d,user%d@example.com", i, i), i <= records
+	})
+	users := pipeline.Map(ctx, lines, func(_ context.Context, line string) *service.User {
+		id, email, _ := strings.Cut(line, ",")
+		return &service.User{ID: id, Email: strings.ToLower(email)}
+	})
+	valid := pipeline.Filter(ctx, users, func(u *service.User) bool { return u.Email != "" })
+	created := pipeline.FanOut(ctx, valid, width, func(ctx context.Context, u *service.User) result {
+		return result{id: u.ID, err: userService.CreateUser(ctx, u)}
+	})
+	return pipeline.Merge(ctx, created...)
+}
+
+// slowStore makes the create stage the bottleneck, the one worth fanning out.
+type slowStore struct {
+	*db.MemoryStore
+}
+
+func (s slowStore) Insert(ctx context.Context, user *service.User) error {
+	time.Sleep(time.Millisecond)
+	return s.MemoryStore.Insert(ctx, user)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/logging"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/profiling"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/runner"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	httptransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/http"
+)
+
+// Serves the users API next to the pprof endpoints while a load generator
+// hammers it, so there is something worth profiling:
+//
+//	go run ./cmd/pprof -workers 16
+//	go tool pprof -http :8081 'localhost:6060/debug/pprof/profile?seconds=10'
+//	go tool pprof -http :8081 localhost:6060/debug/pprof/heap
+//	curl -o trace.out 'localhost:6060/debug/pprof/trace?seconds=5' && go tool trace trace.out
+//	kill -USR1 <pid>    # writes cpu and heap profiles to -dir
+func main() {
+	addr := flag.String("addr", "localhost:6060", "listen address")
+	workers := flag.Int("workers", 8, "concurrent load generator workers")
+	duration := flag.Duration("duration", 0, "how long to run, until Ctrl-C when 0")
+	dir := flag.String("dir", "profiles", "where SIGUSR1 profiles are written")
+	flag.Parse()
+
+	ctx := context.Background()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+	logger := logging.New(os.Stderr, logging.FromEnv())
+	profiling.OnSignal(ctx, *dir, 10*time.Second, logger)
+
+	userService := service.NewUserService(db.NewMemoryStore())
+	// registered by hand on our own mux, importing net/http/pprof for its
+	// side effect would also expose them on http.DefaultServeMux
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/", httptransport.NewHandler(userService, logger))
+	fmt.Printf("pprof on http://%s/debug/pprof/, pid %d\n", *addr, os.Getpid())
+
+	var requests atomic.Int64
+	group := runner.New(runner.WithLogger(logger))
+	group.Add("http", httptransport.NewServer(*addr, mux, httptransport.WithServerLogger(logger)))
+	group.Add("load", runner.Func(func(ctx context.Context) error {
+		var wg sync.WaitGroup
+		for w := range *workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				load(ctx, "http://"+*addr, w, &requests)
+			}()
+		}
+		wg.Wait()
+		return ctx.Err()
+	}))
+	group.Add("report", runner.Func(func(ctx context.Context) error {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+				fmt.Printf("%d req/s\n", requests.Swap(0))
+			}
+		}
+	}))
+	if err := group.Run(ctx); err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+	}
+	fmt.Printf("done, %d requests\n", requests.Load())
+}
+
+// load creates users and reads them back in a loop, a list every so often
+// keeps the sort in db.MemoryStore.List on the profile.
+func load(ctx context.Context, base string, worker int, requests *atomic.Int64) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	for i := 0; ctx.Err() == nil; i++ {
+		id := strconv.Itoa(worker) + "-" + strconv.Itoa(i)
+		do(ctx, client, http.MethodPost, base+"/users", `{"id":"`+id+`","email":"`+id+`@example.com"}`)
+		do(ctx, client, http.MethodGet, base+"/users/"+id, "")
+		if i%50 == 0 {
+			do(ctx, client, http.MethodGet, base+"/users?limit=100", "")
+		}
+		requests.Add(2)
+	}
+}
+
+func do(ctx context.Context, client *http.Client, method, url, body string) {
+	req, err := http.NewRequestWithContext(ctx, method, url, strings.NewReader(body))
+	if err != nil {
+		return
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/codec"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/redis"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Swaps a cache-style backend in behind the same UserService.
+//
+//	go run ./cmd/redis -addr localhost:6379 -ttl 1m
+//	go run ./cmd/redis -codec msgpack
+func main() {
+	addr := flag.String("addr", "localhost:6379", "redis address")
+	ttl := flag.Duration("ttl", time.Minute, "per-record expiry, 0 disables it")
+	codecName := flag.String("codec", "json", "how records are encoded, json or msgpack")
+	flag.Parse()
+	c, ok := codec.ByName(*codecName)
+	if !ok {
+		fmt.Println(fmt.Errorf("error: unknown codec %q", *codecName))
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+
+	client := goredis.NewClient(&goredis.Options{Addr: *addr})
+	defer client.Close()
+
+	userService := service.NewUserService(redis.New(client, *ttl, redis.WithCodec(c)))
+
+	user := &service.User{ID: "1", Email: "gopher@example.com"}
+	if err := userService.CreateUser(ctx, user); err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+	}
+
+	found, err := userService.RetrieveUser(ctx, user.ID)
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		os.Exit(1)
+	}
+	fmt.Printf("User retrieved: %+v\n", found)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/result"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// nicknames is an optional lookup, not every user has one.
+var nicknames = map[string]string{"1": "Countess"}
+
+// The same flow twice: fetch a user, refuse an unverified address, pick a
+// display name (nickname, else the name, else the email's local part), and
+// render a greeting. Both produce the same output, compare the code.
+//
+//	go run ./cmd/result
+func main() {
+	ctx := context.Background()
+	userService := service.NewUserService(db.NewMemoryStore())
+	userService.CreateUser(ctx, &service.User{ID: "1", Email: "ada@example.com", Name: "Ada"})
+	userService.CreateUser(ctx, &service.User{ID: "2", Email: "grace@example.com", Name: "Grace"})
+	userService.CreateUser(ctx, &service.User{ID: "3", Email: "linus@unverified.test"})
+
+	for _, id := range []string{"1", "2", "3", "missing"} {
+		greeting, err := greetIdiomatic(ctx, userService, id)
+		fmt.Printf("idiomatic %-8s %q %v\n", id, greeting, err)
+		r := greetResult(ctx, userService, id)
+		fmt.Printf("result    %-8s %q %v\n", id, r.UnwrapOr(""), r.Err())
+	}
+
+	// the edges still speak (T, error), errors.Is works through either
+	_, err := greetResult(ctx, userService, "missing").Get()
+	fmt.Println("not found:", errors.Is(err, errs.ErrNotFound))
+}
+
+// greetIdiomatic is ordinary Go. Each step is a statement, the error checks
+// are repetitive but every failure is handled right where it happens, with
+// its own wrapping, and a debugger can stop on any line.
+func greetIdiomatic(ctx context.Context, users *service.UserService, id string) (string, error) {
+	user, err := users.RetrieveUser(ctx, id)
+	if err != nil {
+		return "", errs.Wrap("greet", err)
+	}
+	if err := verified(user); err != nil {
+		return "", errs.Wrap("greet", err)
+	}
+	name, ok := nicknames[user.ID]
+	if !ok {
+		name = user.Name
+	}
+	if name == "" {
+		name, _, _ = strings.Cut(user.Email, "@")
+	}
+	return "Hello, " + name + "!", nil
+}
+
+// greetResult chains the same steps. The happy path reads top to bottom
+// with no if err != nil, but it is all closures, Map and AndThen wrap
+// inside out because methods cannot introduce the new type, and the only
+// error wrapping is one place at the edge since no step knows where it is.
+func greetResult(ctx context.Context, users *service.UserService, id string) result.Result[string] {
+	user := result.AndThen(result.Of(users.RetrieveUser(ctx, id)), func(user *service.User) result.Result[*service.User] {
+		if err := verified(user); err != nil {
+			return result.Err[*service.User](err)
+		}
+		return result.Ok(user)
+	})
+	name := result.Map(user, func(user *service.User) string {
+		nickname, ok := nicknames[user.ID]
+		// UnwrapOr takes its fallback by value, so it is computed even
+		// when unused, fine for a Cut, not for a database call
+		local, _, _ := strings.Cut(user.Email, "@")
+		return result.OptionOf(nickname, ok).
+			UnwrapOr(result.OptionOf(user.Name, user.Name != "").
+				UnwrapOr(local))
+	})
+	greeting := result.Map(name, func(name string) string { return "Hello, " + name + "!" })
+	if err := greeting.Err(); err != nil {
+		return result.Err[string](errs.Wrap("greet", err))
+	}
+	return greeting
+}
+
+func verified(user *service.User) error {
+	if strings.HasSuffix(user.Email, ".test") {
+		return fmt.Errorf("%w: email %s is not verified", errs.ErrInvalidInput, user.Email)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// TestBothWaysAgree runs the idiomatic and the Result flows on the same
+// users: same greeting, same error.
+func TestBothWaysAgree(t *testing.T) {
+	ctx := context.Background()
+	users := service.NewUserService(db.NewMemoryStore())
+	for _, u := range []*service.User{
+		{ID: "1", Email: "ada@example.com", Name: "Ada"},
+		{ID: "2", Email: "grace@example.com", Name: "Grace"},
+		{ID: "3", Email: "linus@example.com"},
+		{ID: "4", Email: "ken@unverified.test"},
+	} {
+		if err := users.CreateUser(ctx, u); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+	for _, tt := range []struct {
+		id   string
+		want string
+		err  error
+	}{
+		{"1", "Hello, Countess!", nil},
+		{"2", "Hello, Grace!", nil},
+		{"3", "Hello, linus!", nil},
+		{"4", "", errs.ErrInvalidInput},
+		{"missing", "", errs.ErrNotFound},
+	} {
+		t.Run(tt.id, func(t *testing.T) {
+			got, err := greetIdiomatic(ctx, users, tt.id)
+			if got != tt.want || !errors.Is(err, tt.err) {
+				t.Errorf("greetIdiomatic = %q, %v, want %q, %v", got, err, tt.want, tt.err)
+			}
+			r, rerr := greetResult(ctx, users, tt.id).Get()
+			if r != got || (rerr == nil) != (err == nil) || (err != nil && rerr.Error() != err.Error()) {
+				t.Errorf("greetResult = %q, %v, want what greetIdiomatic gave: %q, %v", r, rerr, got, err)
+			}
+		})
+	}
+}
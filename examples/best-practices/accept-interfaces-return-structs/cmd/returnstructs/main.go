@@ -0,0 +1,17 @@
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/singlechecker"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/tools/analyzer"
+)
+
+// Reports exported constructors that return interfaces, the
+// tools/analyzer check, on the packages given. singlechecker also speaks
+// go vet's protocol, so the same binary works as a -vettool.
+//
+//	go run ./cmd/returnstructs ./...
+//	go build -o /tmp/returnstructs ./cmd/returnstructs && go vet -vettool=/tmp/returnstructs ./...
+func main() {
+	singlechecker.Main(analyzer.Analyzer)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/logging"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/runner"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/scheduler"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Runs a purge of soft-deleted users on an interval next to a slow report
+// that would overlap itself, a job that panics, and an hourly cron job.
+// The purge only removes users deleted longer than -retention ago, users are
+// deleted while it runs so later purges pick them up. Ctrl-C or -duration
+// shuts the scheduler down.
+//
+//	go run ./cmd/scheduler -duration 3s -retention 1s
+func main() {
+	duration := flag.Duration("duration", 3*time.Second, "how long to run")
+	retention := flag.Duration("retention", time.Second, "how long a deleted user is kept")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
+
+	logger := logging.New(os.Stderr, logging.Config{Level: slog.LevelWarn, Format: "text"})
+	userService := service.NewUserService(db.NewMemoryStore())
+	for i := range 10 {
+		userService.CreateUser(ctx, &service.User{ID: fmt.Sprint(i), Email: fmt.Sprintf("user%d@example.com", i)})
+	}
+
+	s := scheduler.New(scheduler.WithLogger(logger))
+	s.Add("purge-deleted", scheduler.Every(500*time.Millisecond), func(ctx context.Context) error {
+		n, err := purgeDeleted(ctx, userService, *retention)
+		if n > 0 {
+			fmt.Printf("purge-deleted: removed %d users\n", n)
+		}
+		return err
+	})
+	s.Add("report", scheduler.Every(200*time.Millisecond), func(ctx context.Context) error {
+		select {
+		case <-time.After(500 * time.Millisecond):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	var flakyRuns atomic.Int64
+	s.Add("flaky", scheduler.Every(300*time.Millisecond), func(ctx context.Context) error {
+		if flakyRuns.Add(1)%2 == 0 {
+			panic("nil map write")
+		}
+		return nil
+	})
+	hourly := scheduler.MustCron("@hourly")
+	s.Add("stats", hourly, func(ctx context.Context) error { return nil })
+	fmt.Printf("stats: next run at %s\n", hourly.Next(time.Now()).Format(time.Kitchen))
+
+	group := runner.New(runner.WithLogger(logger))
+	group.Add("scheduler", s)
+	// delete a user every 250ms while the scheduler runs
+	group.Add("deleter", runner.Func(func(ctx context.Context) error {
+		for i := 0; i < 10; i++ {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(250 * time.Millisecond):
+				userService.DeleteUser(ctx, fmt.Sprint(i))
+			}
+		}
+		// done deleting, keep the group up for the remaining purges
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	if err := group.Run(ctx); err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+	}
+	for _, st := range s.Stats() {
+		fmt.Printf("%-14s runs=%d skipped=%d failed=%d\n", st.Name, st.Runs, st.Skipped, st.Failed)
+	}
+	page, err := userService.ListUsers(context.Background(), service.PageRequest{Limit: 100}, service.IncludeDeleted())
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	fmt.Printf("%d users left, live or awaiting purge\n", len(page.Items))
+}
+
+// purgeDeleted removes the users soft deleted more than retention ago.
+func purgeDeleted(ctx context.Context, users *service.UserService, retention time.Duration) (int, error) {
+	cutoff := time.Now().Add(-retention)
+	var expired []string
+	req := service.PageRequest{Limit: 100}
+	for {
+		page, err := users.ListUsers(ctx, req, service.IncludeDeleted())
+		if err != nil {
+			return 0, err
+		}
+		for _, user := range page.Items {
+			if user.Deleted() && user.DeletedAt.Before(cutoff) {
+				expired = append(expired, user.ID)
+			}
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		req.Cursor = page.NextCursor
+	}
+	// collect first, purging while paging would shift the cursor under us
+	for i, id := range expired {
+		if err := users.PurgeUser(ctx, id); err != nil {
+			return i, err
+		}
+	}
+	return len(expired), nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/cached"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// countingStore counts Gets that reach the backend and makes each one slow
+// enough that concurrent misses overlap.
+type countingStore struct {
+	*db.MemoryStore
+	gets atomic.Int64
+}
+
+func (s *countingStore) Get(ctx context.Context, id string) (*service.User, error) {
+	s.gets.Add(1)
+	time.Sleep(50 * time.Millisecond)
+	return s.MemoryStore.Get(ctx, id)
+}
+
+// Fires 100 concurrent reads of one cold ID through the cached store, they
+// share a single backend Get.
+//
+//	go run ./cmd/singleflight
+func main() {
+	ctx := context.Background()
+	backend := &countingStore{MemoryStore: db.NewMemoryStore()}
+	userService := service.NewUserService(cached.New(backend))
+	if err := userService.CreateUser(ctx, &service.User{ID: "1", Email: "ada@example.com"}); err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+
+	var wg sync.WaitGroup
+	var ok atomic.Int64
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := userService.RetrieveUser(ctx, "1"); err == nil {
+				ok.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	fmt.Printf("%d reads succeeded, %d backend gets\n", ok.Load(), backend.gets.Load())
+
+	// a caller giving up early does not fail the others sharing the call
+	backend.gets.Store(0)
+	userService.UpdateUser(ctx, &service.User{ID: "1", Email: "ada@lovelace.dev", Version: 1})
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	var shortErr, longErr error
+	wg.Add(2)
+	go func() { defer wg.Done(); _, shortErr = userService.RetrieveUser(short, "1") }()
+	go func() { defer wg.Done(); _, longErr = userService.RetrieveUser(ctx, "1") }()
+	wg.Wait()
+	fmt.Printf("impatient caller: %v, patient caller: %v, backend gets %d\n", shortErr, longErr, backend.gets.Load())
+}
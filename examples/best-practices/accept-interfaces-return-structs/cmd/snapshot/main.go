@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Restores a MemoryStore from a gob snapshot on startup, adds a user, and
+// snapshots it again on the way out, so each run finds every user the runs
+// before it made. Delete the file to start over.
+//
+// go test ./db shows what a snapshot does and does not promise: an
+// interrupted save leaves the last snapshot readable, a corrupt one is
+// refused without touching the store, and whatever was written since the
+// last save is gone after a restart.
+//
+//	go run ./cmd/snapshot -file users.gob
+func main() {
+	path := flag.String("file", "users.gob", "path to the snapshot file")
+	flag.Parse()
+	if err := run(*path); err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		os.Exit(1)
+	}
+}
+
+func run(path string) error {
+	ctx := context.Background()
+
+	store := db.NewMemoryStore()
+	switch err := store.Load(ctx, path); {
+	case errors.Is(err, fs.ErrNotExist):
+		fmt.Printf("no snapshot at %s, starting empty\n", path)
+	case err != nil:
+		return err
+	}
+	existing, err := store.List(ctx, "", 1000)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("restored %d users from %s\n", len(existing), path)
+	id := fmt.Sprintf("run-%d", len(existing)+1)
+	users := service.NewUserService(store)
+	if err := users.CreateUser(ctx, &service.User{ID: id, Email: id + "@example.com"}); err != nil {
+		return err
+	}
+	if err := store.Save(ctx, path); err != nil {
+		return err
+	}
+	fmt.Printf("created %s and saved %d users\n", id, len(existing)+1)
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/sqlite"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Runs the UserService end-to-end against a local sqlite file.
+//
+//	go run ./cmd/sqlite -db users.db
+func main() {
+	path := flag.String("db", "users.db", "path to the sqlite database file")
+	flag.Parse()
+
+	ctx := context.Background()
+
+	store, err := sqlite.Open(ctx, *path)
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	userService := service.NewUserService(store)
+
+	user := &service.User{ID: "1", Email: "gopher@example.com"}
+	if err := userService.CreateUser(ctx, user); err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+	}
+
+	found, err := userService.RetrieveUser(ctx, user.ID)
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		os.Exit(1)
+	}
+	fmt.Printf("User retrieved: %+v\n", found)
+}
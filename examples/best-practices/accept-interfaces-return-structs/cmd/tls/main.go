@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/idgen"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/tlsconfig"
+	httptransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/http"
+)
+
+// Makes a CA and server and client certificates in memory, serves the
+// users API over TLS and over mutual TLS, and calls it with a client that
+// pins the CA, with and without a client certificate. go test ./tlsconfig
+// covers the clients that must be refused.
+//
+// -out writes the certificates as PEM files and -serve keeps the mutual
+// TLS server running afterwards for curl:
+//
+//	go run ./cmd/tls
+//	go run ./cmd/tls -out certs -serve
+//	curl --cacert certs/ca.pem --cert certs/client.pem --key certs/client-key.pem https://localhost:8443/whoami
+func main() {
+	out := flag.String("out", "", "directory to write the PEM files to")
+	serve := flag.Bool("serve", false, "keep serving mutual TLS on -addr afterwards")
+	addr := flag.String("addr", "localhost:8443", "address for -serve")
+	flag.Parse()
+
+	if err := run(*out, *serve, *addr); err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		os.Exit(1)
+	}
+}
+
+func run(out string, serve bool, addr string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ca, err := tlsconfig.NewCA("users-api dev CA")
+	if err != nil {
+		return err
+	}
+	serverCert, err := ca.IssueServer("localhost", "127.0.0.1")
+	if err != nil {
+		return err
+	}
+	clientCert, err := ca.IssueClient("billing-service")
+	if err != nil {
+		return err
+	}
+	if out != "" {
+		if err := writePEM(out, ca, serverCert, clientCert); err != nil {
+			return err
+		}
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /whoami", func(w http.ResponseWriter, r *http.Request) {
+		p, ok := ctxutil.PrincipalFrom(r.Context())
+		if !ok {
+			io.WriteString(w, "anonymous\n")
+			return
+		}
+		fmt.Fprintf(w, "%s\n", p.Subject)
+	})
+	mux.Handle("/", httptransport.NewHandler(service.NewUserService(db.NewMemoryStore(), service.WithIDGenerator(idgen.UUIDv7{})), logger))
+	handler := tlsconfig.ClientCertPrincipal()(mux)
+
+	start := func(addr string, config *tls.Config) (string, error) {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return "", err
+		}
+		srv := httptransport.NewServer("", handler, httptransport.WithTLS(config), httptransport.WithServerLogger(logger))
+		go srv.Serve(ctx, ln)
+		_, port, _ := net.SplitHostPort(ln.Addr().String())
+		return "https://localhost:" + port, nil
+	}
+	tlsURL, err := start("127.0.0.1:0", tlsconfig.Server(serverCert))
+	if err != nil {
+		return err
+	}
+	mtlsURL, err := start("127.0.0.1:0", tlsconfig.Server(serverCert, tlsconfig.RequireClientCert(ca.Pool())))
+	if err != nil {
+		return err
+	}
+
+	get := func(config *tls.Config, url string) (string, error) {
+		resp, err := tlsconfig.HTTPClient(config).Get(url)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("GET %s = %s", url, resp.Status)
+		}
+		return strings.TrimSpace(string(body)), err
+	}
+
+	client := tlsconfig.Client(ca.Pool())
+	resp, err := tlsconfig.HTTPClient(client).Post(tlsURL+"/users", "application/json", strings.NewReader(`{"email":"ada@example.com"}`))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	fmt.Printf("TLS, pinning the CA: POST /users -> %s\n", resp.Status)
+	users, err := get(client, tlsURL+"/users")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("TLS, pinning the CA: GET /users -> %s\n", users)
+
+	if _, err = get(client, mtlsURL+"/whoami"); err == nil {
+		return errors.New("mutual TLS let in a client without a certificate")
+	}
+	fmt.Printf("mutual TLS, no client certificate: refused, %s\n", lastLine(err))
+	who, err := get(tlsconfig.Client(ca.Pool(), tlsconfig.WithClientCert(clientCert)), mtlsURL+"/whoami")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("mutual TLS, with a client certificate: GET /whoami -> %s\n", who)
+
+	if !serve {
+		return nil
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("serving mutual TLS on https://%s, Ctrl-C to stop\n", addr)
+	srv := httptransport.NewServer("", handler, httptransport.WithTLS(tlsconfig.Server(serverCert, tlsconfig.RequireClientCert(ca.Pool()))))
+	return srv.Serve(ctx, ln)
+}
+
+func writePEM(dir string, ca *tlsconfig.CA, server, client tls.Certificate) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	files := map[string][]byte{"ca.pem": ca.CertPEM()}
+	for name, cert := range map[string]tls.Certificate{"server": server, "client": client} {
+		certPEM, keyPEM, err := tlsconfig.EncodePEM(cert)
+		if err != nil {
+			return err
+		}
+		files[name+".pem"] = certPEM
+		files[name+"-key.pem"] = keyPEM
+	}
+	for name, b := range files {
+		// keys are private, the certificates may as well match
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0o600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lastLine keeps the reason from the end of a long url.Error.
+func lastLine(err error) string {
+	msg := err.Error()
+	if i := strings.LastIndex(msg, ": "); i >= 0 && len(msg)-i < 80 {
+		return msg[i+2:]
+	}
+	return msg
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/traced"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/middleware"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/tracing"
+	grpctransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/grpc"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/grpc/userspb"
+	httptransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/http"
+)
+
+// Traces one request end to end: a client span, the HTTP server span the
+// traceparent header links to it, the service method, and the store call,
+// then the same again over gRPC. All of them share one trace ID.
+//
+//	go run ./cmd/tracing
+//	docker run -p 4318:4318 -p 16686:16686 jaegertracing/all-in-one
+//	go run ./cmd/tracing -exporter otlp    # then open localhost:16686
+func main() {
+	exporter := flag.String("exporter", "stdout", "stdout or otlp")
+	flag.Parse()
+
+	ctx := context.Background()
+	shutdown, err := tracing.Setup(ctx, "users-demo", tracing.Exporter(*exporter))
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	// flushes the batcher, without it the last spans never leave the process
+	defer shutdown(ctx)
+
+	tp := otel.GetTracerProvider()
+	store := traced.New(db.NewMemoryStore(), tp, "memory")
+	userService := service.NewUserService(store, service.WithTracerProvider(tp))
+
+	srv := httptest.NewServer(middleware.Chain(middleware.Trace("users-api"))(httptransport.NewHandler(userService, nil)))
+	defer srv.Close()
+
+	// otelhttp.NewTransport writes the traceparent header from ctx
+	client := &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
+	tracer := tp.Tracer("cmd/tracing")
+	ctx, span := tracer.Start(ctx, "demo")
+	fmt.Println("trace:", span.SpanContext().TraceID())
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/users", strings.NewReader(`{"id":"1","email":"ada@example.com"}`))
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	resp.Body.Close()
+	fmt.Println("http create:", resp.Status)
+
+	// the gRPC stats handlers do the same through request metadata
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	grpcSrv := grpc.NewServer(grpc.StatsHandler(otelgrpc.NewServerHandler()))
+	userspb.RegisterUsersServer(grpcSrv, grpctransport.NewServer(userService))
+	go grpcSrv.Serve(ln)
+	defer grpcSrv.GracefulStop()
+	conn, err := grpc.NewClient(ln.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	)
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	defer conn.Close()
+	user, err := userspb.NewUsersClient(conn).GetUser(ctx, &userspb.GetUserRequest{Id: "1"})
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	fmt.Println("grpc get:", user.GetEmail())
+	span.End()
+}
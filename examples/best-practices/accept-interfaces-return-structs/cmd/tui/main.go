@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/app"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/tenanted"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/eventbus"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/health"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/httpclient"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/tui"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/userctl"
+)
+
+// Browses the users of a running users API in the terminal, the list kept
+// current by its event stream: arrow keys to move, enter for a user's
+// details, / to search.
+//
+// With -demo there is no API to start first: it serves one in process
+// with a goroutine creating, renaming, and deleting users every so often,
+// so there is something to watch.
+//
+//	go run ./cmd/tui -demo
+//	go run ./cmd/http & go run ./cmd/tui -endpoint http://localhost:8080 -tenant acme
+func main() {
+	endpoint := flag.String("endpoint", "http://localhost:8080", "base URL of the users API")
+	tenant := flag.String("tenant", "default", "tenant whose users to browse")
+	demo := flag.Bool("demo", false, "serve a users API in process and keep changing its users")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *demo {
+		srv := serveDemo(ctx, *tenant)
+		defer srv.Close()
+		// Close waits for the stream's request, which stop ends
+		defer stop()
+		*endpoint = srv.URL
+	}
+
+	client, err := userctl.NewClient(httpclient.New(), *endpoint, os.Getenv("USERCTL_API_KEY"), userctl.WithTenant(*tenant))
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		os.Exit(1)
+	}
+	// the stream is one request for as long as the program runs, so it
+	// gets a client without httpclient's 10 second timeout
+	stream := tui.NewStream(&http.Client{}, *endpoint, tui.WithTenant(*tenant))
+	p := tea.NewProgram(tui.New(ctx, client, stream), tea.WithAltScreen(), tea.WithContext(ctx))
+	if _, err := p.Run(); err != nil && ctx.Err() == nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		os.Exit(1)
+	}
+}
+
+// serveDemo serves the API's real handler over a memory store and changes
+// tenant's users in the background until ctx is done. It logs nowhere, the
+// terminal belongs to the browser.
+func serveDemo(ctx context.Context, tenant string) *httptest.Server {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	bus := eventbus.New()
+	users := app.NewUserService(tenanted.New(db.NewMemoryStore()), logger, bus)
+	stream := app.NewEventStream(bus, logger)
+	srv := httptest.NewServer(app.NewHandler(users, stream, health.New(), logger))
+
+	first := []string{"Ada", "Grace", "Alan", "Barbara", "Edsger", "Frances", "Donald", "Margaret", "Ken", "Radia"}
+	last := []string{"Lovelace", "Hopper", "Turing", "Liskov", "Dijkstra", "Allen", "Knuth", "Hamilton", "Thompson", "Perlman"}
+	// the changes are made in the tenant the browser reads
+	ctx = ctxutil.WithTenant(ctx, tenant)
+	n := 0
+	create := func() {
+		n++
+		name := first[rand.IntN(len(first))] + " " + last[rand.IntN(len(last))]
+		users.CreateUser(ctx, &service.User{ID: fmt.Sprintf("user-%03d", n), Email: fmt.Sprintf("user%d@example.com", n), Name: name})
+	}
+	for range 30 {
+		create()
+	}
+	go func() {
+		tick := time.NewTicker(1500 * time.Millisecond)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+			}
+			id := fmt.Sprintf("user-%03d", 1+rand.IntN(n))
+			switch rand.IntN(3) {
+			case 0:
+				create()
+			case 1:
+				user, err := users.RetrieveUser(ctx, id)
+				if err != nil {
+					continue
+				}
+				user.Name = first[rand.IntN(len(first))] + " " + last[rand.IntN(len(last))]
+				users.UpdateUser(ctx, user)
+			case 2:
+				users.DeleteUser(ctx, id)
+			}
+		}
+	}()
+	return srv
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/userctl"
+)
+
+// Administers users through the users REST API, see package userctl for
+// the commands, the config, and the exit codes:
+//
+//	go run ./cmd/http &
+//	go run ./cmd/userctl create --email ada@example.com --first-name Ada --last-name Lovelace
+//	go run ./cmd/userctl list -o json
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := userctl.Execute(ctx, userctl.NewCommand())
+	stop()
+	os.Exit(code)
+}
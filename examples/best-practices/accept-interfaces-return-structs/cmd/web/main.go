@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/shutdown"
+	webtransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/web"
+)
+
+// Serves the HTML user list over 45 seeded users. The templates and
+// stylesheet are embedded in the binary, build with -tags dev to read them
+// from transport/web instead and see edits on the next reload. The page's
+// tests are in transport/web.
+//
+//	go run ./cmd/web -addr :8080
+//	go run -tags dev ./cmd/web -addr :8080
+func main() {
+	addr := flag.String("addr", ":8080", "address to serve the page on")
+	flag.Parse()
+
+	ctx := context.Background()
+	users := service.NewUserService(db.NewMemoryStore())
+	users.CreateUser(ctx, &service.User{ID: "user-00", Email: "mallory@example.com", Name: "<script>alert(1)</script>"})
+	for i := 1; i < 45; i++ {
+		users.CreateUser(ctx, &service.User{ID: fmt.Sprintf("user-%02d", i), Email: fmt.Sprintf("user%d@example.com", i), Name: fmt.Sprintf("User %d", i)})
+	}
+	handler, err := webtransport.NewHandler(users, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		os.Exit(1)
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: handler}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			fmt.Println(fmt.Errorf("error: %s", err))
+			cancel()
+		}
+	}()
+	fmt.Printf("serving users on http://localhost%s\n", *addr)
+	cleanup := shutdown.New()
+	cleanup.Add("http", srv.Shutdown)
+	cleanup.Wait(ctx)
+}
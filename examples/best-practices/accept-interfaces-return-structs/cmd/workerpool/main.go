@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/workerpool"
+)
+
+// slowStore adds a network round trip's worth of latency to each insert,
+// which is what makes concurrent imports pay off.
+type slowStore struct {
+	*db.MemoryStore
+}
+
+func (s slowStore) Insert(ctx context.Context, user *service.User) error {
+	select {
+	case <-time.After(2 * time.Millisecond):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return s.MemoryStore.Insert(ctx, user)
+}
+
+// Bulk imports users through UserService.CreateUser on a bounded pool.
+//
+//	go run ./cmd/workerpool -users 2000 -workers 32
+func main() {
+	n := flag.Int("users", 1000, "users to import")
+	workers := flag.Int("workers", 16, "concurrent imports")
+	flag.Parse()
+
+	// every 50th record reuses an email and every 75th has none, the import
+	// keeps going and reports them at the end
+	users := make([]*service.User, *n)
+	for i := range users {
+		id := strconv.Itoa(i)
+		email := "user" + id + "@example.com"
+		if i%50 == 49 {
+			email = "user0@example.com"
+		}
+		if i%75 == 74 {
+			email = ""
+		}
+		users[i] = &service.User{ID: id, Email: email}
+	}
+
+	for _, w := range []int{1, *workers} {
+		userService := service.NewUserService(slowStore{db.NewMemoryStore()})
+		start := time.Now()
+		created, conflicts, invalid := importUsers(context.Background(), userService, users, w)
+		fmt.Printf("%2d workers: %d created, %d conflicts, %d invalid in %s\n", w, created, conflicts, invalid, time.Since(start).Round(time.Millisecond))
+	}
+
+	// canceling part way through still yields one result per user, the
+	// jobs that never started carry the context error
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	userService := service.NewUserService(slowStore{db.NewMemoryStore()})
+	results := workerpool.Map(ctx, *workers, users, func(ctx context.Context, user *service.User) (*service.User, error) {
+		copied := *user
+		return &copied, userService.CreateUser(ctx, &copied)
+	})
+	done, canceled := 0, 0
+	for _, res := range results {
+		if errors.Is(res.Err, context.DeadlineExceeded) {
+			canceled++
+		} else {
+			done++
+		}
+	}
+	fmt.Printf("with a 20ms deadline: %d finished, %d canceled, %d results\n", done, canceled, len(results))
+}
+
+// importUsers streams results as they complete instead of waiting for the
+// whole batch, the way a progress bar would want them.
+func importUsers(ctx context.Context, userService *service.UserService, users []*service.User, workers int) (created, conflicts, invalid int) {
+	pool := workerpool.New(ctx, workers, func(ctx context.Context, user service.User) (service.User, error) {
+		return user, userService.CreateUser(ctx, &user)
+	})
+	go func() {
+		defer pool.Close()
+		for _, user := range users {
+			if err := pool.Submit(ctx, *user); err != nil {
+				return
+			}
+		}
+	}()
+	for res := range pool.Results() {
+		switch {
+		case res.Err == nil:
+			created++
+		case errors.Is(res.Err, errs.ErrConflict):
+			conflicts++
+		case errors.Is(res.Err, errs.ErrInvalidInput):
+			invalid++
+		default:
+			fmt.Printf("user %s: %s\n", res.Job.ID, res.Err)
+		}
+	}
+	return created, conflicts, invalid
+}
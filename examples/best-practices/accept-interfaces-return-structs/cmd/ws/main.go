@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/eventbus"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/shutdown"
+	wstransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/ws"
+)
+
+// A chat room and a live user feed on one hub. The demo dials its own
+// server, pass -serve to keep serving afterwards.
+//
+//	go run ./cmd/ws -addr :8080 -serve
+//	websocat 'ws://localhost:8080/chat?room=lobby'
+//	websocat 'ws://localhost:8080/feed?room=users'
+func main() {
+	addr := flag.String("addr", "127.0.0.1:0", "listen address")
+	serve := flag.Bool("serve", false, "keep serving after the demo")
+	flag.Parse()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hub := wstransport.NewHub()
+	go hub.Run(ctx)
+
+	// the feed room is fed from the event bus, clients only listen
+	bus := eventbus.New()
+	go forward(ctx, hub, bus.Subscribe(eventbus.AllTopics))
+	userService := service.NewUserService(db.NewMemoryStore(), service.WithPublisher(bus))
+
+	mux := http.NewServeMux()
+	mux.Handle("/chat", wstransport.Handler(hub, true, nil))
+	mux.Handle("/feed", wstransport.Handler(hub, false, nil))
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(ln)
+	// the hub and the relay stop with ctx, after the server has stopped
+	// accepting and handing them connections
+	cleanup := shutdown.New()
+	cleanup.Add("hub", func(context.Context) error {
+		cancel()
+		bus.Close()
+		return nil
+	})
+	cleanup.Add("http", srv.Shutdown)
+	base := "ws://" + ln.Addr().String()
+
+	alice := dial(base + "/chat?room=lobby")
+	bob := dial(base + "/chat?room=lobby")
+	feed := dial(base + "/feed?room=users")
+	if alice == nil || bob == nil || feed == nil {
+		return
+	}
+	// registration goes through the hub goroutine, give it a moment
+	time.Sleep(50 * time.Millisecond)
+
+	alice.WriteMessage(websocket.TextMessage, []byte("hi bob"))
+	fmt.Println("bob got:", read(bob))
+	fmt.Println("alice got her own:", read(alice))
+
+	userService.CreateUser(ctx, &service.User{ID: "1", Email: "ada@example.com"})
+	fmt.Println("feed got:", read(feed))
+
+	if *serve {
+		fmt.Printf("serving on %s\n", ln.Addr())
+		cleanup.Wait(ctx)
+		return
+	}
+	cleanup.Shutdown()
+}
+
+func forward(ctx context.Context, hub *wstransport.Hub, sub *eventbus.Subscription) {
+	for event := range sub.Events() {
+		data, err := json.Marshal(map[string]any{"event": event.Name(), "data": event})
+		if err != nil {
+			continue
+		}
+		hub.Broadcast(ctx, wstransport.Message{Room: "users", Data: data})
+	}
+}
+
+func dial(url string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return nil
+	}
+	return conn
+}
+
+func read(conn *websocket.Conn) string {
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
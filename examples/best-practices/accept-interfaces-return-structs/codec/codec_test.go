@@ -0,0 +1,91 @@
+package codec_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/codec"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+var (
+	codecs  = []codec.Codec{codec.JSON{}, codec.MsgPack{}}
+	created = time.Date(2026, 1, 2, 3, 4, 5, 678901234, time.UTC)
+	users   = []struct {
+		name string
+		user service.User
+	}{
+		{"new", service.User{ID: "user-0000001", Email: "ada@example.com", Version: 1, CreatedAt: created, UpdatedAt: created}},
+		{"deleted", service.User{
+			ID: "0198c2a4-5b7e-7d3c-9f1a-2b4c6d8e0f12", Email: "grace.hopper@navy.example.mil", Name: "Grace Brewster Murray Hopper",
+			Version: 42, CreatedAt: created, UpdatedAt: created.Add(time.Hour), DeletedAt: created.Add(48 * time.Hour),
+		}},
+	}
+)
+
+// TestRoundTrip checks every codec gives a user back as it was. Times are
+// compared as instants, a codec need not bring back the location.
+func TestRoundTrip(t *testing.T) {
+	for _, c := range codecs {
+		for _, tt := range users {
+			t.Run(c.Name()+"/"+tt.name, func(t *testing.T) {
+				b, err := c.Marshal(tt.user)
+				if err != nil {
+					t.Fatalf("Marshal: %v", err)
+				}
+				var got service.User
+				if err := c.Unmarshal(b, &got); err != nil {
+					t.Fatalf("Unmarshal: %v", err)
+				}
+				want := tt.user
+				for _, ts := range []struct{ got, want *time.Time }{
+					{&got.CreatedAt, &want.CreatedAt}, {&got.UpdatedAt, &want.UpdatedAt}, {&got.DeletedAt, &want.DeletedAt},
+				} {
+					if !ts.got.Equal(*ts.want) {
+						t.Errorf("time = %s, want %s", ts.got, ts.want)
+					}
+					*ts.got = *ts.want
+				}
+				if got != want {
+					t.Errorf("Unmarshal = %+v, want %+v", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestByName(t *testing.T) {
+	for _, c := range codecs {
+		if got, ok := codec.ByName(c.Name()); !ok || got != c {
+			t.Errorf("ByName(%q) = %v, %t, want %v", c.Name(), got, ok, c)
+		}
+	}
+	if _, ok := codec.ByName("gob"); ok {
+		t.Error("ByName(gob) found a codec")
+	}
+}
+
+func BenchmarkCodecs(b *testing.B) {
+	for _, c := range codecs {
+		for _, tt := range users {
+			data, err := c.Marshal(tt.user)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.Run(c.Name()+"/"+tt.name+"/encode", func(b *testing.B) {
+				b.ReportAllocs()
+				for b.Loop() {
+					c.Marshal(tt.user)
+				}
+				b.ReportMetric(float64(len(data)), "bytes")
+			})
+			b.Run(c.Name()+"/"+tt.name+"/decode", func(b *testing.B) {
+				b.ReportAllocs()
+				for b.Loop() {
+					var u service.User
+					c.Unmarshal(data, &u)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package collections
+
+import "iter"
+
+// Deque is a double ended queue on a growable ring buffer: push and pop at
+// either end are amortized O(1), and At indexes from the front in O(1). Use
+// it as a stack (PushBack, PopBack) or a FIFO queue (PushBack, PopFront).
+// The zero value is an empty deque ready to use.
+type Deque[T any] struct {
+	buf  []T
+	head int // index of the front item
+	n    int
+}
+
+func (d *Deque[T]) Len() int {
+	return d.n
+}
+
+func (d *Deque[T]) PushBack(item T) {
+	d.grow()
+	d.buf[(d.head+d.n)%len(d.buf)] = item
+	d.n++
+}
+
+func (d *Deque[T]) PushFront(item T) {
+	d.grow()
+	d.head = (d.head - 1 + len(d.buf)) % len(d.buf)
+	d.buf[d.head] = item
+	d.n++
+}
+
+// PopFront removes and returns the front item, ok is false when empty.
+func (d *Deque[T]) PopFront() (item T, ok bool) {
+	if d.n == 0 {
+		return item, false
+	}
+	var zero T
+	item, d.buf[d.head] = d.buf[d.head], zero // let the GC have it
+	d.head = (d.head + 1) % len(d.buf)
+	d.n--
+	return item, true
+}
+
+// PopBack removes and returns the back item, ok is false when empty.
+func (d *Deque[T]) PopBack() (item T, ok bool) {
+	if d.n == 0 {
+		return item, false
+	}
+	var zero T
+	i := (d.head + d.n - 1) % len(d.buf)
+	item, d.buf[i] = d.buf[i], zero
+	d.n--
+	return item, true
+}
+
+func (d *Deque[T]) Front() (item T, ok bool) {
+	if d.n == 0 {
+		return item, false
+	}
+	return d.buf[d.head], true
+}
+
+func (d *Deque[T]) Back() (item T, ok bool) {
+	if d.n == 0 {
+		return item, false
+	}
+	return d.buf[(d.head+d.n-1)%len(d.buf)], true
+}
+
+// At returns the i-th item from the front, it panics when i is out of range
+// like a slice index would.
+func (d *Deque[T]) At(i int) T {
+	if i < 0 || i >= d.n {
+		panic("collections: Deque index out of range")
+	}
+	return d.buf[(d.head+i)%len(d.buf)]
+}
+
+// All yields the items front to back.
+func (d *Deque[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		for i := range d.n {
+			if !yield(i, d.At(i)) {
+				return
+			}
+		}
+	}
+}
+
+// Clear empties the deque, keeping its buffer.
+func (d *Deque[T]) Clear() {
+	clear(d.buf)
+	d.head, d.n = 0, 0
+}
+
+// grow doubles the buffer when full, unwrapping the ring so the front is
+// at index 0 again.
+func (d *Deque[T]) grow() {
+	if d.n < len(d.buf) {
+		return
+	}
+	buf := make([]T, max(2*len(d.buf), 8))
+	for i := range d.n {
+		buf[i] = d.buf[(d.head+i)%len(d.buf)]
+	}
+	d.buf, d.head = buf, 0
+}
@@ -0,0 +1,86 @@
+package collections_test
+
+import (
+	"slices"
+	"testing"
+	"testing/quick"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/collections"
+)
+
+// TestDequeMatchesSlice runs random operations on a Deque and on a plain
+// slice doing the same thing the obvious way, they must always agree.
+// Each byte is one operation, push heavy so the ring wraps and grows.
+func TestDequeMatchesSlice(t *testing.T) {
+	check := func(ops []byte) bool {
+		var d collections.Deque[int]
+		var model []int
+		for i, op := range ops {
+			switch op % 6 {
+			case 0, 1:
+				d.PushBack(i)
+				model = append(model, i)
+			case 2:
+				d.PushFront(i)
+				model = slices.Insert(model, 0, i)
+			case 3:
+				got, ok := d.PopFront()
+				if ok != (len(model) > 0) || (ok && got != model[0]) {
+					return false
+				}
+				if ok {
+					model = model[1:]
+				}
+			case 4:
+				got, ok := d.PopBack()
+				if ok != (len(model) > 0) || (ok && got != model[len(model)-1]) {
+					return false
+				}
+				if ok {
+					model = model[:len(model)-1]
+				}
+			case 5:
+				if op%2 == 0 && op > 200 {
+					d.Clear()
+					model = nil
+				}
+			}
+			var all []int
+			for _, v := range d.All() {
+				all = append(all, v)
+			}
+			if d.Len() != len(model) || !slices.Equal(all, model) {
+				return false
+			}
+			if front, ok := d.Front(); ok && (front != model[0] || d.At(0) != front) {
+				return false
+			}
+			if back, ok := d.Back(); ok && (back != model[len(model)-1] || d.At(d.Len()-1) != back) {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(check, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDequeEmpty(t *testing.T) {
+	var d collections.Deque[string]
+	if _, ok := d.PopFront(); ok {
+		t.Error("PopFront on an empty deque = ok")
+	}
+	if _, ok := d.PopBack(); ok {
+		t.Error("PopBack on an empty deque = ok")
+	}
+	if _, ok := d.Front(); ok {
+		t.Error("Front on an empty deque = ok")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("At out of range did not panic")
+		}
+	}()
+	d.At(0)
+}
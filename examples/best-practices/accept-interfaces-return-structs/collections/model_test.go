@@ -0,0 +1,183 @@
+package collections_test
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/collections"
+)
+
+// testCase is a random sequence of operations. quick.Check builds them
+// through Generate, smaller gives every case with one operation dropped,
+// which is all shrinking needs.
+type testCase[C any] interface {
+	quick.Generator
+	smaller() []C
+	String() string
+}
+
+// property checks fn against random cases. testing/quick reports the first
+// failing case as generated, often dozens of operations long, so it is
+// shrunk before reporting: drop operations while the check still fails.
+// The seed is in the report, a failure replays with the same one.
+func property[C testCase[C]](t *testing.T, fn func(C) error) {
+	t.Helper()
+	seed := time.Now().UnixNano()
+	err := quick.Check(func(c C) bool { return fn(c) == nil }, &quick.Config{MaxCount: 2000, Rand: rand.New(rand.NewSource(seed))})
+	if err == nil {
+		return
+	}
+	var failure *quick.CheckError
+	if !errors.As(err, &failure) {
+		t.Fatal(err)
+	}
+	c := failure.In[0].(C)
+	for shrunk := true; shrunk; {
+		shrunk = false
+		for _, smaller := range c.smaller() {
+			if fn(smaller) != nil {
+				c, shrunk = smaller, true
+				break
+			}
+		}
+	}
+	t.Errorf("seed %d, case %d shrunk to\n%s%s", seed, failure.Count, c, fn(c))
+}
+
+// keys is kept small so operations keep running into each other.
+const keys = 6
+
+type mapOp struct {
+	kind  string // set, delete, get, deleteEven
+	key   int
+	value int
+}
+
+func (op mapOp) String() string {
+	switch op.kind {
+	case "set":
+		return fmt.Sprintf("Set(%d, %d)", op.key, op.value)
+	case "delete":
+		return fmt.Sprintf("Delete(%d)", op.key)
+	case "get":
+		return fmt.Sprintf("Get(%d)", op.key)
+	}
+	return "Delete every even key while ranging over All"
+}
+
+type mapCase struct {
+	ops []mapOp
+}
+
+func (mapCase) Generate(r *rand.Rand, size int) reflect.Value {
+	var c mapCase
+	for range r.Intn(size + 1) {
+		op := mapOp{key: r.Intn(keys), value: r.Intn(100)}
+		switch p := r.Intn(20); {
+		case p < 10:
+			op.kind = "set"
+		case p < 14:
+			op.kind = "delete"
+		case p < 19:
+			op.kind = "get"
+		default:
+			op.kind = "deleteEven"
+		}
+		c.ops = append(c.ops, op)
+	}
+	return reflect.ValueOf(c)
+}
+
+func (c mapCase) smaller() []mapCase {
+	cases := make([]mapCase, len(c.ops))
+	for i := range c.ops {
+		cases[i] = mapCase{slices.Delete(slices.Clone(c.ops), i, i+1)}
+	}
+	return cases
+}
+
+func (c mapCase) String() string {
+	var b strings.Builder
+	b.WriteString("    collections.NewOrderedMap[int, int]()\n")
+	for _, op := range c.ops {
+		fmt.Fprintf(&b, "    %s\n", op)
+	}
+	return b.String()
+}
+
+type pair struct{ key, value int }
+
+// checkOrderedMap models the map as a slice in insertion order.
+func checkOrderedMap(c mapCase) error {
+	om := collections.NewOrderedMap[int, int]()
+	var model []pair
+	for i, op := range c.ops {
+		at := slices.IndexFunc(model, func(p pair) bool { return p.key == op.key })
+		var result, want string
+		switch op.kind {
+		case "set":
+			if at >= 0 {
+				model[at].value = op.value
+			} else {
+				model = append(model, pair{op.key, op.value})
+			}
+			result, want = fmt.Sprint(om.Set(op.key, op.value)), fmt.Sprint(at < 0)
+		case "delete":
+			if at >= 0 {
+				model = slices.Delete(model, at, at+1)
+			}
+			result, want = fmt.Sprint(om.Delete(op.key)), fmt.Sprint(at >= 0)
+		case "get":
+			result = fmt.Sprint(om.Get(op.key))
+			want = fmt.Sprint(0, false)
+			if at >= 0 {
+				want = fmt.Sprint(model[at].value, true)
+			}
+		case "deleteEven":
+			for k := range om.All() {
+				if k%2 == 0 {
+					om.Delete(k)
+				}
+			}
+			model = slices.DeleteFunc(model, func(p pair) bool { return p.key%2 == 0 })
+		}
+		if result != want {
+			return fmt.Errorf("op %d, %s = %s, model says %s", i+1, op, result, want)
+		}
+
+		var all, backward []pair
+		for k, v := range om.All() {
+			all = append(all, pair{k, v})
+		}
+		for k, v := range om.Backward() {
+			backward = append(backward, pair{k, v})
+		}
+		slices.Reverse(backward)
+		switch {
+		case om.Len() != len(model):
+			return fmt.Errorf("op %d, %s left Len %d, model says %d", i+1, op, om.Len(), len(model))
+		case !slices.Equal(all, model):
+			return fmt.Errorf("op %d, %s left All %v, model says %v", i+1, op, all, model)
+		case !slices.Equal(backward, model):
+			return fmt.Errorf("op %d, %s left Backward %v reversed, model says %v", i+1, op, backward, model)
+		case len(model) > 0 && (fmt.Sprint(om.Oldest()) != fmt.Sprint(model[0].key, model[0].value, true) ||
+			fmt.Sprint(om.Newest()) != fmt.Sprint(model[len(model)-1].key, model[len(model)-1].value, true)):
+			return fmt.Errorf("op %d, %s left Oldest and Newest out of step with %v", i+1, op, model)
+		}
+	}
+	return nil
+}
+
+// TestOrderedMapMatchesModel checks the map against a slice in insertion
+// order under random sequences of operations, deleting while ranging
+// included.
+func TestOrderedMapMatchesModel(t *testing.T) {
+	property(t, checkOrderedMap)
+}
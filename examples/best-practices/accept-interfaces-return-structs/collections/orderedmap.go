@@ -0,0 +1,139 @@
+package collections
+
+import "iter"
+
+// OrderedMap is a map that remembers insertion order. Setting an existing
+// key updates its value in place, it does not move. Get, Set, and Delete are
+// O(1), a linked list through the entries keeps the order. The zero value is
+// not usable, create one with NewOrderedMap.
+type OrderedMap[K comparable, V any] struct {
+	m map[K]*node[K, V]
+	// root is the sentinel of a circular list, root.next is the oldest
+	root node[K, V]
+}
+
+type node[K comparable, V any] struct {
+	key        K
+	value      V
+	prev, next *node[K, V]
+}
+
+func NewOrderedMap[K comparable, V any]() *OrderedMap[K, V] {
+	om := &OrderedMap[K, V]{m: make(map[K]*node[K, V])}
+	om.root.next = &om.root
+	om.root.prev = &om.root
+	return om
+}
+
+func (om *OrderedMap[K, V]) Get(key K) (V, bool) {
+	if n, ok := om.m[key]; ok {
+		return n.value, true
+	}
+	var zero V
+	return zero, false
+}
+
+func (om *OrderedMap[K, V]) Has(key K) bool {
+	_, ok := om.m[key]
+	return ok
+}
+
+// Set adds key at the end, or updates its value where it stands. It
+// reports whether the key was new.
+func (om *OrderedMap[K, V]) Set(key K, value V) bool {
+	if n, ok := om.m[key]; ok {
+		n.value = value
+		return false
+	}
+	n := &node[K, V]{key: key, value: value, prev: om.root.prev, next: &om.root}
+	om.root.prev.next = n
+	om.root.prev = n
+	om.m[key] = n
+	return true
+}
+
+// Delete removes key, reporting whether it was present.
+func (om *OrderedMap[K, V]) Delete(key K) bool {
+	n, ok := om.m[key]
+	if !ok {
+		return false
+	}
+	n.prev.next = n.next
+	n.next.prev = n.prev
+	n.prev, n.next = nil, nil
+	delete(om.m, key)
+	return true
+}
+
+func (om *OrderedMap[K, V]) Len() int {
+	return len(om.m)
+}
+
+// Oldest returns the first inserted entry still present.
+func (om *OrderedMap[K, V]) Oldest() (K, V, bool) {
+	return om.entry(om.root.next)
+}
+
+// Newest returns the last inserted entry.
+func (om *OrderedMap[K, V]) Newest() (K, V, bool) {
+	return om.entry(om.root.prev)
+}
+
+func (om *OrderedMap[K, V]) entry(n *node[K, V]) (K, V, bool) {
+	if n == &om.root {
+		var k K
+		var v V
+		return k, v, false
+	}
+	return n.key, n.value, true
+}
+
+// All yields the entries oldest first. Deleting the entry being visited is
+// safe, adding entries during iteration may or may not visit them.
+func (om *OrderedMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for n := om.root.next; n != &om.root; {
+			// read next first, the loop body may delete n
+			next := n.next
+			if !yield(n.key, n.value) {
+				return
+			}
+			n = next
+		}
+	}
+}
+
+// Backward yields the entries newest first.
+func (om *OrderedMap[K, V]) Backward() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for n := om.root.prev; n != &om.root; {
+			prev := n.prev
+			if !yield(n.key, n.value) {
+				return
+			}
+			n = prev
+		}
+	}
+}
+
+// Keys yields the keys oldest first.
+func (om *OrderedMap[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for k := range om.All() {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
+// Values yields the values oldest first.
+func (om *OrderedMap[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for _, v := range om.All() {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package collections_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/collections"
+)
+
+func TestOrderedMap(t *testing.T) {
+	om := collections.NewOrderedMap[string, int]()
+	if _, _, ok := om.Oldest(); ok {
+		t.Error("Oldest on an empty map = ok")
+	}
+	for i, k := range []string{"c", "a", "b"} {
+		if !om.Set(k, i) {
+			t.Errorf("Set(%s) of a new key = false", k)
+		}
+	}
+	// updating keeps the key where it is
+	if om.Set("c", 10) {
+		t.Error("Set of an existing key = true")
+	}
+	if got := slices.Collect(om.Keys()); !slices.Equal(got, []string{"c", "a", "b"}) {
+		t.Errorf("Keys = %v, want insertion order c a b", got)
+	}
+	if got := slices.Collect(om.Values()); !slices.Equal(got, []int{10, 1, 2}) {
+		t.Errorf("Values = %v, want 10 1 2", got)
+	}
+	if k, v, _ := om.Oldest(); k != "c" || v != 10 {
+		t.Errorf("Oldest = %s %d, want c 10", k, v)
+	}
+	if k, _, _ := om.Newest(); k != "b" {
+		t.Errorf("Newest = %s, want b", k)
+	}
+
+	// deleting while ranging is safe, a deleted key set again goes last
+	for k := range om.All() {
+		if k == "a" {
+			om.Delete(k)
+		}
+	}
+	if om.Has("a") || om.Delete("a") {
+		t.Error("a still present after Delete")
+	}
+	om.Set("a", 3)
+	var backward []string
+	for k := range om.Backward() {
+		backward = append(backward, k)
+	}
+	if !slices.Equal(backward, []string{"a", "b", "c"}) {
+		t.Errorf("Backward = %v, want a b c", backward)
+	}
+
+	// an early break stops there
+	for k := range om.All() {
+		if k != "c" {
+			t.Errorf("ranging past a break reached %s", k)
+		}
+		break
+	}
+}
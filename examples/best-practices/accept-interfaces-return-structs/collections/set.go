@@ -0,0 +1,113 @@
+// Package collections has the generic containers the standard library
+// leaves out: Set, OrderedMap, and Deque. None of them lock, share one
+// between goroutines behind your own mutex. Iteration uses range-over-func
+// iterators, so they compose with slices.Collect, slices.Sorted, and maps.
+package collections
+
+import (
+	"iter"
+	"maps"
+)
+
+// Set is an unordered set of comparable values. The zero value is not
+// usable, create one with NewSet.
+type Set[T comparable] struct {
+	m map[T]struct{}
+}
+
+// NewSet returns a set holding items.
+func NewSet[T comparable](items ...T) Set[T] {
+	s := Set[T]{m: make(map[T]struct{}, len(items))}
+	for _, item := range items {
+		s.m[item] = struct{}{}
+	}
+	return s
+}
+
+// Add inserts item, reporting whether it was new.
+func (s Set[T]) Add(item T) bool {
+	if _, ok := s.m[item]; ok {
+		return false
+	}
+	s.m[item] = struct{}{}
+	return true
+}
+
+// Remove deletes item, reporting whether it was present.
+func (s Set[T]) Remove(item T) bool {
+	if _, ok := s.m[item]; !ok {
+		return false
+	}
+	delete(s.m, item)
+	return true
+}
+
+func (s Set[T]) Contains(item T) bool {
+	_, ok := s.m[item]
+	return ok
+}
+
+func (s Set[T]) Len() int {
+	return len(s.m)
+}
+
+// All yields the items in no particular order.
+func (s Set[T]) All() iter.Seq[T] {
+	return maps.Keys(s.m)
+}
+
+func (s Set[T]) Clone() Set[T] {
+	return Set[T]{m: maps.Clone(s.m)}
+}
+
+// Union returns the items in s or other.
+func (s Set[T]) Union(other Set[T]) Set[T] {
+	out := s.Clone()
+	for item := range other.m {
+		out.m[item] = struct{}{}
+	}
+	return out
+}
+
+// Intersect returns the items in both s and other.
+func (s Set[T]) Intersect(other Set[T]) Set[T] {
+	small, large := s, other
+	if small.Len() > large.Len() {
+		small, large = large, small
+	}
+	out := NewSet[T]()
+	for item := range small.m {
+		if large.Contains(item) {
+			out.m[item] = struct{}{}
+		}
+	}
+	return out
+}
+
+// Difference returns the items in s but not in other.
+func (s Set[T]) Difference(other Set[T]) Set[T] {
+	out := NewSet[T]()
+	for item := range s.m {
+		if !other.Contains(item) {
+			out.m[item] = struct{}{}
+		}
+	}
+	return out
+}
+
+// SubsetOf reports whether every item in s is also in other.
+func (s Set[T]) SubsetOf(other Set[T]) bool {
+	if s.Len() > other.Len() {
+		return false
+	}
+	for item := range s.m {
+		if !other.Contains(item) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Set[T]) Equal(other Set[T]) bool {
+	return s.Len() == other.Len() && s.SubsetOf(other)
+}
@@ -0,0 +1,82 @@
+package collections_test
+
+import (
+	"slices"
+	"testing"
+	"testing/quick"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/collections"
+)
+
+// small keeps generated items in a narrow range so sets overlap.
+func small(items []uint8) []uint8 {
+	out := make([]uint8, len(items))
+	for i, item := range items {
+		out[i] = item % 16
+	}
+	return out
+}
+
+func sorted(s collections.Set[uint8]) []uint8 {
+	return slices.Sorted(s.All())
+}
+
+// TestSetLaws checks the set algebra identities on random sets.
+func TestSetLaws(t *testing.T) {
+	laws := map[string]func(a, b, c []uint8) bool{
+		"union commutes": func(a, b, _ []uint8) bool {
+			x, y := collections.NewSet(small(a)...), collections.NewSet(small(b)...)
+			return x.Union(y).Equal(y.Union(x))
+		},
+		"intersect commutes": func(a, b, _ []uint8) bool {
+			x, y := collections.NewSet(small(a)...), collections.NewSet(small(b)...)
+			return x.Intersect(y).Equal(y.Intersect(x))
+		},
+		"union distributes over intersect": func(a, b, c []uint8) bool {
+			x, y, z := collections.NewSet(small(a)...), collections.NewSet(small(b)...), collections.NewSet(small(c)...)
+			return x.Union(y.Intersect(z)).Equal(x.Union(y).Intersect(x.Union(z)))
+		},
+		"difference and intersect partition": func(a, b, _ []uint8) bool {
+			x, y := collections.NewSet(small(a)...), collections.NewSet(small(b)...)
+			diff, both := x.Difference(y), x.Intersect(y)
+			return diff.Intersect(both).Len() == 0 && diff.Union(both).Equal(x)
+		},
+		"a set is a subset of any union with it": func(a, b, _ []uint8) bool {
+			x, y := collections.NewSet(small(a)...), collections.NewSet(small(b)...)
+			return x.SubsetOf(x.Union(y)) && x.Intersect(y).SubsetOf(x)
+		},
+		"len counts distinct items": func(a, _, _ []uint8) bool {
+			items := small(a)
+			slices.Sort(items)
+			return collections.NewSet(items...).Len() == len(slices.Compact(items))
+		},
+	}
+	for name, law := range laws {
+		t.Run(name, func(t *testing.T) {
+			if err := quick.Check(law, nil); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestSet(t *testing.T) {
+	s := collections.NewSet[uint8]()
+	if !s.Add(1) || s.Add(1) {
+		t.Error("Add reported the wrong newness")
+	}
+	if !s.Contains(1) || s.Contains(2) {
+		t.Error("Contains is wrong after Add(1)")
+	}
+	clone := s.Clone()
+	clone.Add(2)
+	if s.Contains(2) {
+		t.Error("adding to a Clone changed the original")
+	}
+	if !s.Remove(1) || s.Remove(1) || s.Len() != 0 {
+		t.Error("Remove reported the wrong presence")
+	}
+	if got := sorted(collections.NewSet[uint8](3, 1, 2, 1)); !slices.Equal(got, []uint8{1, 2, 3}) {
+		t.Errorf("All = %v, want 1 2 3", got)
+	}
+}
@@ -0,0 +1,246 @@
+// Package config loads the users API configuration from four sources, each
+// overriding the one before it:
+//
+//  1. defaults, see Default
+//  2. a JSON or YAML file named by -config or USERS_CONFIG
+//  3. USERS_* environment variables
+//  4. command line flags
+//
+// so a file can hold the deployment's settings, the environment can tweak
+// them per instance, and a flag always wins when someone is debugging.
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/logging"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/secret"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/validate"
+)
+
+type Config struct {
+	HTTP    HTTP           `json:"http" yaml:"http"`
+	DB      DB             `json:"db" yaml:"db"`
+	Log     logging.Config `json:"log" yaml:"log"`
+	Tracing Tracing        `json:"tracing" yaml:"tracing"`
+}
+
+type HTTP struct {
+	Addr              string   `json:"addr" yaml:"addr"`
+	ReadHeaderTimeout Duration `json:"read_header_timeout" yaml:"read_header_timeout"`
+	ShutdownTimeout   Duration `json:"shutdown_timeout" yaml:"shutdown_timeout"`
+}
+
+type DB struct {
+	// Driver names the store in db/registry, see Backend for the default.
+	Driver string `json:"driver" yaml:"driver"`
+	// DSN is whatever the driver opens, the sqlite file for sqlite.
+	// It can hold credentials for other drivers, so it is kept redacted.
+	DSN         secret.String `json:"dsn" yaml:"dsn"`
+	PingTimeout Duration      `json:"ping_timeout" yaml:"ping_timeout"`
+	// Plugin is a Go plugin to load before the store is opened, it may
+	// register the driver. Linux only.
+	Plugin string `json:"plugin" yaml:"plugin"`
+}
+
+// Backend is Driver, or when that is empty sqlite given a DSN and the
+// memory store without one, what the DSN alone chose before drivers.
+func (d DB) Backend() string {
+	switch {
+	case d.Driver != "":
+		return d.Driver
+	case !d.DSN.IsZero():
+		return "sqlite"
+	default:
+		return "memory"
+	}
+}
+
+type Tracing struct {
+	// Exporter is "", "stdout" or "otlp", see tracing.Setup.
+	Exporter string `json:"exporter" yaml:"exporter"`
+}
+
+// Duration reads "1m30s" style strings from files, where time.Duration
+// would only accept a count of nanoseconds.
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// Default is the configuration with no file, environment, or flags.
+func Default() Config {
+	return Config{
+		HTTP: HTTP{
+			Addr:              ":8080",
+			ReadHeaderTimeout: Duration{5 * time.Second},
+			ShutdownTimeout:   Duration{10 * time.Second},
+		},
+		DB:  DB{PingTimeout: Duration{time.Second}},
+		Log: logging.Config{Level: slog.LevelInfo, Format: "json"},
+	}
+}
+
+// setting is one configuration value and the names it goes by in the
+// environment and on the command line. Files use the struct tags instead.
+type setting struct {
+	flag, env, usage string
+	set              func(*Config, string) error
+}
+
+var settings = []setting{
+	{"addr", "USERS_HTTP_ADDR", "listen address", str(func(c *Config) *string { return &c.HTTP.Addr })},
+	{"read-header-timeout", "USERS_HTTP_READ_HEADER_TIMEOUT", "how long a client may take to send request headers", text(func(c *Config) encoding.TextUnmarshaler { return &c.HTTP.ReadHeaderTimeout })},
+	{"shutdown-timeout", "USERS_HTTP_SHUTDOWN_TIMEOUT", "how long to wait for in-flight requests", text(func(c *Config) encoding.TextUnmarshaler { return &c.HTTP.ShutdownTimeout })},
+	{"db-driver", "USERS_DB_DRIVER", "store backend by registered name, sqlite with a -db and memory without one when empty", str(func(c *Config) *string { return &c.DB.Driver })},
+	{"db", "USERS_DB_DSN", "what the store backend opens, the sqlite database file", text(func(c *Config) encoding.TextUnmarshaler { return &c.DB.DSN })},
+	{"db-plugin", "USERS_DB_PLUGIN", "Go plugin registering more store backends, Linux only", str(func(c *Config) *string { return &c.DB.Plugin })},
+	{"db-ping-timeout", "USERS_DB_PING_TIMEOUT", "readiness check timeout for the database", text(func(c *Config) encoding.TextUnmarshaler { return &c.DB.PingTimeout })},
+	{"log-level", "USERS_LOG_LEVEL", "debug, info, warn or error", text(func(c *Config) encoding.TextUnmarshaler { return &c.Log.Level })},
+	{"log-format", "USERS_LOG_FORMAT", "json or text", str(func(c *Config) *string { return &c.Log.Format })},
+	{"trace", "USERS_TRACING_EXPORTER", "span exporter, stdout or otlp, tracing is off when empty", str(func(c *Config) *string { return &c.Tracing.Exporter })},
+}
+
+// Load builds the configuration for a program called name from its command
+// line arguments, without the program name, and the process environment.
+// -h prints every flag with its environment variable and returns
+// flag.ErrHelp.
+func Load(name string, args []string) (Config, error) {
+	return load(name, args, os.LookupEnv)
+}
+
+func load(name string, args []string, lookupEnv func(string) (string, bool)) (Config, error) {
+	cfg := Default()
+
+	// flags are parsed first to find -config but applied last
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	path := fs.String("config", "", "JSON or YAML config file (USERS_CONFIG)")
+	flags := make(map[string]string)
+	for _, s := range settings {
+		fs.Func(s.flag, fmt.Sprintf("%s (%s)", s.usage, s.env), func(v string) error {
+			flags[s.flag] = v
+			// a bad value fails here, where the flag package prints usage
+			return s.set(&Config{}, v)
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *path == "" {
+		*path, _ = lookupEnv("USERS_CONFIG")
+	}
+	if *path != "" {
+		if err := cfg.loadFile(*path); err != nil {
+			return cfg, errs.Wrap("config.Load", err)
+		}
+	}
+	for _, s := range settings {
+		if v, ok := lookupEnv(s.env); ok {
+			if err := s.set(&cfg, v); err != nil {
+				return cfg, errs.Wrap("config.Load", fmt.Errorf("%s: %w", s.env, err))
+			}
+		}
+	}
+	for _, s := range settings {
+		if v, ok := flags[s.flag]; ok {
+			s.set(&cfg, v)
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, errs.Wrap("config.Load", err)
+	}
+	return cfg, nil
+}
+
+// loadFile decodes path over c, picking the format by extension. Unknown
+// keys are an error so a typo does not silently fall back to the default.
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		dec := json.NewDecoder(f)
+		dec.DisallowUnknownFields()
+		err = dec.Decode(c)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(f)
+		dec.KnownFields(true)
+		err = dec.Decode(c)
+	default:
+		return fmt.Errorf("%s: unsupported config format %q: %w", path, ext, errs.ErrInvalidInput)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once as a *validate.ValidationError.
+func (c Config) Validate() error {
+	var v validate.Errors
+	v.Required("http.addr", c.HTTP.Addr)
+	positive(&v, "http.read_header_timeout", c.HTTP.ReadHeaderTimeout)
+	positive(&v, "http.shutdown_timeout", c.HTTP.ShutdownTimeout)
+	positive(&v, "db.ping_timeout", c.DB.PingTimeout)
+	if c.Log.Format != "json" && c.Log.Format != "text" {
+		v.Add("log.format", "must be json or text")
+	}
+	switch c.Tracing.Exporter {
+	case "", "stdout", "otlp":
+	default:
+		v.Add("tracing.exporter", "must be stdout or otlp")
+	}
+	return v.Err()
+}
+
+// String lists every setting for the startup log, secrets print redacted.
+func (c Config) String() string {
+	return fmt.Sprintf("http.addr=%s http.read_header_timeout=%s http.shutdown_timeout=%s db.driver=%s db.dsn=%s db.ping_timeout=%s db.plugin=%s log.level=%s log.format=%s tracing.exporter=%s",
+		c.HTTP.Addr, c.HTTP.ReadHeaderTimeout, c.HTTP.ShutdownTimeout, c.DB.Backend(), c.DB.DSN, c.DB.PingTimeout, c.DB.Plugin, c.Log.Level, c.Log.Format, c.Tracing.Exporter)
+}
+
+func positive(v *validate.Errors, field string, d Duration) {
+	if d.Duration <= 0 {
+		v.Add(field, "must be positive")
+	}
+}
+
+func str(field func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}
+}
+
+func text(field func(*Config) encoding.TextUnmarshaler) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		return field(c).UnmarshalText([]byte(v))
+	}
+}
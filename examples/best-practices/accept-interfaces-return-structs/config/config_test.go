@@ -0,0 +1,51 @@
+package config_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/config"
+)
+
+// TestDSNRedacted loads a DSN holding a password from each source and
+// checks every way a program prints its config shows it redacted.
+func TestDSNRedacted(t *testing.T) {
+	const dsn = "postgres://app:hunter2@db/users"
+	for _, tt := range []struct {
+		name string
+		args []string
+		env  string
+	}{
+		{"flag", []string{"-db", dsn}, ""},
+		{"environment", nil, dsn},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("USERS_DB_DSN", tt.env)
+			}
+			cfg, err := config.Load("users", tt.args)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.DB.DSN.Reveal() != dsn {
+				t.Fatalf("DSN = %q, want %q", cfg.DB.DSN.Reveal(), dsn)
+			}
+
+			var buf bytes.Buffer
+			slog.New(slog.NewJSONHandler(&buf, nil)).Info("config", "config", cfg, slog.Any("db", cfg.DB))
+			data, err := json.Marshal(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, out := range []string{cfg.String(), fmt.Sprintf("%v", cfg), fmt.Sprintf("%+v", cfg.DB), fmt.Sprintf("%#v", cfg.DB), buf.String(), string(data)} {
+				if strings.Contains(out, "hunter2") {
+					t.Errorf("%q leaks the DSN", out)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package cqrs_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/cqrs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/events"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// sides is the write side, the UserService on its store, and the read
+// side, a UserQueryService on a projection of the events the writes
+// publish, with the log of those events.
+type sides struct {
+	users      *service.UserService
+	log        *cqrs.Log
+	projection *cqrs.Projection
+	queries    *cqrs.UserQueryService
+}
+
+// newSides wires the two sides through a bus and writes 60 users, 20 at
+// each of three domains.
+func newSides(t *testing.T) *sides {
+	t.Helper()
+	// the log and the projection subscribe to the bus, the log first
+	bus := events.NewBus()
+	s := &sides{log: cqrs.NewLog(), projection: cqrs.NewProjection()}
+	bus.Subscribe(s.log.Append)
+	bus.Subscribe(s.projection.Apply)
+	s.users = service.NewUserService(db.NewMemoryStore(), service.WithPublisher(bus))
+	s.queries = cqrs.NewUserQueryService(s.projection)
+	domains := []string{"example.com", "example.org", "example.net"}
+	for i := range 60 {
+		s.create(t, fmt.Sprintf("user-%03d", i), fmt.Sprintf("user%d@%s", i, domains[i%3]))
+	}
+	return s
+}
+
+func (s *sides) create(t *testing.T, id, email string) {
+	t.Helper()
+	if err := s.users.CreateUser(context.Background(), &service.User{ID: id, Email: email}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+}
+
+// everything lists every view of q, domain by domain, a page at a time.
+func everything(t *testing.T, q *cqrs.UserQueryService) []cqrs.UserView {
+	t.Helper()
+	ctx := context.Background()
+	counts, err := q.CountByDomain(ctx)
+	if err != nil {
+		t.Fatalf("CountByDomain: %v", err)
+	}
+	var all []cqrs.UserView
+	for _, domain := range slices.Sorted(maps.Keys(counts)) {
+		req := service.PageRequest{Limit: 7}
+		for {
+			page, err := q.ListByDomain(ctx, domain, req)
+			if err != nil {
+				t.Fatalf("ListByDomain: %v", err)
+			}
+			all = append(all, page.Items...)
+			if page.NextCursor == "" {
+				break
+			}
+			req.Cursor = page.NextCursor
+		}
+	}
+	return all
+}
+
+// rebuilt is everything in a new projection rebuilt from log.
+func rebuilt(t *testing.T, log *cqrs.Log) []cqrs.UserView {
+	t.Helper()
+	replica := cqrs.NewProjection()
+	if err := replica.Rebuild(context.Background(), log); err != nil {
+		t.Fatalf("Rebuild: %v", err)
+	}
+	return everything(t, cqrs.NewUserQueryService(replica))
+}
+
+// slowSource replays a source with a pause before every event, giving
+// writes the time to land in the middle of a rebuild.
+type slowSource struct {
+	cqrs.Source
+	pause time.Duration
+}
+
+func (s slowSource) Replay(ctx context.Context, handle events.Handler) error {
+	return s.Source.Replay(ctx, func(ctx context.Context, event events.Event) error {
+		time.Sleep(s.pause)
+		return handle(ctx, event)
+	})
+}
+
+// failingSource fails its replay after after events.
+type failingSource struct {
+	cqrs.Source
+	after int
+}
+
+func (s failingSource) Replay(ctx context.Context, handle events.Handler) error {
+	n := 0
+	return s.Source.Replay(ctx, func(ctx context.Context, event events.Event) error {
+		if n++; n > s.after {
+			return errors.New("event log unreachable")
+		}
+		return handle(ctx, event)
+	})
+}
+
+func TestWritesReachReadSide(t *testing.T) {
+	ctx := context.Background()
+	s := newSides(t)
+	counts, err := s.queries.CountByDomain(ctx)
+	if err != nil {
+		t.Fatalf("CountByDomain: %v", err)
+	}
+	if want := map[string]int{"example.com": 20, "example.org": 20, "example.net": 20}; !maps.Equal(counts, want) {
+		t.Errorf("CountByDomain = %v, want %v", counts, want)
+	}
+	view, err := s.queries.FindByEmail(ctx, "user7@example.org")
+	if err != nil {
+		t.Fatalf("FindByEmail: %v", err)
+	}
+	if view.ID != "user-007" || view.Version != 1 {
+		t.Errorf("FindByEmail = %+v, want user-007 at version 1", view)
+	}
+}
+
+// TestEmailChange checks an email change moves the user between the
+// email and domain indexes.
+func TestEmailChange(t *testing.T) {
+	ctx := context.Background()
+	s := newSides(t)
+	user, err := s.users.RetrieveUser(ctx, "user-000")
+	if err != nil {
+		t.Fatalf("RetrieveUser: %v", err)
+	}
+	user.Email = "ada@lovelace.example"
+	if err := s.users.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if _, err := s.queries.FindByEmail(ctx, "user0@example.com"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("FindByEmail old email: err = %v, want ErrNotFound", err)
+	}
+	view, err := s.queries.FindByEmail(ctx, "ada@lovelace.example")
+	if err != nil {
+		t.Fatalf("FindByEmail: %v", err)
+	}
+	if view.Version != 2 || !view.CreatedAt.Equal(user.CreatedAt) {
+		t.Errorf("FindByEmail = %+v, want version 2 keeping the creation time", view)
+	}
+	page, err := s.queries.ListByDomain(ctx, "LOVELACE.example", service.PageRequest{})
+	if err != nil {
+		t.Fatalf("ListByDomain: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].ID != "user-000" {
+		t.Errorf("ListByDomain = %v, want user-000", page.Items)
+	}
+}
+
+func TestDeletedLeavesReadSide(t *testing.T) {
+	ctx := context.Background()
+	s := newSides(t)
+	if err := s.users.DeleteUser(ctx, "user-001"); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if _, err := s.queries.GetUser(ctx, "user-001"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("GetUser: err = %v, want ErrNotFound", err)
+	}
+	if _, err := s.queries.FindByEmail(ctx, "user1@example.org"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("FindByEmail: err = %v, want ErrNotFound", err)
+	}
+	// the write side keeps it, soft deleted
+	if _, err := s.users.RetrieveUser(ctx, "user-001", service.IncludeDeleted()); err != nil {
+		t.Errorf("RetrieveUser IncludeDeleted: %v", err)
+	}
+}
+
+// TestReplayIdempotent applies every event a second time to the live
+// projection.
+func TestReplayIdempotent(t *testing.T) {
+	s := newSides(t)
+	before := everything(t, s.queries)
+	if err := s.log.Replay(context.Background(), s.projection.Apply); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if after := everything(t, s.queries); !slices.Equal(before, after) {
+		t.Error("replaying the log into the live projection changed it")
+	}
+}
+
+func TestRebuildMatchesLive(t *testing.T) {
+	s := newSides(t)
+	if err := s.users.DeleteUser(context.Background(), "user-002"); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if got, want := rebuilt(t, s.log), everything(t, s.queries); !slices.Equal(got, want) {
+		t.Errorf("rebuilt %d views, the live projection has %d, or they differ", len(got), len(want))
+	}
+}
+
+// TestRebuildDuringWrites rebuilds the live projection slowly while users
+// are written: none of them is lost, and the result is what a quiet
+// rebuild gives.
+func TestRebuildDuringWrites(t *testing.T) {
+	s := newSides(t)
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for i := range 100 {
+			user := &service.User{ID: fmt.Sprintf("during-%03d", i), Email: fmt.Sprintf("during%d@example.io", i)}
+			if err := s.users.CreateUser(context.Background(), user); err != nil {
+				t.Errorf("CreateUser: %v", err)
+				return
+			}
+			time.Sleep(100 * time.Microsecond)
+		}
+	})
+	if err := s.projection.Rebuild(context.Background(), slowSource{s.log, 200 * time.Microsecond}); err != nil {
+		t.Fatalf("Rebuild: %v", err)
+	}
+	wg.Wait()
+	counts, err := s.queries.CountByDomain(context.Background())
+	if err != nil {
+		t.Fatalf("CountByDomain: %v", err)
+	}
+	if counts["example.io"] != 100 {
+		t.Errorf("%d of the 100 users written during the rebuild", counts["example.io"])
+	}
+	if got, want := everything(t, s.queries), rebuilt(t, s.log); !slices.Equal(got, want) {
+		t.Error("the projection differs from a quiet rebuild")
+	}
+}
+
+func TestFailedRebuildKeepsViews(t *testing.T) {
+	s := newSides(t)
+	before := everything(t, s.queries)
+	if err := s.projection.Rebuild(context.Background(), failingSource{s.log, 10}); err == nil {
+		t.Fatal("Rebuild succeeded, want the source's error")
+	}
+	if after := everything(t, s.queries); !slices.Equal(before, after) {
+		t.Errorf("%d views before the failed rebuild, %d after", len(before), len(after))
+	}
+}
@@ -0,0 +1,80 @@
+// Package cqrs splits reading users from writing them. Writes go through
+// service.UserService as always, which publishes an event for every change.
+// Reads go to a UserQueryService over a Projection: views of the users
+// built from those events alone, indexed for the queries the write side
+// cannot answer cheaply, such as every user of an email domain.
+//
+// The pieces are the existing interfaces, composed:
+//
+//	bus := events.NewBus()
+//	log := cqrs.NewLog()
+//	projection := cqrs.NewProjection()
+//	bus.Subscribe(log.Append)
+//	bus.Subscribe(projection.Apply)
+//	users := service.NewUserService(store, service.WithPublisher(bus))
+//	queries := cqrs.NewUserQueryService(projection)
+//
+// The Log keeps every event in order, so a projection can be rebuilt from
+// scratch, after a bug fix or to add an index, while it keeps serving and
+// applying new events, see Projection.Rebuild. The read side is only as
+// current as the events it has applied: with the synchronous events.Bus it
+// is current once the write returns, behind the outbox or an asynchronous
+// bus it lags behind.
+package cqrs
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/events"
+)
+
+// Source replays events, oldest first, into handle. It stops at the first
+// error handle returns.
+type Source interface {
+	Replay(ctx context.Context, handle events.Handler) error
+}
+
+// Log is an append-only, in-memory record of events, a Source. Subscribe
+// Append to the bus ahead of any projection, so an event is logged before a
+// projection can have seen it.
+type Log struct {
+	mu     sync.RWMutex
+	events []events.Event
+}
+
+func NewLog() *Log {
+	return &Log{}
+}
+
+// Append records event, it is an events.Handler.
+func (l *Log) Append(_ context.Context, event events.Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+	return nil
+}
+
+// Replay hands handle every event logged so far. Events appended while it
+// runs are not included, new ones are the live subscribers' to handle.
+func (l *Log) Replay(ctx context.Context, handle events.Handler) error {
+	l.mu.RLock()
+	logged := l.events[:len(l.events):len(l.events)]
+	l.mu.RUnlock()
+	for _, event := range logged {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := handle(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Len reports how many events were logged.
+func (l *Log) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.events)
+}
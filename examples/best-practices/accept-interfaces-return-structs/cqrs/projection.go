@@ -0,0 +1,181 @@
+package cqrs
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/events"
+)
+
+// UserView is a user as the read side knows them, what the events carry.
+type UserView struct {
+	ID        string
+	Email     string
+	Version   int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Domain is the lowercased part of the email after the last @.
+func (v UserView) Domain() string {
+	return domainOf(v.Email)
+}
+
+func domainOf(email string) string {
+	return strings.ToLower(email[strings.LastIndex(email, "@")+1:])
+}
+
+// views is one generation of the projection's state.
+type views struct {
+	users   map[string]UserView
+	byEmail map[string]string
+	// byDomain holds each domain's user IDs, sorted
+	byDomain map[string][]string
+	// deleted holds the version each deleted user had, events at or below
+	// it are stale
+	deleted map[string]int64
+	applied int
+}
+
+func newViews() *views {
+	return &views{
+		users:    make(map[string]UserView),
+		byEmail:  make(map[string]string),
+		byDomain: make(map[string][]string),
+		deleted:  make(map[string]int64),
+	}
+}
+
+// apply updates the views from event. An event no newer than what is held
+// is a duplicate or stale and changes nothing, so replaying an event twice,
+// or a rebuild overlapping live events, is harmless.
+func (s *views) apply(event events.Event) {
+	switch e := event.(type) {
+	case events.UserCreated:
+		s.upsert(UserView{ID: e.UserID, Email: e.Email, Version: e.Version, CreatedAt: e.At, UpdatedAt: e.At})
+	case events.UserUpdated:
+		s.upsert(UserView{ID: e.UserID, Email: e.Email, Version: e.Version, UpdatedAt: e.At})
+	case events.UserDeleted:
+		if view, ok := s.users[e.UserID]; ok {
+			s.deleted[e.UserID] = view.Version
+			s.unindex(view)
+			delete(s.users, e.UserID)
+			s.applied++
+		}
+	}
+}
+
+func (s *views) upsert(view UserView) {
+	if v, ok := s.deleted[view.ID]; ok && view.Version <= v {
+		return
+	}
+	cur, ok := s.users[view.ID]
+	if ok && view.Version <= cur.Version {
+		return
+	}
+	if ok {
+		// an update's event carries no creation time, keep the one known
+		view.CreatedAt = cur.CreatedAt
+		s.unindex(cur)
+	}
+	delete(s.deleted, view.ID)
+	s.users[view.ID] = view
+	s.byEmail[view.Email] = view.ID
+	ids := s.byDomain[view.Domain()]
+	i, _ := slices.BinarySearch(ids, view.ID)
+	s.byDomain[view.Domain()] = slices.Insert(ids, i, view.ID)
+	s.applied++
+}
+
+func (s *views) unindex(view UserView) {
+	if s.byEmail[view.Email] == view.ID {
+		delete(s.byEmail, view.Email)
+	}
+	domain := view.Domain()
+	ids := s.byDomain[domain]
+	if i, found := slices.BinarySearch(ids, view.ID); found {
+		ids = slices.Delete(ids, i, i+1)
+	}
+	if len(ids) == 0 {
+		delete(s.byDomain, domain)
+	} else {
+		s.byDomain[domain] = ids
+	}
+}
+
+// Projection keeps UserViews up to date from user events. Apply is its
+// events.Handler, subscribe it to the bus the UserService publishes to.
+type Projection struct {
+	mu    sync.RWMutex
+	views *views
+	// pending collects the events applied while a rebuild runs, nil
+	// otherwise
+	pending []events.Event
+
+	// rebuilding allows one Rebuild at a time
+	rebuilding sync.Mutex
+}
+
+func NewProjection() *Projection {
+	return &Projection{views: newViews()}
+}
+
+// Apply updates the views from event. Events it has no use for are
+// ignored, not an error, the publisher would otherwise fail the write.
+func (p *Projection) Apply(_ context.Context, event events.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.views.apply(event)
+	if p.pending != nil {
+		p.pending = append(p.pending, event)
+	}
+	return nil
+}
+
+// Rebuild replaces the views with ones built from scratch by replaying
+// source. The projection keeps serving the old views, and applying new
+// events to them, until the replay is done. The events applied meanwhile
+// are then applied to the new views too, which their versions make safe
+// whether or not the replay already included them, and the new views are
+// swapped in.
+//
+// If the replay fails the old views are kept and the error is returned.
+func (p *Projection) Rebuild(ctx context.Context, source Source) error {
+	p.rebuilding.Lock()
+	defer p.rebuilding.Unlock()
+
+	p.mu.Lock()
+	p.pending = []events.Event{}
+	p.mu.Unlock()
+
+	fresh := newViews()
+	err := source.Replay(ctx, func(_ context.Context, event events.Event) error {
+		fresh.apply(event)
+		return nil
+	})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pending := p.pending
+	p.pending = nil
+	if err != nil {
+		return errs.Wrap("cqrs.Projection.Rebuild", err)
+	}
+	for _, event := range pending {
+		fresh.apply(event)
+	}
+	p.views = fresh
+	return nil
+}
+
+// Applied counts the events that changed the views since they were last
+// built, duplicates and stale events excluded.
+func (p *Projection) Applied() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.views.applied
+}
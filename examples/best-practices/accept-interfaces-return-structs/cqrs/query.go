@@ -0,0 +1,107 @@
+package cqrs
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/cursor"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// UserQueryService answers reads from a Projection, never touching the
+// write side's store. Its errors are the errs sentinels, as the
+// UserService's are.
+type UserQueryService struct {
+	projection *Projection
+}
+
+func NewUserQueryService(projection *Projection) *UserQueryService {
+	return &UserQueryService{projection: projection}
+}
+
+func (q *UserQueryService) GetUser(ctx context.Context, id string) (UserView, error) {
+	if err := ctx.Err(); err != nil {
+		return UserView{}, errs.Wrap("cqrs.GetUser", err)
+	}
+	p := q.projection
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	view, ok := p.views.users[id]
+	if !ok {
+		return UserView{}, errs.Wrap("cqrs.GetUser", errs.ErrNotFound)
+	}
+	return view, nil
+}
+
+// FindByEmail returns the user with exactly email.
+func (q *UserQueryService) FindByEmail(ctx context.Context, email string) (UserView, error) {
+	if err := ctx.Err(); err != nil {
+		return UserView{}, errs.Wrap("cqrs.FindByEmail", err)
+	}
+	p := q.projection
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	id, ok := p.views.byEmail[email]
+	if !ok {
+		return UserView{}, errs.Wrap("cqrs.FindByEmail", errs.ErrNotFound)
+	}
+	return p.views.users[id], nil
+}
+
+// ListByDomain pages through the users whose email is at domain, in ID
+// order, with the same cursors and limits as service.ListUsers. The write
+// side would scan every user for it, the projection keeps an index.
+func (q *UserQueryService) ListByDomain(ctx context.Context, domain string, req service.PageRequest) (service.Page[UserView], error) {
+	if err := ctx.Err(); err != nil {
+		return service.Page[UserView]{}, errs.Wrap("cqrs.ListByDomain", err)
+	}
+	after, err := cursor.Decode(req.Cursor)
+	if err != nil {
+		return service.Page[UserView]{}, errs.Wrap("cqrs.ListByDomain", err)
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	limit = min(limit, maxPageSize)
+
+	p := q.projection
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	ids := p.views.byDomain[strings.ToLower(domain)]
+	i, found := slices.BinarySearch(ids, after)
+	if found {
+		i++
+	}
+	ids = ids[i:]
+	page := service.Page[UserView]{Items: make([]UserView, 0, min(len(ids), limit))}
+	for _, id := range ids[:min(len(ids), limit)] {
+		page.Items = append(page.Items, p.views.users[id])
+	}
+	if len(ids) > limit {
+		page.NextCursor = cursor.Encode(ids[limit-1])
+	}
+	return page, nil
+}
+
+// CountByDomain reports how many users each email domain has.
+func (q *UserQueryService) CountByDomain(ctx context.Context) (map[string]int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Wrap("cqrs.CountByDomain", err)
+	}
+	p := q.projection
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	counts := make(map[string]int, len(p.views.byDomain))
+	for domain, ids := range p.views.byDomain {
+		counts[domain] = len(ids)
+	}
+	return counts, nil
+}
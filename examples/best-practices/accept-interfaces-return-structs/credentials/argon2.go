@@ -0,0 +1,114 @@
+package credentials
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// Argon2id hashes with argon2id into the PHC string format,
+//
+//	$argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>
+//
+// Zero fields take the defaults OWASP recommends as a minimum: 19 MiB of
+// memory, 2 passes, 1 thread, a 16 byte salt and a 32 byte key.
+type Argon2id struct {
+	Memory  uint32 // KiB
+	Time    uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+const argon2Prefix = "$argon2id$"
+
+func (a Argon2id) withDefaults() Argon2id {
+	if a.Memory == 0 {
+		a.Memory = 19 * 1024
+	}
+	if a.Time == 0 {
+		a.Time = 2
+	}
+	if a.Threads == 0 {
+		a.Threads = 1
+	}
+	if a.SaltLen == 0 {
+		a.SaltLen = 16
+	}
+	if a.KeyLen == 0 {
+		a.KeyLen = 32
+	}
+	return a
+}
+
+func (a Argon2id) Hash(password string) (string, error) {
+	a = a.withDefaults()
+	salt := make([]byte, a.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", errs.Wrap("credentials.Argon2id.Hash", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, a.Time, a.Memory, a.Threads, a.KeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2Prefix, argon2.Version, a.Memory, a.Time, a.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (a Argon2id) Verify(hash, password string) error {
+	if !strings.HasPrefix(hash, argon2Prefix) {
+		return ErrUnknownHash
+	}
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return errs.Wrap("credentials.Argon2id.Verify", err)
+	}
+	// the hash's own parameters, not a's, so older hashes still verify
+	got := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(got, key) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+func (a Argon2id) Current(hash string) bool {
+	if !strings.HasPrefix(hash, argon2Prefix) {
+		return false
+	}
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return false
+	}
+	want := a.withDefaults()
+	return params.Memory == want.Memory && params.Time == want.Time && params.Threads == want.Threads &&
+		uint32(len(salt)) == want.SaltLen && uint32(len(key)) == want.KeyLen
+}
+
+// parseArgon2id splits a PHC string into its parameters, salt, and key.
+func parseArgon2id(hash string) (params Argon2id, salt, key []byte, err error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, fmt.Errorf("%w: want 6 fields, got %d", ErrUnknownHash, len(parts))
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: version: %s", ErrUnknownHash, err)
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("%w: argon2 version %d", ErrUnknownHash, version)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: parameters: %s", ErrUnknownHash, err)
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: salt: %s", ErrUnknownHash, err)
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return params, nil, nil, fmt.Errorf("%w: key: %v", ErrUnknownHash, err)
+	}
+	return params, salt, key, nil
+}
@@ -0,0 +1,56 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// Bcrypt hashes with bcrypt at Cost, the zero value uses bcrypt.DefaultCost.
+// bcrypt reads at most 72 bytes of a password, longer ones are rejected
+// rather than silently truncated.
+type Bcrypt struct {
+	Cost int
+}
+
+func (b Bcrypt) cost() int {
+	if b.Cost == 0 {
+		return bcrypt.DefaultCost
+	}
+	return b.Cost
+}
+
+func (b Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.cost())
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		err = fmt.Errorf("%w: password longer than 72 bytes", errs.ErrInvalidInput)
+	}
+	if err != nil {
+		return "", errs.Wrap("credentials.Bcrypt.Hash", err)
+	}
+	return string(hash), nil
+}
+
+func (b Bcrypt) Verify(hash, password string) error {
+	if !isBcrypt(hash) {
+		return ErrUnknownHash
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrMismatch
+	}
+	return errs.Wrap("credentials.Bcrypt.Verify", err)
+}
+
+func (b Bcrypt) Current(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return isBcrypt(hash) && err == nil && cost == b.cost()
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
@@ -0,0 +1,75 @@
+// Package credentials hashes and checks passwords. A Hasher turns a
+// password into a self-describing string that records the algorithm and
+// its parameters, so a hash made under old settings still verifies after
+// the settings change:
+//
+//	hasher := credentials.Chain{credentials.Argon2id{}, credentials.Bcrypt{}}
+//
+// hashes new passwords with argon2id and still accepts bcrypt hashes. A
+// hash that verifies but is not Current to the first hasher is the cue to
+// hash the password again while the caller still has it, at login.
+//
+// Comparisons take the same time however much of a hash matches, the
+// bcrypt package does this itself and Argon2id uses crypto/subtle.
+package credentials
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrMismatch means the password does not match the hash.
+	ErrMismatch = errors.New("password does not match")
+
+	// ErrUnknownHash means the hash is not in a format the Hasher makes,
+	// Chain moves on to the next one.
+	ErrUnknownHash = errors.New("unknown hash format")
+)
+
+// Hasher hashes passwords with one algorithm and set of parameters.
+type Hasher interface {
+	Hash(password string) (string, error)
+	// Verify returns nil when password matches hash, ErrMismatch when it
+	// does not, and ErrUnknownHash for a hash it did not make.
+	Verify(hash, password string) error
+	// Current reports whether hash was made with this algorithm and these
+	// parameters, a false means it is due to be hashed again.
+	Current(hash string) bool
+}
+
+// Store keeps password hashes by user ID, apart from the user record so a
+// hash never travels with a user through hooks, events, or responses.
+type Store interface {
+	SetHash(ctx context.Context, userID, hash string) error
+	// Hash fails with errs.ErrNotFound when the user has no password.
+	Hash(ctx context.Context, userID string) (string, error)
+	Delete(ctx context.Context, userID string) error
+}
+
+// Chain hashes with its first Hasher and verifies with whichever one made
+// the hash, list the preferred algorithm first and the ones being phased
+// out after it.
+type Chain []Hasher
+
+var errEmptyChain = errors.New("credentials: empty Chain")
+
+func (c Chain) Hash(password string) (string, error) {
+	if len(c) == 0 {
+		return "", errEmptyChain
+	}
+	return c[0].Hash(password)
+}
+
+func (c Chain) Verify(hash, password string) error {
+	for _, h := range c {
+		if err := h.Verify(hash, password); !errors.Is(err, ErrUnknownHash) {
+			return err
+		}
+	}
+	return ErrUnknownHash
+}
+
+func (c Chain) Current(hash string) bool {
+	return len(c) > 0 && c[0].Current(hash)
+}
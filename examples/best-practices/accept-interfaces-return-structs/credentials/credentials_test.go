@@ -0,0 +1,124 @@
+package credentials_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/credentials"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// a low bcrypt cost and small argon2id keep the tests quick
+var (
+	fastBcrypt = credentials.Bcrypt{Cost: 4}
+	fastArgon  = credentials.Argon2id{Memory: 1024, Time: 1}
+)
+
+func TestHashers(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		hasher credentials.Hasher
+		prefix string
+	}{
+		{"argon2id", fastArgon, "$argon2id$v=19$m=1024,t=1,p=1$"},
+		{"bcrypt", fastBcrypt, "$2a$04$"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := tt.hasher.Hash("correct horse")
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+			if !strings.HasPrefix(hash, tt.prefix) {
+				t.Errorf("Hash = %s, want the prefix %s", hash, tt.prefix)
+			}
+			if err := tt.hasher.Verify(hash, "correct horse"); err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+			if err := tt.hasher.Verify(hash, "wrong horse"); !errors.Is(err, credentials.ErrMismatch) {
+				t.Errorf("Verify a wrong password: err = %v, want ErrMismatch", err)
+			}
+			if !tt.hasher.Current(hash) {
+				t.Error("Current = false for a hash just made")
+			}
+			// the salt is new every time
+			if again, _ := tt.hasher.Hash("correct horse"); again == hash {
+				t.Error("two hashes of one password are equal")
+			}
+		})
+	}
+}
+
+func TestCurrent(t *testing.T) {
+	weak, err := fastArgon.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	bcrypt, err := fastBcrypt.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	for _, tt := range []struct {
+		name   string
+		hasher credentials.Hasher
+		hash   string
+		want   bool
+	}{
+		{"argon2id, other parameters", credentials.Argon2id{}, weak, false},
+		{"argon2id, a bcrypt hash", fastArgon, bcrypt, false},
+		{"bcrypt, another cost", credentials.Bcrypt{Cost: 5}, bcrypt, false},
+		{"bcrypt, an argon2id hash", fastBcrypt, weak, false},
+		{"chain, its first hasher's", credentials.Chain{fastArgon, fastBcrypt}, weak, true},
+		{"chain, a later hasher's", credentials.Chain{fastArgon, fastBcrypt}, bcrypt, false},
+		{"empty chain", credentials.Chain{}, weak, false},
+	} {
+		if got := tt.hasher.Current(tt.hash); got != tt.want {
+			t.Errorf("%s: Current = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestChain checks a Chain hashes with its first hasher and verifies with
+// whichever made the hash.
+func TestChain(t *testing.T) {
+	chain := credentials.Chain{fastArgon, fastBcrypt}
+	hash, err := chain.Hash("correct horse")
+	if err != nil || !strings.HasPrefix(hash, "$argon2id$") {
+		t.Fatalf("Hash = %s, %v, want argon2id", hash, err)
+	}
+	legacy, _ := fastBcrypt.Hash("correct horse")
+	for _, h := range []string{hash, legacy} {
+		if err := chain.Verify(h, "correct horse"); err != nil {
+			t.Errorf("Verify(%.10s...): %v", h, err)
+		}
+		if err := chain.Verify(h, "wrong horse"); !errors.Is(err, credentials.ErrMismatch) {
+			t.Errorf("Verify(%.10s...) a wrong password: err = %v, want ErrMismatch", h, err)
+		}
+	}
+	if err := chain.Verify("plaintext", "plaintext"); !errors.Is(err, credentials.ErrUnknownHash) {
+		t.Errorf("Verify an unknown hash: err = %v, want ErrUnknownHash", err)
+	}
+	if _, err := (credentials.Chain{}).Hash("correct horse"); err == nil {
+		t.Error("an empty Chain hashed")
+	}
+}
+
+func TestMalformedHashes(t *testing.T) {
+	for _, hash := range []string{
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA",
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$!!!$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA$",
+	} {
+		if err := fastArgon.Verify(hash, "correct horse"); !errors.Is(err, credentials.ErrUnknownHash) {
+			t.Errorf("Verify(%s): err = %v, want ErrUnknownHash", hash, err)
+		}
+	}
+}
+
+func TestBcryptTooLong(t *testing.T) {
+	if _, err := fastBcrypt.Hash(strings.Repeat("x", 73)); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Hash of 73 bytes: err = %v, want ErrInvalidInput", err)
+	}
+}
@@ -0,0 +1,43 @@
+package credentials
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// Memory is a Store for tests and demos, the hashes are lost on restart.
+type Memory struct {
+	mu     sync.Mutex
+	hashes map[string]string
+}
+
+func NewMemory() *Memory {
+	return &Memory{hashes: make(map[string]string)}
+}
+
+func (m *Memory) SetHash(ctx context.Context, userID, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hashes[userID] = hash
+	return nil
+}
+
+func (m *Memory) Hash(ctx context.Context, userID string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hash, ok := m.hashes[userID]
+	if !ok {
+		return "", errs.Wrap("credentials.Memory.Hash", errs.ErrNotFound)
+	}
+	return hash, nil
+}
+
+// Delete is a no-op for a user with no password.
+func (m *Memory) Delete(ctx context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.hashes, userID)
+	return nil
+}
@@ -0,0 +1,140 @@
+// Package ctxutil is the one place request-scoped values go into and come
+// out of a context.Context.
+//
+// Each value has a typed pair of accessors, WithRequestID and RequestID and
+// so on, so callers never touch context.WithValue or assert on an `any`.
+// The keys behind them are values of an unexported type, nothing outside
+// this package can construct one, so no other package can read, overwrite,
+// or collide with them however it names its own keys.
+package ctxutil
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Key is a typed context key. Two keys are only equal if they are the same
+// *Key, the name is for debugging, so NewKey[string]("id") in two packages
+// gives two independent keys. Use it for values that belong to a single
+// package, such as the dataloader in transport/graphql.
+type Key[T any] struct {
+	name string
+}
+
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+// With returns a copy of ctx carrying v under k.
+func (k *Key[T]) With(ctx context.Context, v T) context.Context {
+	return context.WithValue(ctx, k, v)
+}
+
+// From returns the value stored under k, if any.
+func (k *Key[T]) From(ctx context.Context) (T, bool) {
+	v, ok := ctx.Value(k).(T)
+	return v, ok
+}
+
+func (k *Key[T]) String() string {
+	return "ctxutil.Key(" + k.name + ")"
+}
+
+// Principal is the authenticated caller. auth.Principal is an alias of it,
+// living here lets any layer read the caller without importing auth.
+type Principal struct {
+	// Subject is the user ID the token was issued to.
+	Subject string
+	// ExpiresAt is when the access token stops being accepted.
+	ExpiresAt time.Time
+	// Roles are the roles the token grants, see the authz package.
+	Roles []string
+}
+
+var (
+	requestIDKey = NewKey[string]("request_id")
+	traceIDKey   = NewKey[string]("trace_id")
+	principalKey = NewKey[Principal]("principal")
+	loggerKey    = NewKey[*slog.Logger]("logger")
+	tenantKey    = NewKey[string]("tenant")
+)
+
+// WithRequestID returns a copy of ctx carrying id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return requestIDKey.With(ctx, id)
+}
+
+// RequestID returns the request ID stored in ctx, if any.
+func RequestID(ctx context.Context) (string, bool) {
+	return requestIDKey.From(ctx)
+}
+
+// WithTraceID returns a copy of ctx carrying a W3C trace ID, 32 hex digits.
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return traceIDKey.With(ctx, id)
+}
+
+// TraceID returns the trace ID stored in ctx, if any.
+func TraceID(ctx context.Context) (string, bool) {
+	return traceIDKey.From(ctx)
+}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return principalKey.With(ctx, p)
+}
+
+// PrincipalFrom returns the authenticated caller stored in ctx, if any.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	return principalKey.From(ctx)
+}
+
+// WithTenant returns a copy of ctx acting for tenant id. The stores that
+// keep tenants apart read it on every call, see db/tenanted.
+func WithTenant(ctx context.Context, id string) context.Context {
+	return tenantKey.With(ctx, id)
+}
+
+// Tenant returns the tenant stored in ctx, if any. A store that keeps
+// tenants apart puts a call made without one in the default tenant, "".
+func Tenant(ctx context.Context) (string, bool) {
+	return tenantKey.From(ctx)
+}
+
+// WithLogger returns a copy of ctx carrying a request-scoped logger, one
+// that already has attributes such as the request ID attached.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return loggerKey.With(ctx, logger)
+}
+
+// Logger returns the request-scoped logger, or fallback when there is none.
+// Layers keep their own configured logger as the fallback so they still log
+// outside a request, e.g. in a background job.
+func Logger(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := loggerKey.From(ctx); ok && logger != nil {
+		return logger
+	}
+	return fallback
+}
+
+// WithBudget bounds the time left for the rest of a request to d. It only
+// ever shortens the deadline, a budget longer than what the caller already
+// allowed leaves the existing deadline in charge.
+func WithBudget(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= d {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// Budget returns the time left before ctx's deadline, false when it has none.
+// A layer can use it to skip work that cannot finish in time, such as a
+// retry whose backoff alone would overrun the deadline.
+func Budget(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
@@ -0,0 +1,108 @@
+package ctxutil_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+)
+
+func TestAccessors(t *testing.T) {
+	ctx := context.Background()
+	if _, ok := ctxutil.RequestID(ctx); ok {
+		t.Error("RequestID found in an empty context")
+	}
+	if _, ok := ctxutil.PrincipalFrom(ctx); ok {
+		t.Error("PrincipalFrom found in an empty context")
+	}
+
+	p := ctxutil.Principal{Subject: "ada", Roles: []string{"admin"}}
+	ctx = ctxutil.WithRequestID(ctx, "req-1")
+	ctx = ctxutil.WithTraceID(ctx, "4bf92f3577b34da6a3ce929d0e0e4736")
+	ctx = ctxutil.WithPrincipal(ctx, p)
+	ctx = ctxutil.WithTenant(ctx, "acme")
+
+	if got, ok := ctxutil.RequestID(ctx); !ok || got != "req-1" {
+		t.Errorf("RequestID = %q, %t, want req-1", got, ok)
+	}
+	if got, ok := ctxutil.TraceID(ctx); !ok || got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("TraceID = %q, %t", got, ok)
+	}
+	if got, ok := ctxutil.PrincipalFrom(ctx); !ok || got.Subject != "ada" {
+		t.Errorf("PrincipalFrom = %+v, %t, want ada", got, ok)
+	}
+	if got, ok := ctxutil.Tenant(ctx); !ok || got != "acme" {
+		t.Errorf("Tenant = %q, %t, want acme", got, ok)
+	}
+}
+
+// TestKeysDoNotCollide stores values under the names and string keys the
+// package uses internally: none of them can reach its values, and two
+// Keys of the same name and type are still independent.
+func TestKeysDoNotCollide(t *testing.T) {
+	type key string
+	ctx := ctxutil.WithRequestID(context.Background(), "req-1")
+	ctx = context.WithValue(ctx, "request_id", "forged")
+	ctx = context.WithValue(ctx, key("request_id"), "forged")
+	if got, _ := ctxutil.RequestID(ctx); got != "req-1" {
+		t.Errorf("RequestID = %q after other packages' request_id keys, want req-1", got)
+	}
+
+	// and the string key still has its own value
+	if v := ctx.Value("request_id"); v != "forged" {
+		t.Errorf("the string key read %v, want its own value", v)
+	}
+
+	a, b := ctxutil.NewKey[string]("id"), ctxutil.NewKey[string]("id")
+	ctx = a.With(context.Background(), "from a")
+	if _, ok := b.From(ctx); ok {
+		t.Error("a second key named id read the first one's value")
+	}
+	if got, ok := a.From(ctx); !ok || got != "from a" {
+		t.Errorf("a.From = %q, %t, want from a", got, ok)
+	}
+	ctx = b.With(ctx, "from b")
+	if got, _ := a.From(ctx); got != "from a" {
+		t.Errorf("a.From = %q after b.With, want from a", got)
+	}
+	if a.String() != "ctxutil.Key(id)" {
+		t.Errorf("String = %q", a.String())
+	}
+}
+
+func TestLogger(t *testing.T) {
+	fallback := slog.New(slog.DiscardHandler)
+	if got := ctxutil.Logger(context.Background(), fallback); got != fallback {
+		t.Error("Logger without one in the context did not return the fallback")
+	}
+	scoped := fallback.With("request_id", "req-1")
+	if got := ctxutil.Logger(ctxutil.WithLogger(context.Background(), scoped), fallback); got != scoped {
+		t.Error("Logger did not return the request's logger")
+	}
+	if got := ctxutil.Logger(ctxutil.WithLogger(context.Background(), nil), fallback); got != fallback {
+		t.Error("a nil logger in the context was returned instead of the fallback")
+	}
+}
+
+func TestBudget(t *testing.T) {
+	if _, ok := ctxutil.Budget(context.Background()); ok {
+		t.Error("Budget found a deadline on Background")
+	}
+	ctx, cancel := ctxutil.WithBudget(context.Background(), time.Minute)
+	defer cancel()
+	if left, ok := ctxutil.Budget(ctx); !ok || left <= 0 || left > time.Minute {
+		t.Errorf("Budget = %v, %t, want up to a minute", left, ok)
+	}
+
+	// a longer budget never extends the caller's deadline
+	short, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ctx, cancel = ctxutil.WithBudget(short, time.Hour)
+	defer cancel()
+	want, _ := short.Deadline()
+	if got, _ := ctx.Deadline(); !got.Equal(want) {
+		t.Errorf("Deadline = %v, want the caller's %v", got, want)
+	}
+}
@@ -0,0 +1,112 @@
+package cursor_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/cursor"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// The cursor code as it was before the append-based rewrite, kept to
+// compare and benchmark against.
+
+func encodeBefore(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("v1:" + key))
+}
+
+func decodeBefore(c string) (string, error) {
+	if c == "" {
+		return "", nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(c)
+	if err != nil || base64.RawURLEncoding.EncodeToString(b) != c {
+		return "", errs.ErrInvalidInput
+	}
+	key, ok := strings.CutPrefix(string(b), "v1:")
+	if !ok || !utf8.ValidString(key) || strings.IndexFunc(key, unicode.IsControl) >= 0 {
+		return "", errs.ErrInvalidInput
+	}
+	return key, nil
+}
+
+const key = "0198f9d2-7c1e-7b3a-9f00-123456789abc"
+
+// TestSameAsBefore checks the rewrite encodes what the old code did, and
+// refuses what it refused.
+func TestSameAsBefore(t *testing.T) {
+	for _, k := range []string{"", "a", key, "user-0042", strings.Repeat("é", 100)} {
+		if got, want := cursor.Encode(k), encodeBefore(k); got != want {
+			t.Errorf("Encode(%q) = %q, want %q", k, got, want)
+		}
+		if got := string(cursor.AppendEncode([]byte("x"), k)); got != "x"+encodeBefore(k) {
+			t.Errorf("AppendEncode(%q) = %q, want x and then %q", k, got, encodeBefore(k))
+		}
+	}
+	for _, bad := range []string{"djE6YQ\n", "djE6YR", "djE6Y", "YQ", "djE6AA", "!!"} {
+		if _, err := decodeBefore(bad); err == nil {
+			t.Fatalf("the old Decode(%q) succeeded, the case tests nothing", bad)
+		}
+		if _, err := cursor.Decode(bad); err == nil {
+			t.Errorf("Decode(%q) succeeded", bad)
+		}
+	}
+}
+
+// TestAllocs checks the string forms allocate only the string they
+// return, and the append forms nothing at all into a buffer with room.
+func TestAllocs(t *testing.T) {
+	c := cursor.Encode(key)
+	buf := make([]byte, 0, 128)
+	for _, tt := range []struct {
+		name string
+		want float64
+		fn   func()
+	}{
+		{"Encode", 1, func() { _ = cursor.Encode(key) }},
+		{"Decode", 1, func() { _, _ = cursor.Decode(c) }},
+		{"AppendEncode", 0, func() { buf = cursor.AppendEncode(buf[:0], key) }},
+		{"AppendDecode", 0, func() { buf, _ = cursor.AppendDecode(buf[:0], c) }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testing.AllocsPerRun(1_000, tt.fn); got != tt.want {
+				t.Errorf("%v allocations per run, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// sink is where the benchmarks put what they make, so the compiler cannot
+// keep it on the stack as it could if it were thrown away.
+var sink string
+
+// BenchmarkCursor runs each function against the code it replaced. The
+// old code had no append forms, their before appends its string.
+func BenchmarkCursor(b *testing.B) {
+	c := cursor.Encode(key)
+	buf := make([]byte, 0, 128)
+	for _, bm := range []struct {
+		name          string
+		before, after func()
+	}{
+		{"Encode", func() { sink = encodeBefore(key) }, func() { sink = cursor.Encode(key) }},
+		{"Decode", func() { sink, _ = decodeBefore(c) }, func() { sink, _ = cursor.Decode(c) }},
+		{"AppendEncode", func() { buf = append(buf[:0], encodeBefore(key)...) }, func() { buf = cursor.AppendEncode(buf[:0], key) }},
+		{"AppendDecode", func() { k, _ := decodeBefore(c); buf = append(buf[:0], k...) }, func() { buf, _ = cursor.AppendDecode(buf[:0], c) }},
+	} {
+		for _, v := range []struct {
+			name string
+			fn   func()
+		}{{"before", bm.before}, {"after", bm.after}} {
+			b.Run(bm.name+"/"+v.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for b.Loop() {
+					v.fn()
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package cursor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// prefix versions the format so it can change without breaking clients
+// holding old cursors, they will get ErrInvalidInput instead of bad pages.
+const prefix = "v1:"
+
+// scratch is the stack space Encode and Decode work in. A UUID key's
+// cursor is 52 bytes, so every ID this service mints fits, a longer key
+// only costs an allocation on the way through.
+const scratch = 96
+
+// strict rejects what would decode to the same bytes as the one encoding
+// Encode gives: nonzero padding bits. The decoder skips newlines even so,
+// Decode refuses those itself.
+var strict = base64.RawURLEncoding.Strict()
+
+// Encode returns an opaque cursor pointing just after key. The cursor
+// string is its only allocation.
+func Encode(key string) string {
+	var buf [scratch]byte
+	return string(AppendEncode(buf[:0], key))
+}
+
+// AppendEncode appends the cursor Encode returns for key to dst, for a
+// caller writing it into a buffer of its own. It allocates only to grow
+// dst.
+func AppendEncode(dst []byte, key string) []byte {
+	var buf [scratch]byte
+	src := append(append(buf[:0], prefix...), key...)
+	return base64.RawURLEncoding.AppendEncode(dst, src)
+}
+
+// Decode returns the key an Encode cursor points after. The empty cursor
+// decodes to the empty key, meaning the start of the collection. The key
+// string is its only allocation.
+//
+// Only cursors Encode could have produced are accepted: the one encoding of
+// the key, the decoder alone lets stray newlines and padding bits through,
+// and a key that is valid UTF-8 without control characters, which a
+// hand-edited cursor could otherwise smuggle into a store query that fails
+// on them with an internal error rather than a bad request.
+func Decode(c string) (string, error) {
+	if c == "" {
+		return "", nil
+	}
+	var buf [scratch]byte
+	key, err := appendDecode(buf[:0], c)
+	if err != nil {
+		return "", errs.Wrap("cursor.Decode", err)
+	}
+	return string(key), nil
+}
+
+// AppendDecode appends the key Decode returns for c to dst, with the same
+// checks. It allocates only to grow dst, and to copy a c longer than the
+// cursor of any key Encode fits on the stack.
+func AppendDecode(dst []byte, c string) ([]byte, error) {
+	b, err := appendDecode(dst, c)
+	return b, errs.Wrap("cursor.AppendDecode", err)
+}
+
+func appendDecode(dst []byte, c string) ([]byte, error) {
+	if c == "" {
+		return dst, nil
+	}
+	// the decoder takes bytes, copied here rather than converted, which
+	// would allocate for anything over 32 bytes
+	var src [scratch]byte
+	in := src[:0]
+	if len(c) <= len(src) {
+		in = append(in, c...)
+	} else {
+		in = []byte(c)
+	}
+	if bytes.ContainsAny(in, "\r\n") {
+		return dst, errs.ErrInvalidInput
+	}
+	var buf [scratch]byte
+	b, err := strict.AppendDecode(buf[:0], in)
+	if err != nil {
+		return dst, errs.ErrInvalidInput
+	}
+	key, ok := bytes.CutPrefix(b, []byte(prefix))
+	if !ok || !utf8.Valid(key) || bytes.IndexFunc(key, unicode.IsControl) >= 0 {
+		return dst, errs.ErrInvalidInput
+	}
+	return append(dst, key...), nil
+}
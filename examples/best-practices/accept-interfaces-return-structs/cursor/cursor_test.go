@@ -0,0 +1,68 @@
+package cursor_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/cursor"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, key := range []string{"", "42", "u000", "0198f9d2-7c1e-7b3a-9f00-123456789abc", strings.Repeat("k", 200)} {
+		got, err := cursor.Decode(cursor.Encode(key))
+		if err != nil || got != key {
+			t.Errorf("Decode(Encode(%q)) = %q, %v", key, got, err)
+		}
+		b, err := cursor.AppendDecode([]byte("x"), cursor.Encode(key))
+		if err != nil || string(b) != "x"+key {
+			t.Errorf("AppendDecode(x, Encode(%q)) = %q, %v", key, b, err)
+		}
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	for _, c := range []string{
+		"!!!",
+		// base64 of "v2:u00", another version
+		"djI6dTAw",
+		// "v1:" with a newline the decoder would skip
+		"\rdjE6",
+		// nonzero padding bits, a second spelling of "v1:"
+		"djE7",
+		// a non-UTF-8 key
+		"djE600",
+		cursor.Encode("a\x00b"),
+	} {
+		if key, err := cursor.Decode(c); !errors.Is(err, errs.ErrInvalidInput) {
+			t.Errorf("Decode(%q) = %q, %v, want ErrInvalidInput", c, key, err)
+		}
+	}
+}
+
+// FuzzDecodeCursor checks Decode never panics, and that anything it
+// accepts is the one cursor Encode gives for a printable UTF-8 key.
+func FuzzDecodeCursor(f *testing.F) {
+	for _, seed := range []string{
+		"", cursor.Encode("42"), cursor.Encode(""), cursor.Encode("é"), "!!!", "djE6",
+		// regressions: a non-UTF-8 key, and a second encoding of "v1:"
+		"djE600", "\rdjE6",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, c string) {
+		key, err := cursor.Decode(c)
+		if err != nil || c == "" {
+			return
+		}
+		if again := cursor.Encode(key); again != c {
+			t.Fatalf("Decode(%q) = %q, which encodes to %q", c, key, again)
+		}
+		if !utf8.ValidString(key) || strings.IndexFunc(key, unicode.IsControl) >= 0 {
+			t.Fatalf("Decode(%q) accepted the key %q", c, key)
+		}
+	})
+}
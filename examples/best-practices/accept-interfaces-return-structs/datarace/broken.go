@@ -0,0 +1,36 @@
+//go:build broken
+
+package datarace
+
+// UnsafeCounter is the bug: n++ is a load, an add, and a store, and two
+// goroutines interleaving them lose an increment. Without -race the count
+// can just come up short, with -race it is reported on the first overlap.
+type UnsafeCounter struct {
+	n int64
+}
+
+func (c *UnsafeCounter) Inc() { c.n++ }
+
+func (c *UnsafeCounter) Value() int64 { return c.n }
+
+// UnsafeCache is a map with no lock. Even without -race the runtime may
+// abort the process with "concurrent map writes".
+type UnsafeCache struct {
+	m map[string]int
+}
+
+func (c *UnsafeCache) Get(key string) (int, bool) {
+	v, ok := c.m[key]
+	return v, ok
+}
+
+func (c *UnsafeCache) Set(key string, value int) { c.m[key] = value }
+
+var (
+	brokenCounters = []Variant[Counter]{
+		{"broken", func() (Counter, func()) { return &UnsafeCounter{}, func() {} }},
+	}
+	brokenCaches = []Variant[Cache]{
+		{"broken", func() (Cache, func()) { return &UnsafeCache{m: make(map[string]int)}, func() {} }},
+	}
+)
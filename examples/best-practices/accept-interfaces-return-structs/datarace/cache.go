@@ -0,0 +1,100 @@
+package datarace
+
+import "sync"
+
+// MutexCache is a map behind a RWMutex, reads share the lock. A map needs
+// it even when no two goroutines touch the same key: writes can grow the
+// table under a concurrent read, which the runtime detects and turns into
+// a fatal "concurrent map read and map write".
+type MutexCache struct {
+	mu sync.RWMutex
+	m  map[string]int
+}
+
+func NewMutexCache() *MutexCache {
+	return &MutexCache{m: make(map[string]int)}
+}
+
+func (c *MutexCache) Get(key string) (int, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	v, ok := c.m[key]
+	return v, ok
+}
+
+func (c *MutexCache) Set(key string, value int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.m[key] = value
+}
+
+type getRequest struct {
+	key   string
+	reply chan getReply
+}
+
+type getReply struct {
+	value int
+	ok    bool
+}
+
+type setRequest struct {
+	key   string
+	value int
+}
+
+// OwnedCache keeps the map on one goroutine, Get and Set are requests to it.
+type OwnedCache struct {
+	get  chan getRequest
+	set  chan setRequest
+	done chan struct{}
+	once sync.Once
+}
+
+func NewOwnedCache() *OwnedCache {
+	c := &OwnedCache{
+		get:  make(chan getRequest),
+		set:  make(chan setRequest),
+		done: make(chan struct{}),
+	}
+	go func() {
+		m := make(map[string]int)
+		for {
+			select {
+			case req := <-c.get:
+				v, ok := m[req.key]
+				req.reply <- getReply{v, ok}
+			case req := <-c.set:
+				m[req.key] = req.value
+			case <-c.done:
+				return
+			}
+		}
+	}()
+	return c
+}
+
+// Get misses after Close.
+func (c *OwnedCache) Get(key string) (int, bool) {
+	reply := make(chan getReply, 1)
+	select {
+	case c.get <- getRequest{key, reply}:
+		r := <-reply
+		return r.value, r.ok
+	case <-c.done:
+		return 0, false
+	}
+}
+
+// Set is a no-op after Close.
+func (c *OwnedCache) Set(key string, value int) {
+	select {
+	case c.set <- setRequest{key, value}:
+	case <-c.done:
+	}
+}
+
+// Close stops the owning goroutine. It is safe to call more than once.
+func (c *OwnedCache) Close() {
+	c.once.Do(func() { close(c.done) })
+}
@@ -0,0 +1,93 @@
+package datarace
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// MutexCounter serializes every access with a mutex, reads included: an
+// unlocked read can see a stale value or, for wider types, a torn one.
+type MutexCounter struct {
+	mu sync.Mutex
+	n  int64
+}
+
+func (c *MutexCounter) Inc() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n++
+}
+
+func (c *MutexCounter) Value() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n
+}
+
+// AtomicCounter makes the read-modify-write a single instruction. It only
+// works for state that fits in one word, anything with two fields that must
+// change together needs the mutex.
+type AtomicCounter struct {
+	n atomic.Int64
+}
+
+func (c *AtomicCounter) Inc() { c.n.Add(1) }
+
+func (c *AtomicCounter) Value() int64 { return c.n.Load() }
+
+// OwnedCounter keeps n on one goroutine, nothing else ever touches it.
+// Callers send it requests instead. It is the most code of the three, but
+// however many fields the state grows there is no lock to forget, only the
+// owner can reach them.
+type OwnedCounter struct {
+	inc   chan struct{}
+	value chan chan int64
+	done  chan struct{}
+	once  sync.Once
+}
+
+func NewOwnedCounter() *OwnedCounter {
+	c := &OwnedCounter{
+		inc:   make(chan struct{}),
+		value: make(chan chan int64),
+		done:  make(chan struct{}),
+	}
+	go func() {
+		var n int64
+		for {
+			select {
+			case <-c.inc:
+				n++
+			case reply := <-c.value:
+				reply <- n
+			case <-c.done:
+				return
+			}
+		}
+	}()
+	return c
+}
+
+// Inc is a no-op after Close.
+func (c *OwnedCounter) Inc() {
+	select {
+	case c.inc <- struct{}{}:
+	case <-c.done:
+	}
+}
+
+// Value returns 0 after Close.
+func (c *OwnedCounter) Value() int64 {
+	reply := make(chan int64, 1)
+	select {
+	case c.value <- reply:
+		return <-reply
+	case <-c.done:
+		return 0
+	}
+}
+
+// Close stops the owning goroutine. It is safe to call more than once.
+func (c *OwnedCounter) Close() {
+	c.once.Do(func() { close(c.done) })
+}
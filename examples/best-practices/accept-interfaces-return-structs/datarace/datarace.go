@@ -0,0 +1,48 @@
+// Package datarace is one shared counter and one shared cache written three
+// ways that are safe for concurrent use: behind a mutex, with atomics, and
+// owned by a single goroutine that everyone else talks to over a channel.
+//
+// The broken versions, a plain int64 and a plain map, are only compiled
+// with the broken build tag, so nothing else in the module can pick them up
+// by accident and go build, go vet, and the race detector stay clean:
+//
+//	go test -race ./datarace                  # every variant passes
+//	go test -race -tags broken ./datarace     # the race detector fails the broken ones
+package datarace
+
+// Counter is incremented from many goroutines at once.
+type Counter interface {
+	Inc()
+	Value() int64
+}
+
+// Cache is read and written from many goroutines at once.
+type Cache interface {
+	Get(key string) (int, bool)
+	Set(key string, value int)
+}
+
+// Variant is one implementation, Close releases anything it started.
+type Variant[T any] struct {
+	Name string
+	New  func() (v T, close func())
+}
+
+// Counters lists the counter implementations compiled in, the broken one
+// included under the broken build tag.
+func Counters() []Variant[Counter] {
+	return append([]Variant[Counter]{
+		{"mutex", func() (Counter, func()) { return &MutexCounter{}, func() {} }},
+		{"atomic", func() (Counter, func()) { return &AtomicCounter{}, func() {} }},
+		{"owner", func() (Counter, func()) { c := NewOwnedCounter(); return c, c.Close }},
+	}, brokenCounters...)
+}
+
+// Caches lists the cache implementations compiled in, the broken one
+// included under the broken build tag.
+func Caches() []Variant[Cache] {
+	return append([]Variant[Cache]{
+		{"mutex", func() (Cache, func()) { return NewMutexCache(), func() {} }},
+		{"owner", func() (Cache, func()) { c := NewOwnedCache(); return c, c.Close }},
+	}, brokenCaches...)
+}
@@ -0,0 +1,80 @@
+package datarace_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/datarace"
+)
+
+const (
+	goroutines = 8
+	ops        = 10_000
+)
+
+// TestCounters hammers every counter from many goroutines at once and
+// checks no increment was lost. Under -race the detector checks every
+// access too, so with -tags broken it fails the broken counter on the first
+// overlap; without -race, on one CPU, the broken counter may well pass.
+//
+//	go test -race ./datarace
+//	go test -race -tags broken ./datarace
+func TestCounters(t *testing.T) {
+	for _, v := range datarace.Counters() {
+		t.Run(v.Name, func(t *testing.T) {
+			c, closeCounter := v.New()
+			defer closeCounter()
+			var wg sync.WaitGroup
+			for range goroutines {
+				wg.Go(func() {
+					for i := range ops {
+						c.Inc()
+						if i%100 == 0 {
+							c.Value()
+						}
+					}
+				})
+			}
+			wg.Wait()
+			if got, want := c.Value(), int64(goroutines*ops); got != want {
+				t.Errorf("Value = %d, want %d, %d increments lost", got, want, want-got)
+			}
+		})
+	}
+}
+
+// TestCaches gives each goroutine its own keys to write and has it read
+// everyone else's, so the only thing shared is the cache itself.
+func TestCaches(t *testing.T) {
+	const keys = 100
+	for _, v := range datarace.Caches() {
+		t.Run(v.Name, func(t *testing.T) {
+			c, closeCache := v.New()
+			defer closeCache()
+			var wg sync.WaitGroup
+			for g := range goroutines {
+				wg.Go(func() {
+					for i := range ops {
+						c.Set(key(g, i%keys), i)
+						c.Get(key((g+1)%goroutines, i%keys))
+					}
+				})
+			}
+			wg.Wait()
+			for g := range goroutines {
+				for k := range keys {
+					// the last write to key k was the last i with i%keys == k
+					want := (ops-1-k)/keys*keys + k
+					if got, ok := c.Get(key(g, k)); !ok || got != want {
+						t.Fatalf("Get(%s) = %d, %t, want %d", key(g, k), got, ok, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func key(g, k int) string {
+	return strconv.Itoa(g) + "/" + strconv.Itoa(k)
+}
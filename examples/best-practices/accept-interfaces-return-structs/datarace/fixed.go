@@ -0,0 +1,9 @@
+//go:build !broken
+
+package datarace
+
+// without the broken tag there is nothing to add
+var (
+	brokenCounters []Variant[Counter]
+	brokenCaches   []Variant[Cache]
+)
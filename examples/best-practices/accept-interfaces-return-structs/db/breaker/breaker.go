@@ -0,0 +1,83 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/circuitbreaker"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Store decorates a UserStorer with a circuit breaker, so while the
+// database is down the service fails fast with circuitbreaker.ErrOpen
+// instead of every request waiting out its own timeout.
+//
+// Put it outside db/retry: breaker(retry(store)) counts a call once its
+// retries are spent, and does not retry calls the breaker refused.
+type Store struct {
+	next    service.UserStorer
+	breaker *circuitbreaker.Breaker
+}
+
+func New(next service.UserStorer, breaker *circuitbreaker.Breaker) *Store {
+	return &Store{
+		next:    next,
+		breaker: breaker,
+	}
+}
+
+func (s *Store) Insert(ctx context.Context, user *service.User) error {
+	return s.breaker.Do(func() error {
+		return s.next.Insert(ctx, user)
+	})
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*service.User, error) {
+	var user *service.User
+	err := s.breaker.Do(func() error {
+		var err error
+		user, err = s.next.Get(ctx, id)
+		return err
+	})
+	return user, err
+}
+
+func (s *Store) GetByEmail(ctx context.Context, email string) (*service.User, error) {
+	finder, ok := s.next.(service.UserFinder)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	var user *service.User
+	err := s.breaker.Do(func() error {
+		var err error
+		user, err = finder.GetByEmail(ctx, email)
+		return err
+	})
+	return user, err
+}
+
+func (s *Store) List(ctx context.Context, after string, limit int) ([]*service.User, error) {
+	lister, ok := s.next.(service.UserLister)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	var users []*service.User
+	err := s.breaker.Do(func() error {
+		var err error
+		users, err = lister.List(ctx, after, limit)
+		return err
+	})
+	return users, err
+}
+
+func (s *Store) Update(ctx context.Context, user *service.User) error {
+	return s.breaker.Do(func() error {
+		return s.next.Update(ctx, user)
+	})
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	return s.breaker.Do(func() error {
+		return s.next.Delete(ctx, id)
+	})
+}
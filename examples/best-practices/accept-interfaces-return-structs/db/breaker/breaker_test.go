@@ -0,0 +1,63 @@
+package breaker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/circuitbreaker"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/breaker"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/fake"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storetest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// TestFailsFast takes the database down: after the threshold the service
+// stops reaching the store, and once it is back a trial call closes the
+// breaker again.
+func TestFailsFast(t *testing.T) {
+	ctx := context.Background()
+	c := clock.NewFake(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	store := fake.New()
+	b := circuitbreaker.New(circuitbreaker.WithClock(c), circuitbreaker.WithFailureThreshold(3), circuitbreaker.WithResetTimeout(time.Minute))
+	users := service.NewUserService(breaker.New(store, b))
+	if err := users.CreateUser(ctx, &service.User{ID: "ada", Email: "ada@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	store.FailWith("Get", errors.New("connection refused"))
+	for range 3 {
+		users.RetrieveUser(ctx, "ada")
+	}
+	store.Reset()
+	if _, err := users.RetrieveUser(ctx, "ada"); !errors.Is(err, circuitbreaker.ErrOpen) || !errors.Is(err, errs.ErrUnavailable) {
+		t.Fatalf("RetrieveUser while open: err = %v, want ErrOpen", err)
+	}
+	store.AssertNotCalled(t, "Get")
+
+	c.Advance(time.Minute)
+	if _, err := users.RetrieveUser(ctx, "ada"); err != nil {
+		t.Fatalf("RetrieveUser after the reset timeout: %v", err)
+	}
+	if b.State() != circuitbreaker.Closed {
+		t.Errorf("State = %s after a good trial, want closed", b.State())
+	}
+
+	// a missing user is not the database failing
+	for range 5 {
+		users.RetrieveUser(ctx, "nobody")
+	}
+	if b.State() != circuitbreaker.Closed {
+		t.Errorf("State = %s after not found reads, want closed", b.State())
+	}
+}
+
+func TestStore(t *testing.T) {
+	storetest.TestStore(t, func() service.UserStorer {
+		return breaker.New(db.NewMemoryStore(), circuitbreaker.New())
+	})
+}
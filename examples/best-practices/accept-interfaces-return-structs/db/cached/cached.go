@@ -0,0 +1,211 @@
+package cached
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/cache"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/codec"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Store decorates any UserStorer with a read-through cache. Because it both
+// accepts and satisfies service.UserStorer, it can wrap memory, SQL, or
+// redis stores and the UserService never knows it is there.
+//
+// Reads populate the cache on a miss, writes go to the backing store first
+// and then evict the cached copy so the next read reloads it. Concurrent
+// misses for the same ID share one backend call through singleflight, so a
+// hot key expiring does not send a stampede to the database.
+//
+// The cache is a cache.LRU, bounded by WithSize and optionally WithTTL, so
+// a long running process does not end up holding every user it ever read.
+// It holds users as structs, or encoded with WithCodec, one of users and
+// encoded is set.
+type Store struct {
+	next    service.UserStorer
+	group   singleflight.Group
+	users   *cache.LRU[string, service.User]
+	encoded *cache.LRU[string, []byte]
+	codec   codec.Codec
+}
+
+// Option configures a Store.
+type Option func(*options)
+
+type options struct {
+	size  int
+	ttl   time.Duration
+	codec codec.Codec
+}
+
+// WithSize bounds how many users are cached, the default is 10000.
+func WithSize(n int) Option {
+	return func(o *options) {
+		o.size = n
+	}
+}
+
+// WithTTL expires cached users after d, for stores also written to by
+// other processes, whose writes this cache cannot see. The default is no
+// expiry.
+func WithTTL(d time.Duration) Option {
+	return func(o *options) {
+		o.ttl = d
+	}
+}
+
+// WithCodec keeps cached users encoded with c rather than as structs. Each
+// hit pays a decode, in return an entry is one pointer free allocation the
+// garbage collector need not trace, and a large cache costs less GC time.
+// cmd/bench measures both sides of that. The default is no codec.
+func WithCodec(c codec.Codec) Option {
+	return func(o *options) {
+		o.codec = c
+	}
+}
+
+func New(next service.UserStorer, opts ...Option) *Store {
+	o := options{size: 10_000}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	s := &Store{next: next, codec: o.codec}
+	if o.codec != nil {
+		s.encoded = cache.New[string, []byte](o.size, cache.WithTTL(o.ttl))
+	} else {
+		s.users = cache.New[string, service.User](o.size, cache.WithTTL(o.ttl))
+	}
+	return s
+}
+
+// OnEvict registers a hook called whenever a user leaves the cache, e.g. to
+// count evictions.
+func (s *Store) OnEvict(fn func(id string, reason cache.Reason)) {
+	if s.encoded != nil {
+		s.encoded.OnEvict(func(id string, _ []byte, reason cache.Reason) {
+			fn(id, reason)
+		})
+		return
+	}
+	s.users.OnEvict(func(id string, _ service.User, reason cache.Reason) {
+		fn(id, reason)
+	})
+}
+
+func (s *Store) Insert(ctx context.Context, user *service.User) error {
+	if err := s.next.Insert(ctx, user); err != nil {
+		return err
+	}
+	s.evict(user.ID)
+	return nil
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*service.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if user, ok := s.lookup(id); ok {
+		return &user, nil
+	}
+
+	// the shared call outlives any one caller, so it must not be canceled
+	// with the first caller's request, each caller still stops waiting on
+	// its own context
+	ch := s.group.DoChan(id, func() (any, error) {
+		found, err := s.next.Get(context.WithoutCancel(ctx), id)
+		if err != nil {
+			return nil, err
+		}
+		s.store(id, *found)
+		return *found, nil
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		// a copy per caller, they must not share one *User
+		user := res.Val.(service.User)
+		return &user, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// GetByEmail is not cached, the cache is keyed on ID only.
+func (s *Store) GetByEmail(ctx context.Context, email string) (*service.User, error) {
+	finder, ok := s.next.(service.UserFinder)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return finder.GetByEmail(ctx, email)
+}
+
+// List is not cached, pages go straight to the wrapped store.
+func (s *Store) List(ctx context.Context, after string, limit int) ([]*service.User, error) {
+	lister, ok := s.next.(service.UserLister)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return lister.List(ctx, after, limit)
+}
+
+func (s *Store) Update(ctx context.Context, user *service.User) error {
+	if err := s.next.Update(ctx, user); err != nil {
+		return err
+	}
+	s.evict(user.ID)
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	if err := s.next.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.evict(id)
+	return nil
+}
+
+// lookup treats an entry that fails to decode as a miss, the backend
+// still has the user.
+func (s *Store) lookup(id string) (service.User, bool) {
+	if s.encoded == nil {
+		return s.users.Get(id)
+	}
+	b, ok := s.encoded.Get(id)
+	if !ok {
+		return service.User{}, false
+	}
+	var user service.User
+	if err := s.codec.Unmarshal(b, &user); err != nil {
+		s.encoded.Remove(id)
+		return service.User{}, false
+	}
+	return user, true
+}
+
+// store skips caching a user that fails to encode, the next read goes to
+// the backend again.
+func (s *Store) store(id string, user service.User) {
+	if s.encoded == nil {
+		s.users.Set(id, user)
+		return
+	}
+	b, err := s.codec.Marshal(user)
+	if err != nil {
+		return
+	}
+	s.encoded.Set(id, b)
+}
+
+func (s *Store) evict(id string) {
+	if s.encoded != nil {
+		s.encoded.Remove(id)
+		return
+	}
+	s.users.Remove(id)
+}
@@ -0,0 +1,165 @@
+package cached_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/codec"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/cached"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storebench"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storetest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// gatedStore counts the Gets that reach it and holds each one until gate
+// is closed, so concurrent misses are sure to overlap.
+type gatedStore struct {
+	*db.MemoryStore
+	gate chan struct{}
+	gets atomic.Int64
+}
+
+func newGatedStore(t *testing.T) *gatedStore {
+	t.Helper()
+	s := &gatedStore{MemoryStore: db.NewMemoryStore(), gate: make(chan struct{})}
+	if err := s.Insert(context.Background(), &service.User{ID: "ada", Email: "ada@example.com", Version: 1}); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	return s
+}
+
+func (s *gatedStore) Get(ctx context.Context, id string) (*service.User, error) {
+	s.gets.Add(1)
+	<-s.gate
+	return s.MemoryStore.Get(ctx, id)
+}
+
+// TestConcurrentMissesShareOneGet sends N concurrent Gets of a cold ID
+// while the backend is held: every caller gets the user from exactly one
+// backend Get.
+func TestConcurrentMissesShareOneGet(t *testing.T) {
+	const n = 100
+	backend := newGatedStore(t)
+	store := cached.New(backend)
+
+	var (
+		wg    sync.WaitGroup
+		users = make([]*service.User, n)
+	)
+	for i := range n {
+		wg.Go(func() {
+			user, err := store.Get(context.Background(), "ada")
+			if err != nil {
+				t.Errorf("Get: %v", err)
+			}
+			users[i] = user
+		})
+	}
+	// give the callers time to pile up behind the held Get, they share it
+	// whether or not they all arrive before it is let go
+	time.Sleep(20 * time.Millisecond)
+	close(backend.gate)
+	wg.Wait()
+
+	if got := backend.gets.Load(); got != 1 {
+		t.Errorf("%d backend Gets, want 1", got)
+	}
+	seen := make(map[*service.User]bool)
+	for _, user := range users {
+		if user == nil || user.Email != "ada@example.com" {
+			t.Fatalf("Get = %+v, want ada", user)
+		}
+		if seen[user] {
+			t.Fatal("two callers were handed the same *User")
+		}
+		seen[user] = true
+	}
+}
+
+// TestImpatientCaller checks a caller whose context ends stops waiting on
+// the shared Get without failing the callers still waiting on it.
+func TestImpatientCaller(t *testing.T) {
+	backend := newGatedStore(t)
+	store := cached.New(backend)
+
+	patient := make(chan error, 1)
+	go func() {
+		_, err := store.Get(context.Background(), "ada")
+		patient <- err
+	}()
+	short, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := store.Get(short, "ada"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("impatient Get: err = %v, want DeadlineExceeded", err)
+	}
+	close(backend.gate)
+	if err := <-patient; err != nil {
+		t.Errorf("patient Get: %v", err)
+	}
+	if got := backend.gets.Load(); got != 1 {
+		t.Errorf("%d backend Gets, want the one shared", got)
+	}
+}
+
+func TestHitsAndEviction(t *testing.T) {
+	ctx := context.Background()
+	backend := newGatedStore(t)
+	close(backend.gate)
+	store := cached.New(backend)
+
+	for range 3 {
+		if _, err := store.Get(ctx, "ada"); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+	}
+	if got := backend.gets.Load(); got != 1 {
+		t.Fatalf("%d backend Gets for three reads, want 1", got)
+	}
+
+	// a write evicts, the next read reloads what the backend now holds
+	if err := store.Update(ctx, &service.User{ID: "ada", Email: "ada@lovelace.example", Version: 1}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	user, err := store.Get(ctx, "ada")
+	if err != nil || user.Email != "ada@lovelace.example" {
+		t.Fatalf("Get after Update = %+v, %v, want the new email", user, err)
+	}
+	if err := store.Delete(ctx, "ada"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(ctx, "ada"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Get after Delete: err = %v, want ErrNotFound", err)
+	}
+
+	// misses are not cached, the user inserted next is found
+	if err := store.Insert(ctx, &service.User{ID: "ada", Email: "ada@example.com", Version: 1}); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if _, err := store.Get(ctx, "ada"); err != nil {
+		t.Errorf("Get after a miss and an Insert: %v", err)
+	}
+}
+
+// TestStore holds the cache to the contract, every read after a write has
+// to see the write.
+func TestStore(t *testing.T) {
+	storetest.TestStore(t, func() service.UserStorer { return cached.New(db.NewMemoryStore()) })
+}
+
+// BenchmarkStore measures the cache over the memory store, where it can
+// only add overhead, in both codecs. cmd/bench also puts it over sqlite.
+func BenchmarkStore(b *testing.B) {
+	for name, c := range map[string]codec.Codec{"json": codec.JSON{}, "msgpack": codec.MsgPack{}} {
+		b.Run(name, func(b *testing.B) {
+			storebench.BenchmarkStore(b, func(*testing.B) service.UserStorer {
+				return cached.New(db.NewMemoryStore(), cached.WithCodec(c))
+			})
+		})
+	}
+}
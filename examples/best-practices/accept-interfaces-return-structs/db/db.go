@@ -1,6 +1,11 @@
 package db
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
 
 type Store struct {
 	db *sql.DB
@@ -12,13 +17,25 @@ func NewDB() *Store {
 	}
 }
 
-func (s *Store) Insert(item interface{}) error {
+func (s *Store) Insert(ctx context.Context, user *service.User) error {
+
+	//... to-do
+	return nil
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*service.User, error) {
+
+	//... to-do
+	return &service.User{ID: id}, nil
+}
+
+func (s *Store) Update(ctx context.Context, user *service.User) error {
 
 	//... to-do
 	return nil
 }
 
-func (s *Store) Get(id string, val interface{}) error {
+func (s *Store) Delete(ctx context.Context, id string) error {
 
 	//... to-do
 	return nil
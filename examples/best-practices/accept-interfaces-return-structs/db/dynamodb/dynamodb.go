@@ -0,0 +1,173 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// API is the subset of *dynamodb.Client the store needs. Depending on the
+// narrow interface keeps the store fakeable without pulling in the SDK mocks.
+type API interface {
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+}
+
+// item is the table representation of a user, kept separate from the
+// domain type so attribute names can change without touching service.User.
+type item struct {
+	ID        string    `dynamodbav:"id"`
+	Email     string    `dynamodbav:"email"`
+	Name      string    `dynamodbav:"name"`
+	Status    string    `dynamodbav:"status"`
+	CreatedAt time.Time `dynamodbav:"created_at"`
+	UpdatedAt time.Time `dynamodbav:"updated_at"`
+	DeletedAt time.Time `dynamodbav:"deleted_at"`
+	Version   int64     `dynamodbav:"version"`
+}
+
+// Store is a UserStorer backed by a DynamoDB table keyed on "id".
+// Insert and Update use condition expressions so uniqueness and existence
+// are enforced by DynamoDB rather than a racy read-then-write.
+type Store struct {
+	client API
+	table  string
+}
+
+func New(client API, table string) *Store {
+	return &Store{
+		client: client,
+		table:  table,
+	}
+}
+
+// CreateTable creates the users table with on-demand billing, it is meant for
+// dynamodb-local and localstack runs rather than production provisioning.
+func CreateTable(ctx context.Context, client *dynamodb.Client, table string) error {
+	_, err := client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:   aws.String(table),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("id"), KeyType: types.KeyTypeHash},
+		},
+	})
+	var inUse *types.ResourceInUseException
+	if err != nil && !errors.As(err, &inUse) {
+		return errs.Wrap("dynamodb.CreateTable", err)
+	}
+	waiter := dynamodb.NewTableExistsWaiter(client)
+	if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)}, time.Minute); err != nil {
+		return errs.Wrap("dynamodb.CreateTable", err)
+	}
+	return nil
+}
+
+func (s *Store) Insert(ctx context.Context, user *service.User) error {
+	av, err := attributevalue.MarshalMap(item{ID: user.ID, Email: user.Email, Name: user.Name, Status: string(user.Status), CreatedAt: user.CreatedAt, UpdatedAt: user.UpdatedAt, DeletedAt: user.DeletedAt, Version: user.Version})
+	if err != nil {
+		return errs.Wrap("dynamodb.Store.Insert", err)
+	}
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.table),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	})
+	return conditional("dynamodb.Store.Insert", err, errs.ErrConflict)
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*service.User, error) {
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		Key:            key(id),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, errs.Wrap("dynamodb.Store.Get", err)
+	}
+	if out.Item == nil {
+		return nil, errs.Wrap("dynamodb.Store.Get", errs.ErrNotFound)
+	}
+	var it item
+	if err := attributevalue.UnmarshalMap(out.Item, &it); err != nil {
+		return nil, errs.Wrap("dynamodb.Store.Get", err)
+	}
+	return &service.User{ID: it.ID, Email: it.Email, Name: it.Name, Status: service.Status(it.Status), CreatedAt: it.CreatedAt, UpdatedAt: it.UpdatedAt, DeletedAt: it.DeletedAt, Version: it.Version}, nil
+}
+
+// Update only sets the mutable attributes so created_at is left untouched.
+// The condition doubles as a compare-and-swap on version, the old item is
+// returned on failure to tell a missing user from a stale version.
+func (s *Store) Update(ctx context.Context, user *service.User) error {
+	values, err := attributevalue.MarshalMap(map[string]any{
+		":email":      user.Email,
+		":name":       user.Name,
+		":status":     string(user.Status),
+		":updated_at": user.UpdatedAt,
+		":deleted_at": user.DeletedAt,
+		":expected":   user.Version,
+		":next":       user.Version + 1,
+	})
+	if err != nil {
+		return errs.Wrap("dynamodb.Store.Update", err)
+	}
+	_, err = s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                           aws.String(s.table),
+		Key:                                 key(user.ID),
+		UpdateExpression:                    aws.String("SET email = :email, #name = :name, #status = :status, updated_at = :updated_at, deleted_at = :deleted_at, version = :next"),
+		ExpressionAttributeNames:            map[string]string{"#name": "name", "#status": "status"}, // both are reserved words
+		ConditionExpression:                 aws.String("attribute_exists(id) AND version = :expected"),
+		ExpressionAttributeValues:           values,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	var failed *types.ConditionalCheckFailedException
+	if errors.As(err, &failed) {
+		if failed.Item != nil {
+			return errs.Wrap("dynamodb.Store.Update", errs.ErrVersionConflict)
+		}
+		return errs.Wrap("dynamodb.Store.Update", errs.ErrNotFound)
+	}
+	if err != nil {
+		return errs.Wrap("dynamodb.Store.Update", err)
+	}
+	user.Version++
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(s.table),
+		Key:                 key(id),
+		ConditionExpression: aws.String("attribute_exists(id)"),
+	})
+	return conditional("dynamodb.Store.Delete", err, errs.ErrNotFound)
+}
+
+func conditional(op string, err error, kind error) error {
+	if err == nil {
+		return nil
+	}
+	var failed *types.ConditionalCheckFailedException
+	if errors.As(err, &failed) {
+		return errs.Wrap(op, kind)
+	}
+	return errs.Wrap(op, err)
+}
+
+func key(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"id": &types.AttributeValueMemberS{Value: id},
+	}
+}
@@ -0,0 +1,153 @@
+package dynamodb_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	awsdynamodb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/dynamodb"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storetest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storetest/containers"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// failing answers every call with a failed condition check, with the old
+// item when item is set.
+type failing struct {
+	dynamodb.API
+	item map[string]types.AttributeValue
+}
+
+func (f failing) PutItem(context.Context, *awsdynamodb.PutItemInput, ...func(*awsdynamodb.Options)) (*awsdynamodb.PutItemOutput, error) {
+	return nil, &types.ConditionalCheckFailedException{}
+}
+
+func (f failing) UpdateItem(context.Context, *awsdynamodb.UpdateItemInput, ...func(*awsdynamodb.Options)) (*awsdynamodb.UpdateItemOutput, error) {
+	return nil, &types.ConditionalCheckFailedException{Item: f.item}
+}
+
+func (f failing) DeleteItem(context.Context, *awsdynamodb.DeleteItemInput, ...func(*awsdynamodb.Options)) (*awsdynamodb.DeleteItemOutput, error) {
+	return nil, &types.ConditionalCheckFailedException{}
+}
+
+// TestConditionalChecks checks each failed condition maps onto the errs
+// taxonomy, no table needed.
+func TestConditionalChecks(t *testing.T) {
+	ctx := context.Background()
+	user := &service.User{ID: "ada", Email: "ada@example.com", Version: 1}
+	stale := map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "ada"}}
+	for _, tt := range []struct {
+		name string
+		call func() error
+		want error
+	}{
+		{"insert taken ID", func() error { return dynamodb.New(failing{}, "users").Insert(ctx, user) }, errs.ErrConflict},
+		{"update missing", func() error { return dynamodb.New(failing{}, "users").Update(ctx, user) }, errs.ErrNotFound},
+		{"update stale", func() error { return dynamodb.New(failing{item: stale}, "users").Update(ctx, user) }, errs.ErrVersionConflict},
+		{"delete missing", func() error { return dynamodb.New(failing{}, "users").Delete(ctx, "ada") }, errs.ErrNotFound},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// localClient connects to the dynamodb-local or localstack named by
+// DYNAMODB_ENDPOINT and skips the test without one:
+//
+//	docker run -p 8000:8000 amazon/dynamodb-local
+//	DYNAMODB_ENDPOINT=http://localhost:8000 go test ./db/dynamodb
+func localClient(t *testing.T) *awsdynamodb.Client {
+	t.Helper()
+	endpoint := os.Getenv("DYNAMODB_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_ENDPOINT not set")
+	}
+	ctx := context.Background()
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion("us-east-1"),
+		// dynamodb-local accepts any static credentials
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("local", "local", "")),
+		config.WithHTTPClient(&http.Client{Timeout: 5 * time.Second}),
+	)
+	if err != nil {
+		t.Fatalf("LoadDefaultConfig: %v", err)
+	}
+	return awsdynamodb.NewFromConfig(cfg, func(o *awsdynamodb.Options) {
+		o.BaseEndpoint = aws.String(endpoint)
+	})
+}
+
+func TestLocal(t *testing.T) {
+	client := localClient(t)
+	ctx := context.Background()
+	// a table per run, dynamodb-local keeps them until it stops
+	table := fmt.Sprintf("users_%d", time.Now().UnixNano())
+	if err := dynamodb.CreateTable(ctx, client, table); err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+	t.Cleanup(func() {
+		client.DeleteTable(context.Background(), &awsdynamodb.DeleteTableInput{TableName: aws.String(table)})
+	})
+	store := dynamodb.New(client, table)
+
+	ada := &service.User{ID: "ada", Email: "ada@example.com", Name: "Ada", Version: 1}
+	if err := store.Insert(ctx, ada); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if err := store.Insert(ctx, ada); !errors.Is(err, errs.ErrConflict) {
+		t.Errorf("Insert twice: err = %v, want ErrConflict", err)
+	}
+	got, err := store.Get(ctx, "ada")
+	if err != nil || got.Email != ada.Email || got.Version != 1 {
+		t.Fatalf("Get = %+v, %v, want ada at version 1", got, err)
+	}
+	stale := *got
+	if err := store.Update(ctx, got); err != nil || got.Version != 2 {
+		t.Fatalf("Update: %v, Version %d, want 2", err, got.Version)
+	}
+	if err := store.Update(ctx, &stale); !errors.Is(err, errs.ErrVersionConflict) {
+		t.Errorf("stale Update: err = %v, want ErrVersionConflict", err)
+	}
+	if err := store.Delete(ctx, "ada"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(ctx, "ada"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Get after Delete: err = %v, want ErrNotFound", err)
+	}
+}
+
+// TestStore runs the storetest contract against dynamodb-local in a
+// container, skipped under -short or without Docker.
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	endpoint := containers.DynamoDB(t)
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion("us-east-1"),
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("local", "local", "")),
+	)
+	if err != nil {
+		t.Fatalf("LoadDefaultConfig: %v", err)
+	}
+	client := awsdynamodb.NewFromConfig(cfg, func(o *awsdynamodb.Options) {
+		o.BaseEndpoint = aws.String(endpoint)
+	})
+	if err := dynamodb.CreateTable(ctx, client, "users"); err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+	store := dynamodb.New(client, "users")
+	storetest.TestStore(t, func() service.UserStorer { return store })
+}
@@ -0,0 +1,323 @@
+// Package fake is a scriptable UserStorer for exercising the service without
+// a database.
+//
+// Calls reach an in-memory db.MemoryStore unless scripted otherwise: Queue
+// an answer for the next call to a method, FailWith an error for every call,
+// or slow every call down with WithLatency. Every call is recorded, check
+// them with the Assert helpers, which take a *testing.T or anything else
+// with Helper and Errorf:
+//
+//	store := fake.New()
+//	store.Queue("Get", fake.Return{Err: errs.ErrUnavailable})
+//	userService := service.NewUserService(store)
+//	...
+//	store.AssertCalled(t, "Insert", fake.Match(func(u *service.User) bool { return u.ID == "1" }))
+//	store.AssertNotCalled(t, "Delete")
+//
+// It implements UserFinder and UserLister but not Transactor, so the service
+// calls it directly and the log shows every call the service made.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// T is the part of *testing.T the assertions use.
+type T interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// Call is one recorded call. Args exclude the context, a *service.User
+// argument is copied as it was when the call was made.
+type Call struct {
+	Method string
+	Args   []any
+	Err    error
+}
+
+func (c Call) String() string {
+	args := make([]string, len(c.Args))
+	for i, a := range c.Args {
+		args[i] = fmt.Sprintf("%+v", a)
+	}
+	return c.Method + "(" + strings.Join(args, ", ") + ")"
+}
+
+// Return is a scripted answer. User is used by Get and GetByEmail, Users by
+// List, and Err by every method.
+type Return struct {
+	User  *service.User
+	Users []*service.User
+	Err   error
+}
+
+// Matcher matches one argument in an assertion in place of a literal value.
+type Matcher func(arg any) bool
+
+// Any matches every argument.
+var Any Matcher = func(any) bool { return true }
+
+// Match matches arguments of type T that fn accepts.
+func Match[T any](fn func(T) bool) Matcher {
+	return func(arg any) bool {
+		v, ok := arg.(T)
+		return ok && fn(v)
+	}
+}
+
+type Option func(*Store)
+
+// WithLatency delays every call by d, or until its context ends.
+func WithLatency(d time.Duration) Option {
+	return func(s *Store) {
+		s.latency = d
+	}
+}
+
+// WithBacking replaces the MemoryStore unscripted calls reach, e.g. with
+// one already seeded.
+func WithBacking(backing *db.MemoryStore) Option {
+	return func(s *Store) {
+		s.backing = backing
+	}
+}
+
+// Store is safe for concurrent use.
+type Store struct {
+	backing *db.MemoryStore
+	latency time.Duration
+
+	mu     sync.Mutex
+	calls  []Call
+	queued map[string][]Return
+	fail   map[string]error
+}
+
+func New(opts ...Option) *Store {
+	s := &Store{
+		backing: db.NewMemoryStore(),
+		queued:  make(map[string][]Return),
+		fail:    make(map[string]error),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Queue scripts the next call to method, queued answers are used in order
+// before falling through to the backing store again. A scripted call does
+// not touch the backing store, a queued Insert with no Err succeeds without
+// storing anything.
+func (s *Store) Queue(method string, r Return) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queued[method] = append(s.queued[method], r)
+}
+
+// FailWith makes every call to method fail with err, a nil err clears it.
+// Queued answers still come first.
+func (s *Store) FailWith(method string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		delete(s.fail, method)
+		return
+	}
+	s.fail[method] = err
+}
+
+// Calls returns every recorded call, in order.
+func (s *Store) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.calls)
+}
+
+// CallsTo returns the recorded calls to method, in order.
+func (s *Store) CallsTo(method string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var calls []Call
+	for _, c := range s.calls {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Reset forgets the recorded calls and the script, the backing store keeps
+// its data.
+func (s *Store) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = nil
+	clear(s.queued)
+	clear(s.fail)
+}
+
+// AssertCalled checks that method was called at least once with args, each
+// one a literal compared with reflect.DeepEqual or a Matcher. With no args
+// any call to method will do.
+func (s *Store) AssertCalled(t T, method string, args ...any) bool {
+	t.Helper()
+	calls := s.CallsTo(method)
+	for _, c := range calls {
+		if len(args) == 0 || matches(c.Args, args) {
+			return true
+		}
+	}
+	if len(calls) == 0 {
+		t.Errorf("fake: %s was not called", method)
+		return false
+	}
+	t.Errorf("fake: no %s call matched, got %s", method, calls)
+	return false
+}
+
+// AssertNotCalled checks that method was never called.
+func (s *Store) AssertNotCalled(t T, method string) bool {
+	t.Helper()
+	if calls := s.CallsTo(method); len(calls) > 0 {
+		t.Errorf("fake: %s should not have been called, got %s", method, calls)
+		return false
+	}
+	return true
+}
+
+// AssertCallCount checks that method was called exactly n times.
+func (s *Store) AssertCallCount(t T, method string, n int) bool {
+	t.Helper()
+	if calls := s.CallsTo(method); len(calls) != n {
+		t.Errorf("fake: %s called %d times, want %d", method, len(calls), n)
+		return false
+	}
+	return true
+}
+
+func matches(got, want []any) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range want {
+		if m, ok := want[i].(Matcher); ok {
+			if !m(got[i]) {
+				return false
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got[i], want[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// begin waits out the latency and takes the scripted answer for method, if
+// there is one.
+func (s *Store) begin(ctx context.Context, method string) (Return, bool) {
+	if s.latency > 0 {
+		timer := time.NewTimer(s.latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return Return{Err: ctx.Err()}, true
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if q := s.queued[method]; len(q) > 0 {
+		s.queued[method] = q[1:]
+		return q[0], true
+	}
+	if err, ok := s.fail[method]; ok {
+		return Return{Err: err}, true
+	}
+	return Return{}, false
+}
+
+func (s *Store) record(method string, err error, args ...any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, Call{Method: method, Args: args, Err: err})
+}
+
+// snapshot copies user so the log keeps what was passed, not what the
+// caller changed it to afterwards.
+func snapshot(user *service.User) *service.User {
+	if user == nil {
+		return nil
+	}
+	u := *user
+	return &u
+}
+
+func (s *Store) Insert(ctx context.Context, user *service.User) error {
+	arg := snapshot(user)
+	err := s.write(ctx, "Insert", func() error { return s.backing.Insert(ctx, user) })
+	s.record("Insert", err, arg)
+	return err
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*service.User, error) {
+	user, err := s.read(ctx, "Get", func() (*service.User, error) { return s.backing.Get(ctx, id) })
+	s.record("Get", err, id)
+	return user, err
+}
+
+func (s *Store) GetByEmail(ctx context.Context, email string) (*service.User, error) {
+	user, err := s.read(ctx, "GetByEmail", func() (*service.User, error) { return s.backing.GetByEmail(ctx, email) })
+	s.record("GetByEmail", err, email)
+	return user, err
+}
+
+func (s *Store) List(ctx context.Context, after string, limit int) ([]*service.User, error) {
+	var users []*service.User
+	var err error
+	if r, ok := s.begin(ctx, "List"); ok {
+		users, err = r.Users, r.Err
+	} else {
+		users, err = s.backing.List(ctx, after, limit)
+	}
+	s.record("List", err, after, limit)
+	return users, err
+}
+
+func (s *Store) Update(ctx context.Context, user *service.User) error {
+	arg := snapshot(user)
+	err := s.write(ctx, "Update", func() error { return s.backing.Update(ctx, user) })
+	s.record("Update", err, arg)
+	return err
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	err := s.write(ctx, "Delete", func() error { return s.backing.Delete(ctx, id) })
+	s.record("Delete", err, id)
+	return err
+}
+
+func (s *Store) read(ctx context.Context, method string, backing func() (*service.User, error)) (*service.User, error) {
+	if r, ok := s.begin(ctx, method); ok {
+		return r.User, r.Err
+	}
+	return backing()
+}
+
+func (s *Store) write(ctx context.Context, method string, backing func() error) error {
+	if r, ok := s.begin(ctx, method); ok {
+		return r.Err
+	}
+	return backing()
+}
@@ -0,0 +1,153 @@
+package fake_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/fake"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storetest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// seeded returns a fake holding user 1 with nothing recorded yet.
+func seeded(t *testing.T) (*fake.Store, *service.UserService) {
+	t.Helper()
+	store := fake.New()
+	users := service.NewUserService(store)
+	if err := users.CreateUser(context.Background(), &service.User{ID: "1", Email: "ada@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	store.Reset()
+	return store, users
+}
+
+func TestCreateRecordsInsert(t *testing.T) {
+	store := fake.New()
+	user := &service.User{ID: "1", Email: "ada@example.com"}
+	if err := service.NewUserService(store).CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	store.AssertCalled(t, "Insert", fake.Match(func(u *service.User) bool {
+		return u.ID == "1" && u.Version == 1 && !u.CreatedAt.IsZero()
+	}))
+	// the log keeps the argument as it was passed
+	user.Email = "changed@example.com"
+	store.AssertCalled(t, "Insert", fake.Match(func(u *service.User) bool { return u.Email == "ada@example.com" }))
+}
+
+func TestUpdateReadsBeforeWriting(t *testing.T) {
+	store, users := seeded(t)
+	if err := users.UpdateUser(context.Background(), &service.User{ID: "1", Email: "ada@lovelace.dev", Version: 1}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if calls := store.Calls(); len(calls) != 2 || calls[0].Method != "Get" || calls[1].Method != "Update" {
+		t.Errorf("calls = %v, want Get then Update", calls)
+	}
+	store.AssertCalled(t, "Get", "1")
+}
+
+func TestStaleVersionNeverWrites(t *testing.T) {
+	store, users := seeded(t)
+	err := users.UpdateUser(context.Background(), &service.User{ID: "1", Email: "ada@lovelace.dev", Version: 7})
+	if !errors.Is(err, errs.ErrVersionConflict) {
+		t.Errorf("UpdateUser: err = %v, want ErrVersionConflict", err)
+	}
+	store.AssertNotCalled(t, "Update")
+}
+
+func TestScripting(t *testing.T) {
+	ctx := context.Background()
+	t.Run("a queued answer is used once", func(t *testing.T) {
+		store, users := seeded(t)
+		store.Queue("Get", fake.Return{Err: errs.ErrUnavailable})
+		if _, err := users.RetrieveUser(ctx, "1"); !errors.Is(err, errs.ErrUnavailable) {
+			t.Errorf("first RetrieveUser: err = %v, want ErrUnavailable", err)
+		}
+		if _, err := users.RetrieveUser(ctx, "1"); err != nil {
+			t.Errorf("second RetrieveUser: %v", err)
+		}
+		store.AssertCallCount(t, "Get", 2)
+	})
+
+	t.Run("FailWith lasts until cleared", func(t *testing.T) {
+		store := fake.New()
+		users := service.NewUserService(store)
+		store.FailWith("Insert", errs.ErrConflict)
+		for _, id := range []string{"1", "2"} {
+			if err := users.CreateUser(ctx, &service.User{ID: id, Email: id + "@example.com"}); !errors.Is(err, errs.ErrConflict) {
+				t.Errorf("CreateUser(%s): err = %v, want ErrConflict", id, err)
+			}
+		}
+		store.FailWith("Insert", nil)
+		if err := users.CreateUser(ctx, &service.User{ID: "3", Email: "3@example.com"}); err != nil {
+			t.Errorf("CreateUser(3): %v", err)
+		}
+		if got := store.CallsTo("Insert"); len(got) != 3 || got[0].Err == nil || got[2].Err != nil {
+			t.Errorf("Insert calls = %v, want two failures and a success", got)
+		}
+	})
+
+	t.Run("latency runs into the caller's deadline", func(t *testing.T) {
+		store := fake.New(fake.WithLatency(time.Minute))
+		short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		if _, err := service.NewUserService(store).RetrieveUser(short, "1"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("RetrieveUser: err = %v, want context.DeadlineExceeded", err)
+		}
+	})
+}
+
+// recordingT collects the failures an assertion reports.
+type recordingT struct {
+	errors []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+// TestAssertionsFail checks each assertion reports a failure, and says
+// what it did get.
+func TestAssertionsFail(t *testing.T) {
+	store, users := seeded(t)
+	users.RetrieveUser(context.Background(), "1")
+	for _, tt := range []struct {
+		name   string
+		assert func(fake.T) bool
+		want   string
+	}{
+		{"never called", func(t fake.T) bool { return store.AssertCalled(t, "Delete", "1") }, "Delete was not called"},
+		{"other arguments", func(t fake.T) bool { return store.AssertCalled(t, "Get", "2") }, "got [Get(1)]"},
+		{"a matcher rejects", func(t fake.T) bool {
+			return store.AssertCalled(t, "Get", fake.Match(func(id int) bool { return true }))
+		}, "no Get call matched"},
+		{"called", func(t fake.T) bool { return store.AssertNotCalled(t, "Get") }, "should not have been called"},
+		{"count", func(t fake.T) bool { return store.AssertCallCount(t, "Get", 2) }, "called 1 times, want 2"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := &recordingT{}
+			if tt.assert(rt) {
+				t.Error("the assertion passed")
+			}
+			if len(rt.errors) != 1 || !strings.Contains(rt.errors[0], tt.want) {
+				t.Errorf("reported %q, want one failure with %q", rt.errors, tt.want)
+			}
+		})
+	}
+	if !store.AssertCalled(t, "Get", fake.Any) {
+		t.Error("AssertCalled with Any failed")
+	}
+}
+
+// TestStore checks an unscripted fake behaves like a real store, so a
+// service test against it tests the service and not the fake.
+func TestStore(t *testing.T) {
+	storetest.TestStore(t, func() service.UserStorer { return fake.New() })
+}
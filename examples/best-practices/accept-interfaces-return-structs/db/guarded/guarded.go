@@ -0,0 +1,203 @@
+package guarded
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/bloom"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/outbox"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/query"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Store decorates a UserStorer with a Bloom filter of every ID it holds,
+// and answers a Get for an ID the filter has never seen with ErrNotFound
+// without asking the store. It pays where most lookups miss: probes for
+// IDs that were never issued, a cache in front whose misses are mostly for
+// users that do not exist, an import checking each row for a duplicate.
+//
+// An ID goes into the filter before the insert that creates it, so no Get
+// finds the user in the store but not in the filter. A failed insert
+// leaves its ID in, so a later Get for it goes to the store, as every
+// deleted user's does: a Bloom filter cannot forget.
+//
+// The filter only learns of inserts made through the Store. It is for a
+// store this process alone writes to, one written by others as well would
+// answer not found for users they created.
+type Store struct {
+	next   service.UserStorer
+	filter *bloom.Filter[string]
+	stats  *counters
+}
+
+type counters struct {
+	gets, rejected atomic.Uint64
+}
+
+// Option configures a Store.
+type Option func(*options)
+
+type options struct {
+	expected int
+	rate     float64
+}
+
+// WithExpected sizes the filter for n users, the default is 100000. Past n
+// the false positive rate climbs and more misses reach the store.
+func WithExpected(n int) Option {
+	return func(o *options) {
+		o.expected = n
+	}
+}
+
+// WithFalsePositiveRate is the share of misses the filter lets through to
+// the store, the default is 0.01. Each halving costs about 1.4 more bits a
+// user.
+func WithFalsePositiveRate(p float64) Option {
+	return func(o *options) {
+		o.rate = p
+	}
+}
+
+// pageSize is how many users New reads per List call to fill the filter.
+const pageSize = 1000
+
+// New wraps next, first reading every ID it already holds into the filter,
+// for which next must be a service.UserLister.
+func New(ctx context.Context, next service.UserStorer, opts ...Option) (*Store, error) {
+	o := options{expected: 100_000, rate: 0.01}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.expected <= 0 || o.rate <= 0 || o.rate >= 1 {
+		return nil, errs.Wrap("guarded.New", fmt.Errorf("expected %d, false positive rate %g: %w", o.expected, o.rate, errs.ErrInvalidInput))
+	}
+	lister, ok := next.(service.UserLister)
+	if !ok {
+		return nil, errs.Wrap("guarded.New", errors.ErrUnsupported)
+	}
+	s := &Store{next: next, filter: bloom.New[string](o.expected, o.rate), stats: &counters{}}
+	after := ""
+	for {
+		users, err := lister.List(ctx, after, pageSize)
+		if err != nil {
+			return nil, errs.Wrap("guarded.New", err)
+		}
+		for _, user := range users {
+			s.filter.Add(user.ID)
+		}
+		if len(users) < pageSize {
+			return s, nil
+		}
+		after = users[len(users)-1].ID
+	}
+}
+
+// Stats is what the filter has saved the store.
+type Stats struct {
+	// Gets counts Get calls, Rejected those answered without the store.
+	Gets, Rejected uint64
+	// FalsePositiveRate is the filter's estimate of the misses it lets
+	// through now.
+	FalsePositiveRate float64
+}
+
+func (s *Store) Stats() Stats {
+	return Stats{
+		Gets:              s.stats.gets.Load(),
+		Rejected:          s.stats.rejected.Load(),
+		FalsePositiveRate: s.filter.FalsePositiveRate(),
+	}
+}
+
+func (s *Store) Insert(ctx context.Context, user *service.User) error {
+	s.filter.Add(user.ID)
+	return s.next.Insert(ctx, user)
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*service.User, error) {
+	s.stats.gets.Add(1)
+	if !s.filter.Test(id) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		s.stats.rejected.Add(1)
+		return nil, errs.Wrap("guarded.Store.Get", errs.ErrNotFound)
+	}
+	return s.next.Get(ctx, id)
+}
+
+// GetByEmail is not guarded, the filter holds IDs only.
+func (s *Store) GetByEmail(ctx context.Context, email string) (*service.User, error) {
+	finder, ok := s.next.(service.UserFinder)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return finder.GetByEmail(ctx, email)
+}
+
+func (s *Store) List(ctx context.Context, after string, limit int) ([]*service.User, error) {
+	lister, ok := s.next.(service.UserLister)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return lister.List(ctx, after, limit)
+}
+
+func (s *Store) Query(ctx context.Context, q query.Query) ([]*service.User, error) {
+	querier, ok := s.next.(service.UserQuerier)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return querier.Query(ctx, q)
+}
+
+func (s *Store) Update(ctx context.Context, user *service.User) error {
+	return s.next.Update(ctx, user)
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	return s.next.Delete(ctx, id)
+}
+
+// InsertMany adds every ID to the filter, then forwards to the wrapped
+// store's bulk insert when it has one.
+func (s *Store) InsertMany(ctx context.Context, users []*service.User) []error {
+	b, ok := s.next.(service.BatchInserter)
+	if !ok {
+		results := make([]error, len(users))
+		for i, user := range users {
+			results[i] = s.Insert(ctx, user)
+		}
+		return results
+	}
+	for _, user := range users {
+		s.filter.Add(user.ID)
+	}
+	return b.InsertMany(ctx, users)
+}
+
+// AppendOutbox forwards to the wrapped store when it is a service.OutboxAppender.
+func (s *Store) AppendOutbox(ctx context.Context, msg outbox.Message) error {
+	appender, ok := s.next.(service.OutboxAppender)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	return appender.AppendOutbox(ctx, msg)
+}
+
+// WithinTx forwards to the wrapped store when it is a service.Transactor,
+// guarding the transaction's store with the same filter, so users created
+// in it are added. A rolled back one leaves their IDs in.
+func (s *Store) WithinTx(ctx context.Context, fn func(service.UserStorer) error) error {
+	tx, ok := s.next.(service.Transactor)
+	if !ok {
+		return fn(s)
+	}
+	return tx.WithinTx(ctx, func(inner service.UserStorer) error {
+		return fn(&Store{next: inner, filter: s.filter, stats: s.stats})
+	})
+}
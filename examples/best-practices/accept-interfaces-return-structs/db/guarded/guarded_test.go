@@ -0,0 +1,155 @@
+package guarded_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/guarded"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storetest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// backend counts the Gets that reach it and gives each a fixed wait, like
+// a database round trip. The wait spins rather than sleeps, a sleep this
+// short is rounded up to the scheduler's tick on many machines.
+type backend struct {
+	*db.MemoryStore
+	delay time.Duration
+	gets  atomic.Int64
+}
+
+func (b *backend) Get(ctx context.Context, id string) (*service.User, error) {
+	b.gets.Add(1)
+	for start := time.Now(); time.Since(start) < b.delay; {
+	}
+	return b.MemoryStore.Get(ctx, id)
+}
+
+// seeded returns a backend holding user-0..user-(n-1).
+func seeded(tb testing.TB, n int, delay time.Duration) *backend {
+	tb.Helper()
+	b := &backend{MemoryStore: db.NewMemoryStore(), delay: delay}
+	for i := range n {
+		id := "user-" + strconv.Itoa(i)
+		if err := b.MemoryStore.Insert(context.Background(), &service.User{ID: id, Email: id + "@example.com", Version: 1}); err != nil {
+			tb.Fatalf("Insert: %v", err)
+		}
+	}
+	return b
+}
+
+// TestStore holds the filter to the contract: a user inserted through it is
+// never reported missing.
+func TestStore(t *testing.T) {
+	storetest.TestStore(t, func() service.UserStorer {
+		store, err := guarded.New(t.Context(), db.NewMemoryStore(), guarded.WithExpected(1000))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		return store
+	})
+}
+
+// TestDefiniteMiss checks users New loaded are found and a miss the filter
+// is sure of never reaches the backend.
+func TestDefiniteMiss(t *testing.T) {
+	ctx := t.Context()
+	b := seeded(t, 1_000, 0)
+	store, err := guarded.New(ctx, b, guarded.WithExpected(1_000))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for i := range 1_000 {
+		if _, err := store.Get(ctx, "user-"+strconv.Itoa(i)); err != nil {
+			t.Fatalf("Get(user-%d), loaded by New: %v", i, err)
+		}
+	}
+	for i := range 10_000 {
+		if _, err := store.Get(ctx, "nobody-"+strconv.Itoa(i)); !errors.Is(err, errs.ErrNotFound) {
+			t.Fatalf("Get(nobody-%d): err = %v, want ErrNotFound", i, err)
+		}
+	}
+	stats := store.Stats()
+	passed := b.gets.Load() - 1_000
+	if passed > 200 || stats.Rejected+uint64(passed) != 10_000 {
+		t.Errorf("%d of 10000 misses reached the store and %d were rejected, want about 1%% through", passed, stats.Rejected)
+	}
+}
+
+// TestThroughService checks users created through the service are found,
+// including the new ID RenameUser inserts inside the store's transaction.
+func TestThroughService(t *testing.T) {
+	ctx := t.Context()
+	store, err := guarded.New(ctx, db.NewMemoryStore())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	users := service.NewUserService(store)
+	if err := users.CreateUser(ctx, &service.User{ID: "ada", Email: "ada@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if err := users.RenameUser(ctx, "ada", "ada-lovelace"); err != nil {
+		t.Fatalf("RenameUser: %v", err)
+	}
+	if _, err := users.RetrieveUser(ctx, "ada-lovelace"); err != nil {
+		t.Errorf("RetrieveUser(ada-lovelace): %v", err)
+	}
+	if _, err := users.RetrieveUser(ctx, "ada"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("RetrieveUser(ada): err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestNeedsLister(t *testing.T) {
+	type getOnly struct{ service.UserStorer }
+	if _, err := guarded.New(t.Context(), getOnly{db.NewMemoryStore()}); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("New: err = %v, want ErrUnsupported", err)
+	}
+}
+
+// BenchmarkGet runs Gets at several shares of misses against a plain and a
+// guarded backend whose every call waits 50µs: the guard's saving is every
+// miss it answers itself, its cost the hashing on every hit.
+func BenchmarkGet(b *testing.B) {
+	const users = 10_000
+	for _, misses := range []int{0, 50, 90, 99} {
+		ids := make([]string, 1_000)
+		for i := range ids {
+			if i%100 < misses {
+				ids[i] = "nobody-" + strconv.Itoa(i)
+			} else {
+				ids[i] = "user-" + strconv.Itoa(i)
+			}
+		}
+		for _, guard := range []bool{false, true} {
+			name := "misses-" + strconv.Itoa(misses) + "/plain"
+			if guard {
+				name = "misses-" + strconv.Itoa(misses) + "/guarded"
+			}
+			b.Run(name, func(b *testing.B) {
+				ctx := b.Context()
+				back := seeded(b, users, 50*time.Microsecond)
+				var store service.UserStorer = back
+				if guard {
+					g, err := guarded.New(ctx, back)
+					if err != nil {
+						b.Fatalf("New: %v", err)
+					}
+					store = g
+				}
+				back.gets.Store(0)
+				i := 0
+				for b.Loop() {
+					store.Get(ctx, ids[i%len(ids)])
+					i++
+				}
+				b.ReportMetric(float64(back.gets.Load())/float64(b.N), "gets/op")
+			})
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package hedged
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/hedge"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/outbox"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/query"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Store decorates a UserStorer so slow reads are sent a second time and the
+// faster answer wins. Writes go straight through: a hedged Insert would
+// conflict with itself and a hedged Update would lose its version check to
+// its own twin.
+//
+// Put it inside db/limited, limited(hedged(store)) would let the extra
+// reads past the limit, and outside db/retry so a read that is retrying is
+// not also hedged.
+type Store struct {
+	next   service.UserStorer
+	hedger *hedge.Hedger
+}
+
+func New(next service.UserStorer, hedger *hedge.Hedger) *Store {
+	return &Store{
+		next:   next,
+		hedger: hedger,
+	}
+}
+
+func (s *Store) Insert(ctx context.Context, user *service.User) error {
+	return s.next.Insert(ctx, user)
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*service.User, error) {
+	return hedge.Do(ctx, s.hedger, func(ctx context.Context) (*service.User, error) {
+		return s.next.Get(ctx, id)
+	})
+}
+
+func (s *Store) GetByEmail(ctx context.Context, email string) (*service.User, error) {
+	finder, ok := s.next.(service.UserFinder)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return hedge.Do(ctx, s.hedger, func(ctx context.Context) (*service.User, error) {
+		return finder.GetByEmail(ctx, email)
+	})
+}
+
+func (s *Store) List(ctx context.Context, after string, limit int) ([]*service.User, error) {
+	lister, ok := s.next.(service.UserLister)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return hedge.Do(ctx, s.hedger, func(ctx context.Context) ([]*service.User, error) {
+		return lister.List(ctx, after, limit)
+	})
+}
+
+func (s *Store) Query(ctx context.Context, q query.Query) ([]*service.User, error) {
+	querier, ok := s.next.(service.UserQuerier)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return hedge.Do(ctx, s.hedger, func(ctx context.Context) ([]*service.User, error) {
+		return querier.Query(ctx, q)
+	})
+}
+
+func (s *Store) Update(ctx context.Context, user *service.User) error {
+	return s.next.Update(ctx, user)
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	return s.next.Delete(ctx, id)
+}
+
+// InsertMany forwards to the wrapped store's bulk insert when it has one,
+// unhedged like Insert.
+func (s *Store) InsertMany(ctx context.Context, users []*service.User) []error {
+	b, ok := s.next.(service.BatchInserter)
+	if !ok {
+		results := make([]error, len(users))
+		for i, user := range users {
+			results[i] = s.next.Insert(ctx, user)
+		}
+		return results
+	}
+	return b.InsertMany(ctx, users)
+}
+
+// AppendOutbox forwards to the wrapped store when it is a
+// service.OutboxAppender. Sent twice the message would be relayed twice.
+func (s *Store) AppendOutbox(ctx context.Context, msg outbox.Message) error {
+	appender, ok := s.next.(service.OutboxAppender)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	return appender.AppendOutbox(ctx, msg)
+}
+
+// WithinTx forwards to the wrapped store when it is a service.Transactor.
+// fn gets the transaction's store undecorated: its calls share one
+// connection, so a hedged read would only queue behind its twin.
+func (s *Store) WithinTx(ctx context.Context, fn func(service.UserStorer) error) error {
+	tx, ok := s.next.(service.Transactor)
+	if !ok {
+		return fn(s)
+	}
+	return tx.WithinTx(ctx, fn)
+}
@@ -0,0 +1,44 @@
+package hedged_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/hedged"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storetest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/hedge"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/outbox"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+func TestStore(t *testing.T) {
+	storetest.TestStore(t, func() service.UserStorer {
+		return hedged.New(db.NewMemoryStore(), hedge.New(time.Millisecond))
+	})
+}
+
+// TestForwards checks the optional interfaces reach the wrapped store, an
+// outbox message appended in a transaction included.
+func TestForwards(t *testing.T) {
+	ctx := t.Context()
+	memory := db.NewMemoryStore()
+	store := hedged.New(memory, hedge.New(time.Millisecond))
+	users := []*service.User{{ID: "ada", Email: "ada@example.com"}, {ID: "ada", Email: "ada@example.com"}}
+	if results := store.InsertMany(ctx, users); results[0] != nil || !errors.Is(results[1], errs.ErrConflict) {
+		t.Errorf("InsertMany = %v, want the second a conflict", results)
+	}
+	if err := store.WithinTx(ctx, func(tx service.UserStorer) error {
+		return tx.(service.OutboxAppender).AppendOutbox(ctx, outbox.Message{Topic: "users"})
+	}); err != nil {
+		t.Fatalf("WithinTx: %v", err)
+	}
+	if err := store.AppendOutbox(ctx, outbox.Message{Topic: "users"}); err != nil {
+		t.Fatalf("AppendOutbox: %v", err)
+	}
+	if pending, err := memory.PendingOutbox(ctx, 10); err != nil || len(pending) != 2 {
+		t.Errorf("PendingOutbox = %d messages, %v, want 2", len(pending), err)
+	}
+}
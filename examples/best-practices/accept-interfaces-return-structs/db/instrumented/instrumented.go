@@ -0,0 +1,131 @@
+package instrumented
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/outbox"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Store decorates a UserStorer with per-operation call counters and latency
+// histograms. Metrics are labeled by op (insert, get, update, delete) and
+// result (ok, error).
+type Store struct {
+	next     service.UserStorer
+	calls    *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// New registers the store's collectors on reg, pass prometheus.DefaultRegisterer
+// to expose them through promhttp.Handler.
+func New(next service.UserStorer, reg prometheus.Registerer) (*Store, error) {
+	s := &Store{
+		next: next,
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "users",
+			Subsystem: "store",
+			Name:      "calls_total",
+			Help:      "Store operations by op and result.",
+		}, []string{"op", "result"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "users",
+			Subsystem: "store",
+			Name:      "duration_seconds",
+			Help:      "Store operation latency by op.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"op"}),
+	}
+	for _, c := range []prometheus.Collector{s.calls, s.duration} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *Store) Insert(ctx context.Context, user *service.User) error {
+	start := time.Now()
+	err := s.next.Insert(ctx, user)
+	s.observe("insert", start, err)
+	return err
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*service.User, error) {
+	start := time.Now()
+	user, err := s.next.Get(ctx, id)
+	s.observe("get", start, err)
+	return user, err
+}
+
+func (s *Store) GetByEmail(ctx context.Context, email string) (*service.User, error) {
+	finder, ok := s.next.(service.UserFinder)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	start := time.Now()
+	user, err := finder.GetByEmail(ctx, email)
+	s.observe("get_by_email", start, err)
+	return user, err
+}
+
+func (s *Store) List(ctx context.Context, after string, limit int) ([]*service.User, error) {
+	lister, ok := s.next.(service.UserLister)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	start := time.Now()
+	users, err := lister.List(ctx, after, limit)
+	s.observe("list", start, err)
+	return users, err
+}
+
+func (s *Store) Update(ctx context.Context, user *service.User) error {
+	start := time.Now()
+	err := s.next.Update(ctx, user)
+	s.observe("update", start, err)
+	return err
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	start := time.Now()
+	err := s.next.Delete(ctx, id)
+	s.observe("delete", start, err)
+	return err
+}
+
+// AppendOutbox forwards to the wrapped store when it is a service.OutboxAppender.
+func (s *Store) AppendOutbox(ctx context.Context, msg outbox.Message) error {
+	appender, ok := s.next.(service.OutboxAppender)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	start := time.Now()
+	err := appender.AppendOutbox(ctx, msg)
+	s.observe("append_outbox", start, err)
+	return err
+}
+
+// WithinTx forwards to the wrapped store when it is a service.Transactor,
+// recording the calls made through the transaction on the same collectors.
+func (s *Store) WithinTx(ctx context.Context, fn func(service.UserStorer) error) error {
+	tx, ok := s.next.(service.Transactor)
+	if !ok {
+		return fn(s)
+	}
+	return tx.WithinTx(ctx, func(inner service.UserStorer) error {
+		return fn(&Store{next: inner, calls: s.calls, duration: s.duration})
+	})
+}
+
+func (s *Store) observe(op string, start time.Time, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	s.calls.WithLabelValues(op, result).Inc()
+	s.duration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+}
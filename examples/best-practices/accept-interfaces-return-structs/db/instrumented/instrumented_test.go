@@ -0,0 +1,33 @@
+package instrumented_test
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/instrumented"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storebench"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storetest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+func TestStore(t *testing.T) {
+	storetest.TestStore(t, func() service.UserStorer {
+		store, err := instrumented.New(db.NewMemoryStore(), prometheus.NewRegistry())
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		return store
+	})
+}
+
+func BenchmarkStore(b *testing.B) {
+	storebench.BenchmarkStore(b, func(b *testing.B) service.UserStorer {
+		store, err := instrumented.New(db.NewMemoryStore(), prometheus.NewRegistry())
+		if err != nil {
+			b.Fatalf("New: %v", err)
+		}
+		return store
+	})
+}
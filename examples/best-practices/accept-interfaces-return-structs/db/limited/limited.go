@@ -0,0 +1,144 @@
+package limited
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Limiter hands out weighted permits. *semaphore.Weighted from
+// golang.org/x/sync/semaphore satisfies it, as does *Chan.
+type Limiter interface {
+	Acquire(ctx context.Context, n int64) error
+	Release(n int64)
+}
+
+// listWeight is what a List costs against the limit: it scans a page of
+// rows where the other calls touch one.
+const listWeight = 4
+
+// Store decorates a UserStorer so at most the limiter's capacity worth of
+// calls run against it at once, callers beyond that wait their turn or give
+// up with their context. Size it to the database's connection pool so the
+// queue forms here, where it is cheap, and not inside the driver.
+type Store struct {
+	next    service.UserStorer
+	limiter Limiter
+}
+
+func New(next service.UserStorer, limiter Limiter) *Store {
+	return &Store{
+		next:    next,
+		limiter: limiter,
+	}
+}
+
+func (s *Store) Insert(ctx context.Context, user *service.User) error {
+	return s.do(ctx, 1, func() error {
+		return s.next.Insert(ctx, user)
+	})
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*service.User, error) {
+	var user *service.User
+	err := s.do(ctx, 1, func() error {
+		var err error
+		user, err = s.next.Get(ctx, id)
+		return err
+	})
+	return user, err
+}
+
+func (s *Store) GetByEmail(ctx context.Context, email string) (*service.User, error) {
+	finder, ok := s.next.(service.UserFinder)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	var user *service.User
+	err := s.do(ctx, 1, func() error {
+		var err error
+		user, err = finder.GetByEmail(ctx, email)
+		return err
+	})
+	return user, err
+}
+
+func (s *Store) List(ctx context.Context, after string, limit int) ([]*service.User, error) {
+	lister, ok := s.next.(service.UserLister)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	var users []*service.User
+	err := s.do(ctx, listWeight, func() error {
+		var err error
+		users, err = lister.List(ctx, after, limit)
+		return err
+	})
+	return users, err
+}
+
+func (s *Store) Update(ctx context.Context, user *service.User) error {
+	return s.do(ctx, 1, func() error {
+		return s.next.Update(ctx, user)
+	})
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	return s.do(ctx, 1, func() error {
+		return s.next.Delete(ctx, id)
+	})
+}
+
+func (s *Store) do(ctx context.Context, weight int64, fn func() error) error {
+	if err := s.limiter.Acquire(ctx, weight); err != nil {
+		return err
+	}
+	defer s.limiter.Release(weight)
+	return fn()
+}
+
+// Chan is the hand-rolled semaphore: a buffered channel holding one token
+// per permit. It is what x/sync/semaphore replaces, BenchmarkLimited
+// compares the two.
+//
+// A weighted Acquire takes its tokens one at a time under a mutex, so two
+// heavy callers cannot each grab half the tokens and wait on each other
+// forever. Unlike semaphore.Weighted it is not fair: a steady stream of
+// weight 1 callers can keep a heavy one waiting.
+type Chan struct {
+	tokens chan struct{}
+	heavy  sync.Mutex
+}
+
+func NewChan(n int) *Chan {
+	return &Chan{tokens: make(chan struct{}, n)}
+}
+
+func (c *Chan) Acquire(ctx context.Context, n int64) error {
+	if n > int64(cap(c.tokens)) {
+		// could never be satisfied, fail like semaphore.Weighted does
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if n > 1 {
+		c.heavy.Lock()
+		defer c.heavy.Unlock()
+	}
+	for i := int64(0); i < n; i++ {
+		select {
+		case c.tokens <- struct{}{}:
+		case <-ctx.Done():
+			c.Release(i)
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (c *Chan) Release(n int64) {
+	for i := int64(0); i < n; i++ {
+		<-c.tokens
+	}
+}
@@ -0,0 +1,101 @@
+package limited_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/limited"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storetest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// TestStore runs the contract, its concurrent checks included, through a
+// limit small enough that callers queue.
+func TestStore(t *testing.T) {
+	storetest.TestStore(t, func() service.UserStorer {
+		return limited.New(db.NewMemoryStore(), limited.NewChan(4))
+	})
+}
+
+// slowStore gives every call a fixed service time, like a database round
+// trip, so the limit decides throughput.
+type slowStore struct {
+	*db.MemoryStore
+}
+
+func (s slowStore) Get(ctx context.Context, id string) (*service.User, error) {
+	time.Sleep(time.Millisecond)
+	return s.MemoryStore.Get(ctx, id)
+}
+
+func (s slowStore) List(ctx context.Context, after string, limit int) ([]*service.User, error) {
+	time.Sleep(4 * time.Millisecond)
+	return s.MemoryStore.List(ctx, after, limit)
+}
+
+// BenchmarkLimited drives 64 clients through the limited store at several
+// limits with both semaphores, one call in ten a List. Higher limits buy
+// throughput until the backend saturates, lower ones protect it and queue
+// callers instead, visible in the p99. The capacity is four permits per
+// slot so a List, which weighs 4, takes a whole slot.
+//
+//	go test ./db/limited -run '^$' -bench Limited
+func BenchmarkLimited(b *testing.B) {
+	const clients = 64
+	for _, impl := range []string{"xsync", "chan"} {
+		for _, limit := range []int{1, 4, 16, 64} {
+			b.Run(fmt.Sprintf("%s/limit=%d", impl, limit), func(b *testing.B) {
+				var limiter limited.Limiter = semaphore.NewWeighted(int64(limit) * 4)
+				if impl == "chan" {
+					limiter = limited.NewChan(limit * 4)
+				}
+				ctx := b.Context()
+				userService := service.NewUserService(limited.New(slowStore{db.NewMemoryStore()}, limiter))
+				for i := range 100 {
+					id := strconv.Itoa(i)
+					userService.CreateUser(ctx, &service.User{ID: id, Email: id + "@example.com"})
+				}
+
+				var next atomic.Int64
+				latencies := make([]time.Duration, b.N)
+				b.ResetTimer()
+				var wg sync.WaitGroup
+				for range clients {
+					wg.Go(func() {
+						for i := int(next.Add(1)) - 1; i < b.N; i = int(next.Add(1)) - 1 {
+							start := time.Now()
+							var err error
+							if i%10 == 0 {
+								_, err = userService.ListUsers(ctx, service.PageRequest{Limit: 20})
+							} else {
+								_, err = userService.RetrieveUser(ctx, strconv.Itoa(i%100))
+							}
+							if err != nil {
+								b.Error(err)
+								return
+							}
+							latencies[i] = time.Since(start)
+						}
+					})
+				}
+				wg.Wait()
+				b.StopTimer()
+				slices.Sort(latencies)
+				pct := func(p float64) float64 {
+					return float64(latencies[int(p*float64(len(latencies)-1))]) / float64(time.Millisecond)
+				}
+				b.ReportMetric(pct(0.50), "p50-ms")
+				b.ReportMetric(pct(0.99), "p99-ms")
+			})
+		}
+	}
+}
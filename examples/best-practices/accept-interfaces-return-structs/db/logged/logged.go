@@ -0,0 +1,153 @@
+package logged
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/outbox"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/query"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Store decorates a UserStorer, logging one structured line per call with the
+// operation, duration, and error. Calls log with the caller's context so a
+// logging.New logger adds the request ID. Successful calls log at Debug,
+// failures at Error.
+type Store struct {
+	next   service.UserStorer
+	logger *slog.Logger
+}
+
+func New(next service.UserStorer, logger *slog.Logger) *Store {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Store{
+		next:   next,
+		logger: logger,
+	}
+}
+
+func (s *Store) Insert(ctx context.Context, user *service.User) error {
+	start := time.Now()
+	err := s.next.Insert(ctx, user)
+	s.log(ctx, "insert", user.ID, start, err)
+	return err
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*service.User, error) {
+	start := time.Now()
+	user, err := s.next.Get(ctx, id)
+	s.log(ctx, "get", id, start, err)
+	return user, err
+}
+
+func (s *Store) GetByEmail(ctx context.Context, email string) (*service.User, error) {
+	finder, ok := s.next.(service.UserFinder)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	start := time.Now()
+	user, err := finder.GetByEmail(ctx, email)
+	id := ""
+	if user != nil {
+		id = user.ID
+	}
+	s.log(ctx, "get_by_email", id, start, err)
+	return user, err
+}
+
+func (s *Store) List(ctx context.Context, after string, limit int) ([]*service.User, error) {
+	lister, ok := s.next.(service.UserLister)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	start := time.Now()
+	users, err := lister.List(ctx, after, limit)
+	s.log(ctx, "list", after, start, err)
+	return users, err
+}
+
+func (s *Store) Query(ctx context.Context, q query.Query) ([]*service.User, error) {
+	querier, ok := s.next.(service.UserQuerier)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	start := time.Now()
+	users, err := querier.Query(ctx, q)
+	s.log(ctx, "query", "", start, err)
+	return users, err
+}
+
+func (s *Store) Update(ctx context.Context, user *service.User) error {
+	start := time.Now()
+	err := s.next.Update(ctx, user)
+	s.log(ctx, "update", user.ID, start, err)
+	return err
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	start := time.Now()
+	err := s.next.Delete(ctx, id)
+	s.log(ctx, "delete", id, start, err)
+	return err
+}
+
+// InsertMany forwards to the wrapped store's bulk insert when it has one,
+// logging each item the same way Insert does.
+func (s *Store) InsertMany(ctx context.Context, users []*service.User) []error {
+	b, ok := s.next.(service.BatchInserter)
+	if !ok {
+		results := make([]error, len(users))
+		for i, user := range users {
+			results[i] = s.Insert(ctx, user)
+		}
+		return results
+	}
+	start := time.Now()
+	results := b.InsertMany(ctx, users)
+	for i, user := range users {
+		s.log(ctx, "insert", user.ID, start, results[i])
+	}
+	return results
+}
+
+// AppendOutbox forwards to the wrapped store when it is a service.OutboxAppender.
+func (s *Store) AppendOutbox(ctx context.Context, msg outbox.Message) error {
+	appender, ok := s.next.(service.OutboxAppender)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	start := time.Now()
+	err := appender.AppendOutbox(ctx, msg)
+	s.log(ctx, "append_outbox", "", start, err)
+	return err
+}
+
+// WithinTx forwards to the wrapped store when it is a service.Transactor,
+// logging the calls made through the transaction as well.
+func (s *Store) WithinTx(ctx context.Context, fn func(service.UserStorer) error) error {
+	tx, ok := s.next.(service.Transactor)
+	if !ok {
+		return fn(s)
+	}
+	return tx.WithinTx(ctx, func(inner service.UserStorer) error {
+		return fn(New(inner, s.logger))
+	})
+}
+
+func (s *Store) log(ctx context.Context, op, id string, start time.Time, err error) {
+	attrs := []slog.Attr{
+		slog.String("op", op),
+		slog.String("user_id", id),
+		slog.Duration("duration", time.Since(start)),
+	}
+	level := slog.LevelDebug
+	if err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	s.logger.LogAttrs(ctx, level, "store", attrs...)
+}
@@ -0,0 +1,115 @@
+package logged_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/logged"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storebench"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storetest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/logging"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// capture returns a Debug logger writing JSON lines to buf, with the
+// request-scoped attributes logging.ContextHandler adds, and a function
+// that decodes what has been written so far.
+func capture(t *testing.T) (*slog.Logger, func() []map[string]any) {
+	var buf bytes.Buffer
+	handler := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	lines := func() []map[string]any {
+		t.Helper()
+		var out []map[string]any
+		for line := range strings.Lines(buf.String()) {
+			var m map[string]any
+			if err := json.Unmarshal([]byte(line), &m); err != nil {
+				t.Fatalf("log line %q: %v", line, err)
+			}
+			out = append(out, m)
+		}
+		return out
+	}
+	return slog.New(logging.NewContextHandler(handler)), lines
+}
+
+func TestAttributes(t *testing.T) {
+	logger, lines := capture(t)
+	store := logged.New(db.NewMemoryStore(), logger)
+	ctx := ctxutil.WithRequestID(context.Background(), "req-1")
+
+	if err := store.Insert(ctx, &service.User{ID: "ada", Email: "ada@example.com"}); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if _, err := store.Get(ctx, "nobody"); !errors.Is(err, errs.ErrNotFound) {
+		t.Fatalf("Get: err = %v, want ErrNotFound", err)
+	}
+
+	got := lines()
+	if len(got) != 2 {
+		t.Fatalf("%d lines, want 2: %v", len(got), got)
+	}
+	for i, want := range []struct {
+		level, op, id string
+		err           bool
+	}{
+		{"DEBUG", "insert", "ada", false},
+		{"ERROR", "get", "nobody", true},
+	} {
+		line := got[i]
+		if line["msg"] != "store" || line["level"] != want.level || line["op"] != want.op || line["user_id"] != want.id {
+			t.Errorf("line %d = %v, want %s %s of %s", i, line, want.level, want.op, want.id)
+		}
+		if line["request_id"] != "req-1" {
+			t.Errorf("line %d request_id = %v, want req-1", i, line["request_id"])
+		}
+		if _, ok := line["duration"].(float64); !ok {
+			t.Errorf("line %d has no duration: %v", i, line)
+		}
+		if _, ok := line["error"]; ok != want.err {
+			t.Errorf("line %d error = %v, want one: %t", i, line["error"], want.err)
+		}
+	}
+}
+
+// TestComposes checks the decorator forwards what the store it wraps can
+// do, a transaction's calls are logged too.
+func TestComposes(t *testing.T) {
+	logger, lines := capture(t)
+	users := service.NewUserService(logged.New(db.NewMemoryStore(), logger))
+	ctx := context.Background()
+	if err := users.CreateUser(ctx, &service.User{ID: "ada", Email: "ada@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if _, err := users.RetrieveUserByEmail(ctx, "ada@example.com"); err != nil {
+		t.Fatalf("RetrieveUserByEmail: %v", err)
+	}
+	if err := users.DeleteUser(ctx, "ada"); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	var ops []string
+	for _, line := range lines() {
+		ops = append(ops, line["op"].(string))
+	}
+	if want := "insert get_by_email get update"; strings.Join(ops, " ") != want {
+		t.Errorf("ops = %v, want %s", ops, want)
+	}
+}
+
+func TestStore(t *testing.T) {
+	logger, _ := capture(t)
+	storetest.TestStore(t, func() service.UserStorer { return logged.New(db.NewMemoryStore(), logger) })
+}
+
+func BenchmarkStore(b *testing.B) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	storebench.BenchmarkStore(b, func(*testing.B) service.UserStorer { return logged.New(db.NewMemoryStore(), logger) })
+}
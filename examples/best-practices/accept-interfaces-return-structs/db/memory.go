@@ -0,0 +1,304 @@
+package db
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"sync"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/outbox"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/query"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// MemoryStore is a concurrency safe, map backed UserStorer.
+// Users are copied on the way in and out so callers can never mutate
+// the stored record without going through Update. Every method checks the
+// context first so a canceled or expired request never touches the map.
+//
+// Each tenant has a keyspace of its own, picked by ctxutil.Tenant of every
+// call's context, so two tenants can hold the same ID or email and neither
+// can reach the other's users. Calls without a tenant use the keyspace of
+// the default tenant, "".
+//
+// emails is a secondary index from email to ID. It is only read and written
+// while holding mu, which is what makes the uniqueness check race free when
+// two creates for the same email arrive at once.
+//
+// outbox holds unsent messages in ID order, see outbox.Relay. It is shared
+// by every tenant, the relay publishes them all.
+type MemoryStore struct {
+	mu         sync.RWMutex
+	spaces     map[string]*keyspace
+	outbox     []outbox.Message
+	nextOutbox int64
+}
+
+// keyspace is one tenant's users and its email index.
+type keyspace struct {
+	users  map[string]service.User
+	emails map[string]string
+}
+
+func newKeyspace() *keyspace {
+	return &keyspace{
+		users:  make(map[string]service.User),
+		emails: make(map[string]string),
+	}
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		spaces: make(map[string]*keyspace),
+	}
+}
+
+// IsolatesTenants marks the store as one that keeps tenants apart, which
+// db/tenanted asks of the store it wraps.
+func (s *MemoryStore) IsolatesTenants() {}
+
+// space returns the keyspace of ctx's tenant, a new one when create is
+// set and the tenant has none yet. Without create it is an empty keyspace
+// that is not kept, fine to read and never written. mu must be held.
+func (s *MemoryStore) space(ctx context.Context, create bool) *keyspace {
+	tenant, _ := ctxutil.Tenant(ctx)
+	space, ok := s.spaces[tenant]
+	if !ok {
+		if !create {
+			return &keyspace{}
+		}
+		space = newKeyspace()
+		s.spaces[tenant] = space
+	}
+	return space
+}
+
+func (s *MemoryStore) Insert(ctx context.Context, user *service.User) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("db.MemoryStore.Insert", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return errs.Wrap("db.MemoryStore.Insert", s.space(ctx, true).insert(user))
+}
+
+// InsertMany inserts users under a single lock acquisition, a conflict on one
+// user does not stop the others from being stored.
+func (s *MemoryStore) InsertMany(ctx context.Context, users []*service.User) []error {
+	results := make([]error, len(users))
+	if err := ctx.Err(); err != nil {
+		for i := range results {
+			results[i] = errs.Wrap("db.MemoryStore.InsertMany", err)
+		}
+		return results
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	space := s.space(ctx, true)
+	for i, user := range users {
+		results[i] = errs.Wrap("db.MemoryStore.InsertMany", space.insert(user))
+	}
+	return results
+}
+
+func (space *keyspace) insert(user *service.User) error {
+	if _, ok := space.users[user.ID]; ok {
+		return errs.ErrConflict
+	}
+	if _, ok := space.emails[user.Email]; ok {
+		return errs.ErrConflict
+	}
+	space.users[user.ID] = *user
+	space.emails[user.Email] = user.ID
+	return nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id string) (*service.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Wrap("db.MemoryStore.Get", err)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	user, ok := s.space(ctx, false).users[id]
+	if !ok {
+		return nil, errs.Wrap("db.MemoryStore.Get", errs.ErrNotFound)
+	}
+	return &user, nil
+}
+
+func (s *MemoryStore) GetByEmail(ctx context.Context, email string) (*service.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Wrap("db.MemoryStore.GetByEmail", err)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	space := s.space(ctx, false)
+	id, ok := space.emails[email]
+	if !ok {
+		return nil, errs.Wrap("db.MemoryStore.GetByEmail", errs.ErrNotFound)
+	}
+	user := space.users[id]
+	return &user, nil
+}
+
+// List sorts the matching IDs on every call, fine for an example store but
+// O(n log n) per page.
+func (s *MemoryStore) List(ctx context.Context, after string, limit int) ([]*service.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Wrap("db.MemoryStore.List", err)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	space := s.space(ctx, false)
+	ids := make([]string, 0, len(space.users))
+	for id := range space.users {
+		if id > after {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	users := make([]*service.User, len(ids))
+	for i, id := range ids {
+		user := space.users[id]
+		users[i] = &user
+	}
+	return users, nil
+}
+
+// Query filters a copy of every user with query.Filter, the in-memory
+// compilation of the same query the SQL stores run as a statement.
+func (s *MemoryStore) Query(ctx context.Context, q query.Query) ([]*service.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Wrap("db.MemoryStore.Query", err)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	space := s.space(ctx, false)
+	users := make([]*service.User, 0, len(space.users))
+	for _, user := range space.users {
+		users = append(users, &user)
+	}
+	return query.Filter(q, users, (*service.User).Record), nil
+}
+
+func (s *MemoryStore) Update(ctx context.Context, user *service.User) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("db.MemoryStore.Update", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	space := s.space(ctx, false)
+	existing, ok := space.users[user.ID]
+	if !ok {
+		return errs.Wrap("db.MemoryStore.Update", errs.ErrNotFound)
+	}
+	if existing.Version != user.Version {
+		return errs.Wrap("db.MemoryStore.Update", errs.ErrVersionConflict)
+	}
+	if owner, ok := space.emails[user.Email]; ok && owner != user.ID {
+		return errs.Wrap("db.MemoryStore.Update", errs.ErrConflict)
+	}
+	delete(space.emails, existing.Email)
+	updated := *user
+	updated.CreatedAt = existing.CreatedAt
+	updated.Version++
+	space.users[user.ID] = updated
+	user.Version = updated.Version
+	space.emails[user.Email] = user.ID
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("db.MemoryStore.Delete", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	space := s.space(ctx, false)
+	user, ok := space.users[id]
+	if !ok {
+		return errs.Wrap("db.MemoryStore.Delete", errs.ErrNotFound)
+	}
+	delete(space.users, id)
+	delete(space.emails, user.Email)
+	return nil
+}
+
+// WithinTx runs fn against a staged copy of the store while holding the write
+// lock, so other callers are serialized behind the transaction. The staged
+// users replace the live ones only when fn returns nil.
+func (s *MemoryStore) WithinTx(ctx context.Context, fn func(service.UserStorer) error) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("db.MemoryStore.WithinTx", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	staged := NewMemoryStore()
+	for tenant, space := range s.spaces {
+		copied := newKeyspace()
+		maps.Copy(copied.users, space.users)
+		maps.Copy(copied.emails, space.emails)
+		staged.spaces[tenant] = copied
+	}
+	staged.outbox = append(staged.outbox, s.outbox...)
+	staged.nextOutbox = s.nextOutbox
+	if err := fn(staged); err != nil {
+		return err
+	}
+	s.spaces = staged.spaces
+	s.outbox = staged.outbox
+	s.nextOutbox = staged.nextOutbox
+	return nil
+}
+
+// AppendOutbox queues msg, called on the staged store inside WithinTx the
+// message only becomes visible to PendingOutbox once the transaction commits.
+func (s *MemoryStore) AppendOutbox(ctx context.Context, msg outbox.Message) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("db.MemoryStore.AppendOutbox", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextOutbox++
+	msg.ID = s.nextOutbox
+	msg.Payload = append([]byte(nil), msg.Payload...)
+	s.outbox = append(s.outbox, msg)
+	return nil
+}
+
+func (s *MemoryStore) PendingOutbox(ctx context.Context, limit int) ([]outbox.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Wrap("db.MemoryStore.PendingOutbox", err)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := min(limit, len(s.outbox))
+	return append([]outbox.Message(nil), s.outbox[:n]...), nil
+}
+
+// MarkOutboxSent drops the sent messages, the memory store keeps no history.
+func (s *MemoryStore) MarkOutboxSent(ctx context.Context, ids []int64) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("db.MemoryStore.MarkOutboxSent", err)
+	}
+	sent := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		sent[id] = true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.outbox[:0]
+	for _, msg := range s.outbox {
+		if !sent[msg.ID] {
+			kept = append(kept, msg)
+		}
+	}
+	s.outbox = kept
+	return nil
+}
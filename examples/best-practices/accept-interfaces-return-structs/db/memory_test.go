@@ -0,0 +1,144 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storebench"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storetest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// TestMemoryStoreContextDone checks that a context already done stops
+// every call before it touches the store, and that the error says why.
+func TestMemoryStoreContextDone(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	for _, tt := range []struct {
+		name string
+		ctx  context.Context
+		want error
+	}{
+		{"canceled", canceled, context.Canceled},
+		{"deadline exceeded", expired, context.DeadlineExceeded},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			store := db.NewMemoryStore()
+			if err := store.Insert(context.Background(), &service.User{ID: "ada", Email: "ada@example.com"}); err != nil {
+				t.Fatalf("Insert: %v", err)
+			}
+			if err := store.Insert(tt.ctx, &service.User{ID: "bob", Email: "bob@example.com"}); !errors.Is(err, tt.want) {
+				t.Errorf("Insert: err = %v, want %v", err, tt.want)
+			}
+			if _, err := store.Get(tt.ctx, "ada"); !errors.Is(err, tt.want) {
+				t.Errorf("Get: err = %v, want %v", err, tt.want)
+			}
+			if err := store.Update(tt.ctx, &service.User{ID: "ada", Email: "ada@example.com", Version: 1}); !errors.Is(err, tt.want) {
+				t.Errorf("Update: err = %v, want %v", err, tt.want)
+			}
+			if err := store.Delete(tt.ctx, "ada"); !errors.Is(err, tt.want) {
+				t.Errorf("Delete: err = %v, want %v", err, tt.want)
+			}
+			// nothing the canceled calls asked for happened
+			if _, err := store.Get(context.Background(), "bob"); err == nil {
+				t.Error("the canceled Insert stored bob")
+			}
+			if _, err := store.Get(context.Background(), "ada"); err != nil {
+				t.Errorf("the canceled Delete removed ada: %v", err)
+			}
+		})
+	}
+}
+
+// TestServiceContextDone checks the cancellation reaches the store
+// through the service and comes back out of it.
+func TestServiceContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	users := service.NewUserService(db.NewMemoryStore())
+	if err := users.CreateUser(ctx, &service.User{ID: "ada", Email: "ada@example.com"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("CreateUser: err = %v, want context.Canceled", err)
+	}
+	if _, err := users.RetrieveUser(ctx, "ada"); !errors.Is(err, context.Canceled) {
+		t.Errorf("RetrieveUser: err = %v, want context.Canceled", err)
+	}
+}
+
+func TestMemoryStoreWithinTx(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryStore()
+	if err := store.Insert(ctx, &service.User{ID: "ada", Email: "ada@example.com", Version: 1}); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	boom := errors.New("boom")
+
+	t.Run("rolls back on error", func(t *testing.T) {
+		err := store.WithinTx(ctx, func(tx service.UserStorer) error {
+			if err := tx.Insert(ctx, &service.User{ID: "bob", Email: "bob@example.com", Version: 1}); err != nil {
+				return err
+			}
+			if err := tx.Delete(ctx, "ada"); err != nil {
+				return err
+			}
+			// inside the transaction its own changes are visible
+			if _, err := tx.Get(ctx, "bob"); err != nil {
+				return err
+			}
+			return boom
+		})
+		if !errors.Is(err, boom) {
+			t.Fatalf("WithinTx: err = %v, want fn's error", err)
+		}
+		if _, err := store.Get(ctx, "bob"); err == nil {
+			t.Error("bob was inserted by a rolled back transaction")
+		}
+		if _, err := store.Get(ctx, "ada"); err != nil {
+			t.Errorf("ada was deleted by a rolled back transaction: %v", err)
+		}
+	})
+
+	t.Run("commits on nil", func(t *testing.T) {
+		err := store.WithinTx(ctx, func(tx service.UserStorer) error {
+			return tx.Insert(ctx, &service.User{ID: "bob", Email: "bob@example.com", Version: 1})
+		})
+		if err != nil {
+			t.Fatalf("WithinTx: %v", err)
+		}
+		if _, err := store.Get(ctx, "bob"); err != nil {
+			t.Errorf("Get after commit: %v", err)
+		}
+	})
+}
+
+// TestRenameUserAtomic renames onto a taken ID through the service: the
+// insert fails after the delete, and the transaction puts the user back.
+func TestRenameUserAtomic(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryStore()
+	users := service.NewUserService(store)
+	for _, u := range []*service.User{{ID: "ada", Email: "ada@example.com"}, {ID: "bob", Email: "bob@example.com"}} {
+		if err := users.CreateUser(ctx, u); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+	if err := users.RenameUser(ctx, "ada", "bob"); err == nil {
+		t.Fatal("RenameUser onto a taken ID succeeded")
+	}
+	if _, err := store.Get(ctx, "ada"); err != nil {
+		t.Errorf("ada is gone after a failed rename: %v", err)
+	}
+}
+
+func TestMemoryStoreContract(t *testing.T) {
+	storetest.TestStore(t, func() service.UserStorer { return db.NewMemoryStore() })
+}
+
+func BenchmarkStore(b *testing.B) {
+	storebench.BenchmarkStore(b, func(*testing.B) service.UserStorer { return db.NewMemoryStore() })
+}
@@ -0,0 +1,20 @@
+// Package mock holds mocks of the service's store interfaces generated by
+// moq. Where db/fake is a working store with scripting on top, a mock does
+// nothing until told: each method calls the func field of the same name,
+// InsertFunc for Insert, and panics if it is nil, so a test states exactly
+// what the store does and a call it did not expect fails loud. Every call
+// is recorded, InsertCalls returns them.
+//
+//	store := &mock.UserStorerMock{
+//		GetFunc: func(ctx context.Context, id string) (*service.User, error) {
+//			return nil, errs.ErrNotFound
+//		},
+//	}
+//	...
+//	if calls := store.GetCalls(); len(calls) != 1 || calls[0].ID != "1" {
+//
+// The mocks are checked in, regenerate them after changing an interface
+// with go generate ./db/mock.
+package mock
+
+//go:generate go tool moq -rm -pkg mock -out userstorer.go ../../service UserStorer
@@ -0,0 +1,58 @@
+package mock_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/mock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+func TestRecordsCalls(t *testing.T) {
+	store := &mock.UserStorerMock{
+		InsertFunc: func(ctx context.Context, user *service.User) error { return nil },
+	}
+	if err := service.NewUserService(store).CreateUser(context.Background(), &service.User{ID: "1", Email: "ada@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if calls := store.InsertCalls(); len(calls) != 1 || calls[0].User.ID != "1" || calls[0].User.Email != "ada@example.com" {
+		t.Errorf("InsertCalls = %+v, want one for user 1", calls)
+	}
+}
+
+func TestReturnsWhatItIsTold(t *testing.T) {
+	store := &mock.UserStorerMock{
+		GetFunc: func(ctx context.Context, id string) (*service.User, error) { return nil, errs.ErrNotFound },
+	}
+	if _, err := service.NewUserService(store).RetrieveUser(context.Background(), "1"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("RetrieveUser: err = %v, want ErrNotFound", err)
+	}
+	if calls := store.GetCalls(); len(calls) != 1 || calls[0].ID != "1" {
+		t.Errorf("GetCalls = %+v, want one for 1", calls)
+	}
+}
+
+func TestVersionConflict(t *testing.T) {
+	store := &mock.UserStorerMock{
+		GetFunc: func(ctx context.Context, id string) (*service.User, error) {
+			return &service.User{ID: id, Email: "ada@example.com", Version: 2}, nil
+		},
+		UpdateFunc: func(ctx context.Context, user *service.User) error { return errs.ErrVersionConflict },
+	}
+	err := service.NewUserService(store).UpdateUser(context.Background(), &service.User{ID: "1", Email: "ada@example.com", Version: 2})
+	if !errors.Is(err, errs.ErrVersionConflict) {
+		t.Errorf("UpdateUser: err = %v, want ErrVersionConflict", err)
+	}
+}
+
+// TestUnexpectedCallPanics checks a method without its func fails loud.
+func TestUnexpectedCallPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Delete without DeleteFunc did not panic")
+		}
+	}()
+	(&mock.UserStorerMock{}).Delete(context.Background(), "1")
+}
@@ -0,0 +1,232 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mock
+
+import (
+	"context"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	"sync"
+)
+
+// Ensure, that UserStorerMock does implement service.UserStorer.
+// If this is not the case, regenerate this file with moq.
+var _ service.UserStorer = &UserStorerMock{}
+
+// UserStorerMock is a mock implementation of service.UserStorer.
+//
+//	func TestSomethingThatUsesUserStorer(t *testing.T) {
+//
+//		// make and configure a mocked service.UserStorer
+//		mockedUserStorer := &UserStorerMock{
+//			DeleteFunc: func(ctx context.Context, id string) error {
+//				panic("mock out the Delete method")
+//			},
+//			GetFunc: func(ctx context.Context, id string) (*service.User, error) {
+//				panic("mock out the Get method")
+//			},
+//			InsertFunc: func(ctx context.Context, user *service.User) error {
+//				panic("mock out the Insert method")
+//			},
+//			UpdateFunc: func(ctx context.Context, user *service.User) error {
+//				panic("mock out the Update method")
+//			},
+//		}
+//
+//		// use mockedUserStorer in code that requires service.UserStorer
+//		// and then make assertions.
+//
+//	}
+type UserStorerMock struct {
+	// DeleteFunc mocks the Delete method.
+	DeleteFunc func(ctx context.Context, id string) error
+
+	// GetFunc mocks the Get method.
+	GetFunc func(ctx context.Context, id string) (*service.User, error)
+
+	// InsertFunc mocks the Insert method.
+	InsertFunc func(ctx context.Context, user *service.User) error
+
+	// UpdateFunc mocks the Update method.
+	UpdateFunc func(ctx context.Context, user *service.User) error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Delete holds details about calls to the Delete method.
+		Delete []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+		}
+		// Get holds details about calls to the Get method.
+		Get []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+		}
+		// Insert holds details about calls to the Insert method.
+		Insert []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// User is the user argument value.
+			User *service.User
+		}
+		// Update holds details about calls to the Update method.
+		Update []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// User is the user argument value.
+			User *service.User
+		}
+	}
+	lockDelete sync.RWMutex
+	lockGet    sync.RWMutex
+	lockInsert sync.RWMutex
+	lockUpdate sync.RWMutex
+}
+
+// Delete calls DeleteFunc.
+func (mock *UserStorerMock) Delete(ctx context.Context, id string) error {
+	if mock.DeleteFunc == nil {
+		panic("UserStorerMock.DeleteFunc: method is nil but UserStorer.Delete was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  string
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	mock.lockDelete.Lock()
+	mock.calls.Delete = append(mock.calls.Delete, callInfo)
+	mock.lockDelete.Unlock()
+	return mock.DeleteFunc(ctx, id)
+}
+
+// DeleteCalls gets all the calls that were made to Delete.
+// Check the length with:
+//
+//	len(mockedUserStorer.DeleteCalls())
+func (mock *UserStorerMock) DeleteCalls() []struct {
+	Ctx context.Context
+	ID  string
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  string
+	}
+	mock.lockDelete.RLock()
+	calls = mock.calls.Delete
+	mock.lockDelete.RUnlock()
+	return calls
+}
+
+// Get calls GetFunc.
+func (mock *UserStorerMock) Get(ctx context.Context, id string) (*service.User, error) {
+	if mock.GetFunc == nil {
+		panic("UserStorerMock.GetFunc: method is nil but UserStorer.Get was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  string
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	mock.lockGet.Lock()
+	mock.calls.Get = append(mock.calls.Get, callInfo)
+	mock.lockGet.Unlock()
+	return mock.GetFunc(ctx, id)
+}
+
+// GetCalls gets all the calls that were made to Get.
+// Check the length with:
+//
+//	len(mockedUserStorer.GetCalls())
+func (mock *UserStorerMock) GetCalls() []struct {
+	Ctx context.Context
+	ID  string
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  string
+	}
+	mock.lockGet.RLock()
+	calls = mock.calls.Get
+	mock.lockGet.RUnlock()
+	return calls
+}
+
+// Insert calls InsertFunc.
+func (mock *UserStorerMock) Insert(ctx context.Context, user *service.User) error {
+	if mock.InsertFunc == nil {
+		panic("UserStorerMock.InsertFunc: method is nil but UserStorer.Insert was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		User *service.User
+	}{
+		Ctx:  ctx,
+		User: user,
+	}
+	mock.lockInsert.Lock()
+	mock.calls.Insert = append(mock.calls.Insert, callInfo)
+	mock.lockInsert.Unlock()
+	return mock.InsertFunc(ctx, user)
+}
+
+// InsertCalls gets all the calls that were made to Insert.
+// Check the length with:
+//
+//	len(mockedUserStorer.InsertCalls())
+func (mock *UserStorerMock) InsertCalls() []struct {
+	Ctx  context.Context
+	User *service.User
+} {
+	var calls []struct {
+		Ctx  context.Context
+		User *service.User
+	}
+	mock.lockInsert.RLock()
+	calls = mock.calls.Insert
+	mock.lockInsert.RUnlock()
+	return calls
+}
+
+// Update calls UpdateFunc.
+func (mock *UserStorerMock) Update(ctx context.Context, user *service.User) error {
+	if mock.UpdateFunc == nil {
+		panic("UserStorerMock.UpdateFunc: method is nil but UserStorer.Update was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		User *service.User
+	}{
+		Ctx:  ctx,
+		User: user,
+	}
+	mock.lockUpdate.Lock()
+	mock.calls.Update = append(mock.calls.Update, callInfo)
+	mock.lockUpdate.Unlock()
+	return mock.UpdateFunc(ctx, user)
+}
+
+// UpdateCalls gets all the calls that were made to Update.
+// Check the length with:
+//
+//	len(mockedUserStorer.UpdateCalls())
+func (mock *UserStorerMock) UpdateCalls() []struct {
+	Ctx  context.Context
+	User *service.User
+} {
+	var calls []struct {
+		Ctx  context.Context
+		User *service.User
+	}
+	mock.lockUpdate.RLock()
+	calls = mock.calls.Update
+	mock.lockUpdate.RUnlock()
+	return calls
+}
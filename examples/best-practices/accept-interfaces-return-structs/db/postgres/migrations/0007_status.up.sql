@@ -0,0 +1,2 @@
+-- users stored before lifecycles were usable accounts
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active';
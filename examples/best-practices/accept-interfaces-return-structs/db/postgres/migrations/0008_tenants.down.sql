@@ -0,0 +1,5 @@
+-- fails on an ID or email two tenants share rather than merge their users
+DROP INDEX users_email_key;
+CREATE UNIQUE INDEX users_email_key ON users (email);
+ALTER TABLE users DROP CONSTRAINT users_pkey, ADD PRIMARY KEY (id);
+ALTER TABLE users DROP COLUMN tenant_id;
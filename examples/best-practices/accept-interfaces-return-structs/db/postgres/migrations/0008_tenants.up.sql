@@ -0,0 +1,5 @@
+-- users stored before tenants belong to the default tenant, ''
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE users DROP CONSTRAINT users_pkey, ADD PRIMARY KEY (tenant_id, id);
+DROP INDEX users_email_key;
+CREATE UNIQUE INDEX users_email_key ON users (tenant_id, email);
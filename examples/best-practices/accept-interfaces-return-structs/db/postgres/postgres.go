@@ -0,0 +1,294 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"io/fs"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/migrate"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/query"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// columns is the select list scanUser expects, in order.
+const columns = `id, email, name, status, created_at, updated_at, deleted_at, version`
+
+// uniqueViolation is the SQLSTATE postgres reports for a duplicate key.
+const uniqueViolation = "23505"
+
+// Store is a UserStorer backed by postgres through database/sql.
+// Statements are prepared once in New and reused for every call.
+// Inside WithinTx the same statements are rebound to the transaction.
+//
+// Every tenant's users are in the one table, told apart by tenant_id. Each
+// statement is for the tenant of its context, see ctxutil.Tenant, or the
+// default tenant "" when there is none, and IDs and emails are unique
+// within a tenant.
+type Store struct {
+	db     *sql.DB
+	tx     *sql.Tx
+	insert *sql.Stmt
+	get    *sql.Stmt
+	byMail *sql.Stmt
+	list   *sql.Stmt
+	update *sql.Stmt
+	delete *sql.Stmt
+}
+
+// NewMigrator returns the migrator for the store's schema on db. New
+// applies what is pending, it is also how userctl migrate rolls a database
+// back or reports its version.
+func NewMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	fsys, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, errs.Wrap("postgres.NewMigrator", err)
+	}
+	m, err := migrate.New(db, fsys, migrate.WithDialect(query.Postgres))
+	return m, errs.Wrap("postgres.NewMigrator", err)
+}
+
+// New applies the pending migrations and prepares the store's statements.
+// The caller owns db and is responsible for registering a driver, see cmd/postgres.
+func New(ctx context.Context, db *sql.DB) (*Store, error) {
+	m, err := NewMigrator(db)
+	if err == nil {
+		_, err = m.Up(ctx)
+	}
+	if err != nil {
+		return nil, errs.Wrap("postgres.New", err)
+	}
+	s := &Store{db: db}
+	stmts := []struct {
+		dst   **sql.Stmt
+		query string
+	}{
+		{&s.insert, `INSERT INTO users (tenant_id, ` + columns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`},
+		{&s.get, `SELECT ` + columns + ` FROM users WHERE tenant_id = $1 AND id = $2`},
+		{&s.byMail, `SELECT ` + columns + ` FROM users WHERE tenant_id = $1 AND email = $2`},
+		{&s.list, `SELECT ` + columns + ` FROM users WHERE tenant_id = $1 AND id > $2 ORDER BY id LIMIT $3`},
+		{&s.update, `UPDATE users SET email = $3, name = $4, status = $5, updated_at = $6, deleted_at = $7, version = version + 1 WHERE tenant_id = $1 AND id = $2 AND version = $8`},
+		{&s.delete, `DELETE FROM users WHERE tenant_id = $1 AND id = $2`},
+	}
+	for _, st := range stmts {
+		stmt, err := db.PrepareContext(ctx, st.query)
+		if err != nil {
+			s.Close()
+			return nil, errs.Wrap("postgres.New", err)
+		}
+		*st.dst = stmt
+	}
+	return s, nil
+}
+
+// Close releases the prepared statements, it does not close the *sql.DB.
+func (s *Store) Close() error {
+	if s.tx != nil {
+		return nil
+	}
+	var err error
+	for _, stmt := range []*sql.Stmt{s.insert, s.get, s.byMail, s.list, s.update, s.delete} {
+		if stmt == nil {
+			continue
+		}
+		if cerr := stmt.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Check pings the database, making the store a health.Checker.
+func (s *Store) Check(ctx context.Context) error {
+	return errs.Wrap("postgres.Store.Check", s.db.PingContext(ctx))
+}
+
+// IsolatesTenants marks the store as one that keeps tenants apart, which
+// db/tenanted asks of the store it wraps.
+func (s *Store) IsolatesTenants() {}
+
+func (s *Store) Insert(ctx context.Context, user *service.User) error {
+	if _, err := s.stmt(ctx, s.insert).ExecContext(ctx, tenant(ctx), user.ID, user.Email, user.Name, user.Status, user.CreatedAt, user.UpdatedAt, nullTime(user.DeletedAt), user.Version); err != nil {
+		if isUniqueViolation(err) {
+			return errs.Wrap("postgres.Store.Insert", errs.ErrConflict)
+		}
+		return errs.Wrap("postgres.Store.Insert", err)
+	}
+	return nil
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*service.User, error) {
+	user, err := scanUser(s.stmt(ctx, s.get).QueryRowContext(ctx, tenant(ctx), id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errs.Wrap("postgres.Store.Get", errs.ErrNotFound)
+	}
+	if err != nil {
+		return nil, errs.Wrap("postgres.Store.Get", err)
+	}
+	return user, nil
+}
+
+func (s *Store) GetByEmail(ctx context.Context, email string) (*service.User, error) {
+	user, err := scanUser(s.stmt(ctx, s.byMail).QueryRowContext(ctx, tenant(ctx), email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errs.Wrap("postgres.Store.GetByEmail", errs.ErrNotFound)
+	}
+	if err != nil {
+		return nil, errs.Wrap("postgres.Store.GetByEmail", err)
+	}
+	return user, nil
+}
+
+func (s *Store) List(ctx context.Context, after string, limit int) ([]*service.User, error) {
+	rows, err := s.stmt(ctx, s.list).QueryContext(ctx, tenant(ctx), after, limit)
+	users, err := scanUsers(rows, err)
+	return users, errs.Wrap("postgres.Store.List", err)
+}
+
+// Query runs q compiled to a statement, see query.Query.SQL, for the
+// context's tenant. The statement changes with q's conditions, so it is
+// not prepared.
+func (s *Store) Query(ctx context.Context, q query.Query) ([]*service.User, error) {
+	stmt, args := q.WhereTenant(tenant(ctx)).SQL(query.Postgres, columns)
+	var rows *sql.Rows
+	var err error
+	if s.tx != nil {
+		rows, err = s.tx.QueryContext(ctx, stmt, args...)
+	} else {
+		rows, err = s.db.QueryContext(ctx, stmt, args...)
+	}
+	users, err := scanUsers(rows, err)
+	return users, errs.Wrap("postgres.Store.Query", err)
+}
+
+// Update only matches the row at the caller's version, so two writers that
+// read the same version cannot both succeed.
+func (s *Store) Update(ctx context.Context, user *service.User) error {
+	res, err := s.stmt(ctx, s.update).ExecContext(ctx, tenant(ctx), user.ID, user.Email, user.Name, user.Status, user.UpdatedAt, nullTime(user.DeletedAt), user.Version)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return errs.Wrap("postgres.Store.Update", errs.ErrConflict)
+		}
+		return errs.Wrap("postgres.Store.Update", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errs.Wrap("postgres.Store.Update", err)
+	}
+	if n == 0 {
+		// either the row is gone or its version moved on
+		if _, err := s.Get(ctx, user.ID); err != nil {
+			return errs.Wrap("postgres.Store.Update", err)
+		}
+		return errs.Wrap("postgres.Store.Update", errs.ErrVersionConflict)
+	}
+	user.Version++
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	res, err := s.stmt(ctx, s.delete).ExecContext(ctx, tenant(ctx), id)
+	if err != nil {
+		return errs.Wrap("postgres.Store.Delete", err)
+	}
+	return affected("postgres.Store.Delete", res)
+}
+
+// WithinTx runs fn in a database transaction, committing when fn returns nil
+// and rolling back otherwise. Calls nested inside an open transaction join it.
+func (s *Store) WithinTx(ctx context.Context, fn func(service.UserStorer) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errs.Wrap("postgres.Store.WithinTx", err)
+	}
+	txStore := *s
+	txStore.tx = tx
+	if err := fn(&txStore); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return errs.Wrap("postgres.Store.WithinTx", err)
+	}
+	return nil
+}
+
+// stmt returns the prepared statement bound to the current transaction, if any.
+func (s *Store) stmt(ctx context.Context, stmt *sql.Stmt) *sql.Stmt {
+	if s.tx != nil {
+		return s.tx.StmtContext(ctx, stmt)
+	}
+	return stmt
+}
+
+// scanner is satisfied by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanUsers scans every row of a query that returned rows and err.
+func scanUsers(rows *sql.Rows, err error) ([]*service.User, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var users []*service.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func scanUser(row scanner) (*service.User, error) {
+	var user service.User
+	var deletedAt sql.NullTime
+	if err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Status, &user.CreatedAt, &user.UpdatedAt, &deletedAt, &user.Version); err != nil {
+		return nil, err
+	}
+	user.DeletedAt = deletedAt.Time
+	return &user, nil
+}
+
+// tenant is the tenant_id of ctx's statements.
+func tenant(ctx context.Context) string {
+	id, _ := ctxutil.Tenant(ctx)
+	return id
+}
+
+// nullTime stores the zero time as NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// affected maps a statement that touched no rows onto ErrNotFound.
+func affected(op string, res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errs.Wrap(op, err)
+	}
+	if n == 0 {
+		return errs.Wrap(op, errs.ErrNotFound)
+	}
+	return nil
+}
+
+// isUniqueViolation checks the SQLSTATE without importing a specific driver,
+// pgx's *pgconn.PgError exposes it through this method.
+func isUniqueViolation(err error) bool {
+	var sqlErr interface{ SQLState() string }
+	return errors.As(err, &sqlErr) && sqlErr.SQLState() == uniqueViolation
+}
@@ -0,0 +1,31 @@
+package postgres_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/postgres"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storetest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storetest/containers"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// TestStore runs the storetest contract against a postgres in a container,
+// skipped under -short or without Docker. Every check shares the one
+// database, storetest keeps their users apart.
+func TestStore(t *testing.T) {
+	conn, err := sql.Open("pgx", containers.Postgres(t))
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	store, err := postgres.New(context.Background(), conn)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	storetest.TestStore(t, func() service.UserStorer { return store })
+}
@@ -0,0 +1,215 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/codec"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+const (
+	keyPrefix = "user:"
+	// indexKey is a sorted set of every user ID, all scored 0 so that
+	// ZRANGEBYLEX returns them in ID order for List.
+	indexKey = "users:index"
+)
+
+// Store is a UserStorer that keeps each user as a string under
+// "user:<id>", encoded with its codec, JSON unless WithCodec says
+// otherwise. A non-zero ttl makes it behave like a cache: records expire on
+// their own and the service is none the wiser.
+type Store struct {
+	client goredis.Cmdable
+	ttl    time.Duration
+	codec  codec.Codec
+}
+
+// Option configures a Store.
+type Option func(*Store)
+
+// WithCodec sets how users are encoded. Every process sharing a redis must
+// use the same one, records are not tagged with their codec and one
+// written in another fails to decode.
+func WithCodec(c codec.Codec) Option {
+	return func(s *Store) {
+		s.codec = c
+	}
+}
+
+// New accepts anything satisfying goredis.Cmdable (a *Client, *ClusterClient,
+// or a pipeline). A ttl of 0 keeps records forever.
+func New(client goredis.Cmdable, ttl time.Duration, opts ...Option) *Store {
+	s := &Store{
+		client: client,
+		ttl:    ttl,
+		codec:  codec.JSON{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *Store) Insert(ctx context.Context, user *service.User) error {
+	b, err := s.codec.Marshal(user)
+	if err != nil {
+		return errs.Wrap("redis.Store.Insert", err)
+	}
+	// SET NX only writes when the key is absent
+	ok, err := s.client.SetNX(ctx, key(user.ID), b, s.ttl).Result()
+	if err != nil {
+		return errs.Wrap("redis.Store.Insert", err)
+	}
+	if !ok {
+		return errs.Wrap("redis.Store.Insert", errs.ErrConflict)
+	}
+	if err := s.client.ZAdd(ctx, indexKey, goredis.Z{Member: user.ID}).Err(); err != nil {
+		return errs.Wrap("redis.Store.Insert", err)
+	}
+	return nil
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*service.User, error) {
+	user, _, err := s.get(ctx, id)
+	if err != nil {
+		return nil, errs.Wrap("redis.Store.Get", err)
+	}
+	return user, nil
+}
+
+// get is Get that also returns the record as stored, for Update to swap
+// against.
+func (s *Store) get(ctx context.Context, id string) (*service.User, []byte, error) {
+	b, err := s.client.Get(ctx, key(id)).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil, nil, errs.ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := s.decode(b)
+	return user, b, err
+}
+
+func (s *Store) decode(b []byte) (*service.User, error) {
+	var user service.User
+	if err := s.codec.Unmarshal(b, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// List walks the ID index and fetches the records with one MGET. Index
+// entries whose record has expired through the ttl are skipped, so a page
+// can hold fewer than limit users even when more exist.
+func (s *Store) List(ctx context.Context, after string, limit int) ([]*service.User, error) {
+	start := "-"
+	if after != "" {
+		start = "(" + after
+	}
+	ids, err := s.client.ZRangeByLex(ctx, indexKey, &goredis.ZRangeBy{Min: start, Max: "+", Count: int64(limit)}).Result()
+	if err != nil {
+		return nil, errs.Wrap("redis.Store.List", err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = key(id)
+	}
+	vals, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, errs.Wrap("redis.Store.List", err)
+	}
+	users := make([]*service.User, 0, len(vals))
+	for _, v := range vals {
+		str, ok := v.(string)
+		if !ok {
+			continue
+		}
+		user, err := s.decode([]byte(str))
+		if err != nil {
+			return nil, errs.Wrap("redis.Store.List", err)
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// casScript replaces the record only if it still holds the bytes Update
+// read, making the compare-and-swap atomic on the redis side. Comparing
+// whole records rather than decoding the version keeps the script
+// independent of the codec. It returns 1 on success, 0 when the record
+// changed and -1 when missing.
+var casScript = goredis.NewScript(`
+local cur = redis.call("GET", KEYS[1])
+if not cur then
+	return -1
+end
+if cur ~= ARGV[2] then
+	return 0
+end
+local ttl = tonumber(ARGV[3])
+if ttl > 0 then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ttl)
+else
+	redis.call("SET", KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+// Update carries CreatedAt over from the stored record and swaps the new
+// record in with casScript, so a stale Version, or a write that lands
+// between the read and the swap, fails with ErrVersionConflict.
+func (s *Store) Update(ctx context.Context, user *service.User) error {
+	existing, stored, err := s.get(ctx, user.ID)
+	if err != nil {
+		return errs.Wrap("redis.Store.Update", err)
+	}
+	if existing.Version != user.Version {
+		return errs.Wrap("redis.Store.Update", errs.ErrVersionConflict)
+	}
+	updated := *user
+	updated.CreatedAt = existing.CreatedAt
+	updated.Version++
+	b, err := s.codec.Marshal(updated)
+	if err != nil {
+		return errs.Wrap("redis.Store.Update", err)
+	}
+	res, err := casScript.Run(ctx, s.client, []string{key(user.ID)}, b, stored, s.ttl.Milliseconds()).Int()
+	if err != nil {
+		return errs.Wrap("redis.Store.Update", err)
+	}
+	switch res {
+	case -1:
+		return errs.Wrap("redis.Store.Update", errs.ErrNotFound)
+	case 0:
+		return errs.Wrap("redis.Store.Update", errs.ErrVersionConflict)
+	}
+	user.Version = updated.Version
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	n, err := s.client.Del(ctx, key(id)).Result()
+	if err != nil {
+		return errs.Wrap("redis.Store.Delete", err)
+	}
+	if n == 0 {
+		return errs.Wrap("redis.Store.Delete", errs.ErrNotFound)
+	}
+	if err := s.client.ZRem(ctx, indexKey, id).Err(); err != nil {
+		return errs.Wrap("redis.Store.Delete", err)
+	}
+	return nil
+}
+
+func key(id string) string {
+	return keyPrefix + id
+}
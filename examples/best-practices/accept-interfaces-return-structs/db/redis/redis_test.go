@@ -0,0 +1,30 @@
+package redis_test
+
+import (
+	"testing"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/codec"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/redis"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storetest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storetest/containers"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// TestStore runs the storetest contract against a redis in a container,
+// once per codec, skipped under -short or without Docker.
+func TestStore(t *testing.T) {
+	opts, err := goredis.ParseURL(containers.Redis(t))
+	if err != nil {
+		t.Fatalf("ParseURL: %v", err)
+	}
+	client := goredis.NewClient(opts)
+	t.Cleanup(func() { client.Close() })
+	for name, c := range map[string]codec.Codec{"json": codec.JSON{}, "msgpack": codec.MsgPack{}} {
+		t.Run(name, func(t *testing.T) {
+			store := redis.New(client, 0, redis.WithCodec(c))
+			storetest.TestStore(t, func() service.UserStorer { return store })
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package db
+
+import (
+	"context"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/registry"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// the memory store has nothing to connect to, its DSN is ignored
+func init() {
+	registry.Register("memory", func(ctx context.Context, dsn string) (service.UserStorer, error) {
+		return NewMemoryStore(), nil
+	})
+}
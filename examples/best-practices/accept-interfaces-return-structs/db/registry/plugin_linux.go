@@ -0,0 +1,36 @@
+//go:build linux
+
+package registry
+
+import (
+	"plugin"
+	"slices"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// LoadPlugin opens the Go plugin at path, built with
+//
+//	go build -buildmode=plugin -o filestore.so ./plugins/filestore
+//
+// and returns the names its init registered. Opening runs the plugin's
+// init once, a second LoadPlugin of the same path registers nothing new.
+//
+// The price of run time extension is paid here. The plugin must be built
+// by the same Go version, from the same versions of every package it
+// shares with the program, this one included, and with the same flags,
+// -race among them, or Open refuses it. It needs cgo, and once loaded it
+// can never be unloaded.
+func LoadPlugin(path string) ([]string, error) {
+	before := Names()
+	if _, err := plugin.Open(path); err != nil {
+		return nil, errs.Wrap("registry.LoadPlugin", err)
+	}
+	var added []string
+	for _, name := range Names() {
+		if !slices.Contains(before, name) {
+			added = append(added, name)
+		}
+	}
+	return added, nil
+}
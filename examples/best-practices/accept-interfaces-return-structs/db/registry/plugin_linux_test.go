@@ -0,0 +1,127 @@
+package registry_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/app"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/config"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/registry"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// buildPlugin builds plugins/filestore into a temporary directory. A
+// plugin must be built with the flags of the program loading it, so -race
+// is copied from the test binary's build settings.
+func buildPlugin(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("building the plugin needs the go command")
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		t.Skip("no build info to copy the flags from")
+	}
+	so := filepath.Join(t.TempDir(), "filestore.so")
+	args := []string{"build", "-buildmode=plugin", "-o", so}
+	for _, s := range info.Settings {
+		switch {
+		case s.Key == "CGO_ENABLED" && s.Value != "1":
+			t.Skip("plugins need cgo")
+		case s.Key == "-race" && s.Value == "true":
+			args = append(args, "-race")
+		}
+	}
+	// the test runs in db/registry
+	args = append(args, "../../plugins/filestore")
+	if out, err := exec.Command("go", args...).CombinedOutput(); err != nil {
+		t.Fatalf("go %v: %v\n%s", args, err, out)
+	}
+	return so
+}
+
+// TestPlugin loads the plugin once, as a process can, and then opens its
+// backend through the registry and through config.
+func TestPlugin(t *testing.T) {
+	if slices.Contains(registry.Names(), "file") {
+		t.Skip("the plugin is already loaded, a plugin cannot be unloaded")
+	}
+	ctx := context.Background()
+	so := buildPlugin(t)
+
+	added, err := registry.LoadPlugin(so)
+	if err != nil {
+		t.Fatalf("LoadPlugin: %v", err)
+	}
+	if !slices.Equal(added, []string{"file"}) {
+		t.Fatalf("LoadPlugin registered %v, want [file]", added)
+	}
+	if again, err := registry.LoadPlugin(so); err != nil || len(again) != 0 {
+		t.Errorf("a second LoadPlugin = %v, %v, want nothing new", again, err)
+	}
+
+	t.Run("keeps its users", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "users.gob")
+		store, err := registry.Open(ctx, "file", path)
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		roundTrip(t, store, "grace")
+		closer, ok := store.(interface{ Close() error })
+		if !ok {
+			t.Fatal("the plugin's store cannot be closed")
+		}
+		if err := closer.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		reopened, err := registry.Open(ctx, "file", path)
+		if err != nil {
+			t.Fatalf("Open again: %v", err)
+		}
+		if _, err := reopened.Get(ctx, "grace"); err != nil {
+			t.Errorf("Get after a reopen: %v", err)
+		}
+		if _, ok := reopened.(service.UserLister); !ok {
+			t.Error("the plugin's store is not a service.UserLister")
+		}
+	})
+
+	t.Run("named by config", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.gob")
+		cfg, err := config.Load("plugin", []string{"-db-plugin", so, "-db-driver", "file", "-db", path, "-log-level", "error"})
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		a, err := app.NewApp(ctx, cfg)
+		if err != nil {
+			t.Fatalf("NewApp: %v", err)
+		}
+		// the app keeps tenants apart, a user is created in one
+		if err := a.Users.CreateUser(ctxutil.WithTenant(ctx, "acme"), &service.User{ID: "alan", Email: "alan@example.com"}); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		// the plugin's Close is a cleanup, shutting down writes the snapshot
+		if err := a.Shutdown.Shutdown(); err != nil {
+			t.Fatalf("Shutdown: %v", err)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("no snapshot after Shutdown: %v", err)
+		}
+	})
+}
+
+func TestLoadPluginJunk(t *testing.T) {
+	junk := filepath.Join(t.TempDir(), "junk.so")
+	if err := os.WriteFile(junk, []byte("not a plugin"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.LoadPlugin(junk); err == nil {
+		t.Error("LoadPlugin of a file that is not a plugin succeeded")
+	}
+}
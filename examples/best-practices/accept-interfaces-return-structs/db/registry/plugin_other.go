@@ -0,0 +1,14 @@
+//go:build !linux
+
+package registry
+
+import (
+	"errors"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// LoadPlugin is only supported on Linux, import backends instead.
+func LoadPlugin(path string) ([]string, error) {
+	return nil, errs.Wrap("registry.LoadPlugin", errors.ErrUnsupported)
+}
@@ -0,0 +1,77 @@
+// Package registry maps store names to the factories that open them, so a
+// program picks its backend from configuration by a string:
+//
+//	store, err := registry.Open(ctx, cfg.DB.Driver, cfg.DB.DSN.Reveal())
+//
+// Backends register themselves from init, the way database/sql drivers
+// do, and a program chooses which are available by what it imports:
+//
+//	import _ "github.com/.../db/sqlite" // registers "sqlite"
+//
+// That is extension at compile time: every backend is in the binary, type
+// checked against this module, and a missing one is a build error. The
+// alternative is extension at run time, loading a backend built
+// separately as a Go plugin, see LoadPlugin. A plugin's init registers
+// into this same registry, so Open does not know or care which kind of
+// backend it is opening.
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Factory opens a store from a DSN whose meaning is the backend's own: a
+// file path, a URL, or nothing at all.
+type Factory func(ctx context.Context, dsn string) (service.UserStorer, error)
+
+var (
+	mu        sync.RWMutex
+	factories = make(map[string]Factory)
+)
+
+// Register makes f available to Open as name. It panics when name is
+// empty, f is nil, or name is taken, two backends claiming one name is a
+// build mistake that should not wait for the first Open to surface.
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if name == "" || f == nil {
+		panic("registry: Register needs a name and a factory")
+	}
+	if _, dup := factories[name]; dup {
+		panic(fmt.Sprintf("registry: Register called twice for %q", name))
+	}
+	factories[name] = f
+}
+
+// Names lists every registered store, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return slices.Sorted(maps.Keys(factories))
+}
+
+// Open opens the store registered as name. An unknown name matches
+// errors.ErrUnsupported and lists the names there are, it is most often a
+// missing import or an unloaded plugin.
+func Open(ctx context.Context, name, dsn string) (service.UserStorer, error) {
+	mu.RLock()
+	f, ok := factories[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, errs.Wrap("registry.Open", fmt.Errorf("no store %q, have %v: %w", name, Names(), errors.ErrUnsupported))
+	}
+	store, err := f(ctx, dsn)
+	if err != nil {
+		return nil, errs.Wrap("registry.Open", fmt.Errorf("%s: %w", name, err))
+	}
+	return store, nil
+}
@@ -0,0 +1,70 @@
+package registry_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	_ "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/registry"
+	_ "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/sqlite"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// roundTrip stores a user in store and reads it back.
+func roundTrip(t *testing.T, store service.UserStorer, id string) {
+	t.Helper()
+	ctx := context.Background()
+	if err := store.Insert(ctx, &service.User{ID: id, Email: id + "@example.com", Version: 1}); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if _, err := store.Get(ctx, id); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+}
+
+// TestImportRegisters checks the blank imports above are all it takes.
+func TestImportRegisters(t *testing.T) {
+	names := registry.Names()
+	if !slices.Contains(names, "memory") || !slices.Contains(names, "sqlite") {
+		t.Errorf("Names = %v, want memory and sqlite among them", names)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	for name, dsn := range map[string]string{"memory": "", "sqlite": filepath.Join(t.TempDir(), "users.db")} {
+		t.Run(name, func(t *testing.T) {
+			store, err := registry.Open(context.Background(), name, dsn)
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			if c, ok := store.(interface{ Close() error }); ok {
+				t.Cleanup(func() { c.Close() })
+			}
+			roundTrip(t, store, "ada")
+		})
+	}
+}
+
+// TestOpenUnknown checks an unknown name says which names there are.
+func TestOpenUnknown(t *testing.T) {
+	_, err := registry.Open(context.Background(), "mongo", "")
+	if !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("err = %v, want errors.ErrUnsupported", err)
+	}
+	if !strings.Contains(err.Error(), "memory sqlite") {
+		t.Errorf("err = %v, want it to list memory and sqlite", err)
+	}
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Register of a taken name did not panic")
+		}
+	}()
+	registry.Register("memory", func(context.Context, string) (service.UserStorer, error) { return nil, nil })
+}
@@ -0,0 +1,100 @@
+package retry
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/retry"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Policy is retry.Policy, re-exported so callers of the decorator need only
+// this package.
+type Policy = retry.Policy
+
+// DefaultPolicy is retry.DefaultPolicy.
+func DefaultPolicy() Policy {
+	return retry.DefaultPolicy()
+}
+
+// Transient is retry.Transient.
+func Transient(err error) bool {
+	return retry.Transient(err)
+}
+
+// Store decorates a UserStorer, retrying transient failures with the
+// policy's backoff. Waiting between attempts respects ctx so a caller that
+// gives up is not held hostage by the backoff.
+type Store struct {
+	next   service.UserStorer
+	policy Policy
+}
+
+func New(next service.UserStorer, policy Policy) *Store {
+	return &Store{
+		next:   next,
+		policy: policy,
+	}
+}
+
+func (s *Store) Insert(ctx context.Context, user *service.User) error {
+	return s.do(ctx, func() error {
+		return s.next.Insert(ctx, user)
+	})
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*service.User, error) {
+	var user *service.User
+	err := s.do(ctx, func() error {
+		var err error
+		user, err = s.next.Get(ctx, id)
+		return err
+	})
+	return user, err
+}
+
+func (s *Store) GetByEmail(ctx context.Context, email string) (*service.User, error) {
+	finder, ok := s.next.(service.UserFinder)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	var user *service.User
+	err := s.do(ctx, func() error {
+		var err error
+		user, err = finder.GetByEmail(ctx, email)
+		return err
+	})
+	return user, err
+}
+
+func (s *Store) List(ctx context.Context, after string, limit int) ([]*service.User, error) {
+	lister, ok := s.next.(service.UserLister)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	var users []*service.User
+	err := s.do(ctx, func() error {
+		var err error
+		users, err = lister.List(ctx, after, limit)
+		return err
+	})
+	return users, err
+}
+
+func (s *Store) Update(ctx context.Context, user *service.User) error {
+	return s.do(ctx, func() error {
+		return s.next.Update(ctx, user)
+	})
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	return s.do(ctx, func() error {
+		return s.next.Delete(ctx, id)
+	})
+}
+
+func (s *Store) do(ctx context.Context, fn func() error) error {
+	return retry.Do(ctx, s.policy, func(context.Context) error {
+		return fn()
+	})
+}
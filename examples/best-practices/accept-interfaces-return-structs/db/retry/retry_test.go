@@ -0,0 +1,18 @@
+package retry_test
+
+import (
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/retry"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storetest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// TestStore checks retrying never turns a conflict into a success or
+// repeats a write that landed.
+func TestStore(t *testing.T) {
+	storetest.TestStore(t, func() service.UserStorer {
+		return retry.New(db.NewMemoryStore(), retry.Policy{MaxAttempts: 3, Retryable: retry.Transient})
+	})
+}
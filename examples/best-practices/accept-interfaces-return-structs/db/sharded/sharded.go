@@ -0,0 +1,266 @@
+// Package sharded has two variants of db.MemoryStore for many concurrent
+// callers, where the memory store's one RWMutex is the bottleneck: every
+// write waits for every read in flight, and every read behind a waiting
+// write.
+//
+// Store partitions users across N maps by a hash of the ID, each with a
+// mutex of its own, so callers on different shards never meet. SyncMap
+// keeps users in a sync.Map, whose reads take no lock at all, and
+// serializes its writers on one mutex. BenchmarkParallel measures both
+// against the memory store at several read to write ratios.
+//
+// Neither is a service.Transactor: a transaction has to see every user,
+// which for Store means taking every shard's lock and for SyncMap a
+// snapshot the map cannot give, and either undoes what the variant is for.
+// Without one the service runs multi-step operations such as RenameUser
+// step by step, and refuses WithOutbox.
+package sharded
+
+import (
+	"context"
+	"hash/maphash"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/query"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// DefaultShards is the shard count New uses for n <= 0.
+const DefaultShards = 32
+
+// Store is a concurrency safe UserStorer and UserFinder with the memory
+// store's semantics, users copied on the way in and out and emails unique,
+// partitioned so callers rarely share a lock.
+//
+// Users are sharded by ID and the email index by email, each with its own
+// locks. A write holds its user's shard and then the shards of the emails
+// it touches, always in that order and the email shards in index order, so
+// two writes cannot each hold what the other waits for. GetByEmail looks
+// the ID up and lets go before reading the user, for the same reason.
+//
+// List and Query read the shards one after another, not all at once, so a
+// page sees each shard at a different moment. A user created during the
+// read may or may not be on it, as with the memory store, but so may one
+// of two users created in order, the later without the earlier.
+type Store struct {
+	seed   maphash.Seed
+	users  []userShard
+	emails []emailShard
+}
+
+// The shards are padded to a cache line of their own, otherwise
+// neighbouring shards' locks would share one and every core taking either
+// would invalidate it for the other, contention by another name.
+type userShard struct {
+	mu    sync.RWMutex
+	users map[string]service.User
+	_     [64]byte
+}
+
+type emailShard struct {
+	mu  sync.RWMutex
+	ids map[string]string
+	_   [64]byte
+}
+
+// New returns an empty Store of n shards. More shards than cores buys
+// little, fewer leaves callers queued on the same lock.
+func New(n int) *Store {
+	if n <= 0 {
+		n = DefaultShards
+	}
+	s := &Store{
+		seed:   maphash.MakeSeed(),
+		users:  make([]userShard, n),
+		emails: make([]emailShard, n),
+	}
+	for i := range s.users {
+		s.users[i].users = make(map[string]service.User)
+		s.emails[i].ids = make(map[string]string)
+	}
+	return s
+}
+
+func (s *Store) index(key string) int {
+	return int(maphash.String(s.seed, key) % uint64(len(s.users)))
+}
+
+func (s *Store) userShard(id string) *userShard {
+	return &s.users[s.index(id)]
+}
+
+func (s *Store) emailShard(email string) *emailShard {
+	return &s.emails[s.index(email)]
+}
+
+// lockEmails write locks the shards of both emails, lowest index first,
+// each once when they share one.
+func (s *Store) lockEmails(a, b string) (unlock func()) {
+	i, j := s.index(a), s.index(b)
+	if i == j {
+		s.emails[i].mu.Lock()
+		return s.emails[i].mu.Unlock
+	}
+	if i > j {
+		i, j = j, i
+	}
+	s.emails[i].mu.Lock()
+	s.emails[j].mu.Lock()
+	return func() {
+		s.emails[j].mu.Unlock()
+		s.emails[i].mu.Unlock()
+	}
+}
+
+func (s *Store) Insert(ctx context.Context, user *service.User) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("sharded.Store.Insert", err)
+	}
+	us := s.userShard(user.ID)
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	if _, ok := us.users[user.ID]; ok {
+		return errs.Wrap("sharded.Store.Insert", errs.ErrConflict)
+	}
+	es := s.emailShard(user.Email)
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if _, ok := es.ids[user.Email]; ok {
+		return errs.Wrap("sharded.Store.Insert", errs.ErrConflict)
+	}
+	us.users[user.ID] = *user
+	es.ids[user.Email] = user.ID
+	return nil
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*service.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Wrap("sharded.Store.Get", err)
+	}
+	us := s.userShard(id)
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+	user, ok := us.users[id]
+	if !ok {
+		return nil, errs.Wrap("sharded.Store.Get", errs.ErrNotFound)
+	}
+	return &user, nil
+}
+
+// GetByEmail finds the email's owner, then reads the owner. A rename
+// landing between the two makes it not found, as it would be a moment
+// later anyway.
+func (s *Store) GetByEmail(ctx context.Context, email string) (*service.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Wrap("sharded.Store.GetByEmail", err)
+	}
+	es := s.emailShard(email)
+	es.mu.RLock()
+	id, ok := es.ids[email]
+	es.mu.RUnlock()
+	if !ok {
+		return nil, errs.Wrap("sharded.Store.GetByEmail", errs.ErrNotFound)
+	}
+	user, err := s.Get(ctx, id)
+	if err != nil || user.Email != email {
+		return nil, errs.Wrap("sharded.Store.GetByEmail", errs.ErrNotFound)
+	}
+	return user, nil
+}
+
+// List takes the first limit IDs after after from each shard, then the
+// first limit of those, so it copies at most limit users per shard.
+func (s *Store) List(ctx context.Context, after string, limit int) ([]*service.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Wrap("sharded.Store.List", err)
+	}
+	var users []*service.User
+	var ids []string
+	for i := range s.users {
+		us := &s.users[i]
+		us.mu.RLock()
+		ids = ids[:0]
+		for id := range us.users {
+			if id > after {
+				ids = append(ids, id)
+			}
+		}
+		slices.Sort(ids)
+		for _, id := range ids[:min(limit, len(ids))] {
+			user := us.users[id]
+			users = append(users, &user)
+		}
+		us.mu.RUnlock()
+	}
+	slices.SortFunc(users, func(a, b *service.User) int { return strings.Compare(a.ID, b.ID) })
+	return users[:min(limit, len(users))], nil
+}
+
+// Query filters a copy of every user with query.Filter, like the memory
+// store's.
+func (s *Store) Query(ctx context.Context, q query.Query) ([]*service.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Wrap("sharded.Store.Query", err)
+	}
+	var users []*service.User
+	for i := range s.users {
+		us := &s.users[i]
+		us.mu.RLock()
+		for _, user := range us.users {
+			users = append(users, &user)
+		}
+		us.mu.RUnlock()
+	}
+	return query.Filter(q, users, (*service.User).Record), nil
+}
+
+func (s *Store) Update(ctx context.Context, user *service.User) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("sharded.Store.Update", err)
+	}
+	us := s.userShard(user.ID)
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	existing, ok := us.users[user.ID]
+	if !ok {
+		return errs.Wrap("sharded.Store.Update", errs.ErrNotFound)
+	}
+	if existing.Version != user.Version {
+		return errs.Wrap("sharded.Store.Update", errs.ErrVersionConflict)
+	}
+	defer s.lockEmails(existing.Email, user.Email)()
+	to := s.emailShard(user.Email)
+	if owner, ok := to.ids[user.Email]; ok && owner != user.ID {
+		return errs.Wrap("sharded.Store.Update", errs.ErrConflict)
+	}
+	delete(s.emailShard(existing.Email).ids, existing.Email)
+	updated := *user
+	updated.CreatedAt = existing.CreatedAt
+	updated.Version++
+	us.users[user.ID] = updated
+	user.Version = updated.Version
+	to.ids[user.Email] = user.ID
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("sharded.Store.Delete", err)
+	}
+	us := s.userShard(id)
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	user, ok := us.users[id]
+	if !ok {
+		return errs.Wrap("sharded.Store.Delete", errs.ErrNotFound)
+	}
+	es := s.emailShard(user.Email)
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	delete(us.users, id)
+	delete(es.ids, user.Email)
+	return nil
+}
@@ -0,0 +1,166 @@
+package sharded_test
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/sharded"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storebench"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storetest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+func TestStore(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		newStore func() service.UserStorer
+	}{
+		{"one shard", func() service.UserStorer { return sharded.New(1) }},
+		{"default shards", func() service.UserStorer { return sharded.New(0) }},
+		{"syncmap", func() service.UserStorer { return sharded.NewSyncMap() }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			storetest.TestStore(t, tt.newStore)
+		})
+	}
+}
+
+// TestSwapEmails has pairs of users chase each other around a ring of
+// three emails, one always free, as fast as their goroutines can rename
+// them. Every rename holds the shards of two emails, taken in opposite
+// orders they would deadlock. It then checks the index still maps each
+// email to the one user that has it.
+func TestSwapEmails(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		newStore func() service.UserStorer
+	}{
+		{"sharded", func() service.UserStorer { return sharded.New(32) }},
+		{"syncmap", func() service.UserStorer { return sharded.NewSyncMap() }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			swapEmails(t, tt.newStore())
+		})
+	}
+}
+
+func swapEmails(t *testing.T, store service.UserStorer) {
+	const pairs, rounds = 8, 300
+	ctx := context.Background()
+	id := func(p, i int) string {
+		return "swap-" + strconv.Itoa(p) + "-" + strconv.Itoa(i)
+	}
+	ring := func(p, i int) string {
+		return "ring-" + strconv.Itoa(p) + "-" + strconv.Itoa(i%3) + "@example.com"
+	}
+	for p := range pairs {
+		for i := range 2 {
+			if err := store.Insert(ctx, &service.User{ID: id(p, i), Email: ring(p, i), Version: 1}); err != nil {
+				t.Fatalf("Insert: %v", err)
+			}
+		}
+	}
+	// the next email round the ring is taken until the user on it moves
+	// on, a conflict retried until then
+	rename := func(id, email string) error {
+		for {
+			user, err := store.Get(ctx, id)
+			if err != nil {
+				return err
+			}
+			user.Email = email
+			err = store.Update(ctx, user)
+			if !errors.Is(err, errs.ErrConflict) {
+				return err
+			}
+			runtime.Gosched()
+		}
+	}
+	done := make(chan error, 1)
+	go func() {
+		var wg sync.WaitGroup
+		errc := make(chan error, pairs*2)
+		for p := range pairs {
+			for i := range 2 {
+				wg.Go(func() {
+					for step := range rounds {
+						if err := rename(id(p, i), ring(p, i+step+1)); err != nil {
+							errc <- err
+							return
+						}
+					}
+				})
+			}
+		}
+		wg.Wait()
+		close(errc)
+		done <- <-errc
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("rename: %v", err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("renames still running after 30s, deadlocked")
+	}
+	finder := store.(service.UserFinder)
+	for p := range pairs {
+		for i := range 2 {
+			user, err := store.Get(ctx, id(p, i))
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if want := ring(p, i+rounds); user.Email != want {
+				t.Errorf("%s has %s after %d renames, want %s", user.ID, user.Email, rounds, want)
+			}
+			if owner, err := finder.GetByEmail(ctx, user.Email); err != nil || owner.ID != user.ID {
+				t.Errorf("GetByEmail(%s) = %v, %v, want %s", user.Email, owner, err, user.ID)
+			}
+		}
+	}
+}
+
+func BenchmarkStore(b *testing.B) {
+	b.Run("sharded", func(b *testing.B) {
+		storebench.BenchmarkStore(b, func(*testing.B) service.UserStorer { return sharded.New(0) })
+	})
+	b.Run("syncmap", func(b *testing.B) {
+		storebench.BenchmarkStore(b, func(*testing.B) service.UserStorer { return sharded.NewSyncMap() })
+	})
+}
+
+// BenchmarkParallel runs storebench's parallel workloads on the sharded
+// stores and the memory store, go test's -cpu flag setting GOMAXPROCS.
+// sharded-1 is the sharded code with one lock, what sharding costs before
+// it pays.
+func BenchmarkParallel(b *testing.B) {
+	stores := []struct {
+		name     string
+		newStore storebench.NewStore
+	}{
+		{"memory", func(*testing.B) service.UserStorer { return db.NewMemoryStore() }},
+		{"sharded-1", func(*testing.B) service.UserStorer { return sharded.New(1) }},
+		{"sharded-8", func(*testing.B) service.UserStorer { return sharded.New(8) }},
+		{"sharded-32", func(*testing.B) service.UserStorer { return sharded.New(32) }},
+		{"syncmap", func(*testing.B) service.UserStorer { return sharded.NewSyncMap() }},
+	}
+	for _, bench := range []storebench.Benchmark{
+		{Name: "Get", Run: storebench.ParallelGet},
+		{Name: "Mixed", Run: storebench.ParallelMixed},
+		{Name: "Update", Run: storebench.ParallelUpdate},
+	} {
+		for _, s := range stores {
+			b.Run(bench.Name+"/"+s.name, func(b *testing.B) {
+				bench.Run(b, s.newStore)
+			})
+		}
+	}
+}
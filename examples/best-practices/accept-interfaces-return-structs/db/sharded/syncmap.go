@@ -0,0 +1,172 @@
+package sharded
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/query"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// SyncMap is a concurrency safe UserStorer and UserFinder over sync.Map,
+// the case its documentation names: keys written once and read many times.
+// Reads take no lock, and do not slow down however many run at once.
+// Writers take mu, one at a time, which is what keeps the version check and
+// the email index right without a lock-free protocol of their own. Under a
+// write-heavy load that one mutex is the memory store's all over again, and
+// sync.Map's writes cost more than a map's on top.
+//
+// users holds a *service.User that is never changed once stored, an update
+// stores a new one, so a reader's copy is of one whole version. emails maps
+// email to ID. A reader can see a write to one map before the other, that
+// is what GetByEmail checks for.
+type SyncMap struct {
+	mu     sync.Mutex
+	users  sync.Map
+	emails sync.Map
+}
+
+func NewSyncMap() *SyncMap {
+	return &SyncMap{}
+}
+
+func (s *SyncMap) load(id string) (*service.User, bool) {
+	v, ok := s.users.Load(id)
+	if !ok {
+		return nil, false
+	}
+	return v.(*service.User), true
+}
+
+func (s *SyncMap) Insert(ctx context.Context, user *service.User) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("sharded.SyncMap.Insert", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users.Load(user.ID); ok {
+		return errs.Wrap("sharded.SyncMap.Insert", errs.ErrConflict)
+	}
+	if _, ok := s.emails.Load(user.Email); ok {
+		return errs.Wrap("sharded.SyncMap.Insert", errs.ErrConflict)
+	}
+	stored := *user
+	s.users.Store(user.ID, &stored)
+	s.emails.Store(user.Email, user.ID)
+	return nil
+}
+
+func (s *SyncMap) Get(ctx context.Context, id string) (*service.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Wrap("sharded.SyncMap.Get", err)
+	}
+	stored, ok := s.load(id)
+	if !ok {
+		return nil, errs.Wrap("sharded.SyncMap.Get", errs.ErrNotFound)
+	}
+	user := *stored
+	return &user, nil
+}
+
+// GetByEmail is not found when the email's owner no longer has it, a
+// rename a reader sees half of.
+func (s *SyncMap) GetByEmail(ctx context.Context, email string) (*service.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Wrap("sharded.SyncMap.GetByEmail", err)
+	}
+	id, ok := s.emails.Load(email)
+	if !ok {
+		return nil, errs.Wrap("sharded.SyncMap.GetByEmail", errs.ErrNotFound)
+	}
+	stored, ok := s.load(id.(string))
+	if !ok || stored.Email != email {
+		return nil, errs.Wrap("sharded.SyncMap.GetByEmail", errs.ErrNotFound)
+	}
+	user := *stored
+	return &user, nil
+}
+
+// List ranges over every user, as the memory store's does, Range visits
+// them in no order and without a lock.
+func (s *SyncMap) List(ctx context.Context, after string, limit int) ([]*service.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Wrap("sharded.SyncMap.List", err)
+	}
+	var stored []*service.User
+	s.users.Range(func(_, v any) bool {
+		if user := v.(*service.User); user.ID > after {
+			stored = append(stored, user)
+		}
+		return true
+	})
+	slices.SortFunc(stored, func(a, b *service.User) int { return strings.Compare(a.ID, b.ID) })
+	users := make([]*service.User, min(limit, len(stored)))
+	for i := range users {
+		user := *stored[i]
+		users[i] = &user
+	}
+	return users, nil
+}
+
+// Query filters a copy of every user with query.Filter, like the memory
+// store's.
+func (s *SyncMap) Query(ctx context.Context, q query.Query) ([]*service.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Wrap("sharded.SyncMap.Query", err)
+	}
+	var users []*service.User
+	s.users.Range(func(_, v any) bool {
+		user := *v.(*service.User)
+		users = append(users, &user)
+		return true
+	})
+	return query.Filter(q, users, (*service.User).Record), nil
+}
+
+func (s *SyncMap) Update(ctx context.Context, user *service.User) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("sharded.SyncMap.Update", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.load(user.ID)
+	if !ok {
+		return errs.Wrap("sharded.SyncMap.Update", errs.ErrNotFound)
+	}
+	if existing.Version != user.Version {
+		return errs.Wrap("sharded.SyncMap.Update", errs.ErrVersionConflict)
+	}
+	if owner, ok := s.emails.Load(user.Email); ok && owner != user.ID {
+		return errs.Wrap("sharded.SyncMap.Update", errs.ErrConflict)
+	}
+	updated := *user
+	updated.CreatedAt = existing.CreatedAt
+	updated.Version++
+	// the new email goes in before the user that has it, so GetByEmail
+	// finds the user by one email or the other throughout
+	s.emails.Store(user.Email, user.ID)
+	s.users.Store(user.ID, &updated)
+	if existing.Email != user.Email {
+		s.emails.Delete(existing.Email)
+	}
+	user.Version = updated.Version
+	return nil
+}
+
+func (s *SyncMap) Delete(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("sharded.SyncMap.Delete", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.load(id)
+	if !ok {
+		return errs.Wrap("sharded.SyncMap.Delete", errs.ErrNotFound)
+	}
+	s.users.Delete(id)
+	s.emails.Delete(existing.Email)
+	return nil
+}
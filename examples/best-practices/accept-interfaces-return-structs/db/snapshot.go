@@ -0,0 +1,182 @@
+package db
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/outbox"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// snapshotVersion is written first in every snapshot. Load refuses any
+// other, bump it when snapshot changes in a way gob cannot paper over.
+// Adding a field is fine, gob skips fields one side does not have, but
+// changing a field's type is not.
+const snapshotVersion = 1
+
+// snapshot is what Save writes. The email index is not in it, Load
+// rebuilds that from the users. Users are the default tenant's, Tenants
+// every other tenant's, in tenant order. A snapshot from before tenants
+// has no Tenants and loads into the default tenant whole.
+type snapshot struct {
+	Version    int
+	Users      []service.User
+	Tenants    []tenantSnapshot
+	Outbox     []outbox.Message
+	NextOutbox int64
+}
+
+type tenantSnapshot struct {
+	Tenant string
+	Users  []service.User
+}
+
+// Save writes the store's contents to path with encoding/gob. The file is
+// written beside path under a temporary name, synced, and renamed over
+// path, so a crash part way leaves the previous snapshot whole rather than
+// a torn one: a reader of path sees the old snapshot or the new, never a
+// mix.
+//
+// The store is only read locked while it is copied, writes carry on while
+// the copy is encoded. A snapshot is the store as of the moment Save was
+// called, anything written after that and before the next Save is lost if
+// the process dies, the price of not writing every change to disk.
+func (s *MemoryStore) Save(ctx context.Context, path string) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("db.MemoryStore.Save", err)
+	}
+	snap := s.snapshot()
+	if err := writeAtomic(path, func(w *bufio.Writer) error {
+		return gob.NewEncoder(w).Encode(snap)
+	}); err != nil {
+		return errs.Wrap("db.MemoryStore.Save", err)
+	}
+	return nil
+}
+
+func (s *MemoryStore) snapshot() snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snap := snapshot{
+		Version:    snapshotVersion,
+		Outbox:     append([]outbox.Message(nil), s.outbox...),
+		NextOutbox: s.nextOutbox,
+	}
+	// in tenant and ID order, so saving the same contents twice writes the
+	// same bytes
+	for _, tenant := range slices.Sorted(maps.Keys(s.spaces)) {
+		space := s.spaces[tenant]
+		if len(space.users) == 0 {
+			continue
+		}
+		users := slices.SortedFunc(maps.Values(space.users), func(a, b service.User) int { return cmp.Compare(a.ID, b.ID) })
+		if tenant == "" {
+			snap.Users = users
+			continue
+		}
+		snap.Tenants = append(snap.Tenants, tenantSnapshot{Tenant: tenant, Users: users})
+	}
+	return snap
+}
+
+// writeAtomic writes path by way of a temporary file in the same
+// directory, a rename is only atomic within one file system. The
+// directory is synced after the rename as well, without that the rename
+// itself may not survive a power cut.
+func writeAtomic(path string, write func(*bufio.Writer) error) (err error) {
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	w := bufio.NewWriter(f)
+	if err := write(w); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Load replaces the store's contents with the snapshot at path, written by
+// Save. A missing file is an error that matches fs.ErrNotExist, which a
+// caller restoring on startup treats as starting empty. The file is read
+// and checked whole before the store is touched, a corrupt or foreign
+// snapshot leaves the store as it was.
+func (s *MemoryStore) Load(ctx context.Context, path string) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("db.MemoryStore.Load", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return errs.Wrap("db.MemoryStore.Load", err)
+	}
+	defer f.Close()
+	var snap snapshot
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(&snap); err != nil {
+		return errs.Wrap("db.MemoryStore.Load", fmt.Errorf("decode %s: %w", path, err))
+	}
+	if snap.Version != snapshotVersion {
+		return errs.Wrap("db.MemoryStore.Load", fmt.Errorf("%s is snapshot version %d, want %d", path, snap.Version, snapshotVersion))
+	}
+
+	spaces := make(map[string]*keyspace, 1+len(snap.Tenants))
+	for _, t := range append([]tenantSnapshot{{Users: snap.Users}}, snap.Tenants...) {
+		if _, ok := spaces[t.Tenant]; ok {
+			return errs.Wrap("db.MemoryStore.Load", fmt.Errorf("%s holds tenant %q twice", path, t.Tenant))
+		}
+		space := newKeyspace()
+		for _, user := range t.Users {
+			if _, ok := space.users[user.ID]; ok {
+				return errs.Wrap("db.MemoryStore.Load", fmt.Errorf("%s holds user %q twice", path, user.ID))
+			}
+			if _, ok := space.emails[user.Email]; ok {
+				return errs.Wrap("db.MemoryStore.Load", fmt.Errorf("%s holds email %q twice", path, user.Email))
+			}
+			space.users[user.ID] = user
+			space.emails[user.Email] = user.ID
+		}
+		spaces[t.Tenant] = space
+	}
+	for _, msg := range snap.Outbox {
+		if msg.ID > snap.NextOutbox {
+			return errs.Wrap("db.MemoryStore.Load", fmt.Errorf("%s has outbox message %d past its next ID %d", path, msg.ID, snap.NextOutbox))
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spaces = spaces
+	s.outbox = snap.Outbox
+	s.nextOutbox = snap.NextOutbox
+	return nil
+}
@@ -0,0 +1,149 @@
+package db_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// seeded returns a store holding users user-000..user-(n-1), saved to a
+// snapshot at the path it returns.
+func seeded(t *testing.T, n int) (*db.MemoryStore, string) {
+	t.Helper()
+	ctx := context.Background()
+	store := db.NewMemoryStore()
+	users := service.NewUserService(store)
+	for i := range n {
+		id := fmt.Sprintf("user-%03d", i)
+		if err := users.CreateUser(ctx, &service.User{ID: id, Email: id + "@example.com", Name: "Ada"}); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "users.gob")
+	if err := store.Save(ctx, path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	return store, path
+}
+
+// restored is a new store loaded from path.
+func restored(t *testing.T, path string) *db.MemoryStore {
+	t.Helper()
+	store := db.NewMemoryStore()
+	if err := store.Load(context.Background(), path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return store
+}
+
+func count(t *testing.T, store *db.MemoryStore) int {
+	t.Helper()
+	users, err := store.List(context.Background(), "", 1000)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	return len(users)
+}
+
+func TestSnapshotRestores(t *testing.T) {
+	ctx := context.Background()
+	saved, path := seeded(t, 100)
+	store := restored(t, path)
+	for i := range 100 {
+		id := fmt.Sprintf("user-%03d", i)
+		want, _ := saved.Get(ctx, id)
+		got, err := store.GetByEmail(ctx, id+"@example.com")
+		if err != nil {
+			t.Fatalf("GetByEmail: %v", err)
+		}
+		if got.ID != want.ID || got.Version != want.Version || !got.CreatedAt.Equal(want.CreatedAt) {
+			t.Errorf("restored %+v, want %+v", *got, *want)
+		}
+	}
+	// the email index is live, not just loaded
+	if err := store.Insert(ctx, &service.User{ID: "other", Email: "user-000@example.com"}); !errors.Is(err, errs.ErrConflict) {
+		t.Errorf("Insert with a taken email: err = %v, want ErrConflict", err)
+	}
+}
+
+func TestSnapshotDeterministic(t *testing.T) {
+	store, path := seeded(t, 50)
+	first, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(context.Background(), path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	second, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Error("two saves of one store differ")
+	}
+}
+
+// TestSnapshotInterruptedSave leaves what a crash mid save would, a partial
+// temporary file beside the snapshot, which Load never reads.
+func TestSnapshotInterruptedSave(t *testing.T) {
+	_, path := seeded(t, 10)
+	if err := os.WriteFile(path+".tmp-123", []byte("\x17\xff\x81 half a gob"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, restored(t, path)); n != 10 {
+		t.Errorf("restored %d users, want 10", n)
+	}
+}
+
+// TestSnapshotTorn writes half a snapshot in place, what skipping the
+// rename risks: Load refuses it and keeps the store as it was.
+func TestSnapshotTorn(t *testing.T) {
+	store, path := seeded(t, 10)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b[:len(b)/2], 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Load(context.Background(), path); err == nil {
+		t.Fatal("a torn snapshot loaded")
+	}
+	if n := count(t, store); n != 10 {
+		t.Errorf("store has %d users after the failed Load, want 10", n)
+	}
+}
+
+func TestSnapshotMissing(t *testing.T) {
+	err := db.NewMemoryStore().Load(context.Background(), filepath.Join(t.TempDir(), "nope.gob"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Load: err = %v, want fs.ErrNotExist", err)
+	}
+}
+
+// TestSnapshotLosesUnsaved checks the durability trade: writes after the
+// last save are gone after a restart.
+func TestSnapshotLosesUnsaved(t *testing.T) {
+	store, path := seeded(t, 10)
+	if err := store.Insert(context.Background(), &service.User{ID: "late", Email: "late@example.com"}); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	// the process dies here, before the next save
+	again := restored(t, path)
+	if _, err := again.Get(context.Background(), "late"); err == nil {
+		t.Error("an unsaved write survived")
+	}
+	if n := count(t, again); n != 10 {
+		t.Errorf("restored %d users, want 10", n)
+	}
+}
@@ -0,0 +1,140 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/sqlite"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/tenanted"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/migrate"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+func migrator(t *testing.T, path string) (*sql.DB, *migrate.Migrator) {
+	t.Helper()
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	m, err := sqlite.NewMigrator(db)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	return db, m
+}
+
+// TestMigrationsRoundTrip checks the store's migrations all roll back and
+// apply again.
+func TestMigrationsRoundTrip(t *testing.T) {
+	ctx := t.Context()
+	_, m := migrator(t, filepath.Join(t.TempDir(), "users.db"))
+	all := len(m.Migrations())
+	for _, run := range []struct {
+		name string
+		fn   func() ([]migrate.Migration, error)
+	}{
+		{"up", func() ([]migrate.Migration, error) { return m.Up(ctx) }},
+		{"down", func() ([]migrate.Migration, error) { return m.Down(ctx, all) }},
+		{"up again", func() ([]migrate.Migration, error) { return m.Up(ctx) }},
+	} {
+		done, err := run.fn()
+		if err != nil {
+			t.Fatalf("%s: %v", run.name, err)
+		}
+		if len(done) != all {
+			t.Fatalf("%s ran %d of %d versions", run.name, len(done), all)
+		}
+	}
+}
+
+// TestAdoptLegacy checks Open adopts a database from before
+// schema_versions: it keeps its users, records every version as applied
+// and drops the old schema_migrations table.
+func TestAdoptLegacy(t *testing.T) {
+	ctx := t.Context()
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	old, m := migrator(t, path)
+	// what the store's Open did before package migrate
+	if _, err := old.ExecContext(ctx, `CREATE TABLE schema_migrations (name TEXT PRIMARY KEY)`); err != nil {
+		t.Fatal(err)
+	}
+	for _, mig := range m.Migrations() {
+		if _, err := old.ExecContext(ctx, mig.Up); err != nil {
+			t.Fatalf("version %d: %v", mig.Version, err)
+		}
+		name := fmt.Sprintf("migrations/%04d_%s.sql", mig.Version, mig.Name)
+		if _, err := old.ExecContext(ctx, `INSERT INTO schema_migrations (name) VALUES (?)`, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := old.ExecContext(ctx, `INSERT INTO users (id, email) VALUES ('ada', 'ada@example.com')`); err != nil {
+		t.Fatal(err)
+	}
+	old.Close()
+
+	store := open(t, path)
+	ada, err := service.NewUserService(store).RetrieveUser(ctx, "ada")
+	if err != nil || ada.Email != "ada@example.com" || ada.Status != service.StatusActive {
+		t.Fatalf("RetrieveUser = %+v, %v, want the active ada", ada, err)
+	}
+	db, m := migrator(t, path)
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if len(statuses) != len(m.Migrations()) {
+		t.Errorf("%d statuses, want %d", len(statuses), len(m.Migrations()))
+	}
+	for _, s := range statuses {
+		if s.AppliedAt.IsZero() || s.Unknown {
+			t.Errorf("version %d = %+v, want applied", s.Version, s)
+		}
+	}
+	var left int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE name = 'schema_migrations'`).Scan(&left); err != nil || left != 0 {
+		t.Errorf("schema_migrations tables = %d, %v, want it dropped", left, err)
+	}
+}
+
+// TestTenantsFromBefore checks users from before the tenants migration
+// are the default tenant's, and that it refuses to roll back once two
+// tenants share an ID.
+func TestTenantsFromBefore(t *testing.T) {
+	ctx := t.Context()
+	path := filepath.Join(t.TempDir(), "users.db")
+	old, m := migrator(t, path)
+	if _, err := m.UpTo(ctx, 8); err != nil {
+		t.Fatalf("UpTo(8): %v", err)
+	}
+	if _, err := old.ExecContext(ctx, `INSERT INTO users (id, email) VALUES ('ada', 'ada@example.com')`); err != nil {
+		t.Fatal(err)
+	}
+	store := open(t, path)
+	acme := ctxutil.WithTenant(ctx, "acme")
+	if ada, err := store.Get(ctx, "ada"); err != nil || ada.Email != "ada@example.com" {
+		t.Errorf("ada without a tenant = %v, %v, want the old ada", ada, err)
+	}
+	if _, err := tenanted.New(store).Get(acme, "ada"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("ada in acme: err = %v, want ErrNotFound", err)
+	}
+	// rolling back would have two adas in one table
+	if err := store.Insert(acme, &service.User{ID: "ada", Email: "ada@example.com", Version: 1}); err != nil {
+		t.Fatalf("Insert in acme: %v", err)
+	}
+	if _, err := m.Down(ctx, 1); err == nil {
+		t.Fatal("rolled back the tenants with an ID two of them share")
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if last := statuses[len(statuses)-1]; last.Name != "tenants" || last.AppliedAt.IsZero() {
+		t.Errorf("after the failed rollback the last version is %+v, want tenants still applied", last)
+	}
+}
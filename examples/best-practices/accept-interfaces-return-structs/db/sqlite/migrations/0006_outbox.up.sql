@@ -0,0 +1,8 @@
+CREATE TABLE outbox (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	topic      TEXT NOT NULL,
+	payload    BLOB NOT NULL,
+	created_at DATETIME NOT NULL,
+	sent_at    DATETIME
+);
+CREATE INDEX outbox_pending ON outbox (id) WHERE sent_at IS NULL;
@@ -0,0 +1,16 @@
+-- fails on an ID or email two tenants share rather than merge their users
+CREATE TABLE users_untenanted (
+	id         TEXT PRIMARY KEY,
+	email      TEXT NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00',
+	updated_at DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00',
+	deleted_at DATETIME,
+	version    INTEGER NOT NULL DEFAULT 1,
+	name       TEXT NOT NULL DEFAULT '',
+	status     TEXT NOT NULL DEFAULT 'active'
+);
+INSERT INTO users_untenanted (id, email, created_at, updated_at, deleted_at, version, name, status)
+	SELECT id, email, created_at, updated_at, deleted_at, version, name, status FROM users;
+DROP TABLE users;
+ALTER TABLE users_untenanted RENAME TO users;
+CREATE UNIQUE INDEX users_email_key ON users (email);
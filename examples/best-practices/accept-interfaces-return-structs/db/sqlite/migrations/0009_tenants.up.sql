@@ -0,0 +1,20 @@
+-- a primary key cannot be altered in place, so the table is rebuilt with
+-- the tenant in front of the key. Users stored before tenants belong to
+-- the default tenant, ''.
+CREATE TABLE users_tenanted (
+	tenant_id  TEXT NOT NULL DEFAULT '',
+	id         TEXT NOT NULL,
+	email      TEXT NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00',
+	updated_at DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00',
+	deleted_at DATETIME,
+	version    INTEGER NOT NULL DEFAULT 1,
+	name       TEXT NOT NULL DEFAULT '',
+	status     TEXT NOT NULL DEFAULT 'active',
+	PRIMARY KEY (tenant_id, id)
+);
+INSERT INTO users_tenanted (id, email, created_at, updated_at, deleted_at, version, name, status)
+	SELECT id, email, created_at, updated_at, deleted_at, version, name, status FROM users;
+DROP TABLE users;
+ALTER TABLE users_tenanted RENAME TO users;
+CREATE UNIQUE INDEX users_email_key ON users (tenant_id, email);
@@ -0,0 +1,58 @@
+package sqlite
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/outbox"
+)
+
+// AppendOutbox writes msg through s.q, so inside WithinTx it commits or
+// rolls back together with the user change that produced it.
+func (s *Store) AppendOutbox(ctx context.Context, msg outbox.Message) error {
+	_, err := s.q.ExecContext(ctx, `INSERT INTO outbox (topic, payload, created_at) VALUES (?, ?, ?)`, msg.Topic, msg.Payload, msg.CreatedAt.UTC())
+	if err != nil {
+		return errs.Wrap("sqlite.Store.AppendOutbox", err)
+	}
+	return nil
+}
+
+func (s *Store) PendingOutbox(ctx context.Context, limit int) ([]outbox.Message, error) {
+	rows, err := s.q.QueryContext(ctx, `SELECT id, topic, payload, created_at FROM outbox WHERE sent_at IS NULL ORDER BY id LIMIT ?`, limit)
+	if err != nil {
+		return nil, errs.Wrap("sqlite.Store.PendingOutbox", err)
+	}
+	defer rows.Close()
+	var msgs []outbox.Message
+	for rows.Next() {
+		var msg outbox.Message
+		if err := rows.Scan(&msg.ID, &msg.Topic, &msg.Payload, &msg.CreatedAt); err != nil {
+			return nil, errs.Wrap("sqlite.Store.PendingOutbox", err)
+		}
+		msgs = append(msgs, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.Wrap("sqlite.Store.PendingOutbox", err)
+	}
+	return msgs, nil
+}
+
+// MarkOutboxSent stamps sent_at rather than deleting, so the table doubles as
+// a log of what was relayed and when.
+func (s *Store) MarkOutboxSent(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]any, 0, len(ids)+1)
+	args = append(args, time.Now().UTC())
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	if _, err := s.q.ExecContext(ctx, `UPDATE outbox SET sent_at = ? WHERE id IN (`+placeholders+`)`, args...); err != nil {
+		return errs.Wrap("sqlite.Store.MarkOutboxSent", err)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package sqlite_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/fake"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/query"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// base is the earliest creation time of the mixed users.
+var base = time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+// mixed is 40 users whose creation times spread over whole seconds,
+// fractions and nanoseconds, with case, wildcards and deletes mixed into
+// their emails.
+func mixed() []*service.User {
+	var users []*service.User
+	for i := range 40 {
+		domain := []string{"example.com", "Example.COM", "example.org", "ex_ample.io", "100%.example.net"}[i%5]
+		user := &service.User{
+			ID:        fmt.Sprintf("user-%02d", (i*17)%40),
+			Email:     fmt.Sprintf("%s%d@%s", []string{"ada", "Bob", "cy", "dee"}[i%4], i, domain),
+			Name:      []string{"Ada", "bob", "Cy", ""}[i%3],
+			Status:    service.StatusActive,
+			CreatedAt: base.Add(time.Duration(i%9)*time.Second + time.Duration(i%4)*123456789*time.Nanosecond),
+			Version:   1,
+		}
+		user.UpdatedAt = user.CreatedAt.Add(time.Duration(40-i) * time.Minute)
+		if i%6 == 0 {
+			user.DeletedAt = user.UpdatedAt
+		}
+		users = append(users, user)
+	}
+	return users
+}
+
+// seed inserts a copy of every mixed user into store.
+func seed[S service.UserStorer](t *testing.T, store S) S {
+	t.Helper()
+	for _, user := range mixed() {
+		if err := store.Insert(context.Background(), user); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+	}
+	return store
+}
+
+// querier is a store that runs queries itself.
+type querier interface {
+	service.UserStorer
+	service.UserQuerier
+}
+
+// ids runs q on store and returns the IDs in order.
+func ids(t *testing.T, store querier, q query.Query) []string {
+	t.Helper()
+	users, err := store.Query(context.Background(), q)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	out := make([]string, len(users))
+	for i, user := range users {
+		out[i] = user.ID
+	}
+	return out
+}
+
+// TestQueryMatchesMemory runs the same queries through query.Filter in
+// memory and through the statement query.Query.SQL compiles to here: each
+// must return the same users in the same order.
+func TestQueryMatchesMemory(t *testing.T) {
+	memory := seed(t, db.NewMemoryStore())
+	lite := seed(t, open(t, filepath.Join(t.TempDir(), "users.db")))
+	for _, tt := range []struct {
+		name string
+		q    query.Query
+	}{
+		{"everyone", query.Users()},
+		{"by email domain", query.Users().WhereEmailLike("%@example.com")},
+		{"ignoring ASCII case", query.Users().WhereEmailLike("BOB%")},
+		{"one character wildcard", query.Users().WhereEmailLike("cy_@%")},
+		{"escaped underscore", query.Users().WhereEmailLike(`%@ex\_ample.io`)},
+		{"escaped literal", query.Users().WhereEmailLike("%" + query.EscapeLike("100%.") + "%")},
+		{"unescaped _ matches anything", query.Users().WhereEmailLike("%@ex_ample%")},
+		{"no match", query.Users().WhereEmailLike("nobody%")},
+		{"live users newest first", query.Users().ExcludeDeleted().OrderByDesc(query.Created)},
+		{"by created, fractions of a second", query.Users().OrderBy(query.Created).Limit(12)},
+		{"created after a time", query.Users().WhereCreatedAfter(base.Add(4*time.Second + 200*time.Millisecond)).OrderBy(query.Created)},
+		{"by name then updated", query.Users().OrderBy(query.Name).OrderByDesc(query.Updated)},
+		{"by email", query.Users().ExcludeDeleted().OrderBy(query.Email)},
+		{"keyset page", query.Users().WhereIDAfter("user-17").OrderBy(query.ID).Limit(5)},
+		{"everything at once", query.Users().WhereEmailLike("%example%").ExcludeDeleted().WhereCreatedAfter(base.Add(time.Second)).OrderByDesc(query.Updated).Limit(7)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if want, got := ids(t, memory, tt.q), ids(t, lite, tt.q); !slices.Equal(got, want) {
+				t.Errorf("sqlite %v, memory %v", got, want)
+			}
+		})
+	}
+}
+
+// TestMatchAgreesWithSQL checks Match keeps the users the statement's
+// WHERE finds.
+func TestMatchAgreesWithSQL(t *testing.T) {
+	q := query.Users().WhereEmailLike("%@EXAMPLE.com").ExcludeDeleted()
+	found := ids(t, seed(t, open(t, filepath.Join(t.TempDir(), "users.db"))), q)
+	var matched []string
+	for _, user := range mixed() {
+		if q.Match(user.Record()) {
+			matched = append(matched, user.ID)
+		}
+	}
+	slices.Sort(found)
+	slices.Sort(matched)
+	if len(matched) == 0 || !slices.Equal(found, matched) {
+		t.Errorf("sqlite found %v, Match kept %v", found, matched)
+	}
+}
+
+// emails lists every page through ListUsers on s and returns the emails in
+// order.
+func emails(t *testing.T, s service.UserStorer, req service.PageRequest, opts ...service.ReadOption) []string {
+	t.Helper()
+	users := service.NewUserService(s)
+	var out []string
+	for {
+		page, err := users.ListUsers(context.Background(), req, opts...)
+		if err != nil {
+			t.Fatalf("ListUsers: %v", err)
+		}
+		for _, user := range page.Items {
+			out = append(out, user.Email)
+		}
+		if page.NextCursor == "" {
+			return out
+		}
+		req.Cursor = page.NextCursor
+	}
+}
+
+// TestListUsersPagesAlike pages through the stores that run queries and
+// through one that can only List, whose pages ListUsers filters itself.
+func TestListUsersPagesAlike(t *testing.T) {
+	listOnly := seed(t, fake.New())
+	stores := map[string]service.UserStorer{
+		"memory": seed(t, db.NewMemoryStore()),
+		"sqlite": seed(t, open(t, filepath.Join(t.TempDir(), "users.db"))),
+	}
+	for _, tt := range []struct {
+		name string
+		req  service.PageRequest
+		opts []service.ReadOption
+	}{
+		{"all live users", service.PageRequest{Limit: 4}, nil},
+		{"filtered by email", service.PageRequest{Limit: 3, EmailLike: "%@example.com"}, nil},
+		{"filtered, deleted included", service.PageRequest{Limit: 5, EmailLike: "ada%"}, []service.ReadOption{service.IncludeDeleted()}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			want := emails(t, listOnly, tt.req, tt.opts...)
+			if len(want) == 0 {
+				t.Fatal("the list only store returned nobody")
+			}
+			for name, store := range stores {
+				if got := emails(t, store, tt.req, tt.opts...); !slices.Equal(got, want) {
+					t.Errorf("%s %v, list only %v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+// countingStore counts the queries it passes on.
+type countingStore struct {
+	querier
+	queries int
+}
+
+func (s *countingStore) Query(ctx context.Context, q query.Query) ([]*service.User, error) {
+	s.queries++
+	return s.querier.Query(ctx, q)
+}
+
+func TestListUsersOneQuery(t *testing.T) {
+	counted := &countingStore{querier: seed(t, open(t, filepath.Join(t.TempDir(), "users.db")))}
+	got := emails(t, counted, service.PageRequest{Limit: 100, EmailLike: "%.org"})
+	if counted.queries != 1 || len(got) == 0 {
+		t.Errorf("%d users in %d queries, want some in one query", len(got), counted.queries)
+	}
+}
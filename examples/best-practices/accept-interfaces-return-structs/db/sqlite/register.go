@@ -0,0 +1,22 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/registry"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// the DSN is the database file. An empty one would open a private
+// temporary database that vanishes on Close, which is never what a
+// configured backend means.
+func init() {
+	registry.Register("sqlite", func(ctx context.Context, dsn string) (service.UserStorer, error) {
+		if dsn == "" {
+			return nil, fmt.Errorf("sqlite needs a database file: %w", errs.ErrInvalidInput)
+		}
+		return Open(ctx, dsn)
+	})
+}
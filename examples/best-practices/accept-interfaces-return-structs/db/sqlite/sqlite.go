@@ -0,0 +1,262 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"io/fs"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/migrate"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/query"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// columns is the select list scanUser expects, in order.
+const columns = `id, email, name, status, created_at, updated_at, deleted_at, version`
+
+// extended result codes for a duplicate primary key or unique column.
+const (
+	constraintPrimaryKey = 1555
+	constraintUnique     = 2067
+)
+
+// Store is a file backed UserStorer. It uses the pure Go modernc.org/sqlite
+// driver so the example runs without cgo, Docker, or a database server.
+//
+// Every tenant's users are in the one table, told apart by tenant_id. Each
+// statement is for the tenant of its context, see ctxutil.Tenant, or the
+// default tenant "" when there is none, and IDs and emails are unique
+// within a tenant.
+type Store struct {
+	db *sql.DB
+	// q is db, or the open transaction inside WithinTx
+	q querier
+}
+
+// querier is the part of the API *sql.DB and *sql.Tx have in common.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Open opens (or creates) the database at path and applies any migrations
+// that have not run yet. Use ":memory:" for a throwaway database.
+func Open(ctx context.Context, path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, errs.Wrap("sqlite.Open", err)
+	}
+	// sqlite allows a single writer, one connection avoids SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+	m, err := NewMigrator(db)
+	if err == nil {
+		_, err = m.Up(ctx)
+	}
+	if err != nil {
+		db.Close()
+		return nil, errs.Wrap("sqlite.Open", err)
+	}
+	return &Store{db: db, q: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Check pings the database, making the store a health.Checker.
+func (s *Store) Check(ctx context.Context) error {
+	return errs.Wrap("sqlite.Store.Check", s.db.PingContext(ctx))
+}
+
+// NewMigrator returns the migrator for the store's schema on db, opened
+// with this package's "sqlite" driver. Open applies what is pending, it is
+// also how userctl migrate rolls a database back or reports its version.
+func NewMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	fsys, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, errs.Wrap("sqlite.NewMigrator", err)
+	}
+	// databases from before package migrate listed the files they had run
+	// in schema_migrations
+	m, err := migrate.New(db, fsys, migrate.WithLegacyTable("schema_migrations"))
+	return m, errs.Wrap("sqlite.NewMigrator", err)
+}
+
+// IsolatesTenants marks the store as one that keeps tenants apart, which
+// db/tenanted asks of the store it wraps.
+func (s *Store) IsolatesTenants() {}
+
+func (s *Store) Insert(ctx context.Context, user *service.User) error {
+	_, err := s.q.ExecContext(ctx, `INSERT INTO users (tenant_id, `+columns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`, tenant(ctx), user.ID, user.Email, user.Name, user.Status, user.CreatedAt.UTC(), user.UpdatedAt.UTC(), nullTime(user.DeletedAt), user.Version)
+	if err != nil {
+		if isConstraint(err) {
+			return errs.Wrap("sqlite.Store.Insert", errs.ErrConflict)
+		}
+		return errs.Wrap("sqlite.Store.Insert", err)
+	}
+	return nil
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*service.User, error) {
+	user, err := scanUser(s.q.QueryRowContext(ctx, `SELECT `+columns+` FROM users WHERE tenant_id = ? AND id = ?`, tenant(ctx), id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errs.Wrap("sqlite.Store.Get", errs.ErrNotFound)
+	}
+	if err != nil {
+		return nil, errs.Wrap("sqlite.Store.Get", err)
+	}
+	return user, nil
+}
+
+func (s *Store) GetByEmail(ctx context.Context, email string) (*service.User, error) {
+	user, err := scanUser(s.q.QueryRowContext(ctx, `SELECT `+columns+` FROM users WHERE tenant_id = ? AND email = ?`, tenant(ctx), email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errs.Wrap("sqlite.Store.GetByEmail", errs.ErrNotFound)
+	}
+	if err != nil {
+		return nil, errs.Wrap("sqlite.Store.GetByEmail", err)
+	}
+	return user, nil
+}
+
+func (s *Store) List(ctx context.Context, after string, limit int) ([]*service.User, error) {
+	users, err := s.list(ctx, `SELECT `+columns+` FROM users WHERE tenant_id = ? AND id > ? ORDER BY id LIMIT ?`, tenant(ctx), after, limit)
+	return users, errs.Wrap("sqlite.Store.List", err)
+}
+
+// Query runs q compiled to a statement, see query.Query.SQL, for the
+// context's tenant.
+func (s *Store) Query(ctx context.Context, q query.Query) ([]*service.User, error) {
+	stmt, args := q.WhereTenant(tenant(ctx)).SQL(query.SQLite, columns)
+	users, err := s.list(ctx, stmt, args...)
+	return users, errs.Wrap("sqlite.Store.Query", err)
+}
+
+// list scans every row stmt returns.
+func (s *Store) list(ctx context.Context, stmt string, args ...any) ([]*service.User, error) {
+	rows, err := s.q.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var users []*service.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// Update only matches the row at the caller's version, so two writers that
+// read the same version cannot both succeed.
+func (s *Store) Update(ctx context.Context, user *service.User) error {
+	res, err := s.q.ExecContext(ctx, `UPDATE users SET email = ?, name = ?, status = ?, updated_at = ?, deleted_at = ?, version = version + 1 WHERE tenant_id = ? AND id = ? AND version = ?`,
+		user.Email, user.Name, user.Status, user.UpdatedAt.UTC(), nullTime(user.DeletedAt), tenant(ctx), user.ID, user.Version)
+	if err != nil {
+		if isConstraint(err) {
+			return errs.Wrap("sqlite.Store.Update", errs.ErrConflict)
+		}
+		return errs.Wrap("sqlite.Store.Update", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errs.Wrap("sqlite.Store.Update", err)
+	}
+	if n == 0 {
+		// either the row is gone or its version moved on
+		if _, err := s.Get(ctx, user.ID); err != nil {
+			return errs.Wrap("sqlite.Store.Update", err)
+		}
+		return errs.Wrap("sqlite.Store.Update", errs.ErrVersionConflict)
+	}
+	user.Version++
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	res, err := s.q.ExecContext(ctx, `DELETE FROM users WHERE tenant_id = ? AND id = ?`, tenant(ctx), id)
+	if err != nil {
+		return errs.Wrap("sqlite.Store.Delete", err)
+	}
+	return affected("sqlite.Store.Delete", res)
+}
+
+// WithinTx runs fn in a database transaction, committing when fn returns nil
+// and rolling back otherwise. Calls nested inside an open transaction join it.
+func (s *Store) WithinTx(ctx context.Context, fn func(service.UserStorer) error) error {
+	if s.q != s.db {
+		return fn(s)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errs.Wrap("sqlite.Store.WithinTx", err)
+	}
+	if err := fn(&Store{db: s.db, q: tx}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return errs.Wrap("sqlite.Store.WithinTx", err)
+	}
+	return nil
+}
+
+// scanner is satisfied by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row scanner) (*service.User, error) {
+	var user service.User
+	var deletedAt sql.NullTime
+	if err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Status, &user.CreatedAt, &user.UpdatedAt, &deletedAt, &user.Version); err != nil {
+		return nil, err
+	}
+	user.DeletedAt = deletedAt.Time
+	return &user, nil
+}
+
+// tenant is the tenant_id of ctx's statements.
+func tenant(ctx context.Context) string {
+	id, _ := ctxutil.Tenant(ctx)
+	return id
+}
+
+// nullTime stores the zero time as NULL, in UTC like the other timestamps.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+// affected maps a statement that touched no rows onto ErrNotFound.
+func affected(op string, res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errs.Wrap(op, err)
+	}
+	if n == 0 {
+		return errs.Wrap(op, errs.ErrNotFound)
+	}
+	return nil
+}
+
+func isConstraint(err error) bool {
+	var sqlErr interface{ Code() int }
+	if !errors.As(err, &sqlErr) {
+		return false
+	}
+	code := sqlErr.Code()
+	return code == constraintPrimaryKey || code == constraintUnique
+}
@@ -0,0 +1,150 @@
+package sqlite_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/sqlite"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storebench"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storetest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// open opens a store on a file in a directory the test removes when done.
+func open(t *testing.T, path string) *sqlite.Store {
+	t.Helper()
+	store, err := sqlite.Open(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestCRUD(t *testing.T) {
+	ctx := context.Background()
+	store := open(t, filepath.Join(t.TempDir(), "users.db"))
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	ada := &service.User{ID: "ada", Email: "ada@example.com", Name: "Ada", Status: service.StatusActive, CreatedAt: created, UpdatedAt: created, Version: 1}
+	if err := store.Insert(ctx, ada); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	got, err := store.Get(ctx, "ada")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Email != ada.Email || got.Name != ada.Name || got.Status != ada.Status || !got.CreatedAt.Equal(created) || got.Version != 1 {
+		t.Errorf("Get = %+v, want %+v", got, ada)
+	}
+
+	got.Email = "ada@lovelace.example"
+	if err := store.Update(ctx, got); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got.Version != 2 {
+		t.Errorf("after Update Version = %d, want 2", got.Version)
+	}
+	if err := store.Delete(ctx, "ada"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(ctx, "ada"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Get after Delete: err = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, "ada"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Delete twice: err = %v, want ErrNotFound", err)
+	}
+}
+
+// TestReopen checks the migrations run once: a second Open of the same
+// file keeps what the first stored.
+func TestReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "users.db")
+	first, err := sqlite.Open(ctx, path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := first.Insert(ctx, &service.User{ID: "ada", Email: "ada@example.com", Version: 1}); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	second := open(t, path)
+	if got, err := second.Get(ctx, "ada"); err != nil || got.Email != "ada@example.com" {
+		t.Fatalf("Get after reopen = %+v, %v, want ada", got, err)
+	}
+	if err := second.Check(ctx); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+}
+
+func TestServiceEndToEnd(t *testing.T) {
+	ctx := context.Background()
+	users := service.NewUserService(open(t, filepath.Join(t.TempDir(), "users.db")))
+	if err := users.CreateUser(ctx, &service.User{ID: "1", Email: "gopher@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	got, err := users.RetrieveUser(ctx, "1")
+	if err != nil || got.Email != "gopher@example.com" || got.Status != service.StatusPending {
+		t.Fatalf("RetrieveUser = %+v, %v, want the pending gopher", got, err)
+	}
+}
+
+func TestWithinTx(t *testing.T) {
+	ctx := context.Background()
+	store := open(t, filepath.Join(t.TempDir(), "users.db"))
+	if err := store.Insert(ctx, &service.User{ID: "ada", Email: "ada@example.com", Version: 1}); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	boom := errors.New("boom")
+	err := store.WithinTx(ctx, func(tx service.UserStorer) error {
+		if err := tx.Insert(ctx, &service.User{ID: "bob", Email: "bob@example.com", Version: 1}); err != nil {
+			return err
+		}
+		if err := tx.Delete(ctx, "ada"); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("WithinTx: err = %v, want fn's error", err)
+	}
+	if _, err := store.Get(ctx, "bob"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Get bob after rollback: err = %v, want ErrNotFound", err)
+	}
+	if _, err := store.Get(ctx, "ada"); err != nil {
+		t.Errorf("Get ada after rollback: %v", err)
+	}
+
+	if err := store.WithinTx(ctx, func(tx service.UserStorer) error {
+		return tx.Insert(ctx, &service.User{ID: "bob", Email: "bob@example.com", Version: 1})
+	}); err != nil {
+		t.Fatalf("WithinTx: %v", err)
+	}
+	if _, err := store.Get(ctx, "bob"); err != nil {
+		t.Errorf("Get bob after commit: %v", err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	storetest.TestStore(t, func() service.UserStorer {
+		return open(t, filepath.Join(t.TempDir(), "users.db"))
+	})
+}
+
+func BenchmarkStore(b *testing.B) {
+	storebench.BenchmarkStore(b, func(b *testing.B) service.UserStorer {
+		store, err := sqlite.Open(context.Background(), filepath.Join(b.TempDir(), "users.db"))
+		if err != nil {
+			b.Fatalf("Open: %v", err)
+		}
+		b.Cleanup(func() { store.Close() })
+		return store
+	})
+}
@@ -0,0 +1,243 @@
+// Package storebench measures a UserStorer under the access patterns the
+// service produces, so stores and the decorators stacked on them can be
+// compared on the same workloads.
+//
+// An implementation runs them all from its own tests as sub-benchmarks of
+// one BenchmarkStore, a level per variant first when it has several, as
+// db/cached does per codec:
+//
+//	func BenchmarkStore(b *testing.B) {
+//		storebench.BenchmarkStore(b, newStore)
+//	}
+//
+// newStore is called once per round with the round's *testing.B, so it can
+// put files under b.TempDir and close what it opened with b.Cleanup.
+// The stores in this repo do. go test -bench Store ./db/... runs them all,
+// -bench Store/GetHot picks one workload, Store/msgpack/GetHot in
+// db/cached, and cmd/bench runs the same workloads through
+// testing.Benchmark to print them side by side.
+package storebench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// NewStore returns a fresh, empty store for one benchmark round.
+type NewStore func(b *testing.B) service.UserStorer
+
+// Benchmark is one workload.
+type Benchmark struct {
+	Name string
+	Run  func(b *testing.B, newStore NewStore)
+}
+
+func Benchmarks() []Benchmark {
+	return []Benchmark{
+		{"Insert", Insert},
+		{"GetHot", GetHot},
+		{"GetCold", GetCold},
+		{"Mixed", Mixed},
+		{"ParallelGet", ParallelGet},
+		{"ParallelMixed", ParallelMixed},
+		{"ParallelUpdate", ParallelUpdate},
+	}
+}
+
+// BenchmarkStore runs every workload as a sub-benchmark.
+func BenchmarkStore(b *testing.B, newStore NewStore) {
+	for _, bench := range Benchmarks() {
+		b.Run(bench.Name, func(b *testing.B) {
+			bench.Run(b, newStore)
+		})
+	}
+}
+
+const (
+	// seeded is how many users GetHot and Mixed start with.
+	seeded = 1000
+	// hot is how many of them GetHot reads, few enough for any cache.
+	hot = 16
+)
+
+// Insert creates a new user per op.
+func Insert(b *testing.B, newStore NewStore) {
+	ctx, store := context.Background(), newStore(b)
+	users := newUsers(b.N)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range b.N {
+		if err := store.Insert(ctx, users[i]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// GetHot reads the same few users over and over, the best case for a
+// cache in front of the store.
+func GetHot(b *testing.B, newStore NewStore) {
+	ctx, store := context.Background(), newStore(b)
+	ids := seed(b, store, seeded)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range b.N {
+		if _, err := store.Get(ctx, ids[i%hot]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// GetCold reads every user once, the worst case for a cache: each read
+// misses, and a cache pays for the lookup and the fill on top of the store.
+func GetCold(b *testing.B, newStore NewStore) {
+	ctx, store := context.Background(), newStore(b)
+	ids := seed(b, store, b.N)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range b.N {
+		if _, err := store.Get(ctx, ids[i]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Mixed is read heavy, like an API in front of the store: of every ten
+// ops, eight are reads, one renames the user it just read, and one inserts.
+func Mixed(b *testing.B, newStore NewStore) {
+	ctx, store := context.Background(), newStore(b)
+	ids := seed(b, store, seeded)
+	users := newUsers(b.N/10 + 1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range b.N {
+		switch i % 10 {
+		case 8:
+			user, err := store.Get(ctx, ids[i%len(ids)])
+			if err != nil {
+				b.Fatal(err)
+			}
+			user.Name = "renamed " + strconv.Itoa(i)
+			if err := store.Update(ctx, user); err != nil {
+				b.Fatal(err)
+			}
+		case 9:
+			if err := store.Insert(ctx, users[i/10]); err != nil {
+				b.Fatal(err)
+			}
+		default:
+			if _, err := store.Get(ctx, ids[i%len(ids)]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// ParallelGet reads from every goroutine b.RunParallel starts, GOMAXPROCS
+// of them, the load a lock shared by every reader does worst under.
+func ParallelGet(b *testing.B, newStore NewStore) {
+	parallel(b, newStore, 0)
+}
+
+// ParallelMixed is ParallelGet with two ops in ten updating the user they
+// read, like Mixed without its inserts.
+func ParallelMixed(b *testing.B, newStore NewStore) {
+	parallel(b, newStore, 2)
+}
+
+// ParallelUpdate updates the user it read on every other op.
+func ParallelUpdate(b *testing.B, newStore NewStore) {
+	parallel(b, newStore, 5)
+}
+
+// parallel reads a seeded user per op from parallel goroutines, and on
+// writes of every ten ops updates it too. Two goroutines updating the same
+// user at once is a version conflict for one of them, which is the store
+// working, not failing, and counts as an op like any other.
+func parallel(b *testing.B, newStore NewStore, writes int) {
+	ctx, store := context.Background(), newStore(b)
+	ids := seed(b, store, seeded)
+	var goroutines atomic.Int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		// each goroutine starts elsewhere in ids, so they do not walk the
+		// same users in step
+		i := int(goroutines.Add(1)) * 7919
+		for pb.Next() {
+			i++
+			user, err := store.Get(ctx, ids[i%len(ids)])
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			if i%10 < writes {
+				user.Name = "renamed"
+				if err := store.Update(ctx, user); err != nil && !errors.Is(err, errs.ErrVersionConflict) {
+					b.Error(err)
+					return
+				}
+			}
+		}
+	})
+}
+
+var rounds atomic.Int64
+
+// newUsers builds n users up front so building them is not measured. IDs
+// are unique across rounds, the way storetest keeps checks apart.
+func newUsers(n int) []*service.User {
+	p := "bench-" + strconv.FormatInt(rounds.Add(1), 36) + "-"
+	now := time.Now().UTC().Truncate(time.Second)
+	users := make([]*service.User, n)
+	for i := range users {
+		id := p + strconv.Itoa(i)
+		users[i] = &service.User{
+			ID:        id,
+			Email:     id + "@example.com",
+			Name:      "user " + strconv.Itoa(i),
+			Version:   1,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+	}
+	return users
+}
+
+// seed inserts n users and returns their IDs, in one transaction when the
+// store has them, which for a SQL store is the difference between one sync
+// and one per row.
+func seed(b *testing.B, store service.UserStorer, n int) []string {
+	b.Helper()
+	ctx := context.Background()
+	users := newUsers(n)
+	insert := func(store service.UserStorer) error {
+		for _, user := range users {
+			if err := store.Insert(ctx, user); err != nil {
+				return fmt.Errorf("storebench: seeding %s: %w", user.ID, err)
+			}
+		}
+		return nil
+	}
+	var err error
+	if tx, ok := store.(service.Transactor); ok {
+		err = tx.WithinTx(ctx, insert)
+	} else {
+		err = insert(store)
+	}
+	if err != nil {
+		b.Fatal(err)
+	}
+	ids := make([]string, n)
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+	return ids
+}
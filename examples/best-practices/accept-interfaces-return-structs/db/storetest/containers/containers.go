@@ -0,0 +1,118 @@
+// Package containers starts the databases the store tests run against in
+// throwaway Docker containers with testcontainers-go:
+//
+//	func TestStore(t *testing.T) {
+//		conn, err := sql.Open("pgx", containers.Postgres(t))
+//		...
+//	}
+//
+// Each function skips the test under -short or when there is no Docker
+// daemon, and removes its container when the test ends. Only tests import
+// it, testcontainers is not linked into any binary.
+package containers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/testcontainers/testcontainers-go"
+	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
+	tcredis "github.com/testcontainers/testcontainers-go/modules/redis"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// Postgres starts postgres:16 and returns a DSN for its users database.
+func Postgres(t testing.TB) string {
+	t.Helper()
+	ctx := start(t)
+	ctr, err := tcpostgres.Run(ctx, "postgres:16-alpine",
+		tcpostgres.WithDatabase("users"),
+		tcpostgres.WithUsername("postgres"),
+		tcpostgres.WithPassword("postgres"),
+		tcpostgres.BasicWaitStrategies(),
+	)
+	terminate(t, ctr)
+	if err != nil {
+		t.Fatalf("containers: start postgres: %v", err)
+	}
+	dsn, err := ctr.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("containers: postgres DSN: %v", err)
+	}
+	return dsn
+}
+
+// Redis starts redis:7 and returns a redis:// URL for it.
+func Redis(t testing.TB) string {
+	t.Helper()
+	ctx := start(t)
+	ctr, err := tcredis.Run(ctx, "redis:7-alpine")
+	terminate(t, ctr)
+	if err != nil {
+		t.Fatalf("containers: start redis: %v", err)
+	}
+	url, err := ctr.ConnectionString(ctx)
+	if err != nil {
+		t.Fatalf("containers: redis URL: %v", err)
+	}
+	return url
+}
+
+// DynamoDB starts dynamodb-local and returns its endpoint URL. It accepts
+// any static credentials.
+func DynamoDB(t testing.TB) string {
+	t.Helper()
+	ctx := start(t)
+	ctr, err := testcontainers.Run(ctx, "amazon/dynamodb-local:2.5.2",
+		testcontainers.WithExposedPorts("8000/tcp"),
+		testcontainers.WithWaitStrategy(wait.ForListeningPort("8000/tcp")),
+	)
+	terminate(t, ctr)
+	if err != nil {
+		t.Fatalf("containers: start dynamodb-local: %v", err)
+	}
+	endpoint, err := ctr.PortEndpoint(ctx, "8000/tcp", "http")
+	if err != nil {
+		t.Fatalf("containers: dynamodb-local endpoint: %v", err)
+	}
+	return endpoint
+}
+
+// start skips t under -short or without a healthy Docker daemon.
+func start(t testing.TB) context.Context {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("containers: skipped under -short")
+	}
+	ctx := context.Background()
+	if err := dockerHealthy(ctx); err != nil {
+		t.Skipf("containers: docker is not available: %v", err)
+	}
+	return ctx
+}
+
+// dockerHealthy asks the daemon for its info once, testcontainers panics
+// rather than erroring on some setups without one, hence the recover.
+func dockerHealthy(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	provider, err := testcontainers.NewDockerProvider()
+	if err != nil {
+		return err
+	}
+	defer provider.Close()
+	return provider.Health(ctx)
+}
+
+// terminate removes ctr when t ends, a failed Run can still have left one.
+func terminate(t testing.TB, ctr testcontainers.Container) {
+	t.Cleanup(func() {
+		if err := testcontainers.TerminateContainer(ctr); err != nil {
+			t.Errorf("containers: terminate: %v", err)
+		}
+	})
+}
@@ -0,0 +1,443 @@
+// Package storetest is the contract every UserStorer is held to: the
+// semantics the service relies on but the interface cannot express, such as
+// which sentinel a missing user produces, that Update is a compare-and-swap
+// on Version, and that both stay true under concurrent callers.
+//
+// An implementation outside this repo runs it from its own tests:
+//
+//	func TestStore(t *testing.T) {
+//		storetest.TestStore(t, func() service.UserStorer {
+//			return mystore.New(...)
+//		})
+//	}
+//
+// Each check also gets its own ID prefix, so one shared, already populated
+// store will do, which is how the postgres, redis, and dynamodb tests run
+// the contract against a single database in a container.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// ErrSkipped is returned by checks of an optional interface the store does
+// not implement.
+var ErrSkipped = errors.New("storetest: not implemented by this store")
+
+// Check is one clause of the contract.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context, store service.UserStorer) error
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name string
+	Err  error
+}
+
+func (r Result) Skipped() bool { return errors.Is(r.Err, ErrSkipped) }
+
+// Run runs every check against store, in order.
+func Run(ctx context.Context, store service.UserStorer) []Result {
+	checks := Checks()
+	results := make([]Result, len(checks))
+	for i, c := range checks {
+		results[i] = Result{Name: c.Name, Err: c.Run(ctx, store)}
+	}
+	return results
+}
+
+func Checks() []Check {
+	return []Check{
+		{"insert then get returns the user", insertGet},
+		{"get of a missing id is not found", getMissing},
+		{"insert of a taken id conflicts", insertConflict},
+		{"update bumps the version and keeps created at", update},
+		{"update with a stale version conflicts", updateStale},
+		{"update of a missing id is not found", updateMissing},
+		{"delete removes the user once", deleteOnce},
+		{"canceled context fails", canceled},
+		{"list pages in id order", list},
+		{"get by email finds the owner", getByEmail},
+		{"email is unique", emailUnique},
+		{"failed transaction rolls back", rollback},
+		{"concurrent inserts of one id, one wins", racingInserts},
+		{"concurrent updates at one version, one wins", racingUpdates},
+		{"concurrent inserts of distinct ids all land", parallelInserts},
+	}
+}
+
+// TestStore runs every check as a subtest, each against a fresh store from
+// newStore. Checks of optional interfaces the store lacks are skipped.
+func TestStore(t *testing.T, newStore func() service.UserStorer) {
+	t.Helper()
+	for _, c := range Checks() {
+		t.Run(c.Name, func(t *testing.T) {
+			err := c.Run(t.Context(), newStore())
+			if errors.Is(err, ErrSkipped) {
+				t.Skip(err)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+var runs atomic.Int64
+
+// prefix keeps one check's IDs and emails apart from every other run's. It
+// ends in "/", so list checks can page from it without seeing anyone else.
+func prefix() string {
+	return "storetest-" + strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.FormatInt(runs.Add(1), 36) + "/"
+}
+
+// newUser is truncated to the second, the coarsest timestamp any store keeps.
+func newUser(p, name string) *service.User {
+	now := time.Now().UTC().Truncate(time.Second)
+	return &service.User{
+		ID:        p + name,
+		Email:     p + name + "@example.com",
+		Status:    service.StatusPending,
+		Version:   1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+func insertGet(ctx context.Context, store service.UserStorer) error {
+	user := newUser(prefix(), "ada")
+	if err := store.Insert(ctx, user); err != nil {
+		return fmt.Errorf("Insert: %w", err)
+	}
+	got, err := store.Get(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("Get: %w", err)
+	}
+	return same(got, user)
+}
+
+func getMissing(ctx context.Context, store service.UserStorer) error {
+	_, err := store.Get(ctx, prefix()+"missing")
+	return wantErr("Get", err, errs.ErrNotFound)
+}
+
+func insertConflict(ctx context.Context, store service.UserStorer) error {
+	p := prefix()
+	if err := store.Insert(ctx, newUser(p, "ada")); err != nil {
+		return fmt.Errorf("Insert: %w", err)
+	}
+	again := newUser(p, "ada")
+	again.Email = p + "other@example.com"
+	return wantErr("second Insert", store.Insert(ctx, again), errs.ErrConflict)
+}
+
+func update(ctx context.Context, store service.UserStorer) error {
+	user := newUser(prefix(), "ada")
+	if err := store.Insert(ctx, user); err != nil {
+		return fmt.Errorf("Insert: %w", err)
+	}
+	changed := *user
+	changed.Email = "lovelace-" + user.Email
+	changed.CreatedAt = user.CreatedAt.Add(time.Hour)
+	changed.UpdatedAt = user.UpdatedAt.Add(time.Minute)
+	if err := store.Update(ctx, &changed); err != nil {
+		return fmt.Errorf("Update: %w", err)
+	}
+	if changed.Version != 2 {
+		return fmt.Errorf("Update left Version at %d, want it set to 2", changed.Version)
+	}
+	got, err := store.Get(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("Get: %w", err)
+	}
+	want := changed
+	want.CreatedAt = user.CreatedAt
+	return same(got, &want)
+}
+
+func updateStale(ctx context.Context, store service.UserStorer) error {
+	user := newUser(prefix(), "ada")
+	if err := store.Insert(ctx, user); err != nil {
+		return fmt.Errorf("Insert: %w", err)
+	}
+	stale := *user
+	stale.Email = "stale-" + user.Email
+	stale.Version = 7
+	if err := wantErr("Update", store.Update(ctx, &stale), errs.ErrVersionConflict); err != nil {
+		return err
+	}
+	got, err := store.Get(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("Get: %w", err)
+	}
+	if err := same(got, user); err != nil {
+		return fmt.Errorf("after a failed Update: %w", err)
+	}
+	return nil
+}
+
+func updateMissing(ctx context.Context, store service.UserStorer) error {
+	return wantErr("Update", store.Update(ctx, newUser(prefix(), "missing")), errs.ErrNotFound)
+}
+
+func deleteOnce(ctx context.Context, store service.UserStorer) error {
+	user := newUser(prefix(), "ada")
+	if err := store.Insert(ctx, user); err != nil {
+		return fmt.Errorf("Insert: %w", err)
+	}
+	if err := store.Delete(ctx, user.ID); err != nil {
+		return fmt.Errorf("Delete: %w", err)
+	}
+	_, err := store.Get(ctx, user.ID)
+	if err := wantErr("Get after Delete", err, errs.ErrNotFound); err != nil {
+		return err
+	}
+	return wantErr("second Delete", store.Delete(ctx, user.ID), errs.ErrNotFound)
+}
+
+func canceled(ctx context.Context, store service.UserStorer) error {
+	user := newUser(prefix(), "ada")
+	if err := store.Insert(ctx, user); err != nil {
+		return fmt.Errorf("Insert: %w", err)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := store.Get(ctx, user.ID); err == nil {
+		return errors.New("Get with a canceled context succeeded")
+	}
+	return nil
+}
+
+func list(ctx context.Context, store service.UserStorer) error {
+	lister, ok := store.(service.UserLister)
+	if !ok {
+		return fmt.Errorf("UserLister: %w", ErrSkipped)
+	}
+	p := prefix()
+	// inserted out of order, listed in order
+	for _, name := range []string{"c", "a", "e", "b", "d"} {
+		if err := store.Insert(ctx, newUser(p, name)); err != nil {
+			return fmt.Errorf("Insert %s: %w", name, err)
+		}
+	}
+	var pages [][]string
+	after := p
+	for range 3 {
+		users, err := lister.List(ctx, after, 2)
+		if err != nil {
+			return fmt.Errorf("List after %q: %w", after, err)
+		}
+		var ids []string
+		for _, u := range users {
+			id, ok := strings.CutPrefix(u.ID, p)
+			if !ok {
+				break
+			}
+			ids = append(ids, id)
+		}
+		pages = append(pages, ids)
+		if len(ids) == 0 {
+			break
+		}
+		after = p + ids[len(ids)-1]
+	}
+	if got, want := fmt.Sprint(pages), "[[a b] [c d] [e]]"; got != want {
+		return fmt.Errorf("pages of 2 = %s, want %s", got, want)
+	}
+	return nil
+}
+
+func getByEmail(ctx context.Context, store service.UserStorer) error {
+	finder, ok := store.(service.UserFinder)
+	if !ok {
+		return fmt.Errorf("UserFinder: %w", ErrSkipped)
+	}
+	user := newUser(prefix(), "ada")
+	if err := store.Insert(ctx, user); err != nil {
+		return fmt.Errorf("Insert: %w", err)
+	}
+	got, err := finder.GetByEmail(ctx, user.Email)
+	if err != nil {
+		return fmt.Errorf("GetByEmail: %w", err)
+	}
+	if err := same(got, user); err != nil {
+		return err
+	}
+	_, err = finder.GetByEmail(ctx, "missing-"+user.Email)
+	return wantErr("GetByEmail of a missing email", err, errs.ErrNotFound)
+}
+
+// emailUnique is part of the UserFinder contract, stores without the index
+// make no promise about duplicates.
+func emailUnique(ctx context.Context, store service.UserStorer) error {
+	if _, ok := store.(service.UserFinder); !ok {
+		return fmt.Errorf("UserFinder: %w", ErrSkipped)
+	}
+	p := prefix()
+	ada, grace := newUser(p, "ada"), newUser(p, "grace")
+	if err := store.Insert(ctx, ada); err != nil {
+		return fmt.Errorf("Insert: %w", err)
+	}
+	twin := newUser(p, "twin")
+	twin.Email = ada.Email
+	if err := wantErr("Insert with a taken email", store.Insert(ctx, twin), errs.ErrConflict); err != nil {
+		return err
+	}
+	if err := store.Insert(ctx, grace); err != nil {
+		return fmt.Errorf("Insert: %w", err)
+	}
+	grace.Email = ada.Email
+	return wantErr("Update to a taken email", store.Update(ctx, grace), errs.ErrConflict)
+}
+
+func rollback(ctx context.Context, store service.UserStorer) error {
+	tx, ok := store.(service.Transactor)
+	if !ok {
+		return fmt.Errorf("Transactor: %w", ErrSkipped)
+	}
+	p := prefix()
+	errAbort := errors.New("abort")
+	err := tx.WithinTx(ctx, func(store service.UserStorer) error {
+		if err := store.Insert(ctx, newUser(p, "ada")); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		return fmt.Errorf("WithinTx = %v, want fn's error back", err)
+	}
+	_, err = store.Get(ctx, p+"ada")
+	if err := wantErr("Get after rollback", err, errs.ErrNotFound); err != nil {
+		return err
+	}
+	err = tx.WithinTx(ctx, func(store service.UserStorer) error {
+		return store.Insert(ctx, newUser(p, "grace"))
+	})
+	if err != nil {
+		return fmt.Errorf("WithinTx: %w", err)
+	}
+	if _, err := store.Get(ctx, p+"grace"); err != nil {
+		return fmt.Errorf("Get after commit: %w", err)
+	}
+	return nil
+}
+
+// racers is how many goroutines the concurrency checks start at once.
+const racers = 16
+
+// race calls fn from racers goroutines released together and returns their
+// errors.
+func race(fn func(i int) error) []error {
+	results := make([]error, racers)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range racers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			results[i] = fn(i)
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return results
+}
+
+// oneWinner checks that exactly one of errors is nil and the rest are want.
+func oneWinner(op string, results []error, want error) error {
+	wins := 0
+	for _, err := range results {
+		switch {
+		case err == nil:
+			wins++
+		case !errors.Is(err, want):
+			return fmt.Errorf("concurrent %s = %v, want nil or %v", op, err, want)
+		}
+	}
+	if wins != 1 {
+		return fmt.Errorf("%d of %d concurrent %s calls succeeded, want exactly 1", wins, racers, op)
+	}
+	return nil
+}
+
+func racingInserts(ctx context.Context, store service.UserStorer) error {
+	p := prefix()
+	results := race(func(i int) error {
+		user := newUser(p, "ada")
+		user.Email = p + strconv.Itoa(i) + "@example.com"
+		return store.Insert(ctx, user)
+	})
+	return oneWinner("Insert", results, errs.ErrConflict)
+}
+
+func racingUpdates(ctx context.Context, store service.UserStorer) error {
+	user := newUser(prefix(), "ada")
+	if err := store.Insert(ctx, user); err != nil {
+		return fmt.Errorf("Insert: %w", err)
+	}
+	results := race(func(i int) error {
+		changed := *user
+		changed.Email = strconv.Itoa(i) + "-" + user.Email
+		return store.Update(ctx, &changed)
+	})
+	if err := oneWinner("Update", results, errs.ErrVersionConflict); err != nil {
+		return err
+	}
+	got, err := store.Get(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("Get: %w", err)
+	}
+	if got.Version != 2 {
+		return fmt.Errorf("Version after the race = %d, want 2", got.Version)
+	}
+	return nil
+}
+
+func parallelInserts(ctx context.Context, store service.UserStorer) error {
+	p := prefix()
+	results := race(func(i int) error {
+		return store.Insert(ctx, newUser(p, strconv.Itoa(i)))
+	})
+	for i, err := range results {
+		if err != nil {
+			return fmt.Errorf("Insert %d: %w", i, err)
+		}
+	}
+	for i := range racers {
+		if _, err := store.Get(ctx, p+strconv.Itoa(i)); err != nil {
+			return fmt.Errorf("Get %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func wantErr(op string, err, want error) error {
+	if !errors.Is(err, want) {
+		return fmt.Errorf("%s = %v, want %v", op, err, want)
+	}
+	return nil
+}
+
+// same compares the fields every store round trips.
+func same(got, want *service.User) error {
+	switch {
+	case got.ID != want.ID, got.Email != want.Email, got.Status != want.Status, got.Version != want.Version,
+		!got.CreatedAt.Equal(want.CreatedAt), !got.UpdatedAt.Equal(want.UpdatedAt):
+		return fmt.Errorf("got %+v, want %+v", *got, *want)
+	}
+	return nil
+}
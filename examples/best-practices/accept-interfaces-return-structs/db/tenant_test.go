@@ -0,0 +1,38 @@
+package db_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// TestSnapshotTenants checks a memory snapshot keeps tenants apart, the
+// default tenant's users included.
+func TestSnapshotTenants(t *testing.T) {
+	ctx := context.Background()
+	saved := db.NewMemoryStore()
+	tenants := []string{"", "acme", "globex"}
+	for _, tenant := range tenants {
+		if err := saved.Insert(ctxutil.WithTenant(ctx, tenant), &service.User{ID: "ada", Email: "ada@" + tenant + ".example", Version: 1}); err != nil {
+			t.Fatalf("Insert in %q: %v", tenant, err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "users.gob")
+	if err := saved.Save(ctx, path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded := db.NewMemoryStore()
+	if err := loaded.Load(ctx, path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for _, tenant := range tenants {
+		ada, err := loaded.Get(ctxutil.WithTenant(ctx, tenant), "ada")
+		if want := "ada@" + tenant + ".example"; err != nil || ada.Email != want {
+			t.Errorf("ada in %q = %v, %v, want %s", tenant, ada, err, want)
+		}
+	}
+}
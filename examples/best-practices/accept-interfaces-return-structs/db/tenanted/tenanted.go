@@ -0,0 +1,174 @@
+package tenanted
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/outbox"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/query"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// ErrNoTenant is returned for a call whose context names no tenant. It
+// wraps errs.ErrForbidden: a caller the deployment could not place in a
+// tenant is not allowed anyone's users.
+var ErrNoTenant = fmt.Errorf("no tenant: %w", errs.ErrForbidden)
+
+// Isolating is a store that keeps tenants apart: every call works in the
+// keyspace of ctxutil.Tenant of its context and cannot see another's.
+// db.MemoryStore and the sqlite and postgres stores are. IsolatesTenants
+// does nothing, it is how a store says so.
+type Isolating interface {
+	service.UserStorer
+	IsolatesTenants()
+}
+
+// Store decorates an Isolating store so it cannot be reached without a
+// tenant. The store already scopes each call to the context's tenant, but
+// puts a call without one in the default tenant, ""; through the Store
+// that call fails with ErrNoTenant instead, and a request that lost its
+// tenant on the way in reads nobody's users rather than the default's.
+//
+// Nothing between the Store and the store may hold users by ID alone, a
+// cache or a Bloom filter would answer one tenant with another's. So the
+// Store wraps the store itself, and decorators such as logged or retry go
+// outside it.
+type Store struct {
+	next Isolating
+}
+
+// New wraps next, which must keep tenants apart itself.
+func New(next Isolating) *Store {
+	return &Store{next: next}
+}
+
+// IsolatesTenants makes the Store Isolating as well, wrapping it twice
+// changes nothing.
+func (s *Store) IsolatesTenants() {}
+
+// tenant checks that ctx names a tenant other than the default one, "",
+// before op goes on to the store.
+func tenant(ctx context.Context, op string) error {
+	if id, ok := ctxutil.Tenant(ctx); !ok || id == "" {
+		return errs.Wrap(op, ErrNoTenant)
+	}
+	return nil
+}
+
+func (s *Store) Insert(ctx context.Context, user *service.User) error {
+	if err := tenant(ctx, "tenanted.Store.Insert"); err != nil {
+		return err
+	}
+	return s.next.Insert(ctx, user)
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*service.User, error) {
+	if err := tenant(ctx, "tenanted.Store.Get"); err != nil {
+		return nil, err
+	}
+	return s.next.Get(ctx, id)
+}
+
+func (s *Store) Update(ctx context.Context, user *service.User) error {
+	if err := tenant(ctx, "tenanted.Store.Update"); err != nil {
+		return err
+	}
+	return s.next.Update(ctx, user)
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	if err := tenant(ctx, "tenanted.Store.Delete"); err != nil {
+		return err
+	}
+	return s.next.Delete(ctx, id)
+}
+
+func (s *Store) GetByEmail(ctx context.Context, email string) (*service.User, error) {
+	finder, ok := s.next.(service.UserFinder)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	if err := tenant(ctx, "tenanted.Store.GetByEmail"); err != nil {
+		return nil, err
+	}
+	return finder.GetByEmail(ctx, email)
+}
+
+func (s *Store) List(ctx context.Context, after string, limit int) ([]*service.User, error) {
+	lister, ok := s.next.(service.UserLister)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	if err := tenant(ctx, "tenanted.Store.List"); err != nil {
+		return nil, err
+	}
+	return lister.List(ctx, after, limit)
+}
+
+func (s *Store) Query(ctx context.Context, q query.Query) ([]*service.User, error) {
+	querier, ok := s.next.(service.UserQuerier)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	if err := tenant(ctx, "tenanted.Store.Query"); err != nil {
+		return nil, err
+	}
+	return querier.Query(ctx, q)
+}
+
+// InsertMany forwards to the wrapped store's bulk insert when it has one.
+// Without a tenant every user fails with ErrNoTenant.
+func (s *Store) InsertMany(ctx context.Context, users []*service.User) []error {
+	results := make([]error, len(users))
+	if err := tenant(ctx, "tenanted.Store.InsertMany"); err != nil {
+		for i := range results {
+			results[i] = err
+		}
+		return results
+	}
+	b, ok := s.next.(service.BatchInserter)
+	if !ok {
+		for i, user := range users {
+			results[i] = s.next.Insert(ctx, user)
+		}
+		return results
+	}
+	return b.InsertMany(ctx, users)
+}
+
+// AppendOutbox forwards to the wrapped store when it is a
+// service.OutboxAppender. The outbox is the store's, not a tenant's, but
+// a message is only appended for a change made in one.
+func (s *Store) AppendOutbox(ctx context.Context, msg outbox.Message) error {
+	appender, ok := s.next.(service.OutboxAppender)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	if err := tenant(ctx, "tenanted.Store.AppendOutbox"); err != nil {
+		return err
+	}
+	return appender.AppendOutbox(ctx, msg)
+}
+
+// WithinTx forwards to the wrapped store when it is a service.Transactor,
+// the transaction's store decorated the same way. Each call fn makes on it
+// is checked like any other, against the context that call passes.
+func (s *Store) WithinTx(ctx context.Context, fn func(service.UserStorer) error) error {
+	if err := tenant(ctx, "tenanted.Store.WithinTx"); err != nil {
+		return err
+	}
+	tx, ok := s.next.(service.Transactor)
+	if !ok {
+		return fn(s)
+	}
+	return tx.WithinTx(ctx, func(inner service.UserStorer) error {
+		isolating, ok := inner.(Isolating)
+		if !ok {
+			return errs.Wrap("tenanted.Store.WithinTx", errors.ErrUnsupported)
+		}
+		return fn(&Store{next: isolating})
+	})
+}
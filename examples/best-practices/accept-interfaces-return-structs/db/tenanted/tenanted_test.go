@@ -0,0 +1,200 @@
+package tenanted_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/sqlite"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storetest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/tenanted"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/query"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// isolating is a store the tests run against: a tenanted.Isolating with
+// the optional interfaces every store here has.
+type isolating interface {
+	tenanted.Isolating
+	service.UserLister
+	service.UserQuerier
+	service.UserFinder
+	service.Transactor
+}
+
+// forEachStore runs fn as a subtest against a tenanted.Store over each
+// store, with the raw store under it for the calls that skip it.
+func forEachStore(t *testing.T, fn func(t *testing.T, raw isolating, store *tenanted.Store)) {
+	for _, st := range []struct {
+		name string
+		open func(t *testing.T) isolating
+	}{
+		{"memory", func(*testing.T) isolating { return db.NewMemoryStore() }},
+		{"sqlite", func(t *testing.T) isolating {
+			store, err := sqlite.Open(t.Context(), ":memory:")
+			if err != nil {
+				t.Fatalf("sqlite.Open: %v", err)
+			}
+			t.Cleanup(func() { store.Close() })
+			return store
+		}},
+	} {
+		t.Run(st.name, func(t *testing.T) {
+			raw := st.open(t)
+			fn(t, raw, tenanted.New(raw))
+		})
+	}
+}
+
+// tenants returns contexts in acme and in globex.
+func tenants(t *testing.T) (acme, globex context.Context) {
+	return ctxutil.WithTenant(t.Context(), "acme"), ctxutil.WithTenant(t.Context(), "globex")
+}
+
+// wantUser checks what ctx's tenant sees of id.
+func wantUser(t *testing.T, store service.UserStorer, ctx context.Context, id, email string, version int64) {
+	t.Helper()
+	user, err := store.Get(ctx, id)
+	if err != nil {
+		t.Errorf("Get(%s): %v", id, err)
+		return
+	}
+	if user.Email != email || user.Version != version {
+		t.Errorf("Get(%s) = %s at version %d, want %s at %d", id, user.Email, user.Version, email, version)
+	}
+}
+
+func wantErr(t *testing.T, op string, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Errorf("%s: err = %v, want %v", op, err, want)
+	}
+}
+
+// wantNone checks a List or Query found no one.
+func wantNone(t *testing.T, op string, users []*service.User, err error) {
+	t.Helper()
+	if err != nil || len(users) != 0 {
+		t.Errorf("%s = %d users, %v, want none", op, len(users), err)
+	}
+}
+
+// errOf drops the result a call returned with err.
+func errOf[T any](_ T, err error) error {
+	return err
+}
+
+// TestStore holds a tenanted store to the contract inside one tenant.
+func TestStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, _ isolating, store *tenanted.Store) {
+		acme, _ := tenants(t)
+		for _, c := range storetest.Checks() {
+			t.Run(c.Name, func(t *testing.T) {
+				err := c.Run(acme, store)
+				if errors.Is(err, storetest.ErrSkipped) {
+					t.Skip(err)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			})
+		}
+	})
+}
+
+// TestIsolation checks one tenant cannot read or change another's users.
+func TestIsolation(t *testing.T) {
+	forEachStore(t, func(t *testing.T, raw isolating, store *tenanted.Store) {
+		acme, globex := tenants(t)
+		ada := &service.User{ID: "ada", Email: "ada@example.com", Name: "Ada Lovelace", Version: 1}
+		if err := store.Insert(acme, ada); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+		wantErr(t, "Get", errOf(store.Get(globex, "ada")), errs.ErrNotFound)
+		wantErr(t, "GetByEmail", errOf(store.GetByEmail(globex, ada.Email)), errs.ErrNotFound)
+		users, err := store.List(globex, "", 10)
+		wantNone(t, "List", users, err)
+		users, err = store.Query(globex, query.Users().WhereEmailLike("%@example.com"))
+		wantNone(t, "Query", users, err)
+		wantErr(t, "Update", store.Update(globex, &service.User{ID: "ada", Email: "mallory@example.com", Version: 1}), errs.ErrNotFound)
+		wantErr(t, "Delete", store.Delete(globex, "ada"), errs.ErrNotFound)
+		// nor is it in the default tenant, for code that skips the Store
+		wantErr(t, "Get without a tenant", errOf(raw.Get(context.Background(), "ada")), errs.ErrNotFound)
+		wantUser(t, store, acme, "ada", "ada@example.com", 1)
+	})
+}
+
+// TestSameIDs checks tenants can hold the same ID and email, each unique
+// within its tenant still.
+func TestSameIDs(t *testing.T) {
+	forEachStore(t, func(t *testing.T, _ isolating, store *tenanted.Store) {
+		acme, globex := tenants(t)
+		for _, tenant := range []context.Context{acme, globex} {
+			if err := store.Insert(tenant, &service.User{ID: "ada", Email: "ada@example.com", Version: 1}); err != nil {
+				t.Fatalf("Insert: %v", err)
+			}
+		}
+		if err := store.Update(acme, &service.User{ID: "ada", Email: "ada@acme.example", Version: 1}); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		wantUser(t, store, acme, "ada", "ada@acme.example", 2)
+		wantUser(t, store, globex, "ada", "ada@example.com", 1)
+		wantErr(t, "a second ada in acme", store.Insert(acme, &service.User{ID: "ada", Email: "other@example.com", Version: 1}), errs.ErrConflict)
+	})
+}
+
+// TestNoTenant checks a call without a tenant never reaches the store.
+func TestNoTenant(t *testing.T) {
+	forEachStore(t, func(t *testing.T, raw isolating, store *tenanted.Store) {
+		ctx := context.Background()
+		user := &service.User{ID: "ada", Email: "ada@example.com", Version: 1}
+		for _, c := range []struct {
+			name string
+			ctx  context.Context
+		}{
+			{"none", ctx},
+			{"blank", ctxutil.WithTenant(ctx, "")},
+		} {
+			t.Run(c.name, func(t *testing.T) {
+				for i, err := range store.InsertMany(c.ctx, []*service.User{user, user}) {
+					wantErr(t, fmt.Sprintf("InsertMany[%d]", i), err, tenanted.ErrNoTenant)
+				}
+				wantErr(t, "Insert", store.Insert(c.ctx, user), tenanted.ErrNoTenant)
+				wantErr(t, "Get", errOf(store.Get(c.ctx, "ada")), tenanted.ErrNoTenant)
+				wantErr(t, "List", errOf(store.List(c.ctx, "", 10)), tenanted.ErrNoTenant)
+				wantErr(t, "WithinTx", store.WithinTx(c.ctx, func(service.UserStorer) error { return nil }), tenanted.ErrNoTenant)
+				// ErrNoTenant is a refusal, whatever the caller meant
+				wantErr(t, "Insert as forbidden", store.Insert(c.ctx, user), errs.ErrForbidden)
+			})
+		}
+		users, err := raw.List(ctx, "", 10)
+		wantNone(t, "the default tenant", users, err)
+	})
+}
+
+// TestWithinTx checks a transaction stays in its tenant.
+func TestWithinTx(t *testing.T) {
+	forEachStore(t, func(t *testing.T, _ isolating, store *tenanted.Store) {
+		acme, globex := tenants(t)
+		if err := store.WithinTx(acme, func(tx service.UserStorer) error {
+			return tx.Insert(acme, &service.User{ID: "ada", Email: "ada@example.com", Version: 1})
+		}); err != nil {
+			t.Fatalf("WithinTx: %v", err)
+		}
+		rollback := errors.New("roll back")
+		err := store.WithinTx(acme, func(tx service.UserStorer) error {
+			if err := tx.Insert(acme, &service.User{ID: "bob", Email: "bob@example.com", Version: 1}); err != nil {
+				return err
+			}
+			return rollback
+		})
+		wantErr(t, "WithinTx", err, rollback)
+		wantUser(t, store, acme, "ada", "ada@example.com", 1)
+		wantErr(t, "the rolled back bob", errOf(store.Get(acme, "bob")), errs.ErrNotFound)
+		wantErr(t, "ada in globex", errOf(store.Get(globex, "ada")), errs.ErrNotFound)
+	})
+}
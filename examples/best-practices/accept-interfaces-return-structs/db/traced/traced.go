@@ -0,0 +1,162 @@
+package traced
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/outbox"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/query"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+const instrumentationName = "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/traced"
+
+// Store decorates a UserStorer with one span per call, named "store.<op>"
+// and parented to whatever span is in the context, usually the service
+// method's. The optional store interfaces are forwarded like db/logged does.
+type Store struct {
+	next   service.UserStorer
+	tracer trace.Tracer
+	system string
+}
+
+// New traces calls to next on tp. system names the backing database in the
+// db.system attribute, e.g. "sqlite" or "redis".
+func New(next service.UserStorer, tp trace.TracerProvider, system string) *Store {
+	return &Store{
+		next:   next,
+		tracer: tp.Tracer(instrumentationName),
+		system: system,
+	}
+}
+
+func (s *Store) Insert(ctx context.Context, user *service.User) error {
+	ctx, span := s.start(ctx, "insert", attribute.String("user.id", user.ID))
+	err := s.next.Insert(ctx, user)
+	end(span, err)
+	return err
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*service.User, error) {
+	ctx, span := s.start(ctx, "get", attribute.String("user.id", id))
+	user, err := s.next.Get(ctx, id)
+	end(span, err)
+	return user, err
+}
+
+func (s *Store) GetByEmail(ctx context.Context, email string) (*service.User, error) {
+	finder, ok := s.next.(service.UserFinder)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	ctx, span := s.start(ctx, "get_by_email")
+	user, err := finder.GetByEmail(ctx, email)
+	end(span, err)
+	return user, err
+}
+
+func (s *Store) List(ctx context.Context, after string, limit int) ([]*service.User, error) {
+	lister, ok := s.next.(service.UserLister)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	ctx, span := s.start(ctx, "list", attribute.Int("page.limit", limit))
+	users, err := lister.List(ctx, after, limit)
+	span.SetAttributes(attribute.Int("page.rows", len(users)))
+	end(span, err)
+	return users, err
+}
+
+func (s *Store) Query(ctx context.Context, q query.Query) ([]*service.User, error) {
+	querier, ok := s.next.(service.UserQuerier)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	ctx, span := s.start(ctx, "query")
+	users, err := querier.Query(ctx, q)
+	span.SetAttributes(attribute.Int("page.rows", len(users)))
+	end(span, err)
+	return users, err
+}
+
+func (s *Store) Update(ctx context.Context, user *service.User) error {
+	ctx, span := s.start(ctx, "update", attribute.String("user.id", user.ID), attribute.Int64("user.version", user.Version))
+	err := s.next.Update(ctx, user)
+	end(span, err)
+	return err
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	ctx, span := s.start(ctx, "delete", attribute.String("user.id", id))
+	err := s.next.Delete(ctx, id)
+	end(span, err)
+	return err
+}
+
+// InsertMany traces the bulk insert as a single span, falling back to one
+// Insert span per user when the wrapped store has no bulk insert.
+func (s *Store) InsertMany(ctx context.Context, users []*service.User) []error {
+	b, ok := s.next.(service.BatchInserter)
+	if !ok {
+		results := make([]error, len(users))
+		for i, user := range users {
+			results[i] = s.Insert(ctx, user)
+		}
+		return results
+	}
+	ctx, span := s.start(ctx, "insert_many", attribute.Int("batch.size", len(users)))
+	results := b.InsertMany(ctx, users)
+	failed := 0
+	for _, err := range results {
+		if err != nil {
+			failed++
+		}
+	}
+	span.SetAttributes(attribute.Int("batch.failed", failed))
+	span.End()
+	return results
+}
+
+// AppendOutbox forwards to the wrapped store when it is a service.OutboxAppender.
+func (s *Store) AppendOutbox(ctx context.Context, msg outbox.Message) error {
+	appender, ok := s.next.(service.OutboxAppender)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	ctx, span := s.start(ctx, "append_outbox", attribute.String("outbox.topic", msg.Topic))
+	err := appender.AppendOutbox(ctx, msg)
+	end(span, err)
+	return err
+}
+
+// WithinTx forwards to the wrapped store when it is a service.Transactor.
+// The transaction gets its own span covering every call made through it.
+func (s *Store) WithinTx(ctx context.Context, fn func(service.UserStorer) error) error {
+	tx, ok := s.next.(service.Transactor)
+	if !ok {
+		return fn(s)
+	}
+	ctx, span := s.start(ctx, "tx")
+	err := tx.WithinTx(ctx, func(inner service.UserStorer) error {
+		return fn(&Store{next: inner, tracer: s.tracer, system: s.system})
+	})
+	end(span, err)
+	return err
+}
+
+func (s *Store) start(ctx context.Context, op string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("db.system", s.system), attribute.String("db.operation", op))
+	return s.tracer.Start(ctx, "store."+op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
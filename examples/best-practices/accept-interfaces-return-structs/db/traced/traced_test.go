@@ -0,0 +1,18 @@
+package traced_test
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/storetest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/traced"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+func TestStore(t *testing.T) {
+	storetest.TestStore(t, func() service.UserStorer {
+		return traced.New(db.NewMemoryStore(), noop.NewTracerProvider(), "memory")
+	})
+}
@@ -0,0 +1,206 @@
+// Package debounce shapes bursts of calls or values in time. Debounce waits
+// for a burst to settle and delivers only its last value, Throttle delivers
+// at most one value per interval. Each comes as a channel stage and as a
+// function wrapper built on it, and both stop when their context ends.
+package debounce
+
+import (
+	"context"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+)
+
+// Option configures a debounce or throttle stage.
+type Option func(*options)
+
+type options struct {
+	clock clock.Clock
+}
+
+// WithClock sets the time source, the default is clock.Real.
+func WithClock(c clock.Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{clock: clock.Real}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// DebounceChan emits a value once wait has passed without a newer one
+// arriving on in, so a burst of values becomes its last. When in is closed
+// the pending value is flushed immediately and the output closed, when ctx
+// ends the output is closed and anything pending is dropped.
+func DebounceChan[T any](ctx context.Context, wait time.Duration, in <-chan T, opts ...Option) <-chan T {
+	o := newOptions(opts)
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		var (
+			timer   clock.Timer
+			timerC  <-chan time.Time
+			pending T
+			has     bool
+			// ready holds a settled value until the reader takes it,
+			// outC is nil while there is nothing to send
+			ready T
+			outC  chan<- T
+		)
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					if outC != nil && !send(ctx, out, ready) {
+						return
+					}
+					if has {
+						send(ctx, out, pending)
+					}
+					return
+				}
+				pending, has = v, true
+				// a fresh timer each time, a reset one could still hold a
+				// tick that fired before the reset
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = o.clock.NewTimer(wait)
+				timerC = timer.C()
+			case <-timerC:
+				timerC = nil
+				ready, has = pending, false
+				outC = out
+			case outC <- ready:
+				outC = nil
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// ThrottleChan emits the first value from in straight away and then at most
+// one per interval: the latest value to arrive during an interval is emitted
+// when it ends, the ones before it are dropped. When in is closed a pending
+// value is still emitted at the end of its interval, when ctx ends the
+// output is closed and anything pending is dropped.
+func ThrottleChan[T any](ctx context.Context, interval time.Duration, in <-chan T, opts ...Option) <-chan T {
+	o := newOptions(opts)
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		var (
+			timer clock.Timer
+			// timerC is non-nil while an interval is running
+			timerC  <-chan time.Time
+			pending T
+			has     bool
+			ready   T
+			outC    chan<- T
+		)
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+		startInterval := func() {
+			timer = o.clock.NewTimer(interval)
+			timerC = timer.C()
+		}
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					if outC != nil && !send(ctx, out, ready) {
+						return
+					}
+					if has && timerC != nil {
+						select {
+						case <-timerC:
+						case <-ctx.Done():
+							return
+						}
+						send(ctx, out, pending)
+					}
+					return
+				}
+				if timerC == nil {
+					ready, outC = v, out
+					startInterval()
+					continue
+				}
+				pending, has = v, true
+			case <-timerC:
+				timerC = nil
+				if has {
+					ready, has = pending, false
+					outC = out
+					startInterval()
+				}
+			case outC <- ready:
+				outC = nil
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Debounce wraps fn so a burst of calls runs it once, with the last call's
+// argument, after wait has passed without another call. fn runs on its own
+// goroutine, one call at a time. Calls after ctx ends are ignored.
+func Debounce[T any](ctx context.Context, wait time.Duration, fn func(T), opts ...Option) func(T) {
+	return wrap(ctx, fn, func(in <-chan T) <-chan T {
+		return DebounceChan(ctx, wait, in, opts...)
+	})
+}
+
+// Throttle wraps fn so it runs at most once per interval: the first call
+// runs straight away, the last call made during an interval runs when it
+// ends. fn runs on its own goroutine, one call at a time. Calls after ctx
+// ends are ignored.
+func Throttle[T any](ctx context.Context, interval time.Duration, fn func(T), opts ...Option) func(T) {
+	return wrap(ctx, fn, func(in <-chan T) <-chan T {
+		return ThrottleChan(ctx, interval, in, opts...)
+	})
+}
+
+// wrap feeds calls into a channel stage and runs fn on what comes out. The
+// stage keeps reading while fn runs, so a slow fn does not block callers.
+func wrap[T any](ctx context.Context, fn func(T), stage func(<-chan T) <-chan T) func(T) {
+	in := make(chan T)
+	out := stage(in)
+	go func() {
+		for v := range out {
+			fn(v)
+		}
+	}()
+	return func(v T) {
+		select {
+		case in <- v:
+		case <-ctx.Done():
+		}
+	}
+}
+
+func send[T any](ctx context.Context, out chan<- T, v T) bool {
+	select {
+	case out <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
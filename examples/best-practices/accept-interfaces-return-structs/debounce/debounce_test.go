@@ -0,0 +1,169 @@
+package debounce_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/debounce"
+)
+
+// TestMain fails the package if a debounced or throttled func keeps its
+// goroutine after its context ends.
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+const wait = 100 * time.Millisecond
+
+// signalingClock is a fake clock that reports each timer the stage under
+// test creates, so a test knows the stage has taken in a value before it
+// moves time forward.
+type signalingClock struct {
+	*clock.Fake
+	created chan struct{}
+}
+
+func newClock() *signalingClock {
+	return &signalingClock{Fake: clock.NewFake(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)), created: make(chan struct{}, 16)}
+}
+
+func (c *signalingClock) NewTimer(d time.Duration) clock.Timer {
+	t := c.Fake.NewTimer(d)
+	c.created <- struct{}{}
+	return t
+}
+
+func TestDebounceChan(t *testing.T) {
+	c := newClock()
+	in := make(chan string)
+	out := debounce.DebounceChan(context.Background(), wait, in, debounce.WithClock(c))
+	send := func(v string) {
+		in <- v
+		<-c.created
+		c.Advance(10 * time.Millisecond)
+	}
+
+	// a pause shorter than wait keeps the burst going
+	send("a")
+	send("b")
+	send("c")
+	c.Advance(wait - 20*time.Millisecond)
+	send("d")
+	c.Advance(wait)
+	if got := <-out; got != "d" {
+		t.Fatalf("first burst settled on %q, want d", got)
+	}
+
+	// closing the input flushes what is pending without waiting
+	send("e")
+	send("f")
+	close(in)
+	var rest []string
+	for v := range out {
+		rest = append(rest, v)
+	}
+	if !slices.Equal(rest, []string{"f"}) {
+		t.Errorf("after close got %v, want [f]", rest)
+	}
+}
+
+func TestThrottleChan(t *testing.T) {
+	c := newClock()
+	in := make(chan int)
+	out := debounce.ThrottleChan(context.Background(), wait, in, debounce.WithClock(c))
+
+	// the first value goes straight out and starts an interval
+	in <- 1
+	<-c.created
+	if got := <-out; got != 1 {
+		t.Fatalf("first value = %d, want 1 right away", got)
+	}
+	// of the values during the interval only the last survives, at its end
+	in <- 2
+	in <- 3
+	c.Advance(wait)
+	<-c.created
+	if got := <-out; got != 3 {
+		t.Fatalf("end of the interval = %d, want 3", got)
+	}
+	// an interval with nothing in it lets the next value straight through
+	c.Advance(wait)
+	in <- 4
+	<-c.created
+	if got := <-out; got != 4 {
+		t.Fatalf("after a quiet interval = %d, want 4", got)
+	}
+
+	// a value pending when the input closes still waits out its interval
+	in <- 5
+	close(in)
+	next := make(chan int)
+	go func() {
+		for v := range out {
+			next <- v
+		}
+		close(next)
+	}()
+	c.Advance(wait)
+	if got := <-next; got != 5 {
+		t.Errorf("pending at close = %d, want 5", got)
+	}
+	if _, ok := <-next; ok {
+		t.Error("output still open after the input closed")
+	}
+}
+
+func TestContextEnds(t *testing.T) {
+	c := newClock()
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan int)
+	out := debounce.DebounceChan(ctx, wait, in, debounce.WithClock(c))
+	in <- 1
+	<-c.created
+	cancel()
+	if v, ok := <-out; ok {
+		t.Errorf("got %d after cancel, want the output closed and 1 dropped", v)
+	}
+	// the stopped stage leaves no timer behind
+	if c.Waiters() != 0 {
+		t.Errorf("Waiters = %d after cancel, want 0", c.Waiters())
+	}
+}
+
+func TestWrappers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := newClock()
+	searched := make(chan string)
+	search := debounce.Debounce(ctx, wait, func(q string) { searched <- q }, debounce.WithClock(c))
+	for _, q := range []string{"g", "go", "gop"} {
+		search(q)
+		<-c.created
+	}
+	c.Advance(wait)
+	if got := <-searched; got != "gop" {
+		t.Errorf("Debounce ran with %q, want gop", got)
+	}
+
+	c = newClock()
+	reported := make(chan int)
+	progress := debounce.Throttle(ctx, wait, func(n int) { reported <- n }, debounce.WithClock(c))
+	progress(1)
+	<-c.created
+	if got := <-reported; got != 1 {
+		t.Fatalf("Throttle ran with %d first, want 1", got)
+	}
+	for n := 2; n <= 100; n++ {
+		progress(n)
+	}
+	c.Advance(wait)
+	if got := <-reported; got != 100 {
+		t.Errorf("Throttle ran with %d at the end of the interval, want 100", got)
+	}
+}
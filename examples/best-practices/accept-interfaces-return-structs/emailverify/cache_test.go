@@ -0,0 +1,65 @@
+package emailverify_test
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/emailverify"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/emailverify/emailverifytest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ttlcache"
+)
+
+// TestCache asks about the same two addresses three times: the
+// deliverable verdict is cached for the day, the undeliverable one for
+// ten minutes.
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	api := &emailverifytest.API{Respond: func(email string) emailverifytest.Response {
+		if email == "alan@mail.invalid" {
+			return emailverifytest.Undeliverable("domain has no mail server")
+		}
+		return emailverifytest.Deliverable()
+	}}
+	verdicts := ttlcache.New[string, emailverify.Result](24*time.Hour, ttlcache.WithClock(fake))
+	t.Cleanup(verdicts.Close)
+	verifier := emailverify.New(api.Doer(), "https://verify.test/v1/check",
+		emailverify.WithCache(verdicts), emailverify.WithUndeliverableTTL(10*time.Minute))
+	for _, step := range []time.Duration{0, time.Minute, 10 * time.Minute} {
+		fake.Advance(step)
+		for _, email := range []string{"ada@example.com", "alan@mail.invalid"} {
+			if _, err := verifier.Verify(ctx, email); err != nil {
+				t.Fatalf("Verify(%s): %v", email, err)
+			}
+		}
+	}
+	want := []string{"ada@example.com", "alan@mail.invalid", "alan@mail.invalid"}
+	if got := api.Emails(); !slices.Equal(got, want) {
+		t.Errorf("API asked about %q, want %q", got, want)
+	}
+	if rate := verdicts.Stats().HitRate(); rate != 0.5 {
+		t.Errorf("HitRate = %v, want 0.5", rate)
+	}
+}
+
+// TestCacheOutage checks an error is asked about again, not cached.
+func TestCacheOutage(t *testing.T) {
+	api := &emailverifytest.API{Respond: func(string) emailverifytest.Response {
+		return emailverifytest.Response{Status: http.StatusServiceUnavailable, Body: "overloaded"}
+	}}
+	verdicts := ttlcache.New[string, emailverify.Result](time.Hour)
+	t.Cleanup(verdicts.Close)
+	verifier := emailverify.New(api.Doer(), "https://verify.test/v1/check", emailverify.WithCache(verdicts))
+	for range 2 {
+		if _, err := verifier.Verify(context.Background(), "ada@example.com"); err == nil {
+			t.Fatal("Verify succeeded against an outage")
+		}
+	}
+	if n := len(api.Emails()); n != 2 || verdicts.Len() != 0 {
+		t.Errorf("%d requests and %d cached, want 2 and none", n, verdicts.Len())
+	}
+}
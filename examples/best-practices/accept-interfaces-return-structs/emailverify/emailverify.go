@@ -0,0 +1,154 @@
+// Package emailverify asks an external verification API whether an address
+// can receive mail, and rejects undeliverable addresses before a user is
+// created:
+//
+//	verifier := emailverify.New(httpclient.New(httpclient.WithBreaker(breaker)), "https://verify.example.com/v1/check")
+//	userService.OnBeforeUserCreated(verifier.Hook())
+//
+// Verdicts can be cached in a ttlcache.Cache with WithCache, an address
+// that signs up twice, or fails validation once and is resubmitted, is
+// then asked about once per TTL.
+//
+// The API is a GET with the address in the query string, answering
+// {"deliverable": bool, "reason": string}.
+package emailverify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/httpclient"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ttlcache"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/validate"
+)
+
+// Result is the API's verdict on one address.
+type Result struct {
+	Deliverable bool   `json:"deliverable"`
+	Reason      string `json:"reason"`
+}
+
+type Option func(*Verifier)
+
+// WithLogger is where Hook reports signups it let through unverified.
+func WithLogger(logger *slog.Logger) Option {
+	return func(v *Verifier) {
+		v.logger = logger
+	}
+}
+
+// WithCache keeps Verify's verdicts in c, so an address asked about again
+// within c's TTL costs no request. Only verdicts are cached, an error is
+// asked about again next time. The cache is the caller's to Close.
+func WithCache(c *ttlcache.Cache[string, Result]) Option {
+	return func(v *Verifier) {
+		v.cache = c
+	}
+}
+
+// WithUndeliverableTTL caches undeliverable verdicts for d rather than the
+// cache's TTL. A mailbox that did not exist yet is a plausible reason for
+// a retry minutes later, one that was fine yesterday is not going away.
+func WithUndeliverableTTL(d time.Duration) Option {
+	return func(v *Verifier) {
+		v.undeliverableTTL = d
+	}
+}
+
+// Verifier takes an httpclient.Doer rather than a *httpclient.Client, the
+// retries and breaker are the caller's choice and a test can pass a
+// DoerFunc.
+type Verifier struct {
+	client   httpclient.Doer
+	endpoint string
+	logger   *slog.Logger
+
+	cache            *ttlcache.Cache[string, Result]
+	undeliverableTTL time.Duration
+}
+
+func New(client httpclient.Doer, endpoint string, opts ...Option) *Verifier {
+	v := &Verifier{
+		client:   client,
+		endpoint: endpoint,
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Verify returns the API's verdict, or an error when there was none. With
+// WithCache a verdict still cached is returned without asking.
+func (v *Verifier) Verify(ctx context.Context, email string) (Result, error) {
+	if v.cache == nil {
+		return v.verify(ctx, email)
+	}
+	if res, ok := v.cache.Get(email); ok {
+		return res, nil
+	}
+	res, err := v.verify(ctx, email)
+	if err != nil {
+		return res, err
+	}
+	if !res.Deliverable && v.undeliverableTTL > 0 {
+		v.cache.SetWithTTL(email, res, v.undeliverableTTL)
+	} else {
+		v.cache.Set(email, res)
+	}
+	return res, nil
+}
+
+func (v *Verifier) verify(ctx context.Context, email string) (Result, error) {
+	var res Result
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.endpoint+"?"+url.Values{"email": {email}}.Encode(), nil)
+	if err != nil {
+		return res, errs.Wrap("emailverify.Verifier.Verify", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return res, errs.Wrap("emailverify.Verifier.Verify", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return res, errs.Wrap("emailverify.Verifier.Verify", fmt.Errorf("unexpected status %s", resp.Status))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return res, errs.Wrap("emailverify.Verifier.Verify", err)
+	}
+	return res, nil
+}
+
+// Hook is a before-create hook that fails the signup with a validation
+// error when the API says the address is undeliverable.
+//
+// It fails open: when the API cannot be reached, is out of retries, or
+// behind an open breaker, the user is created and a warning logged. A
+// vendor outage should cost some bounced welcome emails, not every signup.
+func (v *Verifier) Hook() service.Hook {
+	return func(ctx context.Context, user *service.User) error {
+		res, err := v.Verify(ctx, user.Email)
+		if err != nil && ctx.Err() != nil {
+			// the caller gave up, there is no signup left to let through
+			return err
+		}
+		if err != nil {
+			v.logger.WarnContext(ctx, "email not verified", slog.String("user_id", user.ID), slog.Any("err", err))
+			return nil
+		}
+		if !res.Deliverable {
+			return &validate.ValidationError{Fields: []validate.FieldError{{Field: "Email", Message: "is not deliverable: " + res.Reason}}}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,130 @@
+package emailverify_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/emailverify"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/emailverify/emailverifytest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/httpclient"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/retry"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// cases is the shared table: what the API answers and what the verifier
+// and its signup hook must make of it.
+var cases = []struct {
+	name    string
+	email   string
+	respond emailverifytest.Response
+	// want is Verify's result when wantErr is false
+	want    emailverify.Result
+	wantErr bool
+	// wantHook is the kind of error the hook returns, nil lets the signup in
+	wantHook error
+}{
+	{
+		name:    "deliverable",
+		email:   "ada@example.com",
+		respond: emailverifytest.Deliverable(),
+		want:    emailverify.Result{Deliverable: true},
+	},
+	{
+		name:     "undeliverable is rejected",
+		email:    "alan@mail.invalid",
+		respond:  emailverifytest.Undeliverable("domain has no mail server"),
+		want:     emailverify.Result{Reason: "domain has no mail server"},
+		wantHook: errs.ErrInvalidInput,
+	},
+	{
+		name:    "plus address survives the query string",
+		email:   "grace+news@example.com",
+		respond: emailverifytest.Deliverable(),
+		want:    emailverify.Result{Deliverable: true},
+	},
+	{
+		name:    "outage fails open",
+		email:   "edsger@example.com",
+		respond: emailverifytest.Response{Status: http.StatusServiceUnavailable, Body: "overloaded"},
+		wantErr: true,
+	},
+	{
+		name:    "unknown endpoint fails open",
+		email:   "barbara@example.com",
+		respond: emailverifytest.Response{Status: http.StatusNotFound, Body: "not found"},
+		wantErr: true,
+	},
+	{
+		name:    "malformed body fails open",
+		email:   "donald@example.com",
+		respond: emailverifytest.Response{Status: http.StatusOK, Body: `{"deliverable":`},
+		wantErr: true,
+	},
+}
+
+// one attempt per call: retries are httpclient's behavior, these cases are
+// about the verifier.
+var noRetry = httpclient.WithPolicy(retry.Policy{MaxAttempts: 1})
+
+// harnesses build a Verifier on top of api, each with the fake at a
+// different depth.
+var harnesses = []struct {
+	name  string
+	build func(t *testing.T, api *emailverifytest.API) *emailverify.Verifier
+}{
+	{"httptest.Server", func(t *testing.T, api *emailverifytest.API) *emailverify.Verifier {
+		server := api.Server()
+		t.Cleanup(server.Close)
+		client := httpclient.New(httpclient.WithTransport(server.Client().Transport), noRetry)
+		return emailverify.New(client, server.URL+"/v1/check")
+	}},
+	{"RoundTripper", func(t *testing.T, api *emailverifytest.API) *emailverify.Verifier {
+		client := httpclient.New(httpclient.WithTransport(api.Transport()), noRetry)
+		return emailverify.New(client, "https://verify.test/v1/check")
+	}},
+	{"Doer", func(t *testing.T, api *emailverifytest.API) *emailverify.Verifier {
+		return emailverify.New(api.Doer(), "https://verify.test/v1/check")
+	}},
+}
+
+// TestVerify runs every case against every harness. The table is written
+// once, the harnesses only differ in how deep the fake sits.
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	for _, h := range harnesses {
+		t.Run(h.name, func(t *testing.T) {
+			for _, tt := range cases {
+				t.Run(tt.name, func(t *testing.T) {
+					api := &emailverifytest.API{Respond: func(string) emailverifytest.Response { return tt.respond }}
+					verifier := h.build(t, api)
+
+					got, err := verifier.Verify(ctx, tt.email)
+					switch {
+					case tt.wantErr && err == nil:
+						t.Errorf("Verify = %+v, want an error", got)
+					case !tt.wantErr && err != nil:
+						t.Errorf("Verify: %v", err)
+					case !tt.wantErr && got != tt.want:
+						t.Errorf("Verify = %+v, want %+v", got, tt.want)
+					}
+
+					err = verifier.Hook()(ctx, &service.User{ID: "1", Email: tt.email})
+					switch {
+					case tt.wantHook == nil && err != nil:
+						t.Errorf("Hook: err = %v, want the signup let through", err)
+					case tt.wantHook != nil && !errors.Is(err, tt.wantHook):
+						t.Errorf("Hook: err = %v, want %v", err, tt.wantHook)
+					}
+
+					if emails := api.Emails(); !slices.Equal(emails, []string{tt.email, tt.email}) {
+						t.Errorf("the API was asked about %q, want %q twice", emails, tt.email)
+					}
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// Package emailverifytest fakes the email verification API at three
+// depths, all driven by the same script so one table of cases runs against
+// each of them:
+//
+//   - Server is a real listener, the client's transport, pool, and timeouts
+//     are all exercised. The slowest and most faithful.
+//   - Transport swaps the http.RoundTripper under an httpclient.Client,
+//     retries, hooks, and the breaker still run, no socket is opened.
+//   - Doer replaces the client entirely, only emailverify's own request
+//     building and response parsing are left.
+//
+// Pick the shallowest fake that still covers what the test is about.
+package emailverifytest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/httpclient"
+)
+
+// Response is one canned reply.
+type Response struct {
+	Status int
+	Body   string
+}
+
+// Deliverable and Undeliverable are the two well-formed answers.
+func Deliverable() Response {
+	return Response{Status: http.StatusOK, Body: `{"deliverable":true}`}
+}
+
+func Undeliverable(reason string) Response {
+	return Response{Status: http.StatusOK, Body: `{"deliverable":false,"reason":"` + reason + `"}`}
+}
+
+// API answers every request with Respond's reply for the email in its query
+// string and records the emails it was asked about. A nil Respond answers
+// Deliverable.
+type API struct {
+	Respond func(email string) Response
+
+	mu     sync.Mutex
+	emails []string
+}
+
+// Emails is every address asked about so far, in order.
+func (a *API) Emails() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.emails...)
+}
+
+func (a *API) reply(req *http.Request) Response {
+	email := req.URL.Query().Get("email")
+	a.mu.Lock()
+	a.emails = append(a.emails, email)
+	a.mu.Unlock()
+	if a.Respond == nil {
+		return Deliverable()
+	}
+	return a.Respond(email)
+}
+
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	res := a.reply(r)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(res.Status)
+	io.WriteString(w, res.Body)
+}
+
+// Server starts the API on a loopback listener, Close it when done.
+func (a *API) Server() *httptest.Server {
+	return httptest.NewServer(a)
+}
+
+// Transport answers in process at the http.RoundTripper layer, pass it to
+// httpclient.WithTransport. Any host will do in the endpoint.
+func (a *API) Transport() http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return a.response(req), nil
+	})
+}
+
+// Doer answers in place of the whole client.
+func (a *API) Doer() httpclient.Doer {
+	return httpclient.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		return a.response(req), nil
+	})
+}
+
+func (a *API) response(req *http.Request) *http.Response {
+	res := a.reply(req)
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", res.Status, http.StatusText(res.Status)),
+		StatusCode: res.Status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(res.Body)),
+		Request:    req,
+	}
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
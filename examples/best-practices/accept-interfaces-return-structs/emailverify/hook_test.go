@@ -0,0 +1,78 @@
+package emailverify_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/emailverify"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/httpclient"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/validate"
+)
+
+// answering is a Doer that replies status and body to every request.
+func answering(status int, body string) httpclient.Doer {
+	return httpclient.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: status, Status: http.StatusText(status), Body: io.NopCloser(strings.NewReader(body))}, nil
+	})
+}
+
+// TestHook runs CreateUser with the verifier as a before-create hook: an
+// undeliverable address fails validation, an API that cannot answer lets
+// the signup through.
+func TestHook(t *testing.T) {
+	down := httpclient.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})
+	for _, tt := range []struct {
+		name    string
+		client  httpclient.Doer
+		created bool
+	}{
+		{"deliverable", answering(http.StatusOK, `{"deliverable":true}`), true},
+		{"undeliverable", answering(http.StatusOK, `{"deliverable":false,"reason":"no such mailbox"}`), false},
+		{"api down", down, true},
+		{"api erroring", answering(http.StatusInternalServerError, ""), true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := db.NewMemoryStore()
+			users := service.NewUserService(store)
+			users.OnBeforeUserCreated(emailverify.New(tt.client, "http://verify.example/v1/check").Hook())
+			err := users.CreateUser(ctx, &service.User{ID: "ada", Email: "ada@example.com"})
+			if tt.created != (err == nil) {
+				t.Fatalf("CreateUser: err = %v, want created %t", err, tt.created)
+			}
+			if tt.created {
+				return
+			}
+			var verr *validate.ValidationError
+			if !errors.As(err, &verr) || !errors.Is(err, errs.ErrInvalidInput) || !strings.Contains(err.Error(), "no such mailbox") {
+				t.Errorf("err = %v, want a validation error on Email with the API's reason", err)
+			}
+			if _, err := store.Get(ctx, "ada"); !errors.Is(err, errs.ErrNotFound) {
+				t.Errorf("the rejected user was stored: %v", err)
+			}
+		})
+	}
+}
+
+// TestHookCallerGone checks the hook does not fail open for a caller that
+// has already given up.
+func TestHookCallerGone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	client := httpclient.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		cancel()
+		return nil, req.Context().Err()
+	})
+	hook := emailverify.New(client, "http://verify.example/v1/check").Hook()
+	if err := hook(ctx, &service.User{ID: "ada", Email: "ada@example.com"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("hook: err = %v, want context.Canceled", err)
+	}
+}
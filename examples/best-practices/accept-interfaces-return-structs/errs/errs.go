@@ -0,0 +1,53 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors shared by every layer. Stores and services wrap these
+// with the operation that failed, callers match on them with errors.Is.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrInvalidInput = errors.New("invalid input")
+
+	// ErrUnauthenticated means the caller did not prove who they are:
+	// no credentials, or a token that is malformed, forged, or expired.
+	ErrUnauthenticated = errors.New("unauthenticated")
+
+	// ErrForbidden means the caller is known but not allowed to do this.
+	ErrForbidden = errors.New("forbidden")
+
+	// ErrUnavailable means a dependency is known to be down and the call
+	// was refused without trying, e.g. by an open circuit breaker. Unlike
+	// an internal error it is safe to retry later.
+	ErrUnavailable = errors.New("unavailable")
+
+	// ErrVersionConflict is returned when an optimistic write loses the race.
+	// It wraps ErrConflict, so errors.Is matches either sentinel.
+	ErrVersionConflict = fmt.Errorf("version %w", ErrConflict)
+)
+
+// OpError records the operation that produced an error, e.g.
+// "service.CreateUser" or "db.MemoryStore.Get". Retrieve it with errors.As.
+type OpError struct {
+	Op  string
+	Err error
+}
+
+func (e *OpError) Error() string {
+	return e.Op + ": " + e.Err.Error()
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// Wrap annotates err with op, returning nil when err is nil.
+func Wrap(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &OpError{Op: op, Err: err}
+}
@@ -0,0 +1,200 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/events"
+)
+
+// ErrClosed is returned by Publish once Close has been called.
+var ErrClosed = errors.New("eventbus: closed")
+
+// AllTopics subscribes to every event regardless of name.
+const AllTopics = "*"
+
+// Policy decides what Publish does when a subscriber's buffer is full.
+type Policy int
+
+const (
+	// Block waits for the subscriber to make room, applying backpressure to
+	// the publisher. The wait ends early if the publish context is done.
+	Block Policy = iota
+	// Drop discards the event for that subscriber and counts it, the
+	// publisher never waits on a slow consumer.
+	Drop
+)
+
+// Bus is an asynchronous, topic based events.Publisher. Each subscription
+// gets its own buffered channel, topics are event names.
+type Bus struct {
+	// mu is held for reading while publishing and for writing while
+	// subscribers are added, removed, or closed, so a channel is never
+	// closed while a publisher may still send on it.
+	mu     sync.RWMutex
+	subs   map[string][]*Subscription
+	closed bool
+	// done is closed at the start of Close to release blocked publishers.
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func New() *Bus {
+	return &Bus{
+		subs: make(map[string][]*Subscription),
+		done: make(chan struct{}),
+	}
+}
+
+// SubscribeOption configures a Subscription.
+type SubscribeOption func(*Subscription)
+
+// WithBuffer sets the channel capacity, the default is 16.
+func WithBuffer(n int) SubscribeOption {
+	return func(s *Subscription) {
+		s.buffer = n
+	}
+}
+
+// WithPolicy sets the slow consumer policy, the default is Block.
+func WithPolicy(p Policy) SubscribeOption {
+	return func(s *Subscription) {
+		s.policy = p
+	}
+}
+
+// Subscription receives the events published to one topic.
+type Subscription struct {
+	bus     *Bus
+	topic   string
+	buffer  int
+	policy  Policy
+	ch      chan events.Event
+	dropped atomic.Uint64
+	// done is closed by Unsubscribe to release publishers blocked on ch.
+	done chan struct{}
+	once sync.Once
+}
+
+// Subscribe starts receiving events named topic, or every event for
+// AllTopics. Subscribing to a closed bus returns a subscription whose
+// channel is already closed.
+func (b *Bus) Subscribe(topic string, opts ...SubscribeOption) *Subscription {
+	s := &Subscription{
+		bus:    b,
+		topic:  topic,
+		buffer: 16,
+		policy: Block,
+		done:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.ch = make(chan events.Event, s.buffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(s.ch)
+		return s
+	}
+	b.subs[topic] = append(b.subs[topic], s)
+	return s
+}
+
+// Events is closed after Unsubscribe or Close, events already buffered
+// can still be drained.
+func (s *Subscription) Events() <-chan events.Event {
+	return s.ch
+}
+
+// Dropped reports how many events the Drop policy discarded.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Unsubscribe stops delivery and closes the channel. It is safe to call
+// more than once and from the consuming goroutine.
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		close(s.done)
+		b := s.bus
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.closed {
+			// Close already closed the channel
+			return
+		}
+		subs := b.subs[s.topic]
+		for i, sub := range subs {
+			if sub == s {
+				b.subs[s.topic] = append(subs[:i:i], subs[i+1:]...)
+				break
+			}
+		}
+		close(s.ch)
+	})
+}
+
+// Publish delivers event to the subscribers of event.Name() and of
+// AllTopics. With the Block policy it returns ctx.Err() if the context ends
+// while waiting on a full subscriber, events already delivered stay delivered.
+func (b *Bus) Publish(ctx context.Context, event events.Event) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return ErrClosed
+	}
+	for _, topic := range [...]string{event.Name(), AllTopics} {
+		for _, s := range b.subs[topic] {
+			if err := b.deliver(ctx, s, event); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (b *Bus) deliver(ctx context.Context, s *Subscription, event events.Event) error {
+	if s.policy == Drop {
+		select {
+		case s.ch <- event:
+		default:
+			s.dropped.Add(1)
+		}
+		return nil
+	}
+	select {
+	case s.ch <- event:
+		return nil
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-b.done:
+		return ErrClosed
+	}
+}
+
+// Close stops the bus: new publishes fail with ErrClosed, publishers blocked
+// on full subscribers are released, and every subscription channel is closed
+// once in-flight publishes return. Subscribers drain what is left buffered.
+func (b *Bus) Close() error {
+	b.closeOnce.Do(func() { close(b.done) })
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	for _, subs := range b.subs {
+		for _, s := range subs {
+			close(s.ch)
+		}
+	}
+	b.subs = nil
+	return nil
+}
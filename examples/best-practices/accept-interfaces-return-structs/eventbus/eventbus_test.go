@@ -0,0 +1,202 @@
+package eventbus_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/eventbus"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/events"
+)
+
+// TestMain fails the package if a subscriber's or publisher's goroutine outlives
+// the bus's Close.
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+func created(id string) events.Event { return events.UserCreated{UserID: id} }
+
+func TestTopics(t *testing.T) {
+	ctx := context.Background()
+	bus := eventbus.New()
+	createdSub := bus.Subscribe(events.NameUserCreated)
+	deletedSub := bus.Subscribe(events.NameUserDeleted)
+	all := bus.Subscribe(eventbus.AllTopics)
+
+	if err := bus.Publish(ctx, created("ada")); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if err := bus.Publish(ctx, events.UserDeleted{UserID: "ada"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	bus.Close()
+
+	for _, tt := range []struct {
+		name string
+		sub  *eventbus.Subscription
+		want []string
+	}{
+		{"created", createdSub, []string{events.NameUserCreated}},
+		{"deleted", deletedSub, []string{events.NameUserDeleted}},
+		{"all topics", all, []string{events.NameUserCreated, events.NameUserDeleted}},
+	} {
+		var got []string
+		for event := range tt.sub.Events() {
+			got = append(got, event.Name())
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDropPolicy(t *testing.T) {
+	ctx := context.Background()
+	bus := eventbus.New()
+	defer bus.Close()
+	sub := bus.Subscribe(eventbus.AllTopics, eventbus.WithBuffer(2), eventbus.WithPolicy(eventbus.Drop))
+	for range 5 {
+		if err := bus.Publish(ctx, created("ada")); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+	if got := len(sub.Events()); got != 2 {
+		t.Errorf("%d events buffered, want 2", got)
+	}
+	if got := sub.Dropped(); got != 3 {
+		t.Errorf("Dropped = %d, want 3", got)
+	}
+}
+
+func TestBlockPolicy(t *testing.T) {
+	bus := eventbus.New()
+	defer bus.Close()
+	sub := bus.Subscribe(eventbus.AllTopics, eventbus.WithBuffer(1))
+	if err := bus.Publish(context.Background(), created("ada")); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	// the buffer is full, so the publisher waits until its context ends
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := bus.Publish(ctx, created("bob")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Publish to a full subscriber: err = %v, want DeadlineExceeded", err)
+	}
+
+	// or until the consumer makes room
+	published := make(chan error)
+	go func() { published <- bus.Publish(context.Background(), created("grace")) }()
+	if event := <-sub.Events(); event.(events.UserCreated).UserID != "ada" {
+		t.Fatalf("first event = %v, want ada's", event)
+	}
+	if err := <-published; err != nil {
+		t.Fatalf("Publish after room was made: %v", err)
+	}
+	if event := <-sub.Events(); event.(events.UserCreated).UserID != "grace" {
+		t.Errorf("second event = %v, want grace's", event)
+	}
+}
+
+// TestCloseReleasesBlockedPublishers checks Close does not wait on a
+// publisher stuck on a full subscriber, it fails that publish instead.
+func TestCloseReleasesBlockedPublishers(t *testing.T) {
+	bus := eventbus.New()
+	sub := bus.Subscribe(eventbus.AllTopics, eventbus.WithBuffer(1))
+	if err := bus.Publish(context.Background(), created("ada")); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	published := make(chan error)
+	go func() { published <- bus.Publish(context.Background(), created("bob")) }()
+
+	// Close can only win the lock once the blocked publish lets go of it
+	bus.Close()
+	if err := <-published; !errors.Is(err, eventbus.ErrClosed) {
+		t.Errorf("blocked Publish: err = %v, want ErrClosed", err)
+	}
+	if err := bus.Publish(context.Background(), created("grace")); !errors.Is(err, eventbus.ErrClosed) {
+		t.Errorf("Publish after Close: err = %v, want ErrClosed", err)
+	}
+	// what was buffered before Close can still be drained
+	n := 0
+	for range sub.Events() {
+		n++
+	}
+	if n != 1 {
+		t.Errorf("drained %d events, want 1", n)
+	}
+	if _, ok := <-bus.Subscribe(eventbus.AllTopics).Events(); ok {
+		t.Error("Subscribe after Close returned an open channel")
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	ctx := context.Background()
+	bus := eventbus.New()
+	defer bus.Close()
+	sub := bus.Subscribe(eventbus.AllTopics, eventbus.WithBuffer(1))
+	other := bus.Subscribe(eventbus.AllTopics, eventbus.WithBuffer(4))
+	if err := bus.Publish(ctx, created("ada")); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	if err := bus.Publish(ctx, created("bob")); err != nil {
+		t.Fatalf("Publish after an unsubscribe: %v", err)
+	}
+	n := 0
+	for range sub.Events() {
+		n++
+	}
+	if n != 1 {
+		t.Errorf("unsubscribed subscription drained %d events, want the 1 from before", n)
+	}
+	if got := len(other.Events()); got != 2 {
+		t.Errorf("other subscription has %d events, want 2", got)
+	}
+}
+
+// TestConcurrentUse publishes, subscribes and unsubscribes from many
+// goroutines at once, meant to be run with -race.
+func TestConcurrentUse(t *testing.T) {
+	ctx := context.Background()
+	bus := eventbus.New()
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			for range 100 {
+				if err := bus.Publish(ctx, created("ada")); err != nil && !errors.Is(err, eventbus.ErrClosed) {
+					t.Errorf("Publish: %v", err)
+				}
+			}
+		})
+		wg.Go(func() {
+			for range 20 {
+				sub := bus.Subscribe(eventbus.AllTopics, eventbus.WithPolicy(eventbus.Drop))
+				sub.Unsubscribe()
+				for range sub.Events() {
+				}
+			}
+		})
+	}
+	consumer := bus.Subscribe(eventbus.AllTopics, eventbus.WithBuffer(1))
+	received := 0
+	drained := make(chan struct{})
+	go func() {
+		for range consumer.Events() {
+			received++
+		}
+		close(drained)
+	}()
+	wg.Wait()
+	bus.Close()
+	<-drained
+	if received != 400 {
+		t.Errorf("blocking subscriber received %d events, want all 400", received)
+	}
+}
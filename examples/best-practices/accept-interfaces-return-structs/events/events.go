@@ -0,0 +1,119 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Event is a domain event. Name is stable and safe to use as a routing key.
+type Event interface {
+	Name() string
+}
+
+// Publisher delivers events to whoever is listening. The service depends on
+// this interface only, the bus behind it is the caller's choice.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Event names, also used as topics.
+const (
+	NameUserCreated = "user.created"
+	NameUserUpdated = "user.updated"
+	NameUserDeleted = "user.deleted"
+)
+
+// UserCreated, UserUpdated, and UserDeleted carry a snapshot of the user at
+// the time of the change. They duplicate the fields they need rather than
+// embedding service.User so this package stays free of service imports.
+// Tenant is the tenant the user belongs to, see ctxutil.Tenant, left out
+// of the JSON for the default tenant so those events encode as before.
+type UserCreated struct {
+	Tenant  string `json:",omitempty"`
+	UserID  string
+	Email   string
+	Version int64
+	At      time.Time
+}
+
+func (UserCreated) Name() string { return NameUserCreated }
+
+type UserUpdated struct {
+	Tenant  string `json:",omitempty"`
+	UserID  string
+	Email   string
+	Version int64
+	At      time.Time
+}
+
+func (UserUpdated) Name() string { return NameUserUpdated }
+
+type UserDeleted struct {
+	Tenant string `json:",omitempty"`
+	UserID string
+	At     time.Time
+}
+
+func (UserDeleted) Name() string { return NameUserDeleted }
+
+// Handler reacts to a published event.
+type Handler func(ctx context.Context, event Event) error
+
+// Bus is a synchronous, in-process Publisher. Publish calls every handler in
+// subscription order on the caller's goroutine and returns the first error.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+func (b *Bus) Subscribe(h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, h)
+}
+
+func (b *Bus) Publish(ctx context.Context, event Event) error {
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+	for _, h := range handlers {
+		if err := h(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decode rebuilds an event from its name and JSON encoding, the inverse of
+// json.Marshal on one of the event types above.
+func Decode(name string, data []byte) (Event, error) {
+	var event Event
+	var err error
+	switch name {
+	case NameUserCreated:
+		var e UserCreated
+		err = json.Unmarshal(data, &e)
+		event = e
+	case NameUserUpdated:
+		var e UserUpdated
+		err = json.Unmarshal(data, &e)
+		event = e
+	case NameUserDeleted:
+		var e UserDeleted
+		err = json.Unmarshal(data, &e)
+		event = e
+	default:
+		return nil, fmt.Errorf("events: unknown event %q", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("events: decode %s: %w", name, err)
+	}
+	return event, nil
+}
@@ -0,0 +1,94 @@
+// Package eventsourcing keeps users as the events that happened to them
+// instead of their current state. A User is rebuilt by replaying its
+// stream, UserRegistered first, and changed by commands that record new
+// events, which a Repository appends to an EventStore. Nothing is ever
+// updated in place, so every past state can be replayed, see LoadAt.
+//
+// This is a different model from service.UserService, not a store for it:
+// the service overwrites a row, here the row is derived. The two meet at
+// the events, a projection such as cqrs.Projection can be built from
+// either.
+//
+// Long streams are loaded from a Snapshot, the state at some version, plus
+// the events after it. Snapshots are a cache: dropping them all changes
+// nothing but load time.
+package eventsourcing
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Event is something that happened to a user. Type is stored with the
+// event and selects the struct it decodes into, it must never change.
+type Event interface {
+	Type() string
+}
+
+// Event types.
+const (
+	TypeUserRegistered  = "user.registered"
+	TypeEmailChanged    = "user.email_changed"
+	TypeUserDeactivated = "user.deactivated"
+)
+
+type UserRegistered struct {
+	UserID string    `json:"user_id"`
+	Email  string    `json:"email"`
+	Name   string    `json:"name,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+func (UserRegistered) Type() string { return TypeUserRegistered }
+
+// EmailChanged keeps the address it replaced, so a stream read on its own
+// says what changed, not only what it became.
+type EmailChanged struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+func (EmailChanged) Type() string { return TypeEmailChanged }
+
+type UserDeactivated struct {
+	Reason string    `json:"reason,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+func (UserDeactivated) Type() string { return TypeUserDeactivated }
+
+func encode(event Event) ([]byte, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("eventsourcing: encode %s: %w", event.Type(), err)
+	}
+	return data, nil
+}
+
+// decode rebuilds the event a Record holds.
+func decode(typ string, data []byte) (Event, error) {
+	var event Event
+	var err error
+	switch typ {
+	case TypeUserRegistered:
+		var e UserRegistered
+		err = json.Unmarshal(data, &e)
+		event = e
+	case TypeEmailChanged:
+		var e EmailChanged
+		err = json.Unmarshal(data, &e)
+		event = e
+	case TypeUserDeactivated:
+		var e UserDeactivated
+		err = json.Unmarshal(data, &e)
+		event = e
+	default:
+		return nil, fmt.Errorf("eventsourcing: unknown event %q", typ)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("eventsourcing: decode %s: %w", typ, err)
+	}
+	return event, nil
+}
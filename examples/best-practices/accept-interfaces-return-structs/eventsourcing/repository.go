@@ -0,0 +1,207 @@
+package eventsourcing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// snapshotFormat is the encoding of User snapshots. Bump it when User's
+// fields change meaning, older snapshots are then replayed past instead of
+// decoded wrong.
+const snapshotFormat = 1
+
+// Repository loads users from their streams and saves the events commands
+// recorded.
+type Repository struct {
+	events    EventStore
+	snapshots SnapshotStore
+	every     int64
+	logger    *slog.Logger
+}
+
+// RepositoryOption configures a Repository.
+type RepositoryOption func(*Repository)
+
+// WithSnapshots saves a snapshot to store whenever a save takes a stream
+// across a multiple of every versions, and loads from the latest one.
+func WithSnapshots(store SnapshotStore, every int) RepositoryOption {
+	return func(r *Repository) {
+		r.snapshots = store
+		r.every = int64(max(every, 1))
+	}
+}
+
+// WithLogger logs snapshots that failed to save or load, which never fail
+// the call, the default is slog.Default.
+func WithLogger(logger *slog.Logger) RepositoryOption {
+	return func(r *Repository) {
+		r.logger = logger
+	}
+}
+
+func NewRepository(events EventStore, opts ...RepositoryOption) *Repository {
+	r := &Repository{events: events, logger: slog.Default()}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Load rebuilds the user with ID id from their latest snapshot, if any,
+// and the events after it. A user with no events is errs.ErrNotFound.
+func (r *Repository) Load(ctx context.Context, id string) (*User, error) {
+	u := &User{}
+	if r.snapshots != nil {
+		if snap, ok := r.loadSnapshot(ctx, id); ok {
+			u = snap
+		}
+	}
+	records, err := r.events.Load(ctx, id, u.Version)
+	if err != nil {
+		return nil, errs.Wrap("eventsourcing.Repository.Load", err)
+	}
+	if err := replay(u, records); err != nil {
+		return nil, errs.Wrap("eventsourcing.Repository.Load", err)
+	}
+	if u.Version == 0 {
+		return nil, errs.Wrap("eventsourcing.Repository.Load", errs.ErrNotFound)
+	}
+	return u, nil
+}
+
+// LoadAt rebuilds the user as they were at version, replaying the stream
+// from the start and ignoring snapshots. A version past the end is the
+// latest state.
+func (r *Repository) LoadAt(ctx context.Context, id string, version int64) (*User, error) {
+	records, err := r.events.Load(ctx, id, 0)
+	if err != nil {
+		return nil, errs.Wrap("eventsourcing.Repository.LoadAt", err)
+	}
+	records = records[:min(int64(len(records)), max(version, 0))]
+	u := &User{}
+	if err := replay(u, records); err != nil {
+		return nil, errs.Wrap("eventsourcing.Repository.LoadAt", err)
+	}
+	if u.Version == 0 {
+		return nil, errs.Wrap("eventsourcing.Repository.LoadAt", errs.ErrNotFound)
+	}
+	return u, nil
+}
+
+// History returns every event of the user's stream, oldest first.
+func (r *Repository) History(ctx context.Context, id string) ([]Event, error) {
+	records, err := r.events.Load(ctx, id, 0)
+	if err != nil {
+		return nil, errs.Wrap("eventsourcing.Repository.History", err)
+	}
+	history := make([]Event, len(records))
+	for i, rec := range records {
+		if history[i], err = decode(rec.Type, rec.Data); err != nil {
+			return nil, errs.Wrap("eventsourcing.Repository.History", err)
+		}
+	}
+	return history, nil
+}
+
+func replay(u *User, records []Record) error {
+	for _, rec := range records {
+		if rec.Version != u.Version+1 {
+			return fmt.Errorf("stream %s: version %d follows %d", rec.StreamID, rec.Version, u.Version)
+		}
+		event, err := decode(rec.Type, rec.Data)
+		if err != nil {
+			return err
+		}
+		u.apply(event)
+	}
+	return nil
+}
+
+// Save appends the events u recorded since it was loaded. If the stream
+// moved on meanwhile it fails with errs.ErrVersionConflict, and the caller
+// loads again and retries the command on the state as it now is. Saving a
+// newly registered user whose ID already has a stream fails the same way.
+func (r *Repository) Save(ctx context.Context, u *User) error {
+	if len(u.changes) == 0 {
+		return nil
+	}
+	base := u.Version - int64(len(u.changes))
+	records := make([]Record, len(u.changes))
+	for i, event := range u.changes {
+		data, err := encode(event)
+		if err != nil {
+			return errs.Wrap("eventsourcing.Repository.Save", err)
+		}
+		records[i] = Record{Type: event.Type(), Data: data, At: at(event)}
+	}
+	if err := r.events.Append(ctx, u.ID, base, records); err != nil {
+		return errs.Wrap("eventsourcing.Repository.Save", err)
+	}
+	u.changes = nil
+	if r.snapshots != nil && base/r.every != u.Version/r.every {
+		r.saveSnapshot(ctx, u)
+	}
+	return nil
+}
+
+// at is when event happened.
+func at(event Event) time.Time {
+	switch e := event.(type) {
+	case UserRegistered:
+		return e.At
+	case EmailChanged:
+		return e.At
+	case UserDeactivated:
+		return e.At
+	}
+	return time.Time{}
+}
+
+// saveSnapshot keeps u's state. A failure is only logged, the events are
+// stored and the next save past a multiple of every tries again.
+func (r *Repository) saveSnapshot(ctx context.Context, u *User) {
+	state, err := json.Marshal(u)
+	if err == nil {
+		err = r.snapshots.SaveSnapshot(ctx, Snapshot{StreamID: u.ID, Version: u.Version, Format: snapshotFormat, State: state})
+	}
+	if err != nil {
+		r.logger.WarnContext(ctx, "eventsourcing: snapshot not saved",
+			slog.String("stream", u.ID),
+			slog.Int64("version", u.Version),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// loadSnapshot returns the state of id's latest snapshot. Without a usable
+// one Load replays the whole stream, a failure is logged, not returned.
+func (r *Repository) loadSnapshot(ctx context.Context, id string) (*User, bool) {
+	snap, err := r.snapshots.LoadSnapshot(ctx, id)
+	if errors.Is(err, errs.ErrNotFound) {
+		return nil, false
+	}
+	if err == nil && snap.Format != snapshotFormat {
+		err = fmt.Errorf("format %d, want %d", snap.Format, snapshotFormat)
+	}
+	var u User
+	if err == nil {
+		err = json.Unmarshal(snap.State, &u)
+	}
+	if err == nil && u.Version != snap.Version {
+		err = fmt.Errorf("state is at version %d, the snapshot says %d", u.Version, snap.Version)
+	}
+	if err != nil {
+		r.logger.WarnContext(ctx, "eventsourcing: snapshot ignored",
+			slog.String("stream", id),
+			slog.String("error", err.Error()),
+		)
+		return nil, false
+	}
+	return &u, true
+}
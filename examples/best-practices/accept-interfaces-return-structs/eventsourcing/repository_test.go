@@ -0,0 +1,246 @@
+package eventsourcing_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/eventsourcing"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/eventsourcing/sqlite"
+)
+
+var (
+	quiet = slog.New(slog.DiscardHandler)
+	now   = time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+)
+
+// store is what both backends implement.
+type store interface {
+	eventsourcing.EventStore
+	eventsourcing.SnapshotStore
+}
+
+// forEachStore runs fn on the memory store and on SQLite on a temporary
+// file, each fresh.
+func forEachStore(t *testing.T, fn func(t *testing.T, s store)) {
+	t.Run("memory", func(t *testing.T) { fn(t, eventsourcing.NewMemoryStore()) })
+	t.Run("sqlite", func(t *testing.T) {
+		s, err := sqlite.Open(context.Background(), filepath.Join(t.TempDir(), "events.db"))
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		fn(t, s)
+	})
+}
+
+// register saves a new user.
+func register(t *testing.T, repo *eventsourcing.Repository, id, email string) *eventsourcing.User {
+	t.Helper()
+	u, err := eventsourcing.Register(id, email, "Ada", now)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := repo.Save(context.Background(), u); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	return u
+}
+
+// load loads id, failing the test if it cannot.
+func load(t *testing.T, repo *eventsourcing.Repository, id string) *eventsourcing.User {
+	t.Helper()
+	u, err := repo.Load(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return u
+}
+
+// leaver saves ada, who registers, changes her email an hour later, and
+// leaves an hour after that.
+func leaver(t *testing.T, repo *eventsourcing.Repository) {
+	t.Helper()
+	register(t, repo, "ada", "ada@example.com")
+	u := load(t, repo, "ada")
+	if err := u.ChangeEmail("ada@example.org", now.Add(time.Hour)); err != nil {
+		t.Fatalf("ChangeEmail: %v", err)
+	}
+	if err := u.Deactivate("left", now.Add(2*time.Hour)); err != nil {
+		t.Fatalf("Deactivate: %v", err)
+	}
+	if err := repo.Save(context.Background(), u); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+}
+
+func TestRebuiltFromEvents(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store) {
+		repo := eventsourcing.NewRepository(s)
+		leaver(t, repo)
+		got := load(t, repo, "ada")
+		if got.Email != "ada@example.org" || got.Active || got.Version != 3 || !got.DeactivatedAt.Equal(now.Add(2*time.Hour)) {
+			t.Errorf("Load = %+v, want ada@example.org deactivated at version 3", got)
+		}
+		history, err := repo.History(context.Background(), "ada")
+		if err != nil {
+			t.Fatalf("History: %v", err)
+		}
+		var types []string
+		for _, e := range history {
+			types = append(types, e.Type())
+		}
+		if want := []string{eventsourcing.TypeUserRegistered, eventsourcing.TypeEmailChanged, eventsourcing.TypeUserDeactivated}; !reflect.DeepEqual(types, want) {
+			t.Fatalf("History = %v, want %v", types, want)
+		}
+		if change := history[1].(eventsourcing.EmailChanged); change.From != "ada@example.com" {
+			t.Errorf("EmailChanged.From = %q, want ada@example.com", change.From)
+		}
+	})
+}
+
+func TestLoadAt(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store) {
+		repo := eventsourcing.NewRepository(s)
+		leaver(t, repo)
+		for _, tt := range []struct {
+			version int64
+			email   string
+		}{
+			{1, "ada@example.com"},
+			{2, "ada@example.org"},
+		} {
+			u, err := repo.LoadAt(context.Background(), "ada", tt.version)
+			if err != nil {
+				t.Fatalf("LoadAt %d: %v", tt.version, err)
+			}
+			if u.Email != tt.email || !u.Active {
+				t.Errorf("LoadAt %d = %+v, want %s still active", tt.version, u, tt.email)
+			}
+		}
+	})
+}
+
+// TestConcurrentSaves loads a user twice and saves both: the second
+// conflicts, and succeeds once reloaded.
+func TestConcurrentSaves(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store) {
+		ctx := context.Background()
+		repo := eventsourcing.NewRepository(s)
+		register(t, repo, "bob", "bob@example.com")
+		a, c := load(t, repo, "bob"), load(t, repo, "bob")
+		a.ChangeEmail("bob@a.example.com", now)
+		c.ChangeEmail("bob@c.example.com", now)
+		if err := repo.Save(ctx, a); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		if err := repo.Save(ctx, c); !errors.Is(err, errs.ErrVersionConflict) {
+			t.Fatalf("second Save: err = %v, want ErrVersionConflict", err)
+		}
+		// the loser reloads and retries on the state as it now is
+		c = load(t, repo, "bob")
+		c.ChangeEmail("bob@c.example.com", now)
+		if err := repo.Save(ctx, c); err != nil {
+			t.Fatalf("Save after reload: %v", err)
+		}
+		if got := load(t, repo, "bob"); got.Email != "bob@c.example.com" || got.Version != 3 {
+			t.Errorf("Load = %+v, want bob@c.example.com at version 3", got)
+		}
+	})
+}
+
+func TestRegisterTakenID(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store) {
+		repo := eventsourcing.NewRepository(s)
+		register(t, repo, "bob", "bob@example.com")
+		u, err := eventsourcing.Register("bob", "other@example.com", "Bob", now)
+		if err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		if err := repo.Save(context.Background(), u); !errors.Is(err, errs.ErrVersionConflict) {
+			t.Errorf("Save: err = %v, want ErrVersionConflict", err)
+		}
+	})
+}
+
+func TestDeactivatedKeepsEmail(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store) {
+		repo := eventsourcing.NewRepository(s)
+		leaver(t, repo)
+		u := load(t, repo, "ada")
+		if err := u.ChangeEmail("ada@example.net", now); !errors.Is(err, errs.ErrConflict) {
+			t.Errorf("ChangeEmail: err = %v, want ErrConflict", err)
+		}
+		if n := len(u.Changes()); n != 0 {
+			t.Errorf("%d events recorded, want none", n)
+		}
+	})
+}
+
+func TestUnknownUser(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store) {
+		if _, err := eventsourcing.NewRepository(s).Load(context.Background(), "nobody"); !errors.Is(err, errs.ErrNotFound) {
+			t.Errorf("Load: err = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+// TestSnapshotLoad saves a snapshot every 5 events: a load from it matches
+// a full replay.
+func TestSnapshotLoad(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store) {
+		ctx := context.Background()
+		snapshotting := eventsourcing.NewRepository(s, eventsourcing.WithSnapshots(s, 5), eventsourcing.WithLogger(quiet))
+		u := register(t, snapshotting, "cy", "cy@example.com")
+		for i := range 11 {
+			if err := u.ChangeEmail(fmt.Sprintf("cy+%d@example.com", i), now.Add(time.Duration(i)*time.Minute)); err != nil {
+				t.Fatalf("ChangeEmail: %v", err)
+			}
+			if err := snapshotting.Save(ctx, u); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+		}
+		snap, err := s.LoadSnapshot(ctx, "cy")
+		if err != nil {
+			t.Fatalf("LoadSnapshot: %v", err)
+		}
+		if snap.Version != 10 {
+			t.Errorf("snapshot at version %d, want 10", snap.Version)
+		}
+		loaded := load(t, snapshotting, "cy")
+		replayed, err := eventsourcing.NewRepository(s).LoadAt(ctx, "cy", 1<<62)
+		if err != nil {
+			t.Fatalf("LoadAt: %v", err)
+		}
+		if !reflect.DeepEqual(loaded, replayed) {
+			t.Errorf("from the snapshot %+v, replayed %+v", loaded, replayed)
+		}
+	})
+}
+
+// TestUnusableSnapshot saves snapshots a load must not trust: of another
+// format, not decodable, and claiming a version its state does not have.
+func TestUnusableSnapshot(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store) {
+		snapshotting := eventsourcing.NewRepository(s, eventsourcing.WithSnapshots(s, 5), eventsourcing.WithLogger(quiet))
+		register(t, snapshotting, "di", "di@example.com")
+		for _, snap := range []eventsourcing.Snapshot{
+			{StreamID: "di", Version: 1, Format: 99, State: []byte(`{"Email":"wrong@example.com","Version":1}`)},
+			{StreamID: "di", Version: 2, Format: 1, State: []byte(`not json`)},
+			{StreamID: "di", Version: 3, Format: 1, State: []byte(`{"Email":"wrong@example.com","Version":1}`)},
+		} {
+			if err := s.SaveSnapshot(context.Background(), snap); err != nil {
+				t.Fatalf("SaveSnapshot: %v", err)
+			}
+			if u := load(t, snapshotting, "di"); u.Email != "di@example.com" || u.Version != 1 {
+				t.Errorf("with a snapshot at version %d Load = %+v, want di at version 1", snap.Version, u)
+			}
+		}
+	})
+}
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS events (
+    stream_id TEXT    NOT NULL,
+    version   INTEGER NOT NULL,
+    type      TEXT    NOT NULL,
+    data      BLOB    NOT NULL,
+    -- Unix nanoseconds, UTC
+    at        INTEGER NOT NULL,
+    PRIMARY KEY (stream_id, version)
+);
+
+CREATE TABLE IF NOT EXISTS snapshots (
+    stream_id TEXT    PRIMARY KEY,
+    version   INTEGER NOT NULL,
+    format    INTEGER NOT NULL,
+    state     BLOB    NOT NULL
+);
@@ -0,0 +1,124 @@
+// Package sqlite is an eventsourcing.EventStore and SnapshotStore on a
+// SQLite file, through the pure Go modernc.org/sqlite driver.
+//
+// Events are rows keyed by stream and version. The key is what makes
+// Append safe under concurrency: two appends after the same version both
+// try to insert that version plus one, and the second fails on the key.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/eventsourcing"
+)
+
+//go:embed schema.sql
+var schema string
+
+// constraintPrimaryKey is the extended result code for a duplicate key.
+const constraintPrimaryKey = 1555
+
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens (or creates) the database at path, ":memory:" for a
+// throwaway one.
+func Open(ctx context.Context, path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, errs.Wrap("eventsourcing/sqlite.Open", err)
+	}
+	// sqlite allows a single writer, one connection avoids SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		db.Close()
+		return nil, errs.Wrap("eventsourcing/sqlite.Open", err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) Append(ctx context.Context, stream string, expected int64, records []eventsourcing.Record) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errs.Wrap("eventsourcing/sqlite.Store.Append", err)
+	}
+	defer tx.Rollback()
+	var cur int64
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM events WHERE stream_id = ?`, stream).Scan(&cur); err != nil {
+		return errs.Wrap("eventsourcing/sqlite.Store.Append", err)
+	}
+	if cur != expected {
+		return errs.Wrap("eventsourcing/sqlite.Store.Append", errs.ErrVersionConflict)
+	}
+	for i, r := range records {
+		_, err := tx.ExecContext(ctx, `INSERT INTO events (stream_id, version, type, data, at) VALUES (?, ?, ?, ?, ?)`,
+			stream, expected+int64(i)+1, r.Type, r.Data, r.At.UnixNano())
+		if isConstraint(err) {
+			return errs.Wrap("eventsourcing/sqlite.Store.Append", errs.ErrVersionConflict)
+		}
+		if err != nil {
+			return errs.Wrap("eventsourcing/sqlite.Store.Append", err)
+		}
+	}
+	return errs.Wrap("eventsourcing/sqlite.Store.Append", tx.Commit())
+}
+
+func (s *Store) Load(ctx context.Context, stream string, after int64) ([]eventsourcing.Record, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT version, type, data, at FROM events WHERE stream_id = ? AND version > ? ORDER BY version`, stream, after)
+	if err != nil {
+		return nil, errs.Wrap("eventsourcing/sqlite.Store.Load", err)
+	}
+	defer rows.Close()
+	var records []eventsourcing.Record
+	for rows.Next() {
+		r := eventsourcing.Record{StreamID: stream}
+		var at int64
+		if err := rows.Scan(&r.Version, &r.Type, &r.Data, &at); err != nil {
+			return nil, errs.Wrap("eventsourcing/sqlite.Store.Load", err)
+		}
+		r.At = time.Unix(0, at).UTC()
+		records = append(records, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.Wrap("eventsourcing/sqlite.Store.Load", err)
+	}
+	return records, nil
+}
+
+func (s *Store) SaveSnapshot(ctx context.Context, snap eventsourcing.Snapshot) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO snapshots (stream_id, version, format, state) VALUES (?, ?, ?, ?)
+		ON CONFLICT (stream_id) DO UPDATE SET version = excluded.version, format = excluded.format, state = excluded.state
+		WHERE excluded.version > snapshots.version`,
+		snap.StreamID, snap.Version, snap.Format, snap.State)
+	return errs.Wrap("eventsourcing/sqlite.Store.SaveSnapshot", err)
+}
+
+func (s *Store) LoadSnapshot(ctx context.Context, stream string) (eventsourcing.Snapshot, error) {
+	snap := eventsourcing.Snapshot{StreamID: stream}
+	err := s.db.QueryRowContext(ctx, `SELECT version, format, state FROM snapshots WHERE stream_id = ?`, stream).Scan(&snap.Version, &snap.Format, &snap.State)
+	if errors.Is(err, sql.ErrNoRows) {
+		return eventsourcing.Snapshot{}, errs.Wrap("eventsourcing/sqlite.Store.LoadSnapshot", errs.ErrNotFound)
+	}
+	if err != nil {
+		return eventsourcing.Snapshot{}, errs.Wrap("eventsourcing/sqlite.Store.LoadSnapshot", err)
+	}
+	return snap, nil
+}
+
+func isConstraint(err error) bool {
+	var sqlErr interface{ Code() int }
+	return errors.As(err, &sqlErr) && sqlErr.Code() == constraintPrimaryKey
+}
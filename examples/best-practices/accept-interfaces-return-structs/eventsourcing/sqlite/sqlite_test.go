@@ -0,0 +1,44 @@
+package sqlite_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/eventsourcing"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/eventsourcing/sqlite"
+)
+
+func TestReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.db")
+	first, err := sqlite.Open(ctx, path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	u, err := eventsourcing.Register("bob", "bob@example.com", "Bob", time.Now())
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	u.ChangeEmail("bob@example.org", time.Now())
+	if err := eventsourcing.NewRepository(first).Save(ctx, u); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	second, err := sqlite.Open(ctx, path)
+	if err != nil {
+		t.Fatalf("Open again: %v", err)
+	}
+	defer second.Close()
+	got, err := eventsourcing.NewRepository(second).Load(ctx, "bob")
+	if err != nil {
+		t.Fatalf("Load after reopen: %v", err)
+	}
+	if got.Email != "bob@example.org" || got.Version != 2 {
+		t.Errorf("Load after reopen = %+v, want bob@example.org at version 2", got)
+	}
+}
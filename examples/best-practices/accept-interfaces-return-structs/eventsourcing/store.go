@@ -0,0 +1,126 @@
+package eventsourcing
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// Record is an event as stored: encoded, and placed in its stream. Version
+// counts from 1 within the stream.
+type Record struct {
+	StreamID string
+	Version  int64
+	Type     string
+	Data     []byte
+	At       time.Time
+}
+
+// EventStore holds append-only streams of records.
+type EventStore interface {
+	// Append adds records to the end of stream, numbering them after
+	// expected, the version the caller's state was built from. If the
+	// stream is no longer at expected, someone else appended first, and
+	// Append fails with errs.ErrVersionConflict, storing none of them.
+	Append(ctx context.Context, stream string, expected int64, records []Record) error
+	// Load returns stream's records after version after, in order. An
+	// unknown stream has none.
+	Load(ctx context.Context, stream string, after int64) ([]Record, error)
+}
+
+// Snapshot is a stream's state at Version, encoded. Format says how, a
+// snapshot in a format the reader does not know is ignored.
+type Snapshot struct {
+	StreamID string
+	Version  int64
+	Format   int
+	State    []byte
+}
+
+// SnapshotStore keeps the latest snapshot of each stream.
+type SnapshotStore interface {
+	// SaveSnapshot keeps s unless the stream has a newer one already.
+	SaveSnapshot(ctx context.Context, s Snapshot) error
+	// LoadSnapshot fails with errs.ErrNotFound for a stream without one.
+	LoadSnapshot(ctx context.Context, stream string) (Snapshot, error)
+}
+
+// MemoryStore is a concurrency safe EventStore and SnapshotStore on maps.
+// Records are copied in and out, a caller cannot rewrite history.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	streams   map[string][]Record
+	snapshots map[string]Snapshot
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		streams:   make(map[string][]Record),
+		snapshots: make(map[string]Snapshot),
+	}
+}
+
+func (s *MemoryStore) Append(ctx context.Context, stream string, expected int64, records []Record) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("eventsourcing.MemoryStore.Append", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cur := int64(len(s.streams[stream])); cur != expected {
+		return errs.Wrap("eventsourcing.MemoryStore.Append", errs.ErrVersionConflict)
+	}
+	for i, r := range records {
+		r.StreamID = stream
+		r.Version = expected + int64(i) + 1
+		r.Data = slices.Clone(r.Data)
+		s.streams[stream] = append(s.streams[stream], r)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Load(ctx context.Context, stream string, after int64) ([]Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Wrap("eventsourcing.MemoryStore.Load", err)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	records := s.streams[stream]
+	after = min(max(after, 0), int64(len(records)))
+	out := make([]Record, 0, int64(len(records))-after)
+	for _, r := range records[after:] {
+		r.Data = slices.Clone(r.Data)
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) SaveSnapshot(ctx context.Context, snap Snapshot) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("eventsourcing.MemoryStore.SaveSnapshot", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cur, ok := s.snapshots[snap.StreamID]; ok && cur.Version >= snap.Version {
+		return nil
+	}
+	snap.State = slices.Clone(snap.State)
+	s.snapshots[snap.StreamID] = snap
+	return nil
+}
+
+func (s *MemoryStore) LoadSnapshot(ctx context.Context, stream string) (Snapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return Snapshot{}, errs.Wrap("eventsourcing.MemoryStore.LoadSnapshot", err)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snap, ok := s.snapshots[stream]
+	if !ok {
+		return Snapshot{}, errs.Wrap("eventsourcing.MemoryStore.LoadSnapshot", errs.ErrNotFound)
+	}
+	snap.State = slices.Clone(snap.State)
+	return snap, nil
+}
@@ -0,0 +1,104 @@
+package eventsourcing
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/validate"
+)
+
+// User is the aggregate: its state is whatever its events add up to. The
+// command methods check the change is allowed, then record an event and
+// apply it, they never set a field directly. Fields are exported for
+// snapshots and reads, setting them by hand bypasses the stream.
+type User struct {
+	ID            string
+	Email         string
+	Name          string
+	Active        bool
+	RegisteredAt  time.Time
+	DeactivatedAt time.Time
+	// Version is how many events the state includes, the version of the
+	// last one.
+	Version int64
+
+	// changes are the events recorded since the user was loaded, not yet
+	// appended to the store
+	changes []Event
+}
+
+// Register starts a new user's stream.
+func Register(id, email, name string, now time.Time) (*User, error) {
+	var v validate.Errors
+	v.Required("ID", id)
+	v.Printable("ID", id)
+	v.MaxLen("ID", id, 64)
+	v.Required("Email", email)
+	v.Email("Email", email)
+	v.MaxLen("Name", name, 200)
+	if err := v.Err(); err != nil {
+		return nil, errs.Wrap("eventsourcing.Register", err)
+	}
+	u := &User{}
+	u.record(UserRegistered{UserID: id, Email: email, Name: name, At: now})
+	return u, nil
+}
+
+// ChangeEmail records an EmailChanged. Changing to the current address
+// records nothing, a deactivated user cannot change theirs.
+func (u *User) ChangeEmail(email string, now time.Time) error {
+	if !u.Active {
+		return errs.Wrap("eventsourcing.User.ChangeEmail", fmt.Errorf("user %s is deactivated: %w", u.ID, errs.ErrConflict))
+	}
+	var v validate.Errors
+	v.Required("Email", email)
+	v.Email("Email", email)
+	if err := v.Err(); err != nil {
+		return errs.Wrap("eventsourcing.User.ChangeEmail", err)
+	}
+	if email == u.Email {
+		return nil
+	}
+	u.record(EmailChanged{From: u.Email, To: email, At: now})
+	return nil
+}
+
+// Deactivate records a UserDeactivated. Deactivating an inactive user
+// records nothing.
+func (u *User) Deactivate(reason string, now time.Time) error {
+	if !u.Active {
+		return nil
+	}
+	u.record(UserDeactivated{Reason: reason, At: now})
+	return nil
+}
+
+// Changes returns the events recorded since the user was loaded.
+func (u *User) Changes() []Event {
+	return u.changes
+}
+
+func (u *User) record(event Event) {
+	u.apply(event)
+	u.changes = append(u.changes, event)
+}
+
+// apply moves the state on by one event. It is the only place state
+// changes, replaying a stream is calling it for every event in order.
+func (u *User) apply(event Event) {
+	switch e := event.(type) {
+	case UserRegistered:
+		u.ID = e.UserID
+		u.Email = e.Email
+		u.Name = e.Name
+		u.Active = true
+		u.RegisteredAt = e.At
+	case EmailChanged:
+		u.Email = e.To
+	case UserDeactivated:
+		u.Active = false
+		u.DeactivatedAt = e.At
+	}
+	u.Version++
+}
@@ -0,0 +1,142 @@
+// Package featureflag turns code paths on and off at runtime. Callers depend
+// on the one-method Provider interface, so the in-memory provider used here
+// can be swapped for LaunchDarkly, Unleash, or a database table without
+// touching the gated code.
+package featureflag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// Provider reports whether the flag key is on. ctx carries whatever a
+// provider needs to target a flag at some callers only, e.g. the principal.
+// Unknown keys are off, so removing a flag everywhere is always safe.
+type Provider interface {
+	Enabled(ctx context.Context, key string) bool
+}
+
+// Memory is a Provider backed by a map, safe for concurrent use. Flags can
+// be flipped with Set or reloaded from a JSON file with Watch.
+type Memory struct {
+	mu    sync.RWMutex
+	flags map[string]bool
+}
+
+func NewMemory(flags map[string]bool) *Memory {
+	m := &Memory{flags: make(map[string]bool, len(flags))}
+	for k, v := range flags {
+		m.flags[k] = v
+	}
+	return m
+}
+
+func (m *Memory) Enabled(_ context.Context, key string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.flags[key]
+}
+
+func (m *Memory) Set(key string, on bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flags[key] = on
+}
+
+// Load replaces every flag with the JSON object read from r, e.g.
+// {"users.strict-validation": true}. On error the current flags are kept,
+// and null is an error, not an empty object.
+func (m *Memory) Load(r io.Reader) error {
+	var flags map[string]bool
+	if err := json.NewDecoder(r).Decode(&flags); err != nil {
+		return errs.Wrap("featureflag.Memory.Load", err)
+	}
+	if flags == nil {
+		return errs.Wrap("featureflag.Memory.Load", fmt.Errorf("flags are null, want an object: %w", errs.ErrInvalidInput))
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flags = flags
+	return nil
+}
+
+// Watch loads path and then polls it every interval, reloading whenever its
+// modification time or size changes, until ctx is done. Polling needs no
+// platform specific notify API and a few stat calls a second cost nothing.
+//
+// Only the first load can fail Watch. A later file that does not parse is
+// logged and ignored, a half written edit should not switch every flag off.
+func (m *Memory) Watch(ctx context.Context, path string, interval time.Duration, logger *slog.Logger) error {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	info, err := m.loadFile(path)
+	if err != nil {
+		return errs.Wrap("featureflag.Memory.Watch", err)
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			cur, err := os.Stat(path)
+			if err != nil {
+				logger.WarnContext(ctx, "flag file unavailable", slog.String("path", path), slog.String("error", err.Error()))
+				continue
+			}
+			if cur.ModTime().Equal(info.ModTime()) && cur.Size() == info.Size() {
+				continue
+			}
+			next, err := m.loadFile(path)
+			if err != nil {
+				logger.ErrorContext(ctx, "flag reload failed, keeping current flags", slog.String("path", path), slog.String("error", err.Error()))
+				// the same broken file is not retried until it changes again
+				info = cur
+				continue
+			}
+			info = next
+			logger.InfoContext(ctx, "flags reloaded", slog.String("path", path))
+		}
+	}()
+	return nil
+}
+
+func (m *Memory) loadFile(path string) (os.FileInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return info, m.Load(f)
+}
+
+// Env is a Provider reading flags from the environment, key
+// "users.strict-validation" with Prefix "FEATURE_" is
+// FEATURE_USERS_STRICT_VALIDATION. Values are parsed with strconv.ParseBool.
+type Env struct {
+	Prefix string
+}
+
+func (e Env) Enabled(_ context.Context, key string) bool {
+	name := e.Prefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+	on, _ := strconv.ParseBool(os.Getenv(name))
+	return on
+}
@@ -0,0 +1,133 @@
+package featureflag_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/featureflag"
+)
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	initial := map[string]bool{"a": true}
+	m := featureflag.NewMemory(initial)
+	initial["b"] = true
+	if !m.Enabled(ctx, "a") || m.Enabled(ctx, "b") {
+		t.Errorf("a, b = %t, %t, want the flags as given to NewMemory", m.Enabled(ctx, "a"), m.Enabled(ctx, "b"))
+	}
+	m.Set("a", false)
+	m.Set("c", true)
+	if m.Enabled(ctx, "a") || !m.Enabled(ctx, "c") || m.Enabled(ctx, "unknown") {
+		t.Error("Set did not flip a and c, or an unknown flag is on")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	ctx := context.Background()
+	for _, tt := range []struct {
+		name string
+		json string
+		err  error
+	}{
+		{"null", "null", errs.ErrInvalidInput},
+		{"not JSON", "{on", nil},
+		{"not an object", `["a"]`, nil},
+		{"not a bool", `{"a":"yes"}`, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m := featureflag.NewMemory(map[string]bool{"a": true})
+			err := m.Load(strings.NewReader(tt.json))
+			if err == nil || (tt.err != nil && !errors.Is(err, tt.err)) {
+				t.Fatalf("Load: err = %v, want an error", err)
+			}
+			if !m.Enabled(ctx, "a") {
+				t.Error("a failed Load changed the flags")
+			}
+			// the flags are still a map Set can write to
+			m.Set("b", true)
+			if !m.Enabled(ctx, "b") {
+				t.Error("Set after a failed Load did not stick")
+			}
+		})
+	}
+
+	m := featureflag.NewMemory(map[string]bool{"a": true})
+	if err := m.Load(strings.NewReader(`{"b":true}`)); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if m.Enabled(ctx, "a") || !m.Enabled(ctx, "b") {
+		t.Error("Load did not replace every flag")
+	}
+}
+
+// eventually waits for cond, which the watcher makes true on its own time.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s never happened", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "flags.json")
+	rewrite := func(json string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(json), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := featureflag.NewMemory(nil)
+	if err := m.Watch(ctx, filepath.Join(t.TempDir(), "missing.json"), time.Millisecond, slog.New(slog.DiscardHandler)); err == nil {
+		t.Fatal("Watch of a missing file succeeded")
+	}
+	rewrite(`{"a":true}`)
+	if err := m.Watch(ctx, path, 5*time.Millisecond, slog.New(slog.DiscardHandler)); err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	if !m.Enabled(ctx, "a") {
+		t.Fatal("a is off after the first load")
+	}
+
+	// each write changes the size, the modification time may not move
+	rewrite(`{"a":false,"b":true}`)
+	eventually(t, "the reload", func() bool { return m.Enabled(ctx, "b") })
+	rewrite(`{"a":true,"b":tru`)
+	rewrite(`null`)
+	time.Sleep(50 * time.Millisecond)
+	if m.Enabled(ctx, "a") || !m.Enabled(ctx, "b") {
+		t.Error("a broken file changed the flags")
+	}
+	rewrite(`{"c":true}`)
+	eventually(t, "the reload after a broken file", func() bool { return m.Enabled(ctx, "c") })
+}
+
+func TestEnv(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("FEATURE_USERS_STRICT_VALIDATION", "true")
+	t.Setenv("FEATURE_OFF", "0")
+	t.Setenv("FEATURE_JUNK", "maybe")
+	env := featureflag.Env{Prefix: "FEATURE_"}
+	for key, want := range map[string]bool{
+		"users.strict-validation": true,
+		"off":                     false,
+		"junk":                    false,
+		"unset":                   false,
+	} {
+		if got := env.Enabled(ctx, key); got != want {
+			t.Errorf("Enabled(%q) = %t, want %t", key, got, want)
+		}
+	}
+}
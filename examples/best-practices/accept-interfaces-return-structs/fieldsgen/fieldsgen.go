@@ -0,0 +1,134 @@
+// Package fieldsgen generates a Fields method for DTO structs: every field
+// in declaration order, named as it is in JSON, with its value. Code that
+// walks a DTO generically, a table printer, a CSV writer, a log line, gets
+// it without reflection, and the compiler sees every field access, so a
+// renamed field breaks the build instead of a report.
+//
+// cmd/fieldsgen is the go:generate front end:
+//
+//	//go:generate go run ../cmd/fieldsgen -type User
+//
+// The generated file also declares the Field type, so each package runs
+// the generator once, with every type it wants in -type.
+package fieldsgen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// field is one generated entry, the struct field's Go name and its JSON
+// name.
+type field struct {
+	goName, name string
+}
+
+// Generate returns the formatted source of the Fields methods for types,
+// struct types declared in the package in dir. Fields without a json tag
+// are named as in Go, ones tagged "-" and unexported ones are left out as
+// encoding/json leaves them out. Embedded fields are refused, encoding/json
+// promotes their fields and the generator does not follow them.
+func Generate(dir string, types []string) ([]byte, error) {
+	if len(types) == 0 {
+		return nil, fmt.Errorf("fieldsgen: no types: %w", errs.ErrInvalidInput)
+	}
+	pkg, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, errs.Wrap("fieldsgen.Generate", err)
+	}
+	structs := make(map[string]*ast.StructType)
+	fset := token.NewFileSet()
+	for _, name := range pkg.GoFiles {
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, errs.Wrap("fieldsgen.Generate", err)
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			if spec, ok := n.(*ast.TypeSpec); ok {
+				if st, ok := spec.Type.(*ast.StructType); ok {
+					structs[spec.Name.Name] = st
+				}
+			}
+			return true
+		})
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by \"fieldsgen -type %s\"; DO NOT EDIT.\n\n", strings.Join(types, ","))
+	fmt.Fprintf(&buf, "package %s\n\n", pkg.Name)
+	buf.WriteString("// Field is one field of a struct with a generated Fields method.\n")
+	buf.WriteString("type Field struct {\n\t// Name is the field's JSON name.\n\tName  string\n\tValue any\n}\n")
+	for _, typ := range types {
+		st, ok := structs[typ]
+		if !ok {
+			return nil, fmt.Errorf("fieldsgen: no struct type %s in %s: %w", typ, dir, errs.ErrNotFound)
+		}
+		fields, err := jsonFields(typ, st)
+		if err != nil {
+			return nil, err
+		}
+		recv := strings.ToLower(typ[:1])
+		fmt.Fprintf(&buf, "\n// Fields is %s's fields in declaration order, as they are named in JSON.\n", recv)
+		fmt.Fprintf(&buf, "func (%s %s) Fields() []Field {\n\treturn []Field{\n", recv, typ)
+		for _, f := range fields {
+			fmt.Fprintf(&buf, "\t\t{Name: %q, Value: %s.%s},\n", f.name, recv, f.goName)
+		}
+		buf.WriteString("\t}\n}\n")
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		// a bug here rather than in the input, the source is all ours
+		return nil, fmt.Errorf("fieldsgen: formatting generated source: %w", err)
+	}
+	return src, nil
+}
+
+func jsonFields(typ string, st *ast.StructType) ([]field, error) {
+	var fields []field
+	seen := make(map[string]bool)
+	for _, f := range st.Fields.List {
+		if len(f.Names) == 0 {
+			return nil, fmt.Errorf("fieldsgen: %s has an embedded field, which is not supported: %w", typ, errors.ErrUnsupported)
+		}
+		tag := ""
+		if f.Tag != nil {
+			unquoted, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return nil, fmt.Errorf("fieldsgen: %s: malformed tag %s: %w", typ, f.Tag.Value, errs.ErrInvalidInput)
+			}
+			tag = reflect.StructTag(unquoted).Get("json")
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" && !strings.HasPrefix(tag, "-,") {
+			continue
+		}
+		for _, ident := range f.Names {
+			if !unicode.IsUpper([]rune(ident.Name)[0]) {
+				continue
+			}
+			jsonName := name
+			if jsonName == "" {
+				jsonName = ident.Name
+			}
+			if seen[jsonName] {
+				return nil, fmt.Errorf("fieldsgen: %s has two fields named %q: %w", typ, jsonName, errs.ErrConflict)
+			}
+			seen[jsonName] = true
+			fields = append(fields, field{goName: ident.Name, name: jsonName})
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,52 @@
+package fieldsgen_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/fieldsgen"
+)
+
+// TestUpToDate regenerates serialization's Fields in memory and compares
+// them with the checked-in file, catching a forgotten go generate.
+func TestUpToDate(t *testing.T) {
+	dir := filepath.Join("..", "serialization")
+	want, err := fieldsgen.Generate(dir, []string{"User"})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "user_fields.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("user_fields.go differs from what fieldsgen generates, run go generate ./serialization")
+	}
+}
+
+func TestRefused(t *testing.T) {
+	for _, tt := range []struct {
+		name, src string
+		types     []string
+		want      error
+	}{
+		{"no types", "type T struct{}", nil, errs.ErrInvalidInput},
+		{"missing type", "type T struct{}", []string{"Nope"}, errs.ErrNotFound},
+		{"embedded field", "type E struct{ A int }\ntype T struct{ E }", []string{"T"}, errors.ErrUnsupported},
+		{"two fields one name", "type T struct {\n\tA int `json:\"x\"`\n\tB int `json:\"x\"`\n}", []string{"T"}, errs.ErrConflict},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "p.go"), []byte("package p\n\n"+tt.src+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fieldsgen.Generate(dir, tt.types); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+// Package fn has the slice and map helpers that come up in every code base,
+// written with their cost in mind. Every helper allocates at most once, sized
+// up front, and the InPlace variants reuse the input's backing array and
+// allocate nothing. The benchmarks measure each against the loop it replaces.
+//
+// A helper is not free: the callback is an indirect call the compiler may
+// not inline. In a hot loop, write the loop.
+package fn
+
+// Map returns f applied to every element, in one allocation of len(s).
+func Map[S ~[]E, E, R any](s S, f func(E) R) []R {
+	out := make([]R, len(s))
+	for i, e := range s {
+		out[i] = f(e)
+	}
+	return out
+}
+
+// MapInPlace overwrites every element with f applied to it and returns s.
+func MapInPlace[S ~[]E, E any](s S, f func(E) E) S {
+	for i, e := range s {
+		s[i] = f(e)
+	}
+	return s
+}
+
+// Filter returns the elements keep reports true for. It allocates once at
+// len(s) rather than growing by appends, trading unused capacity for
+// calling keep only once per element.
+func Filter[S ~[]E, E any](s S, keep func(E) bool) S {
+	out := make(S, 0, len(s))
+	for _, e := range s {
+		if keep(e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// FilterInPlace moves the kept elements to the front of s and returns that
+// prefix, no allocation. The tail is zeroed so dropped pointers can be
+// collected, s itself must not be used afterwards. It is slices.DeleteFunc
+// with the predicate inverted.
+func FilterInPlace[S ~[]E, E any](s S, keep func(E) bool) S {
+	n := 0
+	for _, e := range s {
+		if keep(e) {
+			s[n] = e
+			n++
+		}
+	}
+	clear(s[n:])
+	return s[:n]
+}
+
+// Reduce folds s into one value, starting from init.
+func Reduce[S ~[]E, E, A any](s S, init A, f func(A, E) A) A {
+	acc := init
+	for _, e := range s {
+		acc = f(acc, e)
+	}
+	return acc
+}
+
+// Chunk splits s into consecutive pieces of size, the last may be shorter.
+// The pieces share s's backing array, only the outer slice is allocated.
+// Each piece's capacity ends where it does, so appending to one cannot
+// overwrite the next. It panics if size is less than 1.
+func Chunk[S ~[]E, E any](s S, size int) []S {
+	if size < 1 {
+		panic("fn: Chunk size must be at least 1")
+	}
+	out := make([]S, 0, (len(s)+size-1)/size)
+	for i := 0; i < len(s); i += size {
+		end := min(i+size, len(s))
+		out = append(out, s[i:end:end])
+	}
+	return out
+}
+
+// GroupBy buckets the elements by key, keeping their order within a
+// bucket. The group slices grow by append, one map and a few allocations
+// per distinct key.
+func GroupBy[S ~[]E, E any, K comparable](s S, key func(E) K) map[K]S {
+	out := make(map[K]S)
+	for _, e := range s {
+		k := key(e)
+		out[k] = append(out[k], e)
+	}
+	return out
+}
+
+// MapValues returns a map with the same keys and f applied to every value,
+// sized once for len(m).
+func MapValues[M ~map[K]V, K comparable, V, R any](m M, f func(V) R) map[K]R {
+	out := make(map[K]R, len(m))
+	for k, v := range m {
+		out[k] = f(v)
+	}
+	return out
+}
@@ -0,0 +1,165 @@
+// Package fsm is a finite state machine over typed states and events. A
+// Machine only knows the rules: which event moves which state where, and
+// under what condition. It holds no current state, the caller passes it in
+// and stores what Fire returns, so one Machine serves every subject:
+//
+//	m := fsm.New[Status, Event, *User]()
+//	m.Allow(Pending, Verify, Verified)
+//	m.Allow(Verified, Activate, Active, hasPassword)
+//
+//	next, err := m.Fire(ctx, user.Status, Activate, user)
+//
+// Define the transitions and hooks before the first Fire, a Machine is
+// safe for concurrent Fire calls but not for changes while they run.
+package fsm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// ErrIllegalTransition means the event is not allowed in the current
+// state. It wraps errs.ErrConflict: the request was fine, the subject is in
+// the wrong state for it.
+var ErrIllegalTransition = fmt.Errorf("illegal transition: %w", errs.ErrConflict)
+
+// Guard decides whether a transition may happen for subject, an error
+// refuses it and is returned by Fire.
+type Guard[T any] func(ctx context.Context, subject T) error
+
+// Transition is one move from a state to another on an event.
+type Transition[S, E comparable] struct {
+	From  S
+	Event E
+	To    S
+}
+
+// Hook runs as subject leaves or enters a state. It may change subject, the
+// caller stores it along with the new state. An error abandons the
+// transition.
+type Hook[S, E comparable, T any] func(ctx context.Context, t Transition[S, E], subject T) error
+
+// TransitionError reports a transition Fire refused. Err is
+// ErrIllegalTransition when there is no such transition, otherwise what the
+// guard or hook returned.
+type TransitionError struct {
+	From  any
+	Event any
+	// To is nil for an illegal transition.
+	To  any
+	Err error
+}
+
+func (e *TransitionError) Error() string {
+	if e.To == nil {
+		return fmt.Sprintf("%v in state %v: %s", e.Event, e.From, e.Err)
+	}
+	return fmt.Sprintf("%v from %v to %v: %s", e.Event, e.From, e.To, e.Err)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return e.Err
+}
+
+type key[S, E comparable] struct {
+	from  S
+	event E
+}
+
+type edge[S comparable, T any] struct {
+	to     S
+	guards []Guard[T]
+}
+
+// Machine holds the transitions between states S on events E, for subjects
+// of type T.
+type Machine[S, E comparable, T any] struct {
+	edges map[key[S, E]]edge[S, T]
+	// events keeps each state's events in the order they were allowed
+	events  map[S][]E
+	onEnter map[S][]Hook[S, E, T]
+	onExit  map[S][]Hook[S, E, T]
+}
+
+func New[S, E comparable, T any]() *Machine[S, E, T] {
+	return &Machine[S, E, T]{
+		edges:   make(map[key[S, E]]edge[S, T]),
+		events:  make(map[S][]E),
+		onEnter: make(map[S][]Hook[S, E, T]),
+		onExit:  make(map[S][]Hook[S, E, T]),
+	}
+}
+
+// Allow adds the transition from on event to to, taken only if every guard
+// passes. It panics if from already has a transition on event, a machine
+// must be deterministic.
+func (m *Machine[S, E, T]) Allow(from S, event E, to S, guards ...Guard[T]) *Machine[S, E, T] {
+	k := key[S, E]{from, event}
+	if _, ok := m.edges[k]; ok {
+		panic(fmt.Sprintf("fsm: %v in state %v is already allowed", event, from))
+	}
+	m.edges[k] = edge[S, T]{to: to, guards: guards}
+	m.events[from] = append(m.events[from], event)
+	return m
+}
+
+// OnEnter runs hook whenever a transition ends in state, after the exit
+// hooks of the state it leaves.
+func (m *Machine[S, E, T]) OnEnter(state S, hook Hook[S, E, T]) *Machine[S, E, T] {
+	m.onEnter[state] = append(m.onEnter[state], hook)
+	return m
+}
+
+// OnExit runs hook whenever a transition leaves state, after the guards
+// passed.
+func (m *Machine[S, E, T]) OnExit(state S, hook Hook[S, E, T]) *Machine[S, E, T] {
+	m.onExit[state] = append(m.onExit[state], hook)
+	return m
+}
+
+// Can reports whether current has a transition on event, without running
+// its guards.
+func (m *Machine[S, E, T]) Can(current S, event E) bool {
+	_, ok := m.edges[key[S, E]{current, event}]
+	return ok
+}
+
+// Events returns the events current has transitions on, in the order they
+// were allowed.
+func (m *Machine[S, E, T]) Events(current S) []E {
+	return append([]E(nil), m.events[current]...)
+}
+
+// Fire runs the transition from current on event for subject: its guards,
+// then the exit hooks of current, then the enter hooks of the new state,
+// stopping at the first error. It returns the new state, or current and a
+// *TransitionError. Nothing is stored, on success the caller saves the new
+// state, and whatever the hooks changed, as one write.
+func (m *Machine[S, E, T]) Fire(ctx context.Context, current S, event E, subject T) (S, error) {
+	e, ok := m.edges[key[S, E]{current, event}]
+	if !ok {
+		return current, &TransitionError{From: current, Event: event, Err: ErrIllegalTransition}
+	}
+	refuse := func(err error) (S, error) {
+		return current, &TransitionError{From: current, Event: event, To: e.to, Err: err}
+	}
+	for _, guard := range e.guards {
+		if err := guard(ctx, subject); err != nil {
+			return refuse(err)
+		}
+	}
+	t := Transition[S, E]{From: current, Event: event, To: e.to}
+	for _, hook := range m.onExit[current] {
+		if err := hook(ctx, t, subject); err != nil {
+			return refuse(err)
+		}
+	}
+	for _, hook := range m.onEnter[e.to] {
+		if err := hook(ctx, t, subject); err != nil {
+			return refuse(err)
+		}
+	}
+	return e.to, nil
+}
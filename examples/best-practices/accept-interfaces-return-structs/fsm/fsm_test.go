@@ -0,0 +1,96 @@
+package fsm_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/fsm"
+)
+
+type door string
+
+// doors is a door that opens, closes and locks, logging its hooks to the
+// subject. Entering locked fails with lockErr, a nil lockErr lets it.
+func doors(lockErr error) *fsm.Machine[door, string, *[]string] {
+	hook := func(what string, err error) fsm.Hook[door, string, *[]string] {
+		return func(ctx context.Context, t fsm.Transition[door, string], log *[]string) error {
+			*log = append(*log, fmt.Sprintf("%s %s->%s", what, t.From, t.To))
+			return err
+		}
+	}
+	return fsm.New[door, string, *[]string]().
+		Allow("closed", "open", "open").
+		Allow("open", "close", "closed").
+		Allow("closed", "lock", "locked").
+		OnExit("closed", hook("exit", nil)).
+		OnEnter("open", hook("enter", nil)).
+		OnEnter("locked", hook("enter", lockErr))
+}
+
+// TestHooks checks hooks run exit then enter, and a failing one abandons
+// the move.
+func TestHooks(t *testing.T) {
+	ctx := context.Background()
+	jammed := errors.New("jammed")
+	m := doors(jammed)
+	var calls []string
+	next, err := m.Fire(ctx, "closed", "open", &calls)
+	if err != nil || next != "open" {
+		t.Fatalf("Fire open = %s, %v, want open", next, err)
+	}
+	if want := []string{"exit closed->open", "enter closed->open"}; !slices.Equal(calls, want) {
+		t.Errorf("hooks %v, want %v", calls, want)
+	}
+	next, err = m.Fire(ctx, "closed", "lock", &calls)
+	var terr *fsm.TransitionError
+	if next != "closed" || !errors.Is(err, jammed) || !errors.As(err, &terr) || terr.To != door("locked") {
+		t.Errorf("Fire lock = %s, %v, want closed and the hook's error", next, err)
+	}
+}
+
+func TestIllegalTransition(t *testing.T) {
+	var calls []string
+	next, err := doors(nil).Fire(context.Background(), "open", "lock", &calls)
+	var terr *fsm.TransitionError
+	if !errors.As(err, &terr) || !errors.Is(err, fsm.ErrIllegalTransition) || !errors.Is(err, errs.ErrConflict) {
+		t.Fatalf("Fire = %v, want an illegal transition", err)
+	}
+	if next != "open" || terr.From != door("open") || terr.Event != "lock" {
+		t.Errorf("Fire = %s, %+v, want to stay open, the error naming lock in open", next, terr)
+	}
+	if len(calls) != 0 {
+		t.Errorf("hooks %v, want none", calls)
+	}
+}
+
+func TestGuards(t *testing.T) {
+	ctx := context.Background()
+	noKey := errors.New("no key")
+	m := fsm.New[door, string, bool]().
+		Allow("locked", "unlock", "closed", func(ctx context.Context, hasKey bool) error {
+			if !hasKey {
+				return noKey
+			}
+			return nil
+		})
+	if next, err := m.Fire(ctx, "locked", "unlock", false); next != "locked" || !errors.Is(err, noKey) || errors.Is(err, fsm.ErrIllegalTransition) {
+		t.Errorf("Fire without a key = %s, %v, want to stay locked with the guard's error", next, err)
+	}
+	if next, err := m.Fire(ctx, "locked", "unlock", true); next != "closed" || err != nil {
+		t.Errorf("Fire with a key = %s, %v, want closed", next, err)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	m := doors(nil)
+	if got := m.Events("closed"); !slices.Equal(got, []string{"open", "lock"}) {
+		t.Errorf("Events closed = %v, want [open lock]", got)
+	}
+	if !m.Can("open", "close") || m.Can("open", "open") {
+		t.Error("Can disagrees with what open allows")
+	}
+}
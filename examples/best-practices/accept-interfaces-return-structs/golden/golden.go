@@ -0,0 +1,167 @@
+// Package golden compares output against a checked in file, for responses
+// and documents too long to assert on field by field.
+//
+//	golden.Assert(t, "create_user", rec.Body.Bytes(),
+//		golden.WithNormalizer(golden.IndentJSON, golden.Timestamps))
+//
+// compares the body with testdata/create_user.golden. Run with -update to
+// write the file from the output instead, then review the change in the
+// diff like any other. The flag is golden's, so name the packages that
+// use it, a package without it refuses the flag:
+//
+//	go test ./transport/http -update
+//
+// Output that differs on every run, such as timestamps and generated IDs,
+// goes through normalizers first, so the file holds placeholders in their
+// place. They run in the order given, on the output before it is written
+// as well as before it is compared.
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files from the output instead of comparing")
+
+// T is the part of *testing.T Assert uses.
+type T interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// Normalizer rewrites output before it is compared or written.
+type Normalizer func([]byte) []byte
+
+type Option func(*options)
+
+type options struct {
+	dir         string
+	normalizers []Normalizer
+}
+
+// WithDir reads and writes golden files in dir, the default is testdata,
+// which go test resolves against the package directory.
+func WithDir(dir string) Option {
+	return func(o *options) {
+		o.dir = dir
+	}
+}
+
+// WithNormalizer adds normalizers, run after any added before.
+func WithNormalizer(n ...Normalizer) Option {
+	return func(o *options) {
+		o.normalizers = append(o.normalizers, n...)
+	}
+}
+
+// Assert compares got, normalized, with the golden file name and reports a
+// mismatch on t, or writes the file when run with -update.
+func Assert(t T, name string, got []byte, opts ...Option) bool {
+	t.Helper()
+	o := options{dir: "testdata"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	for _, n := range o.normalizers {
+		got = n(got)
+	}
+	path := filepath.Join(o.dir, name+".golden")
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Errorf("golden: %s", err)
+			return false
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Errorf("golden: %s", err)
+			return false
+		}
+		return true
+	}
+	want, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Errorf("golden: %s does not exist, run with -update to create it", path)
+		return false
+	}
+	if err != nil {
+		t.Errorf("golden: %s", err)
+		return false
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("golden: %s differs, run with -update to accept the output\n%s", path, diff(want, got))
+		return false
+	}
+	return true
+}
+
+// diff shows the first line that differs, which is usually enough to see
+// what changed; the full picture is the file diff after -update.
+func diff(want, got []byte) string {
+	wantLines := strings.Split(string(want), "\n")
+	gotLines := strings.Split(string(got), "\n")
+	for i := range max(len(wantLines), len(gotLines)) {
+		w, g := line(wantLines, i), line(gotLines, i)
+		if w != g {
+			return "line " + strconv.Itoa(i+1) + ":\n  want: " + w + "\n  got:  " + g
+		}
+	}
+	return "trailing bytes differ"
+}
+
+func line(lines []string, i int) string {
+	if i < len(lines) {
+		return strconv.Quote(lines[i])
+	}
+	return "(end of file)"
+}
+
+// IndentJSON reformats JSON with two space indents and a final newline, so
+// the golden file diffs line by line. Anything else is left as it is.
+func IndentJSON(b []byte) []byte {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, "", "  "); err != nil {
+		return b
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+var timestamp = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`)
+
+// Timestamps replaces RFC 3339 timestamps with <timestamp>.
+func Timestamps(b []byte) []byte {
+	return timestamp.ReplaceAll(b, []byte("<timestamp>"))
+}
+
+// Replace replaces every match of re with repl, which may refer to
+// submatches as in regexp.Regexp.Expand.
+func Replace(re *regexp.Regexp, repl string) Normalizer {
+	return func(b []byte) []byte {
+		return re.ReplaceAll(b, []byte(repl))
+	}
+}
+
+// IDs replaces every match of re with <name-1>, <name-2>, ... numbered in
+// order of first appearance, so the file still shows which IDs are the
+// same one without depending on their values.
+func IDs(name string, re *regexp.Regexp) Normalizer {
+	return func(b []byte) []byte {
+		seen := make(map[string]string)
+		return re.ReplaceAllFunc(b, func(id []byte) []byte {
+			p, ok := seen[string(id)]
+			if !ok {
+				p = "<" + name + "-" + strconv.Itoa(len(seen)+1) + ">"
+				seen[string(id)] = p
+			}
+			return []byte(p)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package golden_test
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/golden"
+)
+
+// recorder is a golden.T that keeps what Assert reports.
+type recorder struct {
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+// withUpdate runs the test with -update set.
+func withUpdate(t *testing.T) {
+	t.Helper()
+	if err := flag.Set("update", "true"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { flag.Set("update", "false") })
+}
+
+func TestAssert(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "greeting.golden"), []byte("hello\nworld\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		file string
+		got  string
+		ok   bool
+		want string
+	}{
+		{"match", "greeting", "hello\nworld\n", true, ""},
+		{"mismatch", "greeting", "hello\nthere\n", false, "line 2:\n  want: \"world\"\n  got:  \"there\""},
+		{"longer", "greeting", "hello\nworld\nagain", false, "line 3:\n  want: \"\"\n  got:  \"again\""},
+		{"missing", "nowhere", "hello\n", false, "does not exist, run with -update to create it"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{}
+			if ok := golden.Assert(r, tt.file, []byte(tt.got), golden.WithDir(dir)); ok != tt.ok {
+				t.Errorf("Assert = %t, want %t", ok, tt.ok)
+			}
+			if tt.ok != (len(r.errors) == 0) {
+				t.Fatalf("reported %q", r.errors)
+			}
+			if !tt.ok && !strings.Contains(r.errors[0], tt.want) {
+				t.Errorf("reported %q, want it to say %q", r.errors[0], tt.want)
+			}
+		})
+	}
+}
+
+// TestAssertUpdate checks -update writes the normalized output, creating
+// the directory, and that the file then matches without it.
+func TestAssertUpdate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "testdata", "nested")
+	upper := func(b []byte) []byte { return []byte(strings.ToUpper(string(b))) }
+	t.Run("update", func(t *testing.T) {
+		withUpdate(t)
+		r := &recorder{}
+		if !golden.Assert(r, "out", []byte("written\n"), golden.WithDir(dir), golden.WithNormalizer(upper)) || len(r.errors) > 0 {
+			t.Fatalf("Assert with -update reported %q", r.errors)
+		}
+		got, err := os.ReadFile(filepath.Join(dir, "out.golden"))
+		if err != nil || string(got) != "WRITTEN\n" {
+			t.Fatalf("golden file = %q, %v, want the normalized output", got, err)
+		}
+	})
+	t.Run("compare", func(t *testing.T) {
+		r := &recorder{}
+		if !golden.Assert(r, "out", []byte("Written\n"), golden.WithDir(dir), golden.WithNormalizer(upper)) {
+			t.Errorf("Assert after -update reported %q", r.errors)
+		}
+	})
+}
+
+func TestNormalizers(t *testing.T) {
+	uuid := regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}`)
+	for _, tt := range []struct {
+		name string
+		n    golden.Normalizer
+		in   string
+		want string
+	}{
+		{"IndentJSON", golden.IndentJSON, `{"a":1,"b":[true]}`, "{\n  \"a\": 1,\n  \"b\": [\n    true\n  ]\n}\n"},
+		{"IndentJSON not JSON", golden.IndentJSON, "plain text", "plain text"},
+		{"Timestamps", golden.Timestamps, `{"at":"2026-01-02T03:04:05Z","then":"2026-01-02T03:04:05.123456-07:00"}`, `{"at":"<timestamp>","then":"<timestamp>"}`},
+		{"Timestamps dates alone", golden.Timestamps, `{"on":"2026-01-02"}`, `{"on":"2026-01-02"}`},
+		{"IDs", golden.IDs("id", uuid), "0a0a0a0a-1111 b1b1b1b1-2222 0a0a0a0a-1111", "<id-1> <id-2> <id-1>"},
+		{"Replace", golden.Replace(regexp.MustCompile(`v(\d+)`), "version $1"), "v1 and v22", "version 1 and version 22"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(tt.n([]byte(tt.in))); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestIDsPerCall checks the numbering starts again on every call, each
+// output numbered on its own.
+func TestIDsPerCall(t *testing.T) {
+	ids := golden.IDs("user", regexp.MustCompile(`u\d+`))
+	ids([]byte("u7 u8"))
+	if got := string(ids([]byte("u8"))); got != "<user-1>" {
+		t.Errorf("second call = %q, want <user-1>", got)
+	}
+}
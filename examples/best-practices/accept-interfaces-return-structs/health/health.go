@@ -0,0 +1,177 @@
+// Package health serves liveness and readiness probes.
+//
+// /healthz answers whether the process is alive and runs no checks, a
+// database outage must not get every replica restarted. /readyz runs every
+// registered Checker and answers whether this replica should get traffic.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/multierr"
+)
+
+const defaultTimeout = 2 * time.Second
+
+// ErrDraining is in Report.Err once Drain has been called.
+var ErrDraining = errors.New("health: draining")
+
+// Checker reports whether one dependency is usable. Stores implement it
+// with a Ping, see sqlite.Store and postgres.Store.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function, such as (*sql.DB).PingContext, to Checker.
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+type check struct {
+	name    string
+	checker Checker
+	timeout time.Duration
+}
+
+// Health holds the registered checks. The zero value is not usable, call New.
+type Health struct {
+	mu       sync.RWMutex
+	checks   []check
+	draining atomic.Bool
+}
+
+func New() *Health {
+	return &Health{}
+}
+
+// Register adds a readiness check. Each check gets its own timeout, a zero
+// timeout means the default of 2 seconds.
+func (h *Health) Register(name string, c Checker, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, check{name: name, checker: c, timeout: timeout})
+}
+
+// Drain makes /readyz fail from now on so load balancers stop routing here
+// while in-flight requests finish. Pass it to httptransport.WithOnShutdown.
+func (h *Health) Drain() {
+	h.draining.Store(true)
+}
+
+// Result is one check's outcome in the /readyz body.
+type Result struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+	// err is what the checker returned, or the timeout, for Report.Err
+	err error
+}
+
+// Report is the /readyz body.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Err is the failed checks as one error, each keyed by its name in name
+// order, nil when the report is ok. errors.Is(report.Err(),
+// context.DeadlineExceeded) is whether a check timed out, and errors.As
+// reaches the error a checker returned, a driver's say.
+func (r Report) Err() error {
+	var failed multierr.Errors
+	for _, name := range slices.Sorted(maps.Keys(r.Checks)) {
+		failed.Add(name, r.Checks[name].err)
+	}
+	if r.Status == "draining" {
+		failed.Append(ErrDraining)
+	}
+	return failed.Err()
+}
+
+// Run executes every check concurrently, so the slowest check bounds the
+// probe instead of the sum of them all.
+func (h *Health) Run(ctx context.Context) Report {
+	h.mu.RLock()
+	checks := append([]check(nil), h.checks...)
+	h.mu.RUnlock()
+
+	report := Report{Status: "ok", Checks: make(map[string]Result, len(checks))}
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(ctx, c)
+		}()
+	}
+	wg.Wait()
+	for i, c := range checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != "ok" {
+			report.Status = "fail"
+		}
+	}
+	if h.draining.Load() {
+		report.Status = "draining"
+	}
+	return report
+}
+
+func run(ctx context.Context, c check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	start := time.Now()
+	// a checker that ignores ctx must not hang the probe, so it runs apart
+	done := make(chan error, 1)
+	go func() {
+		done <- c.checker.Check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	res := Result{Status: "ok", Duration: time.Since(start).String()}
+	if err != nil {
+		res.Status = "fail"
+		res.Error = err.Error()
+		res.err = err
+	}
+	return res
+}
+
+// Handler serves GET /healthz and GET /readyz.
+func (h *Health) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"ok"}` + "\n"))
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		report := h.Run(r.Context())
+		code := http.StatusOK
+		if report.Status != "ok" {
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(report)
+	})
+	return mux
+}
@@ -0,0 +1,80 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/health"
+)
+
+// driverError stands in for the error type a database driver returns.
+type driverError struct{ code string }
+
+func (e *driverError) Error() string { return "driver: " + e.code }
+
+// failing has one check that passes, one that fails and one that
+// outlives its timeout.
+func failing() *health.Health {
+	h := health.New()
+	h.Register("cache", health.CheckerFunc(func(context.Context) error { return nil }), 0)
+	h.Register("postgres", health.CheckerFunc(func(context.Context) error {
+		return &driverError{code: "57P03"}
+	}), 0)
+	h.Register("queue", health.CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}), 10*time.Millisecond)
+	return h
+}
+
+// TestReportErr checks a report's error unwraps to each failed check by
+// name.
+func TestReportErr(t *testing.T) {
+	ctx := context.Background()
+	h := failing()
+	err := h.Run(ctx).Err()
+	var driver *driverError
+	if !errors.As(err, &driver) || driver.code != "57P03" {
+		t.Errorf("errors.As found %v, want the driver's error", driver)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("errors.Is(DeadlineExceeded) = false, queue timed out: %v", err)
+	}
+	if want := "2 failures: postgres: driver: 57P03; queue: context deadline exceeded"; err == nil || err.Error() != want {
+		t.Errorf("Err = %v, want %q", err, want)
+	}
+	h.Drain()
+	if err := h.Run(ctx).Err(); !errors.Is(err, health.ErrDraining) {
+		t.Errorf("Err while draining = %v, want ErrDraining", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	ok := health.New()
+	ok.Register("cache", health.CheckerFunc(func(context.Context) error { return nil }), 0)
+	draining := health.New()
+	draining.Drain()
+	for _, tt := range []struct {
+		name   string
+		h      *health.Health
+		path   string
+		status int
+	}{
+		{"ready", ok, "/readyz", http.StatusOK},
+		{"a check failing", failing(), "/readyz", http.StatusServiceUnavailable},
+		{"draining", draining, "/readyz", http.StatusServiceUnavailable},
+		{"alive while failing", failing(), "/healthz", http.StatusOK},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.h.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.status {
+				t.Errorf("GET %s = %d, want %d", tt.path, w.Code, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Package hedge cuts tail latency by asking twice.
+//
+// A call that has not answered within Delay is probably stuck behind a GC
+// pause, a cold cache or a busy replica, and a fresh copy of it usually
+// finishes before the original does. Do starts that copy, takes whichever
+// answers first and cancels the other through its context. Set Delay near
+// the p95 of the call: about one call in twenty is sent twice, in return the
+// p99 drops toward the p95.
+//
+// Only hedge calls that are safe to run twice, reads and idempotent writes.
+package hedge
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+)
+
+type Option func(*Hedger)
+
+// WithClock sets the time source for the hedge delay, the default is
+// clock.Real.
+func WithClock(c clock.Clock) Option {
+	return func(h *Hedger) {
+		h.clock = c
+	}
+}
+
+// WithMaxHedges sets how many extra copies a slow call may grow, one Delay
+// apart, the default is 1.
+func WithMaxHedges(n int) Option {
+	return func(h *Hedger) {
+		h.maxHedges = max(n, 1)
+	}
+}
+
+// Hedger holds the delay and counts what hedging cost and won, it is safe
+// for concurrent use.
+type Hedger struct {
+	delay     time.Duration
+	maxHedges int
+	clock     clock.Clock
+
+	calls  atomic.Int64
+	hedged atomic.Int64
+	won    atomic.Int64
+}
+
+func New(delay time.Duration, opts ...Option) *Hedger {
+	h := &Hedger{
+		delay:     delay,
+		maxHedges: 1,
+		clock:     clock.Real,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Stats counts calls, extra attempts started, and calls answered by an
+// extra attempt rather than the original.
+type Stats struct {
+	Calls  int64
+	Hedged int64
+	Won    int64
+}
+
+func (h *Hedger) Stats() Stats {
+	return Stats{
+		Calls:  h.calls.Load(),
+		Hedged: h.hedged.Load(),
+		Won:    h.won.Load(),
+	}
+}
+
+type outcome[T any] struct {
+	attempt int
+	value   T
+	err     error
+}
+
+// Do calls fn, and again every Delay while no attempt has succeeded, up to
+// the hedger's limit. The first success is returned and the attempts still
+// running see their context canceled.
+//
+// An error before the first Delay is returned as is: a fast failure is not
+// a latency problem and retrying it is the retry package's job. Once
+// attempts overlap an error only counts when it is the last one standing.
+func Do[T any](ctx context.Context, h *Hedger, fn func(ctx context.Context) (T, error)) (T, error) {
+	h.calls.Add(1)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// buffered for every attempt, so losers finish without a reader
+	results := make(chan outcome[T], h.maxHedges+1)
+	start := func(attempt int) {
+		go func() {
+			value, err := fn(ctx)
+			results <- outcome[T]{attempt: attempt, value: value, err: err}
+		}()
+	}
+
+	start(0)
+	started, running := 1, 1
+	timer := h.clock.NewTimer(h.delay)
+	defer timer.Stop()
+	var zero T
+	for {
+		select {
+		case <-timer.C():
+			if started > h.maxHedges {
+				continue
+			}
+			h.hedged.Add(1)
+			start(started)
+			started++
+			running++
+			timer.Reset(h.delay)
+		case res := <-results:
+			running--
+			if res.err == nil {
+				if res.attempt > 0 {
+					h.won.Add(1)
+				}
+				return res.value, nil
+			}
+			if running == 0 || started == 1 {
+				return zero, res.err
+			}
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package hedge_test
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/hedge"
+)
+
+const delay = 10 * time.Millisecond
+
+var errFailed = errors.New("failed")
+
+// attempts hands each attempt Do starts to the test, which answers it
+// through reply and sees its context.
+type attempts struct {
+	started chan attempt
+}
+
+type attempt struct {
+	ctx   context.Context
+	reply chan result
+}
+
+type result struct {
+	value string
+	err   error
+}
+
+func newAttempts() *attempts {
+	return &attempts{started: make(chan attempt, 8)}
+}
+
+func (a *attempts) fn(ctx context.Context) (string, error) {
+	at := attempt{ctx: ctx, reply: make(chan result, 1)}
+	a.started <- at
+	select {
+	case r := <-at.reply:
+		return r.value, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// next waits for the next attempt to start.
+func (a *attempts) next(t *testing.T) attempt {
+	t.Helper()
+	select {
+	case at := <-a.started:
+		return at
+	case <-time.After(5 * time.Second):
+		t.Fatal("no attempt started")
+		return attempt{}
+	}
+}
+
+// none checks no further attempt has started.
+func (a *attempts) none(t *testing.T) {
+	t.Helper()
+	select {
+	case <-a.started:
+		t.Fatal("an attempt started early")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+// run calls Do in the background on a fake clock and returns what it
+// returns.
+func run(t *testing.T, ctx context.Context, opts ...hedge.Option) (*hedge.Hedger, *clock.Fake, *attempts, <-chan result) {
+	t.Helper()
+	fake := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	h := hedge.New(delay, append([]hedge.Option{hedge.WithClock(fake)}, opts...)...)
+	a := newAttempts()
+	done := make(chan result, 1)
+	go func() {
+		value, err := hedge.Do(ctx, h, a.fn)
+		done <- result{value, err}
+	}()
+	return h, fake, a, done
+}
+
+// advance moves the clock on once Do is waiting on its timer.
+func advance(fake *clock.Fake, d time.Duration) {
+	for fake.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	fake.Advance(d)
+}
+
+func wait(t *testing.T, done <-chan result) result {
+	t.Helper()
+	select {
+	case r := <-done:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("Do did not return")
+		return result{}
+	}
+}
+
+func canceled(t *testing.T, at attempt) {
+	t.Helper()
+	select {
+	case <-at.ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Error("the losing attempt's context was not canceled")
+	}
+}
+
+func TestFast(t *testing.T) {
+	h, _, a, done := run(t, context.Background())
+	a.next(t).reply <- result{value: "first"}
+	if r := wait(t, done); r.err != nil || r.value != "first" {
+		t.Errorf("Do = %q, %v, want first", r.value, r.err)
+	}
+	if s := h.Stats(); s != (hedge.Stats{Calls: 1}) {
+		t.Errorf("Stats = %+v, want one call and no hedge", s)
+	}
+}
+
+func TestHedgeAfterDelay(t *testing.T) {
+	h, fake, a, done := run(t, context.Background())
+	first := a.next(t)
+	advance(fake, delay-time.Millisecond)
+	a.none(t)
+	fake.Advance(time.Millisecond)
+	second := a.next(t)
+	second.reply <- result{value: "second"}
+	if r := wait(t, done); r.err != nil || r.value != "second" {
+		t.Errorf("Do = %q, %v, want second", r.value, r.err)
+	}
+	canceled(t, first)
+	if s := h.Stats(); s != (hedge.Stats{Calls: 1, Hedged: 1, Won: 1}) {
+		t.Errorf("Stats = %+v, want one call hedged and won", s)
+	}
+}
+
+func TestOriginalWins(t *testing.T) {
+	h, fake, a, done := run(t, context.Background())
+	first := a.next(t)
+	advance(fake, delay)
+	second := a.next(t)
+	first.reply <- result{value: "first"}
+	if r := wait(t, done); r.err != nil || r.value != "first" {
+		t.Errorf("Do = %q, %v, want first", r.value, r.err)
+	}
+	canceled(t, second)
+	if s := h.Stats(); s.Won != 0 {
+		t.Errorf("Stats.Won = %d, want 0", s.Won)
+	}
+}
+
+func TestSuccessOverError(t *testing.T) {
+	_, fake, a, done := run(t, context.Background())
+	first := a.next(t)
+	advance(fake, delay)
+	second := a.next(t)
+	second.reply <- result{err: errFailed}
+	a.none(t)
+	first.reply <- result{value: "first"}
+	if r := wait(t, done); r.err != nil || r.value != "first" {
+		t.Errorf("Do = %q, %v, want the success after the error", r.value, r.err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	t.Run("before the delay", func(t *testing.T) {
+		h, _, a, done := run(t, context.Background())
+		a.next(t).reply <- result{err: errFailed}
+		if r := wait(t, done); !errors.Is(r.err, errFailed) {
+			t.Errorf("Do: err = %v, want errFailed", r.err)
+		}
+		if s := h.Stats(); s.Hedged != 0 {
+			t.Errorf("Stats.Hedged = %d, want a fast error not hedged", s.Hedged)
+		}
+	})
+	t.Run("every attempt", func(t *testing.T) {
+		_, fake, a, done := run(t, context.Background())
+		first := a.next(t)
+		advance(fake, delay)
+		second := a.next(t)
+		first.reply <- result{err: errors.New("first")}
+		a.none(t)
+		second.reply <- result{err: errFailed}
+		if r := wait(t, done); !errors.Is(r.err, errFailed) {
+			t.Errorf("Do: err = %v, want the last one standing", r.err)
+		}
+	})
+}
+
+func TestMaxHedges(t *testing.T) {
+	h, fake, a, done := run(t, context.Background(), hedge.WithMaxHedges(2))
+	first := a.next(t)
+	advance(fake, delay)
+	a.next(t)
+	advance(fake, delay)
+	third := a.next(t)
+	advance(fake, delay)
+	a.none(t)
+	third.reply <- result{value: "third"}
+	if r := wait(t, done); r.value != "third" {
+		t.Errorf("Do = %q, %v, want third", r.value, r.err)
+	}
+	canceled(t, first)
+	if s := h.Stats(); s.Hedged != 2 {
+		t.Errorf("Stats.Hedged = %d, want 2", s.Hedged)
+	}
+}
+
+func TestCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	_, _, a, done := run(t, ctx)
+	first := a.next(t)
+	cancel()
+	if r := wait(t, done); !errors.Is(r.err, context.Canceled) {
+		t.Errorf("Do: err = %v, want context.Canceled", r.err)
+	}
+	canceled(t, first)
+}
+
+// BenchmarkDo reads through a call that now and then stalls, plain and
+// hedged at about its p95, and reports the percentiles: hedging costs a
+// few percent more calls and pulls the p99 down toward the delay plus one
+// fast call.
+//
+//	go test ./hedge -run '^$' -bench Do
+func BenchmarkDo(b *testing.B) {
+	const fast, slow, slowRate = 2 * time.Millisecond, 50 * time.Millisecond, 0.03
+	call := func(ctx context.Context) (int, error) {
+		d := fast
+		if rand.Float64() < slowRate {
+			d = slow
+		}
+		select {
+		case <-time.After(d):
+			return 0, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+	for _, hedging := range []bool{false, true} {
+		name := "plain"
+		if hedging {
+			name = "hedged"
+		}
+		b.Run(name, func(b *testing.B) {
+			h := hedge.New(5 * time.Millisecond)
+			var mu sync.Mutex
+			var latencies []time.Duration
+			b.SetParallelism(4)
+			b.RunParallel(func(pb *testing.PB) {
+				ctx := context.Background()
+				var own []time.Duration
+				for pb.Next() {
+					start := time.Now()
+					if hedging {
+						hedge.Do(ctx, h, call)
+					} else {
+						call(ctx)
+					}
+					own = append(own, time.Since(start))
+				}
+				mu.Lock()
+				latencies = append(latencies, own...)
+				mu.Unlock()
+			})
+			slices.Sort(latencies)
+			pct := func(p float64) float64 {
+				return float64(latencies[int(p*float64(len(latencies)-1))]) / float64(time.Millisecond)
+			}
+			b.ReportMetric(pct(0.50), "p50-ms")
+			b.ReportMetric(pct(0.99), "p99-ms")
+			if hedging {
+				b.ReportMetric(float64(h.Stats().Hedged)/float64(b.N), "hedged/op")
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+// Package httpclient is an *http.Client with the defaults a service wants
+// when it calls someone else's API: a timeout, a connection pool sized for
+// more than two idle connections per host, retries with backoff for
+// requests that are safe to repeat, and an optional circuit breaker.
+//
+// Code that makes HTTP calls should accept a Doer, not a *Client, so tests
+// can hand it a DoerFunc and never open a socket:
+//
+//	verifier := emailverify.New(httpclient.New(), url)
+//	fake := emailverify.New(httpclient.DoerFunc(func(req *http.Request) (*http.Response, error) {
+//		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(`{"deliverable":true}`))}, nil
+//	}), url)
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/circuitbreaker"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/retry"
+)
+
+// Doer sends a request. *http.Client and *Client satisfy it.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc adapts a function to a Doer.
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) { return f(req) }
+
+// StatusError is a response whose status says try again later: a 429 or a
+// 5xx that was still failing when the retries ran out. Its body has already
+// been read and closed. It unwraps to errs.ErrUnavailable, so callers can
+// treat it like an open breaker.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+func (e *StatusError) Unwrap() error {
+	return errs.ErrUnavailable
+}
+
+// temporaryStatus is a status worth retrying. A 500 is included, it is as
+// often a transient fault as a bug, the attempt limit bounds the cost.
+func temporaryStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// Retryable is the default retry classifier: network errors, per-attempt
+// timeouts, and StatusError. The request's own context ending is checked
+// separately, it stops the retries whatever the classifier says.
+func Retryable(err error) bool {
+	return !errors.Is(err, context.Canceled)
+}
+
+type Option func(*Client)
+
+// WithTimeout bounds each attempt, headers and body, the default is 10
+// seconds. The request's context bounds the call as a whole.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.http.Timeout = d
+	}
+}
+
+// WithTransport replaces the pooled default transport, to add tracing with
+// otelhttp.NewTransport or to talk to an httptest.Server.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.http.Transport = rt
+	}
+}
+
+// WithPolicy sets the retry policy for every request, the default is
+// retry.DefaultPolicy with Retryable as its classifier.
+func WithPolicy(p retry.Policy) Option {
+	return func(c *Client) {
+		c.policy = p
+	}
+}
+
+// WithBreaker fails calls fast while the breaker is open. It sits outside
+// the retries, a call counts once however many attempts it took.
+func WithBreaker(b *circuitbreaker.Breaker) Option {
+	return func(c *Client) {
+		c.breaker = b
+	}
+}
+
+// WithLogger logs every attempt, at debug when a response arrived and at
+// warn when it did not. The query string is left out, it is where APIs
+// like to put email addresses and keys.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// WithRequestHook calls fn before every attempt, e.g. to sign the request
+// or add an auth header.
+func WithRequestHook(fn func(req *http.Request)) Option {
+	return func(c *Client) {
+		c.onRequest = append(c.onRequest, fn)
+	}
+}
+
+// WithResponseHook calls fn after every attempt with whatever came back,
+// e.g. to record metrics. resp's body must not be read.
+func WithResponseHook(fn func(req *http.Request, resp *http.Response, err error, elapsed time.Duration)) Option {
+	return func(c *Client) {
+		c.onResponse = append(c.onResponse, fn)
+	}
+}
+
+var policyKey = ctxutil.NewKey[retry.Policy]("httpclient.policy")
+
+// WithRequestPolicy overrides the client's retry policy for requests made
+// with ctx, retry.Policy{MaxAttempts: 1} turns retries off for one call.
+func WithRequestPolicy(ctx context.Context, p retry.Policy) context.Context {
+	return policyKey.With(ctx, p)
+}
+
+// Client is safe for concurrent use, share one per dependency so they
+// share its connection pool and breaker.
+type Client struct {
+	http       *http.Client
+	policy     retry.Policy
+	breaker    *circuitbreaker.Breaker
+	logger     *slog.Logger
+	onRequest  []func(req *http.Request)
+	onResponse []func(req *http.Request, resp *http.Response, err error, elapsed time.Duration)
+}
+
+func New(opts ...Option) *Client {
+	policy := retry.DefaultPolicy()
+	policy.Retryable = Retryable
+	c := &Client{
+		http: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: defaultTransport(),
+		},
+		policy: policy,
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// defaultTransport is http.DefaultTransport with shorter connect timeouts
+// and room for more than DefaultMaxIdleConnsPerHost, which is 2: at any
+// real concurrency a single upstream would otherwise churn connections.
+func defaultTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = (&net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	t.TLSHandshakeTimeout = 5 * time.Second
+	t.ResponseHeaderTimeout = 5 * time.Second
+	t.MaxIdleConns = 100
+	t.MaxIdleConnsPerHost = 32
+	return t
+}
+
+// Do sends req, retrying while the policy allows when the request can be
+// replayed: an idempotent method, or an Idempotency-Key header, and a body
+// that is nil or has GetBody, which http.NewRequest sets for in-memory
+// bodies. Like *http.Client a 4xx is a response, not an error, only the
+// statuses worth retrying become a StatusError.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	policy := c.policy
+	if p, ok := policyKey.From(ctx); ok {
+		policy = p
+	}
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = Retryable
+	}
+	replay := replayable(req)
+	policy.Retryable = func(err error) bool {
+		return replay && ctx.Err() == nil && retryable(err)
+	}
+
+	attempt := 0
+	call := func(ctx context.Context) (*http.Response, error) {
+		attempt++
+		return c.attempt(req, attempt)
+	}
+	var resp *http.Response
+	var err error
+	if c.breaker == nil {
+		resp, err = retry.DoValue(ctx, policy, call)
+	} else {
+		err = c.breaker.Do(func() error {
+			var err error
+			resp, err = retry.DoValue(ctx, policy, call)
+			return err
+		})
+	}
+	if err != nil {
+		return nil, errs.Wrap("httpclient.Client.Do", err)
+	}
+	return resp, nil
+}
+
+func (c *Client) attempt(req *http.Request, attempt int) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	if attempt > 1 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	for _, fn := range c.onRequest {
+		fn(r)
+	}
+
+	start := time.Now()
+	resp, err := c.http.Do(r)
+	elapsed := time.Since(start)
+	for _, fn := range c.onResponse {
+		fn(r, resp, err, elapsed)
+	}
+	attrs := []any{
+		slog.String("method", r.Method),
+		slog.String("host", r.URL.Host),
+		slog.String("path", r.URL.Path),
+		slog.Int("attempt", attempt),
+		slog.Duration("elapsed", elapsed),
+	}
+	if err != nil {
+		c.logger.WarnContext(r.Context(), "http request failed", append(attrs, slog.Any("err", err))...)
+		return nil, err
+	}
+	c.logger.DebugContext(r.Context(), "http request", append(attrs, slog.Int("status", resp.StatusCode))...)
+
+	if temporaryStatus(resp.StatusCode) {
+		// drain so the connection goes back to the pool
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		return nil, &StatusError{Method: r.Method, URL: r.URL.Scheme + "://" + r.URL.Host + r.URL.Path, StatusCode: resp.StatusCode}
+	}
+	return resp, nil
+}
+
+// replayable reports whether sending req twice is safe and possible.
+func replayable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
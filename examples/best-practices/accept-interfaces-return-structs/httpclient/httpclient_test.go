@@ -0,0 +1,211 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/circuitbreaker"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/httpclient"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/retry"
+)
+
+// upstream answers with the next status in its script, 200 once the
+// script runs out, and records the body of every request.
+type upstream struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   []string
+}
+
+func (u *upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	u.mu.Lock()
+	u.bodies = append(u.bodies, string(body))
+	status := http.StatusOK
+	if len(u.statuses) > 0 {
+		status, u.statuses = u.statuses[0], u.statuses[1:]
+	}
+	u.mu.Unlock()
+	w.WriteHeader(status)
+	io.WriteString(w, "ok")
+}
+
+func (u *upstream) requests() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return len(u.bodies)
+}
+
+// fast retries three times with no wait between attempts.
+var fast = httpclient.WithPolicy(retry.Policy{MaxAttempts: 3, Retryable: httpclient.Retryable})
+
+func get(client *httpclient.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// start serves u on a loopback listener closed when the test ends.
+func start(t *testing.T, statuses ...int) (*upstream, *httptest.Server) {
+	t.Helper()
+	u := &upstream{statuses: statuses}
+	srv := httptest.NewServer(u)
+	t.Cleanup(srv.Close)
+	return u, srv
+}
+
+func TestRetries(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		method   string
+		key      string
+		statuses []int
+		status   int
+		err      error
+		requests int
+	}{
+		{"a 503 then success", http.MethodGet, "", []int{503}, 200, nil, 2},
+		{"a 429 then success", http.MethodPut, "", []int{429}, 200, nil, 2},
+		{"out of retries", http.MethodGet, "", []int{503, 502, 500}, 0, errs.ErrUnavailable, 3},
+		{"a 4xx is a response", http.MethodGet, "", []int{404}, 404, nil, 1},
+		{"a POST is not retried", http.MethodPost, "", []int{503}, 0, errs.ErrUnavailable, 1},
+		{"a POST with an idempotency key is", http.MethodPost, "k1", []int{503}, 200, nil, 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			u, srv := start(t, tt.statuses...)
+			req, _ := http.NewRequest(tt.method, srv.URL+"/v1", strings.NewReader("payload"))
+			if tt.key != "" {
+				req.Header.Set("Idempotency-Key", tt.key)
+			}
+			resp, err := httpclient.New(fast).Do(req)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Do: err = %v, want %v", err, tt.err)
+			}
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode != tt.status {
+					t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+				}
+			}
+			if got := u.requests(); got != tt.requests {
+				t.Errorf("upstream saw %d requests, want %d", got, tt.requests)
+			}
+			// a replayed body arrives whole every time
+			for i, body := range u.bodies {
+				if body != "payload" {
+					t.Errorf("attempt %d sent body %q, want payload", i+1, body)
+				}
+			}
+		})
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	_, srv := start(t, 503)
+	_, err := get(httpclient.New(httpclient.WithPolicy(retry.Policy{MaxAttempts: 1})), srv.URL+"/v1/check?email=ada@example.com")
+	var status *httpclient.StatusError
+	if !errors.As(err, &status) || status.StatusCode != 503 {
+		t.Fatalf("err = %v, want a StatusError for 503", err)
+	}
+	if strings.Contains(status.Error(), "ada") {
+		t.Errorf("StatusError %q has the query string in it", status)
+	}
+}
+
+func TestRequestPolicy(t *testing.T) {
+	u, srv := start(t, 503, 503)
+	ctx := httpclient.WithRequestPolicy(context.Background(), retry.Policy{MaxAttempts: 1})
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if _, err := httpclient.New(fast).Do(req); err == nil {
+		t.Fatal("Do with retries off for the call succeeded")
+	}
+	if got := u.requests(); got != 1 {
+		t.Errorf("upstream saw %d requests, want 1", got)
+	}
+}
+
+// TestCanceledStopsRetries checks a caller that gives up is let go at once,
+// not after the hour long backoff, with the last attempt's error.
+func TestCanceledStopsRetries(t *testing.T) {
+	u, srv := start(t, 503, 503, 503)
+	ctx, cancel := context.WithCancel(context.Background())
+	client := httpclient.New(
+		httpclient.WithPolicy(retry.Policy{MaxAttempts: 3, Backoff: retry.Constant(time.Hour)}),
+		httpclient.WithResponseHook(func(*http.Request, *http.Response, error, time.Duration) { cancel() }),
+	)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if _, err := client.Do(req); !errors.Is(err, errs.ErrUnavailable) {
+		t.Errorf("err = %v, want the 503", err)
+	}
+	if got := u.requests(); got != 1 {
+		t.Errorf("upstream saw %d requests, want 1", got)
+	}
+}
+
+// TestBreaker checks a call counts once against the breaker however many
+// attempts it took, and an open breaker fails without a request.
+func TestBreaker(t *testing.T) {
+	u, srv := start(t, 503, 503, 503, 503, 503, 503)
+	breaker := circuitbreaker.New(circuitbreaker.WithFailureThreshold(2), circuitbreaker.WithResetTimeout(time.Hour))
+	client := httpclient.New(fast, httpclient.WithBreaker(breaker))
+	for range 2 {
+		if _, err := get(client, srv.URL); err == nil {
+			t.Fatal("Get against a failing upstream succeeded")
+		}
+	}
+	if got := breaker.State(); got != circuitbreaker.Open {
+		t.Fatalf("after two failed calls the breaker is %s, want open", got)
+	}
+	if _, err := get(client, srv.URL); !errors.Is(err, circuitbreaker.ErrOpen) {
+		t.Errorf("err = %v, want ErrOpen", err)
+	}
+	if got := u.requests(); got != 6 {
+		t.Errorf("upstream saw %d requests, want 6, none behind the open breaker", got)
+	}
+}
+
+func TestHooksAndLogs(t *testing.T) {
+	_, srv := start(t, 503)
+	var logs bytes.Buffer
+	var attempts []string
+	client := httpclient.New(fast,
+		httpclient.WithLogger(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+		httpclient.WithRequestHook(func(req *http.Request) { req.Header.Set("Authorization", "Bearer k") }),
+		httpclient.WithResponseHook(func(req *http.Request, resp *http.Response, err error, _ time.Duration) {
+			attempts = append(attempts, req.Header.Get("Authorization")+" "+resp.Status)
+		}),
+	)
+	resp, err := get(client, srv.URL+"/v1/check?email=ada@example.com")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	resp.Body.Close()
+	if want := []string{"Bearer k 503 Service Unavailable", "Bearer k 200 OK"}; strings.Join(attempts, ",") != strings.Join(want, ",") {
+		t.Errorf("the hooks saw %q, want %q", attempts, want)
+	}
+	if out := logs.String(); !strings.Contains(out, "path=/v1/check") || strings.Contains(out, "ada") {
+		t.Errorf("logs = %s, want the path without the query string", out)
+	}
+}
+
+func TestDoerFunc(t *testing.T) {
+	var doer httpclient.Doer = httpclient.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusTeapot, Body: http.NoBody}, nil
+	})
+	req, _ := http.NewRequest(http.MethodGet, "http://example.invalid", nil)
+	if resp, err := doer.Do(req); err != nil || resp.StatusCode != http.StatusTeapot {
+		t.Errorf("Do = %v, %v, want the function's response", resp, err)
+	}
+}
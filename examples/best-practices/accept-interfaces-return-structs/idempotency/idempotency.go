@@ -0,0 +1,115 @@
+// Package idempotency keeps what an Idempotency-Key has already done, so a
+// retried request can be answered with the first response instead of being
+// carried out twice. A key is claimed before the request runs, and either
+// completed with the response or released when there is nothing worth
+// keeping, letting the next try run for real.
+//
+// Memory keeps records in a ttlcache.Cache, which forgets each one when its
+// TTL is up. The transport only needs an httptransport.IdempotencyStore, a
+// store shared by several replicas can stand in for it.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ttlcache"
+)
+
+// Record is what is kept for a key. Fingerprint identifies the request
+// that claimed it, a retry must match it. Status is zero while that
+// request is still running, and the response's code once it completes.
+type Record struct {
+	Fingerprint string
+	Status      int
+	Header      http.Header
+	Body        []byte
+}
+
+// Done reports whether the request that claimed the key has completed.
+func (r Record) Done() bool {
+	return r.Status != 0
+}
+
+// clone copies what a caller could change after handing r over.
+func (r Record) clone() Record {
+	r.Header = r.Header.Clone()
+	r.Body = bytes.Clone(r.Body)
+	return r
+}
+
+// Memory is a store in a ttlcache.Cache, for a single instance and for
+// demos. It is safe for concurrent use, Close it to stop the sweeper.
+type Memory struct {
+	// mu makes Claim's look and set one step, the cache has no
+	// set-if-absent of its own
+	mu      sync.Mutex
+	records *ttlcache.Cache[string, Record]
+}
+
+// NewMemory creates an empty store. opts configure the cache, how often it
+// sweeps and its clock. Every record is set with a TTL of its own.
+func NewMemory(opts ...ttlcache.Option) *Memory {
+	return &Memory{records: ttlcache.New[string, Record](0, opts...)}
+}
+
+// Claim stores rec for key, for ttl, when nothing is kept for it and
+// reports true. Otherwise it leaves the key alone and returns what is
+// kept, a Record still running or one that is Done.
+func (m *Memory) Claim(ctx context.Context, key string, rec Record, ttl time.Duration) (Record, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return Record{}, false, errs.Wrap("idempotency.Memory.Claim", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if held, ok := m.records.Get(key); ok {
+		return held.clone(), false, nil
+	}
+	m.records.SetWithTTL(key, rec.clone(), ttl)
+	return rec, true, nil
+}
+
+// Complete replaces the claim on key with rec, the finished response, to
+// be kept for ttl.
+func (m *Memory) Complete(ctx context.Context, key string, rec Record, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("idempotency.Memory.Complete", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records.SetWithTTL(key, rec.clone(), ttl)
+	return nil
+}
+
+// Release forgets key, the next request with it runs as if it were the
+// first. Releasing a key that is not kept does nothing.
+func (m *Memory) Release(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("idempotency.Memory.Release", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records.Delete(key)
+	return nil
+}
+
+// DeleteExpired drops every record whose TTL is up and returns how many,
+// without waiting for the sweeper.
+func (m *Memory) DeleteExpired() int {
+	return m.records.DeleteExpired()
+}
+
+// Len is the number of records kept, expired ones the sweeper has not
+// reached yet included.
+func (m *Memory) Len() int {
+	return m.records.Len()
+}
+
+// Close stops the sweeper.
+func (m *Memory) Close() {
+	m.records.Close()
+}
@@ -0,0 +1,94 @@
+package idempotency_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/idempotency"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ttlcache"
+)
+
+// newMemory is a store on fake, swept only by hand.
+func newMemory(t *testing.T, fake *clock.Fake) *idempotency.Memory {
+	t.Helper()
+	m := idempotency.NewMemory(ttlcache.WithClock(fake), ttlcache.WithSweepInterval(0))
+	t.Cleanup(m.Close)
+	return m
+}
+
+func TestClaim(t *testing.T) {
+	ctx := context.Background()
+	m := newMemory(t, clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)))
+	if _, ok, err := m.Claim(ctx, "k", idempotency.Record{Fingerprint: "first"}, time.Hour); err != nil || !ok {
+		t.Fatalf("Claim = %t, %v, want the key claimed", ok, err)
+	}
+	held, ok, err := m.Claim(ctx, "k", idempotency.Record{Fingerprint: "second"}, time.Hour)
+	if err != nil || ok {
+		t.Fatalf("second Claim = %t, %v, want the key held", ok, err)
+	}
+	if held.Fingerprint != "first" || held.Done() {
+		t.Errorf("held = %+v, want the first claim still running", held)
+	}
+
+	done := idempotency.Record{Fingerprint: "first", Status: http.StatusCreated, Header: http.Header{"Location": {"/users/1"}}, Body: []byte("{}")}
+	if err := m.Complete(ctx, "k", done, time.Hour); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	// what Complete was handed is copied, changing it changes nothing kept
+	done.Header.Set("Location", "/users/2")
+	done.Body[0] = 'x'
+	held, _, _ = m.Claim(ctx, "k", idempotency.Record{}, time.Hour)
+	if !held.Done() || held.Header.Get("Location") != "/users/1" || string(held.Body) != "{}" {
+		t.Errorf("held = %+v, want the completed response as it was", held)
+	}
+
+	if err := m.Release(ctx, "k"); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if _, ok, _ := m.Claim(ctx, "k", idempotency.Record{}, time.Hour); !ok {
+		t.Error("Claim after Release found the key held")
+	}
+	if err := m.Release(ctx, "unknown"); err != nil {
+		t.Errorf("Release of an unknown key: %v", err)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	m := newMemory(t, fake)
+	m.Claim(ctx, "short", idempotency.Record{}, time.Hour)
+	m.Claim(ctx, "long", idempotency.Record{}, 24*time.Hour)
+
+	fake.Advance(time.Hour)
+	// claimed again, for a day this time
+	if _, ok, _ := m.Claim(ctx, "short", idempotency.Record{}, 24*time.Hour); !ok {
+		t.Error("short still held at its TTL")
+	}
+	fake.Advance(23 * time.Hour)
+	if dropped := m.DeleteExpired(); dropped != 1 || m.Len() != 1 {
+		t.Errorf("DeleteExpired at 24h dropped %d, %d left, want long dropped and short kept", dropped, m.Len())
+	}
+}
+
+func TestContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m := newMemory(t, clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)))
+	if _, _, err := m.Claim(ctx, "k", idempotency.Record{}, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("Claim: err = %v, want context.Canceled", err)
+	}
+	if err := m.Complete(ctx, "k", idempotency.Record{Status: http.StatusOK}, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("Complete: err = %v, want context.Canceled", err)
+	}
+	if err := m.Release(ctx, "k"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Release: err = %v, want context.Canceled", err)
+	}
+	if m.Len() != 0 {
+		t.Errorf("Len = %d, want nothing kept", m.Len())
+	}
+}
@@ -0,0 +1,110 @@
+package idgen_test
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/idgen"
+)
+
+// The ID code as it was before the append-based rewrite, kept to compare
+// and benchmark against.
+
+func uuidBefore() string {
+	var b [16]byte
+	rand.Read(b[6:])
+	ms := uint64(time.Now().UnixMilli())
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+	b[6] = (b[6] & 0x0f) | 0x70
+	b[8] = (b[8] & 0x3f) | 0x80
+	var out [36]byte
+	hex.Encode(out[0:8], b[0:4])
+	out[8] = '-'
+	hex.Encode(out[9:13], b[4:6])
+	out[13] = '-'
+	hex.Encode(out[14:18], b[6:8])
+	out[18] = '-'
+	hex.Encode(out[19:23], b[8:10])
+	out[23] = '-'
+	hex.Encode(out[24:], b[10:])
+	return string(out[:])
+}
+
+func sequenceBefore(prefix string, n uint64) string {
+	return prefix + strconv.FormatUint(n, 10)
+}
+
+func TestSameAsBefore(t *testing.T) {
+	if before, after := uuidBefore(), (idgen.UUIDv7{}).NewID(); !uuidV7.MatchString(before) || len(before) != len(after) {
+		t.Errorf("before %q, after %q, want both UUIDv7", before, after)
+	}
+	if got, want := (&idgen.Sequence{Prefix: "user-"}).NewID(), sequenceBefore("user-", 1); got != want {
+		t.Errorf("Sequence.NewID = %q, want %q", got, want)
+	}
+}
+
+// TestAllocs checks NewID allocates only the string it returns, and
+// AppendID nothing at all into a buffer with room.
+func TestAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector adds allocations")
+	}
+	buf := make([]byte, 0, 64)
+	for _, tt := range []struct {
+		name string
+		gen  idgen.Appender
+	}{
+		{"UUIDv4", idgen.UUIDv4{}},
+		{"UUIDv7", idgen.UUIDv7{}},
+		{"ULID", idgen.ULID{}},
+		{"Sequence", &idgen.Sequence{Prefix: "user-"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testing.AllocsPerRun(1_000, func() { _ = tt.gen.NewID() }); got != 1 {
+				t.Errorf("NewID: %v allocations per run, want 1", got)
+			}
+			if got := testing.AllocsPerRun(1_000, func() { buf = tt.gen.AppendID(buf[:0]) }); got != 0 {
+				t.Errorf("AppendID: %v allocations per run, want 0", got)
+			}
+		})
+	}
+}
+
+// sink is where the benchmarks put what they make, so the compiler cannot
+// keep it on the stack as it could if it were thrown away.
+var sink string
+
+// BenchmarkIDs runs each generator against the code it replaced. The old
+// code had no AppendID, its before appends the string.
+func BenchmarkIDs(b *testing.B) {
+	buf := make([]byte, 0, 64)
+	var n uint64
+	seq := &idgen.Sequence{Prefix: "user-"}
+	v7 := idgen.UUIDv7{}
+	for _, bm := range []struct {
+		name          string
+		before, after func()
+	}{
+		{"UUIDv7.NewID", func() { sink = uuidBefore() }, func() { sink = v7.NewID() }},
+		{"UUIDv7.AppendID", func() { buf = append(buf[:0], uuidBefore()...) }, func() { buf = v7.AppendID(buf[:0]) }},
+		{"Sequence.NewID", func() { n++; sink = sequenceBefore("user-", n) }, func() { sink = seq.NewID() }},
+	} {
+		for _, v := range []struct {
+			name string
+			fn   func()
+		}{{"before", bm.before}, {"after", bm.after}} {
+			b.Run(bm.name+"/"+v.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for b.Loop() {
+					v.fn()
+				}
+			})
+		}
+	}
+}
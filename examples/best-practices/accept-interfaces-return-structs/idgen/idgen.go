@@ -0,0 +1,154 @@
+package idgen
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Generator mints unique IDs. It has the same method set as
+// service.IDGenerator, so every generator here can be passed to
+// service.WithIDGenerator without the service importing this package.
+type Generator interface {
+	NewID() string
+}
+
+// Appender is a Generator that can also write an ID into a buffer of the
+// caller's, every generator here is one. NewID's only allocation is the
+// string it returns, AppendID's only one is growing dst.
+type Appender interface {
+	Generator
+	AppendID(dst []byte) []byte
+}
+
+// UUIDv4 generates random RFC 9562 version 4 UUIDs.
+type UUIDv4 struct{}
+
+func (g UUIDv4) NewID() string {
+	var buf [uuidLen]byte
+	return string(g.AppendID(buf[:0]))
+}
+
+func (UUIDv4) AppendID(dst []byte) []byte {
+	var b [16]byte
+	fillRandom(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 9562 variant
+	return appendUUID(dst, &b)
+}
+
+// UUIDv7 generates time ordered RFC 9562 version 7 UUIDs: a 48 bit unix
+// millisecond timestamp followed by random bits. IDs sort by creation time,
+// which keeps database indexes and cursor pagination append-mostly.
+type UUIDv7 struct {
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+func (g UUIDv7) NewID() string {
+	var buf [uuidLen]byte
+	return string(g.AppendID(buf[:0]))
+}
+
+func (g UUIDv7) AppendID(dst []byte) []byte {
+	var b [16]byte
+	fillRandom(b[6:])
+	putMillis(b[:6], now(g.Now))
+	b[6] = (b[6] & 0x0f) | 0x70 // version 7
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 9562 variant
+	return appendUUID(dst, &b)
+}
+
+// ULID generates lexicographically sortable IDs: 48 bits of unix
+// milliseconds and 80 random bits, written as 26 Crockford base32 characters.
+type ULID struct {
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// The lengths of the IDs, each NewID builds its ID in an array this long
+// on the stack before the one copy into a string.
+const (
+	uuidLen = 36
+	ulidLen = 26
+)
+
+func (g ULID) NewID() string {
+	var buf [ulidLen]byte
+	return string(g.AppendID(buf[:0]))
+}
+
+func (g ULID) AppendID(dst []byte) []byte {
+	var b [16]byte
+	putMillis(b[:6], now(g.Now))
+	fillRandom(b[6:])
+
+	// 128 bits encode to 26 characters, 5 bits each with 2 bits of padding
+	// at the front, so walk the value from the least significant end.
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	var out [ulidLen]byte
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return append(dst, out[:]...)
+}
+
+// Sequence is a deterministic Generator for tests and examples, it returns
+// Prefix followed by 1, 2, 3, ... and is safe for concurrent use.
+type Sequence struct {
+	Prefix string
+	n      atomic.Uint64
+}
+
+func (s *Sequence) NewID() string {
+	// room for a short prefix and any uint64
+	var buf [32]byte
+	return string(s.AppendID(buf[:0]))
+}
+
+func (s *Sequence) AppendID(dst []byte) []byte {
+	return strconv.AppendUint(append(dst, s.Prefix...), s.n.Add(1), 10)
+}
+
+func now(fn func() time.Time) time.Time {
+	if fn == nil {
+		return time.Now()
+	}
+	return fn()
+}
+
+func putMillis(dst []byte, t time.Time) {
+	ms := uint64(t.UnixMilli())
+	for i := 5; i >= 0; i-- {
+		dst[i] = byte(ms)
+		ms >>= 8
+	}
+}
+
+// fillRandom panics if the system random source fails, there is no sensible
+// fallback for an ID generator and crypto/rand does not fail on supported platforms.
+func fillRandom(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic("idgen: crypto/rand failed: " + err.Error())
+	}
+}
+
+func appendUUID(dst []byte, b *[16]byte) []byte {
+	dst = hex.AppendEncode(dst, b[0:4])
+	dst = append(dst, '-')
+	dst = hex.AppendEncode(dst, b[4:6])
+	dst = append(dst, '-')
+	dst = hex.AppendEncode(dst, b[6:8])
+	dst = append(dst, '-')
+	dst = hex.AppendEncode(dst, b[8:10])
+	dst = append(dst, '-')
+	return hex.AppendEncode(dst, b[10:])
+}
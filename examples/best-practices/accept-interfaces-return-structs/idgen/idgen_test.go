@@ -0,0 +1,120 @@
+package idgen_test
+
+import (
+	"regexp"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/idgen"
+)
+
+var (
+	uuidV4 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	uuidV7 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	ulid   = regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+)
+
+func TestFormats(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		gen  idgen.Appender
+		want *regexp.Regexp
+	}{
+		{"uuidv4", idgen.UUIDv4{}, uuidV4},
+		{"uuidv7", idgen.UUIDv7{}, uuidV7},
+		{"ulid", idgen.ULID{}, ulid},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := make(map[string]bool)
+			for range 1000 {
+				id := tt.gen.NewID()
+				if !tt.want.MatchString(id) {
+					t.Fatalf("NewID = %q, want it to match %s", id, tt.want)
+				}
+				if seen[id] {
+					t.Fatalf("NewID repeated %q", id)
+				}
+				seen[id] = true
+			}
+			// AppendID writes the same shape after what dst already holds
+			got := tt.gen.AppendID([]byte("id:"))
+			if string(got[:3]) != "id:" || !tt.want.Match(got[3:]) {
+				t.Errorf("AppendID = %q, want id: and then an ID", got)
+			}
+		})
+	}
+}
+
+// TestTimeOrdered checks IDs minted at later milliseconds sort after
+// earlier ones, whatever their random bits.
+func TestTimeOrdered(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tt := range []struct {
+		name string
+		gen  func(now func() time.Time) idgen.Generator
+	}{
+		{"uuidv7", func(now func() time.Time) idgen.Generator { return idgen.UUIDv7{Now: now} }},
+		{"ulid", func(now func() time.Time) idgen.Generator { return idgen.ULID{Now: now} }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			at := start
+			gen := tt.gen(func() time.Time { return at })
+			var ids []string
+			for range 100 {
+				ids = append(ids, gen.NewID())
+				at = at.Add(time.Millisecond)
+			}
+			if !slices.IsSorted(ids) {
+				t.Errorf("IDs minted a millisecond apart are not sorted: %v", ids)
+			}
+		})
+	}
+}
+
+func TestULIDTimestamp(t *testing.T) {
+	// the first 10 characters are the millisecond timestamp, known for the
+	// unix epoch and for the largest 48 bit value
+	for _, tt := range []struct {
+		at   time.Time
+		want string
+	}{
+		{time.UnixMilli(0), "0000000000"},
+		{time.UnixMilli(1<<48 - 1), "7ZZZZZZZZZ"},
+	} {
+		id := idgen.ULID{Now: func() time.Time { return tt.at }}.NewID()
+		if id[:10] != tt.want {
+			t.Errorf("ULID at %d = %s, want the prefix %s", tt.at.UnixMilli(), id, tt.want)
+		}
+	}
+}
+
+func TestSequence(t *testing.T) {
+	seq := &idgen.Sequence{Prefix: "user-"}
+	for _, want := range []string{"user-1", "user-2", "user-3"} {
+		if got := seq.NewID(); got != want {
+			t.Fatalf("NewID = %q, want %q", got, want)
+		}
+	}
+	if got := string(seq.AppendID(nil)); got != "user-4" {
+		t.Errorf("AppendID = %q, want user-4", got)
+	}
+}
+
+func TestSequenceConcurrent(t *testing.T) {
+	const n = 100
+	var (
+		seq idgen.Sequence
+		wg  sync.WaitGroup
+		ids = make([]string, n)
+	)
+	for i := range n {
+		wg.Go(func() { ids[i] = seq.NewID() })
+	}
+	wg.Wait()
+	slices.Sort(ids)
+	if got := len(slices.Compact(ids)); got != n {
+		t.Errorf("%d goroutines got %d different IDs, want %d", n, got, n)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package idgen_test
+
+// raceEnabled is whether the tests were built with -race, which makes the
+// random bytes escape and so adds an allocation.
+const raceEnabled = true
@@ -0,0 +1,155 @@
+// Package ingest imports a users CSV file, gzipped or not, in one pass as
+// it is read. Each job is a small io.Reader or io.Writer wrapped around
+// the one before it, so no stage knows about the others:
+//
+//	r                  the upload, a file, a response body
+//	io.LimitReader     MaxSize, checked, so the upload cannot run on forever
+//	io.TeeReader       every byte also goes to sha256 and a byte count
+//	bufio.Reader       BufferSize, how much each read asks of r
+//	gzip.Reader        when the first two bytes say gzip
+//	io.LimitReader     MaxUncompressed, checked, against a gzip bomb
+//	ImportUsersCSV     a row at a time, in batches
+//
+// The bufio.Reader sits right in front of gzip on purpose. It is an
+// io.ByteReader, and given one gzip reads exactly what it needs through it
+// rather than wrapping r in a 4 KiB buffer of its own, so BufferSize is
+// the read size r sees. BenchmarkImport shows what that is worth.
+//
+// Nothing is buffered beyond BufferSize and the decompressor's window. The
+// digest is of the whole input only once the import has read to the end,
+// by which time the rows are in: the import is row by row, the same as
+// ImportUsersCSV, and a file that turns out too large or corrupt part way
+// keeps the rows before it.
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+const (
+	// DefaultBufferSize is BufferSize when it is left zero.
+	DefaultBufferSize = 64 << 10
+	// DefaultMaxSize is MaxSize when it is left zero.
+	DefaultMaxSize = 64 << 20
+	// DefaultMaxUncompressed is MaxUncompressed when it is left zero. CSV
+	// compresses about tenfold, this leaves room for that and not for a
+	// file built to expand a thousandfold.
+	DefaultMaxUncompressed = 16 * DefaultMaxSize
+)
+
+// ErrTooLarge is an input over MaxSize, or over MaxUncompressed once
+// decompressed.
+var ErrTooLarge = fmt.Errorf("too large: %w", errs.ErrInvalidInput)
+
+// gzipMagic is the first two bytes of every gzip file.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Importer is what Import feeds, *service.UserService has it.
+type Importer interface {
+	ImportUsersCSV(ctx context.Context, r io.Reader) (service.ImportResult, error)
+}
+
+// Options tunes Import, the zero value uses the defaults.
+type Options struct {
+	// MaxSize bounds the bytes read from r.
+	MaxSize int64
+	// MaxUncompressed bounds the CSV a gzipped input expands to.
+	MaxUncompressed int64
+	// BufferSize is how much each read asks of r.
+	BufferSize int
+}
+
+// Result is the import's rows and what was read to get them.
+type Result struct {
+	service.ImportResult
+	// Size is the bytes read from r, the gzipped size when it was gzipped.
+	Size int64
+	// CSVSize is the bytes of CSV, after decompression.
+	CSVSize int64
+	// Gzipped is whether the input was.
+	Gzipped bool
+	// SHA256 is the hex digest of the Size bytes read from r, of the whole
+	// input when Import returns no error.
+	SHA256 string
+}
+
+// Import reads a CSV file from r, gzipped or not, and imports its rows
+// into users. On an error Result still has the rows created before it and
+// what had been read.
+func Import(ctx context.Context, r io.Reader, users Importer, opts Options) (res Result, err error) {
+	maxSize := orDefault(opts.MaxSize, DefaultMaxSize)
+	maxUncompressed := orDefault(opts.MaxUncompressed, DefaultMaxUncompressed)
+	bufferSize := int(orDefault(int64(opts.BufferSize), DefaultBufferSize))
+
+	sum := sha256.New()
+	var size, csvSize counter
+	defer func() {
+		res.Size, res.CSVSize = int64(size), int64(csvSize)
+		res.SHA256 = hex.EncodeToString(sum.Sum(nil))
+		err = errs.Wrap("ingest.Import", err)
+	}()
+
+	in := &checked{r: io.LimitReader(r, maxSize+1), max: maxSize}
+	br := bufio.NewReaderSize(io.TeeReader(in, io.MultiWriter(sum, &size)), bufferSize)
+	var csv io.Reader = br
+	magic, err := br.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		// an empty file is left for the import to refuse for its header
+		return res, err
+	}
+	if bytes.Equal(magic, gzipMagic) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return res, err
+		}
+		defer zr.Close()
+		res.Gzipped = true
+		csv = &checked{r: io.LimitReader(zr, maxUncompressed+1), max: maxUncompressed}
+	}
+	res.ImportResult, err = users.ImportUsersCSV(ctx, io.TeeReader(csv, &csvSize))
+	return res, err
+}
+
+func orDefault(v, def int64) int64 {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
+
+// checked reads an io.LimitReader allowed one byte over max. Reaching that
+// byte means the input is over max, and is ErrTooLarge rather than the
+// quiet end of input the LimitReader alone would give, which would pass a
+// truncated file for a whole one.
+type checked struct {
+	r    io.Reader
+	max  int64
+	read int64
+}
+
+func (c *checked) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.read += int64(n)
+	if c.read > c.max {
+		return n - int(c.read-c.max), ErrTooLarge
+	}
+	return n, err
+}
+
+// counter is an io.Writer that counts what is written to it.
+type counter int64
+
+func (c *counter) Write(p []byte) (int, error) {
+	*c += counter(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,230 @@
+package ingest_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ingest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// usersCSV is a file of n users, every badEvery-th row without an email
+// when badEvery is set.
+func usersCSV(n, badEvery int) []byte {
+	var b bytes.Buffer
+	b.WriteString("id,email,name\n")
+	for i := range n {
+		email := fmt.Sprintf("user%06d@example.com", i)
+		if badEvery > 0 && i%badEvery == badEvery-1 {
+			email = ""
+		}
+		fmt.Fprintf(&b, "user-%06d,%s,Ada Lovelace\n", i, email)
+	}
+	return b.Bytes()
+}
+
+func gzipped(b []byte) []byte {
+	var out bytes.Buffer
+	zw := gzip.NewWriter(&out)
+	zw.Write(b)
+	zw.Close()
+	return out.Bytes()
+}
+
+// reads counts the reads made of r and the largest one asked for.
+type reads struct {
+	r       io.Reader
+	n       int
+	largest int
+}
+
+func (r *reads) Read(p []byte) (int, error) {
+	r.n++
+	r.largest = max(r.largest, len(p))
+	return r.r.Read(p)
+}
+
+// slow gives every read a fixed wait, like a network round trip.
+type slow struct {
+	r     io.Reader
+	delay time.Duration
+}
+
+func (s slow) Read(p []byte) (int, error) {
+	time.Sleep(s.delay)
+	return s.r.Read(p)
+}
+
+// parseOnly reads the CSV and stores nothing, so the benchmarks measure
+// the reading rather than the service creating users.
+type parseOnly struct{}
+
+func (parseOnly) ImportUsersCSV(ctx context.Context, r io.Reader) (service.ImportResult, error) {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	var res service.ImportResult
+	for {
+		_, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return res, nil
+		}
+		if err != nil {
+			return res, err
+		}
+		res.Created++
+	}
+}
+
+func users() *service.UserService {
+	return service.NewUserService(db.NewMemoryStore())
+}
+
+func TestImport(t *testing.T) {
+	plain := usersCSV(5_000, 0)
+	gz := gzipped(plain)
+	digest := sha256.Sum256(gz)
+
+	t.Run("gzipped", func(t *testing.T) {
+		res, err := ingest.Import(context.Background(), bytes.NewReader(gz), users(), ingest.Options{})
+		if err != nil {
+			t.Fatalf("Import: %v", err)
+		}
+		if res.Created != 5_000 || !res.Gzipped {
+			t.Errorf("created %d, gzipped %t, want 5000 from a gzipped file", res.Created, res.Gzipped)
+		}
+		if res.Size != int64(len(gz)) || res.CSVSize != int64(len(plain)) {
+			t.Errorf("read %d bytes to %d of CSV, want %d to %d", res.Size, res.CSVSize, len(gz), len(plain))
+		}
+		if res.SHA256 != hex.EncodeToString(digest[:]) {
+			t.Errorf("SHA256 = %s, want the file's %x", res.SHA256, digest)
+		}
+	})
+
+	t.Run("plain", func(t *testing.T) {
+		res, err := ingest.Import(context.Background(), bytes.NewReader(plain), users(), ingest.Options{})
+		if err != nil {
+			t.Fatalf("Import: %v", err)
+		}
+		if res.Created != 5_000 || res.Gzipped || res.Size != res.CSVSize {
+			t.Errorf("created %d, gzipped %t, %d bytes to %d, want 5000 plain", res.Created, res.Gzipped, res.Size, res.CSVSize)
+		}
+	})
+
+	t.Run("bad rows", func(t *testing.T) {
+		res, err := ingest.Import(context.Background(), bytes.NewReader(gzipped(usersCSV(1_000, 100))), users(), ingest.Options{})
+		if err != nil {
+			t.Fatalf("Import: %v", err)
+		}
+		if res.Created != 990 || res.Failed != 10 {
+			t.Errorf("created %d and failed %d, want 990 and 10", res.Created, res.Failed)
+		}
+	})
+}
+
+func TestMaxSize(t *testing.T) {
+	gz := gzipped(usersCSV(5_000, 0))
+	limit := int64(len(gz) / 2)
+	res, err := ingest.Import(context.Background(), bytes.NewReader(gz), users(), ingest.Options{MaxSize: limit})
+	if !errors.Is(err, ingest.ErrTooLarge) || !errors.Is(err, errs.ErrInvalidInput) {
+		t.Fatalf("err = %v, want ErrTooLarge", err)
+	}
+	if res.Size != limit {
+		t.Errorf("read %d bytes, want to stop at %d", res.Size, limit)
+	}
+}
+
+// TestGzipBomb checks a small file that decompresses into 64 MiB stops at
+// MaxUncompressed.
+func TestGzipBomb(t *testing.T) {
+	var bomb bytes.Buffer
+	zw := gzip.NewWriter(&bomb)
+	zw.Write([]byte("id,email,name\nada,ada@example.com,\""))
+	zw.Write(bytes.Repeat([]byte("a"), 64<<20))
+	zw.Close()
+	res, err := ingest.Import(context.Background(), &bomb, parseOnly{}, ingest.Options{MaxUncompressed: 1 << 20})
+	if !errors.Is(err, ingest.ErrTooLarge) {
+		t.Fatalf("err = %v, want ErrTooLarge", err)
+	}
+	if res.CSVSize != 1<<20 {
+		t.Errorf("decompressed %d bytes, want to stop at 1 MiB", res.CSVSize)
+	}
+}
+
+func TestCorruptGzip(t *testing.T) {
+	corrupt := gzipped(usersCSV(5_000, 0))
+	corrupt[len(corrupt)/2] ^= 0xff
+	if _, err := ingest.Import(context.Background(), bytes.NewReader(corrupt), users(), ingest.Options{}); err == nil {
+		t.Error("Import of a corrupt gzip succeeded")
+	}
+}
+
+// TestBufferSize checks BufferSize is the read size the source sees.
+func TestBufferSize(t *testing.T) {
+	gz := gzipped(usersCSV(5_000, 0))
+	for _, size := range []int{512, 64 << 10} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			src := &reads{r: bytes.NewReader(gz)}
+			if _, err := ingest.Import(context.Background(), src, parseOnly{}, ingest.Options{BufferSize: size}); err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			// one read past the end to see EOF, and the Peek for the magic
+			if want := len(gz)/size + 2; src.largest != size || src.n > want {
+				t.Errorf("%d reads of up to %d bytes, want at most %d of %d", src.n, src.largest, want, size)
+			}
+		})
+	}
+}
+
+// BenchmarkImport reads one file at several buffer sizes, from disk and
+// from a source with a round trip per read. A file the kernel has cached
+// barely cares, a source where every read waits cares a great deal.
+func BenchmarkImport(b *testing.B) {
+	file := gzipped(usersCSV(20_000, 0))
+	path := filepath.Join(b.TempDir(), "users.csv.gz")
+	if err := os.WriteFile(path, file, 0o644); err != nil {
+		b.Fatal(err)
+	}
+	for _, src := range []struct {
+		name string
+		open func() (io.ReadCloser, error)
+	}{
+		{"file", func() (io.ReadCloser, error) { return os.Open(path) }},
+		{"50µs per read", func() (io.ReadCloser, error) {
+			return io.NopCloser(slow{r: bytes.NewReader(file), delay: 50 * time.Microsecond}), nil
+		}},
+	} {
+		for _, size := range []int{512, 4 << 10, 64 << 10, 1 << 20} {
+			b.Run(fmt.Sprintf("%s/%d", src.name, size), func(b *testing.B) {
+				b.ReportAllocs()
+				b.SetBytes(int64(len(file)))
+				n := 0
+				for b.Loop() {
+					r, err := src.open()
+					if err != nil {
+						b.Fatal(err)
+					}
+					counted := &reads{r: r}
+					if _, err := ingest.Import(context.Background(), counted, parseOnly{}, ingest.Options{BufferSize: size}); err != nil {
+						b.Fatal(err)
+					}
+					r.Close()
+					n += counted.n
+				}
+				b.ReportMetric(float64(n)/float64(b.N), "reads/op")
+			})
+		}
+	}
+}
@@ -0,0 +1,312 @@
+// Package jobqueue runs typed background jobs on a fixed set of worker
+// goroutines. A failed job is retried with backoff according to its policy,
+// a job that runs out of attempts, or fails with a permanent error, goes to
+// the dead-letter channel instead of being lost.
+package jobqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// ErrClosed is returned by Enqueue once Shutdown has been called.
+var ErrClosed = errors.New("jobqueue: closed")
+
+// Policy controls how a failed job is retried.
+type Policy struct {
+	// MaxAttempts is the total number of runs, including the first one.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry, doubled on each attempt.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff before jitter is applied.
+	MaxDelay time.Duration
+	// Retryable reports whether err is worth another attempt,
+	// nil uses Transient.
+	Retryable func(err error) bool
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts: 5,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+		Retryable:   Transient,
+	}
+}
+
+// Transient treats everything except the errs taxonomy and context errors as
+// temporary, a job rejected as invalid will be rejected again.
+func Transient(err error) bool {
+	switch {
+	case errors.Is(err, errs.ErrNotFound),
+		errors.Is(err, errs.ErrConflict),
+		errors.Is(err, errs.ErrInvalidInput),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return false
+	}
+	return true
+}
+
+// Job is a unit of work and, on the dead-letter channel, its outcome.
+type Job[T any] struct {
+	// ID is assigned by Enqueue, starting at 1.
+	ID       int64
+	Payload  T
+	Enqueued time.Time
+	// Attempts is how many times the handler ran.
+	Attempts int
+	// Err is the last failure.
+	Err error
+
+	policy Policy
+}
+
+// Handler processes one job's payload.
+type Handler[T any] func(ctx context.Context, payload T) error
+
+// Option configures a Queue.
+type Option func(*options)
+
+type options struct {
+	buffer     int
+	deadLetter int
+	policy     Policy
+}
+
+// WithBuffer sets how many jobs can wait for a worker before Enqueue
+// blocks, the default is 64.
+func WithBuffer(n int) Option {
+	return func(o *options) {
+		o.buffer = n
+	}
+}
+
+// WithDeadLetterBuffer sets the dead-letter channel capacity, the default
+// is 16. Workers block on a full dead-letter channel.
+func WithDeadLetterBuffer(n int) Option {
+	return func(o *options) {
+		o.deadLetter = n
+	}
+}
+
+// WithPolicy sets the retry policy for jobs enqueued without their own,
+// the default is DefaultPolicy.
+func WithPolicy(p Policy) Option {
+	return func(o *options) {
+		o.policy = p
+	}
+}
+
+// Queue is safe for concurrent use. The dead-letter channel must be read,
+// once it fills the workers block.
+type Queue[T any] struct {
+	handler    Handler[T]
+	jobs       chan *Job[T]
+	deadLetter chan Job[T]
+	policy     Policy
+
+	// ctx is canceled when a Shutdown deadline passes, aborting handlers
+	// and pending retries
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// mu orders Enqueue before Shutdown, so every job counted in pending
+	// was accepted before the queue closed
+	mu      sync.Mutex
+	closed  bool
+	nextID  int64
+	pending sync.WaitGroup
+	workers sync.WaitGroup
+	// done is closed once every worker has exited
+	done chan struct{}
+}
+
+// New starts workers goroutines calling handler.
+func New[T any](workers int, handler Handler[T], opts ...Option) *Queue[T] {
+	o := options{buffer: 64, deadLetter: 16, policy: DefaultPolicy()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue[T]{
+		handler:    handler,
+		jobs:       make(chan *Job[T], max(o.buffer, 1)),
+		deadLetter: make(chan Job[T], max(o.deadLetter, 0)),
+		policy:     normalize(o.policy),
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+	for range max(workers, 1) {
+		q.workers.Add(1)
+		go func() {
+			defer q.workers.Done()
+			for job := range q.jobs {
+				q.run(job)
+			}
+		}()
+	}
+	return q
+}
+
+// EnqueueOption overrides the queue's defaults for one job.
+type EnqueueOption func(*enqueueOptions)
+
+type enqueueOptions struct {
+	policy *Policy
+}
+
+// WithRetry gives the job its own retry policy, e.g. a single attempt for
+// work that is not safe to repeat.
+func WithRetry(p Policy) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.policy = &p
+	}
+}
+
+// Enqueue queues payload and returns its job ID. It blocks while the queue
+// is full, failing with the context error if ctx ends first, and fails with
+// ErrClosed after Shutdown.
+func (q *Queue[T]) Enqueue(ctx context.Context, payload T, opts ...EnqueueOption) (int64, error) {
+	var o enqueueOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	job := &Job[T]{Payload: payload, Enqueued: time.Now(), policy: q.policy}
+	if o.policy != nil {
+		job.policy = normalize(*o.policy)
+	}
+
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return 0, ErrClosed
+	}
+	q.nextID++
+	job.ID = q.nextID
+	q.pending.Add(1)
+	q.mu.Unlock()
+
+	select {
+	case q.jobs <- job:
+		return job.ID, nil
+	case <-ctx.Done():
+		q.pending.Done()
+		return 0, ctx.Err()
+	case <-q.ctx.Done():
+		q.pending.Done()
+		return 0, ErrClosed
+	}
+}
+
+// DeadLetters delivers the jobs that failed for good, with their last error.
+// It is closed once Shutdown has finished.
+func (q *Queue[T]) DeadLetters() <-chan Job[T] {
+	return q.deadLetter
+}
+
+// Shutdown stops accepting jobs and waits for the queued ones, including
+// their retries, to finish. If ctx ends first the remaining handlers are
+// canceled, unfinished jobs are dead-lettered with the cancellation error,
+// and Shutdown returns ctx.Err() once the workers have exited. It is safe to
+// call more than once.
+func (q *Queue[T]) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		go func() {
+			// retries send on jobs, so it stays open until nothing is pending
+			q.pending.Wait()
+			close(q.jobs)
+			q.workers.Wait()
+			close(q.deadLetter)
+			q.cancel()
+			close(q.done)
+		}()
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		<-q.done
+		return ctx.Err()
+	}
+}
+
+func (q *Queue[T]) run(job *Job[T]) {
+	if err := q.ctx.Err(); err != nil {
+		q.fail(job, err)
+		return
+	}
+	job.Attempts++
+	err := q.call(job)
+	if err == nil {
+		q.pending.Done()
+		return
+	}
+	job.Err = err
+	if job.Attempts >= job.policy.MaxAttempts || !job.policy.Retryable(err) {
+		q.fail(job, err)
+		return
+	}
+	// wait out the backoff off the worker, so one failing job does not
+	// stall the rest of the queue
+	go func() {
+		timer := time.NewTimer(job.policy.backoff(job.Attempts))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			q.jobs <- job
+		case <-q.ctx.Done():
+			q.fail(job, errors.Join(err, q.ctx.Err()))
+		}
+	}()
+}
+
+// call runs the handler, recovering a panic into the job's error.
+func (q *Queue[T]) call(job *Job[T]) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("jobqueue: job %d panicked: %v", job.ID, r)
+		}
+	}()
+	return q.handler(q.ctx, job.Payload)
+}
+
+func (q *Queue[T]) fail(job *Job[T], err error) {
+	job.Err = err
+	q.deadLetter <- *job
+	q.pending.Done()
+}
+
+func normalize(p Policy) Policy {
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = 1
+	}
+	if p.Retryable == nil {
+		p.Retryable = Transient
+	}
+	return p
+}
+
+// backoff returns a random duration in [0, min(MaxDelay, BaseDelay*2^(attempt-1))).
+func (p Policy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay << (attempt - 1)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)))
+}
@@ -0,0 +1,101 @@
+package jobqueue_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/jobqueue"
+)
+
+// TestMain fails the package if a queue's workers outlive its Shutdown.
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+var fast = jobqueue.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Retryable: jobqueue.Transient}
+
+// TestRetriesThenDeadLetters checks transient failures are retried up to
+// MaxAttempts, permanent ones are not, and both end on the dead-letter
+// channel with their last error.
+func TestRetriesThenDeadLetters(t *testing.T) {
+	var calls [3]atomic.Int32
+	q := jobqueue.New(2, func(ctx context.Context, n int) error {
+		calls[n].Add(1)
+		switch n {
+		case 1:
+			return errors.New("transient")
+		case 2:
+			return errs.ErrInvalidInput
+		}
+		return nil
+	}, jobqueue.WithPolicy(fast))
+	for n := range 3 {
+		if _, err := q.Enqueue(context.Background(), n); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+	dead := make(chan []jobqueue.Job[int])
+	go func() {
+		var jobs []jobqueue.Job[int]
+		for j := range q.DeadLetters() {
+			jobs = append(jobs, j)
+		}
+		dead <- jobs
+	}()
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	for n, want := range []int32{1, 3, 1} {
+		if got := calls[n].Load(); got != want {
+			t.Errorf("job %d ran %d times, want %d", n, got, want)
+		}
+	}
+	jobs := <-dead
+	if len(jobs) != 2 {
+		t.Fatalf("%d dead letters, want 2", len(jobs))
+	}
+	for _, j := range jobs {
+		if j.Err == nil || j.Attempts != int(calls[j.Payload].Load()) {
+			t.Errorf("dead letter %+v, want its last error and every attempt", j)
+		}
+	}
+	if _, err := q.Enqueue(context.Background(), 0); !errors.Is(err, jobqueue.ErrClosed) {
+		t.Errorf("Enqueue after Shutdown: err = %v, want ErrClosed", err)
+	}
+}
+
+// TestShutdownDeadline checks a Shutdown that runs out of time cancels the
+// handlers still running and dead-letters what was left.
+func TestShutdownDeadline(t *testing.T) {
+	q := jobqueue.New(1, func(ctx context.Context, n int) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, jobqueue.WithPolicy(fast))
+	for n := range 3 {
+		if _, err := q.Enqueue(context.Background(), n); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+	dead := make(chan int)
+	go func() {
+		n := 0
+		for range q.DeadLetters() {
+			n++
+		}
+		dead <- n
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := q.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown: err = %v, want context.DeadlineExceeded", err)
+	}
+	if n := <-dead; n != 3 {
+		t.Errorf("%d dead letters, want all 3", n)
+	}
+}
@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+)
+
+// ContextHandler is slog.Handler middleware: it copies request-scoped values
+// out of the record's context (request ID, trace ID, authenticated user)
+// and adds them as attributes before passing the record on. Code only has
+// to log with the *Context methods, e.g. logger.InfoContext(ctx, ...), and
+// never to attach the IDs itself.
+//
+// The attributes land in whatever group is open, so a logger built with
+// WithGroup("store") reports store.request_id.
+type ContextHandler struct {
+	next slog.Handler
+}
+
+func NewContextHandler(next slog.Handler) *ContextHandler {
+	return &ContextHandler{next: next}
+}
+
+func (h *ContextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id, ok := ctxutil.RequestID(ctx); ok {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	// an OpenTelemetry span is the better source, it also knows the span ID
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	} else if id, ok := ctxutil.TraceID(ctx); ok {
+		r.AddAttrs(slog.String("trace_id", id))
+	}
+	if p, ok := ctxutil.PrincipalFrom(ctx); ok {
+		r.AddAttrs(slog.String("principal", p.Subject))
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{next: h.next.WithAttrs(attrs)}
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{next: h.next.WithGroup(name)}
+}
@@ -0,0 +1,58 @@
+// Package logging is the one logger setup every entry point shares, so a
+// log line from the HTTP middleware, the service, and a store decorator all
+// look alike and carry the same request-scoped attributes.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Config picks the handler. The zero value is Info level JSON.
+type Config struct {
+	Level slog.Level `json:"level" yaml:"level"`
+	// Format is "json" or "text".
+	Format    string `json:"format" yaml:"format"`
+	AddSource bool   `json:"add_source" yaml:"add_source"`
+}
+
+// FromEnv reads LOG_LEVEL (debug, info, warn, error) and LOG_FORMAT (json,
+// text), defaulting to info and json, the shape log collectors want.
+// An unknown value falls back to the default rather than failing startup.
+func FromEnv() Config {
+	cfg := Config{Level: slog.LevelInfo, Format: "json"}
+	if lvl, err := ParseLevel(os.Getenv("LOG_LEVEL")); err == nil {
+		cfg.Level = lvl
+	}
+	if format := strings.ToLower(os.Getenv("LOG_FORMAT")); format == "text" || format == "json" {
+		cfg.Format = format
+	}
+	return cfg
+}
+
+// ParseLevel accepts the names slog prints, case insensitively, plus offsets
+// such as "debug-2". The empty string is an error so callers keep a default.
+func ParseLevel(s string) (slog.Level, error) {
+	var lvl slog.Level
+	if s == "" {
+		return lvl, fmt.Errorf("logging: empty level")
+	}
+	err := lvl.UnmarshalText([]byte(s))
+	return lvl, err
+}
+
+// New builds a logger writing to w, wrapped in a ContextHandler so
+// request-scoped attributes are added to every record.
+func New(w io.Writer, cfg Config) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: cfg.Level, AddSource: cfg.AddSource}
+	var h slog.Handler
+	if cfg.Format == "text" {
+		h = slog.NewTextHandler(w, opts)
+	} else {
+		h = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(NewContextHandler(h))
+}
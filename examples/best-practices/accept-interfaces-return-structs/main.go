@@ -2,24 +2,210 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/logged"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/idgen"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/logging"
 	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/validate"
 
 	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
 )
 
+// Tours the users service built the accept interfaces, return structs way:
+// a store injected behind an interface and wrapped in a decorator, hooks,
+// both validators, renames, unique emails, batches, concurrent fetches,
+// paging, generated IDs, a fake clock, optimistic concurrency, soft
+// deletes, and the kinds of error callers branch on.
+//
+//	go run .
 func main() {
 	ctx := context.Background()
-	// store injected into user service
-	store := db.NewDB()
+	// the context handler adds request_id to every line logged with ctx
+	logger := logging.New(os.Stderr, logging.Config{Level: slog.LevelDebug, Format: "text"})
+	ctx = ctxutil.WithRequestID(ctx, "req-1")
+	// store injected into user service, wrapped in a logging decorator
+	store := logged.New(db.NewMemoryStore(), logger)
 	// user service struct, can now use it's exposed methods
-	useService := service.NewUserService(store)
+	useService := service.NewUserService(store, service.WithLogger(logger))
+	// hooks run in registration order around every mutation
+	useService.OnUserCreated(func(ctx context.Context, u *service.User) error {
+		fmt.Printf("hook: user %s created\n", u.ID)
+		return nil
+	})
 
-	user := &service.User{}
+	user := &service.User{ID: "1", Email: "gopher@example.com"}
 	if err := useService.CreateUser(ctx, user); err != nil {
 		fmt.Println(fmt.Errorf("error: %s", err))
 	}
 
 	fmt.Println(fmt.Printf("User created: %+v", user))
+
+	// typed return, no type assertion needed to read fields
+	found, err := useService.RetrieveUser(ctx, user.ID)
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	fmt.Printf("User retrieved: %s\n", found.ID)
+
+	// callers branch on the kind of failure, not on the error string
+	_, err = useService.RetrieveUser(ctx, "missing")
+	describe(err)
+	describe(useService.CreateUser(ctx, user))
+	describe(useService.CreateUser(ctx, &service.User{}))
+
+	// every field problem is reported at once, by either validator
+	bad := &service.User{ID: "5", Email: "not an email"}
+	var vErr *validate.ValidationError
+	if errors.As(bad.Validate(), &vErr) {
+		fmt.Println("hand-rolled:", vErr.Fields)
+	}
+	if errors.As(validate.Struct(bad), &vErr) {
+		fmt.Println("reflection: ", vErr.Fields)
+	}
+
+	// the rename is atomic, renaming onto a taken ID rolls the insert back
+	describe(useService.CreateUser(ctx, &service.User{ID: "2", Email: "other@example.com"}))
+	describe(useService.RenameUser(ctx, "1", "2"))
+	_, err = useService.RetrieveUser(ctx, "1")
+	describe(err)
+
+	// email is a unique secondary key
+	byEmail, err := useService.RetrieveUserByEmail(ctx, "other@example.com")
+	describe(err)
+	if err == nil {
+		fmt.Printf("User by email: %s\n", byEmail.ID)
+	}
+	describe(useService.CreateUser(ctx, &service.User{ID: "4", Email: "other@example.com"}))
+
+	// concurrent creates racing for the same email, the store lets one win
+	var wg sync.WaitGroup
+	var created atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			user := &service.User{ID: fmt.Sprintf("race-%d", i), Email: "race@example.com"}
+			if useService.CreateUser(ctx, user) == nil {
+				created.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	fmt.Printf("concurrent creates for one email: %d succeeded\n", created.Load())
+
+	// batches report per-item failures instead of failing as a whole
+	result, err := useService.CreateUsers(ctx, []*service.User{
+		{ID: "3", Email: "three@example.com"},
+		{ID: "2", Email: "dupe@example.com"},
+		{Email: "no-id@example.com"},
+	})
+	describe(err)
+	fmt.Printf("batch: %d created, %d failed\n", len(result.Created), len(result.Failed))
+	for _, itemErr := range result.Failed {
+		describe(itemErr)
+	}
+
+	// fetched concurrently, returned in the order asked for
+	many, err := useService.RetrieveUsers(ctx, []string{"3", "2"})
+	describe(err)
+	for _, u := range many {
+		fmt.Printf("fetched: %s\n", u.ID)
+	}
+	// one missing ID fails the lot and cancels the other fetches
+	_, err = useService.RetrieveUsers(ctx, []string{"3", "missing", "2"})
+	describe(err)
+
+	// walk every page, the cursor from one page requests the next
+	req := service.PageRequest{Limit: 2}
+	for {
+		page, err := useService.ListUsers(ctx, req)
+		if err != nil {
+			describe(err)
+			break
+		}
+		for _, u := range page.Items {
+			fmt.Printf("listed: %s\n", u.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		req.Cursor = page.NextCursor
+	}
+
+	// with a generator injected, users created without an ID get one
+	withIDs := service.NewUserService(db.NewMemoryStore(), service.WithIDGenerator(idgen.UUIDv7{}))
+	anon := &service.User{Email: "anon@example.com"}
+	describe(withIDs.CreateUser(ctx, anon))
+	fmt.Printf("generated ID: %s\n", anon.ID)
+
+	// a fake clock makes timestamps deterministic, time only moves on Advance
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	timed := service.NewUserService(db.NewMemoryStore(), service.WithClock(fake))
+	stamped := &service.User{ID: "t1", Email: "time@example.com"}
+	describe(timed.CreateUser(ctx, stamped))
+	fake.Advance(time.Hour)
+	describe(timed.UpdateUser(ctx, &service.User{ID: "t1", Email: "later@example.com", Version: stamped.Version}))
+	if stamped, err = timed.RetrieveUser(ctx, "t1"); err == nil {
+		fmt.Printf("created %s, updated %s\n", stamped.CreatedAt.Format(time.RFC3339), stamped.UpdatedAt.Format(time.RFC3339))
+	}
+
+	// optimistic concurrency: two writers read version 1, only the first wins
+	first, _ := useService.RetrieveUser(ctx, "3")
+	second, _ := useService.RetrieveUser(ctx, "3")
+	first.Email = "first@example.com"
+	second.Email = "second@example.com"
+	describe(useService.UpdateUser(ctx, first))
+	describe(useService.UpdateUser(ctx, second))
+	fmt.Printf("version after update: %d\n", first.Version)
+
+	// soft delete hides the user from reads until it is restored
+	describe(useService.DeleteUser(ctx, "3"))
+	_, err = useService.RetrieveUser(ctx, "3")
+	describe(err)
+	_, err = useService.RetrieveUser(ctx, "3", service.IncludeDeleted())
+	describe(err)
+	describe(useService.RestoreUser(ctx, "3"))
+	_, err = useService.RetrieveUser(ctx, "3")
+	describe(err)
+
+	// a canceled context aborts the call before the store does any work
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = useService.RetrieveUser(canceled, user.ID)
+	describe(err)
+}
+
+func describe(err error) {
+	switch {
+	case err == nil:
+		fmt.Println("ok")
+	case errors.Is(err, errs.ErrNotFound):
+		fmt.Println("not found, respond with 404:", err)
+	case errors.Is(err, errs.ErrConflict):
+		fmt.Println("conflict, respond with 409:", err)
+	case errors.Is(err, errs.ErrInvalidInput):
+		fmt.Println("invalid input, respond with 400:", err)
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		fmt.Println("request abandoned:", err)
+	default:
+		fmt.Println("unexpected error:", err)
+	}
+
+	// errors.As reaches the outermost operation that failed
+	var opErr *errs.OpError
+	if errors.As(err, &opErr) {
+		fmt.Println("  failed operation:", opErr.Op)
+	}
 }
@@ -0,0 +1,279 @@
+// Package mapper copies the fields two struct types have in common, by
+// name, so a DTO and the domain struct it carries need no hand-written
+// list of assignments that has to be kept up as fields are added.
+//
+// A field of the destination is set from the source field of the same
+// name, or of the name in its `mapper:"Name"` tag. `mapper:"-"` on either
+// side leaves a field out, both ways, so one tag on a DTO covers its
+// mapping to and from the domain struct. The two must be the same type, or basic types of the same kind,
+// like a named string type and string. A destination field with no source
+// field is left as it was, the caller sets it. Unexported and embedded
+// fields are not looked at.
+//
+// There are two ways to copy, the same rules in each:
+//
+//   - Copy takes any two structs and works out its plan with package
+//     reflect on the first call for a pair of types. Every call after still
+//     goes through reflect.Value, which is what it costs over an
+//     assignment, see BenchmarkMap.
+//   - Compile[S, D] works out the plan once, for types known when the code
+//     is written, and returns a Mapper whose copies are typed loads and
+//     stores at each field's offset. No reflection is left in a call
+//     except for fields of a kind it has no typed copy for.
+//
+// Neither is as cheap as writing the assignments out, package mapping does
+// that and its tests check it against drift instead.
+package mapper
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// field is one step of a plan: set the destination field at dst from the
+// source field at src, converting when their types differ.
+type field struct {
+	dst, src       int
+	dstOff, srcOff uintptr
+	typ, srcTyp    reflect.Type
+	convert        bool
+}
+
+type plan struct {
+	fields []field
+	err    error
+}
+
+type pair struct{ dst, src reflect.Type }
+
+// plans caches the plan for each pair of types Copy has seen, an error
+// included, the types will not change before the next call.
+var plans sync.Map
+
+func planFor(dst, src reflect.Type) *plan {
+	if p, ok := plans.Load(pair{dst, src}); ok {
+		return p.(*plan)
+	}
+	fields, err := build(dst, src)
+	p, _ := plans.LoadOrStore(pair{dst, src}, &plan{fields: fields, err: err})
+	return p.(*plan)
+}
+
+func build(dst, src reflect.Type) ([]field, error) {
+	var fields []field
+	for i := range dst.NumField() {
+		df := dst.Field(i)
+		if !df.IsExported() || df.Anonymous {
+			continue
+		}
+		name, tagged := df.Tag.Lookup("mapper")
+		if name == "-" {
+			continue
+		}
+		if !tagged {
+			name = df.Name
+		}
+		sf, ok := src.FieldByName(name)
+		if !ok || !sf.IsExported() || sf.Anonymous || len(sf.Index) != 1 {
+			if tagged {
+				return nil, fmt.Errorf("mapper: %s.%s is tagged for %s.%s, which has no such field: %w", dst, df.Name, src, name, errs.ErrInvalidInput)
+			}
+			continue
+		}
+		if sf.Tag.Get("mapper") == "-" {
+			continue
+		}
+		f := field{dst: i, src: sf.Index[0], dstOff: df.Offset, srcOff: sf.Offset, typ: df.Type, srcTyp: sf.Type}
+		if sf.Type != df.Type {
+			if !basic(df.Type.Kind()) || sf.Type.Kind() != df.Type.Kind() {
+				return nil, fmt.Errorf("mapper: %s.%s is %s and %s.%s is %s: %w", src, sf.Name, sf.Type, dst, df.Name, df.Type, errs.ErrInvalidInput)
+			}
+			f.convert = true
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// basic kinds convert to one another of the same kind without changing
+// their bits, all a named type over one adds is methods.
+func basic(k reflect.Kind) bool {
+	switch k {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	}
+	return false
+}
+
+// Copy sets the fields of dst, a pointer to a struct, from those of src, a
+// struct or a pointer to one. A pair of types the rules cannot copy
+// between is an error on every call, before any field is set.
+func Copy(dst, src any) error {
+	dv := reflect.ValueOf(dst)
+	if dv.Kind() != reflect.Pointer || dv.IsNil() || dv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("mapper: destination %T is not a pointer to a struct: %w", dst, errs.ErrInvalidInput)
+	}
+	dv = dv.Elem()
+	sv := reflect.ValueOf(src)
+	if sv.Kind() == reflect.Pointer && !sv.IsNil() {
+		sv = sv.Elem()
+	}
+	if sv.Kind() != reflect.Struct {
+		return fmt.Errorf("mapper: source %T is not a struct or a pointer to one: %w", src, errs.ErrInvalidInput)
+	}
+	p := planFor(dv.Type(), sv.Type())
+	if p.err != nil {
+		return p.err
+	}
+	for _, f := range p.fields {
+		v := sv.Field(f.src)
+		if f.convert {
+			v = v.Convert(f.typ)
+		}
+		dv.Field(f.dst).Set(v)
+	}
+	return nil
+}
+
+// Mapper copies from S to D, both struct types, see Compile.
+type Mapper[S, D any] struct {
+	ops []op
+	// reflects is whether any op is opReflect
+	reflects bool
+}
+
+// op is a field of the plan with the typed copy for it picked out. The
+// plan has checked that both fields are of typ, or of basic types of one
+// kind and so the same bits, which is what makes it sound to load the
+// source as the destination's type.
+type op struct {
+	kind           opKind
+	dstOff, srcOff uintptr
+	typ, srcTyp    reflect.Type
+}
+
+type opKind int
+
+const (
+	// opReflect copies kinds with no typed copy here through reflect.NewAt,
+	// still without the per-call checks Copy makes.
+	opReflect opKind = iota
+	opString
+	opBool
+	opInt
+	opInt32
+	opInt64
+	opUint64
+	opFloat64
+	opTime
+)
+
+var kinds = map[reflect.Kind]opKind{
+	reflect.String:  opString,
+	reflect.Bool:    opBool,
+	reflect.Int:     opInt,
+	reflect.Int32:   opInt32,
+	reflect.Int64:   opInt64,
+	reflect.Uint64:  opUint64,
+	reflect.Float64: opFloat64,
+}
+
+// Compile works out how to copy from S to D, an error if the rules cannot
+// copy between them or either is not a struct.
+func Compile[S, D any]() (*Mapper[S, D], error) {
+	src, dst := reflect.TypeFor[S](), reflect.TypeFor[D]()
+	if src.Kind() != reflect.Struct || dst.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("mapper: Compile[%s, %s] needs two struct types: %w", src, dst, errs.ErrInvalidInput)
+	}
+	p := planFor(dst, src)
+	if p.err != nil {
+		return nil, p.err
+	}
+	m := &Mapper[S, D]{}
+	for _, f := range p.fields {
+		o := op{kind: kinds[f.typ.Kind()], dstOff: f.dstOff, srcOff: f.srcOff, typ: f.typ, srcTyp: f.srcTyp}
+		if f.typ == reflect.TypeFor[time.Time]() {
+			o.kind = opTime
+		}
+		m.ops = append(m.ops, o)
+		m.reflects = m.reflects || o.kind == opReflect
+	}
+	return m, nil
+}
+
+// MustCompile is Compile for a package level var, panicking on an error.
+// The types are fixed in the source, so a field that stops converting
+// fails as the package loads, not in the middle of a request.
+func MustCompile[S, D any]() *Mapper[S, D] {
+	m, err := Compile[S, D]()
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Map returns a D with the fields src has in common with it.
+func (m *Mapper[S, D]) Map(src *S) D {
+	if m.reflects {
+		// package reflect keeps what it is given as far as the compiler can
+		// tell, so a D that goes through it is on the heap, and only then
+		dst := new(D)
+		m.Copy(dst, src)
+		return *dst
+	}
+	var dst D
+	copyTyped(m.ops, unsafe.Pointer(&dst), unsafe.Pointer(src))
+	return dst
+}
+
+// Copy sets the fields of dst from src, leaving the others as they are.
+func (m *Mapper[S, D]) Copy(dst *D, src *S) {
+	d, s := unsafe.Pointer(dst), unsafe.Pointer(src)
+	copyTyped(m.ops, d, s)
+	if !m.reflects {
+		return
+	}
+	for i := range m.ops {
+		if o := &m.ops[i]; o.kind == opReflect {
+			v := reflect.NewAt(o.srcTyp, unsafe.Add(s, o.srcOff)).Elem()
+			if o.srcTyp != o.typ {
+				v = v.Convert(o.typ)
+			}
+			reflect.NewAt(o.typ, unsafe.Add(d, o.dstOff)).Elem().Set(v)
+		}
+	}
+}
+
+// copyTyped runs every op but the opReflect ones.
+func copyTyped(ops []op, d, s unsafe.Pointer) {
+	for i := range ops {
+		o := &ops[i]
+		dp, sp := unsafe.Add(d, o.dstOff), unsafe.Add(s, o.srcOff)
+		switch o.kind {
+		case opString:
+			*(*string)(dp) = *(*string)(sp)
+		case opBool:
+			*(*bool)(dp) = *(*bool)(sp)
+		case opInt:
+			*(*int)(dp) = *(*int)(sp)
+		case opInt32:
+			*(*int32)(dp) = *(*int32)(sp)
+		case opInt64:
+			*(*int64)(dp) = *(*int64)(sp)
+		case opUint64:
+			*(*uint64)(dp) = *(*uint64)(sp)
+		case opFloat64:
+			*(*float64)(dp) = *(*float64)(sp)
+		case opTime:
+			*(*time.Time)(dp) = *(*time.Time)(sp)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package mapper_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/mapper"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// userDTO is the shape of the transport's response bodies, with Status as
+// a plain string to exercise a conversion.
+type userDTO struct {
+	ID        string
+	Email     string
+	Name      string
+	Status    string
+	Version   int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time `mapper:"-"`
+}
+
+func byHand(user *service.User) userDTO {
+	return userDTO{
+		ID:        user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		Status:    string(user.Status),
+		Version:   user.Version,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+}
+
+func ada() *service.User {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	return &service.User{
+		ID:        "ada",
+		Email:     "ada@example.com",
+		Name:      "Ada Lovelace",
+		Status:    service.StatusActive,
+		Version:   3,
+		CreatedAt: now,
+		UpdatedAt: now.Add(time.Hour),
+	}
+}
+
+// compile compiles a Mapper from S to D.
+func compile[S, D any](tb testing.TB) *mapper.Mapper[S, D] {
+	tb.Helper()
+	m, err := mapper.Compile[S, D]()
+	if err != nil {
+		tb.Fatalf("Compile: %v", err)
+	}
+	return m
+}
+
+// TestMatchesByHand checks Copy and Map copy what a hand-written mapping
+// does.
+func TestMatchesByHand(t *testing.T) {
+	user := ada()
+	want := byHand(user)
+	var copied userDTO
+	if err := mapper.Copy(&copied, user); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if !reflect.DeepEqual(copied, want) {
+		t.Errorf("Copy = %+v, want %+v", copied, want)
+	}
+	if mapped := compile[service.User, userDTO](t).Map(user); !reflect.DeepEqual(mapped, want) {
+		t.Errorf("Map = %+v, want %+v", mapped, want)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	user := ada()
+	dto := compile[service.User, userDTO](t).Map(user)
+	if got := compile[userDTO, service.User](t).Map(&dto); !reflect.DeepEqual(&got, user) {
+		t.Errorf("round trip = %+v, want %+v", got, *user)
+	}
+}
+
+// TestReflectFields checks fields without a typed copy go through reflect.
+func TestReflectFields(t *testing.T) {
+	type level int16
+	type from struct {
+		Tags  []string
+		Level level
+	}
+	type to struct {
+		Tags  []string
+		Level int16
+	}
+	src := from{Tags: []string{"admin"}, Level: 3}
+	want := to{Tags: []string{"admin"}, Level: 3}
+	var viaCopy to
+	if err := mapper.Copy(&viaCopy, src); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if !reflect.DeepEqual(viaCopy, want) {
+		t.Errorf("Copy = %+v, want %+v", viaCopy, want)
+	}
+	if got := compile[from, to](t).Map(&src); !reflect.DeepEqual(got, want) {
+		t.Errorf("Map = %+v, want %+v", got, want)
+	}
+}
+
+// TestTags checks tags rename and drop fields and unmatched ones are left
+// alone.
+func TestTags(t *testing.T) {
+	type row struct {
+		Key     string `mapper:"ID"`
+		Email   string `mapper:"-"`
+		Comment string
+	}
+	user := ada()
+	want := row{Key: "ada", Email: "kept", Comment: "kept"}
+	dst := row{Email: "kept", Comment: "kept"}
+	if err := mapper.Copy(&dst, user); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if dst != want {
+		t.Errorf("Copy = %+v, want %+v", dst, want)
+	}
+	dst = row{Email: "kept", Comment: "kept"}
+	compile[service.User, row](t).Copy(&dst, user)
+	if dst != want {
+		t.Errorf("Mapper.Copy = %+v, want %+v", dst, want)
+	}
+}
+
+// TestRefused checks fields that do not convert are an error, not skipped,
+// and so is a destination Copy cannot set.
+func TestRefused(t *testing.T) {
+	type drifted struct {
+		Version string
+	}
+	type mistagged struct {
+		Handle string `mapper:"Nickname"`
+	}
+	user := ada()
+	var dst userDTO
+	for _, tt := range []struct {
+		name string
+		err  error
+	}{
+		{"Copy int64 to string", mapper.Copy(&drifted{}, user)},
+		{"Copy into a struct value", mapper.Copy(dst, user)},
+		{"Copy into a nil pointer", mapper.Copy((*userDTO)(nil), user)},
+		{"Copy into a non-struct", mapper.Copy(new(int), user)},
+		{"Copy from a nil pointer", mapper.Copy(&dst, (*service.User)(nil))},
+		{"Compile int64 to string", compileErr[service.User, drifted]()},
+		{"Compile with a tag for no field", compileErr[service.User, mistagged]()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if !errors.Is(tt.err, errs.ErrInvalidInput) {
+				t.Errorf("err = %v, want ErrInvalidInput", tt.err)
+			}
+		})
+	}
+}
+
+func compileErr[S, D any]() error {
+	_, err := mapper.Compile[S, D]()
+	return err
+}
+
+var sink userDTO
+
+// BenchmarkMap copies a service.User to a DTO each way, the cost of
+// reflection being the gap between mapper.Copy and the rest.
+func BenchmarkMap(b *testing.B) {
+	user := ada()
+	compiled := compile[service.User, userDTO](b)
+	for _, bench := range []struct {
+		name string
+		fn   func()
+	}{
+		{"byhand", func() { sink = byHand(user) }},
+		{"Mapper.Map", func() { sink = compiled.Map(user) }},
+		{"Mapper.Copy", func() { compiled.Copy(&sink, user) }},
+		{"mapper.Copy", func() { mapper.Copy(&sink, user) }},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				bench.fn()
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// Package mapping converts users between the shapes they take at the
+// edges: the domain service.User, the userspb.User protobuf message the
+// gRPC transport speaks, and the serialization.User JSON DTO.
+//
+// Every conversion goes through the domain type, proto to DTO included, so
+// each edge shape has one mapping in each direction to keep up to date
+// when a field is added. The package tests check by reflection that none has
+// been missed.
+package mapping
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/serialization"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/grpc/userspb"
+)
+
+// UserToProto leaves DeleteTime unset for a live user, proto3 cannot tell
+// a zero Timestamp message from a meaningful one otherwise.
+func UserToProto(user *service.User) *userspb.User {
+	return &userspb.User{
+		Id:         user.ID,
+		Email:      user.Email,
+		Name:       user.Name,
+		Version:    user.Version,
+		CreateTime: timestamp(user.CreatedAt),
+		UpdateTime: timestamp(user.UpdatedAt),
+		DeleteTime: timestamp(user.DeletedAt),
+	}
+}
+
+func UserFromProto(pb *userspb.User) *service.User {
+	return &service.User{
+		ID:        pb.GetId(),
+		Email:     pb.GetEmail(),
+		Name:      pb.GetName(),
+		Version:   pb.GetVersion(),
+		CreatedAt: timeOf(pb.GetCreateTime()),
+		UpdatedAt: timeOf(pb.GetUpdateTime()),
+		DeletedAt: timeOf(pb.GetDeleteTime()),
+	}
+}
+
+// CreateRequestToUser is the user a CreateUserRequest asks for, the
+// service fills in everything else.
+func CreateRequestToUser(req *userspb.CreateUserRequest) *service.User {
+	return &service.User{ID: req.GetId(), Email: req.GetEmail(), Name: req.GetName()}
+}
+
+// UpdateRequestToUser is the update an UpdateUserRequest asks for, at the
+// version the client last read.
+func UpdateRequestToUser(req *userspb.UpdateUserRequest) *service.User {
+	return &service.User{ID: req.GetId(), Email: req.GetEmail(), Name: req.GetName(), Version: req.GetVersion()}
+}
+
+// UserToDTO truncates times to the millisecond, what the DTO's JSON keeps.
+func UserToDTO(user *service.User) serialization.User {
+	return serialization.FromUser(user)
+}
+
+// UserFromDTO drops the DTO's Password, which is not part of a user and is
+// set through service.UserService.SetPassword.
+func UserFromDTO(dto serialization.User) *service.User {
+	return dto.ToUser()
+}
+
+func ProtoToDTO(pb *userspb.User) serialization.User {
+	return UserToDTO(UserFromProto(pb))
+}
+
+func DTOToProto(dto serialization.User) *userspb.User {
+	return UserToProto(UserFromDTO(dto))
+}
+
+// timestamp maps the zero time to no message, and back in timeOf: an unset
+// Timestamp is the Unix epoch to AsTime, not time.Time's zero.
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func timeOf(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
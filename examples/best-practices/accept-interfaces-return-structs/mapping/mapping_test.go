@@ -0,0 +1,214 @@
+package mapping_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/mapping"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/serialization"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/grpc/userspb"
+)
+
+// field is one service.User field and its name in the other shapes.
+type field struct {
+	domain, proto, dto string
+}
+
+// fields is how the three shapes line up. Adding a field to any of them
+// fails TestFieldsInTable until it is added here, and adding it here
+// fails the round trips until the mappers carry it.
+var fields = []field{
+	{"ID", "id", "id"},
+	{"Email", "email", "email"},
+	{"Name", "name", "name"},
+	{"CreatedAt", "create_time", "created_at"},
+	{"UpdatedAt", "update_time", "updated_at"},
+	{"Version", "version", "version"},
+	{"DeletedAt", "delete_time", "deleted_at"},
+}
+
+// dtoOnly are DTO keys with no domain field: status is derived from
+// DeletedAt, password is write-only.
+var dtoOnly = []string{"status", "password"}
+
+// domainOnly are service.User fields no edge shape carries: the lifecycle
+// Status only moves through the service's lifecycle methods, and the DTO's
+// status key is the unrelated active or deleted above.
+var domainOnly = []string{"Status"}
+
+// TestFieldsInTable reads the field lists of service.User, userspb.User,
+// and serialization.User by reflection and holds them against the table.
+func TestFieldsInTable(t *testing.T) {
+	t.Run("service.User", func(t *testing.T) {
+		typ := reflect.TypeFor[service.User]()
+		var names []string
+		for i := range typ.NumField() {
+			names = append(names, typ.Field(i).Name)
+		}
+		sameSet(t, "service.User", names, append(column(func(f field) string { return f.domain }), domainOnly...))
+	})
+	t.Run("userspb.User", func(t *testing.T) {
+		desc := (&userspb.User{}).ProtoReflect().Descriptor().Fields()
+		var names []string
+		for i := range desc.Len() {
+			names = append(names, string(desc.Get(i).Name()))
+		}
+		sameSet(t, "userspb.User", names, column(func(f field) string { return f.proto }))
+	})
+	t.Run("serialization.User", func(t *testing.T) {
+		typ := reflect.TypeFor[serialization.User]()
+		var names []string
+		for i := range typ.NumField() {
+			name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			names = append(names, name)
+		}
+		sameSet(t, "serialization.User", names, append(column(func(f field) string { return f.dto }), dtoOnly...))
+	})
+}
+
+// TestRoundTrips maps a user with every field set through each shape, it
+// must come back whole. This catches a mapper that forgot a field the
+// table knows about.
+func TestRoundTrips(t *testing.T) {
+	for _, deleted := range []bool{false, true} {
+		user := populated(deleted)
+		name := map[bool]string{false: "live", true: "deleted"}[deleted]
+		t.Run(name+"/proto", func(t *testing.T) {
+			pb := mapping.UserToProto(user)
+			allSet(t, pb, deleted)
+			// through the wire encoding too, not just the Go struct
+			b, err := proto.Marshal(pb)
+			if err != nil {
+				t.Fatalf("proto.Marshal: %v", err)
+			}
+			var decoded userspb.User
+			if err := proto.Unmarshal(b, &decoded); err != nil {
+				t.Fatalf("proto.Unmarshal: %v", err)
+			}
+			same(t, mapping.UserFromProto(&decoded), user)
+		})
+		t.Run(name+"/dto", func(t *testing.T) {
+			b, err := json.Marshal(mapping.UserToDTO(user))
+			if err != nil {
+				t.Fatalf("json.Marshal: %v", err)
+			}
+			var dto serialization.User
+			if err := json.Unmarshal(b, &dto); err != nil {
+				t.Fatalf("json.Unmarshal: %v", err)
+			}
+			same(t, mapping.UserFromDTO(dto), user)
+		})
+		t.Run(name+"/proto to dto to proto", func(t *testing.T) {
+			pb := mapping.UserToProto(user)
+			same(t, mapping.UserFromProto(mapping.DTOToProto(mapping.ProtoToDTO(pb))), user)
+		})
+	}
+}
+
+// TestRequests checks create and update requests carry every field they
+// have.
+func TestRequests(t *testing.T) {
+	create := &userspb.CreateUserRequest{Id: "ada", Email: "ada@example.com", Name: "Ada"}
+	allSet(t, create, false)
+	if got := mapping.CreateRequestToUser(create); *got != (service.User{ID: "ada", Email: "ada@example.com", Name: "Ada"}) {
+		t.Errorf("CreateRequestToUser = %+v", *got)
+	}
+	update := &userspb.UpdateUserRequest{Id: "ada", Email: "ada@example.com", Name: "Ada", Version: 3}
+	allSet(t, update, false)
+	if got := mapping.UpdateRequestToUser(update); *got != (service.User{ID: "ada", Email: "ada@example.com", Name: "Ada", Version: 3}) {
+		t.Errorf("UpdateRequestToUser = %+v", *got)
+	}
+}
+
+func column(get func(field) string) []string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = get(f)
+	}
+	return names
+}
+
+// sameSet reports the names the type has and the table lacks, and the other
+// way around.
+func sameSet(t *testing.T, typ string, have, want []string) {
+	t.Helper()
+	for _, name := range have {
+		if !slices.Contains(want, name) {
+			t.Errorf("%s.%s is not in the table", typ, name)
+		}
+	}
+	for _, name := range want {
+		if !slices.Contains(have, name) {
+			t.Errorf("the table has %s, %s does not", name, typ)
+		}
+	}
+}
+
+// populated is a user with a distinct non-zero value in every field, set by
+// reflection so a new field cannot be left at zero by oversight. DeletedAt
+// is only set for a deleted user and the domainOnly fields never, nothing
+// would carry them. Times are whole milliseconds, the coarsest the shapes
+// keep.
+func populated(deleted bool) *service.User {
+	user := &service.User{}
+	v := reflect.ValueOf(user).Elem()
+	base := time.Date(2026, 1, 2, 3, 4, 5, 6e6, time.UTC)
+	for i := range v.NumField() {
+		f, name := v.Field(i), v.Type().Field(i).Name
+		switch {
+		case slices.Contains(domainOnly, name):
+		case name == "Email":
+			f.SetString("someone@example.com")
+		case f.Type() == reflect.TypeFor[time.Time]():
+			if name != "DeletedAt" || deleted {
+				f.Set(reflect.ValueOf(base.Add(time.Duration(i) * time.Hour)))
+			}
+		case f.Kind() == reflect.String:
+			f.SetString(strings.ToLower(name) + "-value")
+		case f.Kind() == reflect.Int64:
+			f.SetInt(int64(i + 1))
+		default:
+			panic(fmt.Sprintf("populated: no value for %s %s, add one", name, f.Type()))
+		}
+	}
+	return user
+}
+
+// allSet wants every field of m set, delete_time only when deleted.
+func allSet(t *testing.T, m proto.Message, deleted bool) {
+	t.Helper()
+	r := m.ProtoReflect()
+	desc := r.Descriptor().Fields()
+	var unset []string
+	for i := range desc.Len() {
+		fd := desc.Get(i)
+		if fd.Name() == protoreflect.Name("delete_time") && !deleted {
+			if r.Has(fd) {
+				t.Error("delete_time is set on a live user")
+			}
+			continue
+		}
+		if !r.Has(fd) {
+			unset = append(unset, string(fd.Name()))
+		}
+	}
+	if len(unset) > 0 {
+		t.Errorf("%s leaves %s unset", r.Descriptor().Name(), strings.Join(unset, ", "))
+	}
+}
+
+func same(t *testing.T, got, want *service.User) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", *got, *want)
+	}
+}
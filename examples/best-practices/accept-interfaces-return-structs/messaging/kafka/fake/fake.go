@@ -0,0 +1,247 @@
+// Package fake is an in-memory Kafka broker for running producers and
+// consumer groups without a cluster. Broker is a kafka.Writer and hands out
+// kafka.Readers, so code written against those interfaces runs on it
+// unchanged:
+//
+//	broker := fake.New(4)
+//	producer := kafka.NewProducer(broker, "users")
+//	consumer := kafka.NewConsumer(broker.NewReader("directory", "users"), model.Apply, logger)
+//
+// It keeps the semantics consumers have to be written for: a topic is a
+// fixed number of partitions, a key always lands on the same one and is
+// ordered within it, a group's members share the partitions between them,
+// and a member joining or leaving rebalances the group, rewinding every
+// partition to its last committed offset. What was fetched and not
+// committed is delivered again, to whichever member now holds it.
+//
+// There is no retention, replication, or network, and a commit from a
+// member that lost the partition in a rebalance fails with ErrRebalanced
+// where a real broker would fail it with a generation error.
+package fake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/messaging/kafka"
+)
+
+var (
+	// ErrClosed is returned by a Reader's methods after Close.
+	ErrClosed = errors.New("fake: reader closed")
+	// ErrRebalanced is returned committing a partition the reader no
+	// longer holds.
+	ErrRebalanced = errors.New("fake: partition reassigned")
+)
+
+type partitionKey struct {
+	topic     string
+	partition int
+}
+
+// group is one consumer group. position is the next offset to hand out,
+// committed the next offset the group would resume from.
+type group struct {
+	members   []*Reader
+	position  map[partitionKey]int64
+	committed map[partitionKey]int64
+}
+
+// Broker holds every topic and consumer group.
+type Broker struct {
+	mu         sync.Mutex
+	partitions int
+	logs       map[partitionKey][]kafka.Message
+	groups     map[string]*group
+	// changed is closed and replaced whenever a fetch might now succeed,
+	// on a write or a rebalance
+	changed chan struct{}
+}
+
+// New is a broker whose topics each have partitions partitions, created
+// on first use.
+func New(partitions int) *Broker {
+	return &Broker{
+		partitions: max(partitions, 1),
+		logs:       make(map[partitionKey][]kafka.Message),
+		groups:     make(map[string]*group),
+		changed:    make(chan struct{}),
+	}
+}
+
+// WriteMessages appends each message to the partition its key hashes to.
+func (b *Broker) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, msg := range msgs {
+		if msg.Topic == "" {
+			return errors.New("fake: message has no topic")
+		}
+		h := fnv.New32a()
+		h.Write(msg.Key)
+		pk := partitionKey{msg.Topic, int(h.Sum32() % uint32(b.partitions))}
+		msg.Partition = pk.partition
+		msg.Offset = int64(len(b.logs[pk]))
+		msg.Time = time.Now()
+		msg.Key = slices.Clone(msg.Key)
+		msg.Value = slices.Clone(msg.Value)
+		b.logs[pk] = append(b.logs[pk], msg)
+	}
+	b.notifyLocked()
+	return nil
+}
+
+func (b *Broker) notifyLocked() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// NewReader joins consumer group groupID, reading topic. The group
+// rebalances to take it in.
+func (b *Broker) NewReader(groupID, topic string) *Reader {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	g, ok := b.groups[groupID]
+	if !ok {
+		g = &group{position: make(map[partitionKey]int64), committed: make(map[partitionKey]int64)}
+		b.groups[groupID] = g
+	}
+	r := &Reader{broker: b, group: g, topic: topic}
+	g.members = append(g.members, r)
+	b.rebalanceLocked(g)
+	return r
+}
+
+// rebalanceLocked rewinds the group to its commits, members then pick up
+// their share of the partitions from there.
+func (b *Broker) rebalanceLocked(g *group) {
+	for pk := range g.position {
+		g.position[pk] = g.committed[pk]
+	}
+	b.notifyLocked()
+}
+
+// Committed is the offset groupID resumes topic's partition from.
+func (b *Broker) Committed(groupID, topic string, partition int) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if g, ok := b.groups[groupID]; ok {
+		return g.committed[partitionKey{topic, partition}]
+	}
+	return 0
+}
+
+// Lag is how many of topic's messages groupID has not committed, across
+// every partition.
+func (b *Broker) Lag(groupID, topic string) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	g := b.groups[groupID]
+	var lag int64
+	for p := range b.partitions {
+		pk := partitionKey{topic, p}
+		lag += int64(len(b.logs[pk]))
+		if g != nil {
+			lag -= g.committed[pk]
+		}
+	}
+	return lag
+}
+
+// Reader is one member of a consumer group.
+type Reader struct {
+	broker *Broker
+	group  *group
+	topic  string
+	closed bool
+	// next is the partition FetchMessage looks at first, rotated so one
+	// busy partition cannot starve the others
+	next int
+}
+
+// owns reports whether partition is r's in the group's current
+// assignment: partition p goes to member p modulo the member count.
+func (r *Reader) owns(partition int) bool {
+	members := r.group.members
+	return len(members) > 0 && members[partition%len(members)] == r
+}
+
+func (r *Reader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	b := r.broker
+	for {
+		b.mu.Lock()
+		if r.closed {
+			b.mu.Unlock()
+			return kafka.Message{}, ErrClosed
+		}
+		for i := range b.partitions {
+			p := (r.next + i) % b.partitions
+			if !r.owns(p) {
+				continue
+			}
+			pk := partitionKey{r.topic, p}
+			pos := r.group.position[pk]
+			if pos < int64(len(b.logs[pk])) {
+				r.group.position[pk] = pos + 1
+				r.next = p + 1
+				msg := b.logs[pk][pos]
+				b.mu.Unlock()
+				return msg, nil
+			}
+		}
+		changed := b.changed
+		b.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// CommitMessages moves the group's committed offset past each message,
+// never back.
+func (r *Reader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b := r.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if r.closed {
+		return ErrClosed
+	}
+	for _, msg := range msgs {
+		if !r.owns(msg.Partition) {
+			return fmt.Errorf("%w: %s/%d", ErrRebalanced, msg.Topic, msg.Partition)
+		}
+	}
+	for _, msg := range msgs {
+		pk := partitionKey{msg.Topic, msg.Partition}
+		r.group.committed[pk] = max(r.group.committed[pk], msg.Offset+1)
+	}
+	return nil
+}
+
+// Close leaves the group, which rebalances its partitions onto the other
+// members, from their last commits.
+func (r *Reader) Close() error {
+	b := r.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	r.group.members = slices.DeleteFunc(r.group.members, func(m *Reader) bool { return m == r })
+	b.rebalanceLocked(r.group)
+	return nil
+}
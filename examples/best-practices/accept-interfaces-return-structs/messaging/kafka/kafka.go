@@ -0,0 +1,184 @@
+// Package kafka carries user events between processes over Kafka: a
+// Producer the outbox relay publishes to, and a Consumer that feeds a
+// consumer group's messages to a handler, such as a ReadModel.
+//
+// Both sides speak to the broker through the small Writer and Reader
+// interfaces below, not a client library. NewWriter and NewReader adapt
+// segmentio/kafka-go to them for a real cluster, package fake is an in
+// memory broker with the same delivery semantics for running without one.
+//
+// Delivery is at least once end to end. The relay marks a message sent only
+// after Publish returns, and the Consumer commits an offset only after the
+// handler returns, so a crash at any point redelivers rather than loses.
+// Handlers must therefore be idempotent, ReadModel is by version.
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/events"
+)
+
+// eventHeader names the event a message holds, events.Decode needs it.
+const eventHeader = "event"
+
+// Header is a message header.
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Message is one record on a topic. Partition and Offset are set by the
+// broker, a Writer ignores them.
+type Message struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   []Header
+	Time      time.Time
+}
+
+func (m Message) header(key string) string {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Writer produces messages. WriteMessages returns once the broker has
+// acknowledged every message, or with an error when any was not, in which
+// case some may have been written anyway.
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...Message) error
+}
+
+// Reader is one member of a consumer group. FetchMessage blocks for the
+// next message of the partitions assigned to it, CommitMessages records
+// that they were handled. Messages fetched and not committed are fetched
+// again by whichever member holds their partition after a restart or a
+// rebalance.
+type Reader interface {
+	FetchMessage(ctx context.Context) (Message, error)
+	CommitMessages(ctx context.Context, msgs ...Message) error
+	Close() error
+}
+
+// Producer is an events.Publisher writing each event to one topic, keyed
+// by user ID. Kafka orders messages within a partition only, and a key
+// always hashes to the same partition, so the events of one user are
+// consumed in the order they were published.
+type Producer struct {
+	writer Writer
+	topic  string
+}
+
+func NewProducer(writer Writer, topic string) *Producer {
+	return &Producer{writer: writer, topic: topic}
+}
+
+func (p *Producer) Publish(ctx context.Context, event events.Event) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("kafka: encode %s: %w", event.Name(), err)
+	}
+	err = p.writer.WriteMessages(ctx, Message{
+		Topic:   p.topic,
+		Key:     []byte(userID(event)),
+		Value:   value,
+		Headers: []Header{{Key: eventHeader, Value: []byte(event.Name())}},
+	})
+	if err != nil {
+		return fmt.Errorf("kafka: publish %s: %w", event.Name(), err)
+	}
+	return nil
+}
+
+func userID(event events.Event) string {
+	switch e := event.(type) {
+	case events.UserCreated:
+		return e.UserID
+	case events.UserUpdated:
+		return e.UserID
+	case events.UserDeleted:
+		return e.UserID
+	}
+	return ""
+}
+
+// Consumer hands each message its Reader fetches to a handler, and commits
+// it once the handler returns nil.
+type Consumer struct {
+	reader Reader
+	handle events.Handler
+	logger *slog.Logger
+}
+
+func NewConsumer(reader Reader, handle events.Handler, logger *slog.Logger) *Consumer {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Consumer{reader: reader, handle: handle, logger: logger}
+}
+
+// Run consumes until ctx is done, it then returns ctx.Err(). A message that
+// cannot be decoded is logged and committed, redelivering it would fail
+// the same way forever. A handler error stops Run with the message
+// uncommitted, the consumer restarted is handed it again. A failed commit
+// is logged and consuming carries on: the message stays uncommitted, and
+// is handled again by whoever reads the partition after a restart or
+// rebalance, which the handler's idempotence makes harmless.
+func (c *Consumer) Run(ctx context.Context) error {
+	for {
+		msg, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("kafka: fetch: %w", err)
+		}
+		if err := c.process(ctx, msg); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if err := c.reader.CommitMessages(ctx, msg); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// most often a rebalance moved the partition, its new owner
+			// resumes from the last commit and handles msg again
+			c.logger.WarnContext(ctx, "kafka: commit failed",
+				slog.String("topic", msg.Topic),
+				slog.Int("partition", msg.Partition),
+				slog.Int64("offset", msg.Offset),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+}
+
+func (c *Consumer) process(ctx context.Context, msg Message) error {
+	event, err := events.Decode(msg.header(eventHeader), msg.Value)
+	if err != nil {
+		c.logger.ErrorContext(ctx, "kafka: skipping message",
+			slog.String("topic", msg.Topic),
+			slog.Int("partition", msg.Partition),
+			slog.Int64("offset", msg.Offset),
+			slog.String("error", err.Error()),
+		)
+		return nil
+	}
+	if err := c.handle(ctx, event); err != nil {
+		return fmt.Errorf("kafka: handle %s/%d@%d: %w", msg.Topic, msg.Partition, msg.Offset, err)
+	}
+	return nil
+}
@@ -0,0 +1,332 @@
+package kafka_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/events"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/messaging/kafka"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/messaging/kafka/fake"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/outbox"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// The tests run on the in-memory fake broker unless $KAFKA_BROKERS names a
+// cluster, such as the single broker in compose.yaml:
+//
+//	docker compose -f messaging/kafka/compose.yaml up -d
+//	KAFKA_BROKERS=localhost:9092 go test ./messaging/kafka
+var brokers = os.Getenv("KAFKA_BROKERS")
+
+// the failures below are on purpose, keep their logs out of the output
+var quiet = slog.New(slog.DiscardHandler)
+
+// broker is one test's topic, on the fake or on a real cluster, with one
+// consumer group reading it.
+type broker struct {
+	t         *testing.T
+	writer    kafka.Writer
+	newReader func(group, topic string) kafka.Reader
+	// fake is nil on a real cluster
+	fake  *fake.Broker
+	topic string
+	group string
+}
+
+func newBroker(t *testing.T) *broker {
+	t.Helper()
+	// a real cluster keeps topics between runs, every test has its own
+	b := &broker{t: t, topic: fmt.Sprintf("users-%d", time.Now().UnixNano()), group: "directory"}
+	if brokers == "" {
+		f := fake.New(4)
+		b.writer, b.fake = f, f
+		b.newReader = func(group, topic string) kafka.Reader { return f.NewReader(group, topic) }
+		return b
+	}
+	addrs := strings.Split(brokers, ",")
+	w := kafka.NewWriter(addrs...)
+	t.Cleanup(func() { w.Close() })
+	b.writer = w
+	b.newReader = func(group, topic string) kafka.Reader { return kafka.NewReader(group, topic, addrs...) }
+	return b
+}
+
+// needFake skips a test that drives the fake in ways a cluster does not
+// offer.
+func (b *broker) needFake() {
+	b.t.Helper()
+	if b.fake == nil {
+		b.t.Skip("needs the fake broker")
+	}
+}
+
+// pipeline returns a users service on a fresh store whose outbox relays
+// to the topic through w.
+func (b *broker) pipeline(w kafka.Writer) (*service.UserService, *outbox.Relay) {
+	store := db.NewMemoryStore()
+	return service.NewUserService(store, service.WithOutbox()), outbox.NewRelay(store, kafka.NewProducer(w, b.topic), quiet)
+}
+
+// relayAll flushes the outbox until it is empty.
+func (b *broker) relayAll(relay *outbox.Relay) {
+	b.t.Helper()
+	for {
+		n, err := relay.Flush(context.Background())
+		if err != nil {
+			b.t.Fatalf("Flush: %v", err)
+		}
+		if n == 0 {
+			return
+		}
+	}
+}
+
+func (b *broker) createUsers(users *service.UserService, prefix string, n int) {
+	b.t.Helper()
+	for i := range n {
+		id := fmt.Sprintf("%s-%04d", prefix, i)
+		if err := users.CreateUser(context.Background(), &service.User{ID: id, Email: id + "@example.com"}); err != nil {
+			b.t.Fatalf("CreateUser: %v", err)
+		}
+	}
+}
+
+// consume runs a member of the group handing messages to handle until the
+// returned stop is called, or the test ends. stop returns what Run
+// returned.
+func (b *broker) consume(handle events.Handler) (stop func() error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	reader := b.newReader(b.group, b.topic)
+	done := make(chan error, 1)
+	go func() { done <- kafka.NewConsumer(reader, handle, quiet).Run(ctx) }()
+	stop = sync.OnceValue(func() error {
+		cancel()
+		err := <-done
+		reader.Close()
+		return err
+	})
+	b.t.Cleanup(func() { stop() })
+	return stop
+}
+
+// eventually fails the test unless cond holds within the time a real
+// cluster needs to rebalance.
+func (b *broker) eventually(what string, cond func() bool) {
+	b.t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			b.t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// committed waits for the group to have committed every message, which
+// only the fake can tell.
+func (b *broker) committed() {
+	b.t.Helper()
+	if b.fake != nil {
+		b.eventually("every offset committed", func() bool { return b.fake.Lag(b.group, b.topic) == 0 })
+	}
+}
+
+// withPrefix counts the model's users whose IDs start with prefix.
+func withPrefix(model *kafka.ReadModel, prefix string) int {
+	n := 0
+	for _, e := range model.Entries() {
+		if strings.HasPrefix(e.UserID, prefix+"-") {
+			n++
+		}
+	}
+	return n
+}
+
+// counting wraps handle, counting the events that reach it.
+func counting(handle events.Handler, n *atomic.Int64) events.Handler {
+	return func(ctx context.Context, event events.Event) error {
+		n.Add(1)
+		return handle(ctx, event)
+	}
+}
+
+// flakyWriter fails its failAt'th write.
+type flakyWriter struct {
+	kafka.Writer
+	writes atomic.Int64
+	failAt int64
+}
+
+func (w *flakyWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if w.writes.Add(1) == w.failAt {
+		return errors.New("broker unreachable")
+	}
+	return w.Writer.WriteMessages(ctx, msgs...)
+}
+
+func TestEveryUserReachesReadModel(t *testing.T) {
+	b := newBroker(t)
+	users, relay := b.pipeline(b.writer)
+	b.createUsers(users, "created", 200)
+	b.relayAll(relay)
+	model := kafka.NewReadModel()
+	// two members, the partitions are split between them
+	b.consume(model.Apply)
+	b.consume(model.Apply)
+	b.eventually("200 users", func() bool { return withPrefix(model, "created") == 200 })
+	b.committed()
+}
+
+// TestOrderPerUser checks one user's updates arrive in the order they were
+// made: an event overtaken by a later one would be dropped as stale.
+func TestOrderPerUser(t *testing.T) {
+	b := newBroker(t)
+	ctx := context.Background()
+	users, relay := b.pipeline(b.writer)
+	user := &service.User{ID: "ordered", Email: "ordered@example.com"}
+	if err := users.CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	for i := range 20 {
+		user.Email = fmt.Sprintf("ordered-%d@example.com", i)
+		if err := users.UpdateUser(ctx, user); err != nil {
+			t.Fatalf("UpdateUser: %v", err)
+		}
+	}
+	b.relayAll(relay)
+	model := kafka.NewReadModel()
+	b.consume(model.Apply)
+	b.eventually("the last update", func() bool { e, _ := model.Get("ordered"); return e.Version == user.Version })
+	if n := model.Applied(); n != 21 {
+		t.Errorf("Applied = %d, want 21", n)
+	}
+}
+
+// TestPublishRetried checks a publish that fails stays in the outbox for
+// the next flush rather than being lost.
+func TestPublishRetried(t *testing.T) {
+	b := newBroker(t)
+	users, relay := b.pipeline(&flakyWriter{Writer: b.writer, failAt: 3})
+	b.createUsers(users, "flaky", 10)
+	if n, err := relay.Flush(context.Background()); err == nil || n != 2 {
+		t.Fatalf("first Flush = %d, %v, want 2 and the failure", n, err)
+	}
+	b.relayAll(relay)
+	model := kafka.NewReadModel()
+	b.consume(model.Apply)
+	b.eventually("10 users", func() bool { return withPrefix(model, "flaky") == 10 })
+}
+
+// TestRedeliveredAfterCrash kills a consumer after it handles a message
+// and before it commits it: the next member is handed it again, and the
+// read model drops the copy.
+func TestRedeliveredAfterCrash(t *testing.T) {
+	b := newBroker(t)
+	users, relay := b.pipeline(b.writer)
+	b.createUsers(users, "crash", 20)
+	b.relayAll(relay)
+	model := kafka.NewReadModel()
+	var deliveries atomic.Int64
+	dying := make(chan struct{})
+	var once sync.Once
+	stop := b.consume(func(ctx context.Context, event events.Event) error {
+		if err := counting(model.Apply, &deliveries)(ctx, event); err != nil {
+			return err
+		}
+		if deliveries.Load() == 5 {
+			once.Do(func() { close(dying) })
+			<-ctx.Done()
+		}
+		return nil
+	})
+	<-dying
+	if err := stop(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("the dying consumer: err = %v, want context.Canceled", err)
+	}
+	b.consume(counting(model.Apply, &deliveries))
+	b.eventually("20 users", func() bool { return withPrefix(model, "crash") == 20 })
+	if n := deliveries.Load(); n < 21 {
+		t.Errorf("%d deliveries of 20 events, want the fifth redelivered", n)
+	}
+	if n := model.Applied(); n != 20 {
+		t.Errorf("Applied = %d, want 20 with the redelivery dropped", n)
+	}
+}
+
+// TestMemberJoins starts a second member part way: it takes over
+// partitions and nothing is lost or applied twice.
+func TestMemberJoins(t *testing.T) {
+	b := newBroker(t)
+	b.needFake()
+	users, relay := b.pipeline(b.writer)
+	model := kafka.NewReadModel()
+	var byA, byB atomic.Int64
+	b.consume(counting(model.Apply, &byA))
+	b.createUsers(users, "join-a", 100)
+	b.relayAll(relay)
+	b.eventually("some of the first 100", func() bool { return byA.Load() > 0 })
+	b.consume(counting(model.Apply, &byB))
+	b.createUsers(users, "join-b", 100)
+	b.relayAll(relay)
+	b.eventually("all 200", func() bool { return withPrefix(model, "join-a")+withPrefix(model, "join-b") == 200 })
+	if byB.Load() == 0 {
+		t.Error("the new member was given nothing")
+	}
+	if n := model.Applied(); n != 200 {
+		t.Errorf("Applied = %d, want 200", n)
+	}
+}
+
+// TestDeletedStaysDeleted publishes a user's creation again after its
+// deletion, as a producer retrying after a lost ack would.
+func TestDeletedStaysDeleted(t *testing.T) {
+	b := newBroker(t)
+	ctx := context.Background()
+	users, relay := b.pipeline(b.writer)
+	user := &service.User{ID: "deleted", Email: "deleted@example.com"}
+	if err := users.CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	created := events.UserCreated{UserID: user.ID, Email: user.Email, Version: user.Version, At: user.CreatedAt}
+	if err := users.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	b.relayAll(relay)
+	if err := kafka.NewProducer(b.writer, b.topic).Publish(ctx, created); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	// a marker to know when the consumer is past the copy
+	b.createUsers(users, "marker", 1)
+	b.relayAll(relay)
+	model := kafka.NewReadModel()
+	b.consume(model.Apply)
+	b.eventually("the marker", func() bool { return withPrefix(model, "marker") == 1 })
+	if _, ok := model.Get(user.ID); ok {
+		t.Error("the deleted user is back")
+	}
+}
+
+// TestUndecodableSkipped puts garbage on the topic: it is committed and
+// skipped, not retried forever.
+func TestUndecodableSkipped(t *testing.T) {
+	b := newBroker(t)
+	users, relay := b.pipeline(b.writer)
+	if err := b.writer.WriteMessages(context.Background(), kafka.Message{Topic: b.topic, Key: []byte("x"), Value: []byte("{not json")}); err != nil {
+		t.Fatalf("WriteMessages: %v", err)
+	}
+	b.createUsers(users, "marker", 1)
+	b.relayAll(relay)
+	model := kafka.NewReadModel()
+	b.consume(model.Apply)
+	b.eventually("the marker", func() bool { return withPrefix(model, "marker") == 1 })
+	b.committed()
+}
@@ -0,0 +1,84 @@
+package kafka
+
+import (
+	"context"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// ClusterWriter is a Writer on a real cluster, through segmentio/kafka-go.
+type ClusterWriter struct {
+	w *kafkago.Writer
+}
+
+// NewWriter connects to the cluster at brokers. Messages are spread across
+// partitions by hash of the key and each write waits for every in sync
+// replica, an acknowledged message survives losing the leader. Topics are
+// created on first write, which suits a compose file's single broker.
+func NewWriter(brokers ...string) *ClusterWriter {
+	return &ClusterWriter{&kafkago.Writer{
+		Addr:                   kafkago.TCP(brokers...),
+		Balancer:               &kafkago.Hash{},
+		RequiredAcks:           kafkago.RequireAll,
+		AllowAutoTopicCreation: true,
+	}}
+}
+
+func (k *ClusterWriter) WriteMessages(ctx context.Context, msgs ...Message) error {
+	out := make([]kafkago.Message, len(msgs))
+	for i, m := range msgs {
+		out[i] = kafkago.Message{Topic: m.Topic, Key: m.Key, Value: m.Value, Time: m.Time}
+		for _, h := range m.Headers {
+			out[i].Headers = append(out[i].Headers, kafkago.Header{Key: h.Key, Value: h.Value})
+		}
+	}
+	return k.w.WriteMessages(ctx, out...)
+}
+
+// Close flushes pending writes and closes the connections.
+func (k *ClusterWriter) Close() error {
+	return k.w.Close()
+}
+
+// ClusterReader is a Reader on a real cluster, through segmentio/kafka-go.
+type ClusterReader struct {
+	r *kafkago.Reader
+}
+
+// NewReader joins consumer group group, reading topic on the cluster at
+// brokers. Commits are synchronous: CommitMessages returns once the broker
+// has the offset.
+func NewReader(group, topic string, brokers ...string) *ClusterReader {
+	return &ClusterReader{kafkago.NewReader(kafkago.ReaderConfig{
+		Brokers: brokers,
+		GroupID: group,
+		Topic:   topic,
+	})}
+}
+
+func (k *ClusterReader) FetchMessage(ctx context.Context) (Message, error) {
+	m, err := k.r.FetchMessage(ctx)
+	if err != nil {
+		return Message{}, err
+	}
+	msg := Message{Topic: m.Topic, Partition: m.Partition, Offset: m.Offset, Key: m.Key, Value: m.Value, Time: m.Time}
+	for _, h := range m.Headers {
+		msg.Headers = append(msg.Headers, Header{Key: h.Key, Value: h.Value})
+	}
+	return msg, nil
+}
+
+// CommitMessages passes on only what kafka-go keys a commit by, the topic,
+// partition, and offset.
+func (k *ClusterReader) CommitMessages(ctx context.Context, msgs ...Message) error {
+	out := make([]kafkago.Message, len(msgs))
+	for i, m := range msgs {
+		out[i] = kafkago.Message{Topic: m.Topic, Partition: m.Partition, Offset: m.Offset}
+	}
+	return k.r.CommitMessages(ctx, out...)
+}
+
+// Close leaves the group, its partitions move to the other members.
+func (k *ClusterReader) Close() error {
+	return k.r.Close()
+}
@@ -0,0 +1,104 @@
+package kafka
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/events"
+)
+
+// DirectoryEntry is a user as the read model knows them.
+type DirectoryEntry struct {
+	UserID    string
+	Email     string
+	Version   int64
+	UpdatedAt time.Time
+}
+
+// ReadModel is a user directory kept up to date from user events, the
+// query side of the users service in another process. Apply is its
+// events.Handler.
+//
+// Applying an event twice changes nothing, which is what at least once
+// delivery asks of a consumer. An event carrying a version no newer than
+// the entry's is a duplicate and dropped. A deletion leaves a tombstone,
+// so a redelivered creation arriving after it does not bring the user
+// back.
+type ReadModel struct {
+	mu      sync.RWMutex
+	entries map[string]DirectoryEntry
+	// deleted holds the version each deleted user had, events at or below
+	// it are stale
+	deleted map[string]int64
+	applied int
+}
+
+func NewReadModel() *ReadModel {
+	return &ReadModel{
+		entries: make(map[string]DirectoryEntry),
+		deleted: make(map[string]int64),
+	}
+}
+
+// Apply updates the directory from event. Events it has no use for are
+// ignored, not an error, a consumer would otherwise stop on them.
+func (m *ReadModel) Apply(_ context.Context, event events.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch e := event.(type) {
+	case events.UserCreated:
+		m.upsert(DirectoryEntry{UserID: e.UserID, Email: e.Email, Version: e.Version, UpdatedAt: e.At})
+	case events.UserUpdated:
+		m.upsert(DirectoryEntry{UserID: e.UserID, Email: e.Email, Version: e.Version, UpdatedAt: e.At})
+	case events.UserDeleted:
+		if entry, ok := m.entries[e.UserID]; ok {
+			m.deleted[e.UserID] = entry.Version
+			delete(m.entries, e.UserID)
+			m.applied++
+		}
+	}
+	return nil
+}
+
+func (m *ReadModel) upsert(entry DirectoryEntry) {
+	if v, ok := m.deleted[entry.UserID]; ok && entry.Version <= v {
+		return
+	}
+	if cur, ok := m.entries[entry.UserID]; ok && entry.Version <= cur.Version {
+		return
+	}
+	delete(m.deleted, entry.UserID)
+	m.entries[entry.UserID] = entry
+	m.applied++
+}
+
+// Get returns the entry for a live user.
+func (m *ReadModel) Get(id string) (DirectoryEntry, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entry, ok := m.entries[id]
+	return entry, ok
+}
+
+// Entries returns every live user in ID order.
+func (m *ReadModel) Entries() []DirectoryEntry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entries := make([]DirectoryEntry, 0, len(m.entries))
+	for _, entry := range m.entries {
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b DirectoryEntry) int { return strings.Compare(a.UserID, b.UserID) })
+	return entries
+}
+
+// Applied counts the events that changed the directory, duplicates and
+// stale events excluded.
+func (m *ReadModel) Applied() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.applied
+}
@@ -0,0 +1,153 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/mapping"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/serialization"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// ServiceError is an error reply from the users service. It unwraps to the
+// errs sentinel its code stands for, so callers match on errs.ErrNotFound
+// and the rest exactly as they would calling the service in process.
+type ServiceError struct {
+	Subject     string
+	Code        int
+	Description string
+}
+
+func (e *ServiceError) Error() string {
+	return fmt.Sprintf("%s: %d %s", e.Subject, e.Code, e.Description)
+}
+
+func (e *ServiceError) Unwrap() error {
+	switch e.Code {
+	case http.StatusNotFound:
+		return errs.ErrNotFound
+	case codeVersionConflict:
+		return errs.ErrVersionConflict
+	case http.StatusConflict:
+		return errs.ErrConflict
+	case http.StatusBadRequest:
+		return errs.ErrInvalidInput
+	case http.StatusUnauthorized:
+		return errs.ErrUnauthenticated
+	case http.StatusForbidden:
+		return errs.ErrForbidden
+	case http.StatusServiceUnavailable:
+		return errs.ErrUnavailable
+	case http.StatusNotImplemented:
+		return errors.ErrUnsupported
+	case http.StatusGatewayTimeout:
+		return context.DeadlineExceeded
+	case codeClientClosed:
+		return context.Canceled
+	}
+	return nil
+}
+
+// Client calls the users service over a connection. Its methods mirror
+// *service.UserService, so it can stand in for the service in another
+// process.
+type Client struct {
+	conn    *natsgo.Conn
+	timeout time.Duration
+}
+
+// NewClient calls over conn. A call without a deadline on its context gives
+// up after timeout, request/reply has no other way to learn the service is
+// gone once the request was sent.
+func NewClient(conn *natsgo.Conn, timeout time.Duration) *Client {
+	return &Client{conn: conn, timeout: timeout}
+}
+
+// request sends body to subject and decodes the reply into out. No
+// instance subscribed is errs.ErrUnavailable, with no wait for the timeout.
+func (c *Client) request(ctx context.Context, op, subject string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return errs.Wrap(op, err)
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	msg, err := c.conn.RequestWithContext(ctx, subject, data)
+	if errors.Is(err, natsgo.ErrNoResponders) {
+		return errs.Wrap(op, fmt.Errorf("%s: %w", subject, errs.ErrUnavailable))
+	}
+	if err != nil {
+		return errs.Wrap(op, err)
+	}
+	if code := msg.Header.Get(micro.ErrorCodeHeader); code != "" {
+		n, _ := strconv.Atoi(code)
+		return errs.Wrap(op, &ServiceError{Subject: subject, Code: n, Description: msg.Header.Get(micro.ErrorHeader)})
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(msg.Data, out); err != nil {
+		return errs.Wrap(op, fmt.Errorf("decode reply: %w", err))
+	}
+	return nil
+}
+
+// CreateUser creates user and fills in what the service set, such as
+// CreatedAt and Version.
+func (c *Client) CreateUser(ctx context.Context, user *service.User) error {
+	var reply serialization.User
+	err := c.request(ctx, "nats.Client.CreateUser", SubjectCreate, createRequest{ID: user.ID, Email: user.Email, Name: user.Name}, &reply)
+	if err != nil {
+		return err
+	}
+	*user = *mapping.UserFromDTO(reply)
+	return nil
+}
+
+func (c *Client) RetrieveUser(ctx context.Context, id string) (*service.User, error) {
+	var reply serialization.User
+	if err := c.request(ctx, "nats.Client.RetrieveUser", SubjectGet, idRequest{ID: id}, &reply); err != nil {
+		return nil, err
+	}
+	return mapping.UserFromDTO(reply), nil
+}
+
+// UpdateUser updates user, whose Version must be the stored one, and fills
+// in the new version.
+func (c *Client) UpdateUser(ctx context.Context, user *service.User) error {
+	var reply serialization.User
+	body := updateRequest{ID: user.ID, Email: user.Email, Name: user.Name, Version: user.Version}
+	if err := c.request(ctx, "nats.Client.UpdateUser", SubjectUpdate, body, &reply); err != nil {
+		return err
+	}
+	*user = *mapping.UserFromDTO(reply)
+	return nil
+}
+
+func (c *Client) DeleteUser(ctx context.Context, id string) error {
+	return c.request(ctx, "nats.Client.DeleteUser", SubjectDelete, idRequest{ID: id}, nil)
+}
+
+func (c *Client) ListUsers(ctx context.Context, req service.PageRequest) (service.Page[service.User], error) {
+	var reply listReply
+	if err := c.request(ctx, "nats.Client.ListUsers", SubjectList, listRequest{Cursor: req.Cursor, Limit: req.Limit}, &reply); err != nil {
+		return service.Page[service.User]{}, err
+	}
+	page := service.Page[service.User]{Items: make([]service.User, len(reply.Users)), NextCursor: reply.NextCursor}
+	for i, dto := range reply.Users {
+		page.Items[i] = *mapping.UserFromDTO(dto)
+	}
+	return page, nil
+}
@@ -0,0 +1,70 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	natsgo "github.com/nats-io/nats.go"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/events"
+)
+
+// EventSubjectPrefix starts the subject of every event, the event's name
+// follows it. Subscribing to "events.user.>" receives every user event.
+const EventSubjectPrefix = "events."
+
+// Publisher is an events.Publisher on NATS subjects.
+type Publisher struct {
+	conn *natsgo.Conn
+}
+
+func NewPublisher(conn *natsgo.Conn) *Publisher {
+	return &Publisher{conn: conn}
+}
+
+// Publish sends event to EventSubjectPrefix plus its name. It returns once
+// the connection has buffered the message, whoever is subscribed then
+// receives it, nobody else ever does.
+func (p *Publisher) Publish(ctx context.Context, event events.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("nats: encode %s: %w", event.Name(), err)
+	}
+	if err := p.conn.Publish(EventSubjectPrefix+event.Name(), data); err != nil {
+		return fmt.Errorf("nats: publish %s: %w", event.Name(), err)
+	}
+	return nil
+}
+
+// Subscribe hands handle every event published on subject, which may hold
+// wildcards, such as "events.>". Events are handled one at a time, in the
+// order a publisher sent them. One that cannot be decoded, or that handle
+// fails, is logged and dropped, core NATS has no redelivery to wait for.
+// Unsubscribe on the result stops it.
+func Subscribe(conn *natsgo.Conn, subject string, handle events.Handler, logger *slog.Logger) (*natsgo.Subscription, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	sub, err := conn.Subscribe(subject, func(msg *natsgo.Msg) {
+		ctx := context.Background()
+		name := strings.TrimPrefix(msg.Subject, EventSubjectPrefix)
+		event, err := events.Decode(name, msg.Data)
+		if err != nil {
+			logger.ErrorContext(ctx, "nats: skipping event", slog.String("subject", msg.Subject), slog.String("error", err.Error()))
+			return
+		}
+		if err := handle(ctx, event); err != nil {
+			logger.ErrorContext(ctx, "nats: event handler failed", slog.String("subject", msg.Subject), slog.String("error", err.Error()))
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("nats: subscribe %s: %w", subject, err)
+	}
+	return sub, nil
+}
@@ -0,0 +1,339 @@
+package nats_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/events"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/messaging/nats"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// The tests start an embedded NATS server in process, no network involved,
+// unless NATS_URL names one:
+//
+//	docker run --rm -p 4222:4222 nats:2.12
+//	NATS_URL=nats://localhost:4222 go test ./messaging/nats
+var url = os.Getenv("NATS_URL")
+
+var quiet = slog.New(slog.DiscardHandler)
+
+// users is the service served by two instances in one queue group, and a
+// client calling it.
+type users struct {
+	t         *testing.T
+	connect   func(name string) *natsgo.Conn
+	instances []micro.Service
+	conns     []*natsgo.Conn
+	conn      *natsgo.Conn
+	client    *nats.Client
+	// run tags IDs, a real server may be shared with earlier runs
+	run int64
+}
+
+func newUsers(t *testing.T) *users {
+	t.Helper()
+	u := &users{t: t, run: time.Now().UnixNano()}
+	opts := []natsgo.Option{}
+	if url == "" {
+		ns := embedded(t)
+		opts = append(opts, natsgo.InProcessServer(ns))
+	}
+	u.connect = func(name string) *natsgo.Conn {
+		t.Helper()
+		conn, err := natsgo.Connect(url, append(opts, natsgo.Name(name))...)
+		if err != nil {
+			t.Fatalf("Connect: %v", err)
+		}
+		t.Cleanup(conn.Close)
+		return conn
+	}
+
+	// each instance has a connection of its own, as separate processes
+	// would, and publishes the events of the writes it serves. They share
+	// the store, the one database behind them.
+	store := db.NewMemoryStore()
+	for i := range 2 {
+		conn := u.connect(fmt.Sprintf("users-%d", i))
+		users := service.NewUserService(store, service.WithPublisher(nats.NewPublisher(conn)))
+		svc, err := nats.NewServer(conn, users, nats.WithLogger(quiet)).Register(context.Background())
+		if err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		t.Cleanup(func() { svc.Stop() })
+		u.instances = append(u.instances, svc)
+		u.conns = append(u.conns, conn)
+	}
+	u.conn = u.connect("client")
+	u.client = nats.NewClient(u.conn, 2*time.Second)
+	return u
+}
+
+// embedded starts a NATS server that accepts in process connections only.
+func embedded(t *testing.T) *server.Server {
+	t.Helper()
+	ns, err := server.NewServer(&server.Options{DontListen: true})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	go ns.Start()
+	t.Cleanup(ns.Shutdown)
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("embedded nats server did not start")
+	}
+	return ns
+}
+
+func (u *users) id(name string) string { return fmt.Sprintf("%s-%d", name, u.run) }
+
+func (u *users) create(name, email string) *service.User {
+	u.t.Helper()
+	user := &service.User{ID: u.id(name), Email: email}
+	if err := u.client.CreateUser(context.Background(), user); err != nil {
+		u.t.Fatalf("CreateUser: %v", err)
+	}
+	return user
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	u := newUsers(t)
+	user := &service.User{ID: u.id("ada"), Email: "ada@example.com", Name: "Ada"}
+	if err := u.client.CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if user.Version != 1 || user.CreatedAt.IsZero() {
+		t.Errorf("created %+v, want version 1 and a creation time", user)
+	}
+	got, err := u.client.RetrieveUser(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("RetrieveUser: %v", err)
+	}
+	if got.Email != user.Email || got.Name != "Ada" {
+		t.Errorf("RetrieveUser = %+v, want %+v", got, user)
+	}
+	got.Email = "ada@lovelace.example"
+	if err := u.client.UpdateUser(ctx, got); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if got.Version != 2 || !got.CreatedAt.Equal(user.CreatedAt) {
+		t.Errorf("updated %+v, want version 2 keeping the creation time", got)
+	}
+	if err := u.client.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if _, err := u.client.RetrieveUser(ctx, user.ID); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("RetrieveUser after delete: err = %v, want ErrNotFound", err)
+	}
+}
+
+// TestErrors checks errors come back as the service's sentinels.
+func TestErrors(t *testing.T) {
+	ctx := context.Background()
+	u := newUsers(t)
+	user := u.create("grace", "grace@example.com")
+
+	err := u.client.CreateUser(ctx, &service.User{ID: user.ID, Email: "other@example.com"})
+	if !errors.Is(err, errs.ErrConflict) || errors.Is(err, errs.ErrVersionConflict) {
+		t.Errorf("duplicate create: err = %v, want ErrConflict", err)
+	}
+	stale := *user
+	user.Name = "Grace"
+	if err := u.client.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if err := u.client.UpdateUser(ctx, &stale); !errors.Is(err, errs.ErrVersionConflict) {
+		t.Errorf("stale update: err = %v, want ErrVersionConflict", err)
+	}
+	err = u.client.CreateUser(ctx, &service.User{ID: u.id("bad"), Email: "not an email"})
+	var serr *nats.ServiceError
+	if !errors.Is(err, errs.ErrInvalidInput) || !errors.As(err, &serr) || serr.Code != 400 {
+		t.Errorf("invalid email: err = %v, want ErrInvalidInput with code 400", err)
+	}
+	// a body that is not JSON at all, sent by hand
+	msg, err := u.conn.RequestWithContext(ctx, nats.SubjectGet, []byte("{not json"))
+	if err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	if code := msg.Header.Get(micro.ErrorCodeHeader); code != "400" {
+		t.Errorf("malformed request: code %q, want 400", code)
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	u := newUsers(t)
+	var want []string
+	for i := range 25 {
+		want = append(want, u.create(fmt.Sprintf("page-%02d", i), fmt.Sprintf("page%d@example.com", i)).ID)
+	}
+	var got []string
+	req := service.PageRequest{Limit: 10}
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatal("paging does not end")
+		}
+		page, err := u.client.ListUsers(context.Background(), req)
+		if err != nil {
+			t.Fatalf("ListUsers: %v", err)
+		}
+		for _, user := range page.Items {
+			if slices.Contains(want, user.ID) {
+				got = append(got, user.ID)
+			}
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		req.Cursor = page.NextCursor
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("listed %v, want %v", got, want)
+	}
+}
+
+// TestQueueGroup checks requests are spread across both instances.
+func TestQueueGroup(t *testing.T) {
+	u := newUsers(t)
+	grace := u.create("grace", "grace@example.com")
+	served := func() []int {
+		n := make([]int, len(u.instances))
+		for i, svc := range u.instances {
+			for _, e := range svc.Stats().Endpoints {
+				n[i] += e.NumRequests
+			}
+		}
+		return n
+	}
+	before := served()
+	var wg sync.WaitGroup
+	for range 200 {
+		wg.Go(func() {
+			if _, err := u.client.RetrieveUser(context.Background(), grace.ID); err != nil {
+				t.Errorf("RetrieveUser: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+	after := served()
+	total := 0
+	for i := range after {
+		if after[i] == before[i] {
+			t.Errorf("instance %d served none of the 200 requests", i)
+		}
+		total += after[i] - before[i]
+	}
+	if total != 200 {
+		t.Errorf("%d requests served, want 200", total)
+	}
+}
+
+// TestEvents checks a user's events arrive on their subjects in order.
+func TestEvents(t *testing.T) {
+	ctx := context.Background()
+	u := newUsers(t)
+	var (
+		mu  sync.Mutex
+		got []string
+	)
+	record := func(_ context.Context, event events.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, event.Name())
+		return nil
+	}
+	listener := u.connect("listener")
+	sub, err := nats.Subscribe(listener, "events.user.>", record, quiet)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+	// the subscription must reach the server before anything is published
+	if err := listener.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	user := u.create("linus", "linus@example.com")
+	user.Name = "Linus"
+	if err := u.client.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if err := u.client.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	want := []string{events.NameUserCreated, events.NameUserUpdated, events.NameUserDeleted}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		done, n := slices.Equal(got, want), len(got)
+		mu.Unlock()
+		if done {
+			return
+		}
+		if n >= len(want) || time.Now().After(deadline) {
+			mu.Lock()
+			defer mu.Unlock()
+			t.Fatalf("got events %v, want %v", got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	u := newUsers(t)
+	subject, err := micro.ControlSubject(micro.InfoVerb, "users", "")
+	if err != nil {
+		t.Fatalf("ControlSubject: %v", err)
+	}
+	msg, err := u.conn.RequestWithContext(context.Background(), subject, nil)
+	if err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	var info micro.Info
+	if err := json.Unmarshal(msg.Data, &info); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	var subjects []string
+	for _, e := range info.Endpoints {
+		subjects = append(subjects, e.Subject)
+	}
+	want := []string{nats.SubjectCreate, nats.SubjectGet, nats.SubjectUpdate, nats.SubjectDelete, nats.SubjectList}
+	if !slices.Equal(subjects, want) {
+		t.Errorf("endpoints %v, want %v", subjects, want)
+	}
+}
+
+// TestUnavailable stops every instance: a call then fails as unavailable
+// at once, rather than waiting out the timeout.
+func TestUnavailable(t *testing.T) {
+	u := newUsers(t)
+	grace := u.create("grace", "grace@example.com")
+	for i, svc := range u.instances {
+		if err := svc.Stop(); err != nil {
+			t.Fatalf("Stop: %v", err)
+		}
+		// the unsubscribes must reach the server before the call does
+		if err := u.conns[i].Flush(); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+	}
+	start := time.Now()
+	if _, err := u.client.RetrieveUser(context.Background(), grace.ID); !errors.Is(err, errs.ErrUnavailable) {
+		t.Fatalf("RetrieveUser: err = %v, want ErrUnavailable", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("RetrieveUser took %s, want no wait for the timeout", elapsed)
+	}
+}
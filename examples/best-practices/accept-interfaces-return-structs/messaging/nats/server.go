@@ -0,0 +1,315 @@
+// Package nats serves the user service over NATS request/reply and
+// publishes its events on NATS subjects.
+//
+// Server registers a NATS micro service, "users", with one endpoint per
+// operation under the users subject prefix: users.create, users.get,
+// users.update, users.delete, and users.list. Every instance joins the same
+// queue group, so running more of them spreads requests between them with
+// no load balancer, and the micro framework answers discovery and stats
+// requests on $SRV subjects for free. Client is the calling side, it gives
+// back the same errs sentinels the service returned.
+//
+// Publisher is an events.Publisher on subjects "events.<event name>", such
+// as events.user.created, and Subscribe decodes them again. This is core
+// NATS: a subscriber gets what is published while it is connected, and
+// nothing is kept for it otherwise. Events that must not be missed go
+// through the outbox to a durable log, see messaging/kafka.
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/mapping"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/serialization"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Subjects of the users endpoints.
+const (
+	SubjectCreate = "users.create"
+	SubjectGet    = "users.get"
+	SubjectUpdate = "users.update"
+	SubjectDelete = "users.delete"
+	SubjectList   = "users.list"
+)
+
+// Error codes carried in the Nats-Service-Error-Code header. They are HTTP
+// status codes, the micro convention, with 412 set apart for a version
+// conflict so the client can tell it from any other conflict.
+const (
+	codeVersionConflict = http.StatusPreconditionFailed
+	codeClientClosed    = 499
+)
+
+func errorCode(err error) int {
+	switch {
+	case errors.Is(err, errs.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errs.ErrVersionConflict):
+		return codeVersionConflict
+	case errors.Is(err, errs.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, errs.ErrInvalidInput):
+		return http.StatusBadRequest
+	case errors.Is(err, errs.ErrUnauthenticated):
+		return http.StatusUnauthorized
+	case errors.Is(err, errs.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, errs.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, errors.ErrUnsupported):
+		return http.StatusNotImplemented
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return codeClientClosed
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// UserService is what the server needs from *service.UserService.
+type UserService interface {
+	CreateUser(ctx context.Context, user *service.User) error
+	RetrieveUser(ctx context.Context, id string, opts ...service.ReadOption) (*service.User, error)
+	UpdateUser(ctx context.Context, user *service.User) error
+	DeleteUser(ctx context.Context, id string) error
+	ListUsers(ctx context.Context, req service.PageRequest, opts ...service.ReadOption) (service.Page[service.User], error)
+}
+
+// The request bodies. Replies are serialization.User, or listReply.
+type (
+	createRequest struct {
+		ID    string `json:"id"`
+		Email string `json:"email"`
+		Name  string `json:"name,omitempty"`
+	}
+	idRequest struct {
+		ID string `json:"id"`
+	}
+	updateRequest struct {
+		ID      string `json:"id"`
+		Email   string `json:"email"`
+		Name    string `json:"name,omitempty"`
+		Version int64  `json:"version"`
+	}
+	listRequest struct {
+		Cursor string `json:"cursor,omitempty"`
+		Limit  int    `json:"limit,omitempty"`
+	}
+	listReply struct {
+		Users      []serialization.User `json:"users"`
+		NextCursor string               `json:"next_cursor,omitempty"`
+	}
+)
+
+// Server answers the users endpoints from a UserService.
+type Server struct {
+	conn    *natsgo.Conn
+	users   UserService
+	logger  *slog.Logger
+	timeout time.Duration
+	version string
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithLogger logs requests that fail unexpectedly, the default discards
+// them.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// WithTimeout bounds how long one request may take, the default is five
+// seconds. NATS carries no deadline from the caller, whose own timeout
+// should be the longer of the two.
+func WithTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.timeout = d
+	}
+}
+
+// WithVersion is the semantic version the service reports to discovery,
+// the default is 1.0.0.
+func WithVersion(v string) Option {
+	return func(s *Server) {
+		s.version = v
+	}
+}
+
+func NewServer(conn *natsgo.Conn, users UserService, opts ...Option) *Server {
+	s := &Server{
+		conn:    conn,
+		users:   users,
+		logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+		timeout: 5 * time.Second,
+		version: "1.0.0",
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Register adds the endpoints to the connection and returns once the NATS
+// server has the subscriptions, so a request sent after it is answered.
+// Requests are handled under ctx, Stop on the result unsubscribes.
+func (s *Server) Register(ctx context.Context) (micro.Service, error) {
+	svc, err := micro.AddService(s.conn, micro.Config{
+		Name:        "users",
+		Version:     s.version,
+		Description: "the user service over request/reply",
+	})
+	if err != nil {
+		return nil, errs.Wrap("nats.Server.Register", err)
+	}
+	group := svc.AddGroup("users")
+	endpoints := []struct {
+		name   string
+		handle func(context.Context, micro.Request) (any, error)
+	}{
+		{"create", s.create},
+		{"get", s.get},
+		{"update", s.update},
+		{"delete", s.delete},
+		{"list", s.list},
+	}
+	for _, e := range endpoints {
+		if err := group.AddEndpoint(e.name, micro.ContextHandler(ctx, s.handler(e.handle))); err != nil {
+			svc.Stop()
+			return nil, errs.Wrap("nats.Server.Register", err)
+		}
+	}
+	if err := s.conn.FlushTimeout(s.timeout); err != nil {
+		svc.Stop()
+		return nil, errs.Wrap("nats.Server.Register", err)
+	}
+	return svc, nil
+}
+
+// Run registers and serves until ctx is done, then stops the service,
+// letting the request in hand finish. It returns ctx.Err().
+func (s *Server) Run(ctx context.Context) error {
+	svc, err := s.Register(context.WithoutCancel(ctx))
+	if err != nil {
+		return err
+	}
+	<-ctx.Done()
+	if err := svc.Stop(); err != nil {
+		return errs.Wrap("nats.Server.Run", err)
+	}
+	return ctx.Err()
+}
+
+// handler runs handle and replies with its result as JSON, or with its
+// error as a micro error. Unexpected errors are logged and described only
+// as internal, like the HTTP and gRPC transports do.
+func (s *Server) handler(handle func(context.Context, micro.Request) (any, error)) func(context.Context, micro.Request) {
+	return func(ctx context.Context, req micro.Request) {
+		ctx, cancel := context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+		reply, err := handle(ctx, req)
+		if err != nil {
+			code := errorCode(err)
+			// a header value is one line
+			description := strings.ReplaceAll(err.Error(), "\n", "; ")
+			if code == http.StatusInternalServerError {
+				s.logger.ErrorContext(ctx, "nats request failed", slog.String("subject", req.Subject()), slog.String("error", err.Error()))
+				description = "internal error"
+			}
+			req.Error(strconv.Itoa(code), description, nil)
+			return
+		}
+		req.RespondJSON(reply)
+	}
+}
+
+// decode reads a request body, a malformed one is invalid input.
+func decode[T any](req micro.Request) (T, error) {
+	var v T
+	if err := json.Unmarshal(req.Data(), &v); err != nil {
+		return v, errs.Wrap("nats.decode", fmt.Errorf("%w: %s", errs.ErrInvalidInput, err))
+	}
+	return v, nil
+}
+
+func (s *Server) create(ctx context.Context, req micro.Request) (any, error) {
+	body, err := decode[createRequest](req)
+	if err != nil {
+		return nil, err
+	}
+	user := &service.User{ID: body.ID, Email: body.Email, Name: body.Name}
+	if err := s.users.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return mapping.UserToDTO(user), nil
+}
+
+func (s *Server) get(ctx context.Context, req micro.Request) (any, error) {
+	body, err := decode[idRequest](req)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.users.RetrieveUser(ctx, body.ID)
+	if err != nil {
+		return nil, err
+	}
+	return mapping.UserToDTO(user), nil
+}
+
+func (s *Server) update(ctx context.Context, req micro.Request) (any, error) {
+	body, err := decode[updateRequest](req)
+	if err != nil {
+		return nil, err
+	}
+	user := &service.User{ID: body.ID, Email: body.Email, Name: body.Name, Version: body.Version}
+	if err := s.users.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	// re-read so the reply carries the stored CreatedAt as well
+	stored, err := s.users.RetrieveUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return mapping.UserToDTO(stored), nil
+}
+
+func (s *Server) delete(ctx context.Context, req micro.Request) (any, error) {
+	body, err := decode[idRequest](req)
+	if err != nil {
+		return nil, err
+	}
+	return struct{}{}, s.users.DeleteUser(ctx, body.ID)
+}
+
+func (s *Server) list(ctx context.Context, req micro.Request) (any, error) {
+	body, err := decode[listRequest](req)
+	if err != nil {
+		return nil, err
+	}
+	page, err := s.users.ListUsers(ctx, service.PageRequest{Cursor: body.Cursor, Limit: body.Limit})
+	if err != nil {
+		return nil, err
+	}
+	reply := listReply{Users: make([]serialization.User, len(page.Items)), NextCursor: page.NextCursor}
+	for i := range page.Items {
+		reply.Users[i] = mapping.UserToDTO(&page.Items[i])
+	}
+	return reply, nil
+}
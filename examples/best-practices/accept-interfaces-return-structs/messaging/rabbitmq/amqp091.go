@@ -0,0 +1,230 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Headers the adapter keeps a message's state in.
+const (
+	attemptHeader = "x-attempt"
+	reasonHeader  = "x-error"
+)
+
+// ErrClosed is returned by Receive once the connection is gone.
+var ErrClosed = errors.New("rabbitmq: connection closed")
+
+// BrokerQueue is a Queue on a RabbitMQ broker, through rabbitmq/amqp091-go.
+//
+// Dial declares the topology it needs, all durable:
+//
+//   - name, the work queue, dead-lettering to name.dlq, so a message
+//     rejected by any other consumer is kept as well;
+//   - name.dlq, the dead-letter queue;
+//   - name.retry.<ms> on the first Retry with that delay: a queue nobody
+//     consumes whose messages expire after the delay and are dead-lettered
+//     back to name. RabbitMQ expires messages from the head of a queue
+//     only, so each delay needs a queue of its own.
+//
+// Every publish waits for the broker's confirm, and Retry and DeadLetter
+// publish the copy before acknowledging the original: a crash in between
+// duplicates the job, never loses it.
+type BrokerQueue struct {
+	conn       *amqp.Connection
+	ch         *amqp.Channel
+	name       string
+	deliveries <-chan amqp.Delivery
+
+	mu sync.Mutex
+	// retryQueues holds the retry queues declared so far
+	retryQueues map[time.Duration]string
+}
+
+// Dial connects to the broker at url and consumes name, holding at most
+// prefetch messages unsettled.
+func Dial(url, name string, prefetch int) (*BrokerQueue, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, fmt.Errorf("rabbitmq: dial: %w", err)
+	}
+	q, err := open(conn, name, prefetch)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+func open(conn *amqp.Connection, name string, prefetch int) (*BrokerQueue, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("rabbitmq: open channel: %w", err)
+	}
+	if _, err := ch.QueueDeclare(name+".dlq", true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("rabbitmq: declare %s.dlq: %w", name, err)
+	}
+	args := amqp.Table{"x-dead-letter-exchange": "", "x-dead-letter-routing-key": name + ".dlq"}
+	if _, err := ch.QueueDeclare(name, true, false, false, false, args); err != nil {
+		return nil, fmt.Errorf("rabbitmq: declare %s: %w", name, err)
+	}
+	if err := ch.Qos(max(prefetch, 1), 0, false); err != nil {
+		return nil, fmt.Errorf("rabbitmq: qos: %w", err)
+	}
+	if err := ch.Confirm(false); err != nil {
+		return nil, fmt.Errorf("rabbitmq: confirm mode: %w", err)
+	}
+	deliveries, err := ch.Consume(name, "", false, false, false, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("rabbitmq: consume %s: %w", name, err)
+	}
+	return &BrokerQueue{conn: conn, ch: ch, name: name, deliveries: deliveries, retryQueues: make(map[time.Duration]string)}, nil
+}
+
+func (q *BrokerQueue) Publish(ctx context.Context, msg Message) error {
+	return q.publish(ctx, q.name, msg)
+}
+
+// publish sends msg to queue through the default exchange, persistent, and
+// waits for the confirm.
+func (q *BrokerQueue) publish(ctx context.Context, queue string, msg Message) error {
+	headers := amqp.Table{attemptHeader: int64(max(msg.Attempt, 1))}
+	if msg.Reason != "" {
+		headers[reasonHeader] = msg.Reason
+	}
+	confirm, err := q.ch.PublishWithDeferredConfirmWithContext(ctx, "", queue, false, false, amqp.Publishing{
+		MessageId:    msg.ID,
+		Body:         msg.Body,
+		Headers:      headers,
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("rabbitmq: publish %s to %s: %w", msg.ID, queue, err)
+	}
+	ok, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("rabbitmq: publish %s to %s: %w", msg.ID, queue, err)
+	}
+	if !ok {
+		return fmt.Errorf("rabbitmq: publish %s to %s: nacked by the broker", msg.ID, queue)
+	}
+	return nil
+}
+
+func (q *BrokerQueue) Receive(ctx context.Context) (Delivery, error) {
+	select {
+	case <-ctx.Done():
+		return Delivery{}, ctx.Err()
+	case d, ok := <-q.deliveries:
+		if !ok {
+			return Delivery{}, ErrClosed
+		}
+		reason, _ := d.Headers[reasonHeader].(string)
+		return Delivery{
+			Message: Message{
+				ID:      d.MessageId,
+				Body:    d.Body,
+				Attempt: attempt(d.Headers[attemptHeader]),
+				Reason:  reason,
+			},
+			Tag:         d.DeliveryTag,
+			Redelivered: d.Redelivered,
+		}, nil
+	}
+}
+
+// attempt reads the attempt header, which a publisher other than this one
+// may have sent as any integer type, or not at all.
+func attempt(v any) int {
+	switch n := v.(type) {
+	case int64:
+		return int(n)
+	case int32:
+		return int(n)
+	case int16:
+		return int(n)
+	case int8:
+		return int(n)
+	case int:
+		return n
+	}
+	return 1
+}
+
+func (q *BrokerQueue) Ack(_ context.Context, d Delivery) error {
+	if err := q.ch.Ack(d.Tag, false); err != nil {
+		return fmt.Errorf("rabbitmq: ack %s: %w", d.ID, err)
+	}
+	return nil
+}
+
+func (q *BrokerQueue) Retry(ctx context.Context, d Delivery, delay time.Duration) error {
+	queue, err := q.retryQueue(delay)
+	if err != nil {
+		return err
+	}
+	msg := d.Message
+	msg.Attempt = max(msg.Attempt, 1) + 1
+	if err := q.publish(ctx, queue, msg); err != nil {
+		return err
+	}
+	return q.Ack(ctx, d)
+}
+
+// retryQueue declares, once, the queue holding retries for delay.
+func (q *BrokerQueue) retryQueue(delay time.Duration) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if name, ok := q.retryQueues[delay]; ok {
+		return name, nil
+	}
+	ms := max(delay.Milliseconds(), 1)
+	name := fmt.Sprintf("%s.retry.%d", q.name, ms)
+	args := amqp.Table{
+		"x-message-ttl":             ms,
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": q.name,
+	}
+	if _, err := q.ch.QueueDeclare(name, true, false, false, false, args); err != nil {
+		return "", fmt.Errorf("rabbitmq: declare %s: %w", name, err)
+	}
+	q.retryQueues[delay] = name
+	return name, nil
+}
+
+func (q *BrokerQueue) DeadLetter(ctx context.Context, d Delivery, reason error) error {
+	msg := d.Message
+	msg.Reason = reason.Error()
+	if err := q.publish(ctx, q.name+".dlq", msg); err != nil {
+		return err
+	}
+	return q.Ack(ctx, d)
+}
+
+// DrainDeadLetters takes every message off the dead-letter queue, to look
+// at or publish again.
+func (q *BrokerQueue) DrainDeadLetters(ctx context.Context) ([]Message, error) {
+	var msgs []Message
+	for ctx.Err() == nil {
+		d, ok, err := q.ch.Get(q.name+".dlq", true)
+		if err != nil {
+			return msgs, fmt.Errorf("rabbitmq: get %s.dlq: %w", q.name, err)
+		}
+		if !ok {
+			return msgs, nil
+		}
+		reason, _ := d.Headers[reasonHeader].(string)
+		msgs = append(msgs, Message{ID: d.MessageId, Body: d.Body, Attempt: attempt(d.Headers[attemptHeader]), Reason: reason})
+	}
+	return msgs, ctx.Err()
+}
+
+// Close closes the connection, the broker requeues what it held unsettled.
+func (q *BrokerQueue) Close() error {
+	return q.conn.Close()
+}
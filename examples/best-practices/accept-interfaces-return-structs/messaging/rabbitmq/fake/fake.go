@@ -0,0 +1,250 @@
+// Package fake is an in-memory RabbitMQ for running Workers without a
+// broker. A Broker holds the queues, Open on it is one consumer's
+// connection and a rabbitmq.Queue, so code written against that interface
+// runs on it unchanged:
+//
+//	broker := fake.New()
+//	queue := broker.Open("imports", 8)
+//	workers := rabbitmq.NewWorkers(queue, rabbitmq.ImportHandler(users))
+//
+// It keeps the semantics workers have to be written for: a message goes to
+// one consumer at a time, a connection holds at most its prefetch
+// unsettled, a retry comes back only after its delay, and closing a
+// connection hands what it held unsettled to the other consumers, marked
+// redelivered.
+//
+// There is no persistence, no network, and no ordering guarantee beyond
+// first in first out for messages that are never retried.
+package fake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/messaging/rabbitmq"
+)
+
+var (
+	// ErrClosed is returned by a Queue's methods after Close.
+	ErrClosed = errors.New("fake: connection closed")
+	// ErrUnknownDelivery is returned settling a delivery this connection
+	// does not hold, one settled already or handed out by another. A
+	// broker closes the channel for it.
+	ErrUnknownDelivery = errors.New("fake: unknown delivery tag")
+)
+
+// entry is a message on a queue, or held unsettled by a connection.
+type entry struct {
+	msg         rabbitmq.Message
+	redelivered bool
+}
+
+type queue struct {
+	ready []entry
+	dead  []rabbitmq.Message
+	// retrying counts the messages waiting out a retry delay
+	retrying int
+}
+
+// Broker holds every queue, created on first use.
+type Broker struct {
+	mu     sync.Mutex
+	queues map[string]*queue
+	// changed is closed and replaced whenever a Receive might now succeed
+	changed chan struct{}
+}
+
+func New() *Broker {
+	return &Broker{queues: make(map[string]*queue), changed: make(chan struct{})}
+}
+
+func (b *Broker) queueLocked(name string) *queue {
+	q, ok := b.queues[name]
+	if !ok {
+		q = &queue{}
+		b.queues[name] = q
+	}
+	return q
+}
+
+func (b *Broker) notifyLocked() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// Open is a connection consuming name, holding at most prefetch messages
+// unsettled.
+func (b *Broker) Open(name string, prefetch int) *Queue {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.queueLocked(name)
+	return &Queue{broker: b, name: name, prefetch: max(prefetch, 1), unacked: make(map[uint64]entry)}
+}
+
+// Depth reports name's messages: ready to deliver, waiting out a retry
+// delay, and dead-lettered. Unsettled deliveries are in none of them.
+func (b *Broker) Depth(name string) (ready, retrying, dead int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q := b.queueLocked(name)
+	return len(q.ready), q.retrying, len(q.dead)
+}
+
+// Queue is one connection to a queue.
+type Queue struct {
+	broker   *Broker
+	name     string
+	prefetch int
+	closed   bool
+	nextTag  uint64
+	unacked  map[uint64]entry
+}
+
+func (c *Queue) Publish(ctx context.Context, msg rabbitmq.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	msg.Attempt = max(msg.Attempt, 1)
+	msg.Body = slices.Clone(msg.Body)
+	q := b.queueLocked(c.name)
+	q.ready = append(q.ready, entry{msg: msg})
+	b.notifyLocked()
+	return nil
+}
+
+func (c *Queue) Receive(ctx context.Context) (rabbitmq.Delivery, error) {
+	b := c.broker
+	for {
+		b.mu.Lock()
+		if c.closed {
+			b.mu.Unlock()
+			return rabbitmq.Delivery{}, ErrClosed
+		}
+		q := b.queueLocked(c.name)
+		if len(q.ready) > 0 && len(c.unacked) < c.prefetch {
+			r := q.ready[0]
+			q.ready = q.ready[1:]
+			c.nextTag++
+			tag := c.nextTag
+			c.unacked[tag] = r
+			b.mu.Unlock()
+			return rabbitmq.Delivery{Message: r.msg, Tag: tag, Redelivered: r.redelivered}, nil
+		}
+		changed := b.changed
+		b.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return rabbitmq.Delivery{}, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// settleLocked removes d from the connection's unsettled deliveries.
+func (c *Queue) settleLocked(d rabbitmq.Delivery) (entry, error) {
+	if c.closed {
+		return entry{}, ErrClosed
+	}
+	r, ok := c.unacked[d.Tag]
+	if !ok {
+		return entry{}, fmt.Errorf("%w: %d", ErrUnknownDelivery, d.Tag)
+	}
+	delete(c.unacked, d.Tag)
+	// a slot under the prefetch opened up
+	c.broker.notifyLocked()
+	return r, nil
+}
+
+func (c *Queue) Ack(_ context.Context, d rabbitmq.Delivery) error {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+	_, err := c.settleLocked(d)
+	return err
+}
+
+func (c *Queue) Retry(_ context.Context, d rabbitmq.Delivery, delay time.Duration) error {
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r, err := c.settleLocked(d)
+	if err != nil {
+		return err
+	}
+	msg := r.msg
+	msg.Attempt++
+	q := b.queueLocked(c.name)
+	q.retrying++
+	time.AfterFunc(delay, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		q.retrying--
+		q.ready = append(q.ready, entry{msg: msg})
+		b.notifyLocked()
+	})
+	return nil
+}
+
+func (c *Queue) DeadLetter(_ context.Context, d rabbitmq.Delivery, reason error) error {
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r, err := c.settleLocked(d)
+	if err != nil {
+		return err
+	}
+	msg := r.msg
+	msg.Reason = reason.Error()
+	q := b.queueLocked(c.name)
+	q.dead = append(q.dead, msg)
+	return nil
+}
+
+// DrainDeadLetters takes every message off the dead-letter queue.
+func (c *Queue) DrainDeadLetters(ctx context.Context) ([]rabbitmq.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q := b.queueLocked(c.name)
+	dead := q.dead
+	q.dead = nil
+	return dead, nil
+}
+
+// Close puts every delivery the connection holds unsettled back at the
+// front of the queue, marked redelivered.
+func (c *Queue) Close() error {
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	tags := make([]uint64, 0, len(c.unacked))
+	for tag := range c.unacked {
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+	back := make([]entry, len(tags))
+	for i, tag := range tags {
+		back[i] = entry{msg: c.unacked[tag].msg, redelivered: true}
+	}
+	c.unacked = nil
+	q := b.queueLocked(c.name)
+	q.ready = append(back, q.ready...)
+	b.notifyLocked()
+	return nil
+}
@@ -0,0 +1,58 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// ImportJob asks for one user to be created, a row of a bulk import.
+type ImportJob struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+// PublishImport queues job, its message ID is the user's.
+func PublishImport(ctx context.Context, queue Queue, job ImportJob) error {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("rabbitmq: encode import %s: %w", job.ID, err)
+	}
+	return queue.Publish(ctx, Message{ID: job.ID, Body: body})
+}
+
+// UserImporter is what ImportHandler needs from *service.UserService.
+type UserImporter interface {
+	CreateUser(ctx context.Context, user *service.User) error
+	RetrieveUser(ctx context.Context, id string, opts ...service.ReadOption) (*service.User, error)
+}
+
+// ImportHandler creates the user each ImportJob describes. A job that does
+// not decode, or that the service rejects as invalid, fails with
+// errs.ErrInvalidInput and is dead-lettered, retrying cannot fix it.
+//
+// It is idempotent: a job redelivered after the user was created finds them
+// already there with the same email, and succeeds. A user already there
+// with another email is a conflict, a different import claimed the ID.
+func ImportHandler(users UserImporter) Handler {
+	return func(ctx context.Context, msg Message) error {
+		var job ImportJob
+		if err := json.Unmarshal(msg.Body, &job); err != nil {
+			return errs.Wrap("rabbitmq.ImportHandler", fmt.Errorf("%w: %s", errs.ErrInvalidInput, err))
+		}
+		err := users.CreateUser(ctx, &service.User{ID: job.ID, Email: job.Email, Name: job.Name})
+		if !errors.Is(err, errs.ErrConflict) {
+			return errs.Wrap("rabbitmq.ImportHandler", err)
+		}
+		existing, rerr := users.RetrieveUser(ctx, job.ID)
+		if rerr == nil && existing.Email == job.Email {
+			return nil
+		}
+		return errs.Wrap("rabbitmq.ImportHandler", err)
+	}
+}
@@ -0,0 +1,216 @@
+// Package rabbitmq is a work queue over AMQP: jobs, such as user imports,
+// are published to a queue and handled by a pool of Workers, each job by
+// exactly one of them.
+//
+// Workers acknowledge a job only once its handler returned. A job that
+// failed with a transient error is retried after a delay, one that keeps
+// failing or fails for good is moved to the queue's dead-letter queue with
+// the error, to be looked at and replayed by a person. A worker that dies
+// holding jobs it has not acknowledged loses nothing, the broker hands them
+// to another worker. Delivery is therefore at least once, and handlers must
+// be idempotent, as ImportHandler is.
+//
+// Workers speak to the broker through the Queue interface below. Dial
+// adapts rabbitmq/amqp091-go to it for a real broker, package fake is an in
+// memory queue with the same delivery semantics for running without one.
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/jobqueue"
+)
+
+// Message is one job on a queue.
+type Message struct {
+	ID   string
+	Body []byte
+	// Attempt is 1 the first time a job is handled and goes up on every
+	// Retry. A redelivery after a worker died does not count.
+	Attempt int
+	// Reason is the error that dead-lettered the job, empty otherwise.
+	Reason string
+}
+
+// Delivery is a message handed to one consumer, which must settle it with
+// exactly one of Ack, Retry, or DeadLetter.
+type Delivery struct {
+	Message
+	// Tag identifies the delivery to the Queue that handed it out.
+	Tag uint64
+	// Redelivered is set when an earlier consumer received the message and
+	// never settled it.
+	Redelivered bool
+}
+
+// Queue is one consumer's connection to a queue. Receive blocks for the
+// next message, no more than the connection's prefetch are handed out
+// unsettled at a time. Closing the connection returns what it holds
+// unsettled to the queue, redelivered to whoever receives next.
+type Queue interface {
+	Publish(ctx context.Context, msg Message) error
+	Receive(ctx context.Context) (Delivery, error)
+	// Ack removes a handled message.
+	Ack(ctx context.Context, d Delivery) error
+	// Retry puts the message back, with its next Attempt, once delay has
+	// passed.
+	Retry(ctx context.Context, d Delivery, delay time.Duration) error
+	// DeadLetter moves the message to the dead-letter queue with reason.
+	DeadLetter(ctx context.Context, d Delivery, reason error) error
+	Close() error
+}
+
+// Handler handles one job.
+type Handler func(ctx context.Context, msg Message) error
+
+// Workers runs a pool of goroutines receiving from one queue.
+type Workers struct {
+	queue     Queue
+	handle    Handler
+	workers   int
+	delays    []time.Duration
+	retryable func(err error) bool
+	logger    *slog.Logger
+}
+
+// Option configures Workers.
+type Option func(*Workers)
+
+// WithWorkers sets how many jobs are handled at once, the default is 4.
+// The queue's prefetch should be at least as many.
+func WithWorkers(n int) Option {
+	return func(w *Workers) {
+		w.workers = max(n, 1)
+	}
+}
+
+// WithRetryDelays sets how long each retry waits, the first after the
+// first attempt and so on. A job failing once more than there are delays is
+// dead-lettered. The default is 1s, 10s, and 1m. RabbitMQ holds a retry in
+// a queue per delay, so delays should be few and fixed, not jittered.
+func WithRetryDelays(delays ...time.Duration) Option {
+	return func(w *Workers) {
+		w.delays = delays
+	}
+}
+
+// WithRetryable reports whether an error is worth retrying, the default is
+// jobqueue.Transient. Anything else is dead-lettered at once.
+func WithRetryable(fn func(err error) bool) Option {
+	return func(w *Workers) {
+		w.retryable = fn
+	}
+}
+
+// WithLogger logs failed jobs and settlements, the default is slog.Default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(w *Workers) {
+		w.logger = logger
+	}
+}
+
+func NewWorkers(queue Queue, handle Handler, opts ...Option) *Workers {
+	w := &Workers{
+		queue:     queue,
+		handle:    handle,
+		workers:   4,
+		delays:    []time.Duration{time.Second, 10 * time.Second, time.Minute},
+		retryable: jobqueue.Transient,
+		logger:    slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Run handles jobs until ctx is done, it then waits for the jobs in hand
+// and returns ctx.Err(). A job whose handler failed because ctx ended is
+// left unsettled, to be handed out again once the queue is closed.
+//
+// A Receive failure other than ctx ending stops every worker and is
+// returned, the connection is likely gone. A failed settlement is logged
+// and the worker carries on: the message stays unsettled, and the broker
+// delivers it again once the connection closes.
+func (w *Workers) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for range w.workers {
+		wg.Go(func() {
+			if err := w.work(ctx); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		})
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+func (w *Workers) work(ctx context.Context) error {
+	for {
+		d, err := w.queue.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("rabbitmq: receive: %w", err)
+		}
+		herr := w.call(ctx, d.Message)
+		if herr != nil && ctx.Err() != nil {
+			// stopped mid job: leave it unsettled for the broker to hand
+			// out again once the connection closes, not dead-lettered for
+			// the cancellation
+			return nil
+		}
+		if err := w.settle(context.WithoutCancel(ctx), d, herr); err != nil {
+			w.logger.WarnContext(ctx, "rabbitmq: settle failed",
+				slog.String("id", d.ID),
+				slog.Int("attempt", d.Attempt),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+}
+
+// call runs the handler, recovering a panic into its error.
+func (w *Workers) call(ctx context.Context, msg Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("rabbitmq: job %s panicked: %v", msg.ID, r)
+		}
+	}()
+	return w.handle(ctx, msg)
+}
+
+func (w *Workers) settle(ctx context.Context, d Delivery, err error) error {
+	if err == nil {
+		return w.queue.Ack(ctx, d)
+	}
+	attrs := []any{
+		slog.String("id", d.ID),
+		slog.Int("attempt", d.Attempt),
+		slog.String("error", err.Error()),
+	}
+	if !w.retryable(err) || d.Attempt > len(w.delays) {
+		w.logger.ErrorContext(ctx, "rabbitmq: job dead-lettered", attrs...)
+		return w.queue.DeadLetter(ctx, d, err)
+	}
+	delay := w.delays[max(d.Attempt, 1)-1]
+	w.logger.WarnContext(ctx, "rabbitmq: job failed, retrying", append(attrs, slog.Duration("delay", delay))...)
+	return w.queue.Retry(ctx, d, delay)
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Deprecation marks every response as coming from a deprecated API:
+// Deprecation (RFC 9745) says since when, Sunset (RFC 8594) when it goes
+// away, and a successor-version Link where to move to. A zero sunset or
+// empty successor leaves that header out.
+func Deprecation(since, sunset time.Time, successor string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("Deprecation", fmt.Sprintf("@%d", since.Unix()))
+			if !sunset.IsZero() {
+				h.Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			}
+			if successor != "" {
+				h.Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+)
+
+// Logger logs one structured line per request once the handler returns:
+// method, path, status, response size, and duration. 5xx responses log at
+// Error, the rest at Info. The request and trace IDs are not added here,
+// a logger built with logging.New picks them up from the context.
+//
+// It also stores logger in the context, see ctxutil.Logger, so the service
+// and stores log through the same handler.
+func Logger(logger *slog.Logger) Middleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := newRecorder(w)
+			next.ServeHTTP(rec, r.WithContext(ctxutil.WithLogger(r.Context(), logger)))
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rec.Status()),
+				slog.Int("bytes", rec.bytes),
+				slog.Duration("duration", time.Since(start)),
+			}
+			level := slog.LevelInfo
+			if rec.Status() >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			logger.LogAttrs(r.Context(), level, "http request", attrs...)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import "net/http"
+
+// Middleware wraps a handler with behavior that runs before and/or after it.
+type Middleware func(http.Handler) http.Handler
+
+// Chain composes middlewares into one. The first one listed is the
+// outermost, so it sees the request first and the response last:
+//
+//	Chain(RequestID(gen), Logger(logger), Recover(logger))(h)
+//
+// is RequestID(Logger(Recover(h))). Put Recover inside Logger so a panic is
+// logged as the 500 it turns into, and RequestID outside both so their log
+// lines carry the ID.
+func Chain(mws ...Middleware) Middleware {
+	return func(h http.Handler) http.Handler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			h = mws[i](h)
+		}
+		return h
+	}
+}
+
+// recorder captures the status code and body size written through it.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+	// beforeWrite runs once, just before the header is sent
+	beforeWrite func(w http.ResponseWriter)
+}
+
+func newRecorder(w http.ResponseWriter) *recorder {
+	// an existing recorder is reused so nested middlewares share one view
+	if rec, ok := w.(*recorder); ok {
+		return rec
+	}
+	return &recorder{ResponseWriter: w}
+}
+
+func (r *recorder) WriteHeader(code int) {
+	if r.status != 0 {
+		return
+	}
+	if r.beforeWrite != nil {
+		r.beforeWrite(r.ResponseWriter)
+	}
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Status is the code sent, 200 for a handler that wrote nothing.
+func (r *recorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// Unwrap lets http.ResponseController reach Flush, Hijack and deadlines
+// on the underlying writer.
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
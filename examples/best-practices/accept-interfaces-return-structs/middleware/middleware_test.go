@@ -0,0 +1,202 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/idgen"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/logging"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/middleware"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/safe"
+)
+
+// tag is a middleware recording when the request and the response pass it.
+func tag(calls *[]string, name string) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*calls = append(*calls, name+" in")
+			next.ServeHTTP(w, r)
+			*calls = append(*calls, name+" out")
+		})
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	h := middleware.Chain(tag(&calls, "a"), tag(&calls, "b"), tag(&calls, "c"))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		calls = append(calls, "handler")
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	want := []string{"a in", "b in", "c in", "handler", "c out", "b out", "a out"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+
+	// no middlewares is the handler itself
+	calls = nil
+	middleware.Chain()(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		calls = append(calls, "handler")
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !slices.Equal(calls, []string{"handler"}) {
+		t.Errorf("empty chain calls = %v, want only the handler", calls)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	h := middleware.RequestID(&idgen.Sequence{Prefix: "req-"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = ctxutil.RequestID(r.Context())
+	}))
+	for _, tt := range []struct {
+		name, header, want string
+	}{
+		{"minted", "", "req-1"},
+		{"kept", "from-the-proxy", "from-the-proxy"},
+		{"forged log line replaced", "a\nlevel=ERROR", "req-2"},
+		{"too long replaced", strings.Repeat("x", 129), "req-3"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				r.Header.Set(middleware.RequestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if seen != tt.want {
+				t.Errorf("context request ID = %q, want %q", seen, tt.want)
+			}
+			if got := w.Header().Get(middleware.RequestIDHeader); got != tt.want {
+				t.Errorf("response %s = %q, want %q", middleware.RequestIDHeader, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRecommendedOrder runs a panicking handler through the chain Chain's
+// documentation recommends: the panic becomes a 500, and the one request
+// line Logger writes reports that 500 with the request's ID.
+func TestRecommendedOrder(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(logging.NewContextHandler(slog.NewJSONHandler(&buf, nil)))
+	h := middleware.Chain(
+		middleware.RequestID(&idgen.Sequence{Prefix: "req-"}),
+		middleware.Logger(logger),
+		middleware.Recover(logger),
+	)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/users", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+
+	var lines []map[string]any
+	for line := range strings.Lines(buf.String()) {
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+		lines = append(lines, m)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("%d log lines, want the panic and the request: %v", len(lines), lines)
+	}
+	if p := lines[0]; p["msg"] != "panic serving request" || p["panic"] != "boom" || !strings.Contains(p["stack"].(string), "middleware_test") {
+		t.Errorf("panic line = %v, want boom with its stack", p)
+	}
+	if r := lines[1]; r["msg"] != "http request" || r["status"] != 500.0 || r["level"] != "ERROR" || r["request_id"] != "req-1" {
+		t.Errorf("request line = %v, want an ERROR for the 500 of req-1", r)
+	}
+}
+
+// TestRecoverAfterWrite checks a panic after the response started aborts
+// the connection, the status already sent cannot become a 500.
+func TestRecoverAfterWrite(t *testing.T) {
+	srv := httptest.NewServer(middleware.Recover(slog.New(slog.DiscardHandler))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "partial")
+		http.NewResponseController(w).Flush()
+		panic("boom")
+	})))
+	defer srv.Close()
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get: %v, want the status line before the abort", err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); err == nil {
+		t.Error("the body of the aborted response read cleanly, want an error")
+	}
+}
+
+func explode() { panic("boom") }
+
+// TestRecoverReports checks the handler's panic goes to the safe hooks
+// with the stack it panicked on, and to the log.
+func TestRecoverReports(t *testing.T) {
+	var logs bytes.Buffer
+	var reported []*safe.PanicError
+	t.Cleanup(safe.OnPanic(func(pe *safe.PanicError) { reported = append(reported, pe) }))
+	h := middleware.Recover(slog.New(slog.NewJSONHandler(&logs, nil)))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		explode()
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/ada", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", w.Code)
+	}
+	if len(reported) != 1 {
+		t.Fatalf("%d panics reported, want 1", len(reported))
+	}
+	if top := strings.SplitN(string(reported[0].Stack), "\n", 3)[1]; !strings.Contains(top, "middleware_test.explode(") {
+		t.Errorf("top frame %q, want explode\n%s", top, reported[0].Stack)
+	}
+	if !strings.Contains(logs.String(), `"panic":"boom"`) || !strings.Contains(logs.String(), "middleware_test.explode") {
+		t.Errorf("log %s, want the panic and its stack", logs.String())
+	}
+}
+
+// TestRecoverAbort checks http.ErrAbortHandler passes through Recover
+// unreported.
+func TestRecoverAbort(t *testing.T) {
+	reported := 0
+	t.Cleanup(safe.OnPanic(func(*safe.PanicError) { reported++ }))
+	h := middleware.Recover(slog.New(slog.DiscardHandler))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	err := safe.Do(func() error {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		return nil
+	})
+	if !errors.Is(err, http.ErrAbortHandler) {
+		t.Errorf("ServeHTTP panicked with %v, want http.ErrAbortHandler", err)
+	}
+	// the one report is the outer Do's, Recover let it go
+	if reported != 1 {
+		t.Errorf("%d panics reported, want only the outer Do's", reported)
+	}
+}
+
+func TestTiming(t *testing.T) {
+	h := middleware.Chain(middleware.Timing(), middleware.Logger(slog.New(slog.DiscardHandler)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if got := w.Header().Get("Server-Timing"); !strings.HasPrefix(got, "app;dur=") {
+		t.Errorf("Server-Timing = %q, want app;dur=...", got)
+	}
+	if w.Code != http.StatusTeapot {
+		t.Errorf("status = %d, want the handler's 418", w.Code)
+	}
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// KeyFunc picks the bucket a request is counted against.
+type KeyFunc func(r *http.Request) string
+
+// KeyByIP buckets requests by the connection's remote IP. Behind a proxy
+// every request shares the proxy's IP, use a KeyFunc that trusts the
+// proxy's forwarding header there instead.
+func KeyByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// KeyByHeader buckets requests by an API key header, falling back to the
+// client IP for requests that do not send one.
+func KeyByHeader(header string) KeyFunc {
+	return func(r *http.Request) string {
+		if key := r.Header.Get(header); key != "" {
+			return "key:" + key
+		}
+		return "ip:" + KeyByIP(r)
+	}
+}
+
+type rateLimiter struct {
+	limit   rate.Limit
+	burst   int
+	key     KeyFunc
+	idleTTL time.Duration
+
+	mu        sync.Mutex
+	clients   map[string]*client
+	lastPrune time.Time
+}
+
+type client struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+type RateLimitOption func(*rateLimiter)
+
+// WithRate allows r requests per second per client with bursts of up to
+// burst. The default is 10 per second with a burst of 20.
+func WithRate(r rate.Limit, burst int) RateLimitOption {
+	return func(l *rateLimiter) {
+		l.limit, l.burst = r, burst
+	}
+}
+
+// WithKeyFunc replaces the default KeyByIP.
+func WithKeyFunc(key KeyFunc) RateLimitOption {
+	return func(l *rateLimiter) {
+		l.key = key
+	}
+}
+
+// WithIdleTTL forgets clients that have been quiet for d, which bounds
+// memory to the clients seen recently. The default is 10 minutes.
+func WithIdleTTL(d time.Duration) RateLimitOption {
+	return func(l *rateLimiter) {
+		l.idleTTL = d
+	}
+}
+
+// RateLimit gives every client its own token bucket. A request that finds
+// the bucket empty is answered 429 Too Many Requests with a Retry-After
+// header saying when a token will be available, and never reaches next.
+func RateLimit(opts ...RateLimitOption) Middleware {
+	l := &rateLimiter{
+		limit:   10,
+		burst:   20,
+		key:     KeyByIP,
+		idleTTL: 10 * time.Minute,
+		clients: make(map[string]*client),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if wait, ok := l.allow(l.key(r)); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// allow takes a token for key, or reports how long until one is available.
+func (l *rateLimiter) allow(key string) (time.Duration, bool) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(now)
+
+	c, ok := l.clients[key]
+	if !ok {
+		c = &client{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[key] = c
+	}
+	c.lastSeen = now
+	res := c.limiter.ReserveN(now, 1)
+	if !res.OK() {
+		// a burst of 0 never admits anything
+		return time.Second, false
+	}
+	if delay := res.DelayFrom(now); delay > 0 {
+		// give the token back, this request is rejected rather than queued
+		res.CancelAt(now)
+		return delay, false
+	}
+	return 0, true
+}
+
+// prune drops idle clients at most once per idleTTL, so the sweep is
+// amortized over many requests. l.mu must be held.
+func (l *rateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < l.idleTTL {
+		return
+	}
+	l.lastPrune = now
+	for key, c := range l.clients {
+		if now.Sub(c.lastSeen) >= l.idleTTL {
+			delete(l.clients, key)
+		}
+	}
+}
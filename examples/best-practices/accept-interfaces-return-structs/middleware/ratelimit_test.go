@@ -0,0 +1,105 @@
+package middleware_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/middleware"
+)
+
+// TestRateLimitLoad fires many concurrent requests from several API keys
+// at a rate slow enough that no token refills during the test: each key
+// gets exactly its burst through, and every other request is a 429 that
+// never reached the handler.
+func TestRateLimitLoad(t *testing.T) {
+	const (
+		keys     = 5
+		perKey   = 50
+		burst    = 7
+		rejected = perKey - burst
+	)
+	var handled atomic.Int64
+	h := middleware.RateLimit(
+		middleware.WithRate(0.001, burst),
+		middleware.WithKeyFunc(middleware.KeyByHeader("X-API-Key")),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handled.Add(1)
+	}))
+
+	var (
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+		ok    [keys]atomic.Int64
+		limit [keys]atomic.Int64
+	)
+	for k := range keys {
+		for range perKey {
+			wg.Go(func() {
+				r := httptest.NewRequest("GET", "/users", nil)
+				r.Header.Set("X-API-Key", fmt.Sprintf("key-%d", k))
+				w := httptest.NewRecorder()
+				<-start
+				h.ServeHTTP(w, r)
+				switch w.Code {
+				case http.StatusOK:
+					ok[k].Add(1)
+				case http.StatusTooManyRequests:
+					limit[k].Add(1)
+					if secs, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || secs < 1 {
+						t.Errorf("Retry-After = %q, want whole seconds", w.Header().Get("Retry-After"))
+					}
+				default:
+					t.Errorf("status = %d, want 200 or 429", w.Code)
+				}
+			})
+		}
+	}
+	close(start)
+	wg.Wait()
+
+	for k := range keys {
+		if ok[k].Load() != burst || limit[k].Load() != rejected {
+			t.Errorf("key-%d: %d allowed and %d limited, want %d and %d", k, ok[k].Load(), limit[k].Load(), burst, rejected)
+		}
+	}
+	if got := handled.Load(); got != keys*burst {
+		t.Errorf("handler ran %d times, want %d", got, keys*burst)
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	h := middleware.RateLimit(middleware.WithRate(0.001, 1))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	send := func(remote string) int {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+	if got := send("10.0.0.1:1111"); got != http.StatusOK {
+		t.Fatalf("first request = %d, want 200", got)
+	}
+	// the port changes for every connection, the bucket is the IP's
+	if got := send("10.0.0.1:2222"); got != http.StatusTooManyRequests {
+		t.Errorf("same IP, new port = %d, want 429", got)
+	}
+	if got := send("10.0.0.2:1111"); got != http.StatusOK {
+		t.Errorf("another IP = %d, want 200", got)
+	}
+}
+
+func TestRateLimitZeroBurst(t *testing.T) {
+	h := middleware.RateLimit(middleware.WithRate(10, 0))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Error("a request got through a zero burst")
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("status = %d, Retry-After = %q, want 429 with a Retry-After", w.Code, w.Header().Get("Retry-After"))
+	}
+}
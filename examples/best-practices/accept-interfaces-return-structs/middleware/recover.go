@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/safe"
+)
+
+// Recover turns a panicking handler into a 500 and logs the panic with its
+// stack, so one bad request cannot take down the process. If the handler
+// had already started the response, the status can no longer change and
+// the connection is aborted instead. The panic is reported to the
+// safe.OnPanic hooks like any other recovered one.
+func Recover(logger *slog.Logger) Middleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := newRecorder(w)
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				// ErrAbortHandler is net/http's own way to abort, let it through
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				pe := safe.Recovered(v)
+				logger.ErrorContext(r.Context(), "panic serving request",
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("panic", fmt.Sprint(pe.Value)),
+					slog.String("stack", string(pe.Stack)),
+				)
+				if rec.status != 0 {
+					panic(http.ErrAbortHandler)
+				}
+				http.Error(rec, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
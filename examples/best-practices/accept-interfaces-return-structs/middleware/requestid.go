@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/idgen"
+)
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds client supplied IDs, they end up in every log line.
+const maxRequestIDLen = 128
+
+// RequestID stores a request ID in the context with ctxutil.WithRequestID,
+// where the logged store and Logger pick it up, and echoes it in the
+// response header. A well formed incoming X-Request-ID is kept so an ID
+// assigned by a proxy or the caller traces through, otherwise gen mints one.
+func RequestID(gen idgen.Generator) Middleware {
+	if gen == nil {
+		gen = idgen.UUIDv4{}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = gen.NewID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+			next.ServeHTTP(w, r.WithContext(ctxutil.WithRequestID(r.Context(), id)))
+		})
+	}
+}
+
+// validRequestID accepts short printable ASCII, nothing that could forge a
+// log line or header.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+)
+
+// TenantHeader names the tenant a request acts for.
+const TenantHeader = "X-Tenant-ID"
+
+// maxTenantLen bounds a tenant ID, it is stored with every user.
+const maxTenantLen = 64
+
+// Tenant stores the request's X-Tenant-ID in the context with
+// ctxutil.WithTenant, where the stores that keep tenants apart read it,
+// see db/tenanted. A request without one, or with one that is not short
+// printable ASCII, is answered 400 and goes no further.
+//
+// The header is taken at its word. Put Tenant behind whatever proves the
+// caller belongs to the tenant, a gateway that sets the header itself or a
+// check of the token's claims, never in front of clients that pick their
+// own.
+func Tenant() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(TenantHeader)
+			if !validTenant(id) {
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ctxutil.WithTenant(r.Context(), id)))
+		})
+	}
+}
+
+// validTenant accepts the same characters as validRequestID, up to
+// maxTenantLen of them.
+func validTenant(id string) bool {
+	return len(id) <= maxTenantLen && validRequestID(id)
+}
@@ -0,0 +1,46 @@
+package middleware_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/middleware"
+)
+
+// TestTenant checks the middleware puts X-Tenant-ID in the context and
+// refuses a request without a usable one.
+func TestTenant(t *testing.T) {
+	h := middleware.Tenant()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, _ := ctxutil.Tenant(r.Context())
+		fmt.Fprint(w, tenant)
+	}))
+	for _, tt := range []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"tenant", "acme", http.StatusOK},
+		{"missing", "", http.StatusBadRequest},
+		{"space", "ac me", http.StatusBadRequest},
+		{"too long", strings.Repeat("a", 65), http.StatusBadRequest},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/users", nil)
+			if tt.header != "" {
+				r.Header.Set(middleware.TenantHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("X-Tenant-ID %q = %d, want %d", tt.header, w.Code, tt.status)
+			}
+			if tt.status == http.StatusOK && w.Body.String() != tt.header {
+				t.Errorf("the handler saw tenant %q, want %q", w.Body.String(), tt.header)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Timing reports how long the handler took before it started responding in
+// a Server-Timing header, which browser dev tools show next to the request.
+// It measures up to the first byte, the header cannot wait for the body.
+func Timing() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := newRecorder(w)
+			prev := rec.beforeWrite
+			rec.beforeWrite = func(w http.ResponseWriter) {
+				if prev != nil {
+					prev(w)
+				}
+				ms := float64(time.Since(start).Microseconds()) / 1000
+				w.Header().Add("Server-Timing", fmt.Sprintf("app;dur=%.3f", ms))
+			}
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
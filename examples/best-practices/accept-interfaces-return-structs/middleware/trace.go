@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Trace starts a server span for each request, continuing the caller's trace
+// when the request carries a traceparent header. Spans go to the global
+// TracerProvider, see tracing.Setup. Put it first in the Chain so the span
+// covers the rest of the middleware.
+func Trace(operation string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return otelhttp.NewHandler(next, operation, otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			return operation + " " + r.Method
+		}))
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+)
+
+// Traceparent stores the trace ID of a W3C traceparent header in the
+// context with ctxutil.WithTraceID, starting a new trace when the header is
+// missing or malformed. Unlike the request ID, which is this service's own,
+// the trace ID is shared with every service the request passes through.
+func Traceparent() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, ok := parseTraceparent(r.Header.Get("traceparent"))
+			if !ok {
+				id = newTraceID()
+			}
+			next.ServeHTTP(w, r.WithContext(ctxutil.WithTraceID(r.Context(), id)))
+		})
+	}
+}
+
+// parseTraceparent extracts the trace ID from "00-<trace id>-<parent id>-<flags>".
+func parseTraceparent(header string) (string, bool) {
+	parts := strings.Split(header, "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return "", false
+	}
+	// version ff is invalid and an all zero trace ID means none
+	if parts[0] == "ff" || strings.Trim(parts[1], "0") == "" {
+		return "", false
+	}
+	if _, err := hex.DecodeString(parts[1]); err != nil {
+		return "", false
+	}
+	return strings.ToLower(parts[1]), true
+}
+
+func newTraceID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,438 @@
+// Package migrate applies a store's schema changes in order, from SQL files
+// embedded next to the store, and records each version it applies in a
+// schema_versions table so every database knows what it has run:
+//
+//	0001_create_users.up.sql
+//	0001_create_users.down.sql
+//	0002_unique_email.up.sql
+//
+// Versions are run in numeric order and need not be consecutive. A down
+// file is optional, a version without one cannot be rolled back.
+//
+// Each migration runs in a transaction with its schema_versions row, so a
+// database has it applied or not, never half. On Postgres a run holds an
+// advisory lock, so instances starting together apply each version once.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"maps"
+	"math"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/query"
+)
+
+// ErrIrreversible is a Down that reaches a version without a down file.
+var ErrIrreversible = errors.New("migration has no down file")
+
+// Migration is one version of a schema.
+type Migration struct {
+	Version int
+	// Name is the file name between the version and the direction
+	Name string
+	Up   string
+	// Down is empty when the version has no down file
+	Down string
+}
+
+// Status is a version as the database sees it.
+type Status struct {
+	Version int
+	Name    string
+	// AppliedAt is zero for a version not applied yet.
+	AppliedAt time.Time
+	// Unknown is a version the database has applied that no file is for,
+	// from a newer build or a file since removed.
+	Unknown bool
+}
+
+// Option configures a Migrator.
+type Option func(*Migrator)
+
+// WithDialect sets the SQL flavour of the database, the default is
+// query.SQLite. query.Postgres also takes an advisory lock for each run.
+func WithDialect(d query.Dialect) Option {
+	return func(m *Migrator) {
+		m.dialect = d
+	}
+}
+
+// WithTable replaces schema_versions as the table versions are recorded in.
+func WithTable(name string) Option {
+	return func(m *Migrator) {
+		m.table = name
+	}
+}
+
+// WithLegacyTable adopts a table of applied file names kept before this
+// package, one name column of paths like migrations/0003_timestamps.sql.
+// The first run records the versions it names as applied and drops it.
+func WithLegacyTable(name string) Option {
+	return func(m *Migrator) {
+		m.legacy = name
+	}
+}
+
+// WithClock sets the clock applied_at is read from, the default is
+// clock.Real.
+func WithClock(c clock.Clock) Option {
+	return func(m *Migrator) {
+		m.clock = c
+	}
+}
+
+// Migrator applies one set of migrations to one database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	dialect    query.Dialect
+	table      string
+	legacy     string
+	clock      clock.Clock
+}
+
+// identifier is what a table name may be, it is written into statements.
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// New loads the migrations at the root of fsys, fs.Sub one directory out
+// of an embed.FS, for db. A file that is not named for a version and
+// direction, or a version without an up file, is errs.ErrInvalidInput.
+func New(db *sql.DB, fsys fs.FS, opts ...Option) (*Migrator, error) {
+	m := &Migrator{db: db, table: "schema_versions", clock: clock.Real}
+	for _, opt := range opts {
+		opt(m)
+	}
+	for _, table := range []string{m.table, m.legacy} {
+		if table != "" && !identifier.MatchString(table) {
+			return nil, errs.Wrap("migrate.New", fmt.Errorf("table name %q: %w", table, errs.ErrInvalidInput))
+		}
+	}
+	migrations, err := load(fsys)
+	if err != nil {
+		return nil, errs.Wrap("migrate.New", err)
+	}
+	m.migrations = migrations
+	return m, nil
+}
+
+// load reads and orders the .sql files at the root of fsys.
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		file := entry.Name()
+		if entry.IsDir() || path.Ext(file) != ".sql" {
+			continue
+		}
+		base := strings.TrimSuffix(file, ".sql")
+		base, up := strings.CutSuffix(base, ".up")
+		if !up {
+			var down bool
+			if base, down = strings.CutSuffix(base, ".down"); !down {
+				return nil, fmt.Errorf("%s: want version_name.up.sql or .down.sql: %w", file, errs.ErrInvalidInput)
+			}
+		}
+		digits, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(digits)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%s: %q is not a version: %w", file, digits, errs.ErrInvalidInput)
+		}
+		body, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: name}
+			byVersion[version] = mig
+		}
+		if mig.Name != name {
+			return nil, fmt.Errorf("%s: version %d is also named %q: %w", file, version, mig.Name, errs.ErrInvalidInput)
+		}
+		if up {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("version %d %s has no up file: %w", mig.Version, mig.Name, errs.ErrInvalidInput)
+		}
+		migrations = append(migrations, *mig)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+	return migrations, nil
+}
+
+// Migrations returns every migration, in version order.
+func (m *Migrator) Migrations() []Migration {
+	return slices.Clone(m.migrations)
+}
+
+// Up applies every migration not applied yet, in version order, and
+// returns those it applied. It stops at the first that fails, the ones
+// before it stay applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied, err := m.up(ctx, math.MaxInt)
+	return applied, errs.Wrap("migrate.Migrator.Up", err)
+}
+
+// UpTo is Up for the versions up to and including version.
+func (m *Migrator) UpTo(ctx context.Context, version int) ([]Migration, error) {
+	applied, err := m.up(ctx, version)
+	return applied, errs.Wrap("migrate.Migrator.UpTo", err)
+}
+
+func (m *Migrator) up(ctx context.Context, target int) ([]Migration, error) {
+	var applied []Migration
+	err := m.session(ctx, func(conn *sql.Conn) error {
+		done, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if mig.Version > target {
+				break
+			}
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, mig, true); err != nil {
+				return err
+			}
+			applied = append(applied, mig)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the steps most recently applied versions, newest first,
+// and returns those it rolled back. Every one must have a down file, or
+// nothing is rolled back and the error is ErrIrreversible.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.session(ctx, func(conn *sql.Conn) error {
+		done, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versions := slices.Sorted(maps.Keys(done))
+		slices.Reverse(versions)
+		versions = versions[:min(max(steps, 0), len(versions))]
+		plan := make([]Migration, 0, len(versions))
+		for _, version := range versions {
+			i := slices.IndexFunc(m.migrations, func(mig Migration) bool { return mig.Version == version })
+			if i < 0 {
+				return fmt.Errorf("version %d %s is applied but has no files: %w", version, done[version].Name, errs.ErrNotFound)
+			}
+			if m.migrations[i].Down == "" {
+				return fmt.Errorf("version %d %s: %w", version, m.migrations[i].Name, ErrIrreversible)
+			}
+			plan = append(plan, m.migrations[i])
+		}
+		for _, mig := range plan {
+			if err := m.apply(ctx, conn, mig, false); err != nil {
+				return err
+			}
+			reverted = append(reverted, mig)
+		}
+		return nil
+	})
+	return reverted, errs.Wrap("migrate.Migrator.Down", err)
+}
+
+// Status lists every version, the migrations' and any others the database
+// has applied, in version order.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.session(ctx, func(conn *sql.Conn) error {
+		done, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			status := Status{Version: mig.Version, Name: mig.Name}
+			if row, ok := done[mig.Version]; ok {
+				status.AppliedAt = row.AppliedAt
+				delete(done, mig.Version)
+			}
+			statuses = append(statuses, status)
+		}
+		for _, row := range done {
+			row.Unknown = true
+			statuses = append(statuses, row)
+		}
+		slices.SortFunc(statuses, func(a, b Status) int { return a.Version - b.Version })
+		return nil
+	})
+	return statuses, errs.Wrap("migrate.Migrator.Status", err)
+}
+
+// session runs fn on one connection, with the versions table in place and
+// on Postgres the advisory lock held.
+func (m *Migrator) session(ctx context.Context, fn func(*sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if m.dialect == query.Postgres {
+		key := m.lockKey()
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, key); err != nil {
+			return err
+		}
+		// unlocked even when ctx is what ended the run
+		defer conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, key)
+	}
+	if err := m.ensure(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// lockKey is the advisory lock for the versions table, migrators of other
+// tables in the same database do not wait on each other.
+func (m *Migrator) lockKey() int64 {
+	h := fnv.New64a()
+	h.Write([]byte("migrate." + m.table))
+	return int64(h.Sum64())
+}
+
+// ensure creates the versions table and adopts the legacy one.
+func (m *Migrator) ensure(ctx context.Context, conn *sql.Conn) error {
+	timestamp := "DATETIME"
+	if m.dialect == query.Postgres {
+		timestamp = "TIMESTAMPTZ"
+	}
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+m.table+` (
+		version    BIGINT PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at `+timestamp+` NOT NULL
+	)`)
+	if err != nil || m.legacy == "" {
+		return err
+	}
+	exists := `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`
+	if m.dialect == query.Postgres {
+		exists = `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1`
+	}
+	var n int
+	if err := conn.QueryRowContext(ctx, exists, m.legacy).Scan(&n); err != nil || n == 0 {
+		return err
+	}
+	return m.inTx(ctx, conn, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT name FROM `+m.legacy)
+		if err != nil {
+			return err
+		}
+		var names []string
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				rows.Close()
+				return err
+			}
+			names = append(names, name)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, file := range names {
+			digits, name, _ := strings.Cut(strings.TrimSuffix(path.Base(file), ".sql"), "_")
+			version, err := strconv.Atoi(digits)
+			if err != nil {
+				return fmt.Errorf("%s row %q is not a version: %w", m.legacy, file, errs.ErrInvalidInput)
+			}
+			if err := m.record(ctx, tx, version, name); err != nil {
+				return err
+			}
+		}
+		_, err = tx.ExecContext(ctx, `DROP TABLE `+m.legacy)
+		return err
+	})
+}
+
+// applied reads the versions table by version.
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int]Status, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, applied_at FROM `+m.table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	done := make(map[int]Status)
+	for rows.Next() {
+		var s Status
+		if err := rows.Scan(&s.Version, &s.Name, &s.AppliedAt); err != nil {
+			return nil, err
+		}
+		done[s.Version] = s
+	}
+	return done, rows.Err()
+}
+
+// apply runs one direction of mig and records it, in one transaction.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mig Migration, up bool) error {
+	err := m.inTx(ctx, conn, func(tx *sql.Tx) error {
+		body := mig.Down
+		if up {
+			body = mig.Up
+		}
+		if strings.TrimSpace(body) != "" {
+			if _, err := tx.ExecContext(ctx, body); err != nil {
+				return err
+			}
+		}
+		if up {
+			return m.record(ctx, tx, mig.Version, mig.Name)
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM `+m.table+` WHERE version = `+m.dialect.Placeholder(1), mig.Version)
+		return err
+	})
+	if err != nil {
+		direction := "down"
+		if up {
+			direction = "up"
+		}
+		return fmt.Errorf("%04d_%s %s: %w", mig.Version, mig.Name, direction, err)
+	}
+	return nil
+}
+
+func (m *Migrator) record(ctx context.Context, tx *sql.Tx, version int, name string) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO `+m.table+` (version, name, applied_at) VALUES (`+m.dialect.Placeholder(1)+`, `+m.dialect.Placeholder(2)+`, `+m.dialect.Placeholder(3)+`)`,
+		version, name, m.clock.Now().UTC())
+	return err
+}
+
+func (m *Migrator) inTx(ctx context.Context, conn *sql.Conn, fn func(*sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
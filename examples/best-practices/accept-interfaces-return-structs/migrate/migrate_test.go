@@ -0,0 +1,192 @@
+package migrate_test
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/migrate"
+)
+
+// files is a small schema, version 10 without a down file.
+var files = fstest.MapFS{
+	"0001_create_notes.up.sql":   {Data: []byte("CREATE TABLE notes (id INTEGER PRIMARY KEY);")},
+	"0001_create_notes.down.sql": {Data: []byte("DROP TABLE notes;")},
+	"0002_body.up.sql":           {Data: []byte("ALTER TABLE notes ADD COLUMN body TEXT NOT NULL DEFAULT '';")},
+	"0002_body.down.sql":         {Data: []byte("ALTER TABLE notes DROP COLUMN body;")},
+	"0010_tags.up.sql":           {Data: []byte("CREATE TABLE tags (name TEXT PRIMARY KEY);\nCREATE INDEX tags_name ON tags (name);")},
+	"README.md":                  {Data: []byte("not a migration")},
+}
+
+// openMemory opens a throwaway database, on one connection so every
+// statement sees the same one.
+func openMemory(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newMigrator(t *testing.T, db *sql.DB, fsys fstest.MapFS, opts ...migrate.Option) *migrate.Migrator {
+	t.Helper()
+	m, err := migrate.New(db, fsys, opts...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return m
+}
+
+// versions lists m's versions, + for applied, - for pending and ? for
+// applied but not in the files.
+func versions(t *testing.T, m *migrate.Migrator) string {
+	t.Helper()
+	statuses, err := m.Status(t.Context())
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	var b strings.Builder
+	for _, s := range statuses {
+		mark := "-"
+		if !s.AppliedAt.IsZero() {
+			mark = "+"
+		}
+		if s.Unknown {
+			mark = "?"
+		}
+		fmt.Fprintf(&b, "%s%d ", mark, s.Version)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// TestUp checks Up applies the versions in order, once, stamped by the
+// migrator's clock.
+func TestUp(t *testing.T) {
+	ctx := t.Context()
+	db := openMemory(t)
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	fake := clock.NewFake(start)
+	m := newMigrator(t, db, files, migrate.WithClock(fake))
+	applied, err := m.UpTo(ctx, 2)
+	if err != nil || len(applied) != 2 || applied[1].Name != "body" {
+		t.Fatalf("UpTo(2) = %v, %v, want 1 and 2", applied, err)
+	}
+	fake.Advance(time.Hour)
+	if applied, err = m.Up(ctx); err != nil || len(applied) != 1 || applied[0].Version != 10 {
+		t.Fatalf("Up = %v, %v, want only 10", applied, err)
+	}
+	if applied, err = m.Up(ctx); err != nil || len(applied) != 0 {
+		t.Errorf("a second Up = %v, %v, want nothing", applied, err)
+	}
+	if got := versions(t, m); got != "+1 +2 +10" {
+		t.Errorf("status %s, want +1 +2 +10", got)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if !statuses[0].AppliedAt.Equal(start) || !statuses[2].AppliedAt.Equal(start.Add(time.Hour)) {
+		t.Errorf("applied at %s and %s, want the fake clock's", statuses[0].AppliedAt, statuses[2].AppliedAt)
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO tags (name) VALUES ('go')`); err != nil {
+		t.Errorf("insert into the migrated table: %v", err)
+	}
+}
+
+// TestDown checks Down rolls back newest first and refuses a version
+// without a down file.
+func TestDown(t *testing.T) {
+	ctx := t.Context()
+	db := openMemory(t)
+	m := newMigrator(t, db, files)
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if reverted, err := m.Down(ctx, 2); !errors.Is(err, migrate.ErrIrreversible) || len(reverted) != 0 {
+		t.Fatalf("Down(2) = %v, %v, want ErrIrreversible and nothing rolled back", reverted, err)
+	}
+	// undo 10 by hand, as its author would have to
+	for _, stmt := range []string{`DROP TABLE tags`, `DELETE FROM schema_versions WHERE version = 10`} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	if reverted, err := m.Down(ctx, 1); err != nil || len(reverted) != 1 || reverted[0].Version != 2 {
+		t.Fatalf("Down(1) = %v, %v, want 2", reverted, err)
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO notes (body) VALUES ('x')`); err == nil {
+		t.Error("notes still has body after version 2 was rolled back")
+	}
+	if got := versions(t, m); got != "+1 -2 -10" {
+		t.Errorf("status %s, want +1 -2 -10", got)
+	}
+}
+
+// TestFailedVersion checks a failing migration leaves nothing of itself
+// behind.
+func TestFailedVersion(t *testing.T) {
+	db := openMemory(t)
+	m := newMigrator(t, db, fstest.MapFS{
+		"0001_create_notes.up.sql": files["0001_create_notes.up.sql"],
+		"0002_half.up.sql":         {Data: []byte("CREATE TABLE half (id INTEGER);\nINSERT INTO missing VALUES (1);")},
+	})
+	if applied, err := m.Up(t.Context()); err == nil || len(applied) != 1 {
+		t.Fatalf("Up = %v, %v, want version 1 applied and 2 failed", applied, err)
+	}
+	if _, err := db.ExecContext(t.Context(), `SELECT * FROM half`); err == nil {
+		t.Error("the failed version's table was kept")
+	}
+	if got := versions(t, m); got != "+1 -2" {
+		t.Errorf("status %s, want +1 -2", got)
+	}
+}
+
+// TestUnknownVersion checks a version the files do not have is reported,
+// not touched.
+func TestUnknownVersion(t *testing.T) {
+	db := openMemory(t)
+	if _, err := newMigrator(t, db, files).Up(t.Context()); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	older := newMigrator(t, db, fstest.MapFS{
+		"0001_create_notes.up.sql": files["0001_create_notes.up.sql"],
+		"0002_body.up.sql":         files["0002_body.up.sql"],
+	})
+	if got := versions(t, older); got != "+1 +2 ?10" {
+		t.Errorf("status %s, want +1 +2 ?10", got)
+	}
+	if _, err := older.Down(t.Context(), 1); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Down of version 10: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestNewRefused(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		fsys fstest.MapFS
+		opts []migrate.Option
+	}{
+		{"no direction", fstest.MapFS{"0001_notes.sql": {}}, nil},
+		{"no version", fstest.MapFS{"notes.up.sql": {}}, nil},
+		{"down only", fstest.MapFS{"0001_notes.down.sql": {Data: []byte("DROP TABLE notes;")}}, nil},
+		{"two names", fstest.MapFS{"0001_a.up.sql": {Data: []byte("SELECT 1;")}, "0001_b.down.sql": {}}, nil},
+		{"injected table name", files, []migrate.Option{migrate.WithTable("versions; DROP TABLE users")}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := migrate.New(nil, tt.fsys, tt.opts...); !errors.Is(err, errs.ErrInvalidInput) {
+				t.Errorf("New: err = %v, want ErrInvalidInput", err)
+			}
+		})
+	}
+}
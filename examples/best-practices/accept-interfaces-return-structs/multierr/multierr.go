@@ -0,0 +1,153 @@
+// Package multierr is errors.Join for batches: every failure is kept
+// under the key of the item it is for, a line of a file, a check's name,
+// and the whole is one error that errors.Is and errors.As look through to
+// each, the way they do a joined error.
+//
+// Errors that already name their item, like service.ItemError, are kept
+// as they are with Append. Others get a key with Add. Summary formats any
+// error holding several, these or errors.Join's, one failure a line.
+package multierr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Error is one item's failure.
+type Error struct {
+	Key string
+	Err error
+}
+
+func (e *Error) Error() string {
+	return e.Key + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Errors collects the failures of a batch. The zero value is empty and
+// ready to use, and it is not safe for concurrent use.
+type Errors struct {
+	errs []error
+	// omitted counts failures that were counted and not kept
+	omitted int
+}
+
+// Add keeps err under key, as an *Error. A nil err is not a failure and is
+// skipped, so a loop can Add every item's result.
+func (e *Errors) Add(key string, err error) {
+	if err != nil {
+		e.errs = append(e.errs, &Error{Key: key, Err: err})
+	}
+}
+
+// Append keeps errs as they are, skipping nils.
+func (e *Errors) Append(errs ...error) {
+	for _, err := range errs {
+		if err != nil {
+			e.errs = append(e.errs, err)
+		}
+	}
+}
+
+// Omit counts n failures that a caller with a bound on what it holds did
+// not keep, so the message and Len still tell how many there were.
+func (e *Errors) Omit(n int) {
+	e.omitted += max(n, 0)
+}
+
+// Len counts the failures, the omitted ones included.
+func (e *Errors) Len() int {
+	return len(e.errs) + e.omitted
+}
+
+// Err returns e as an error, nil when it holds no failures, which is what
+// a function returning the batch's error wants. e must not change after.
+func (e *Errors) Err() error {
+	if e.Len() == 0 {
+		return nil
+	}
+	return e
+}
+
+// Unwrap returns the failures kept, in the order they were added.
+func (e *Errors) Unwrap() []error {
+	return e.errs
+}
+
+// Error is the failures on one line, "; " between them. Summary formats
+// them a line each.
+func (e *Errors) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s: ", e.Len(), plural(e.Len()))
+	for i, err := range e.errs {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(err.Error())
+	}
+	if e.omitted > 0 {
+		if len(e.errs) > 0 {
+			b.WriteString("; ")
+		}
+		fmt.Fprintf(&b, "%d more not kept", e.omitted)
+	}
+	return b.String()
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return "failure"
+	}
+	return "failures"
+}
+
+// Flatten returns the failures in err, following errors.Join and Errors
+// down to errors that do not hold several, in order. An error that holds
+// one, wrapped or not, is one failure, and nil is none.
+func Flatten(err error) []error {
+	if err == nil {
+		return nil
+	}
+	multi, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var flat []error
+	for _, err := range multi.Unwrap() {
+		flat = append(flat, Flatten(err)...)
+	}
+	return flat
+}
+
+// Summary formats err for a person, a count and then each failure on a
+// line of its own, at most limit of them, zero or less meaning all. A
+// failure's message spanning lines is indented under its first.
+func Summary(err error, limit int) string {
+	if err == nil {
+		return "no failures"
+	}
+	flat := Flatten(err)
+	total := len(flat)
+	var e *Errors
+	if errors.As(err, &e) {
+		total += e.omitted
+	}
+	shown := flat
+	if limit > 0 && len(shown) > limit {
+		shown = shown[:limit]
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s:", total, plural(total))
+	for _, err := range shown {
+		b.WriteString("\n  - ")
+		b.WriteString(strings.ReplaceAll(err.Error(), "\n", "\n    "))
+	}
+	if rest := total - len(shown); rest > 0 {
+		fmt.Fprintf(&b, "\n  ... and %d more", rest)
+	}
+	return b.String()
+}
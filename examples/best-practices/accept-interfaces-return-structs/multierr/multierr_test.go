@@ -0,0 +1,34 @@
+package multierr_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/multierr"
+)
+
+func TestErrorsEmpty(t *testing.T) {
+	var none multierr.Errors
+	none.Add("a", nil)
+	none.Append(nil, nil)
+	if err := none.Err(); err != nil {
+		t.Errorf("Err = %v, want nil", err)
+	}
+}
+
+// TestJoin checks Flatten and Summary read errors.Join as well.
+func TestJoin(t *testing.T) {
+	a, b, c := errors.New("a"), errors.New("b"), errors.New("c\nsecond line")
+	joined := errors.Join(a, errors.Join(b, nil), fmt.Errorf("wrapped: %w", c))
+	if flat := multierr.Flatten(joined); len(flat) != 3 || flat[0] != a || flat[1] != b || !errors.Is(flat[2], c) {
+		t.Errorf("Flatten = %v, want a, b, and the wrapped c", flat)
+	}
+	want := "3 failures:\n  - a\n  - b\n  - wrapped: c\n    second line"
+	if got := multierr.Summary(joined, 0); got != want {
+		t.Errorf("Summary = %q, want %q", got, want)
+	}
+	if got := multierr.Summary(nil, 0); got != "no failures" {
+		t.Errorf("Summary(nil) = %q, want no failures", got)
+	}
+}
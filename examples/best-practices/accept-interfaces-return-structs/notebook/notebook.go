@@ -0,0 +1,162 @@
+// Package notebook finds the runnable examples in the repository for
+// cmd/notebook. An example is any package main under examples/, each
+// example module's own main and every cmd/ demo in it, described by its
+// doc comment: the package's, or else the one on func main, which is
+// where the demos keep theirs.
+//
+// Examples are found by reading the source, nothing is built or imported,
+// so examples in other modules, on other Go versions, are found alike.
+package notebook
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// Example is one runnable package.
+type Example struct {
+	// Name is Dir relative to examples/ with forward slashes, like
+	// "best-practices/accept-interfaces-return-structs/cmd/tls".
+	Name string
+	Dir  string
+	// Doc is the whole doc comment, empty when there is none.
+	Doc string
+}
+
+// Synopsis is the first sentence of Doc.
+func (e Example) Synopsis() string {
+	return new(doc.Package).Synopsis(e.Doc)
+}
+
+// Root is the nearest of dir and its parents holding an examples
+// directory, the repository root.
+func Root(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", errs.Wrap("notebook.Root", err)
+	}
+	for {
+		if info, err := os.Stat(filepath.Join(dir, "examples")); err == nil && info.IsDir() {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errs.Wrap("notebook.Root", fmt.Errorf("no examples directory above the working directory: %w", errs.ErrNotFound))
+		}
+		dir = parent
+	}
+}
+
+// Discover returns every example under root's examples directory, sorted
+// by name. A package main whose func main is empty, like a Go plugin, has
+// nothing to run and is left out.
+func Discover(root string) ([]Example, error) {
+	base := filepath.Join(root, "examples")
+	var examples []Example
+	err := filepath.WalkDir(base, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if name := d.Name(); path != base && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor" || name == "node_modules") {
+			return filepath.SkipDir
+		}
+		e, ok, err := inspect(path)
+		if err != nil || !ok {
+			return err
+		}
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		e.Name = filepath.ToSlash(rel)
+		examples = append(examples, e)
+		return nil
+	})
+	if err != nil {
+		return nil, errs.Wrap("notebook.Discover", err)
+	}
+	slices.SortFunc(examples, func(a, b Example) int { return strings.Compare(a.Name, b.Name) })
+	return examples, nil
+}
+
+// inspect reads the package in dir, reporting whether it is a runnable
+// package main. go/build applies the build constraints, so a file only
+// built with a tag does not decide the package's doc comment.
+func inspect(dir string) (Example, bool, error) {
+	pkg, err := build.ImportDir(dir, 0)
+	var noGo *build.NoGoError
+	if errors.As(err, &noGo) {
+		return Example{}, false, nil
+	}
+	if err != nil {
+		return Example{}, false, err
+	}
+	if pkg.Name != "main" {
+		return Example{}, false, nil
+	}
+	e := Example{Dir: dir}
+	runnable := false
+	fset := token.NewFileSet()
+	for _, name := range pkg.GoFiles {
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return Example{}, false, err
+		}
+		if f.Doc != nil {
+			e.Doc = f.Doc.Text()
+		}
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || fn.Name.Name != "main" {
+				continue
+			}
+			runnable = fn.Body != nil && len(fn.Body.List) > 0
+			if e.Doc == "" && fn.Doc != nil {
+				e.Doc = fn.Doc.Text()
+			}
+		}
+	}
+	return e, runnable, nil
+}
+
+// Find returns the example called name, or else the one example whose
+// name ends in "/"+name, so "accept-interfaces-return-structs" and
+// "cmd/tls" are enough to pick one out.
+func Find(examples []Example, name string) (Example, error) {
+	name = strings.Trim(filepath.ToSlash(name), "/")
+	var matches []Example
+	for _, e := range examples {
+		if e.Name == name {
+			return e, nil
+		}
+		if strings.HasSuffix(e.Name, "/"+name) {
+			matches = append(matches, e)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Example{}, errs.Wrap("notebook.Find", fmt.Errorf("no example %q: %w", name, errs.ErrNotFound))
+	case 1:
+		return matches[0], nil
+	}
+	names := make([]string, len(matches))
+	for i, e := range matches {
+		names[i] = e.Name
+	}
+	return Example{}, errs.Wrap("notebook.Find", fmt.Errorf("%q could be any of %s: %w", name, strings.Join(names, ", "), errs.ErrInvalidInput))
+}
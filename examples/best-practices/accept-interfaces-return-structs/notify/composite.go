@@ -0,0 +1,25 @@
+package notify
+
+import (
+	"context"
+	"errors"
+)
+
+// Composite is a Sender that sends through every one of senders, in order.
+// One failing does not stop the others, their errors are joined, so a
+// user with email and SMS still gets the email while the gateway is down.
+func Composite(senders ...Sender) Sender {
+	return SenderFunc(func(ctx context.Context, to Recipient, msg Message) error {
+		var sendErrs []error
+		for _, s := range senders {
+			if err := ctx.Err(); err != nil {
+				sendErrs = append(sendErrs, err)
+				break
+			}
+			if err := s.Send(ctx, to, msg); err != nil {
+				sendErrs = append(sendErrs, err)
+			}
+		}
+		return errors.Join(sendErrs...)
+	})
+}
@@ -0,0 +1,138 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+type EmailOption func(*Email)
+
+// WithSMTPAuth authenticates with auth when the server offers AUTH.
+// smtp.PlainAuth only sends the password over TLS or to localhost.
+func WithSMTPAuth(auth smtp.Auth) EmailOption {
+	return func(e *Email) {
+		e.auth = auth
+	}
+}
+
+// WithTLSConfig is used for STARTTLS, the default verifies the server
+// against the host in addr.
+func WithTLSConfig(cfg *tls.Config) EmailOption {
+	return func(e *Email) {
+		e.tls = cfg
+	}
+}
+
+// Email is the SMTP strategy, a plain text message to Recipient.Email.
+type Email struct {
+	addr string
+	from string
+	auth smtp.Auth
+	tls  *tls.Config
+}
+
+// NewEmail sends through the SMTP server at addr, host:port, from the
+// address from.
+func NewEmail(addr, from string, opts ...EmailOption) *Email {
+	e := &Email{addr: addr, from: from}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Send holds one SMTP session per message. net/smtp takes no context, so
+// ctx bounds the dial and its deadline the whole conversation.
+func (e *Email) Send(ctx context.Context, to Recipient, msg Message) error {
+	if to.Email == "" {
+		return errs.Wrap("notify.Email.Send", ErrNoAddress)
+	}
+	data, err := e.format(to.Email, msg)
+	if err != nil {
+		return errs.Wrap("notify.Email.Send", err)
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", e.addr)
+	if err != nil {
+		return errs.Wrap("notify.Email.Send", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	host, _, _ := net.SplitHostPort(e.addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return errs.Wrap("notify.Email.Send", err)
+	}
+	defer c.Close()
+	if err := e.deliver(c, host, to.Email, data); err != nil {
+		return errs.Wrap("notify.Email.Send", err)
+	}
+	return nil
+}
+
+func (e *Email) deliver(c *smtp.Client, host, to string, data []byte) error {
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		cfg := e.tls
+		if cfg == nil {
+			cfg = &tls.Config{ServerName: host}
+		}
+		if err := c.StartTLS(cfg); err != nil {
+			return err
+		}
+	}
+	if ok, _ := c.Extension("AUTH"); ok && e.auth != nil {
+		if err := c.Auth(e.auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(e.from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// format writes the headers and body. A line break in an address or the
+// subject would let it add headers of its own, so those are refused.
+func (e *Email) format(to string, msg Message) ([]byte, error) {
+	for _, v := range []string{e.from, to, msg.Subject, msg.Kind} {
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, fmt.Errorf("line break in a header: %w", errs.ErrInvalidInput)
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", e.from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	if msg.Kind != "" {
+		fmt.Fprintf(&b, "X-Message-Kind: %s\r\n", msg.Kind)
+	}
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	// the DATA writer turns bare \n into \r\n and escapes leading dots
+	b.WriteString(msg.Body)
+	return []byte(b.String()), nil
+}
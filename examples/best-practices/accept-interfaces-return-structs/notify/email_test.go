@@ -0,0 +1,69 @@
+package notify_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/notify"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/notify/notifytest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// smtpServer starts a loopback SMTP server the test closes when done.
+func smtpServer(t *testing.T) *notifytest.SMTPServer {
+	t.Helper()
+	s, err := notifytest.NewSMTPServer()
+	if err != nil {
+		t.Fatalf("NewSMTPServer: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestEmail(t *testing.T) {
+	server := smtpServer(t)
+	ada := &service.User{ID: "ada", Email: "ada@example.com", Name: "Ada"}
+	err := notify.NewEmail(server.Addr(), "hello@example.com").Send(context.Background(), notify.Recipient{Email: ada.Email}, notify.Welcome(ada))
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	mails := server.Mails()
+	if len(mails) != 1 {
+		t.Fatalf("%d mails, want 1", len(mails))
+	}
+	m := mails[0]
+	if m.From != "hello@example.com" || !slices.Equal(m.To, []string{"ada@example.com"}) {
+		t.Errorf("mail from %s to %v, want from hello@ to ada@", m.From, m.To)
+	}
+	for _, want := range []string{"Subject: Welcome aboard\n", "X-Message-Kind: user.welcome\n", "\n\nHi Ada, your account is ready."} {
+		if !strings.Contains(m.Data, want) {
+			t.Errorf("mail has no %q:\n%s", want, m.Data)
+		}
+	}
+}
+
+func TestEmailRefusedRecipient(t *testing.T) {
+	server := smtpServer(t)
+	server.RejectRcpt = func(addr string) bool { return addr == "gone@example.com" }
+	err := notify.NewEmail(server.Addr(), "hello@example.com").Send(context.Background(), notify.Recipient{Email: "gone@example.com"}, notify.Message{Subject: "hi"})
+	if err == nil || !strings.Contains(err.Error(), "550") {
+		t.Errorf("Send: err = %v, want the 550", err)
+	}
+	if n := len(server.Mails()); n != 0 {
+		t.Errorf("%d mails accepted, want the refused one dropped", n)
+	}
+}
+
+// TestEmailHeaderInjection checks a line break cannot add mail headers.
+func TestEmailHeaderInjection(t *testing.T) {
+	server := smtpServer(t)
+	msg := notify.Message{Subject: "hi\r\nBcc: everyone@example.com", Body: "x"}
+	err := notify.NewEmail(server.Addr(), "hello@example.com").Send(context.Background(), notify.Recipient{Email: "ada@example.com"}, msg)
+	if !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Send: err = %v, want ErrInvalidInput", err)
+	}
+}
@@ -0,0 +1,225 @@
+// Package notify delivers messages to users over whichever channels they
+// prefer. Each channel is a strategy behind the one Sender interface,
+// email over SMTP, SMS through a gateway's HTTP API, or a webhook the user
+// registered, and a Notifier picks among them per user:
+//
+//	notifier := notify.New(prefs,
+//		notify.WithSender(notify.ChannelEmail, notify.NewEmail("smtp.example.com:587", "hello@example.com")),
+//		notify.WithSender(notify.ChannelSMS, notify.NewSMS(client, "https://sms.example.com/v1/messages", "+15550100")),
+//		notify.WithSender(notify.ChannelWebhook, notify.NewWebhook(client)),
+//	)
+//	userService.OnUserCreated(notifier.Hook())
+//
+// A user who picked several channels is sent to through a Composite of
+// their senders. Adding a channel is a new Sender and one WithSender, the
+// Notifier and its callers do not change. notifytest has a fake for every
+// strategy.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// ErrNoAddress means the user has no address for the channel, a phone
+// number for SMS or a URL for webhooks.
+var ErrNoAddress = fmt.Errorf("no address for channel: %w", errs.ErrInvalidInput)
+
+// Channel names a delivery strategy.
+type Channel string
+
+const (
+	ChannelEmail   Channel = "email"
+	ChannelSMS     Channel = "sms"
+	ChannelWebhook Channel = "webhook"
+)
+
+// Message is what every Sender delivers, each in its own format.
+type Message struct {
+	// Kind names the message for receivers that route on it, like
+	// "user.welcome".
+	Kind    string
+	UserID  string
+	Subject string
+	Body    string
+}
+
+// Recipient is where a user is reached on each channel. A Sender reads
+// only its own address and fails with ErrNoAddress when it is empty.
+type Recipient struct {
+	Email   string
+	Phone   string
+	Webhook string
+}
+
+// Sender is one delivery strategy.
+type Sender interface {
+	Send(ctx context.Context, to Recipient, msg Message) error
+}
+
+// SenderFunc adapts a function to a Sender.
+type SenderFunc func(ctx context.Context, to Recipient, msg Message) error
+
+func (f SenderFunc) Send(ctx context.Context, to Recipient, msg Message) error {
+	return f(ctx, to, msg)
+}
+
+// Preference is how a user wants to be notified. No Channels means the
+// Notifier's default.
+type Preference struct {
+	Channels []Channel
+	Phone    string
+	Webhook  string
+}
+
+// Preferences looks up a user's Preference, errs.ErrNotFound when they
+// never set one.
+type Preferences interface {
+	Preference(ctx context.Context, userID string) (Preference, error)
+}
+
+// MemoryPreferences is a map backed Preferences, safe for concurrent use.
+type MemoryPreferences struct {
+	mu    sync.RWMutex
+	prefs map[string]Preference
+}
+
+func NewMemoryPreferences() *MemoryPreferences {
+	return &MemoryPreferences{prefs: make(map[string]Preference)}
+}
+
+func (m *MemoryPreferences) Set(userID string, pref Preference) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prefs[userID] = pref
+}
+
+func (m *MemoryPreferences) Preference(ctx context.Context, userID string) (Preference, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	pref, ok := m.prefs[userID]
+	if !ok {
+		return Preference{}, errs.Wrap("notify.MemoryPreferences.Preference", errs.ErrNotFound)
+	}
+	return pref, nil
+}
+
+type Option func(*Notifier)
+
+// WithSender makes s the strategy for ch, replacing any earlier one.
+func WithSender(ch Channel, s Sender) Option {
+	return func(n *Notifier) {
+		n.senders[ch] = s
+	}
+}
+
+// WithDefault is the channel for users without a preference, ChannelEmail
+// unless set.
+func WithDefault(ch Channel) Option {
+	return func(n *Notifier) {
+		n.fallback = ch
+	}
+}
+
+// WithLogger is where Hook reports notifications it could not deliver.
+func WithLogger(logger *slog.Logger) Option {
+	return func(n *Notifier) {
+		n.logger = logger
+	}
+}
+
+// Notifier chooses the Senders for a user from their Preference.
+type Notifier struct {
+	prefs    Preferences
+	senders  map[Channel]Sender
+	fallback Channel
+	logger   *slog.Logger
+}
+
+func New(prefs Preferences, opts ...Option) *Notifier {
+	n := &Notifier{
+		prefs:    prefs,
+		senders:  make(map[Channel]Sender),
+		fallback: ChannelEmail,
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+// Sender returns the strategy for user's preferred channels, a Composite
+// when there are several, and where to reach them.
+func (n *Notifier) Sender(ctx context.Context, user *service.User) (Sender, Recipient, error) {
+	pref, err := n.prefs.Preference(ctx, user.ID)
+	if err != nil && !errors.Is(err, errs.ErrNotFound) {
+		return nil, Recipient{}, errs.Wrap("notify.Notifier.Sender", err)
+	}
+	channels := pref.Channels
+	if len(channels) == 0 {
+		channels = []Channel{n.fallback}
+	}
+	senders := make([]Sender, 0, len(channels))
+	for _, ch := range channels {
+		s, ok := n.senders[ch]
+		if !ok {
+			return nil, Recipient{}, errs.Wrap("notify.Notifier.Sender", fmt.Errorf("no %s sender: %w", ch, errors.ErrUnsupported))
+		}
+		senders = append(senders, s)
+	}
+	to := Recipient{Email: user.Email, Phone: pref.Phone, Webhook: pref.Webhook}
+	if len(senders) == 1 {
+		return senders[0], to, nil
+	}
+	return Composite(senders...), to, nil
+}
+
+// Notify sends msg to user over their preferred channels.
+func (n *Notifier) Notify(ctx context.Context, user *service.User, msg Message) error {
+	s, to, err := n.Sender(ctx, user)
+	if err != nil {
+		return err
+	}
+	msg.UserID = user.ID
+	return errs.Wrap("notify.Notifier.Notify", s.Send(ctx, to, msg))
+}
+
+// Hook is an OnUserCreated hook sending the welcome message.
+//
+// It fails open like emailverify's: a user whose welcome is lost is still
+// created, so an undeliverable welcome is logged rather than returned to
+// the caller of CreateUser.
+func (n *Notifier) Hook() service.Hook {
+	return func(ctx context.Context, user *service.User) error {
+		err := n.Notify(ctx, user, Welcome(user))
+		if err != nil && ctx.Err() != nil {
+			return err
+		}
+		if err != nil {
+			n.logger.WarnContext(ctx, "welcome not sent", slog.String("user_id", user.ID), slog.Any("err", err))
+		}
+		return nil
+	}
+}
+
+// Welcome is the message Hook sends a new user.
+func Welcome(user *service.User) Message {
+	name := user.Name
+	if name == "" {
+		name = user.Email
+	}
+	return Message{
+		Kind:    "user.welcome",
+		UserID:  user.ID,
+		Subject: "Welcome aboard",
+		Body:    fmt.Sprintf("Hi %s, your account is ready.", name),
+	}
+}
@@ -0,0 +1,134 @@
+package notify_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/notify"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/notify/notifytest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// preferences has ada without a preference, bob on SMS, cy on a webhook,
+// dee on SMS and email, and eve on SMS without a phone.
+func preferences() *notify.MemoryPreferences {
+	prefs := notify.NewMemoryPreferences()
+	prefs.Set("bob", notify.Preference{Channels: []notify.Channel{notify.ChannelSMS}, Phone: "+15550123"})
+	prefs.Set("cy", notify.Preference{Channels: []notify.Channel{notify.ChannelWebhook}, Webhook: "https://hooks.example.com/cy"})
+	prefs.Set("dee", notify.Preference{Channels: []notify.Channel{notify.ChannelSMS, notify.ChannelEmail}, Phone: "+15550199"})
+	prefs.Set("eve", notify.Preference{Channels: []notify.Channel{notify.ChannelSMS}})
+	return prefs
+}
+
+// fakes is a fake sender on every channel.
+func fakes() map[notify.Channel]*notifytest.Sender {
+	return map[notify.Channel]*notifytest.Sender{
+		notify.ChannelEmail:   {},
+		notify.ChannelSMS:     {},
+		notify.ChannelWebhook: {},
+	}
+}
+
+func withFakes(senders map[notify.Channel]*notifytest.Sender) []notify.Option {
+	var opts []notify.Option
+	for ch, s := range senders {
+		opts = append(opts, notify.WithSender(ch, s))
+	}
+	return opts
+}
+
+func user(id string) *service.User {
+	return &service.User{ID: id, Email: id + "@example.com"}
+}
+
+// TestPicksByPreference checks each user gets the strategies they chose,
+// email for a user without a preference.
+func TestPicksByPreference(t *testing.T) {
+	senders := fakes()
+	notifier := notify.New(preferences(), withFakes(senders)...)
+	for _, id := range []string{"ada", "bob", "cy", "dee"} {
+		if err := notifier.Notify(context.Background(), user(id), notify.Message{Subject: "hi"}); err != nil {
+			t.Fatalf("Notify %s: %v", id, err)
+		}
+	}
+	for ch, want := range map[notify.Channel][]string{
+		notify.ChannelEmail:   {"ada", "dee"},
+		notify.ChannelSMS:     {"bob", "dee"},
+		notify.ChannelWebhook: {"cy"},
+	} {
+		var got []string
+		for _, s := range senders[ch].Sent() {
+			got = append(got, s.Msg.UserID)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("%s went to %v, want %v", ch, got, want)
+		}
+	}
+}
+
+// TestCompositeKeepsGoing fails the first of dee's two channels: the
+// second is still tried, and the error names the first.
+func TestCompositeKeepsGoing(t *testing.T) {
+	senders := fakes()
+	senders[notify.ChannelSMS].Err = errors.New("gateway down")
+	err := notify.New(preferences(), withFakes(senders)...).Notify(context.Background(), user("dee"), notify.Message{Subject: "hi"})
+	if err == nil || !strings.Contains(err.Error(), "gateway down") {
+		t.Errorf("Notify: err = %v, want the SMS failure", err)
+	}
+	if sent := senders[notify.ChannelEmail].Sent(); len(sent) != 1 || sent[0].To.Email != "dee@example.com" {
+		t.Errorf("emailed %+v, want dee", sent)
+	}
+}
+
+// TestNotDroppedSilently checks a missing address or sender is an error.
+func TestNotDroppedSilently(t *testing.T) {
+	ctx := context.Background()
+	prefs := preferences()
+	sms := notify.NewSMS((&notifytest.SMSGateway{}).Doer(), "https://sms.example.com/v1/messages", "+15550100")
+	if err := notify.New(prefs, notify.WithSender(notify.ChannelSMS, sms)).Notify(ctx, user("eve"), notify.Message{}); !errors.Is(err, notify.ErrNoAddress) || !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Notify without a phone: err = %v, want ErrNoAddress", err)
+	}
+	emailOnly := notify.New(prefs, notify.WithSender(notify.ChannelEmail, &notifytest.Sender{}))
+	if err := emailOnly.Notify(ctx, user("bob"), notify.Message{}); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Notify without an SMS sender: err = %v, want errors.ErrUnsupported", err)
+	}
+}
+
+// TestHook checks the UserCreated hook welcomes new users, and a welcome
+// lost fails nobody's signup.
+func TestHook(t *testing.T) {
+	ctx := context.Background()
+	var logs bytes.Buffer
+	down := &notifytest.Sender{Err: errs.ErrUnavailable}
+	welcome := &notifytest.Sender{}
+	notifier := notify.New(preferences(),
+		notify.WithSender(notify.ChannelEmail, welcome),
+		notify.WithSender(notify.ChannelSMS, down),
+		notify.WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+	)
+	users := service.NewUserService(db.NewMemoryStore())
+	users.OnUserCreated(notifier.Hook())
+	if err := users.CreateUser(ctx, &service.User{ID: "fay", Email: "fay@example.com", Name: "Fay"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if sent := welcome.Sent(); len(sent) != 1 || sent[0].To.Email != "fay@example.com" || sent[0].Msg.Body != "Hi Fay, your account is ready." {
+		t.Errorf("sent %+v, want fay's welcome", sent)
+	}
+	// bob prefers SMS, which is down: he is created all the same
+	if err := users.CreateUser(ctx, user("bob")); err != nil {
+		t.Fatalf("a lost welcome failed the signup: %v", err)
+	}
+	if _, err := users.RetrieveUser(ctx, "bob"); err != nil {
+		t.Errorf("RetrieveUser: %v", err)
+	}
+	if len(down.Sent()) != 1 || !strings.Contains(logs.String(), "welcome not sent") {
+		t.Errorf("the failure was not tried and logged: %q", logs.String())
+	}
+}
@@ -0,0 +1,293 @@
+// Package notifytest fakes what each notify strategy talks to, plus a
+// Sender to stand in for any of them:
+//
+//   - SMTPServer speaks enough SMTP on a loopback port for notify.Email,
+//     and keeps every message it accepts.
+//   - SMSGateway is the gateway API notify.SMS posts to.
+//   - WebhookReceiver is a user's webhook endpoint, checking signatures.
+//   - Sender records what a notify.Notifier hands it, for tests about
+//     which strategy was picked rather than how it delivers.
+//
+// The HTTP fakes have Server for a real listener and Doer to answer in
+// process, as emailverifytest does.
+package notifytest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/httpclient"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/notify"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/secret"
+)
+
+// Sent is one recorded Send.
+type Sent struct {
+	To  notify.Recipient
+	Msg notify.Message
+}
+
+// Sender is a notify.Sender that records every call and returns Err.
+type Sender struct {
+	Err error
+
+	mu   sync.Mutex
+	sent []Sent
+}
+
+func (s *Sender) Send(ctx context.Context, to notify.Recipient, msg notify.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, Sent{To: to, Msg: msg})
+	return s.Err
+}
+
+// Sent is every call so far, in order.
+func (s *Sender) Sent() []Sent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Sent(nil), s.sent...)
+}
+
+// Mail is one message an SMTPServer accepted.
+type Mail struct {
+	From string
+	To   []string
+	// Data is the message as sent, headers and body, dot-unstuffed and
+	// with \n line endings.
+	Data string
+}
+
+// SMTPServer is an SMTP server without extensions, TLS, or auth. Set
+// RejectRcpt before the first message to refuse some recipients with a 550.
+type SMTPServer struct {
+	RejectRcpt func(addr string) bool
+
+	ln    net.Listener
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	mails []Mail
+}
+
+// NewSMTPServer listens on a loopback port, Close it when done.
+func NewSMTPServer() (*SMTPServer, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &SMTPServer{ln: ln}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr is host:port for notify.NewEmail.
+func (s *SMTPServer) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Mails is every message accepted so far, in order.
+func (s *SMTPServer) Mails() []Mail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Mail(nil), s.mails...)
+}
+
+// Close stops listening and waits for open sessions to end.
+func (s *SMTPServer) Close() error {
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *SMTPServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.session(textproto.NewConn(conn))
+		}()
+	}
+}
+
+func (s *SMTPServer) session(c *textproto.Conn) {
+	defer c.Close()
+	c.PrintfLine("220 localhost notifytest")
+	var mail Mail
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			c.PrintfLine("250 localhost")
+		case "MAIL":
+			mail = Mail{From: address(arg)}
+			c.PrintfLine("250 OK")
+		case "RCPT":
+			to := address(arg)
+			if s.RejectRcpt != nil && s.RejectRcpt(to) {
+				c.PrintfLine("550 no such user")
+				continue
+			}
+			mail.To = append(mail.To, to)
+			c.PrintfLine("250 OK")
+		case "DATA":
+			c.PrintfLine("354 end with <CRLF>.<CRLF>")
+			data, err := c.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			mail.Data = string(data)
+			s.mu.Lock()
+			s.mails = append(s.mails, mail)
+			s.mu.Unlock()
+			c.PrintfLine("250 OK")
+		case "RSET":
+			mail = Mail{}
+			c.PrintfLine("250 OK")
+		case "NOOP":
+			c.PrintfLine("250 OK")
+		case "QUIT":
+			c.PrintfLine("221 bye")
+			return
+		default:
+			c.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+// address pulls the mailbox out of "FROM:<a@b>" or "TO:<a@b>".
+func address(arg string) string {
+	start, end := strings.IndexByte(arg, '<'), strings.IndexByte(arg, '>')
+	if start < 0 || end < start {
+		return ""
+	}
+	return arg[start+1 : end]
+}
+
+// Text is one message an SMSGateway was asked to send.
+type Text struct {
+	From          string `json:"from"`
+	To            string `json:"to"`
+	Text          string `json:"text"`
+	Authorization string `json:"-"`
+}
+
+// SMSGateway accepts texts with a 202, or answers Status when it is set.
+type SMSGateway struct {
+	Status int
+
+	mu    sync.Mutex
+	texts []Text
+}
+
+// Texts is every text accepted so far, in order.
+func (g *SMSGateway) Texts() []Text {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]Text(nil), g.texts...)
+}
+
+func (g *SMSGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if g.Status != 0 {
+		http.Error(w, "gateway unavailable", g.Status)
+		return
+	}
+	var text Text
+	if err := json.NewDecoder(r.Body).Decode(&text); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	text.Authorization = r.Header.Get("Authorization")
+	g.mu.Lock()
+	g.texts = append(g.texts, text)
+	g.mu.Unlock()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Server starts the gateway on a loopback listener, Close it when done.
+func (g *SMSGateway) Server() *httptest.Server {
+	return httptest.NewServer(g)
+}
+
+// Doer answers in place of the whole client.
+func (g *SMSGateway) Doer() httpclient.Doer {
+	return doer(g)
+}
+
+// Call is one request a WebhookReceiver took.
+type Call struct {
+	Body notify.WebhookBody
+	// Signed is whether the signature matched Key.
+	Signed bool
+}
+
+// WebhookReceiver is a user's endpoint. With a Key it refuses calls
+// whose signature does not match with a 401, as a careful receiver would.
+type WebhookReceiver struct {
+	Key secret.String
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Calls is every call taken so far, in order.
+func (wr *WebhookReceiver) Calls() []Call {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	return append([]Call(nil), wr.calls...)
+}
+
+func (wr *WebhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	call := Call{Signed: notify.Verify(wr.Key, data, r.Header.Get(notify.SignatureHeader))}
+	if !wr.Key.IsZero() && !call.Signed {
+		http.Error(w, "bad signature", http.StatusUnauthorized)
+		return
+	}
+	if err := json.Unmarshal(data, &call.Body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	wr.mu.Lock()
+	wr.calls = append(wr.calls, call)
+	wr.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Server starts the receiver on a loopback listener, Close it when done.
+func (wr *WebhookReceiver) Server() *httptest.Server {
+	return httptest.NewServer(wr)
+}
+
+// Doer answers in place of the whole client, whatever the URL.
+func (wr *WebhookReceiver) Doer() httpclient.Doer {
+	return doer(wr)
+}
+
+func doer(h http.Handler) httpclient.Doer {
+	return httpclient.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Result(), nil
+	})
+}
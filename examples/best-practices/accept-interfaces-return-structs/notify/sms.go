@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/httpclient"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/secret"
+)
+
+type SMSOption func(*SMS)
+
+// WithSMSToken is sent to the gateway as a bearer token.
+func WithSMSToken(token secret.String) SMSOption {
+	return func(s *SMS) {
+		s.token = token
+	}
+}
+
+// SMS is the text message strategy. It posts {"from", "to", "text"} as
+// JSON to a gateway's endpoint, the shape most SMS APIs take.
+type SMS struct {
+	client   httpclient.Doer
+	endpoint string
+	from     string
+	token    secret.String
+}
+
+// NewSMS sends from the number from through the gateway at endpoint. Like
+// emailverify it takes an httpclient.Doer, retries are the caller's choice.
+func NewSMS(client httpclient.Doer, endpoint, from string, opts ...SMSOption) *SMS {
+	s := &SMS{client: client, endpoint: endpoint, from: from}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// smsBody is the gateway request, subject and body are one text.
+type smsBody struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Text string `json:"text"`
+}
+
+func (s *SMS) Send(ctx context.Context, to Recipient, msg Message) error {
+	if to.Phone == "" {
+		return errs.Wrap("notify.SMS.Send", ErrNoAddress)
+	}
+	text := msg.Body
+	if msg.Subject != "" {
+		text = msg.Subject + ": " + msg.Body
+	}
+	header := http.Header{}
+	if !s.token.IsZero() {
+		header.Set("Authorization", "Bearer "+s.token.Reveal())
+	}
+	data, err := json.Marshal(smsBody{From: s.from, To: to.Phone, Text: text})
+	if err != nil {
+		return errs.Wrap("notify.SMS.Send", err)
+	}
+	return errs.Wrap("notify.SMS.Send", postJSON(ctx, s.client, s.endpoint, header, data))
+}
+
+// postJSON posts body and wants a 2xx back.
+func postJSON(ctx context.Context, client httpclient.Doer, url string, header http.Header, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// drain so the connection goes back to the pool
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package notify_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/notify"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/notify/notifytest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/secret"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+func TestSMS(t *testing.T) {
+	ctx := context.Background()
+	gateway := &notifytest.SMSGateway{}
+	sms := notify.NewSMS(gateway.Doer(), "https://sms.example.com/v1/messages", "+15550100", notify.WithSMSToken(secret.New("sms-token")))
+	bob := &service.User{ID: "bob", Email: "bob@example.com"}
+	if err := sms.Send(ctx, notify.Recipient{Phone: "+15550123"}, notify.Welcome(bob)); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	want := []notifytest.Text{{From: "+15550100", To: "+15550123", Text: "Welcome aboard: Hi bob@example.com, your account is ready.", Authorization: "Bearer sms-token"}}
+	if got := gateway.Texts(); !slices.Equal(got, want) {
+		t.Errorf("texts %+v, want %+v", got, want)
+	}
+
+	gateway.Status = 503
+	if err := sms.Send(ctx, notify.Recipient{Phone: "+15550123"}, notify.Welcome(bob)); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Send to a failing gateway: err = %v, want the 503", err)
+	}
+}
@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/httpclient"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/secret"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of a webhook body, prefixed
+// "sha256=", when the Webhook has a signing key.
+const SignatureHeader = "X-Notify-Signature"
+
+type WebhookOption func(*Webhook)
+
+// WithSigningKey signs every body with key, so receivers can tell the
+// call came from us. They check it with Verify.
+func WithSigningKey(key secret.String) WebhookOption {
+	return func(w *Webhook) {
+		w.key = key
+	}
+}
+
+// Webhook is the strategy for users who registered a URL, the message is
+// posted there as JSON.
+//
+// The URL is the user's, so the request goes wherever they said. Give it
+// a Doer whose transport refuses internal addresses before exposing
+// webhooks to untrusted users.
+type Webhook struct {
+	client httpclient.Doer
+	key    secret.String
+}
+
+func NewWebhook(client httpclient.Doer, opts ...WebhookOption) *Webhook {
+	w := &Webhook{client: client}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// WebhookBody is what a webhook receives.
+type WebhookBody struct {
+	Kind    string    `json:"kind"`
+	UserID  string    `json:"user_id"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+func (w *Webhook) Send(ctx context.Context, to Recipient, msg Message) error {
+	if to.Webhook == "" {
+		return errs.Wrap("notify.Webhook.Send", ErrNoAddress)
+	}
+	u, err := url.Parse(to.Webhook)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errs.Wrap("notify.Webhook.Send", fmt.Errorf("webhook %q is not an http(s) URL: %w", to.Webhook, errs.ErrInvalidInput))
+	}
+	body := WebhookBody{Kind: msg.Kind, UserID: msg.UserID, Subject: msg.Subject, Body: msg.Body, SentAt: time.Now().UTC()}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return errs.Wrap("notify.Webhook.Send", err)
+	}
+	header := http.Header{}
+	if !w.key.IsZero() {
+		header.Set(SignatureHeader, "sha256="+sign(w.key, data))
+	}
+	return errs.Wrap("notify.Webhook.Send", postJSON(ctx, w.client, u.String(), header, data))
+}
+
+// Verify reports whether signature, a SignatureHeader value, is key's
+// signature of body. It compares in constant time.
+func Verify(key secret.String, body []byte, signature string) bool {
+	return hmac.Equal([]byte(signature), []byte("sha256="+sign(key, body)))
+}
+
+func sign(key secret.String, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(key.Reveal()))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,36 @@
+package notify_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/notify"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/notify/notifytest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/secret"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// TestWebhook checks a webhook is signed and the receiver checks it.
+func TestWebhook(t *testing.T) {
+	ctx := context.Background()
+	key := secret.New("webhook-signing-key")
+	receiver := &notifytest.WebhookReceiver{Key: key}
+	server := receiver.Server()
+	defer server.Close()
+	cy := &service.User{ID: "cy", Email: "cy@example.com"}
+
+	webhook := notify.NewWebhook(server.Client(), notify.WithSigningKey(key))
+	if err := webhook.Send(ctx, notify.Recipient{Webhook: server.URL + "/hooks/cy"}, notify.Welcome(cy)); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	calls := receiver.Calls()
+	if len(calls) != 1 || !calls[0].Signed || calls[0].Body.UserID != "cy" || calls[0].Body.Kind != "user.welcome" {
+		t.Errorf("calls %+v, want one signed user.welcome for cy", calls)
+	}
+
+	forged := notify.NewWebhook(server.Client(), notify.WithSigningKey(secret.New("wrong")))
+	if err := forged.Send(ctx, notify.Recipient{Webhook: server.URL}, notify.Welcome(cy)); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Send with a wrong key: err = %v, want a 401", err)
+	}
+}
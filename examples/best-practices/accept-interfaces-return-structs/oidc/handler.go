@@ -0,0 +1,244 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/session"
+)
+
+const (
+	flowCookie = "oidc_flow"
+	// flowTTL bounds how long a login may sit at the provider
+	flowTTL = 10 * time.Minute
+)
+
+// UserService is what the callback needs from *service.UserService.
+type UserService interface {
+	RetrieveUserByEmail(ctx context.Context, email string, opts ...service.ReadOption) (*service.User, error)
+	CreateUser(ctx context.Context, user *service.User) error
+}
+
+// Sessions starts the session for a logged in user and ends it at logout,
+// *session.Manager does.
+type Sessions interface {
+	Start(w http.ResponseWriter, r *http.Request, userID string, roles ...string) (*session.Session, error)
+	End(w http.ResponseWriter, r *http.Request) error
+}
+
+type Option func(*Handler)
+
+// WithLogger is where failed logins are reported, the browser only gets
+// the status.
+func WithLogger(logger *slog.Logger) Option {
+	return func(h *Handler) {
+		h.logger = logger
+	}
+}
+
+// Handler serves the login flow:
+//
+//	GET  /login     redirect to the provider, ?next= is where to land after
+//	GET  /callback  the provider's redirect back, starts the session
+//	POST /logout    ends the session
+type Handler struct {
+	provider *Provider
+	users    UserService
+	sessions Sessions
+	codec    *session.Codec
+	logger   *slog.Logger
+	now      func() time.Time
+	mux      *http.ServeMux
+}
+
+// NewHandler seals the cookie that carries a login's state, nonce, and
+// verifier across the trip to the provider with codec, which may be the
+// session manager's.
+func NewHandler(provider *Provider, users UserService, sessions Sessions, codec *session.Codec, opts ...Option) *Handler {
+	h := &Handler{
+		provider: provider,
+		users:    users,
+		sessions: sessions,
+		codec:    codec,
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		now:      time.Now,
+		mux:      http.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.mux.HandleFunc("GET /login", h.login)
+	h.mux.HandleFunc("GET /callback", h.callback)
+	h.mux.HandleFunc("POST /logout", h.logout)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// flow is what the browser carries to the provider and back.
+type flow struct {
+	State     string    `json:"state"`
+	Nonce     string    `json:"nonce"`
+	Verifier  string    `json:"verifier"`
+	Next      string    `json:"next"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (h *Handler) login(w http.ResponseWriter, r *http.Request) {
+	f := flow{
+		State:     rand.Text(),
+		Nonce:     rand.Text(),
+		Verifier:  oauth2.GenerateVerifier(),
+		Next:      localPath(r.URL.Query().Get("next")),
+		ExpiresAt: h.now().Add(flowTTL),
+	}
+	value, err := h.seal(f)
+	if err != nil {
+		h.fail(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     flowCookie,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(flowTTL / time.Second),
+		HttpOnly: true,
+		Secure:   true,
+		// Lax, the provider's redirect back is a cross site top level GET
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, h.provider.AuthCodeURL(f.State, f.Nonce, f.Verifier), http.StatusFound)
+}
+
+func (h *Handler) callback(w http.ResponseWriter, r *http.Request) {
+	// the flow is single use whatever happens next
+	http.SetCookie(w, &http.Cookie{Name: flowCookie, Path: "/", MaxAge: -1, HttpOnly: true, Secure: true, SameSite: http.SameSiteLaxMode})
+	f, err := h.open(r)
+	if err != nil {
+		h.fail(w, r, http.StatusBadRequest, err)
+		return
+	}
+	q := r.URL.Query()
+	if q.Get("state") != f.State {
+		h.fail(w, r, http.StatusBadRequest, errors.New("state does not match the login"))
+		return
+	}
+	if e := q.Get("error"); e != "" {
+		h.fail(w, r, http.StatusUnauthorized, fmt.Errorf("provider: %s: %s", e, q.Get("error_description")))
+		return
+	}
+	claims, err := h.provider.Exchange(r.Context(), q.Get("code"), f.Verifier, f.Nonce)
+	if err != nil {
+		h.fail(w, r, http.StatusUnauthorized, err)
+		return
+	}
+	user, err := h.user(r.Context(), claims)
+	if err != nil {
+		h.fail(w, r, status(err), err)
+		return
+	}
+	if _, err := h.sessions.Start(w, r, user.ID); err != nil {
+		h.fail(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	http.Redirect(w, r, f.Next, http.StatusFound)
+}
+
+func (h *Handler) logout(w http.ResponseWriter, r *http.Request) {
+	if err := h.sessions.End(w, r); err != nil {
+		h.logger.ErrorContext(r.Context(), "logout failed", slog.String("error", err.Error()))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// user finds the user with the token's email, creating one on first
+// login. An unverified email proves nothing about who owns the address,
+// matching on it would hand an existing account to whoever typed it in.
+func (h *Handler) user(ctx context.Context, claims *Claims) (*service.User, error) {
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, fmt.Errorf("%w: email not verified by the provider", errs.ErrForbidden)
+	}
+	user, err := h.users.RetrieveUserByEmail(ctx, claims.Email, service.IncludeDeleted())
+	if err == nil && user.Deleted() {
+		return nil, fmt.Errorf("%w: user %s is deleted", errs.ErrForbidden, user.ID)
+	}
+	if !errors.Is(err, errs.ErrNotFound) {
+		return user, err
+	}
+	user = UserFor(claims)
+	if err := h.users.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	h.logger.InfoContext(ctx, "user created on first login", slog.String("user_id", user.ID))
+	return user, nil
+}
+
+func (h *Handler) fail(w http.ResponseWriter, r *http.Request, code int, err error) {
+	h.logger.WarnContext(r.Context(), "login failed", slog.Int("status", code), slog.String("error", err.Error()))
+	http.Error(w, http.StatusText(code), code)
+}
+
+func status(err error) int {
+	switch {
+	case errors.Is(err, errs.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, errs.ErrInvalidInput):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// seal encrypts f as JSON, so the verifier and nonce are as hidden from
+// the browser as they are from anyone it leaks them to.
+func (h *Handler) seal(f flow) (string, error) {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return "", err
+	}
+	return h.codec.Encode(flowCookie, b)
+}
+
+func (h *Handler) open(r *http.Request) (flow, error) {
+	var f flow
+	c, err := r.Cookie(flowCookie)
+	if err != nil {
+		return f, errors.New("no login in progress")
+	}
+	b, err := h.codec.Decode(flowCookie, c.Value)
+	if err != nil {
+		return f, fmt.Errorf("flow cookie: %w", err)
+	}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return f, err
+	}
+	if h.now().After(f.ExpiresAt) {
+		return f, errors.New("login expired")
+	}
+	return f, nil
+}
+
+// localPath keeps next on this site, "//evil.example" and absolute URLs
+// would make the login an open redirect.
+func localPath(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
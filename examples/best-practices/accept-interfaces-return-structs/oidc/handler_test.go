@@ -0,0 +1,309 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/idgen"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/oidc"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/oidc/oidctest"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/secret"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/session"
+)
+
+// app is the users API behind the login flow, with oidctest's provider in
+// front of it and a session clock the test can move forward.
+type app struct {
+	*httptest.Server
+	provider *oidctest.Provider
+	// skew moves the session clock forward to expire sessions on demand
+	skew atomic.Int64
+}
+
+func newApp(t *testing.T) *app {
+	t.Helper()
+	a := &app{provider: oidctest.NewProvider("users-api", "s3cret")}
+	t.Cleanup(a.provider.Close)
+
+	users := service.NewUserService(db.NewMemoryStore(), service.WithIDGenerator(idgen.UUIDv7{}))
+	key := make([]byte, 32)
+	rand.Read(key)
+	codec, err := session.NewCodec(key)
+	if err != nil {
+		t.Fatalf("NewCodec: %v", err)
+	}
+	logger := slog.New(slog.DiscardHandler)
+	sessions := session.New(session.NewMemory(), codec,
+		session.WithIdleTimeout(30*time.Minute),
+		session.WithAbsoluteTimeout(12*time.Hour),
+		session.WithNow(func() time.Time { return time.Now().Add(time.Duration(a.skew.Load())) }),
+		session.WithLogger(logger),
+	)
+
+	// the app's URL is the redirect URL, so start it before discovering
+	mux := http.NewServeMux()
+	a.Server = httptest.NewUnstartedServer(sessions.Middleware()(mux))
+	a.StartTLS()
+	t.Cleanup(a.Close)
+	provider, err := oidc.Discover(context.Background(), oidc.Config{
+		Issuer:       a.provider.Issuer(),
+		ClientID:     "users-api",
+		ClientSecret: secret.New("s3cret"),
+		RedirectURL:  a.URL + "/callback",
+		HTTPClient:   a.provider.Client(),
+	})
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	mux.Handle("/", oidc.NewHandler(provider, users, sessions, codec, oidc.WithLogger(logger)))
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "home")
+	})
+	mux.HandleFunc("GET /me", func(w http.ResponseWriter, r *http.Request) {
+		s, ok := session.From(r.Context())
+		if !ok {
+			http.Error(w, "not logged in", http.StatusUnauthorized)
+			return
+		}
+		user, err := users.RetrieveUser(r.Context(), s.UserID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id": user.ID, "email": user.Email, "name": user.Name})
+	})
+	return a
+}
+
+// browser follows redirects between the app and the provider, keeping the
+// cookies the app sets.
+func (a *app) browser() *http.Client {
+	jar, _ := cookiejar.New(nil)
+	return &http.Client{Transport: a.provider.Client().Transport, Jar: jar}
+}
+
+func (a *app) get(t *testing.T, client *http.Client, path string) (*http.Response, string) {
+	t.Helper()
+	resp, err := client.Get(a.URL + path)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	return resp, string(body)
+}
+
+func (a *app) logout(t *testing.T, client *http.Client) {
+	t.Helper()
+	resp, err := client.Post(a.URL+"/logout", "", nil)
+	if err != nil {
+		t.Fatalf("POST /logout: %v", err)
+	}
+	resp.Body.Close()
+}
+
+// me returns who client is logged in as, ok is false when it is not.
+func (a *app) me(t *testing.T, client *http.Client) (user map[string]string, ok bool) {
+	t.Helper()
+	resp, body := a.get(t, client, "/me")
+	if resp.StatusCode != http.StatusOK {
+		return nil, false
+	}
+	if err := json.Unmarshal([]byte(body), &user); err != nil {
+		t.Fatalf("GET /me: %v", err)
+	}
+	return user, true
+}
+
+// loggedIn is a browser fresh from a login.
+func (a *app) loggedIn(t *testing.T) *http.Client {
+	t.Helper()
+	client := a.browser()
+	a.get(t, client, "/login")
+	if _, ok := a.me(t, client); !ok {
+		t.Fatal("not logged in after a login")
+	}
+	return client
+}
+
+// copied is a second browser holding client's cookies.
+func (a *app) copied(t *testing.T, client *http.Client) *http.Client {
+	t.Helper()
+	u, err := url.Parse(a.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := a.browser()
+	c.Jar.SetCookies(u, client.Jar.Cookies(u))
+	return c
+}
+
+func TestLogin(t *testing.T) {
+	a := newApp(t)
+	// a first login creates the user from the claims, a second finds it
+	var first map[string]string
+	for i := range 2 {
+		client := a.browser()
+		a.get(t, client, "/login?next=/me")
+		user, ok := a.me(t, client)
+		if !ok {
+			t.Fatalf("login %d: not logged in", i+1)
+		}
+		if user["email"] != "ada@example.com" || user["name"] != "Ada Lovelace" {
+			t.Fatalf("login %d: user = %v, want Ada from the claims", i+1, user)
+		}
+		if first != nil && user["id"] != first["id"] {
+			t.Errorf("second login: id = %s, want %s", user["id"], first["id"])
+		}
+		first = user
+	}
+
+	// next cannot send the browser off the site
+	resp, body := a.get(t, a.browser(), "/login?next="+url.QueryEscape("//evil.example/"))
+	if resp.Request.URL.Host != a.Listener.Addr().String() || body != "home" {
+		t.Errorf("next=//evil.example/ landed on %s", resp.Request.URL)
+	}
+}
+
+// TestRefused checks the provider's answers the app must not trust, the
+// provider is edited for each login.
+func TestRefused(t *testing.T) {
+	a := newApp(t)
+	for _, tt := range []struct {
+		name string
+		edit func(*oidctest.Provider)
+		want int
+	}{
+		{"another login's nonce", func(p *oidctest.Provider) { p.Claims = func(c jwt.MapClaims) { c["nonce"] = "replayed" } }, http.StatusUnauthorized},
+		{"another client's ID token", func(p *oidctest.Provider) { p.Claims = func(c jwt.MapClaims) { c["aud"] = "someone-else" } }, http.StatusUnauthorized},
+		{"an expired ID token", func(p *oidctest.Provider) { p.Claims = func(c jwt.MapClaims) { c["exp"] = 1 } }, http.StatusUnauthorized},
+		{"an unverified email", func(p *oidctest.Provider) { p.User.Email, p.User.EmailVerified = "grace@example.com", false }, http.StatusForbidden},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			saved, claims := a.provider.User, a.provider.Claims
+			defer func() { a.provider.User, a.provider.Claims = saved, claims }()
+			tt.edit(a.provider)
+			if resp, _ := a.get(t, a.browser(), "/login?next=/me"); resp.StatusCode != tt.want {
+				t.Errorf("login = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallbackState(t *testing.T) {
+	a := newApp(t)
+	t.Run("forged", func(t *testing.T) {
+		client := a.browser()
+		// stop at the redirect back to the app and tamper with it
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if req.URL.Path == "/callback" {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		}
+		resp, _ := a.get(t, client, "/login")
+		callback, err := url.Parse(resp.Header.Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := callback.Query()
+		q.Set("state", "forged")
+		if resp, _ := a.get(t, client, "/callback?"+q.Encode()); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("callback = %d, want 400", resp.StatusCode)
+		}
+	})
+	t.Run("no login in progress", func(t *testing.T) {
+		if resp, _ := a.get(t, a.browser(), "/callback?code=x&state=y"); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("callback = %d, want 400", resp.StatusCode)
+		}
+	})
+}
+
+func TestSessions(t *testing.T) {
+	a := newApp(t)
+	t.Run("logout revokes copies of the cookie", func(t *testing.T) {
+		client := a.loggedIn(t)
+		thief := a.copied(t, client)
+		if _, ok := a.me(t, thief); !ok {
+			t.Fatal("the copy is not logged in before logout")
+		}
+		a.logout(t, client)
+		if _, ok := a.me(t, client); ok {
+			t.Error("still logged in after logout")
+		}
+		if _, ok := a.me(t, thief); ok {
+			t.Error("the copy is still logged in after logout")
+		}
+	})
+	t.Run("logging in again replaces the session", func(t *testing.T) {
+		client := a.loggedIn(t)
+		before := a.copied(t, client)
+		a.get(t, client, "/login")
+		if _, ok := a.me(t, client); !ok {
+			t.Fatal("not logged in after the second login")
+		}
+		if _, ok := a.me(t, before); ok {
+			t.Error("the session from before the login still works")
+		}
+	})
+	t.Run("a tampered cookie is refused", func(t *testing.T) {
+		client := a.loggedIn(t)
+		u, _ := url.Parse(a.URL)
+		cookies := client.Jar.Cookies(u)
+		for _, c := range cookies {
+			c.Value = c.Value[:len(c.Value)-2] + "AA"
+		}
+		forged := a.browser()
+		forged.Jar.SetCookies(u, cookies)
+		if _, ok := a.me(t, forged); ok {
+			t.Error("a tampered cookie was accepted")
+		}
+	})
+}
+
+func TestSessionTimeouts(t *testing.T) {
+	a := newApp(t)
+	t.Run("idle", func(t *testing.T) {
+		defer a.skew.Store(0)
+		client := a.loggedIn(t)
+		a.skew.Store(int64(29 * time.Minute))
+		if _, ok := a.me(t, client); !ok {
+			t.Fatal("logged out after 29 idle minutes")
+		}
+		a.skew.Add(int64(31 * time.Minute))
+		if _, ok := a.me(t, client); ok {
+			t.Error("still logged in after 31 idle minutes")
+		}
+	})
+	t.Run("absolute", func(t *testing.T) {
+		defer a.skew.Store(0)
+		client := a.loggedIn(t)
+		for a.skew.Load() < int64(11*time.Hour+40*time.Minute) {
+			a.skew.Add(int64(20 * time.Minute))
+			if _, ok := a.me(t, client); !ok {
+				t.Fatalf("an active session logged out after %s", time.Duration(a.skew.Load()))
+			}
+		}
+		a.skew.Add(int64(20 * time.Minute))
+		if _, ok := a.me(t, client); ok {
+			t.Errorf("still logged in after %s", time.Duration(a.skew.Load()))
+		}
+	})
+}
@@ -0,0 +1,140 @@
+// Package oidc logs users in through an OpenID Connect provider with the
+// authorization code flow, for any provider that publishes a discovery
+// document:
+//
+//	provider, err := oidc.Discover(ctx, oidc.Config{
+//		Issuer:       "https://accounts.example.com",
+//		ClientID:     "users-api",
+//		ClientSecret: secret.New(os.Getenv("OIDC_CLIENT_SECRET")),
+//		RedirectURL:  "https://users.example.com/callback",
+//	})
+//	sessions := session.New(store, codec)
+//	login := oidc.NewHandler(provider, userService, sessions, codec)
+//	mux.Handle("/", login) // GET /login, GET /callback, POST /logout
+//
+// GET /login sends the browser to the provider with a fresh state, nonce,
+// and PKCE verifier, kept in an encrypted cookie until it comes back. GET
+// /callback checks the state, exchanges the code, verifies the ID token's
+// signature, issuer, audience, expiry, and nonce, finds or creates the user
+// for its email, and starts a session for it with the session package.
+// The oidctest package fakes a provider for tests.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/oauth2"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/secret"
+)
+
+// Config is the client's registration with the provider.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret secret.String
+	RedirectURL  string
+	// Scopes are requested beyond openid, email and profile by default,
+	// which carry the claims UserFor reads.
+	Scopes []string
+	// HTTPClient reaches the provider, http.DefaultClient when nil.
+	HTTPClient *http.Client
+}
+
+// Metadata is the part of the discovery document the flow needs.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider is a discovered provider, ready to run the flow against.
+type Provider struct {
+	Metadata Metadata
+	config   Config
+	oauth    oauth2.Config
+	keys     *keySet
+}
+
+// Discover reads the provider's discovery document from
+// {Issuer}/.well-known/openid-configuration. The document must name the
+// same issuer it was fetched from, or every ID token would be checked
+// against the wrong one.
+func Discover(ctx context.Context, config Config) (*Provider, error) {
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	if config.Scopes == nil {
+		config.Scopes = []string{"email", "profile"}
+	}
+	url := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	var meta Metadata
+	if err := getJSON(ctx, config.HTTPClient, url, &meta); err != nil {
+		return nil, errs.Wrap("oidc.Discover", err)
+	}
+	if meta.Issuer != config.Issuer {
+		return nil, errs.Wrap("oidc.Discover", fmt.Errorf("discovery document is for issuer %q, want %q", meta.Issuer, config.Issuer))
+	}
+	return &Provider{
+		Metadata: meta,
+		config:   config,
+		oauth: oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret.Reveal(),
+			RedirectURL:  config.RedirectURL,
+			Scopes:       append([]string{"openid"}, config.Scopes...),
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  meta.AuthorizationEndpoint,
+				TokenURL: meta.TokenEndpoint,
+			},
+		},
+		keys: newKeySet(config.HTTPClient, meta.JWKSURI),
+	}, nil
+}
+
+// AuthCodeURL is where to send the browser to log in.
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) string {
+	return p.oauth.AuthCodeURL(state, oauth2.SetAuthURLParam("nonce", nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+// Exchange trades the code from the callback for the verified claims of
+// the ID token that came with it. nonce is the one sent with AuthCodeURL.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Claims, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.config.HTTPClient)
+	token, err := p.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, errs.Wrap("oidc.Provider.Exchange", fmt.Errorf("%w: %w", errs.ErrUnauthenticated, err))
+	}
+	raw, ok := token.Extra("id_token").(string)
+	if !ok || raw == "" {
+		return nil, errs.Wrap("oidc.Provider.Exchange", fmt.Errorf("%w: no id_token in the token response", errs.ErrUnauthenticated))
+	}
+	claims, err := p.Verify(ctx, raw, nonce)
+	if err != nil {
+		return nil, errs.Wrap("oidc.Provider.Exchange", err)
+	}
+	return claims, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,64 @@
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Claims are the ID token claims the flow reads, the registered ones plus
+// the standard email and profile claims.
+type Claims struct {
+	jwt.RegisteredClaims
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+}
+
+// Verify checks an ID token's signature against the provider's published
+// keys, and that it was issued by the provider, to this client, has not
+// expired, and carries nonce. Every failure wraps errs.ErrUnauthenticated.
+func (p *Provider) Verify(ctx context.Context, raw, nonce string) (*Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(raw, &claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.keys.get(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(p.Metadata.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, errs.Wrap("oidc.Provider.Verify", fmt.Errorf("%w: %w", errs.ErrUnauthenticated, err))
+	}
+	// a token minted for another login, replayed into this one
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, errs.Wrap("oidc.Provider.Verify", fmt.Errorf("%w: nonce does not match", errs.ErrUnauthenticated))
+	}
+	if claims.Subject == "" {
+		return nil, errs.Wrap("oidc.Provider.Verify", fmt.Errorf("%w: no subject", errs.ErrUnauthenticated))
+	}
+	return &claims, nil
+}
+
+// UserFor maps claims onto a new User. The ID is left empty for the
+// service's IDGenerator, a provider's subject is only unique per provider
+// and has no length limit.
+func UserFor(claims *Claims) *service.User {
+	name := claims.Name
+	if name == "" {
+		name = strings.TrimSpace(claims.GivenName + " " + claims.FamilyName)
+	}
+	return &service.User{Email: claims.Email, Name: name}
+}
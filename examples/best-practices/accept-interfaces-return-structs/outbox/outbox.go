@@ -0,0 +1,122 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/events"
+)
+
+// Message is an event waiting in the outbox. Stores assign ID on append,
+// it increases with every message so relaying in ID order keeps event order.
+type Message struct {
+	ID        int64
+	Topic     string
+	Payload   []byte
+	CreatedAt time.Time
+}
+
+// NewMessage encodes event for the outbox, events.Decode reverses it.
+func NewMessage(event events.Event, now time.Time) (Message, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{Topic: event.Name(), Payload: payload, CreatedAt: now}, nil
+}
+
+// Source is the outbox as seen by the relay.
+type Source interface {
+	// PendingOutbox returns up to limit unsent messages, oldest first.
+	PendingOutbox(ctx context.Context, limit int) ([]Message, error)
+	// MarkOutboxSent records that the messages were published.
+	MarkOutboxSent(ctx context.Context, ids []int64) error
+}
+
+// Relay moves messages from the outbox to a publisher. Delivery is at least
+// once: a message is marked sent only after Publish succeeds, so a crash in
+// between republishes it on the next run and consumers must be idempotent.
+//
+// A message that cannot be decoded would fail the same way on every run,
+// so it is logged, handed to DeadLetter, and marked sent rather than
+// holding back every message after it.
+type Relay struct {
+	source    Source
+	publisher events.Publisher
+	logger    *slog.Logger
+	// Interval is how often the outbox is polled, default one second.
+	Interval time.Duration
+	// BatchSize caps how many messages are relayed per poll, default 100.
+	BatchSize int
+	// DeadLetter, when set, is given each message that cannot be decoded
+	// and why, before the message is marked sent without being published.
+	DeadLetter func(ctx context.Context, msg Message, err error)
+}
+
+func NewRelay(source Source, publisher events.Publisher, logger *slog.Logger) *Relay {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Relay{
+		source:    source,
+		publisher: publisher,
+		logger:    logger,
+		Interval:  time.Second,
+		BatchSize: 100,
+	}
+}
+
+// Run polls until ctx is done, it always returns ctx.Err().
+func (r *Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.Flush(ctx); err != nil && ctx.Err() == nil {
+			r.logger.ErrorContext(ctx, "outbox relay", slog.String("error", err.Error()))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush relays one batch and reports how many messages were published.
+// It stops at the first publish failure so later messages never overtake
+// it, and skips messages that cannot be decoded, see Relay.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	msgs, err := r.source.PendingOutbox(ctx, r.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	sent := make([]int64, 0, len(msgs))
+	published := 0
+	var publishErr error
+	for _, msg := range msgs {
+		event, err := events.Decode(msg.Topic, msg.Payload)
+		if err != nil {
+			r.logger.ErrorContext(ctx, "outbox relay skipped an undecodable message",
+				slog.Int64("id", msg.ID), slog.String("topic", msg.Topic), slog.String("error", err.Error()))
+			if r.DeadLetter != nil {
+				r.DeadLetter(ctx, msg, err)
+			}
+			sent = append(sent, msg.ID)
+			continue
+		}
+		if err := r.publisher.Publish(ctx, event); err != nil {
+			publishErr = err
+			break
+		}
+		sent = append(sent, msg.ID)
+		published++
+	}
+	if len(sent) > 0 {
+		if err := r.source.MarkOutboxSent(ctx, sent); err != nil {
+			return 0, err
+		}
+	}
+	return published, publishErr
+}
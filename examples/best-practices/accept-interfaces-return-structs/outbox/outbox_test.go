@@ -0,0 +1,169 @@
+package outbox_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/events"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/outbox"
+)
+
+// recorder is a publisher keeping the user IDs it was given, failing the
+// next fail calls first.
+type recorder struct {
+	ids  []string
+	fail int
+}
+
+func (r *recorder) Publish(ctx context.Context, event events.Event) error {
+	if r.fail > 0 {
+		r.fail--
+		return errors.New("broker down")
+	}
+	r.ids = append(r.ids, event.(events.UserCreated).UserID)
+	return nil
+}
+
+// failingMark is a source whose next MarkOutboxSent fails, as a crash
+// between the publish and the mark would leave it.
+type failingMark struct {
+	*db.MemoryStore
+	fail bool
+}
+
+func (s *failingMark) MarkOutboxSent(ctx context.Context, ids []int64) error {
+	if s.fail {
+		s.fail = false
+		return errors.New("store down")
+	}
+	return s.MemoryStore.MarkOutboxSent(ctx, ids)
+}
+
+// fill appends a UserCreated for each id to store's outbox.
+func fill(t *testing.T, store *db.MemoryStore, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		msg, err := outbox.NewMessage(events.UserCreated{UserID: id}, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.AppendOutbox(context.Background(), msg); err != nil {
+			t.Fatalf("AppendOutbox: %v", err)
+		}
+	}
+}
+
+// pending is how many messages store still has to relay.
+func pending(t *testing.T, store *db.MemoryStore) int {
+	t.Helper()
+	msgs, err := store.PendingOutbox(context.Background(), 1000)
+	if err != nil {
+		t.Fatalf("PendingOutbox: %v", err)
+	}
+	return len(msgs)
+}
+
+func TestRelayOrder(t *testing.T) {
+	store := db.NewMemoryStore()
+	fill(t, store, "u1", "u2", "u3", "u4", "u5")
+	pub := &recorder{}
+	relay := outbox.NewRelay(store, pub, slog.New(slog.DiscardHandler))
+	relay.BatchSize = 2
+	for _, want := range []int{2, 2, 1, 0} {
+		if n, err := relay.Flush(context.Background()); err != nil || n != want {
+			t.Fatalf("Flush = %d, %v, want %d", n, err, want)
+		}
+	}
+	if want := []string{"u1", "u2", "u3", "u4", "u5"}; !slices.Equal(pub.ids, want) {
+		t.Errorf("published %v, want %v", pub.ids, want)
+	}
+}
+
+// TestRelayPublishError checks a failed publish stops the batch there, and
+// the next Flush starts again from that message.
+func TestRelayPublishError(t *testing.T) {
+	store := db.NewMemoryStore()
+	fill(t, store, "u1", "u2", "u3")
+	pub := &recorder{}
+	relay := outbox.NewRelay(store, pub, slog.New(slog.DiscardHandler))
+	if _, err := relay.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	fill(t, store, "u4", "u5")
+	pub.fail = 1
+	if n, err := relay.Flush(context.Background()); err == nil || n != 0 {
+		t.Fatalf("Flush with the broker down = %d, %v, want 0 and its error", n, err)
+	}
+	if got := pending(t, store); got != 2 {
+		t.Errorf("%d pending after the failure, want u4 and u5 kept", got)
+	}
+	if n, err := relay.Flush(context.Background()); err != nil || n != 2 {
+		t.Fatalf("Flush once it is back = %d, %v, want 2", n, err)
+	}
+	if want := []string{"u1", "u2", "u3", "u4", "u5"}; !slices.Equal(pub.ids, want) {
+		t.Errorf("published %v, want %v", pub.ids, want)
+	}
+}
+
+// TestRelayRedelivery checks the delivery is at least once: messages
+// published but not marked sent are published again on the next Flush.
+func TestRelayRedelivery(t *testing.T) {
+	store := &failingMark{MemoryStore: db.NewMemoryStore(), fail: true}
+	fill(t, store.MemoryStore, "u1", "u2")
+	pub := &recorder{}
+	relay := outbox.NewRelay(store, pub, slog.New(slog.DiscardHandler))
+	if _, err := relay.Flush(context.Background()); err == nil {
+		t.Fatal("Flush with MarkOutboxSent failing succeeded")
+	}
+	if n, err := relay.Flush(context.Background()); err != nil || n != 2 {
+		t.Fatalf("Flush = %d, %v, want both again", n, err)
+	}
+	if want := []string{"u1", "u2", "u1", "u2"}; !slices.Equal(pub.ids, want) {
+		t.Errorf("published %v, want %v", pub.ids, want)
+	}
+	if got := pending(t, store.MemoryStore); got != 0 {
+		t.Errorf("%d pending, want none", got)
+	}
+}
+
+// TestRelayPoison puts messages that can never be decoded between good
+// ones: they are logged, dead-lettered, and marked sent, and the ones
+// after them are published.
+func TestRelayPoison(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryStore()
+	fill(t, store, "u1")
+	store.AppendOutbox(ctx, outbox.Message{Topic: events.NameUserCreated, Payload: []byte("{not json")})
+	store.AppendOutbox(ctx, outbox.Message{Topic: "user.renamed", Payload: []byte("{}")})
+	fill(t, store, "u2")
+
+	var logs bytes.Buffer
+	pub := &recorder{}
+	relay := outbox.NewRelay(store, pub, slog.New(slog.NewTextHandler(&logs, nil)))
+	var dead []string
+	relay.DeadLetter = func(_ context.Context, msg outbox.Message, err error) {
+		dead = append(dead, msg.Topic)
+	}
+	if n, err := relay.Flush(ctx); err != nil || n != 2 {
+		t.Fatalf("Flush = %d, %v, want u1 and u2 published", n, err)
+	}
+	if want := []string{"u1", "u2"}; !slices.Equal(pub.ids, want) {
+		t.Errorf("published %v, want %v", pub.ids, want)
+	}
+	if want := []string{events.NameUserCreated, "user.renamed"}; !slices.Equal(dead, want) {
+		t.Errorf("dead-lettered %v, want %v", dead, want)
+	}
+	if got := pending(t, store); got != 0 {
+		t.Errorf("%d pending, want the undecodable ones marked too", got)
+	}
+	if got := strings.Count(logs.String(), "undecodable"); got != 2 {
+		t.Errorf("logged %d skipped messages, want 2:\n%s", got, logs.String())
+	}
+}
@@ -0,0 +1,149 @@
+// Package pipeline composes channel stages: a generator feeds values in,
+// each stage reads from the one before and writes to the one after, and
+// FanOut and Merge run a slow stage on several goroutines.
+//
+// Every stage owns and closes its output channel, and every send and
+// receive also watches ctx. Canceling ctx therefore unwinds the whole
+// pipeline, even when the consumer has stopped reading or the source is
+// never closed, without leaking a goroutine.
+package pipeline
+
+import (
+	"context"
+	"iter"
+	"sync"
+)
+
+// From emits items in order and closes the channel.
+func From[T any](ctx context.Context, items ...T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for _, item := range items {
+			if !send(ctx, out, item) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Generate emits next() until it reports false.
+func Generate[T any](ctx context.Context, next func() (T, bool)) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for {
+			v, ok := next()
+			if !ok || !send(ctx, out, v) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Map applies fn to every value from in.
+func Map[In, Out any](ctx context.Context, in <-chan In, fn func(context.Context, In) Out) <-chan Out {
+	out := make(chan Out)
+	go func() {
+		defer close(out)
+		for v := range receive(ctx, in) {
+			if !send(ctx, out, fn(ctx, v)) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Filter passes on the values keep returns true for.
+func Filter[T any](ctx context.Context, in <-chan T, keep func(T) bool) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for v := range receive(ctx, in) {
+			if keep(v) && !send(ctx, out, v) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// FanOut runs n copies of the Map stage over in. Each value goes to
+// exactly one copy, so output order is lost, Merge collects the outputs.
+func FanOut[In, Out any](ctx context.Context, in <-chan In, n int, fn func(context.Context, In) Out) []<-chan Out {
+	outs := make([]<-chan Out, max(n, 1))
+	for i := range outs {
+		outs[i] = Map(ctx, in, fn)
+	}
+	return outs
+}
+
+// Merge interleaves every input onto one channel, closed once all inputs are.
+func Merge[T any](ctx context.Context, ins ...<-chan T) <-chan T {
+	out := make(chan T)
+	var wg sync.WaitGroup
+	for _, in := range ins {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for v := range receive(ctx, in) {
+				if !send(ctx, out, v) {
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// Collect drains in into a slice. It stops early with ctx.Err() when ctx is
+// done, returning what it had collected.
+func Collect[T any](ctx context.Context, in <-chan T) ([]T, error) {
+	var items []T
+	for {
+		select {
+		case v, ok := <-in:
+			if !ok {
+				return items, nil
+			}
+			items = append(items, v)
+		case <-ctx.Done():
+			return items, ctx.Err()
+		}
+	}
+}
+
+// receive ranges over in until it is closed or ctx ends. Ranging over in
+// directly would outlive ctx when in comes from outside the pipeline and
+// is never closed.
+func receive[T any](ctx context.Context, in <-chan T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok || !yield(v) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// send reports false when ctx ended first, the caller's cue to return.
+func send[T any](ctx context.Context, out chan<- T, v T) bool {
+	select {
+	case out <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,88 @@
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/pipeline"
+)
+
+// TestMain fails the package if a stage outlives the test that built it.
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+func TestStages(t *testing.T) {
+	ctx := context.Background()
+	n := 0
+	count := pipeline.Generate(ctx, func() (int, bool) { n++; return n, n <= 10 })
+	even := pipeline.Filter(ctx, count, func(n int) bool { return n%2 == 0 })
+	squares := pipeline.Merge(ctx, pipeline.FanOut(ctx, even, 3, func(_ context.Context, n int) int { return n * n })...)
+	got, err := pipeline.Collect(ctx, squares)
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	// FanOut gives up the order
+	slices.Sort(got)
+	if want := []int{4, 16, 36, 64, 100}; !slices.Equal(got, want) {
+		t.Errorf("Collect = %v, want %v", got, want)
+	}
+
+	doubled, err := pipeline.Collect(ctx, pipeline.Map(ctx, pipeline.From(ctx, 1, 2, 3), func(_ context.Context, n int) int { return 2 * n }))
+	if err != nil || !slices.Equal(doubled, []int{2, 4, 6}) {
+		t.Errorf("Map = %v, %v, want [2 4 6] in order", doubled, err)
+	}
+}
+
+// TestCancelStopsStages cancels a pipeline whose source is never closed:
+// every stage still exits, which TestMain checks.
+func TestCancelStopsStages(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	source := make(chan int)
+	even := pipeline.Filter(ctx, source, func(n int) bool { return n%2 == 0 })
+	out := pipeline.Merge(ctx, pipeline.FanOut(ctx, even, 3, func(_ context.Context, n int) int { return n * n })...)
+	source <- 2
+	if got := <-out; got != 4 {
+		t.Errorf("first value = %d, want 4", got)
+	}
+	cancel()
+	for range out {
+	}
+
+	if _, err := pipeline.Collect(ctx, pipeline.Map(ctx, make(chan int), func(_ context.Context, n int) int { return n })); !errors.Is(err, context.Canceled) {
+		t.Errorf("Collect: err = %v, want context.Canceled", err)
+	}
+}
+
+// BenchmarkFanOut runs batches of items through a stage that waits on
+// something slow, as a database write would, at several widths. The time
+// per batch falls with the width until the stage stops being the
+// bottleneck.
+//
+//	go test ./pipeline -run '^$' -bench FanOut
+func BenchmarkFanOut(b *testing.B) {
+	const items = 32
+	slow := func(_ context.Context, n int) int {
+		time.Sleep(time.Millisecond)
+		return n
+	}
+	batch := make([]int, items)
+	for _, width := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("width=%d", width), func(b *testing.B) {
+			ctx := b.Context()
+			for b.Loop() {
+				out := pipeline.Merge(ctx, pipeline.FanOut(ctx, pipeline.From(ctx, batch...), width, slow)...)
+				got, err := pipeline.Collect(ctx, out)
+				if err != nil || len(got) != items {
+					b.Fatalf("Collect = %d items, %v, want %d", len(got), err, items)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+// Command filestore is a store backend built as a Go plugin rather than
+// compiled into the program. Loading it registers "file", a memory store
+// restored from the gob snapshot at the DSN on open and saved back there
+// on Close:
+//
+//	go build -buildmode=plugin -o filestore.so ./plugins/filestore
+//	go run ./cmd/http -db-plugin filestore.so -db-driver file -db users.gob
+//
+// It registers from init like the compiled-in backends, plugin.Open runs
+// it, so the program finds it through registry.Open by name either way.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/registry"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+func init() {
+	registry.Register("file", open)
+}
+
+// store is the memory store with every method it has, List and Query
+// included, plus a Close that writes it out.
+type store struct {
+	*db.MemoryStore
+	path string
+}
+
+func (s *store) Close() error {
+	return s.Save(context.Background(), s.path)
+}
+
+func open(ctx context.Context, path string) (service.UserStorer, error) {
+	if path == "" {
+		return nil, fmt.Errorf("file needs a snapshot path: %w", errs.ErrInvalidInput)
+	}
+	s := &store{MemoryStore: db.NewMemoryStore(), path: path}
+	// the first run has no snapshot yet
+	if err := s.Load(ctx, path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return s, nil
+}
+
+// main is never called, a plugin is only a package main so it can be
+// built with -buildmode=plugin.
+func main() {}
@@ -0,0 +1,61 @@
+// Package profiling writes CPU and heap profiles to files, for processes
+// where exposing net/http/pprof is not an option or where the interesting
+// moment is easier to catch with kill -USR1 than with curl.
+package profiling
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// CPU records a CPU profile for d, or until ctx is done, into
+// dir/cpu-<timestamp>.pprof and returns the file's path.
+func CPU(ctx context.Context, dir string, d time.Duration) (string, error) {
+	f, err := create(dir, "cpu")
+	if err != nil {
+		return "", errs.Wrap("profiling.CPU", err)
+	}
+	defer f.Close()
+	// only one CPU profile can run per process
+	if err := pprof.StartCPUProfile(f); err != nil {
+		return "", errs.Wrap("profiling.CPU", err)
+	}
+	t := time.NewTimer(d)
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+		t.Stop()
+	}
+	pprof.StopCPUProfile()
+	return f.Name(), nil
+}
+
+// Heap writes a heap profile to dir/heap-<timestamp>.pprof. It runs a GC
+// first so the profile reflects live objects rather than garbage.
+func Heap(dir string) (string, error) {
+	f, err := create(dir, "heap")
+	if err != nil {
+		return "", errs.Wrap("profiling.Heap", err)
+	}
+	defer f.Close()
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		return "", errs.Wrap("profiling.Heap", err)
+	}
+	return f.Name(), nil
+}
+
+func create(dir, kind string) (*os.File, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%s-%s.pprof", kind, time.Now().Format("20060102T150405.000"))
+	return os.Create(filepath.Join(dir, name))
+}
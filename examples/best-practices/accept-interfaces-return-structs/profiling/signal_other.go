@@ -0,0 +1,17 @@
+//go:build !unix
+
+package profiling
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// OnSignal is a no-op without SIGUSR1, call CPU and Heap directly instead.
+func OnSignal(ctx context.Context, dir string, cpuFor time.Duration, logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.WarnContext(ctx, "profiling on signal is not supported on this platform")
+}
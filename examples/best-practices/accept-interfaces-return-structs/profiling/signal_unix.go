@@ -0,0 +1,47 @@
+//go:build unix
+
+package profiling
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// OnSignal captures a cpuFor long CPU profile followed by a heap profile
+// into dir every time the process receives SIGUSR1, until ctx is done:
+//
+//	kill -USR1 <pid>
+//	go tool pprof -http :8081 profiles/cpu-*.pprof
+//
+// Signals arriving while a capture is running are dropped rather than queued.
+func OnSignal(ctx context.Context, dir string, cpuFor time.Duration, logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGUSR1)
+	go func() {
+		defer signal.Stop(sigs)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sigs:
+			}
+			logger.InfoContext(ctx, "capturing profiles", slog.String("dir", dir), slog.Duration("cpu", cpuFor))
+			cpu, err := CPU(ctx, dir, cpuFor)
+			if err != nil {
+				logger.ErrorContext(ctx, "cpu profile failed", slog.String("error", err.Error()))
+			}
+			heap, err := Heap(dir)
+			if err != nil {
+				logger.ErrorContext(ctx, "heap profile failed", slog.String("error", err.Error()))
+			}
+			logger.InfoContext(ctx, "profiles written", slog.String("cpu", cpu), slog.String("heap", heap))
+		}
+	}()
+}
@@ -0,0 +1,147 @@
+package query
+
+import (
+	"slices"
+	"strings"
+)
+
+// Match reports whether r meets every condition of q. Order and limit
+// apply to a set of records, see Filter.
+func (q Query) Match(r Record) bool {
+	for _, c := range q.conds {
+		switch c.op {
+		case idAfter:
+			if r.ID <= c.s {
+				return false
+			}
+		case emailLike:
+			if !like(lowerASCII(r.Email), lowerASCII(c.s)) {
+				return false
+			}
+		case createdAfter:
+			if !r.CreatedAt.After(c.t) {
+				return false
+			}
+		case notDeleted:
+			if !r.DeletedAt.IsZero() {
+				return false
+			}
+		case tenantIs:
+			if r.Tenant != c.s {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Filter runs q over items in memory: the ones that match, sorted, up to
+// the limit. record reads the fields q looks at from an item.
+func Filter[T any](q Query, items []T, record func(T) Record) []T {
+	var out []T
+	for _, item := range items {
+		if q.Match(record(item)) {
+			out = append(out, item)
+		}
+	}
+	orders := q.orders()
+	slices.SortFunc(out, func(a, b T) int {
+		return compare(orders, record(a), record(b))
+	})
+	if q.limit > 0 && len(out) > q.limit {
+		out = out[:q.limit]
+	}
+	return out
+}
+
+// compare orders records as ORDER BY does. Strings compare bytewise, as
+// SQLite's default BINARY collation does.
+func compare(orders []order, a, b Record) int {
+	for _, o := range orders {
+		var c int
+		switch o.field {
+		case ID:
+			c = strings.Compare(a.ID, b.ID)
+		case Email:
+			c = strings.Compare(a.Email, b.Email)
+		case Name:
+			c = strings.Compare(a.Name, b.Name)
+		case Created:
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		case Updated:
+			c = a.UpdatedAt.Compare(b.UpdatedAt)
+		}
+		if o.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// lowerASCII folds A-Z only, what SQLite's lower() does without ICU.
+func lowerASCII(s string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
+
+// token is one element of a LIKE pattern: a literal rune, _ or %.
+type token struct {
+	r        rune
+	one, any bool
+}
+
+func tokenize(pattern string) []token {
+	var tokens []token
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			tokens = append(tokens, token{r: r})
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '_':
+			tokens = append(tokens, token{one: true})
+		case r == '%':
+			tokens = append(tokens, token{any: true})
+		default:
+			tokens = append(tokens, token{r: r})
+		}
+	}
+	return tokens
+}
+
+// like matches s against a LIKE pattern a rune at a time. On a mismatch it
+// backtracks to the last %, letting it swallow one more rune, so it runs in
+// O(len(s) * len(pattern)) without recursion.
+func like(s, pattern string) bool {
+	tokens, runes := tokenize(pattern), []rune(s)
+	ti, si := 0, 0
+	star, mark := -1, 0
+	for si < len(runes) {
+		switch {
+		case ti < len(tokens) && tokens[ti].any:
+			star, mark = ti, si
+			ti++
+		case ti < len(tokens) && (tokens[ti].one || tokens[ti].r == runes[si]):
+			ti++
+			si++
+		case star >= 0:
+			mark++
+			ti, si = star+1, mark
+		default:
+			return false
+		}
+	}
+	for ti < len(tokens) && tokens[ti].any {
+		ti++
+	}
+	return ti == len(tokens)
+}
@@ -0,0 +1,180 @@
+// Package query builds user queries once and runs them anywhere. A Query
+// is a chain of conditions, an order, and a limit:
+//
+//	q := query.Users().WhereEmailLike("%@example.com").OrderBy(query.Created).Limit(10)
+//
+// It compiles two ways, to a parameterized SQL statement for the SQL
+// stores with SQL, and to Match and Filter for stores that keep users in
+// memory. Every condition is written for both side by side, so they cannot
+// drift: the same Query returns the same users, in the same order, from
+// either. The sqlite tests check that they do.
+//
+// Each method returns a new Query and leaves its receiver alone, so a base
+// query can be shared and extended without one caller's conditions leaking
+// into another's.
+package query
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Field is a users column a Query can order by.
+type Field int
+
+const (
+	ID Field = iota
+	Email
+	Name
+	Created
+	Updated
+)
+
+// column holds each Field's name in SQL. Fields are never taken from
+// input as strings, so nothing but these names reaches a statement.
+var column = [...]string{
+	ID:      "id",
+	Email:   "email",
+	Name:    "name",
+	Created: "created_at",
+	Updated: "updated_at",
+}
+
+func (f Field) String() string {
+	if f < 0 || int(f) >= len(column) {
+		return fmt.Sprintf("Field(%d)", int(f))
+	}
+	return column[f]
+}
+
+// Record is what a Query reads from a user. The query package cannot
+// import service, service imports it, so stores hand over users as
+// Records, see service.User.Record.
+type Record struct {
+	// Tenant is the tenant the user belongs to, see WhereTenant.
+	Tenant    string
+	ID        string
+	Email     string
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt time.Time
+}
+
+type op int
+
+const (
+	idAfter op = iota
+	emailLike
+	createdAfter
+	notDeleted
+	tenantIs
+)
+
+// cond is one WHERE condition, its operand in s or t.
+type cond struct {
+	op op
+	s  string
+	t  time.Time
+}
+
+type order struct {
+	field Field
+	desc  bool
+}
+
+// Query is a users query. The zero value, like Users(), matches every user
+// in ID order.
+type Query struct {
+	conds []cond
+	order []order
+	limit int
+}
+
+// Users starts a query over the users table.
+func Users() Query {
+	return Query{}
+}
+
+// with returns q plus c. The three-index slice makes append copy, so
+// queries built from the same base never share a backing array.
+func (q Query) with(c cond) Query {
+	q.conds = append(q.conds[:len(q.conds):len(q.conds)], c)
+	return q
+}
+
+// WhereIDAfter keeps users whose ID sorts after id, the keyset condition
+// behind cursor paging.
+func (q Query) WhereIDAfter(id string) Query {
+	return q.with(cond{op: idAfter, s: id})
+}
+
+// WhereEmailLike keeps users whose email matches pattern as in SQL LIKE:
+// % matches any run of characters, _ any one, and a backslash makes the
+// next character literal, see EscapeLike. Matching ignores ASCII case only,
+// like SQLite's lower(); Postgres folds other letters too.
+func (q Query) WhereEmailLike(pattern string) Query {
+	return q.with(cond{op: emailLike, s: pattern})
+}
+
+// WhereCreatedAfter keeps users created after t.
+func (q Query) WhereCreatedAfter(t time.Time) Query {
+	// stores keep UTC, and SQLite compares timestamps as text
+	return q.with(cond{op: createdAfter, t: t.UTC()})
+}
+
+// ExcludeDeleted drops soft deleted users.
+func (q Query) ExcludeDeleted() Query {
+	return q.with(cond{op: notDeleted})
+}
+
+// WhereTenant keeps the users of tenant id. The SQL stores add it to every
+// query they run, their tables hold every tenant; the memory store keeps
+// each tenant apart before filtering and has no need of it.
+func (q Query) WhereTenant(id string) Query {
+	return q.with(cond{op: tenantIs, s: id})
+}
+
+// OrderBy sorts by f ascending, after any earlier OrderBy. Ties are always
+// broken by ID, so a query's order is total and the same in every store.
+func (q Query) OrderBy(f Field) Query {
+	return q.orderBy(f, false)
+}
+
+// OrderByDesc sorts by f descending, after any earlier OrderBy.
+func (q Query) OrderByDesc(f Field) Query {
+	return q.orderBy(f, true)
+}
+
+func (q Query) orderBy(f Field, desc bool) Query {
+	if f < 0 || int(f) >= len(column) {
+		panic(fmt.Sprintf("query: unknown field %s", f))
+	}
+	q.order = append(q.order[:len(q.order):len(q.order)], order{field: f, desc: desc})
+	return q
+}
+
+// Limit caps the number of users returned, zero or less means no cap.
+func (q Query) Limit(n int) Query {
+	q.limit = max(n, 0)
+	return q
+}
+
+// orders is q's order with the ID tiebreak, unless ID is already in it.
+func (q Query) orders() []order {
+	for _, o := range q.order {
+		if o.field == ID {
+			return q.order
+		}
+	}
+	return append(q.order[:len(q.order):len(q.order)], order{field: ID})
+}
+
+// EscapeLike quotes s's wildcards, so WhereEmailLike("%"+EscapeLike(s)+"%")
+// finds s anywhere in an email, underscores and all.
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
@@ -0,0 +1,44 @@
+package query_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/query"
+)
+
+func TestSQL(t *testing.T) {
+	q := query.Users().WhereEmailLike("%@example.com").ExcludeDeleted().OrderBy(query.Created).Limit(10)
+	for _, tt := range []struct {
+		name    string
+		dialect query.Dialect
+		want    string
+	}{
+		{"sqlite", query.SQLite, `SELECT id FROM users WHERE lower(email) LIKE lower(?) ESCAPE '\' AND deleted_at IS NULL ORDER BY created_at, id LIMIT ?`},
+		{"postgres", query.Postgres, `SELECT id FROM users WHERE lower(email) LIKE lower($1) ESCAPE '\' AND deleted_at IS NULL ORDER BY created_at, id LIMIT $2`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt, args := q.SQL(tt.dialect, "id")
+			if stmt != tt.want {
+				t.Errorf("SQL = %s, want %s", stmt, tt.want)
+			}
+			if want := []any{"%@example.com", 10}; !slices.Equal(args, want) {
+				t.Errorf("args = %v, want %v", args, want)
+			}
+		})
+	}
+}
+
+// TestExtendingCopies checks extending a query leaves the one it came from
+// alone.
+func TestExtendingCopies(t *testing.T) {
+	active := query.Users().ExcludeDeleted().OrderBy(query.Name)
+	_ = active.WhereEmailLike("ada%")
+	bob := active.WhereEmailLike("bob%").OrderByDesc(query.Created)
+	if stmt, _ := active.SQL(query.SQLite, "id"); stmt != `SELECT id FROM users WHERE deleted_at IS NULL ORDER BY name, id` {
+		t.Errorf("the base became %s", stmt)
+	}
+	if stmt, _ := bob.SQL(query.SQLite, "id"); stmt != `SELECT id FROM users WHERE deleted_at IS NULL AND lower(email) LIKE lower(?) ESCAPE '\' ORDER BY name, created_at DESC, id` {
+		t.Errorf("extended to %s", stmt)
+	}
+}
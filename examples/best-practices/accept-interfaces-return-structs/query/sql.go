@@ -0,0 +1,71 @@
+package query
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect is the SQL flavour a Query compiles to. Only the placeholders
+// differ between the two.
+type Dialect int
+
+const (
+	SQLite Dialect = iota
+	Postgres
+)
+
+// Placeholder returns the marker for the nth argument, counting from 1.
+func (d Dialect) Placeholder(n int) string {
+	if d == Postgres {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// SQL compiles q to a statement selecting columns from the users table,
+// and the arguments for its placeholders. Every value travels as an
+// argument, only column names from this package and the caller's columns
+// are written into the statement.
+func (q Query) SQL(d Dialect, columns string) (string, []any) {
+	var b strings.Builder
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return d.Placeholder(len(args))
+	}
+	b.WriteString("SELECT " + columns + " FROM users")
+	for i, c := range q.conds {
+		if i == 0 {
+			b.WriteString(" WHERE ")
+		} else {
+			b.WriteString(" AND ")
+		}
+		switch c.op {
+		case idAfter:
+			b.WriteString("id > " + arg(c.s))
+		case emailLike:
+			b.WriteString("lower(email) LIKE lower(" + arg(c.s) + `) ESCAPE '\'`)
+		case createdAfter:
+			b.WriteString("created_at > " + arg(c.t))
+		case notDeleted:
+			b.WriteString("deleted_at IS NULL")
+		case tenantIs:
+			b.WriteString("tenant_id = " + arg(c.s))
+		}
+	}
+	for i, o := range q.orders() {
+		if i == 0 {
+			b.WriteString(" ORDER BY ")
+		} else {
+			b.WriteString(", ")
+		}
+		b.WriteString(column[o.field])
+		if o.desc {
+			b.WriteString(" DESC")
+		}
+	}
+	if q.limit > 0 {
+		b.WriteString(" LIMIT " + arg(q.limit))
+	}
+	return b.String(), args
+}
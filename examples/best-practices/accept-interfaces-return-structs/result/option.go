@@ -0,0 +1,66 @@
+package result
+
+// Option holds a value or nothing. The zero value is None.
+type Option[T any] struct {
+	value T
+	ok    bool
+}
+
+func Some[T any](value T) Option[T] {
+	return Option[T]{value: value, ok: true}
+}
+
+func None[T any]() Option[T] {
+	return Option[T]{}
+}
+
+// OptionOf wraps a (T, bool) pair such as a map lookup:
+//
+//	v, ok := m[key]
+//	o := result.OptionOf(v, ok)
+func OptionOf[T any](value T, ok bool) Option[T] {
+	if !ok {
+		return None[T]()
+	}
+	return Some(value)
+}
+
+func (o Option[T]) IsSome() bool {
+	return o.ok
+}
+
+func (o Option[T]) Get() (T, bool) {
+	return o.value, o.ok
+}
+
+// UnwrapOr returns the value, or def when there is none.
+func (o Option[T]) UnwrapOr(def T) T {
+	if !o.ok {
+		return def
+	}
+	return o.value
+}
+
+// OkOr turns None into an error Result.
+func (o Option[T]) OkOr(err error) Result[T] {
+	if !o.ok {
+		return Err[T](err)
+	}
+	return Ok(o.value)
+}
+
+// MapOption applies f to the value of a Some.
+func MapOption[T, U any](o Option[T], f func(T) U) Option[U] {
+	if !o.ok {
+		return None[U]()
+	}
+	return Some(f(o.value))
+}
+
+// AndThenOption chains a step that may itself have nothing.
+func AndThenOption[T, U any](o Option[T], f func(T) Option[U]) Option[U] {
+	if !o.ok {
+		return None[U]()
+	}
+	return f(o.value)
+}
@@ -0,0 +1,86 @@
+// Package result has Result[T] and Option[T], the types other languages use
+// instead of Go's (T, error) and (T, bool) pairs. It is here to compare the
+// two styles, not to replace the Go one, see cmd/result for the same flow
+// written both ways.
+//
+// What the types buy: a chain of steps reads as one expression, and a value
+// cannot be used without going through the check. What they cost: Go has no
+// ? operator or pattern matching, so the chain is a pile of closures and
+// every step is a function call. Methods cannot take type parameters, so the
+// steps that change the type, Map and AndThen, are package functions and
+// read inside out. Errors lose their position: a failing step is not where
+// the error surfaces. And everything else in Go, the standard library
+// included, speaks (T, error), so a Result spends most of its life being
+// converted at the edges.
+package result
+
+// Result holds either a value or an error. The zero value is Ok with the
+// zero T.
+type Result[T any] struct {
+	value T
+	err   error
+}
+
+func Ok[T any](value T) Result[T] {
+	return Result[T]{value: value}
+}
+
+func Err[T any](err error) Result[T] {
+	return Result[T]{err: err}
+}
+
+// Of wraps a (T, error) return, the bridge from ordinary Go code:
+//
+//	r := result.Of(strconv.Atoi(s))
+func Of[T any](value T, err error) Result[T] {
+	if err != nil {
+		return Err[T](err)
+	}
+	return Ok(value)
+}
+
+func (r Result[T]) IsOk() bool {
+	return r.err == nil
+}
+
+func (r Result[T]) Err() error {
+	return r.err
+}
+
+// Get is the bridge back, the usual pair.
+func (r Result[T]) Get() (T, error) {
+	return r.value, r.err
+}
+
+// UnwrapOr returns the value, or def when r holds an error.
+func (r Result[T]) UnwrapOr(def T) T {
+	if r.err != nil {
+		return def
+	}
+	return r.value
+}
+
+// Option drops the error, keeping only whether there was a value.
+func (r Result[T]) Option() Option[T] {
+	if r.err != nil {
+		return None[T]()
+	}
+	return Some(r.value)
+}
+
+// Map applies f to the value of an Ok result, an error passes through.
+func Map[T, U any](r Result[T], f func(T) U) Result[U] {
+	if r.err != nil {
+		return Err[U](r.err)
+	}
+	return Ok(f(r.value))
+}
+
+// AndThen chains a step that can itself fail, an error passes through and
+// f is not called.
+func AndThen[T, U any](r Result[T], f func(T) Result[U]) Result[U] {
+	if r.err != nil {
+		return Err[U](r.err)
+	}
+	return f(r.value)
+}
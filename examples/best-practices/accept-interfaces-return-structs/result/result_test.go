@@ -0,0 +1,102 @@
+package result_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/result"
+)
+
+var boom = errors.New("boom")
+
+func TestResult(t *testing.T) {
+	if v, err := result.Of(strconv.Atoi("42")).Get(); v != 42 || err != nil {
+		t.Errorf("Of(Atoi(42)).Get = %d, %v, want 42, nil", v, err)
+	}
+	bad := result.Of(strconv.Atoi("x"))
+	if bad.IsOk() || !errors.Is(bad.Err(), strconv.ErrSyntax) {
+		t.Errorf("Of(Atoi(x)).Err = %v, want ErrSyntax", bad.Err())
+	}
+	if got := bad.UnwrapOr(7); got != 7 {
+		t.Errorf("UnwrapOr = %d, want the fallback 7", got)
+	}
+	if _, ok := bad.Option().Get(); ok {
+		t.Error("Option of an error = Some")
+	}
+	var zero result.Result[int]
+	if !zero.IsOk() || zero.UnwrapOr(1) != 0 {
+		t.Error("the zero Result is not Ok with the zero value")
+	}
+}
+
+// TestChains checks an error short circuits: later steps never run and
+// the first error comes out the end unchanged.
+func TestChains(t *testing.T) {
+	double := func(n int) int { return n * 2 }
+	half := func(n int) result.Result[int] {
+		if n%2 != 0 {
+			return result.Err[int](boom)
+		}
+		return result.Ok(n / 2)
+	}
+	for _, tt := range []struct {
+		name string
+		in   result.Result[int]
+		want int
+		err  error
+	}{
+		{"ok", result.Ok(3), 3, nil},
+		{"error at the start", result.Err[int](boom), 0, boom},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := result.AndThen(result.Map(tt.in, double), half).Get()
+			if got != tt.want || !errors.Is(err, tt.err) {
+				t.Errorf("AndThen(Map(...)) = %d, %v, want %d, %v", got, err, tt.want, tt.err)
+			}
+		})
+	}
+	called := false
+	result.AndThen(half(3), func(n int) result.Result[int] { called = true; return result.Ok(n) })
+	if called {
+		t.Error("AndThen called f after an error")
+	}
+}
+
+func TestOption(t *testing.T) {
+	m := map[string]int{"a": 1}
+	lookup := func(key string) result.Option[int] {
+		v, ok := m[key]
+		return result.OptionOf(v, ok)
+	}
+	if got := lookup("a").UnwrapOr(0); got != 1 {
+		t.Errorf("lookup(a).UnwrapOr = %d, want 1", got)
+	}
+	none := lookup("b")
+	if none.IsSome() || none.UnwrapOr(9) != 9 {
+		t.Error("a missing key is not None")
+	}
+	var zero result.Option[int]
+	if zero.IsSome() {
+		t.Error("the zero Option = Some")
+	}
+
+	plusOne := func(n int) int { return n + 1 }
+	if got, ok := result.MapOption(lookup("a"), plusOne).Get(); !ok || got != 2 {
+		t.Errorf("MapOption(Some(1)) = %d, %t, want 2, true", got, ok)
+	}
+	if result.MapOption(none, plusOne).IsSome() {
+		t.Error("MapOption(None) = Some")
+	}
+	positive := func(n int) result.Option[int] { return result.OptionOf(n, n > 0) }
+	if result.AndThenOption(result.Some(-1), positive).IsSome() {
+		t.Error("AndThenOption did not take f's None")
+	}
+
+	if err := none.OkOr(boom).Err(); !errors.Is(err, boom) {
+		t.Errorf("OkOr(None).Err = %v, want boom", err)
+	}
+	if v, err := lookup("a").OkOr(boom).Get(); v != 1 || err != nil {
+		t.Errorf("OkOr(Some(1)).Get = %d, %v, want 1, nil", v, err)
+	}
+}
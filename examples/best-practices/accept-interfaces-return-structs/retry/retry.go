@@ -0,0 +1,180 @@
+// Package retry calls a function until it succeeds, the policy gives up, or
+// the context ends. It is the loop behind the db/retry store decorator and
+// anything else that talks to a dependency that fails now and then:
+//
+//	err := retry.Do(ctx, retry.DefaultPolicy(), func(ctx context.Context) error {
+//		return client.Ping(ctx)
+//	})
+//
+// Waiting between attempts respects ctx, a caller that gives up is not held
+// hostage by the backoff.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// Backoff decides the wait before a retry, attempt is 1 before the first
+// retry, 2 before the second, and so on.
+type Backoff interface {
+	Delay(attempt int) time.Duration
+}
+
+// BackoffFunc adapts a function to a Backoff.
+type BackoffFunc func(attempt int) time.Duration
+
+func (f BackoffFunc) Delay(attempt int) time.Duration { return f(attempt) }
+
+// Constant waits d before every retry.
+func Constant(d time.Duration) Backoff {
+	return BackoffFunc(func(int) time.Duration { return d })
+}
+
+// Exponential waits base before the first retry and doubles it each time,
+// capped at max when max is positive. Without a cap the wait stops growing
+// at the longest time.Duration rather than overflowing.
+func Exponential(base, max time.Duration) Backoff {
+	return BackoffFunc(func(attempt int) time.Duration {
+		if base <= 0 {
+			return 0
+		}
+		d := base
+		// doubled one step at a time, the cap checked before each, a shift
+		// by attempt could wrap round to a short wait or a negative one
+		for range attempt - 1 {
+			if max > 0 && d >= max {
+				break
+			}
+			if d > math.MaxInt64/2 {
+				d = math.MaxInt64
+				break
+			}
+			d *= 2
+		}
+		if max > 0 && d > max {
+			d = max
+		}
+		return d
+	})
+}
+
+// Jitter draws each wait uniformly from [0, b's delay), "full jitter", so
+// clients that failed together do not retry together.
+func Jitter(b Backoff) Backoff {
+	return BackoffFunc(func(attempt int) time.Duration {
+		d := b.Delay(attempt)
+		if d <= 0 {
+			return 0
+		}
+		return time.Duration(rand.Int63n(int64(d)))
+	})
+}
+
+// Policy controls how failed calls are retried.
+type Policy struct {
+	// MaxAttempts is the total number of calls, including the first one.
+	// Zero means no limit when MaxElapsed is set, and one call otherwise.
+	MaxAttempts int
+	// MaxElapsed stops retrying once the next wait would end past this much
+	// time since the first call, zero means no limit.
+	MaxElapsed time.Duration
+	// Backoff is the wait between attempts, nil retries immediately.
+	Backoff Backoff
+	// Retryable reports whether err is worth another attempt,
+	// nil uses Transient.
+	Retryable func(err error) bool
+	// Clock is the time source, nil uses clock.Real.
+	Clock clock.Clock
+}
+
+// DefaultPolicy makes three attempts with jittered exponential backoff from
+// 50ms, capped at a second.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts: 3,
+		Backoff:     Jitter(Exponential(50*time.Millisecond, time.Second)),
+		Retryable:   Transient,
+	}
+}
+
+// Transient treats everything except the errs taxonomy and context errors as
+// temporary. Not found, conflicts, and bad input will fail the same way again.
+func Transient(err error) bool {
+	switch {
+	case errors.Is(err, errs.ErrNotFound),
+		errors.Is(err, errs.ErrConflict),
+		errors.Is(err, errs.ErrInvalidInput),
+		errors.Is(err, errs.ErrUnauthenticated),
+		errors.Is(err, errs.ErrForbidden),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return false
+	}
+	return true
+}
+
+// Do calls fn until it returns nil or a non-retryable error, or the policy
+// runs out, and returns the last error. If ctx ends during a wait the last
+// error is returned joined with ctx.Err().
+func Do(ctx context.Context, p Policy, fn func(ctx context.Context) error) error {
+	_, err := DoValue(ctx, p, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+// DoValue is Do for a function that also returns a value, the value of the
+// successful call is returned.
+func DoValue[T any](ctx context.Context, p Policy, fn func(ctx context.Context) (T, error)) (T, error) {
+	p = p.normalize()
+	start := p.Clock.Now()
+	for attempt := 1; ; attempt++ {
+		v, err := fn(ctx)
+		if err == nil || !p.Retryable(err) {
+			return v, err
+		}
+		if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+			return v, err
+		}
+		var wait time.Duration
+		if p.Backoff != nil {
+			wait = p.Backoff.Delay(attempt)
+		}
+		if p.MaxElapsed > 0 && p.Clock.Now().Add(wait).Sub(start) > p.MaxElapsed {
+			return v, err
+		}
+		if wait <= 0 {
+			if cerr := ctx.Err(); cerr != nil {
+				return v, errors.Join(err, cerr)
+			}
+			continue
+		}
+		timer := p.Clock.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return v, errors.Join(err, ctx.Err())
+		case <-timer.C():
+		}
+	}
+}
+
+func (p Policy) normalize() Policy {
+	if p.MaxAttempts < 1 && p.MaxElapsed <= 0 {
+		p.MaxAttempts = 1
+	}
+	if p.Retryable == nil {
+		p.Retryable = Transient
+	}
+	if p.Clock == nil {
+		p.Clock = clock.Real
+	}
+	return p
+}
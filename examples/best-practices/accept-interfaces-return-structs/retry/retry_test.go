@@ -0,0 +1,226 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/retry"
+)
+
+var errFlaky = errors.New("flaky")
+
+// flaky fails its first n calls with errFlaky, counting every call.
+type flaky struct {
+	n     int
+	calls int
+}
+
+func (f *flaky) call(context.Context) error {
+	f.calls++
+	if f.calls <= f.n {
+		return errFlaky
+	}
+	return nil
+}
+
+// drive advances fake by step every time the code under test starts
+// waiting, until done is closed.
+func drive(fake *clock.Fake, step time.Duration, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if fake.Waiters() > 0 {
+			fake.Advance(step)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestExponential(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		base, max time.Duration
+		attempt   int
+		want      time.Duration
+	}{
+		{"first", time.Millisecond, 0, 1, time.Millisecond},
+		{"doubled", time.Millisecond, 0, 4, 8 * time.Millisecond},
+		{"capped", time.Millisecond, time.Second, 20, time.Second},
+		{"capped far out", time.Millisecond, time.Second, 1000, time.Second},
+		{"no cap saturates", time.Millisecond, 0, 64, math.MaxInt64},
+		{"no cap far out", time.Millisecond, 0, 1000, math.MaxInt64},
+		{"odd base past the shift's range", 3, 0, 63, math.MaxInt64},
+		{"no base", 0, time.Second, 5, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retry.Exponential(tt.base, tt.max).Delay(tt.attempt); got != tt.want {
+				t.Errorf("Delay(%d) = %v, want %v", tt.attempt, got, tt.want)
+			}
+		})
+	}
+
+	// never shrinks, where a shift would wrap round
+	for _, base := range []time.Duration{1, 3, time.Millisecond, 7 * time.Second} {
+		b := retry.Exponential(base, 0)
+		prev := b.Delay(1)
+		for attempt := 2; attempt <= 200; attempt++ {
+			d := b.Delay(attempt)
+			if d < prev {
+				t.Fatalf("base %v: Delay(%d) = %v, shorter than Delay(%d) = %v", base, attempt, d, attempt-1, prev)
+			}
+			prev = d
+		}
+	}
+}
+
+func TestJitter(t *testing.T) {
+	b := retry.Jitter(retry.Constant(10 * time.Millisecond))
+	var lo, hi time.Duration = math.MaxInt64, 0
+	for range 1000 {
+		d := b.Delay(1)
+		if d < 0 || d >= 10*time.Millisecond {
+			t.Fatalf("Delay = %v, want it in [0, 10ms)", d)
+		}
+		lo, hi = min(lo, d), max(hi, d)
+	}
+	// full jitter spreads the waits over the whole range
+	if lo > 2*time.Millisecond || hi < 8*time.Millisecond {
+		t.Errorf("1000 waits between %v and %v, want them spread over [0, 10ms)", lo, hi)
+	}
+	if d := retry.Jitter(retry.Constant(0)).Delay(1); d != 0 {
+		t.Errorf("Jitter of no wait = %v, want 0", d)
+	}
+}
+
+func TestDo(t *testing.T) {
+	ctx := context.Background()
+	for _, tt := range []struct {
+		name   string
+		policy retry.Policy
+		fails  int
+		calls  int
+		err    error
+	}{
+		{"first try", retry.Policy{MaxAttempts: 3}, 0, 1, nil},
+		{"after retries", retry.Policy{MaxAttempts: 3}, 2, 3, nil},
+		{"out of attempts", retry.Policy{MaxAttempts: 3}, 5, 3, errFlaky},
+		{"one call by default", retry.Policy{}, 5, 1, errFlaky},
+		{"not retryable", retry.Policy{MaxAttempts: 3, Retryable: func(err error) bool { return false }}, 5, 1, errFlaky},
+		{"retryable by the predicate", retry.Policy{MaxAttempts: 5, Retryable: func(err error) bool { return errors.Is(err, errFlaky) }}, 3, 4, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &flaky{n: tt.fails}
+			if err := retry.Do(ctx, tt.policy, f.call); !errors.Is(err, tt.err) || (tt.err == nil) != (err == nil) {
+				t.Errorf("Do: err = %v, want %v", err, tt.err)
+			}
+			if f.calls != tt.calls {
+				t.Errorf("%d calls, want %d", f.calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestDoValue(t *testing.T) {
+	calls := 0
+	v, err := retry.DoValue(context.Background(), retry.Policy{MaxAttempts: 3}, func(context.Context) (int, error) {
+		calls++
+		if calls < 2 {
+			return -1, errFlaky
+		}
+		return 42, nil
+	})
+	if err != nil || v != 42 || calls != 2 {
+		t.Errorf("DoValue = %d, %v after %d calls, want 42 from the second", v, err, calls)
+	}
+}
+
+func TestTransient(t *testing.T) {
+	for _, err := range []error{errs.ErrNotFound, errs.ErrConflict, errs.ErrInvalidInput, errs.ErrUnauthenticated, errs.ErrForbidden, context.Canceled, context.DeadlineExceeded} {
+		if retry.Transient(errs.Wrap("op", err)) {
+			t.Errorf("Transient(%v) = true, want false", err)
+		}
+	}
+	if !retry.Transient(errFlaky) || !retry.Transient(errs.ErrUnavailable) {
+		t.Error("Transient of an unknown or unavailable error = false, want true")
+	}
+}
+
+// TestBackoffWaits runs on the fake clock: the waits between calls are the
+// backoff's, and the calls only go on as the clock does.
+func TestBackoffWaits(t *testing.T) {
+	fake := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	start := fake.Now()
+	var at []time.Duration
+	done := make(chan struct{})
+	go drive(fake, 10*time.Millisecond, done)
+	err := retry.Do(context.Background(), retry.Policy{MaxAttempts: 4, Backoff: retry.Exponential(10*time.Millisecond, 0), Clock: fake}, func(context.Context) error {
+		at = append(at, fake.Now().Sub(start))
+		return errFlaky
+	})
+	close(done)
+	if !errors.Is(err, errFlaky) {
+		t.Fatalf("Do: err = %v, want errFlaky", err)
+	}
+	want := []time.Duration{0, 10 * time.Millisecond, 30 * time.Millisecond, 70 * time.Millisecond}
+	if len(at) != len(want) {
+		t.Fatalf("calls at %v, want %v", at, want)
+	}
+	for i := range want {
+		if at[i] != want[i] {
+			t.Errorf("calls at %v, want %v", at, want)
+			break
+		}
+	}
+}
+
+// TestMaxElapsed stops before a wait that would end past MaxElapsed,
+// with attempts left.
+func TestMaxElapsed(t *testing.T) {
+	fake := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	done := make(chan struct{})
+	go drive(fake, time.Second, done)
+	f := &flaky{n: 100}
+	err := retry.Do(context.Background(), retry.Policy{MaxElapsed: 2500 * time.Millisecond, Backoff: retry.Constant(time.Second), Clock: fake}, f.call)
+	close(done)
+	if !errors.Is(err, errFlaky) || f.calls != 3 {
+		t.Errorf("Do = %v after %d calls, want errFlaky after 3, at 0s, 1s and 2s", err, f.calls)
+	}
+}
+
+// TestCancelWhileWaiting cancels ctx during a wait the fake clock never
+// ends: Do returns at once with the last error and the context's.
+func TestCancelWhileWaiting(t *testing.T) {
+	fake := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	f := &flaky{n: 100}
+	go func() {
+		result <- retry.Do(ctx, retry.Policy{MaxAttempts: 10, Backoff: retry.Constant(time.Hour), Clock: fake}, f.call)
+	}()
+	for fake.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	select {
+	case err := <-result:
+		if !errors.Is(err, errFlaky) || !errors.Is(err, context.Canceled) {
+			t.Errorf("Do: err = %v, want errFlaky joined with context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Do kept waiting after ctx was canceled")
+	}
+	if f.calls != 1 {
+		t.Errorf("%d calls, want 1", f.calls)
+	}
+	if n := fake.Waiters(); n != 0 {
+		t.Errorf("%d timers left, want the wait's stopped", n)
+	}
+}
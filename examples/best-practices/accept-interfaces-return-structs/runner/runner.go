@@ -0,0 +1,189 @@
+// Package runner runs a main's long-lived components, an HTTP server, an
+// outbox relay, a scheduler, as one unit. The group stops as soon as any
+// component returns, the process gets a shutdown signal, or the parent
+// context ends: every other component then sees its context canceled and
+// Run waits for them all before returning.
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrShutdownTimeout is returned when components are still running once the
+// shutdown timeout has passed.
+var ErrShutdownTimeout = errors.New("runner: shutdown timed out")
+
+// Component runs until its context is canceled. *httptransport.Server,
+// *outbox.Relay, and *scheduler.Scheduler all satisfy it.
+type Component interface {
+	Run(ctx context.Context) error
+}
+
+// Func adapts a function to a Component.
+type Func func(ctx context.Context) error
+
+func (f Func) Run(ctx context.Context) error { return f(ctx) }
+
+// Option configures a Group.
+type Option func(*Group)
+
+// WithLogger sets the logger for start and shutdown events, the default
+// discards them.
+func WithLogger(logger *slog.Logger) Option {
+	return func(g *Group) {
+		g.logger = logger
+	}
+}
+
+// WithSignals sets the signals that stop the group, the default is SIGINT
+// and SIGTERM. No signals leaves shutdown to the components and the context.
+func WithSignals(signals ...os.Signal) Option {
+	return func(g *Group) {
+		g.signals = signals
+	}
+}
+
+// WithShutdownTimeout bounds how long Run waits for the remaining
+// components once the group is stopping, the default is 30 seconds.
+// Components should enforce their own, shorter, drain timeouts.
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(g *Group) {
+		g.shutdownTimeout = d
+	}
+}
+
+// Group is a set of components started and stopped together. Add them all
+// before calling Run.
+type Group struct {
+	logger          *slog.Logger
+	signals         []os.Signal
+	shutdownTimeout time.Duration
+	components      []component
+}
+
+type component struct {
+	name string
+	c    Component
+}
+
+func New(opts ...Option) *Group {
+	g := &Group{
+		logger:          slog.New(slog.NewTextHandler(io.Discard, nil)),
+		signals:         []os.Signal{syscall.SIGINT, syscall.SIGTERM},
+		shutdownTimeout: 30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Add registers c under name, used in logs and errors.
+func (g *Group) Add(name string, c Component) {
+	g.components = append(g.components, component{name: name, c: c})
+}
+
+type result struct {
+	index int
+	err   error
+}
+
+// Run starts every component and blocks until all of them have returned.
+// It returns nil for a clean stop, a signal, the parent context ending, or
+// a component returning nil, and otherwise the first component's error
+// joined with any other component that failed on the way down. A component
+// returning the context error after the group started stopping is a clean
+// exit, not a failure.
+func (g *Group) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if len(g.signals) > 0 {
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, g.signals...)
+		defer stop()
+		go func() {
+			// a second signal now kills the process instead of waiting
+			<-ctx.Done()
+			stop()
+		}()
+	}
+
+	results := make(chan result, len(g.components))
+	for i, c := range g.components {
+		go func() {
+			results <- result{index: i, err: run(ctx, c)}
+		}()
+		g.logger.InfoContext(ctx, "component started", slog.String("component", c.name))
+	}
+
+	pending := make(map[int]bool, len(g.components))
+	for i := range g.components {
+		pending[i] = true
+	}
+	var errs []error
+	record := func(r result) {
+		delete(pending, r.index)
+		name := g.components[r.index].name
+		// judged before cancel below, so a component that fails with its
+		// own context error while the group is still up counts as failed
+		if r.err != nil && !(ctx.Err() != nil && isCanceled(r.err)) {
+			g.logger.ErrorContext(ctx, "component failed", slog.String("component", name), slog.String("error", r.err.Error()))
+			errs = append(errs, fmt.Errorf("runner: %s: %w", name, r.err))
+			return
+		}
+		g.logger.InfoContext(ctx, "component stopped", slog.String("component", name))
+	}
+
+	if len(g.components) > 0 {
+		select {
+		case r := <-results:
+			record(r)
+		case <-ctx.Done():
+			g.logger.InfoContext(ctx, "shutdown requested", slog.String("reason", ctx.Err().Error()))
+		}
+	}
+	cancel()
+
+	timeout := time.NewTimer(g.shutdownTimeout)
+	defer timeout.Stop()
+	for len(pending) > 0 {
+		select {
+		case r := <-results:
+			record(r)
+		case <-timeout.C:
+			var names []string
+			for i, c := range g.components {
+				if pending[i] {
+					names = append(names, c.name)
+				}
+			}
+			errs = append(errs, fmt.Errorf("%w waiting for %s", ErrShutdownTimeout, strings.Join(names, ", ")))
+			return errors.Join(errs...)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// run calls the component, recovering a panic into its error so one broken
+// component stops the group instead of the process.
+func run(ctx context.Context, c component) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return c.c.Run(ctx)
+}
+
+func isCanceled(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
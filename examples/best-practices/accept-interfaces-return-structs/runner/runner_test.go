@@ -0,0 +1,157 @@
+package runner_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/runner"
+)
+
+// TestMain fails the package if a component's goroutine outlives Run.
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+var errBoom = errors.New("boom")
+
+// untilCanceled runs until its context ends, closing stopped on the way
+// out.
+func untilCanceled(stopped chan<- struct{}) runner.Func {
+	return func(ctx context.Context) error {
+		<-ctx.Done()
+		close(stopped)
+		return ctx.Err()
+	}
+}
+
+func newGroup(opts ...runner.Option) *runner.Group {
+	return runner.New(append([]runner.Option{runner.WithSignals()}, opts...)...)
+}
+
+func TestFirstErrorCancelsSiblings(t *testing.T) {
+	g := newGroup()
+	b, c := make(chan struct{}), make(chan struct{})
+	g.Add("a", runner.Func(func(context.Context) error { return errBoom }))
+	g.Add("b", untilCanceled(b))
+	g.Add("c", untilCanceled(c))
+	err := g.Run(context.Background())
+	if !errors.Is(err, errBoom) || !strings.Contains(err.Error(), "runner: a: boom") {
+		t.Errorf("Run: err = %v, want a's boom", err)
+	}
+	if errors.Is(err, context.Canceled) {
+		t.Errorf("Run: err = %v, want the siblings' clean exits left out", err)
+	}
+	for name, stopped := range map[string]chan struct{}{"b": b, "c": c} {
+		select {
+		case <-stopped:
+		default:
+			t.Errorf("%s still running after Run returned", name)
+		}
+	}
+}
+
+func TestFailureOnTheWayDown(t *testing.T) {
+	g := newGroup()
+	errDrain := errors.New("drain failed")
+	g.Add("a", runner.Func(func(context.Context) error { return errBoom }))
+	g.Add("b", runner.Func(func(ctx context.Context) error {
+		<-ctx.Done()
+		return errDrain
+	}))
+	if err := g.Run(context.Background()); !errors.Is(err, errBoom) || !errors.Is(err, errDrain) {
+		t.Errorf("Run: err = %v, want boom joined with the drain failure", err)
+	}
+}
+
+func TestCleanStops(t *testing.T) {
+	t.Run("parent canceled", func(t *testing.T) {
+		g := newGroup()
+		stopped := make(chan struct{})
+		g.Add("a", untilCanceled(stopped))
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		if err := g.Run(ctx); err != nil {
+			t.Errorf("Run: err = %v, want nil", err)
+		}
+	})
+	t.Run("component done", func(t *testing.T) {
+		g := newGroup()
+		stopped := make(chan struct{})
+		g.Add("once", runner.Func(func(context.Context) error { return nil }))
+		g.Add("server", untilCanceled(stopped))
+		if err := g.Run(context.Background()); err != nil {
+			t.Errorf("Run: err = %v, want nil", err)
+		}
+		<-stopped
+	})
+	t.Run("signal", func(t *testing.T) {
+		g := runner.New(runner.WithSignals(syscall.SIGUSR1))
+		stopped := make(chan struct{})
+		started := make(chan struct{})
+		g.Add("a", runner.Func(func(ctx context.Context) error {
+			close(started)
+			return untilCanceled(stopped)(ctx)
+		}))
+		go func() {
+			<-started
+			syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+		}()
+		if err := g.Run(context.Background()); err != nil {
+			t.Errorf("Run: err = %v, want nil", err)
+		}
+	})
+	t.Run("no components", func(t *testing.T) {
+		if err := newGroup().Run(context.Background()); err != nil {
+			t.Errorf("Run: err = %v, want nil", err)
+		}
+	})
+}
+
+// TestOwnContextError counts a component returning a context error while
+// the group is still up as a failure, not a clean exit.
+func TestOwnContextError(t *testing.T) {
+	g := newGroup()
+	g.Add("a", runner.Func(func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+		defer cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	if err := g.Run(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run: err = %v, want a's deadline", err)
+	}
+}
+
+func TestPanic(t *testing.T) {
+	g := newGroup()
+	stopped := make(chan struct{})
+	g.Add("a", runner.Func(func(context.Context) error { panic("broken") }))
+	g.Add("b", untilCanceled(stopped))
+	if err := g.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "runner: a: panic: broken") {
+		t.Errorf("Run: err = %v, want a's panic", err)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	g := newGroup(runner.WithShutdownTimeout(20 * time.Millisecond))
+	release, done := make(chan struct{}), make(chan struct{})
+	g.Add("a", runner.Func(func(context.Context) error { return errBoom }))
+	g.Add("stuck", runner.Func(func(context.Context) error {
+		defer close(done)
+		<-release
+		return nil
+	}))
+	err := g.Run(context.Background())
+	if !errors.Is(err, runner.ErrShutdownTimeout) || !errors.Is(err, errBoom) || !strings.Contains(err.Error(), "waiting for stuck") {
+		t.Errorf("Run: err = %v, want boom and a timeout waiting for stuck", err)
+	}
+	// let it go, so TestMain does not count it as leaked
+	close(release)
+	<-done
+}
@@ -0,0 +1,135 @@
+// Package safe turns panics into errors at the edges where one call must
+// not take the rest of the process with it: a goroutine, a worker's job, a
+// request. Every such edge in the service recovers the same way, into a
+// *PanicError carrying the stack of the panic, and reports it to the same
+// hooks, so a crash reporter sees a panic wherever it was recovered.
+//
+// Recovering is for code that does not know its callee, a pool running
+// submitted jobs, a server running handlers. Code that panics on purpose
+// to unwind, like net/http's ErrAbortHandler, has to be let through by the
+// edge that knows about it, see middleware.Recover.
+package safe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"slices"
+	"sync"
+)
+
+// PanicError is a recovered panic.
+type PanicError struct {
+	// Value is what was passed to panic, a *runtime.PanicNilError for
+	// panic(nil).
+	Value any
+	// Stack is the panicking goroutine's stack from the frame that
+	// panicked, as debug.Stack formats it.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns Value when it is an error, so errors.Is and errors.As see
+// through a panic(err), a runtime.Error included.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Do calls fn, returning its error or the *PanicError it panicked with.
+// A runtime.Goexit in fn, a t.FailNow, is not a panic and still ends the
+// goroutine.
+func Do(fn func() error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = Recovered(v)
+		}
+	}()
+	return fn()
+}
+
+// Go calls fn on a new goroutine. There is no one to return a panic to,
+// so past the hooks it is logged to slog.Default with its stack.
+func Go(fn func()) {
+	go func() {
+		err := Do(func() error {
+			fn()
+			return nil
+		})
+		if pe, ok := err.(*PanicError); ok {
+			slog.Default().ErrorContext(context.Background(), "panic in goroutine",
+				slog.String("panic", fmt.Sprint(pe.Value)),
+				slog.String("stack", string(pe.Stack)),
+			)
+		}
+	}()
+}
+
+// Recovered is for a deferred function that must see the panic value
+// before deciding what to do with it, where Do does not fit. It turns v,
+// what recover returned, into a *PanicError and reports it, and must be
+// called from that deferred function, while the panicking frames are still
+// on the stack to capture. v must not be nil.
+func Recovered(v any) *PanicError {
+	pe := &PanicError{Value: v, Stack: trim(debug.Stack())}
+	report(pe)
+	return pe
+}
+
+// trim cuts the frames recovering added, debug.Stack down to the runtime's
+// panic, off the top of stack, leaving the goroutine line with the frame
+// that panicked under it, as an unrecovered panic prints it. Each frame is
+// two lines, the function and its file.
+func trim(stack []byte) []byte {
+	lines := bytes.SplitAfter(stack, []byte("\n"))
+	for i := len(lines) - 2; i > 0; i-- {
+		if bytes.HasPrefix(lines[i], []byte("panic(")) && bytes.Contains(lines[i+1], []byte("runtime/panic.go")) {
+			return bytes.Join(append(lines[:1:1], lines[i+2:]...), nil)
+		}
+	}
+	return stack
+}
+
+type hook struct {
+	id int
+	fn func(*PanicError)
+}
+
+var hooks struct {
+	mu   sync.RWMutex
+	next int
+	list []hook
+}
+
+// OnPanic adds fn to the hooks every recovered panic is reported to, by
+// Do, Go, Recovered, and the pool and middleware built on them, and
+// returns a func that removes it. Hooks run in the order they were added,
+// on the recovering goroutine before the error is returned, so they see
+// the panic even where the caller drops the error. A hook must not panic,
+// there is nothing left to recover it.
+func OnPanic(fn func(*PanicError)) (remove func()) {
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	id := hooks.next
+	hooks.next++
+	hooks.list = append(hooks.list, hook{id, fn})
+	return func() {
+		hooks.mu.Lock()
+		defer hooks.mu.Unlock()
+		hooks.list = slices.DeleteFunc(slices.Clone(hooks.list), func(h hook) bool { return h.id == id })
+	}
+}
+
+func report(pe *PanicError) {
+	hooks.mu.RLock()
+	list := hooks.list
+	hooks.mu.RUnlock()
+	for _, h := range list {
+		h.fn(pe)
+	}
+}
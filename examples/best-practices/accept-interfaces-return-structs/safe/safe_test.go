@@ -0,0 +1,161 @@
+package safe_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/safe"
+)
+
+var errBoom = errors.New("boom")
+
+// panicLine is the line explode panics on, set as it runs.
+var panicLine int
+
+func explode() {
+	// the panic is two lines down
+	_, _, line, _ := runtime.Caller(0)
+	panicLine = line + 2
+	panic(errBoom)
+}
+
+// checkTopFrame checks that the first frame of stack, under the goroutine
+// line, is explode panicking at panicLine, with nothing of the recovery
+// above it.
+func checkTopFrame(t *testing.T, stack []byte) {
+	t.Helper()
+	lines := strings.Split(string(stack), "\n")
+	if len(lines) < 3 || !strings.HasPrefix(lines[0], "goroutine ") {
+		t.Fatalf("stack does not start with its goroutine:\n%s", stack)
+	}
+	if fn, _, _ := strings.Cut(lines[1], "("); !strings.HasSuffix(fn, "safe_test.explode") {
+		t.Fatalf("top frame %q, want explode\n%s", lines[1], stack)
+	}
+	// the file line is "\tpath:line", then " +0x.." unless it was inlined
+	at, _, _ := strings.Cut(strings.TrimSpace(lines[2]), " ")
+	if want := fmt.Sprintf("safe_test.go:%d", panicLine); !strings.HasSuffix(at, want) {
+		t.Errorf("top frame at %q, want %s", at, want)
+	}
+	for _, recovering := range []string{"runtime/debug.Stack", "safe.Recovered", "safe.Do"} {
+		if i := strings.Index(string(stack), recovering); i >= 0 && i < strings.Index(string(stack), "explode") {
+			t.Errorf("%s above the panic\n%s", recovering, stack)
+		}
+	}
+}
+
+// lockedBuffer is a log the goroutine Go starts can write while the test
+// reads.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// TestDoError checks Do returns fn's own error untouched.
+func TestDoError(t *testing.T) {
+	if err := safe.Do(func() error { return nil }); err != nil {
+		t.Errorf("Do(nil) = %v", err)
+	}
+	if err := safe.Do(func() error { return errBoom }); err != errBoom {
+		t.Errorf("Do = %v, want errBoom itself", err)
+	}
+}
+
+// TestDoPanic checks a panic comes back as an error with the stack it
+// happened on.
+func TestDoPanic(t *testing.T) {
+	err := safe.Do(func() error {
+		explode()
+		return nil
+	})
+	var pe *safe.PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("Do = %v, want a *safe.PanicError", err)
+	}
+	if err.Error() != "panic: boom" || !errors.Is(err, errBoom) {
+		t.Errorf("Do = %q, want panic: boom wrapping errBoom", err)
+	}
+	checkTopFrame(t, pe.Stack)
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	var m map[string]int
+	err := safe.Do(func() error {
+		m["x"] = 1
+		return nil
+	})
+	var rerr runtime.Error
+	if !errors.As(err, &rerr) {
+		t.Errorf("nil map write = %v, want a runtime.Error", err)
+	}
+	err = safe.Do(func() error {
+		panic(nil)
+	})
+	var nilErr *runtime.PanicNilError
+	if !errors.As(err, &nilErr) {
+		t.Errorf("panic(nil) = %v, want a *runtime.PanicNilError", err)
+	}
+}
+
+// TestOnPanic checks hooks see every panic in order, until removed.
+func TestOnPanic(t *testing.T) {
+	var got []string
+	removeFirst := safe.OnPanic(func(pe *safe.PanicError) { got = append(got, "first "+fmt.Sprint(pe.Value)) })
+	removeSecond := safe.OnPanic(func(pe *safe.PanicError) { got = append(got, "second "+fmt.Sprint(pe.Value)) })
+	t.Cleanup(removeSecond)
+	safe.Do(func() error { panic("a") })
+	removeFirst()
+	safe.Do(func() error { panic("b") })
+	if want := []string{"first a", "second a", "second b"}; !slices.Equal(got, want) {
+		t.Errorf("hooks saw %q, want %q", got, want)
+	}
+}
+
+// TestGo checks Go reports and logs a panic it has no one to return to.
+func TestGo(t *testing.T) {
+	logs := &lockedBuffer{}
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+	reported := make(chan *safe.PanicError, 1)
+	t.Cleanup(safe.OnPanic(func(pe *safe.PanicError) { reported <- pe }))
+	var done sync.WaitGroup
+	done.Add(1)
+	safe.Go(func() {
+		defer done.Done()
+		explode()
+	})
+	select {
+	case pe := <-reported:
+		checkTopFrame(t, pe.Stack)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no panic reported")
+	}
+	done.Wait()
+	// the log line is written after the hooks, once Go's Do returns
+	for deadline := time.Now().Add(5 * time.Second); !strings.Contains(logs.String(), "safe_test.explode"); {
+		if time.Now().After(deadline) {
+			t.Fatalf("log %q, want the panic with its stack", logs.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,300 @@
+// Package saga onboards a user across services that share no transaction:
+// create the user, provision their profile, send the welcome email. If a
+// step fails for good, the steps already done are undone in reverse order,
+// the compensations, so a failed signup leaves no half-made account.
+//
+// The workflow is a state machine persisted in a Store. Every step's
+// outcome is written before the next step runs, which is what makes it
+// long-running: a step that keeps failing waits out a backoff without
+// holding a goroutine, and a process that dies mid-workflow leaves each
+// instance at its step for Run to pick up again after a restart.
+//
+// A step can run more than once, when the process dies after the step but
+// before its outcome is stored, or when two coordinators race for one
+// instance. Every step and compensation is therefore idempotent: the
+// ports are expected to treat a repeat as done, and CreateUser, which the
+// service does not, is made so here.
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/retry"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// Users is the part of service.UserService onboarding uses.
+type Users interface {
+	CreateUser(ctx context.Context, user *service.User) error
+	RetrieveUser(ctx context.Context, id string, opts ...service.ReadOption) (*service.User, error)
+	PurgeUser(ctx context.Context, id string) error
+}
+
+// Profiles provisions the profile service's record of a user. Provision of
+// an existing profile and Remove of a missing one succeed.
+type Profiles interface {
+	Provision(ctx context.Context, userID string) error
+	Remove(ctx context.Context, userID string) error
+}
+
+// Mailer sends the welcome email, at most once per key.
+type Mailer interface {
+	SendWelcome(ctx context.Context, key, to string) error
+}
+
+type Option func(*Onboarding)
+
+// WithClock sets the time source for backoff and Run's polling, the
+// default is clock.Real.
+func WithClock(c clock.Clock) Option {
+	return func(o *Onboarding) {
+		o.clock = c
+	}
+}
+
+// WithMaxAttempts sets how many times a step runs before its failure is
+// final, the default is 5. Compensations are retried for as long as they
+// fail, giving up on one would leave the half-made account behind.
+func WithMaxAttempts(n int) Option {
+	return func(o *Onboarding) {
+		o.maxAttempts = max(n, 1)
+	}
+}
+
+// WithBackoff sets the wait before a failed step runs again, the default
+// is exponential from a second, capped at five minutes.
+func WithBackoff(b retry.Backoff) Option {
+	return func(o *Onboarding) {
+		o.backoff = b
+	}
+}
+
+// WithRetryable decides which step failures are worth another attempt, the
+// default is retry.Transient. Anything else fails the step at once.
+func WithRetryable(fn func(error) bool) Option {
+	return func(o *Onboarding) {
+		o.retryable = fn
+	}
+}
+
+// WithInterval sets how often Run looks for due instances, the default is
+// a second.
+func WithInterval(d time.Duration) Option {
+	return func(o *Onboarding) {
+		o.interval = d
+	}
+}
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *Onboarding) {
+		o.logger = logger
+	}
+}
+
+// Onboarding is the coordinator, the process manager of the workflow. It
+// keeps no state of its own, any number of them can share a Store.
+type Onboarding struct {
+	store       Store
+	users       Users
+	profiles    Profiles
+	mailer      Mailer
+	clock       clock.Clock
+	maxAttempts int
+	backoff     retry.Backoff
+	retryable   func(error) bool
+	interval    time.Duration
+	logger      *slog.Logger
+}
+
+func NewOnboarding(store Store, users Users, profiles Profiles, mailer Mailer, opts ...Option) *Onboarding {
+	o := &Onboarding{
+		store:       store,
+		users:       users,
+		profiles:    profiles,
+		mailer:      mailer,
+		clock:       clock.Real,
+		maxAttempts: 5,
+		backoff:     retry.Exponential(time.Second, 5*time.Minute),
+		retryable:   retry.Transient,
+		interval:    time.Second,
+		logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Start records a new onboarding for user and runs it as far as it goes
+// without waiting: to the end, or to a step waiting out a backoff, which
+// Run carries on. An invalid user is rejected before anything is stored,
+// a user already being onboarded is errs.ErrConflict.
+func (o *Onboarding) Start(ctx context.Context, user service.User) (*Instance, error) {
+	if err := user.Validate(); err != nil {
+		return nil, errs.Wrap("saga.Onboarding.Start", err)
+	}
+	now := o.clock.Now()
+	inst := &Instance{
+		ID:        user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		State:     StateCreateUser,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := o.store.Create(ctx, inst); err != nil {
+		return nil, errs.Wrap("saga.Onboarding.Start", err)
+	}
+	inst, err := o.Advance(ctx, inst.ID)
+	return inst, errs.Wrap("saga.Onboarding.Start", err)
+}
+
+// Advance runs instance id's steps, storing each outcome, until it is Done
+// or waiting out a backoff, and returns it as stored. If another
+// coordinator stores an outcome first, Advance leaves the instance to it.
+//
+// A step cut short by ctx is not counted as a failure, the instance stays
+// where it was and Advance returns the context error.
+func (o *Onboarding) Advance(ctx context.Context, id string) (*Instance, error) {
+	inst, err := o.store.Get(ctx, id)
+	if err != nil {
+		return nil, errs.Wrap("saga.Onboarding.Advance", err)
+	}
+	for !inst.State.Done() && !inst.NextAttempt.After(o.clock.Now()) {
+		err := o.run(ctx, inst)
+		if err != nil && ctx.Err() != nil {
+			return inst, errs.Wrap("saga.Onboarding.Advance", ctx.Err())
+		}
+		o.transition(ctx, inst, err)
+		err = o.store.Update(ctx, inst)
+		if errors.Is(err, errs.ErrVersionConflict) {
+			// someone else moved it on, return where they left it
+			inst, err = o.store.Get(ctx, id)
+			return inst, errs.Wrap("saga.Onboarding.Advance", err)
+		}
+		if err != nil {
+			return inst, errs.Wrap("saga.Onboarding.Advance", err)
+		}
+	}
+	return inst, nil
+}
+
+// Run advances due instances until ctx is done, then returns ctx.Err().
+// It is how workflows survive a restart: whatever was running or waiting
+// when the last process stopped is found by Store.Due.
+func (o *Onboarding) Run(ctx context.Context) error {
+	for {
+		if err := o.advanceDue(ctx); err != nil && ctx.Err() == nil {
+			o.logger.ErrorContext(ctx, "saga: poll failed", slog.String("error", err.Error()))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-o.clock.After(o.interval):
+		}
+	}
+}
+
+func (o *Onboarding) advanceDue(ctx context.Context) error {
+	due, err := o.store.Due(ctx, o.clock.Now(), 100)
+	if err != nil {
+		return err
+	}
+	for _, inst := range due {
+		if _, err := o.Advance(ctx, inst.ID); err != nil && ctx.Err() == nil {
+			o.logger.ErrorContext(ctx, "saga: advance failed", slog.String("id", inst.ID), slog.String("error", err.Error()))
+		}
+	}
+	return nil
+}
+
+// run performs inst's current step.
+func (o *Onboarding) run(ctx context.Context, inst *Instance) error {
+	switch inst.State {
+	case StateCreateUser:
+		return o.createUser(ctx, inst)
+	case StateProvisionProfile:
+		return o.profiles.Provision(ctx, inst.ID)
+	case StateSendWelcome:
+		// the instance ID is the key, a repeat after a crash is not sent
+		return o.mailer.SendWelcome(ctx, inst.ID, inst.Email)
+	case StateRemoveProfile:
+		return o.profiles.Remove(ctx, inst.ID)
+	case StatePurgeUser:
+		err := o.users.PurgeUser(ctx, inst.ID)
+		if errors.Is(err, errs.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	return fmt.Errorf("saga: unknown state %q", inst.State)
+}
+
+// createUser creates the user, treating an existing user with the same
+// email as this step having run before. One with another email is someone
+// else's, the conflict stands and nothing gets undone.
+func (o *Onboarding) createUser(ctx context.Context, inst *Instance) error {
+	err := o.users.CreateUser(ctx, &service.User{ID: inst.ID, Email: inst.Email, Name: inst.Name})
+	if !errors.Is(err, errs.ErrConflict) {
+		return err
+	}
+	existing, getErr := o.users.RetrieveUser(ctx, inst.ID)
+	if getErr == nil && existing.Email == inst.Email {
+		return nil
+	}
+	return err
+}
+
+// next is the state after each step succeeds, undo the compensation that
+// starts once it fails for good.
+var (
+	next = map[State]State{
+		StateCreateUser:       StateProvisionProfile,
+		StateProvisionProfile: StateSendWelcome,
+		StateSendWelcome:      StateCompleted,
+		StateRemoveProfile:    StatePurgeUser,
+		StatePurgeUser:        StateFailed,
+	}
+	undo = map[State]State{
+		// nothing was done yet
+		StateCreateUser:       StateFailed,
+		StateProvisionProfile: StatePurgeUser,
+		StateSendWelcome:      StateRemoveProfile,
+	}
+)
+
+// transition moves inst on after its step returned err.
+func (o *Onboarding) transition(ctx context.Context, inst *Instance, err error) {
+	now := o.clock.Now()
+	inst.UpdatedAt = now
+	if err == nil {
+		inst.State = next[inst.State]
+		inst.Attempts = 0
+		inst.NextAttempt = time.Time{}
+		return
+	}
+	inst.Attempts++
+	inst.LastError = err.Error()
+	log := o.logger.With(slog.String("id", inst.ID), slog.String("state", string(inst.State)), slog.Int("attempt", inst.Attempts), slog.String("error", err.Error()))
+	if inst.State.Compensating() || (o.retryable(err) && inst.Attempts < o.maxAttempts) {
+		inst.NextAttempt = now.Add(o.backoff.Delay(inst.Attempts))
+		if inst.State.Compensating() {
+			log.ErrorContext(ctx, "saga: compensation failed, retrying")
+		} else {
+			log.WarnContext(ctx, "saga: step failed, retrying")
+		}
+		return
+	}
+	log.WarnContext(ctx, "saga: step failed, compensating")
+	inst.FailedStep = inst.State
+	inst.State = undo[inst.State]
+	inst.Attempts = 0
+	inst.NextAttempt = time.Time{}
+}
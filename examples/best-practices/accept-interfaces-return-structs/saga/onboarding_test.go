@@ -0,0 +1,282 @@
+package saga_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/retry"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/saga"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// profiles is the profile service, fail decides whether a call fails.
+type profiles struct {
+	mu    sync.Mutex
+	ids   map[string]bool
+	calls int
+	fail  func(call int) error
+}
+
+func (p *profiles) Provision(ctx context.Context, userID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if p.fail != nil {
+		if err := p.fail(p.calls); err != nil {
+			return err
+		}
+	}
+	p.ids[userID] = true
+	return nil
+}
+
+func (p *profiles) Remove(ctx context.Context, userID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.ids, userID)
+	return nil
+}
+
+func (p *profiles) has(userID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.ids[userID]
+}
+
+// mailer is the email provider, it sends once per key. block makes a send
+// hang until its context ends, the process dying mid-step.
+type mailer struct {
+	mu    sync.Mutex
+	sent  map[string]int
+	fail  error
+	block bool
+}
+
+func (m *mailer) SendWelcome(ctx context.Context, key, to string) error {
+	m.mu.Lock()
+	block, fail := m.block, m.fail
+	m.mu.Unlock()
+	if block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if fail != nil {
+		return fail
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent[key]++
+	return nil
+}
+
+func (m *mailer) count(key string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sent[key]
+}
+
+// services is an onboarding on a fake clock, with the stores and services
+// it drives, three attempts a step a minute apart.
+type services struct {
+	clk        *clock.Fake
+	store      *saga.MemoryStore
+	users      *service.UserService
+	profiles   *profiles
+	mail       *mailer
+	onboarding *saga.Onboarding
+}
+
+func newServices() *services {
+	s := &services{
+		clk:      clock.NewFake(time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)),
+		store:    saga.NewMemoryStore(),
+		users:    service.NewUserService(db.NewMemoryStore()),
+		profiles: &profiles{ids: make(map[string]bool)},
+		mail:     &mailer{sent: make(map[string]int)},
+	}
+	s.onboarding = saga.NewOnboarding(s.store, s.users, s.profiles, s.mail,
+		saga.WithClock(s.clk),
+		saga.WithMaxAttempts(3),
+		saga.WithBackoff(retry.Constant(time.Minute)),
+		saga.WithLogger(slog.New(slog.DiscardHandler)),
+	)
+	return s
+}
+
+func (s *services) start(t *testing.T, id, email string) *saga.Instance {
+	t.Helper()
+	inst, err := s.onboarding.Start(context.Background(), service.User{ID: id, Email: email})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	return inst
+}
+
+// gone fails the test unless user id and their profile do not exist.
+func (s *services) gone(t *testing.T, id string) {
+	t.Helper()
+	if _, err := s.users.RetrieveUser(context.Background(), id, service.IncludeDeleted()); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("RetrieveUser %s: err = %v, want ErrNotFound", id, err)
+	}
+	if s.profiles.has(id) {
+		t.Errorf("profile %s still provisioned", id)
+	}
+}
+
+func failedAt(t *testing.T, inst *saga.Instance, step saga.State) {
+	t.Helper()
+	if inst.State != saga.StateFailed || inst.FailedStep != step {
+		t.Fatalf("state %s after failing %s, want %s after failing %s", inst.State, inst.FailedStep, saga.StateFailed, step)
+	}
+}
+
+func TestEveryStep(t *testing.T) {
+	s := newServices()
+	if inst := s.start(t, "ada", "ada@example.com"); inst.State != saga.StateCompleted {
+		t.Fatalf("state %s, want %s", inst.State, saga.StateCompleted)
+	}
+	if _, err := s.users.RetrieveUser(context.Background(), "ada"); err != nil {
+		t.Errorf("RetrieveUser: %v", err)
+	}
+	if !s.profiles.has("ada") || s.mail.count("ada") != 1 {
+		t.Errorf("profile %t, %d welcome emails, want a profile and one email", s.profiles.has("ada"), s.mail.count("ada"))
+	}
+}
+
+// TestCompensates rejects the welcome email for good: the profile and the
+// user are undone.
+func TestCompensates(t *testing.T) {
+	s := newServices()
+	s.mail.fail = fmt.Errorf("mailbox rejected: %w", errs.ErrInvalidInput)
+	failedAt(t, s.start(t, "bob", "bob@example.com"), saga.StateSendWelcome)
+	s.gone(t, "bob")
+}
+
+// TestRetries fails the profile step every time: it is retried after each
+// backoff and not before, and the user undone once attempts run out.
+func TestRetries(t *testing.T) {
+	ctx := context.Background()
+	s := newServices()
+	s.profiles.fail = func(int) error { return errors.New("profile service unavailable") }
+	inst := s.start(t, "cy", "cy@example.com")
+	if inst.State != saga.StateProvisionProfile || inst.Attempts != 1 {
+		t.Fatalf("state %s after %d attempts, want the profile step waiting after 1", inst.State, inst.Attempts)
+	}
+	for _, tt := range []struct {
+		wait     time.Duration
+		attempts int
+	}{
+		{30 * time.Second, 1}, // not due yet, nothing runs
+		{30 * time.Second, 2},
+	} {
+		s.clk.Advance(tt.wait)
+		inst, err := s.onboarding.Advance(ctx, "cy")
+		if err != nil || inst.Attempts != tt.attempts {
+			t.Fatalf("Advance = %d attempts, %v, want %d", inst.Attempts, err, tt.attempts)
+		}
+	}
+	s.clk.Advance(time.Minute)
+	inst, err := s.onboarding.Advance(ctx, "cy")
+	if err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+	failedAt(t, inst, saga.StateProvisionProfile)
+	if inst.LastError != "profile service unavailable" {
+		t.Errorf("LastError = %q, want the profile service's", inst.LastError)
+	}
+	s.gone(t, "cy")
+}
+
+// TestTakenID onboards onto a user ID someone already has: it fails
+// without undoing their account.
+func TestTakenID(t *testing.T) {
+	ctx := context.Background()
+	s := newServices()
+	if err := s.users.CreateUser(ctx, &service.User{ID: "di", Email: "di@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	failedAt(t, s.start(t, "di", "eve@example.com"), saga.StateCreateUser)
+	existing, err := s.users.RetrieveUser(ctx, "di")
+	if err != nil {
+		t.Fatalf("the existing user: %v", err)
+	}
+	if existing.Email != "di@example.com" || s.profiles.has("di") {
+		t.Errorf("existing user now %s, profile %t", existing.Email, s.profiles.has("di"))
+	}
+}
+
+func TestInvalidSignup(t *testing.T) {
+	ctx := context.Background()
+	s := newServices()
+	if _, err := s.onboarding.Start(ctx, service.User{ID: "eve", Email: "not an email"}); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("Start: err = %v, want ErrInvalidInput", err)
+	}
+	if _, err := s.store.Get(ctx, "eve"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Get: err = %v, want ErrNotFound, nothing stored", err)
+	}
+}
+
+// TestResumesAfterCrash kills the coordinator while the welcome email is
+// being sent: a new one sharing only the store finishes the onboarding.
+func TestResumesAfterCrash(t *testing.T) {
+	ctx := context.Background()
+	s := newServices()
+	s.mail.block = true
+	crashed, crash := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.onboarding.Start(crashed, service.User{ID: "fay", Email: "fay@example.com"})
+		done <- err
+	}()
+	for {
+		inst, err := s.store.Get(ctx, "fay")
+		if err == nil && inst.State == saga.StateSendWelcome {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	crash()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("interrupted Start: err = %v, want context.Canceled", err)
+	}
+	inst, err := s.store.Get(ctx, "fay")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if inst.State != saga.StateSendWelcome || inst.Attempts != 0 {
+		t.Fatalf("stored at %s after %d attempts, want the email step untried", inst.State, inst.Attempts)
+	}
+
+	s.mail.mu.Lock()
+	s.mail.block = false
+	s.mail.mu.Unlock()
+	restarted := saga.NewOnboarding(s.store, s.users, s.profiles, s.mail, saga.WithInterval(5*time.Millisecond))
+	runCtx, stop := context.WithTimeout(ctx, 2*time.Second)
+	ran := make(chan struct{})
+	go func() {
+		defer close(ran)
+		restarted.Run(runCtx)
+	}()
+	defer func() { stop(); <-ran }()
+	for {
+		inst, err := s.store.Get(ctx, "fay")
+		if err == nil && inst.State == saga.StateCompleted {
+			break
+		}
+		if runCtx.Err() != nil {
+			t.Fatalf("still at %s", inst.State)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if s.mail.count("fay") != 1 || !s.profiles.has("fay") {
+		t.Errorf("%d welcome emails, profile %t, want one and a profile", s.mail.count("fay"), s.profiles.has("fay"))
+	}
+}
@@ -0,0 +1,149 @@
+package saga
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// State is where an instance is in its workflow, the step it is about to
+// run or, once Done, how it ended.
+type State string
+
+const (
+	StateCreateUser       State = "create_user"
+	StateProvisionProfile State = "provision_profile"
+	StateSendWelcome      State = "send_welcome"
+	StateCompleted        State = "completed"
+
+	// compensations, run in reverse order after a step failed for good
+	StateRemoveProfile State = "remove_profile"
+	StatePurgeUser     State = "purge_user"
+	// StateFailed is the end of a failed onboarding, every completed step
+	// undone.
+	StateFailed State = "failed"
+)
+
+// Done reports whether s is an end state.
+func (s State) Done() bool {
+	return s == StateCompleted || s == StateFailed
+}
+
+// Compensating reports whether s undoes a step.
+func (s State) Compensating() bool {
+	return s == StateRemoveProfile || s == StatePurgeUser
+}
+
+// Instance is one user's onboarding, everything needed to carry it on after
+// a restart.
+type Instance struct {
+	// ID is the user's ID, a user is onboarded once.
+	ID    string
+	Email string
+	Name  string
+	State State
+	// Attempts counts failures of the current step, NextAttempt is when it
+	// runs again.
+	Attempts    int
+	NextAttempt time.Time
+	// FailedStep is the step that failed for good, LastError why the last
+	// attempt of any step failed.
+	FailedStep State
+	LastError  string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	// Version increments on every update, like service.User's.
+	Version int64
+}
+
+// Store persists instances. Every state change is an Update, so whatever
+// reads the store after a crash finds each instance at the step it was on.
+type Store interface {
+	// Create fails with errs.ErrConflict if the ID is taken.
+	Create(ctx context.Context, inst *Instance) error
+	Get(ctx context.Context, id string) (*Instance, error)
+	// Update is a compare-and-swap on Version, failing with
+	// errs.ErrVersionConflict when the stored version differs, and on
+	// success setting inst.Version to the next value.
+	Update(ctx context.Context, inst *Instance) error
+	// Due returns up to limit instances not Done whose NextAttempt is at
+	// or before now, oldest first.
+	Due(ctx context.Context, now time.Time, limit int) ([]*Instance, error)
+}
+
+// MemoryStore is a concurrency safe Store on a map, instances are copied in
+// and out.
+type MemoryStore struct {
+	mu        sync.Mutex
+	instances map[string]Instance
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{instances: make(map[string]Instance)}
+}
+
+func (s *MemoryStore) Create(ctx context.Context, inst *Instance) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("saga.MemoryStore.Create", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.instances[inst.ID]; ok {
+		return errs.Wrap("saga.MemoryStore.Create", errs.ErrConflict)
+	}
+	inst.Version = 1
+	s.instances[inst.ID] = *inst
+	return nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id string) (*Instance, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Wrap("saga.MemoryStore.Get", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inst, ok := s.instances[id]
+	if !ok {
+		return nil, errs.Wrap("saga.MemoryStore.Get", errs.ErrNotFound)
+	}
+	return &inst, nil
+}
+
+func (s *MemoryStore) Update(ctx context.Context, inst *Instance) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Wrap("saga.MemoryStore.Update", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, ok := s.instances[inst.ID]
+	if !ok {
+		return errs.Wrap("saga.MemoryStore.Update", errs.ErrNotFound)
+	}
+	if cur.Version != inst.Version {
+		return errs.Wrap("saga.MemoryStore.Update", errs.ErrVersionConflict)
+	}
+	inst.Version++
+	s.instances[inst.ID] = *inst
+	return nil
+}
+
+func (s *MemoryStore) Due(ctx context.Context, now time.Time, limit int) ([]*Instance, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Wrap("saga.MemoryStore.Due", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []*Instance
+	for _, inst := range s.instances {
+		if !inst.State.Done() && !inst.NextAttempt.After(now) {
+			due = append(due, &inst)
+		}
+	}
+	slices.SortFunc(due, func(a, b *Instance) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return due[:min(len(due), max(limit, 0))], nil
+}
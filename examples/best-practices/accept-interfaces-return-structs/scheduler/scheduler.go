@@ -0,0 +1,198 @@
+// Package scheduler runs recurring background tasks on interval or cron
+// schedules. Each job runs on its own goroutine: a panic is recovered and
+// logged without touching the other jobs, and a run that is still going
+// when the next one is due causes that tick to be skipped instead of two
+// runs overlapping.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+)
+
+// ErrRunning is returned by a second concurrent call to Run.
+var ErrRunning = errors.New("scheduler: already running")
+
+// Task is one run of a job. Its context is canceled when the scheduler
+// shuts down.
+type Task func(ctx context.Context) error
+
+// Option configures a Scheduler.
+type Option func(*Scheduler)
+
+// WithClock sets the time source, the default is clock.Real.
+func WithClock(c clock.Clock) Option {
+	return func(s *Scheduler) {
+		s.clock = c
+	}
+}
+
+// WithLogger sets the logger for runs, skips, and failures, the default
+// discards them.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Scheduler) {
+		s.logger = logger
+	}
+}
+
+// Scheduler is safe for concurrent use. Jobs can be added before or while
+// it runs.
+type Scheduler struct {
+	clock  clock.Clock
+	logger *slog.Logger
+
+	mu   sync.Mutex
+	jobs []*job
+	// ctx is set while Run is active
+	ctx context.Context
+	wg  sync.WaitGroup
+}
+
+func New(opts ...Option) *Scheduler {
+	s := &Scheduler{
+		clock:  clock.Real,
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+type job struct {
+	name     string
+	schedule Schedule
+	task     Task
+	running  atomic.Bool
+	runs     atomic.Int64
+	skipped  atomic.Int64
+	failed   atomic.Int64
+}
+
+// Stats counts what a job has done so far.
+type Stats struct {
+	Name    string
+	Runs    int64
+	Skipped int64
+	Failed  int64
+}
+
+// Add registers task under name, a name used only in logs and Stats.
+func (s *Scheduler) Add(name string, schedule Schedule, task Task) {
+	j := &job{name: name, schedule: schedule, task: task}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, j)
+	if s.ctx != nil {
+		s.start(s.ctx, j)
+	}
+}
+
+// Stats reports every job in the order they were added.
+func (s *Scheduler) Stats() []Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make([]Stats, len(s.jobs))
+	for i, j := range s.jobs {
+		stats[i] = Stats{Name: j.name, Runs: j.runs.Load(), Skipped: j.skipped.Load(), Failed: j.failed.Load()}
+	}
+	return stats
+}
+
+// Run starts every job and blocks until ctx is done, then waits for the
+// runs in progress, which see ctx canceled, before returning ctx.Err().
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.ctx != nil {
+		s.mu.Unlock()
+		return ErrRunning
+	}
+	s.ctx = ctx
+	for _, j := range s.jobs {
+		s.start(ctx, j)
+	}
+	s.mu.Unlock()
+
+	<-ctx.Done()
+	s.mu.Lock()
+	s.ctx = nil
+	s.mu.Unlock()
+	s.wg.Wait()
+	return ctx.Err()
+}
+
+// start must be called with s.mu held.
+func (s *Scheduler) start(ctx context.Context, j *job) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.loop(ctx, j)
+	}()
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	now := s.clock.Now()
+	next := j.schedule.Next(now)
+	for !next.IsZero() {
+		timer := s.clock.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+		s.fire(ctx, j)
+
+		// schedule from the planned time so runs don't drift, unless
+		// ticks were missed (a suspended laptop), those are not made up
+		now = s.clock.Now()
+		next = j.schedule.Next(next)
+		if next.Before(now) {
+			next = j.schedule.Next(now)
+		}
+	}
+}
+
+func (s *Scheduler) fire(ctx context.Context, j *job) {
+	if !j.running.CompareAndSwap(false, true) {
+		j.skipped.Add(1)
+		s.logger.WarnContext(ctx, "job skipped, previous run still in progress", slog.String("job", j.name))
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer j.running.Store(false)
+		start := s.clock.Now()
+		err := run(ctx, j)
+		j.runs.Add(1)
+		if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+			// cut short by shutdown, not a failure of the job
+			s.logger.DebugContext(ctx, "job canceled", slog.String("job", j.name))
+			return
+		}
+		if err != nil {
+			j.failed.Add(1)
+			s.logger.ErrorContext(ctx, "job failed", slog.String("job", j.name), slog.String("error", err.Error()))
+			return
+		}
+		s.logger.DebugContext(ctx, "job finished", slog.String("job", j.name), slog.Duration("duration", s.clock.Now().Sub(start)))
+	}()
+}
+
+// run calls the task, recovering a panic into its error.
+func run(ctx context.Context, j *job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("scheduler: job %s panicked: %v", j.name, r)
+		}
+	}()
+	return j.task(ctx)
+}
@@ -0,0 +1,175 @@
+package scheduler_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/scheduler"
+)
+
+// TestMain fails the package if any test leaves a job's goroutine running
+// after Run has returned.
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+// until waits for cond, polling, since the scheduler's goroutines give no
+// signal of their own.
+func until(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// running starts s.Run and returns a function that stops it and returns
+// Run's error.
+func running(t *testing.T, s *scheduler.Scheduler) func() error {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+	return func() error {
+		cancel()
+		return <-done
+	}
+}
+
+// TestSkipsOverlap checks a tick that comes due while the last run is still
+// going is skipped, not run alongside it.
+func TestSkipsOverlap(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := scheduler.New(scheduler.WithClock(clk))
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	s.Add("slow", scheduler.Every(time.Minute), func(ctx context.Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+	stop := running(t, s)
+
+	until(t, "the first timer", func() bool { return clk.Waiters() == 1 })
+	clk.Advance(time.Minute)
+	<-started
+	until(t, "the second timer", func() bool { return clk.Waiters() == 1 })
+	clk.Advance(time.Minute)
+	until(t, "the skip", func() bool { return s.Stats()[0].Skipped == 1 })
+	close(release)
+	until(t, "the run to finish", func() bool { return s.Stats()[0].Runs == 1 })
+
+	if err := stop(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run: err = %v, want context.Canceled", err)
+	}
+	if got := s.Stats()[0]; got.Runs != 1 || got.Skipped != 1 || got.Failed != 0 {
+		t.Errorf("Stats = %+v, want one run and one skip", got)
+	}
+}
+
+// TestFailures checks an error and a panic each count as a failure of
+// their own job, and neither stops the scheduler.
+func TestFailures(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := scheduler.New(scheduler.WithClock(clk))
+	s.Add("errors", scheduler.Every(time.Minute), func(context.Context) error { return errors.New("boom") })
+	s.Add("panics", scheduler.Every(time.Minute), func(context.Context) error { panic("boom") })
+	s.Add("works", scheduler.Every(time.Minute), func(context.Context) error { return nil })
+	stop := running(t, s)
+	for round := int64(1); round <= 2; round++ {
+		until(t, "every timer", func() bool { return clk.Waiters() == 3 })
+		clk.Advance(time.Minute)
+		until(t, "every run", func() bool {
+			for _, st := range s.Stats() {
+				if st.Runs < round {
+					return false
+				}
+			}
+			return true
+		})
+	}
+	stop()
+	for _, st := range s.Stats() {
+		want := int64(2)
+		if st.Name == "works" {
+			want = 0
+		}
+		if st.Runs != 2 || st.Failed != want {
+			t.Errorf("Stats(%s) = %+v, want 2 runs and %d failures", st.Name, st, want)
+		}
+	}
+}
+
+// TestRunWaits checks Run returns only once the runs in progress, which see
+// its context canceled, have finished.
+func TestRunWaits(t *testing.T) {
+	s := scheduler.New()
+	var inFlight atomic.Int32
+	s.Add("tick", scheduler.Every(time.Millisecond), func(ctx context.Context) error {
+		inFlight.Add(1)
+		defer inFlight.Add(-1)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run: err = %v, want context.DeadlineExceeded", err)
+	}
+	if n := inFlight.Load(); n != 0 {
+		t.Errorf("Run returned with %d runs in progress", n)
+	}
+	if got := s.Stats()[0]; got.Runs == 0 || got.Failed != 0 {
+		t.Errorf("Stats = %+v, want runs that were canceled, not failed", got)
+	}
+}
+
+func TestRunTwice(t *testing.T) {
+	s := scheduler.New()
+	stop := running(t, s)
+	defer stop()
+	// Run has to have started, and can only be seen through its error
+	until(t, "Run to start", func() bool {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		return errors.Is(s.Run(ctx), scheduler.ErrRunning)
+	})
+}
+
+func TestCron(t *testing.T) {
+	// a Thursday
+	after := time.Date(2026, 1, 1, 10, 7, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 1, 1, 10, 15, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{"30 8-18/2 * * *", time.Date(2026, 1, 1, 10, 30, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2026, 1, 4, 12, 0, 0, 0, time.UTC)},
+		// either day field matches, the Monday comes before the 1st
+		{"0 0 1 * 1", time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	} {
+		if got := scheduler.MustCron(tt.expr).Next(after); !got.Equal(tt.want) {
+			t.Errorf("Cron(%q).Next = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+	for _, expr := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "MON * * * *"} {
+		if _, err := scheduler.Cron(expr); err == nil {
+			t.Errorf("Cron(%q) parsed", expr)
+		}
+	}
+	if got := scheduler.Every(0).Next(after); !got.IsZero() {
+		t.Errorf("Every(0).Next = %v, want never", got)
+	}
+}
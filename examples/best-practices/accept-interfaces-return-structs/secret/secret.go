@@ -0,0 +1,85 @@
+// Package secret keeps credentials out of logs, error messages and API
+// responses by making the safe thing the default: a String prints as
+// [REDACTED] through every fmt verb, slog, and encoding/json, and the real
+// value only comes out through an explicit Reveal call that is easy to grep
+// for in review.
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+const redacted = "[REDACTED]"
+
+// String holds a sensitive value such as a DSN, token, or API key. The zero
+// value is the empty secret, which prints as "" so a missing setting is
+// still visible in a startup log.
+type String struct {
+	value string
+}
+
+func New(value string) String {
+	return String{value: value}
+}
+
+// Reveal returns the plain value, only pass it straight to whatever needs it.
+func (s String) Reveal() string {
+	return s.value
+}
+
+func (s String) IsZero() bool {
+	return s.value == ""
+}
+
+func (s String) String() string {
+	if s.value == "" {
+		return ""
+	}
+	return redacted
+}
+
+// GoString covers %#v, which would otherwise print the struct field.
+func (s String) GoString() string {
+	return fmt.Sprintf("secret.String(%q)", s.String())
+}
+
+// Format handles every verb, %x or %d included, so no verb reaches the
+// field through reflection.
+func (s String) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if f.Flag('#') {
+			fmt.Fprint(f, s.GoString())
+			return
+		}
+		fmt.Fprint(f, s.String())
+	case 'q':
+		fmt.Fprintf(f, "%q", s.String())
+	default:
+		fmt.Fprint(f, s.String())
+	}
+}
+
+// LogValue makes slog print the redacted form, JSON and text handlers alike.
+func (s String) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+// MarshalJSON redacts, so a secret never round trips through JSON. Config
+// files carry secrets in, they are never written back out.
+func (s String) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// MarshalText redacts for encoders that prefer text, yaml among them.
+func (s String) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText lets config files and flags set the value.
+func (s *String) UnmarshalText(b []byte) error {
+	s.value = string(b)
+	return nil
+}
@@ -0,0 +1,99 @@
+package secret_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/secret"
+)
+
+const plain = "postgres://app:hunter2@db/users"
+
+// TestFmtVerbs prints a secret through every verb and flag combination
+// likely to show up in a log call, on its own and nested in the values
+// programs really print.
+func TestFmtVerbs(t *testing.T) {
+	s := secret.New(plain)
+	type settings struct {
+		DSN secret.String
+	}
+	values := map[string]any{
+		"value":   s,
+		"pointer": &s,
+		"struct":  settings{DSN: s},
+		"slice":   []secret.String{s},
+		"map":     map[string]secret.String{"dsn": s},
+		"any":     []any{s},
+	}
+	verbs := []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%X", "%d", "%T %v", "%20s", "%-20v", "%.3s"}
+	for name, v := range values {
+		for _, verb := range verbs {
+			got := fmt.Sprintf(verb, v)
+			if strings.Contains(got, "hunter2") || strings.Contains(got, fmt.Sprintf("%x", "hunter2")) {
+				t.Errorf("Sprintf(%q, %s) = %q, leaks the secret", verb, name, got)
+			}
+		}
+	}
+	for _, got := range []string{fmt.Sprint(s), fmt.Sprintln(s), s.String(), fmt.Errorf("connect %v: %w", s, errors.New("refused")).Error()} {
+		if strings.Contains(got, "hunter2") {
+			t.Errorf("%q leaks the secret", got)
+		}
+	}
+	if got := fmt.Sprintf("%#v", s); got != `secret.String("[REDACTED]")` {
+		t.Errorf("%%#v = %s", got)
+	}
+	if got := fmt.Sprint(secret.String{}); got != "" {
+		t.Errorf("the empty secret prints %q, want it visibly empty", got)
+	}
+}
+
+func TestLoggers(t *testing.T) {
+	s := secret.New(plain)
+	var buf bytes.Buffer
+	for _, h := range []slog.Handler{slog.NewJSONHandler(&buf, nil), slog.NewTextHandler(&buf, nil)} {
+		logger := slog.New(h)
+		logger.Info("connecting", "dsn", s, slog.Any("config", map[string]any{"dsn": s}))
+		logger.Info("connecting", slog.Group("db", slog.Any("dsn", s)))
+	}
+	log.New(&buf, "", 0).Printf("dsn=%v", s)
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Errorf("a logger wrote the secret:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "[REDACTED]") {
+		t.Errorf("the loggers did not write the redacted form:\n%s", buf.String())
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	s := secret.New(plain)
+	data, err := json.Marshal(struct {
+		DSN secret.String `json:"dsn"`
+	}{s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"dsn":"[REDACTED]"}` {
+		t.Errorf("json = %s, want it redacted", data)
+	}
+	text, _ := s.MarshalText()
+	if string(text) != "[REDACTED]" {
+		t.Errorf("MarshalText = %s, want it redacted", text)
+	}
+
+	// decoding is the one way in, and Reveal the one way out
+	var in struct {
+		DSN secret.String `json:"dsn"`
+	}
+	if err := json.Unmarshal([]byte(`{"dsn":"`+plain+`"}`), &in); err != nil {
+		t.Fatal(err)
+	}
+	if in.DSN.Reveal() != plain || in.DSN.IsZero() {
+		t.Errorf("Reveal after decoding = %q, want %q", in.DSN.Reveal(), plain)
+	}
+}
@@ -0,0 +1,105 @@
+// Package seq has combinators for range-over-func iterators, the iter.Seq
+// and iter.Seq2 counterparts of what package fn does for slices. They are
+// lazy: nothing runs until the result is ranged over, and a consumer that
+// breaks early stops every stage above it, so an iterator that pages
+// through a store stops fetching too.
+//
+// The "2" variants work on iter.Seq2, which is how a fallible sequence
+// such as UserService.ListAll is shaped. Filter2 and Map2 hand the error to
+// the callback as well, pass it through rather than dropping it.
+package seq
+
+import "iter"
+
+// Take yields at most the first n values of s.
+func Take[V any](s iter.Seq[V], n int) iter.Seq[V] {
+	return func(yield func(V) bool) {
+		if n <= 0 {
+			return
+		}
+		i := 0
+		for v := range s {
+			if !yield(v) {
+				return
+			}
+			if i++; i == n {
+				return
+			}
+		}
+	}
+}
+
+// Filter yields the values of s keep reports true for.
+func Filter[V any](s iter.Seq[V], keep func(V) bool) iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for v := range s {
+			if keep(v) && !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Map yields f applied to every value of s.
+func Map[V, R any](s iter.Seq[V], f func(V) R) iter.Seq[R] {
+	return func(yield func(R) bool) {
+		for v := range s {
+			if !yield(f(v)) {
+				return
+			}
+		}
+	}
+}
+
+// Take2 yields at most the first n pairs of s.
+func Take2[K, V any](s iter.Seq2[K, V], n int) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		if n <= 0 {
+			return
+		}
+		i := 0
+		for k, v := range s {
+			if !yield(k, v) {
+				return
+			}
+			if i++; i == n {
+				return
+			}
+		}
+	}
+}
+
+// Filter2 yields the pairs of s keep reports true for.
+func Filter2[K, V any](s iter.Seq2[K, V], keep func(K, V) bool) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for k, v := range s {
+			if keep(k, v) && !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// Map2 yields f applied to every pair of s.
+func Map2[K, V, R, S any](s iter.Seq2[K, V], f func(K, V) (R, S)) iter.Seq2[R, S] {
+	return func(yield func(R, S) bool) {
+		for k, v := range s {
+			if !yield(f(k, v)) {
+				return
+			}
+		}
+	}
+}
+
+// Collect2 drains a fallible sequence into a slice, stopping at the first
+// error. It is slices.Collect for iter.Seq2[V, error].
+func Collect2[V any](s iter.Seq2[V, error]) ([]V, error) {
+	var out []V
+	for v, err := range s {
+		if err != nil {
+			return out, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
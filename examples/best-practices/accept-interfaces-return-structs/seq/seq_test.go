@@ -0,0 +1,115 @@
+package seq_test
+
+import (
+	"errors"
+	"iter"
+	"slices"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/seq"
+)
+
+// counting yields 0, 1, 2, ... up to n, recording how many it produced
+// and whether it ran its cleanup, as a paging iterator would.
+func counting(n int, produced *int, cleaned *bool) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		defer func() { *cleaned = true }()
+		for i := range n {
+			*produced++
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+func TestCombinators(t *testing.T) {
+	even := func(n int) bool { return n%2 == 0 }
+	square := func(n int) int { return n * n }
+	for _, tt := range []struct {
+		name string
+		s    func(iter.Seq[int]) iter.Seq[int]
+		want []int
+	}{
+		{"take", func(s iter.Seq[int]) iter.Seq[int] { return seq.Take(s, 3) }, []int{0, 1, 2}},
+		{"take more than there is", func(s iter.Seq[int]) iter.Seq[int] { return seq.Take(s, 100) }, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{"take none", func(s iter.Seq[int]) iter.Seq[int] { return seq.Take(s, 0) }, nil},
+		{"filter", func(s iter.Seq[int]) iter.Seq[int] { return seq.Filter(s, even) }, []int{0, 2, 4, 6, 8}},
+		{"map", func(s iter.Seq[int]) iter.Seq[int] { return seq.Take(seq.Map(s, square), 4) }, []int{0, 1, 4, 9}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var produced int
+			var cleaned bool
+			if got := slices.Collect(tt.s(counting(10, &produced, &cleaned))); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			// Take(0) never starts the source, there is nothing to clean up
+			if produced > 0 && !cleaned {
+				t.Error("the source did not run its cleanup")
+			}
+		})
+	}
+}
+
+// TestEarlyBreak checks a break at the bottom of a pipeline stops the
+// source: no value past the one that was needed is produced, and the
+// source's deferred cleanup still runs.
+func TestEarlyBreak(t *testing.T) {
+	var produced int
+	var cleaned bool
+	pipeline := seq.Map(seq.Filter(counting(1000, &produced, &cleaned), func(n int) bool { return n%3 == 0 }), func(n int) int { return -n })
+	if produced != 0 {
+		t.Fatalf("building the pipeline produced %d values, want 0", produced)
+	}
+	for v := range pipeline {
+		if v == -9 {
+			break
+		}
+	}
+	if produced != 10 {
+		t.Errorf("breaking at the fourth match produced %d values, want 10", produced)
+	}
+	if !cleaned {
+		t.Error("breaking did not run the source's cleanup")
+	}
+
+	// Take stops at n without asking for the n+1th
+	produced, cleaned = 0, false
+	for range seq.Take(counting(1000, &produced, &cleaned), 5) {
+	}
+	if produced != 5 || !cleaned {
+		t.Errorf("Take(5) produced %d values, cleaned %t, want 5, true", produced, cleaned)
+	}
+}
+
+// pairs yields the numbers below n with a nil error, then failWith if set.
+func pairs(n int, failWith error) iter.Seq2[int, error] {
+	return func(yield func(int, error) bool) {
+		for i := range n {
+			if !yield(i, nil) {
+				return
+			}
+		}
+		if failWith != nil {
+			yield(0, failWith)
+		}
+	}
+}
+
+func TestFallible(t *testing.T) {
+	boom := errors.New("boom")
+	odd := func(n int, err error) bool { return err != nil || n%2 == 1 }
+	got, err := seq.Collect2(seq.Filter2(pairs(6, boom), odd))
+	if !slices.Equal(got, []int{1, 3, 5}) || !errors.Is(err, boom) {
+		t.Errorf("Collect2(Filter2) = %v, %v, want 1 3 5 and then boom", got, err)
+	}
+	// the error is past the fifth pair, Take2 stops before it
+	got, err = seq.Collect2(seq.Take2(pairs(6, boom), 5))
+	if len(got) != 5 || err != nil {
+		t.Errorf("Collect2(Take2(5)) = %v, %v, want five values and no error", got, err)
+	}
+	doubled, err := seq.Collect2(seq.Map2(pairs(3, nil), func(n int, err error) (int, error) { return n * 2, err }))
+	if !slices.Equal(doubled, []int{0, 2, 4}) || err != nil {
+		t.Errorf("Collect2(Map2) = %v, %v, want 0 2 4", doubled, err)
+	}
+}
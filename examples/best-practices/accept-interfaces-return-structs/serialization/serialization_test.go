@@ -0,0 +1,32 @@
+package serialization_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// roundTrip marshals v, unmarshals the result into a new T, and wants it
+// equal to v and to marshal to the same bytes again.
+func roundTrip[T any](t *testing.T, v T) {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var got T
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("Unmarshal(%s): %v", b, err)
+	}
+	if !reflect.DeepEqual(got, v) {
+		t.Fatalf("round trip of %s gave %+v, want %+v", b, got, v)
+	}
+	again, err := json.Marshal(got)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if !bytes.Equal(again, b) {
+		t.Errorf("marshaled again to %s, was %s", again, b)
+	}
+}
@@ -0,0 +1,51 @@
+package serialization
+
+import (
+	"fmt"
+)
+
+//go:generate go tool stringer -type Status -linecomment
+
+// Status is where a user is in its lifecycle. It is a small integer in Go,
+// so a switch over it is cheap and the compiler catches a typo in a
+// constant, and a string on the wire, so JSON stays readable and a
+// reordering of the constants never changes what clients see.
+//
+// String is generated by stringer from the line comments below, which are
+// the wire names. A new constant gets its name by adding one and running
+// go generate ./serialization.
+type Status uint8
+
+// The zero Status is not a valid one: a DTO built without setting it fails
+// to marshal instead of telling clients something made up.
+const (
+	StatusActive  Status = iota + 1 // active
+	StatusDeleted                   // deleted
+)
+
+// valid reports whether s is one of the constants. iota keeps them
+// contiguous, a new one goes last and becomes the upper bound here.
+func (s Status) valid() bool {
+	return s >= StatusActive && s <= StatusDeleted
+}
+
+// MarshalText rather than MarshalJSON: encoding/json quotes text for us,
+// and the same method serves map keys, slog, and any other text encoder.
+func (s Status) MarshalText() ([]byte, error) {
+	if !s.valid() {
+		return nil, fmt.Errorf("serialization: invalid status %d", uint8(s))
+	}
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText accepts exactly the names MarshalText writes. JSON numbers
+// never reach it, encoding/json rejects them for a TextUnmarshaler.
+func (s *Status) UnmarshalText(b []byte) error {
+	for status := StatusActive; status.valid(); status++ {
+		if string(b) == status.String() {
+			*s = status
+			return nil
+		}
+	}
+	return fmt.Errorf("serialization: unknown status %q", b)
+}
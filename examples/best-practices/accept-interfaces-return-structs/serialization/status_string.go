@@ -0,0 +1,25 @@
+// Code generated by "stringer -type Status -linecomment"; DO NOT EDIT.
+
+package serialization
+
+import "strconv"
+
+func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[StatusActive-1]
+	_ = x[StatusDeleted-2]
+}
+
+const _Status_name = "activedeleted"
+
+var _Status_index = [...]uint8{0, 6, 13}
+
+func (i Status) String() string {
+	idx := int(i) - 1
+	if i < 1 || idx >= len(_Status_index)-1 {
+		return "Status(" + strconv.FormatInt(int64(i), 10) + ")"
+	}
+	return _Status_name[_Status_index[idx]:_Status_index[idx+1]]
+}
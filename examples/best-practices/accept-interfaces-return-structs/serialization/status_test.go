@@ -0,0 +1,52 @@
+package serialization_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/serialization"
+)
+
+func TestStatus(t *testing.T) {
+	for _, status := range []serialization.Status{serialization.StatusActive, serialization.StatusDeleted} {
+		t.Run(status.String(), func(t *testing.T) {
+			b, err := json.Marshal(status)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if string(b) != `"`+status.String()+`"` {
+				t.Errorf("Marshal = %s, want the string %q", b, status.String())
+			}
+			roundTrip(t, status)
+		})
+	}
+	if b, err := json.Marshal(serialization.Status(0)); err == nil {
+		t.Errorf("Marshal of the zero Status = %s, want an error", b)
+	}
+}
+
+func TestStatusRefused(t *testing.T) {
+	for _, in := range []string{`"ACTIVE"`, `"Active"`, `""`, `"suspended"`, `1`, `null`, `true`} {
+		var s serialization.Status
+		if err := json.Unmarshal([]byte(in), &s); err == nil && s != 0 {
+			t.Errorf("Unmarshal(%s) = %s, want it refused", in, s)
+		}
+	}
+}
+
+// TestStatusString checks stringer's names are the wire names.
+func TestStatusString(t *testing.T) {
+	for _, tt := range []struct {
+		status serialization.Status
+		want   string
+	}{
+		{serialization.StatusActive, "active"},
+		{serialization.StatusDeleted, "deleted"},
+		{0, "Status(0)"},
+		{9, "Status(9)"},
+	} {
+		if got := tt.status.String(); got != tt.want {
+			t.Errorf("Status(%d).String() = %q, want %q", uint8(tt.status), got, tt.want)
+		}
+	}
+}
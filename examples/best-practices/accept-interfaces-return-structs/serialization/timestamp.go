@@ -0,0 +1,62 @@
+package serialization
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// timestampLayout is RFC 3339 in UTC with exactly three fractional digits.
+// A fixed width sorts as text in time order, and milliseconds are what
+// JavaScript's Date keeps, finer digits would be dropped there anyway.
+const timestampLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// Timestamp is a time.Time with one wire format. time.Time's own MarshalJSON
+// keeps the value's offset and up to nine fractional digits, so the same
+// instant can be written many ways depending on the server's time zone.
+//
+// Embedding time.Time keeps its methods, IsZero among them, so a zero
+// Timestamp is left out by the omitzero tag option.
+type Timestamp struct {
+	time.Time
+}
+
+// NewTimestamp truncates t to what the wire keeps, so a value compares
+// equal to itself after a round trip.
+func NewTimestamp(t time.Time) Timestamp {
+	if t.IsZero() {
+		return Timestamp{}
+	}
+	return Timestamp{t.UTC().Truncate(time.Millisecond)}
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	if y := t.UTC().Year(); y < 0 || y > 9999 {
+		return nil, fmt.Errorf("serialization: year %d is outside RFC 3339", y)
+	}
+	return []byte(`"` + t.UTC().Format(timestampLayout) + `"`), nil
+}
+
+// UnmarshalJSON takes any RFC 3339 time, any offset and any precision, and
+// null for the zero value. Dates without a time, Unix seconds, and other
+// layouts are rejected rather than guessed at.
+func (t *Timestamp) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) {
+		*t = Timestamp{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("serialization: timestamp must be an RFC 3339 string, got %s", b)
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return fmt.Errorf("serialization: timestamp %q is not RFC 3339", s)
+	}
+	t.Time = parsed.UTC()
+	return nil
+}
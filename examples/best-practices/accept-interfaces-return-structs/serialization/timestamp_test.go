@@ -0,0 +1,76 @@
+package serialization_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/serialization"
+)
+
+var instant = time.Date(2026, 3, 14, 15, 9, 26, 535897932, time.UTC)
+
+// TestTimestampMarshal checks every timestamp is written as UTC to the
+// millisecond, whatever its location.
+func TestTimestampMarshal(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		t    time.Time
+		want string
+	}{
+		{"UTC", instant, `"2026-03-14T15:09:26.535Z"`},
+		{"an offset", instant.In(time.FixedZone("EST", -5*60*60)), `"2026-03-14T15:09:26.535Z"`},
+		{"local time", instant.Local(), `"2026-03-14T15:09:26.535Z"`},
+		{"whole seconds", instant.Truncate(time.Second), `"2026-03-14T15:09:26.000Z"`},
+		{"year 1", time.Date(1, 1, 1, 0, 0, 0, 1e6, time.UTC), `"0001-01-01T00:00:00.001Z"`},
+		{"year 9999", time.Date(9999, 12, 31, 23, 59, 59, 999e6, time.UTC), `"9999-12-31T23:59:59.999Z"`},
+		{"zero", time.Time{}, "null"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := serialization.NewTimestamp(tt.t)
+			b, err := json.Marshal(ts)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("Marshal = %s, want %s", b, tt.want)
+			}
+			roundTrip(t, ts)
+		})
+	}
+	if b, err := json.Marshal(serialization.NewTimestamp(time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC))); err == nil {
+		t.Errorf("Marshal past year 9999 = %s, want an error", b)
+	}
+}
+
+func TestTimestampUnmarshal(t *testing.T) {
+	for _, in := range []string{
+		`"2026-03-14T15:09:26Z"`,
+		`"2026-03-14T10:09:26-05:00"`,
+		`"2026-03-14T15:09:26.535897932Z"`,
+		`"2026-03-14T16:09:26.5+01:00"`,
+	} {
+		var ts serialization.Timestamp
+		if err := json.Unmarshal([]byte(in), &ts); err != nil {
+			t.Errorf("Unmarshal(%s): %v", in, err)
+			continue
+		}
+		if ts.Location() != time.UTC || !ts.Truncate(time.Second).Equal(instant.Truncate(time.Second)) {
+			t.Errorf("Unmarshal(%s) = %s, want %s in UTC", in, ts.Time, instant.Truncate(time.Second))
+		}
+	}
+	for _, in := range []string{
+		`"2026-03-14"`,
+		`"2026-03-14 15:09:26Z"`,
+		`"14 Mar 26 15:09 UTC"`,
+		`"2026-03-14T15:09:26"`,
+		`1773500966`,
+		`""`,
+		`{}`,
+	} {
+		var ts serialization.Timestamp
+		if err := json.Unmarshal([]byte(in), &ts); err == nil {
+			t.Errorf("Unmarshal(%s) = %s, want it refused", in, ts.Time)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Package serialization is a user DTO with its JSON encoding written out
+// by hand where the defaults fall short:
+//
+//   - Status is an integer enum in Go and a string on the wire.
+//   - Timestamp writes every time as UTC RFC 3339 to the millisecond, and
+//     reads any RFC 3339 time back.
+//   - User.Password is read from JSON and never written to it.
+//
+// Each is done with the narrowest hook that works: MarshalText for the
+// enum, MarshalJSON on the one field type for times, and MarshalJSON on
+// User only for what the struct tags cannot say.
+package serialization
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/secret"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+//go:generate go run ../cmd/fieldsgen -type User
+
+// User is the JSON DTO for a service.User. Fields, in user_fields.go, is
+// generated from it.
+type User struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name,omitempty"`
+	Status    Status    `json:"status"`
+	Version   int64     `json:"version"`
+	CreatedAt Timestamp `json:"created_at"`
+	UpdatedAt Timestamp `json:"updated_at"`
+	DeletedAt Timestamp `json:"deleted_at,omitzero"`
+	// Password is write-only: a client may send one to set it, no response
+	// ever carries it back, whatever the value.
+	Password secret.String `json:"password"`
+}
+
+// FromUser is the DTO for user, truncated to what the wire keeps.
+func FromUser(user *service.User) User {
+	dto := User{
+		ID:        user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		Status:    StatusActive,
+		Version:   user.Version,
+		CreatedAt: NewTimestamp(user.CreatedAt),
+		UpdatedAt: NewTimestamp(user.UpdatedAt),
+		DeletedAt: NewTimestamp(user.DeletedAt),
+	}
+	if user.Deleted() {
+		dto.Status = StatusDeleted
+	}
+	return dto
+}
+
+// ToUser is the domain user u describes. Status is not carried over, the
+// domain derives it from DeletedAt.
+func (u User) ToUser() *service.User {
+	return &service.User{
+		ID:        u.ID,
+		Email:     u.Email,
+		Name:      u.Name,
+		Version:   u.Version,
+		CreatedAt: u.CreatedAt.Time,
+		UpdatedAt: u.UpdatedAt.Time,
+		DeletedAt: u.DeletedAt.Time,
+	}
+}
+
+// plain is User without its methods. Marshaling a User from inside its own
+// MarshalJSON would call MarshalJSON again, forever, marshaling a plain
+// uses the struct tags and nothing else.
+type plain User
+
+// MarshalJSON leaves the password out. secret.String would only redact it,
+// a "password" key in every response invites a client to start reading
+// it. The outer Password sits shallower than plain's, so encoding/json
+// uses it for the "password" key and ignores the embedded one, and being
+// nil it is omitted.
+//
+// The receiver is a value, not a pointer, so a User is encoded this way
+// whether it is passed by value or by pointer.
+func (u User) MarshalJSON() ([]byte, error) {
+	if u.Status != StatusActive && u.Status != StatusDeleted {
+		return nil, fmt.Errorf("serialization: user %q has invalid status %d", u.ID, uint8(u.Status))
+	}
+	return json.Marshal(struct {
+		plain
+		Password *struct{} `json:"password,omitempty"`
+	}{plain: plain(u)})
+}
+
+// UnmarshalJSON is strict where encoding/json is lenient: unknown keys,
+// a missing status, and trailing data are errors instead of being ignored,
+// so a client's typo fails loud.
+func (u *User) UnmarshalJSON(b []byte) error {
+	var p struct {
+		plain
+		// a pointer tells a missing status from one that failed to parse
+		Status *Status `json:"status"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return fmt.Errorf("serialization: user: %w", err)
+	}
+	if !errors.Is(dec.Decode(&struct{}{}), io.EOF) {
+		return errors.New("serialization: user: trailing data after the object")
+	}
+	if p.Status == nil {
+		return errors.New("serialization: user: status is required")
+	}
+	*u = User(p.plain)
+	u.Status = *p.Status
+	return nil
+}
@@ -0,0 +1,67 @@
+package serialization_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/serialization"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+func fieldsDTO() serialization.User {
+	now := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	return serialization.FromUser(&service.User{ID: "1", Email: "ada@example.com", Name: "Ada", Version: 3, CreatedAt: now, UpdatedAt: now})
+}
+
+// objectKeys is the keys of the JSON object b in the order they appear.
+func objectKeys(t *testing.T, b []byte) []string {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if _, err := dec.Token(); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key.(string))
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return keys
+}
+
+func TestFieldsAreJSONKeys(t *testing.T) {
+	dto := fieldsDTO()
+	b, err := json.Marshal(dto)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var names []string
+	for _, f := range dto.Fields() {
+		names = append(names, f.Name)
+	}
+	// the password is write-only and deleted_at is omitted while zero,
+	// Fields has both
+	want := slices.DeleteFunc(slices.Clone(names), func(name string) bool { return name == "password" || name == "deleted_at" })
+	if keys := objectKeys(t, b); !slices.Equal(keys, want) {
+		t.Errorf("JSON keys = %v, want Fields' names %v", keys, want)
+	}
+}
+
+func TestFieldsValues(t *testing.T) {
+	values := make(map[string]any)
+	for _, f := range fieldsDTO().Fields() {
+		values[f.Name] = f.Value
+	}
+	if values["id"] != "1" || values["email"] != "ada@example.com" || values["status"] != serialization.StatusActive || values["version"] != int64(3) {
+		t.Errorf("Fields = %v, want the user's values", values)
+	}
+}
@@ -0,0 +1,108 @@
+package serialization_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/secret"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/serialization"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+var (
+	created = time.Date(2026, 1, 2, 3, 4, 5, 678901234, time.UTC)
+	users   = []*service.User{
+		{ID: "ada", Email: "ada@example.com", Name: "Ada Lovelace", Version: 1, CreatedAt: created, UpdatedAt: created},
+		{ID: "grace", Email: "grace@example.com", Version: 7, CreatedAt: created, UpdatedAt: created.Add(time.Hour)},
+		{ID: "gone", Email: "gone@example.com", Name: "Gone", Version: 2, CreatedAt: created, UpdatedAt: created, DeletedAt: created.Add(48 * time.Hour)},
+		{ID: "ünï", Email: "u@example.com", Name: `quotes " and <tags> & 日本語`, Version: 1, CreatedAt: created.In(time.Local), UpdatedAt: created},
+	}
+)
+
+func TestUserRoundTrip(t *testing.T) {
+	for _, user := range users {
+		t.Run(user.ID, func(t *testing.T) {
+			dto := serialization.FromUser(user)
+			roundTrip(t, dto)
+			// the domain user comes back as the wire keeps it
+			want := *user
+			want.CreatedAt = serialization.NewTimestamp(user.CreatedAt).Time
+			want.UpdatedAt = serialization.NewTimestamp(user.UpdatedAt).Time
+			want.DeletedAt = serialization.NewTimestamp(user.DeletedAt).Time
+			if got := dto.ToUser(); !reflect.DeepEqual(*got, want) {
+				t.Errorf("ToUser = %+v, want %+v", *got, want)
+			}
+			wantStatus := serialization.StatusActive
+			if user.Deleted() {
+				wantStatus = serialization.StatusDeleted
+			}
+			if dto.Status != wantStatus {
+				t.Errorf("Status = %s, want %s", dto.Status, wantStatus)
+			}
+		})
+	}
+}
+
+// TestUserShape pins a user's JSON to exactly the documented shape.
+func TestUserShape(t *testing.T) {
+	b, err := json.Marshal(serialization.FromUser(users[2]))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	want := `{"id":"gone","email":"gone@example.com","name":"Gone","status":"deleted","version":2,` +
+		`"created_at":"2026-01-02T03:04:05.678Z","updated_at":"2026-01-02T03:04:05.678Z","deleted_at":"2026-01-04T03:04:05.678Z"}`
+	if string(b) != want {
+		t.Errorf("Marshal = %s\nwant       %s", b, want)
+	}
+}
+
+// TestPasswordNeverWritten checks the password is read but never written:
+// by value, by pointer, nested, in a slice, and through slog.
+func TestPasswordNeverWritten(t *testing.T) {
+	var dto serialization.User
+	in := `{"id":"ada","email":"ada@example.com","status":"active","password":"correct horse"}`
+	if err := json.Unmarshal([]byte(in), &dto); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if got := dto.Password.Reveal(); got != "correct horse" {
+		t.Fatalf("Password = %q, want correct horse", got)
+	}
+	for _, v := range []any{dto, &dto, map[string]any{"user": dto}, []serialization.User{dto}} {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("Marshal(%T): %v", v, err)
+		}
+		if bytes.Contains(b, []byte("password")) || bytes.Contains(b, []byte("correct horse")) {
+			t.Errorf("Marshal(%T) leaked the password: %s", v, b)
+		}
+	}
+	var logged bytes.Buffer
+	slog.New(slog.NewJSONHandler(&logged, nil)).Info("user", slog.Any("user", dto))
+	if strings.Contains(logged.String(), "correct horse") {
+		t.Errorf("slog leaked the password: %s", logged.String())
+	}
+}
+
+func TestUserRefused(t *testing.T) {
+	for _, tt := range []struct{ name, in string }{
+		{"an unknown key", `{"id":"ada","email":"ada@example.com","status":"active","admin":true}`},
+		{"a missing status", `{"id":"ada","email":"ada@example.com"}`},
+		{"a null status", `{"id":"ada","email":"ada@example.com","status":null}`},
+		{"a numeric status", `{"id":"ada","email":"ada@example.com","status":1}`},
+		{"a bad timestamp", `{"id":"ada","email":"ada@example.com","status":"active","created_at":"yesterday"}`},
+		{"a wrongly typed field", `{"id":7,"email":"ada@example.com","status":"active"}`},
+	} {
+		var dto serialization.User
+		if err := json.Unmarshal([]byte(tt.in), &dto); err == nil {
+			t.Errorf("%s: Unmarshal succeeded, want it refused", tt.name)
+		}
+	}
+	if b, err := json.Marshal(serialization.User{ID: "ada", Password: secret.New("x")}); err == nil {
+		t.Errorf("Marshal of a user without a status = %s, want an error", b)
+	}
+}
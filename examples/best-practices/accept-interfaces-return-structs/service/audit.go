@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/audit"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+)
+
+// WithAuditSink writes an audit.Entry to sink for every stored mutation:
+// creates, updates, deletes, restores, renames, and purges, with the user
+// before and after, the caller from ctxutil.PrincipalFrom, and the tenant
+// from ctxutil.Tenant. The entry is written once the change is stored, so
+// a sink failure is reported like an After hook error: the change stands
+// and the caller gets the error back.
+func WithAuditSink(sink audit.Sink) Option {
+	return func(u *UserService) {
+		u.audit = sink
+	}
+}
+
+// record is a no-op without WithAuditSink. before is nil for a create,
+// after for a purge.
+func (u *UserService) record(ctx context.Context, action audit.Action, before, after *User) error {
+	if u.audit == nil {
+		return nil
+	}
+	entry := audit.Entry{
+		Action: action,
+		At:     u.clock.Now(),
+		Before: snapshot(before),
+		After:  snapshot(after),
+	}
+	entry.Changes = audit.Diff(entry.Before, entry.After)
+	if after != nil {
+		entry.UserID = after.ID
+	} else if before != nil {
+		entry.UserID = before.ID
+	}
+	if p, ok := ctxutil.PrincipalFrom(ctx); ok {
+		entry.Actor = p.Subject
+	}
+	entry.RequestID, _ = ctxutil.RequestID(ctx)
+	entry.Tenant, _ = ctxutil.Tenant(ctx)
+	return u.audit.Write(ctx, entry)
+}
+
+func snapshot(user *User) *audit.Snapshot {
+	if user == nil {
+		return nil
+	}
+	return &audit.Snapshot{
+		ID:        user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		Status:    string(user.Status),
+		Version:   user.Version,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		DeletedAt: user.DeletedAt,
+	}
+}
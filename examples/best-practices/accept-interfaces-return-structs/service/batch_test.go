@@ -0,0 +1,82 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/multierr"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/validate"
+)
+
+func TestBatchResultEmpty(t *testing.T) {
+	if err := (service.BatchResult{}).Err(); err != nil {
+		t.Errorf("Err = %v, want nil", err)
+	}
+}
+
+// TestCreateUsersErr checks a batch's error unwraps to each failed item.
+func TestCreateUsersErr(t *testing.T) {
+	ctx := context.Background()
+	users := service.NewUserService(db.NewMemoryStore())
+	if err := users.CreateUser(ctx, &service.User{ID: "ada", Email: "ada@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	result, err := users.CreateUsers(ctx, []*service.User{
+		{ID: "alan", Email: "alan@example.com"},
+		{ID: "grace", Email: "not-an-email"},
+		{ID: "ada", Email: "ada2@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("CreateUsers: %v", err)
+	}
+	batchErr := result.Err()
+	var item *service.ItemError
+	if !errors.As(batchErr, &item) || item.Index != 1 {
+		t.Errorf("errors.As found %v, want item 1 first", item)
+	}
+	var invalid *validate.ValidationError
+	if !errors.As(batchErr, &invalid) || invalid.Fields[0].Field != "Email" {
+		t.Errorf("errors.As found %v, want the email's validation error", invalid)
+	}
+	if !errors.Is(batchErr, errs.ErrConflict) {
+		t.Errorf("errors.Is(ErrConflict) = false, item 2 is a duplicate: %v", batchErr)
+	}
+	want := `2 failures:
+  - item 1 (id "grace"): validation failed: Email must be a valid email address
+  - item 2 (id "ada"): db.MemoryStore.InsertMany: conflict`
+	if got := multierr.Summary(batchErr, 0); got != want {
+		t.Errorf("Summary = %q, want %q", got, want)
+	}
+}
+
+// TestImportUsersCSVErr checks an import's error counts the rows it did
+// not keep.
+func TestImportUsersCSVErr(t *testing.T) {
+	users := service.NewUserService(db.NewMemoryStore())
+	var csv strings.Builder
+	csv.WriteString("id,email\n")
+	for i := range 1500 {
+		fmt.Fprintf(&csv, "user-%d,bad-%d\n", i, i)
+	}
+	result, err := users.ImportUsersCSV(context.Background(), strings.NewReader(csv.String()))
+	if err != nil {
+		t.Fatalf("ImportUsersCSV: %v", err)
+	}
+	importErr := result.Err()
+	var row *service.RowError
+	if !errors.As(importErr, &row) || row.Line != 2 {
+		t.Errorf("errors.As found %v, want line 2 first", row)
+	}
+	if !errors.Is(importErr, errs.ErrInvalidInput) {
+		t.Errorf("errors.Is(ErrInvalidInput) = false: %.200v", importErr)
+	}
+	if summary := multierr.Summary(importErr, 3); !strings.HasPrefix(summary, "1500 failures:") || !strings.HasSuffix(summary, "... and 1497 more") {
+		t.Errorf("Summary = %q, want all 1500 counted", summary)
+	}
+}
@@ -0,0 +1,74 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/budget"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// insertDeadlines is a store that notes how long each Insert had left.
+type insertDeadlines struct {
+	*db.MemoryStore
+	clock clock.Clock
+	left  []time.Duration
+}
+
+func (s *insertDeadlines) Insert(ctx context.Context, user *service.User) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		s.left = append(s.left, deadline.Sub(s.clock.Now()))
+	}
+	return s.MemoryStore.Insert(ctx, user)
+}
+
+// TestCreateUserBudget checks the insert keeps its share while a before
+// hook waits out its own.
+func TestCreateUserBudget(t *testing.T) {
+	fake := clock.NewFake(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	store := &insertDeadlines{MemoryStore: db.NewMemoryStore(), clock: fake}
+	users := service.NewUserService(store,
+		service.WithClock(fake),
+		service.WithCreateBudget(budget.NewPlan(service.CreateUserSteps(), budget.WithClock(fake))),
+	)
+	// a best-effort enrichment call that hangs until its step ends
+	users.OnBeforeUserCreated(func(ctx context.Context, _ *service.User) error {
+		<-ctx.Done()
+		return nil
+	})
+	var publishLeft time.Duration
+	users.OnUserCreated(func(ctx context.Context, _ *service.User) error {
+		deadline, _ := ctx.Deadline()
+		publishLeft = deadline.Sub(fake.Now())
+		return nil
+	})
+	parent, cancel := clock.WithTimeout(context.Background(), fake, 2*time.Second)
+	defer cancel()
+	created := make(chan error, 1)
+	go func() {
+		created <- users.CreateUser(parent, &service.User{ID: "ada", Email: "ada@example.com"})
+	}()
+	// the parent's timer and the validate step's
+	for fake.Waiters() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	fake.Advance(500 * time.Millisecond)
+	select {
+	case err := <-created:
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("CreateUser never returned")
+	}
+	// 1.5s left, less publish's 100ms, two thirds of it
+	if want := 1400 * time.Millisecond * 2 / 3; len(store.left) != 1 || store.left[0] != want {
+		t.Errorf("the insert had %v, want %s", store.left, want)
+	}
+	if want := 1500 * time.Millisecond; publishLeft != want {
+		t.Errorf("the after hook had %s, want the %s left", publishLeft, want)
+	}
+}
@@ -0,0 +1,47 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// TestTimestamps stamps users from a fake clock, so the test can say
+// exactly when each write happened without sleeping between them.
+func TestTimestamps(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	c := clock.NewFake(created)
+	users := service.NewUserService(db.NewMemoryStore(), service.WithClock(c))
+
+	if err := users.CreateUser(ctx, &service.User{ID: "ada", Email: "ada@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	c.Advance(time.Hour)
+	ada, err := users.RetrieveUser(ctx, "ada")
+	if err != nil {
+		t.Fatalf("RetrieveUser: %v", err)
+	}
+	if !ada.CreatedAt.Equal(created) || !ada.UpdatedAt.Equal(created) {
+		t.Errorf("after create CreatedAt, UpdatedAt = %v, %v, want both %v", ada.CreatedAt, ada.UpdatedAt, created)
+	}
+
+	ada.Name = "Ada"
+	if err := users.UpdateUser(ctx, ada); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	ada, err = users.RetrieveUser(ctx, "ada")
+	if err != nil {
+		t.Fatalf("RetrieveUser: %v", err)
+	}
+	if !ada.CreatedAt.Equal(created) {
+		t.Errorf("after update CreatedAt = %v, want it kept at %v", ada.CreatedAt, created)
+	}
+	if want := created.Add(time.Hour); !ada.UpdatedAt.Equal(want) {
+		t.Errorf("after update UpdatedAt = %v, want %v", ada.UpdatedAt, want)
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/credentials"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/validate"
+)
+
+const (
+	minPasswordLen = 8
+	maxPasswordLen = 128
+)
+
+// WithPasswords enables SetPassword and VerifyPassword, keeping the hashes
+// hasher makes in store. Pass a credentials.Chain to move users to a new
+// algorithm: each one is rehashed with the first hasher at their next
+// successful VerifyPassword.
+func WithPasswords(store credentials.Store, hasher credentials.Hasher) Option {
+	return func(u *UserService) {
+		u.passwords = store
+		u.hasher = hasher
+		// a hash to verify against when there is none, see VerifyPassword
+		u.decoy = sync.OnceValue(func() string {
+			hash, _ := hasher.Hash("decoy password")
+			return hash
+		})
+	}
+}
+
+// SetPassword sets or replaces the password of a live user.
+func (u *UserService) SetPassword(ctx context.Context, id, password string) (err error) {
+	ctx, span := u.startSpan(ctx, "SetPassword", attribute.String("user.id", id))
+	defer func() { endSpan(span, err) }()
+	if u.passwords == nil {
+		return errs.Wrap("service.SetPassword", errors.ErrUnsupported)
+	}
+	if id == "" {
+		return errs.Wrap("service.SetPassword", errs.ErrInvalidInput)
+	}
+	var v validate.Errors
+	if n := utf8.RuneCountInString(password); n < minPasswordLen {
+		v.Add("Password", fmt.Sprintf("must be at least %d characters", minPasswordLen))
+	}
+	v.MaxLen("Password", password, maxPasswordLen)
+	if err := v.Err(); err != nil {
+		return errs.Wrap("service.SetPassword", err)
+	}
+	user, err := u.store.Get(ctx, id)
+	if err != nil {
+		return errs.Wrap("service.SetPassword", err)
+	}
+	if user.Deleted() {
+		return errs.Wrap("service.SetPassword", errs.ErrNotFound)
+	}
+	hash, err := u.hasher.Hash(password)
+	if err != nil {
+		return errs.Wrap("service.SetPassword", err)
+	}
+	if err := u.passwords.SetHash(ctx, id, hash); err != nil {
+		return errs.Wrap("service.SetPassword", err)
+	}
+	u.log(ctx).DebugContext(ctx, "password set", slog.String("user_id", id))
+	return nil
+}
+
+// VerifyPassword returns nil when password is the user's, and an error
+// matching errs.ErrUnauthenticated when it is not, when the user has no
+// password, and when there is no such live user: the caller cannot tell
+// which, and neither can anyone timing it, since every case runs the hasher.
+// A match against a hash that is not current is rehashed before returning,
+// a failure to store the new hash is logged and the login still succeeds.
+func (u *UserService) VerifyPassword(ctx context.Context, id, password string) (err error) {
+	ctx, span := u.startSpan(ctx, "VerifyPassword", attribute.String("user.id", id))
+	defer func() { endSpan(span, err) }()
+	if u.passwords == nil {
+		return errs.Wrap("service.VerifyPassword", errors.ErrUnsupported)
+	}
+	hash, err := u.passwordHash(ctx, id)
+	if errors.Is(err, errs.ErrNotFound) {
+		_ = u.hasher.Verify(u.decoy(), password)
+		return errs.Wrap("service.VerifyPassword", errs.ErrUnauthenticated)
+	}
+	if err != nil {
+		return errs.Wrap("service.VerifyPassword", err)
+	}
+	if err := u.hasher.Verify(hash, password); err != nil {
+		if errors.Is(err, credentials.ErrMismatch) {
+			err = errs.ErrUnauthenticated
+		}
+		return errs.Wrap("service.VerifyPassword", err)
+	}
+	if !u.hasher.Current(hash) {
+		u.rehash(ctx, id, password)
+	}
+	return nil
+}
+
+// passwordHash fails with errs.ErrNotFound for a missing or deleted user as
+// well as for one without a password.
+func (u *UserService) passwordHash(ctx context.Context, id string) (string, error) {
+	user, err := u.store.Get(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if user.Deleted() {
+		return "", errs.ErrNotFound
+	}
+	return u.passwords.Hash(ctx, id)
+}
+
+func (u *UserService) rehash(ctx context.Context, id, password string) {
+	hash, err := u.hasher.Hash(password)
+	if err == nil {
+		err = u.passwords.SetHash(ctx, id, hash)
+	}
+	if err != nil {
+		u.log(ctx).WarnContext(ctx, "password rehash failed", slog.String("user_id", id), slog.String("error", err.Error()))
+		return
+	}
+	u.log(ctx).DebugContext(ctx, "password rehashed", slog.String("user_id", id))
+}
+
+// movePassword follows a rename, the store is keyed by user ID.
+func (u *UserService) movePassword(ctx context.Context, oldID, newID string) error {
+	if u.passwords == nil {
+		return nil
+	}
+	hash, err := u.passwords.Hash(ctx, oldID)
+	if errors.Is(err, errs.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := u.passwords.SetHash(ctx, newID, hash); err != nil {
+		return err
+	}
+	return u.passwords.Delete(ctx, oldID)
+}
+
+func (u *UserService) dropPassword(ctx context.Context, id string) error {
+	if u.passwords == nil {
+		return nil
+	}
+	return u.passwords.Delete(ctx, id)
+}
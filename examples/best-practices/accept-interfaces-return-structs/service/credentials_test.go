@@ -0,0 +1,149 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/credentials"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// a low bcrypt cost keeps the tests quick, production would use the default
+var (
+	legacy  = credentials.Bcrypt{Cost: 4}
+	current = credentials.Argon2id{}
+	hasher  = credentials.Chain{current, legacy}
+)
+
+// withPassword returns a service hashing with hasher over one user, ada,
+// whose password was set by a service hashing with setWith.
+func withPassword(t *testing.T, setWith credentials.Hasher) (*service.UserService, *credentials.Memory) {
+	t.Helper()
+	ctx := context.Background()
+	store := db.NewMemoryStore()
+	hashes := credentials.NewMemory()
+	old := service.NewUserService(store, service.WithPasswords(hashes, setWith))
+	if err := old.CreateUser(ctx, &service.User{ID: "ada", Email: "ada@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if err := old.SetPassword(ctx, "ada", "correct horse"); err != nil {
+		t.Fatalf("SetPassword: %v", err)
+	}
+	return service.NewUserService(store, service.WithPasswords(hashes, hasher)), hashes
+}
+
+func hashOf(t *testing.T, hashes *credentials.Memory) string {
+	t.Helper()
+	hash, err := hashes.Hash(context.Background(), "ada")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	return hash
+}
+
+// TestPasswordUpgrade checks users hashed with bcrypt, or with weaker
+// argon2id parameters, move to the current settings the next time they log
+// in, and only then.
+func TestPasswordUpgrade(t *testing.T) {
+	ctx := context.Background()
+	for _, tt := range []struct {
+		name    string
+		setWith credentials.Hasher
+	}{
+		{"bcrypt", legacy},
+		{"weaker argon2id", credentials.Argon2id{Memory: 8 * 1024, Time: 1}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			users, hashes := withPassword(t, tt.setWith)
+			before := hashOf(t, hashes)
+			if err := users.VerifyPassword(ctx, "ada", "wrong horse"); !errors.Is(err, errs.ErrUnauthenticated) {
+				t.Fatalf("wrong password: err = %v, want ErrUnauthenticated", err)
+			}
+			if hashOf(t, hashes) != before {
+				t.Fatal("hash changed on a failed login")
+			}
+			if err := users.VerifyPassword(ctx, "ada", "correct horse"); err != nil {
+				t.Fatalf("VerifyPassword: %v", err)
+			}
+			if hash := hashOf(t, hashes); !current.Current(hash) || !strings.Contains(hash, "m=19456,t=2,p=1") {
+				t.Errorf("hash after login = %s, want argon2id with the default parameters", hash)
+			}
+			if err := users.VerifyPassword(ctx, "ada", "correct horse"); err != nil {
+				t.Errorf("VerifyPassword after the upgrade: %v", err)
+			}
+		})
+	}
+}
+
+func TestCurrentHashNotRewritten(t *testing.T) {
+	users, hashes := withPassword(t, current)
+	before := hashOf(t, hashes)
+	if err := users.VerifyPassword(context.Background(), "ada", "correct horse"); err != nil {
+		t.Fatalf("VerifyPassword: %v", err)
+	}
+	// the salt is new on every Hash, so a rewrite would show
+	if hashOf(t, hashes) != before {
+		t.Error("a current hash was rewritten")
+	}
+}
+
+// TestVerifyPasswordIndistinguishable checks unknown, passwordless, and
+// deleted users all fail the same way as a wrong password.
+func TestVerifyPasswordIndistinguishable(t *testing.T) {
+	ctx := context.Background()
+	users, _ := withPassword(t, current)
+	if err := users.CreateUser(ctx, &service.User{ID: "grace", Email: "grace@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	for _, tt := range []struct {
+		name, id string
+	}{
+		{"unknown user", "nobody"},
+		{"no password", "grace"},
+	} {
+		if err := users.VerifyPassword(ctx, tt.id, "correct horse"); !errors.Is(err, errs.ErrUnauthenticated) {
+			t.Errorf("%s: err = %v, want ErrUnauthenticated", tt.name, err)
+		}
+	}
+	if err := users.DeleteUser(ctx, "ada"); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if err := users.VerifyPassword(ctx, "ada", "correct horse"); !errors.Is(err, errs.ErrUnauthenticated) {
+		t.Errorf("deleted user: err = %v, want ErrUnauthenticated", err)
+	}
+	if err := users.SetPassword(ctx, "ada", "correct horse"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("SetPassword on a deleted user: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSetPassword(t *testing.T) {
+	ctx := context.Background()
+	users, hashes := withPassword(t, current)
+	for _, password := range []string{"short", strings.Repeat("x", 129)} {
+		if err := users.SetPassword(ctx, "ada", password); !errors.Is(err, errs.ErrInvalidInput) {
+			t.Errorf("SetPassword of %d characters: err = %v, want ErrInvalidInput", len(password), err)
+		}
+	}
+	// renames and purges follow the user
+	if err := users.RenameUser(ctx, "ada", "lovelace"); err != nil {
+		t.Fatalf("RenameUser: %v", err)
+	}
+	if err := users.VerifyPassword(ctx, "lovelace", "correct horse"); err != nil {
+		t.Errorf("VerifyPassword after rename: %v", err)
+	}
+	if err := users.PurgeUser(ctx, "lovelace"); err != nil {
+		t.Fatalf("PurgeUser: %v", err)
+	}
+	if _, err := hashes.Hash(ctx, "lovelace"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("hash after purge: err = %v, want ErrNotFound", err)
+	}
+
+	without := service.NewUserService(db.NewMemoryStore())
+	if err := without.SetPassword(ctx, "ada", "correct horse"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("SetPassword without WithPasswords: err = %v, want ErrUnsupported", err)
+	}
+}
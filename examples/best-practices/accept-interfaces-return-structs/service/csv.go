@@ -0,0 +1,265 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/bufpool"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/multierr"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/validate"
+)
+
+const (
+	// importBatchSize rows are held at a time and created with one
+	// CreateUsers, which is all of the file an import keeps in memory.
+	importBatchSize = 500
+	// maxRowErrors bounds ImportResult.Errors, a file of nothing but bad
+	// rows would otherwise be held in memory as errors instead.
+	maxRowErrors = 1000
+)
+
+// csvHeader is the columns ExportUsersCSV writes. Import reads id, email,
+// and name, and skips the rest, which the service owns, so an export
+// imports into another deployment as is.
+var csvHeader = []string{"id", "email", "name", "created_at", "updated_at", "version"}
+
+// RowError reports why one row of an import was not created.
+type RowError struct {
+	// Line is the row's line in the file, the header is line 1.
+	Line int
+	ID   string
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d (id %q): %s", e.Line, e.ID, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// ImportResult counts the rows an import created and failed. Errors holds
+// the first maxRowErrors failures in file order, Failed counts them all.
+type ImportResult struct {
+	Created int
+	Failed  int
+	Errors  []*RowError
+}
+
+// Err is the failures as one error, nil when there were none, see
+// BatchResult.Err. The ones past maxRowErrors are counted in it but were
+// not kept to unwrap.
+func (r ImportResult) Err() error {
+	var failed multierr.Errors
+	for _, e := range r.Errors {
+		failed.Append(e)
+	}
+	failed.Omit(r.Failed - len(r.Errors))
+	return failed.Err()
+}
+
+func (r *ImportResult) fail(err *RowError) {
+	r.Failed++
+	if len(r.Errors) < maxRowErrors {
+		r.Errors = append(r.Errors, err)
+	}
+}
+
+// ImportUsersCSV creates a user for every row of a CSV file with a header
+// row naming its columns, email required, id and name optional:
+//
+//	id,email,name
+//	ada,ada@example.com,Ada Lovelace
+//
+// The file is read as a stream and created importBatchSize rows at a time
+// with CreateUsers, so its size is bounded by the caller's reader, not
+// memory. Like CreateUsers, a bad row is reported in the result and the
+// import carries on. The returned error is for a file that cannot be read
+// on, a bad header or broken quoting, and the rows before it stay created:
+// the import is not one transaction.
+func (u *UserService) ImportUsersCSV(ctx context.Context, r io.Reader) (_ ImportResult, err error) {
+	ctx, span := u.startSpan(ctx, "ImportUsersCSV")
+	defer func() { endSpan(span, err) }()
+	var result ImportResult
+
+	br := bufpool.GetReader(r)
+	defer bufpool.PutReader(br)
+	cr := csv.NewReader(br)
+	// FieldsPerRecord left at 0 holds every row to the header's field count
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return result, errs.Wrap("service.ImportUsersCSV", fmt.Errorf("%w: empty file, want a header row", errs.ErrInvalidInput))
+	}
+	if err != nil {
+		return result, errs.Wrap("service.ImportUsersCSV", csvError(err))
+	}
+	cols, err := newCSVColumns(header)
+	if err != nil {
+		return result, errs.Wrap("service.ImportUsersCSV", err)
+	}
+
+	batch := make([]*User, 0, importBatchSize)
+	lines := make([]int, 0, importBatchSize)
+	// rows rejected while reading the batch, reported with its failures so
+	// the errors stay in file order
+	var rejected []*RowError
+	flush := func() error {
+		res, err := u.CreateUsers(ctx, batch)
+		if err != nil {
+			return err
+		}
+		result.Created += len(res.Created)
+		for _, f := range res.Failed {
+			rejected = append(rejected, &RowError{Line: lines[f.Index], ID: f.ID, Err: f.Err})
+		}
+		slices.SortFunc(rejected, func(a, b *RowError) int { return cmp.Compare(a.Line, b.Line) })
+		for _, e := range rejected {
+			result.fail(e)
+		}
+		batch, lines, rejected = batch[:0], lines[:0], rejected[:0]
+		return nil
+	}
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		switch {
+		case errors.Is(err, csv.ErrFieldCount):
+			line, _ := cr.FieldPos(0)
+			rejected = append(rejected, &RowError{Line: line, Err: &validate.ValidationError{Fields: []validate.FieldError{{
+				Field:   "row",
+				Message: fmt.Sprintf("has %d fields, the header has %d", len(record), len(header)),
+			}}}})
+		case err != nil:
+			// the rows read so far are good, create them before giving up
+			return result, errs.Wrap("service.ImportUsersCSV", errors.Join(csvError(err), flush()))
+		default:
+			line, _ := cr.FieldPos(0)
+			batch = append(batch, cols.user(record))
+			lines = append(lines, line)
+		}
+		if len(batch)+len(rejected) == importBatchSize {
+			if err := flush(); err != nil {
+				return result, errs.Wrap("service.ImportUsersCSV", err)
+			}
+		}
+	}
+	if len(batch) > 0 || len(rejected) > 0 {
+		if err := flush(); err != nil {
+			return result, errs.Wrap("service.ImportUsersCSV", err)
+		}
+	}
+	span.SetAttributes(attribute.Int("import.created", result.Created), attribute.Int("import.failed", result.Failed))
+	return result, nil
+}
+
+// csvError makes malformed CSV invalid input, a failing reader stays as it
+// is so its cause, say a body over the size limit, is still there to find.
+func csvError(err error) error {
+	var perr *csv.ParseError
+	if errors.As(err, &perr) {
+		return fmt.Errorf("%w: %w", errs.ErrInvalidInput, err)
+	}
+	return err
+}
+
+// csvColumns is where each imported field sits in a row, -1 when the file
+// has no such column.
+type csvColumns struct {
+	id, email, name int
+}
+
+func newCSVColumns(header []string) (csvColumns, error) {
+	cols := csvColumns{id: -1, email: -1, name: -1}
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		if i == 0 {
+			// spreadsheets like to start their CSV with a byte order mark
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if seen[name] {
+			return cols, fmt.Errorf("%w: column %q appears twice", errs.ErrInvalidInput, name)
+		}
+		seen[name] = true
+		switch name {
+		case "id":
+			cols.id = i
+		case "email":
+			cols.email = i
+		case "name":
+			cols.name = i
+		case "created_at", "updated_at", "version":
+		default:
+			return cols, fmt.Errorf("%w: unknown column %q", errs.ErrInvalidInput, name)
+		}
+	}
+	if cols.email < 0 {
+		return cols, fmt.Errorf("%w: no email column", errs.ErrInvalidInput)
+	}
+	return cols, nil
+}
+
+func (c csvColumns) user(record []string) *User {
+	field := func(i int) string {
+		if i < 0 {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	return &User{ID: field(c.id), Email: field(c.email), Name: field(c.name)}
+}
+
+// ExportUsersCSV writes every live user to w as CSV in ID order, under a
+// header row of csvHeader. Users are read a page at a time with ListAll and
+// written as they come, nothing waits for the whole list. A failure part
+// way leaves w holding a truncated file, w is not rewound.
+//
+// Values are written as stored. A name beginning with =, +, -, or @ is a
+// formula to a spreadsheet that opens the file, which is the reader's to
+// defuse, altering it here would break the round trip through an import.
+func (u *UserService) ExportUsersCSV(ctx context.Context, w io.Writer) (err error) {
+	ctx, span := u.startSpan(ctx, "ExportUsersCSV")
+	defer func() { endSpan(span, err) }()
+	bw := bufpool.GetWriter(w)
+	defer bufpool.PutWriter(bw)
+	cw := csv.NewWriter(bw)
+	if err := cw.Write(csvHeader); err != nil {
+		return errs.Wrap("service.ExportUsersCSV", err)
+	}
+	record := make([]string, len(csvHeader))
+	n := 0
+	for user, err := range u.ListAll(ctx) {
+		if err != nil {
+			return errs.Wrap("service.ExportUsersCSV", err)
+		}
+		record[0], record[1], record[2] = user.ID, user.Email, user.Name
+		record[3] = user.CreatedAt.UTC().Format(time.RFC3339Nano)
+		record[4] = user.UpdatedAt.UTC().Format(time.RFC3339Nano)
+		record[5] = strconv.FormatInt(user.Version, 10)
+		if err := cw.Write(record); err != nil {
+			return errs.Wrap("service.ExportUsersCSV", err)
+		}
+		n++
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return errs.Wrap("service.ExportUsersCSV", err)
+	}
+	span.SetAttributes(attribute.Int("export.rows", n))
+	return nil
+}
@@ -0,0 +1,95 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/sqlite"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// stores returns the memory store and a sqlite store on a temporary file,
+// both with a unique index on email.
+func stores(t *testing.T) map[string]service.UserStorer {
+	t.Helper()
+	lite, err := sqlite.Open(context.Background(), filepath.Join(t.TempDir(), "users.db"))
+	if err != nil {
+		t.Fatalf("sqlite.Open: %v", err)
+	}
+	t.Cleanup(func() { lite.Close() })
+	return map[string]service.UserStorer{"memory": db.NewMemoryStore(), "sqlite": lite}
+}
+
+func TestRetrieveUserByEmail(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			users := service.NewUserService(store)
+			if err := users.CreateUser(ctx, &service.User{ID: "ada", Email: "ada@example.com"}); err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+			got, err := users.RetrieveUserByEmail(ctx, "ada@example.com")
+			if err != nil || got.ID != "ada" {
+				t.Fatalf("RetrieveUserByEmail = %+v, %v, want ada", got, err)
+			}
+			if _, err := users.RetrieveUserByEmail(ctx, "nobody@example.com"); !errors.Is(err, errs.ErrNotFound) {
+				t.Errorf("missing email: err = %v, want ErrNotFound", err)
+			}
+			if err := users.CreateUser(ctx, &service.User{ID: "ada2", Email: "ada@example.com"}); !errors.Is(err, errs.ErrConflict) {
+				t.Errorf("duplicate email: err = %v, want ErrConflict", err)
+			}
+			// an update cannot take someone else's email either
+			if err := users.CreateUser(ctx, &service.User{ID: "bob", Email: "bob@example.com"}); err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+			bob, _ := users.RetrieveUser(ctx, "bob")
+			bob.Email = "ada@example.com"
+			if err := users.UpdateUser(ctx, bob); !errors.Is(err, errs.ErrConflict) {
+				t.Errorf("update onto a taken email: err = %v, want ErrConflict", err)
+			}
+		})
+	}
+}
+
+// TestConcurrentCreatesSameEmail races creates of different users with one
+// email: the store's index lets exactly one through.
+func TestConcurrentCreatesSameEmail(t *testing.T) {
+	const n = 20
+	ctx := context.Background()
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			users := service.NewUserService(store)
+			var (
+				wg      sync.WaitGroup
+				start   = make(chan struct{})
+				results = make([]error, n)
+			)
+			for i := range n {
+				wg.Go(func() {
+					<-start
+					results[i] = users.CreateUser(ctx, &service.User{ID: fmt.Sprintf("user-%d", i), Email: "same@example.com"})
+				})
+			}
+			close(start)
+			wg.Wait()
+			created := 0
+			for _, err := range results {
+				switch {
+				case err == nil:
+					created++
+				case !errors.Is(err, errs.ErrConflict):
+					t.Errorf("a losing create: err = %v, want ErrConflict", err)
+				}
+			}
+			if created != 1 {
+				t.Errorf("%d creates succeeded, want 1", created)
+			}
+		})
+	}
+}
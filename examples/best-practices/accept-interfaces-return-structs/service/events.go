@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/events"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/outbox"
+)
+
+// OutboxAppender is implemented by stores that can record a message in the
+// same transaction as the change that caused it, see WithOutbox.
+type OutboxAppender interface {
+	AppendOutbox(ctx context.Context, msg outbox.Message) error
+}
+
+// WithPublisher publishes UserCreated, UserUpdated, and UserDeleted events
+// after each successful mutation. Publishing is wired through the After
+// hooks, so a publish failure is reported like any other hook error: the
+// change is already stored and the caller gets the error back.
+func WithPublisher(pub events.Publisher) Option {
+	return func(u *UserService) {
+		u.hooks.add(afterCreate, func(ctx context.Context, user *User) error {
+			return pub.Publish(ctx, userCreated(ctx, user))
+		})
+		u.hooks.add(afterUpdate, func(ctx context.Context, user *User) error {
+			return pub.Publish(ctx, userUpdated(ctx, user))
+		})
+		u.hooks.add(afterDelete, func(ctx context.Context, user *User) error {
+			return pub.Publish(ctx, userDeleted(ctx, user))
+		})
+	}
+}
+
+// WithOutbox writes every mutation's event to the store's outbox inside the
+// same transaction as the change, instead of publishing it directly. Either
+// both the user and its event are stored or neither is, an outbox.Relay then
+// publishes the events. The store must implement Transactor, and its
+// transaction scoped store must implement OutboxAppender.
+func WithOutbox() Option {
+	return func(u *UserService) {
+		u.outbox = true
+	}
+}
+
+// userCreated, userUpdated, and userDeleted are the events for a change to
+// user, in the tenant of ctx.
+func userCreated(ctx context.Context, user *User) events.Event {
+	return events.UserCreated{Tenant: tenant(ctx), UserID: user.ID, Email: user.Email, Version: user.Version, At: user.CreatedAt}
+}
+
+func userUpdated(ctx context.Context, user *User) events.Event {
+	return events.UserUpdated{Tenant: tenant(ctx), UserID: user.ID, Email: user.Email, Version: user.Version, At: user.UpdatedAt}
+}
+
+func userDeleted(ctx context.Context, user *User) events.Event {
+	return events.UserDeleted{Tenant: tenant(ctx), UserID: user.ID, At: user.DeletedAt}
+}
+
+// tenant is ctx's tenant, "" for the default one.
+func tenant(ctx context.Context) string {
+	id, _ := ctxutil.Tenant(ctx)
+	return id
+}
+
+// mutate runs write against the store. With WithOutbox it runs inside a
+// transaction and appends the event built for user before committing.
+func (u *UserService) mutate(ctx context.Context, user *User, event func(context.Context, *User) events.Event, write func(UserStorer) error) error {
+	if !u.outbox {
+		return write(u.store)
+	}
+	tx, ok := u.store.(Transactor)
+	if !ok {
+		return errs.Wrap("service.outbox", errors.ErrUnsupported)
+	}
+	return tx.WithinTx(ctx, func(store UserStorer) error {
+		if err := write(store); err != nil {
+			return err
+		}
+		return u.appendOutbox(ctx, store, event(ctx, user))
+	})
+}
+
+// appendOutbox is a no-op unless WithOutbox is set.
+func (u *UserService) appendOutbox(ctx context.Context, store UserStorer, event events.Event) error {
+	if !u.outbox {
+		return nil
+	}
+	appender, ok := store.(OutboxAppender)
+	if !ok {
+		return errs.Wrap("service.outbox", errors.ErrUnsupported)
+	}
+	msg, err := outbox.NewMessage(event, u.clock.Now())
+	if err != nil {
+		return err
+	}
+	return appender.AppendOutbox(ctx, msg)
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/validate"
+)
+
+// FlagStrictValidation turns on the stricter v2 rules for writes: on top of
+// the configured Validator a user must have a Name. Rolled out behind a flag
+// so existing clients that never send one can be moved over gradually.
+const FlagStrictValidation = "users.strict-validation"
+
+// Flags reports whether a feature is on for the request in ctx. The
+// featureflag providers satisfy it.
+type Flags interface {
+	Enabled(ctx context.Context, key string) bool
+}
+
+type noFlags struct{}
+
+func (noFlags) Enabled(context.Context, string) bool { return false }
+
+// WithFlags gates the service's new code paths, by default all are off.
+func WithFlags(flags Flags) Option {
+	return func(u *UserService) {
+		u.flags = flags
+	}
+}
+
+// validate runs the configured Validator and, when the flag is on for this
+// request, the strict rules.
+func (u *UserService) validate(ctx context.Context, user *User) error {
+	if err := u.validator.Validate(user); err != nil {
+		return err
+	}
+	if user == nil || !u.flags.Enabled(ctx, FlagStrictValidation) {
+		return nil
+	}
+	var v validate.Errors
+	v.Required("Name", user.Name)
+	return v.Err()
+}
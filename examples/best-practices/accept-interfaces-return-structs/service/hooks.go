@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Hook is called around a user mutation with the user being written.
+//
+// Ordering and error semantics, for every event:
+//   - hooks run synchronously, in the order they were registered
+//   - a Before hook returning an error aborts the mutation, later hooks for
+//     that event do not run and the error is returned to the caller
+//   - After hooks run once the change is stored, all of them run even if one
+//     fails and their errors are joined. The mutation is not undone, the
+//     caller gets the joined error alongside a successful write
+//
+// Hooks must not call back into the same UserService mutation they observe.
+type Hook func(ctx context.Context, user *User) error
+
+type hookEvent int
+
+const (
+	beforeCreate hookEvent = iota
+	afterCreate
+	beforeUpdate
+	afterUpdate
+	beforeDelete
+	afterDelete
+	numHookEvents
+)
+
+type hooks struct {
+	mu    sync.RWMutex
+	hooks [numHookEvents][]Hook
+}
+
+func (h *hooks) add(event hookEvent, hook Hook) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hooks[event] = append(h.hooks[event], hook)
+}
+
+func (h *hooks) list(event hookEvent) []Hook {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.hooks[event]
+}
+
+// runBefore stops at the first error.
+func (h *hooks) runBefore(ctx context.Context, event hookEvent, user *User) error {
+	for _, hook := range h.list(event) {
+		if err := hook(ctx, user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runAfter runs every hook and joins their errors.
+func (h *hooks) runAfter(ctx context.Context, event hookEvent, user *User) error {
+	var hookErrs []error
+	for _, hook := range h.list(event) {
+		if err := hook(ctx, user); err != nil {
+			hookErrs = append(hookErrs, err)
+		}
+	}
+	return errors.Join(hookErrs...)
+}
+
+// OnBeforeUserCreated registers a hook run after validation and before the
+// insert, CreateUsers runs it per item and reports failures as item errors.
+func (u *UserService) OnBeforeUserCreated(hook Hook) {
+	u.hooks.add(beforeCreate, hook)
+}
+
+// OnUserCreated registers a hook run after a user is inserted.
+func (u *UserService) OnUserCreated(hook Hook) {
+	u.hooks.add(afterCreate, hook)
+}
+
+// OnBeforeUserUpdated registers a hook run before UpdateUser or RestoreUser writes.
+func (u *UserService) OnBeforeUserUpdated(hook Hook) {
+	u.hooks.add(beforeUpdate, hook)
+}
+
+// OnUserUpdated registers a hook run after UpdateUser or RestoreUser writes.
+func (u *UserService) OnUserUpdated(hook Hook) {
+	u.hooks.add(afterUpdate, hook)
+}
+
+// OnBeforeUserDeleted registers a hook run before DeleteUser marks a user
+// deleted. PurgeUser is an administrative operation and runs no hooks.
+func (u *UserService) OnBeforeUserDeleted(hook Hook) {
+	u.hooks.add(beforeDelete, hook)
+}
+
+// OnUserDeleted registers a hook run after DeleteUser marks a user deleted.
+func (u *UserService) OnUserDeleted(hook Hook) {
+	u.hooks.add(afterDelete, hook)
+}
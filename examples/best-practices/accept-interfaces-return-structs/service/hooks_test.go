@@ -0,0 +1,106 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// recorder returns a hook appending name to calls, failing with err.
+func recorder(calls *[]string, name string, err error) service.Hook {
+	return func(context.Context, *service.User) error {
+		*calls = append(*calls, name)
+		return err
+	}
+}
+
+func TestHookOrder(t *testing.T) {
+	ctx := context.Background()
+	var calls []string
+	users := service.NewUserService(db.NewMemoryStore())
+	for _, on := range []struct {
+		register func(service.Hook)
+		name     string
+	}{
+		{users.OnBeforeUserCreated, "before create"},
+		{users.OnUserCreated, "after create"},
+		{users.OnBeforeUserUpdated, "before update"},
+		{users.OnUserUpdated, "after update"},
+		{users.OnBeforeUserDeleted, "before delete"},
+		{users.OnUserDeleted, "after delete"},
+	} {
+		on.register(recorder(&calls, on.name+" 1", nil))
+		on.register(recorder(&calls, on.name+" 2", nil))
+	}
+
+	if err := users.CreateUser(ctx, &service.User{ID: "ada", Email: "ada@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	ada, _ := users.RetrieveUser(ctx, "ada")
+	ada.Name = "Ada"
+	if err := users.UpdateUser(ctx, ada); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if err := users.DeleteUser(ctx, "ada"); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	want := []string{
+		"before create 1", "before create 2", "after create 1", "after create 2",
+		"before update 1", "before update 2", "after update 1", "after update 2",
+		"before delete 1", "before delete 2", "after delete 1", "after delete 2",
+	}
+	if !slices.Equal(calls, want) {
+		t.Errorf("hooks ran\n%q\nwant\n%q", calls, want)
+	}
+}
+
+// TestBeforeHookAborts checks a failing before hook stops the later hooks
+// and the write, and its error reaches the caller.
+func TestBeforeHookAborts(t *testing.T) {
+	ctx := context.Background()
+	veto := errors.New("veto")
+	var calls []string
+	users := service.NewUserService(db.NewMemoryStore())
+	users.OnBeforeUserCreated(recorder(&calls, "first", nil))
+	users.OnBeforeUserCreated(recorder(&calls, "veto", veto))
+	users.OnBeforeUserCreated(recorder(&calls, "never", nil))
+	users.OnUserCreated(recorder(&calls, "after", nil))
+
+	if err := users.CreateUser(ctx, &service.User{ID: "ada", Email: "ada@example.com"}); !errors.Is(err, veto) {
+		t.Fatalf("CreateUser: err = %v, want the hook's error", err)
+	}
+	if want := []string{"first", "veto"}; !slices.Equal(calls, want) {
+		t.Errorf("hooks ran %q, want %q", calls, want)
+	}
+	if _, err := users.RetrieveUser(ctx, "ada"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("RetrieveUser: err = %v, want the vetoed user not stored", err)
+	}
+}
+
+// TestAfterHookErrorsJoin checks every after hook runs when some fail, the
+// caller gets all their errors, and the write stands.
+func TestAfterHookErrorsJoin(t *testing.T) {
+	ctx := context.Background()
+	first, second := errors.New("first"), errors.New("second")
+	var calls []string
+	users := service.NewUserService(db.NewMemoryStore())
+	users.OnUserCreated(recorder(&calls, "first", first))
+	users.OnUserCreated(recorder(&calls, "ok", nil))
+	users.OnUserCreated(recorder(&calls, "second", second))
+
+	err := users.CreateUser(ctx, &service.User{ID: "ada", Email: "ada@example.com"})
+	if !errors.Is(err, first) || !errors.Is(err, second) {
+		t.Fatalf("CreateUser: err = %v, want both hook errors", err)
+	}
+	if want := []string{"first", "ok", "second"}; !slices.Equal(calls, want) {
+		t.Errorf("hooks ran %q, want %q", calls, want)
+	}
+	if _, err := users.RetrieveUser(ctx, "ada"); err != nil {
+		t.Errorf("RetrieveUser: %v, want the user stored despite the hooks", err)
+	}
+}
@@ -0,0 +1,50 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/idgen"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+func TestCreateUserAssignsIDs(t *testing.T) {
+	ctx := context.Background()
+	users := service.NewUserService(db.NewMemoryStore(), service.WithIDGenerator(&idgen.Sequence{Prefix: "user-"}))
+
+	ada := &service.User{Email: "ada@example.com"}
+	if err := users.CreateUser(ctx, ada); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if ada.ID != "user-1" {
+		t.Errorf("assigned ID = %q, want user-1", ada.ID)
+	}
+	// an ID the caller chose is kept and does not use up a generated one
+	bob := &service.User{ID: "bob", Email: "bob@example.com"}
+	if err := users.CreateUser(ctx, bob); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if bob.ID != "bob" {
+		t.Errorf("ID = %q, want the caller's bob", bob.ID)
+	}
+	grace := &service.User{Email: "grace@example.com"}
+	if err := users.CreateUser(ctx, grace); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if grace.ID != "user-2" {
+		t.Errorf("assigned ID = %q, want user-2", grace.ID)
+	}
+	if got, err := users.RetrieveUser(ctx, "user-2"); err != nil || got.Email != "grace@example.com" {
+		t.Errorf("RetrieveUser(user-2) = %+v, %v, want grace", got, err)
+	}
+}
+
+func TestCreateUserWithoutGenerator(t *testing.T) {
+	users := service.NewUserService(db.NewMemoryStore())
+	if err := users.CreateUser(context.Background(), &service.User{Email: "ada@example.com"}); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Errorf("CreateUser without an ID: err = %v, want ErrInvalidInput", err)
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"iter"
+)
+
+// ListAll yields every user ordered by ID, fetching maxPageSize at a time
+// through ListUsers only as the loop asks for more:
+//
+//	for user, err := range users.ListAll(ctx) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// Breaking out of the loop stops the paging, no page past the one being
+// read is fetched. A failure is yielded once with a nil user and ends the
+// sequence. Each range over the result starts again from the first page.
+func (u *UserService) ListAll(ctx context.Context, opts ...ReadOption) iter.Seq2[*User, error] {
+	return func(yield func(*User, error) bool) {
+		req := PageRequest{Limit: maxPageSize}
+		for {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			page, err := u.ListUsers(ctx, req, opts...)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for i := range page.Items {
+				if !yield(&page.Items[i], nil) {
+					return
+				}
+			}
+			if page.NextCursor == "" {
+				return
+			}
+			req.Cursor = page.NextCursor
+		}
+	}
+}
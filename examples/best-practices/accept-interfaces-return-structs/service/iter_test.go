@@ -0,0 +1,100 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/query"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/seq"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// pagingStore counts the pages fetched and fails them once failAfter
+// pages have been handed out.
+type pagingStore struct {
+	*db.MemoryStore
+	pages     atomic.Int64
+	failAfter int64
+}
+
+func (s *pagingStore) Query(ctx context.Context, q query.Query) ([]*service.User, error) {
+	if n := s.pages.Add(1); s.failAfter > 0 && n > s.failAfter {
+		return nil, errors.New("connection reset")
+	}
+	return s.MemoryStore.Query(ctx, q)
+}
+
+// paged returns a service over a store with n users, 100 to a page.
+func paged(t *testing.T, n int) (*service.UserService, *pagingStore) {
+	t.Helper()
+	store := &pagingStore{MemoryStore: db.NewMemoryStore()}
+	users := service.NewUserService(store)
+	for i := range n {
+		if err := users.CreateUser(context.Background(), &service.User{ID: fmt.Sprintf("u%03d", i), Email: fmt.Sprintf("u%03d@example.com", i)}); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+	store.pages.Store(0)
+	return users, store
+}
+
+func TestListAll(t *testing.T) {
+	ctx := context.Background()
+	users, store := paged(t, 250)
+	all, err := seq.Collect2(users.ListAll(ctx))
+	if err != nil || len(all) != 250 {
+		t.Fatalf("ListAll = %d users, %v, want 250", len(all), err)
+	}
+	if got := store.pages.Swap(0); got != 3 {
+		t.Errorf("ranging over 250 users fetched %d pages, want 3", got)
+	}
+
+	// breaking stops the paging at the page being read
+	for user, err := range users.ListAll(ctx) {
+		if err != nil {
+			t.Fatalf("ListAll: %v", err)
+		}
+		if user.ID == "u142" {
+			break
+		}
+	}
+	if got := store.pages.Swap(0); got != 2 {
+		t.Errorf("breaking on the second page fetched %d pages, want 2", got)
+	}
+
+	// so does Take, before any page is needed nothing is fetched
+	first := seq.Take2(users.ListAll(ctx), 10)
+	if got := store.pages.Load(); got != 0 {
+		t.Errorf("building the sequence fetched %d pages, want 0", got)
+	}
+	got, _ := seq.Collect2(first)
+	if len(got) != 10 || store.pages.Load() != 1 {
+		t.Errorf("Take2(10) = %d users over %d pages, want 10 over 1", len(got), store.pages.Load())
+	}
+}
+
+func TestListAllErrors(t *testing.T) {
+	users, store := paged(t, 250)
+	store.failAfter = 1
+	got, err := seq.Collect2(users.ListAll(context.Background()))
+	if err == nil || len(got) != 100 {
+		t.Errorf("a failing second page: %d users, err = %v, want 100 and an error", len(got), err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	yielded := 0
+	for _, err := range users.ListAll(ctx) {
+		yielded++
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("a canceled context: err = %v, want context.Canceled", err)
+		}
+	}
+	if yielded != 1 {
+		t.Errorf("a canceled context yielded %d times, want once", yielded)
+	}
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/audit"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/fsm"
+)
+
+// Status is a user's lifecycle state:
+//
+//	pending ──verify──> verified ──activate──> active
+//	   │                   │                     │
+//	   └───────────────────┴──────deactivate─────┴──> deactivated
+//
+// The empty Status is a user stored before lifecycles existed, they were
+// all usable accounts and count as StatusActive.
+type Status string
+
+const (
+	StatusPending     Status = "pending"
+	StatusVerified    Status = "verified"
+	StatusActive      Status = "active"
+	StatusDeactivated Status = "deactivated"
+)
+
+// LifecycleEvent moves a user from one Status to the next.
+type LifecycleEvent string
+
+const (
+	EventVerify     LifecycleEvent = "verify"
+	EventActivate   LifecycleEvent = "activate"
+	EventDeactivate LifecycleEvent = "deactivate"
+)
+
+func newLifecycle(u *UserService) *fsm.Machine[Status, LifecycleEvent, *User] {
+	m := fsm.New[Status, LifecycleEvent, *User]()
+	m.Allow(StatusPending, EventVerify, StatusVerified)
+	m.Allow(StatusVerified, EventActivate, StatusActive, u.hasPassword)
+	for _, from := range []Status{StatusPending, StatusVerified, StatusActive} {
+		m.Allow(from, EventDeactivate, StatusDeactivated)
+	}
+	for _, state := range []Status{StatusVerified, StatusActive, StatusDeactivated} {
+		m.OnEnter(state, func(ctx context.Context, t fsm.Transition[Status, LifecycleEvent], user *User) error {
+			u.log(ctx).DebugContext(ctx, "user "+string(t.To),
+				slog.String("user_id", user.ID),
+				slog.String("from", string(t.From)),
+			)
+			return nil
+		})
+	}
+	return m
+}
+
+// hasPassword guards activation when WithPasswords is set: an account no
+// one can log in to is not active.
+func (u *UserService) hasPassword(ctx context.Context, user *User) error {
+	if u.passwords == nil {
+		return nil
+	}
+	_, err := u.passwords.Hash(ctx, user.ID)
+	if errors.Is(err, errs.ErrNotFound) {
+		return fmt.Errorf("user %s has no password: %w", user.ID, errs.ErrConflict)
+	}
+	return err
+}
+
+// VerifyUser marks a pending user's email as verified.
+func (u *UserService) VerifyUser(ctx context.Context, id string) error {
+	return u.transition(ctx, "VerifyUser", id, EventVerify)
+}
+
+// ActivateUser makes a verified user active. With WithPasswords the user
+// must have a password first.
+func (u *UserService) ActivateUser(ctx context.Context, id string) error {
+	return u.transition(ctx, "ActivateUser", id, EventActivate)
+}
+
+// DeactivateUser ends a user's lifecycle from any other status. Unlike
+// DeleteUser the user stays visible, deactivated.
+func (u *UserService) DeactivateUser(ctx context.Context, id string) error {
+	return u.transition(ctx, "DeactivateUser", id, EventDeactivate)
+}
+
+// transition fires event on the live user id and stores the Status it leads
+// to. An event the user's Status does not allow, or whose guard refuses,
+// fails with an *fsm.TransitionError matching errs.ErrConflict and changes
+// nothing.
+func (u *UserService) transition(ctx context.Context, name, id string, event LifecycleEvent) (err error) {
+	op := "service." + name
+	ctx, span := u.startSpan(ctx, name, attribute.String("user.id", id))
+	defer func() { endSpan(span, err) }()
+	if id == "" {
+		return errs.Wrap(op, errs.ErrInvalidInput)
+	}
+	before, user, err := u.modify(ctx, id, userUpdated, func(user *User) error {
+		if user.Deleted() {
+			return errs.ErrNotFound
+		}
+		from := user.Status
+		if from == "" {
+			from = StatusActive
+		}
+		to, err := u.lifecycle.Fire(ctx, from, event, user)
+		if err != nil {
+			return err
+		}
+		user.Status = to
+		return u.hooks.runBefore(ctx, beforeUpdate, user)
+	})
+	if err != nil {
+		return errs.Wrap(op, err)
+	}
+	return errs.Wrap(op, errors.Join(
+		u.record(ctx, audit.Updated, before, user),
+		u.hooks.runAfter(ctx, afterUpdate, user),
+	))
+}
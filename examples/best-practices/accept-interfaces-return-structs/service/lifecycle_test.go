@@ -0,0 +1,170 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/audit"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/credentials"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/events"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/fsm"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// created returns a service on store with a pending user ada.
+func created(t *testing.T, store service.UserStorer, opts ...service.Option) *service.UserService {
+	t.Helper()
+	users := service.NewUserService(store, opts...)
+	if err := users.CreateUser(context.Background(), &service.User{ID: "ada", Email: "ada@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return users
+}
+
+// statusOf returns id's stored status.
+func statusOf(t *testing.T, users *service.UserService, id string) service.Status {
+	t.Helper()
+	user, err := users.RetrieveUser(context.Background(), id)
+	if err != nil {
+		t.Fatalf("RetrieveUser: %v", err)
+	}
+	return user.Status
+}
+
+func TestLifecycle(t *testing.T) {
+	ctx := context.Background()
+	users := created(t, db.NewMemoryStore())
+	got := []service.Status{statusOf(t, users, "ada")}
+	for _, step := range []func(context.Context, string) error{users.VerifyUser, users.ActivateUser, users.DeactivateUser} {
+		if err := step(ctx, "ada"); err != nil {
+			t.Fatalf("step from %s: %v", got[len(got)-1], err)
+		}
+		got = append(got, statusOf(t, users, "ada"))
+	}
+	want := []service.Status{service.StatusPending, service.StatusVerified, service.StatusActive, service.StatusDeactivated}
+	if !slices.Equal(got, want) {
+		t.Errorf("went through %v, want %v", got, want)
+	}
+	if err := users.VerifyUser(ctx, "ada"); !errors.Is(err, fsm.ErrIllegalTransition) {
+		t.Errorf("VerifyUser deactivated: err = %v, want ErrIllegalTransition", err)
+	}
+}
+
+// TestLifecycleIllegalStoresNothing activates a pending user.
+func TestLifecycleIllegalStoresNothing(t *testing.T) {
+	users := created(t, db.NewMemoryStore())
+	err := users.ActivateUser(context.Background(), "ada")
+	var terr *fsm.TransitionError
+	if !errors.As(err, &terr) || !errors.Is(err, fsm.ErrIllegalTransition) || !errors.Is(err, errs.ErrConflict) {
+		t.Fatalf("ActivateUser: err = %v, want an illegal transition", err)
+	}
+	if terr.From != service.StatusPending || terr.Event != service.EventActivate {
+		t.Errorf("error names %v in %v, want activate in pending", terr.Event, terr.From)
+	}
+	user, err := users.RetrieveUser(context.Background(), "ada")
+	if err != nil {
+		t.Fatalf("RetrieveUser: %v", err)
+	}
+	if user.Status != service.StatusPending || user.Version != 1 {
+		t.Errorf("stored %s at version %d, want pending at 1", user.Status, user.Version)
+	}
+}
+
+func TestLifecycleUpdateUserKeepsStatus(t *testing.T) {
+	ctx := context.Background()
+	users := created(t, db.NewMemoryStore())
+	user, err := users.RetrieveUser(ctx, "ada")
+	if err != nil {
+		t.Fatalf("RetrieveUser: %v", err)
+	}
+	user.Name = "Ada"
+	user.Status = service.StatusActive
+	if err := users.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if got := statusOf(t, users, "ada"); got != service.StatusPending {
+		t.Errorf("status %s after UpdateUser, want pending", got)
+	}
+}
+
+// TestLifecyclePublishedAndAudited checks a transition is an update like
+// any other.
+func TestLifecyclePublishedAndAudited(t *testing.T) {
+	ctx := context.Background()
+	bus := events.NewBus()
+	trail := audit.NewMemory()
+	users := created(t, db.NewMemoryStore(), service.WithPublisher(bus), service.WithAuditSink(trail))
+	var published []events.Event
+	bus.Subscribe(func(ctx context.Context, event events.Event) error {
+		published = append(published, event)
+		return nil
+	})
+	if err := users.VerifyUser(ctx, "ada"); err != nil {
+		t.Fatalf("VerifyUser: %v", err)
+	}
+	if len(published) != 1 || published[0].Name() != events.NameUserUpdated {
+		t.Errorf("published %v, want one %s", published, events.NameUserUpdated)
+	}
+	entries, err := trail.ByUser(ctx, "ada")
+	if err != nil {
+		t.Fatalf("ByUser: %v", err)
+	}
+	last := entries[len(entries)-1]
+	if want := (audit.Change{Field: "status", From: "pending", To: "verified"}); !slices.Contains(last.Changes, want) {
+		t.Errorf("last entry changed %v, want %v", last.Changes, want)
+	}
+}
+
+// TestLifecycleActivateNeedsPassword checks the guard: with passwords kept, a user
+// cannot be activated until they have one.
+func TestLifecycleActivateNeedsPassword(t *testing.T) {
+	ctx := context.Background()
+	users := created(t, db.NewMemoryStore(), service.WithPasswords(credentials.NewMemory(), credentials.Bcrypt{Cost: 4}))
+	if err := users.VerifyUser(ctx, "ada"); err != nil {
+		t.Fatalf("VerifyUser: %v", err)
+	}
+	if err := users.ActivateUser(ctx, "ada"); !errors.Is(err, errs.ErrConflict) || errors.Is(err, fsm.ErrIllegalTransition) {
+		t.Errorf("ActivateUser without a password: err = %v, want the guard's ErrConflict", err)
+	}
+	if err := users.SetPassword(ctx, "ada", "correct horse battery"); err != nil {
+		t.Fatalf("SetPassword: %v", err)
+	}
+	if err := users.ActivateUser(ctx, "ada"); err != nil {
+		t.Errorf("ActivateUser with a password: %v", err)
+	}
+}
+
+// TestLifecycleStoredBefore checks a user with no status counts as
+// active.
+func TestLifecycleStoredBefore(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemoryStore()
+	if err := store.Insert(ctx, &service.User{ID: "old", Email: "old@example.com", Version: 1}); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	users := service.NewUserService(store)
+	if err := users.VerifyUser(ctx, "old"); !errors.Is(err, fsm.ErrIllegalTransition) {
+		t.Errorf("VerifyUser: err = %v, want ErrIllegalTransition", err)
+	}
+	if err := users.DeactivateUser(ctx, "old"); err != nil {
+		t.Fatalf("DeactivateUser: %v", err)
+	}
+	if got := statusOf(t, users, "old"); got != service.StatusDeactivated {
+		t.Errorf("status %s, want deactivated", got)
+	}
+}
+
+func TestLifecycleDeleted(t *testing.T) {
+	ctx := context.Background()
+	users := created(t, db.NewMemoryStore())
+	if err := users.DeleteUser(ctx, "ada"); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if err := users.DeactivateUser(ctx, "ada"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("DeactivateUser: err = %v, want ErrNotFound", err)
+	}
+}
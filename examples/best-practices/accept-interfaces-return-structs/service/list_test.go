@@ -0,0 +1,120 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/cursor"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// seeded returns a service over a memory store holding users u000..u(n-1).
+func seeded(t *testing.T, n int) *service.UserService {
+	t.Helper()
+	users := service.NewUserService(db.NewMemoryStore())
+	for i := range n {
+		if err := users.CreateUser(context.Background(), &service.User{ID: fmt.Sprintf("u%03d", i), Email: fmt.Sprintf("u%03d@example.com", i)}); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+	return users
+}
+
+// ids lists every page, limit users at a time, calling between
+// after each page.
+func ids(t *testing.T, users *service.UserService, limit int, between func(page int)) []string {
+	t.Helper()
+	var got []string
+	req := service.PageRequest{Limit: limit}
+	for page := 0; ; page++ {
+		p, err := users.ListUsers(context.Background(), req)
+		if err != nil {
+			t.Fatalf("ListUsers: %v", err)
+		}
+		if len(p.Items) > limit {
+			t.Fatalf("page %d has %d users, limit %d", page, len(p.Items), limit)
+		}
+		for _, u := range p.Items {
+			got = append(got, u.ID)
+		}
+		if p.NextCursor == "" {
+			return got
+		}
+		if between != nil {
+			between(page)
+		}
+		req.Cursor = p.NextCursor
+	}
+}
+
+func TestListUsersAllPages(t *testing.T) {
+	users := seeded(t, 25)
+	got := ids(t, users, 10, nil)
+	if len(got) != 25 || !slices.IsSorted(got) {
+		t.Fatalf("listed %v, want u000..u024 in order", got)
+	}
+	// a full last page still says it is the last
+	if got := ids(t, seeded(t, 20), 10, nil); len(got) != 20 {
+		t.Fatalf("listed %d users in pages of 10, want 20", len(got))
+	}
+}
+
+// TestListUsersCursorStability changes the store between pages: users
+// added before the cursor or removed behind it never make a later page
+// skip or repeat anyone.
+func TestListUsersCursorStability(t *testing.T) {
+	ctx := context.Background()
+	users := seeded(t, 30)
+	got := ids(t, users, 10, func(page int) {
+		// sorts before every ID listed so far, an offset would shift by one
+		if err := users.CreateUser(ctx, &service.User{ID: fmt.Sprintf("a%d", page), Email: fmt.Sprintf("a%d@example.com", page)}); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if err := users.DeleteUser(ctx, fmt.Sprintf("u%03d", page)); err != nil {
+			t.Fatalf("DeleteUser: %v", err)
+		}
+	})
+	want := make([]string, 0, 30)
+	for i := range 30 {
+		want = append(want, fmt.Sprintf("u%03d", i))
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("listed %v, want %v", got, want)
+	}
+}
+
+func TestListUsersRequests(t *testing.T) {
+	users := seeded(t, 150)
+	ctx := context.Background()
+	for _, tt := range []struct {
+		name  string
+		req   service.PageRequest
+		items int
+		err   error
+	}{
+		{"default limit", service.PageRequest{}, 20, nil},
+		{"capped limit", service.PageRequest{Limit: 1000}, 100, nil},
+		{"cursor from the middle", service.PageRequest{Cursor: cursor.Encode("u139")}, 10, nil},
+		{"not a cursor", service.PageRequest{Cursor: "!!!"}, 0, errs.ErrInvalidInput},
+		{"not this version's cursor", service.PageRequest{Cursor: "djI6dTAw"}, 0, errs.ErrInvalidInput},
+		{"matching everyone", service.PageRequest{EmailLike: "%"}, 20, nil},
+		{"unprintable pattern", service.PageRequest{EmailLike: "%\x00%"}, 0, errs.ErrInvalidInput},
+		{"pattern too long", service.PageRequest{EmailLike: strings.Repeat("%", 255)}, 0, errs.ErrInvalidInput},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := users.ListUsers(ctx, tt.req)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if len(page.Items) != tt.items {
+				t.Errorf("%d items, want %d", len(page.Items), tt.items)
+			}
+		})
+	}
+}
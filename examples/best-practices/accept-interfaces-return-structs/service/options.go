@@ -0,0 +1,102 @@
+package service
+
+import (
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/budget"
+)
+
+// Option configures a UserService. Options keep NewUserService backward
+// compatible: the store stays the one required argument and every new
+// dependency gets a sensible default instead of a new parameter.
+type Option func(*UserService)
+
+// Validator checks a user before it is written.
+type Validator interface {
+	Validate(*User) error
+}
+
+// ValidatorFunc adapts a plain function to a Validator.
+type ValidatorFunc func(*User) error
+
+func (f ValidatorFunc) Validate(user *User) error {
+	return f(user)
+}
+
+// IDGenerator mints IDs for users created without one.
+type IDGenerator interface {
+	NewID() string
+}
+
+// Clock tells the service what time it is for CreatedAt and UpdatedAt.
+// clock.Real and *clock.Fake both satisfy it.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// WithLogger sets the logger for service events, the default discards them.
+func WithLogger(logger *slog.Logger) Option {
+	return func(u *UserService) {
+		u.logger = logger
+	}
+}
+
+// WithClock replaces the wall clock, pass a *clock.Fake for deterministic timestamps.
+func WithClock(clock Clock) Option {
+	return func(u *UserService) {
+		u.clock = clock
+	}
+}
+
+// CreateUserSteps is how CreateUser splits its context's deadline by
+// default: validation and the before hooks, the insert, then the audit
+// entry and the after hooks. The insert has twice the others' share, and
+// it and the after hooks are set 250ms and 100ms aside while the steps
+// before them run, so a before hook calling a slow API cannot leave the
+// insert no time.
+func CreateUserSteps() []budget.Step {
+	return []budget.Step{
+		{Name: "validate", Weight: 1},
+		{Name: "store", Weight: 2, Min: 250 * time.Millisecond},
+		{Name: "publish", Weight: 1, Min: 100 * time.Millisecond},
+	}
+}
+
+// WithCreateBudget replaces the plan CreateUser splits its deadline by,
+// which must have the steps of CreateUserSteps. Build it on the clock the
+// caller's deadlines are on, a *clock.Fake's in a test, with
+// budget.WithClock. A nil plan leaves every step the whole deadline.
+func WithCreateBudget(p *budget.Plan) Option {
+	return func(u *UserService) {
+		u.createBudget = p
+	}
+}
+
+// WithIDGenerator lets CreateUser assign IDs to users that arrive without
+// one. Without a generator an empty ID is a validation error.
+func WithIDGenerator(gen IDGenerator) Option {
+	return func(u *UserService) {
+		u.ids = gen
+	}
+}
+
+// WithValidator replaces the default (*User).Validate rules.
+func WithValidator(v Validator) Option {
+	return func(u *UserService) {
+		u.validator = v
+	}
+}
+
+func defaultValidator() Validator {
+	return ValidatorFunc((*User).Validate)
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
@@ -1,40 +1,747 @@
 package service
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/audit"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/budget"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/credentials"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/cursor"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/events"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/fsm"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/multierr"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/query"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/validate"
+)
+
+// UserStorer persists users. Update is a compare-and-swap on Version: it
+// must fail with errs.ErrVersionConflict when user.Version differs from the
+// stored version, and on success store and set user.Version to the next value.
 type UserStorer interface {
-	Insert(item interface{}) error
-	Get(id string, val interface{}) error
+	Insert(ctx context.Context, user *User) error
+	Get(ctx context.Context, id string) (*User, error)
+	Update(ctx context.Context, user *User) error
+	Delete(ctx context.Context, id string) error
+}
+
+// Transactor is implemented by stores that can run several operations as a
+// single atomic unit. fn receives a UserStorer bound to the transaction,
+// returning an error from fn rolls every change back.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(UserStorer) error) error
+}
+
+// BatchInserter is implemented by stores with a native bulk insert. The
+// returned slice has one entry per user, nil where the insert succeeded.
+type BatchInserter interface {
+	InsertMany(ctx context.Context, users []*User) []error
+}
+
+// UserLister is implemented by stores that can page through users in ID
+// order. List returns at most limit users whose ID sorts after the given one.
+type UserLister interface {
+	List(ctx context.Context, after string, limit int) ([]*User, error)
+}
+
+// UserQuerier is implemented by stores that run a query.Query themselves,
+// the SQL stores compile it to a statement. ListUsers pages through every
+// other UserLister and filters with query.Query.Match instead.
+type UserQuerier interface {
+	Query(ctx context.Context, q query.Query) ([]*User, error)
+}
+
+// UserFinder is implemented by stores with a unique index on email. Those
+// stores also reject inserts and updates that would duplicate an email with
+// errs.ErrConflict, enforcing it in the store keeps concurrent creates safe.
+type UserFinder interface {
+	GetByEmail(ctx context.Context, email string) (*User, error)
 }
 
+// The validate tags drive validate.Struct, the service itself uses the
+// hand-rolled Validate below. Both must agree on the rules.
 type User struct {
+	ID    string `validate:"required,max=64,printable"`
+	Email string `validate:"required,email,max=254"`
+	// Name is the display name, optional.
+	Name string `validate:"max=200,printable"`
+	// Status is where the user is in their lifecycle. It only moves through
+	// VerifyUser, ActivateUser, and DeactivateUser, CreateUser sets it to
+	// StatusPending and UpdateUser keeps the stored one.
+	Status    Status
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// Version starts at 1 and increments on every update. Callers send back
+	// the version they read, a stale version means someone else wrote first.
+	Version int64
+	// DeletedAt is set by DeleteUser and cleared by RestoreUser,
+	// the zero value means the user is live.
+	DeletedAt time.Time
+}
+
+// Deleted reports whether the user has been soft deleted.
+func (user *User) Deleted() bool {
+	return !user.DeletedAt.IsZero()
+}
+
+// Record returns the fields a query.Query filters and orders users on.
+func (user *User) Record() query.Record {
+	return query.Record{
+		ID:        user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		DeletedAt: user.DeletedAt,
+	}
+}
+
+const (
+	maxIDLen    = 64
+	maxEmailLen = 254
+	maxNameLen  = 200
+)
+
+// Validate checks user field by field with no reflection, the compiler
+// catches a renamed field here where a struct tag would fail silently.
+func (user *User) Validate() error {
+	if user == nil {
+		return &validate.ValidationError{Fields: []validate.FieldError{{Field: "User", Message: "is required"}}}
+	}
+	var v validate.Errors
+	v.Required("ID", user.ID)
+	v.MaxLen("ID", user.ID, maxIDLen)
+	v.Printable("ID", user.ID)
+	v.Required("Email", user.Email)
+	v.Email("Email", user.Email)
+	v.MaxLen("Email", user.Email, maxEmailLen)
+	v.MaxLen("Name", user.Name, maxNameLen)
+	v.Printable("Name", user.Name)
+	return v.Err()
+}
+
+// ItemError reports why a single user in a batch was rejected.
+type ItemError struct {
+	Index int
 	ID    string
-	Email string
+	Err   error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("item %d (id %q): %s", e.Index, e.ID, e.Err)
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// BatchResult splits a batch into the users that were stored and the
+// per-item errors for those that were not.
+type BatchResult struct {
+	Created []*User
+	Failed  []*ItemError
+}
+
+// Err is Failed as one error, nil when nothing failed. errors.As finds the
+// first *ItemError in it, or the first of any error a failure wraps, such
+// as a *validate.ValidationError, and errors.Is asks of them all, so
+// errors.Is(result.Err(), errs.ErrConflict) is whether any was a duplicate.
+func (r BatchResult) Err() error {
+	var failed multierr.Errors
+	for _, f := range r.Failed {
+		failed.Append(f)
+	}
+	return failed.Err()
+}
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// ReadOption tunes a read, see IncludeDeleted.
+type ReadOption func(*readOptions)
+
+type readOptions struct {
+	includeDeleted bool
+}
+
+// IncludeDeleted makes reads return soft deleted users, which are hidden by default.
+func IncludeDeleted() ReadOption {
+	return func(o *readOptions) {
+		o.includeDeleted = true
+	}
+}
+
+func readOpts(opts []ReadOption) readOptions {
+	var o readOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// visible reports whether user should be returned for a read with o.
+func (o readOptions) visible(user *User) bool {
+	return o.includeDeleted || !user.Deleted()
+}
+
+// PageRequest asks for the page after Cursor, the empty cursor is the first page.
+type PageRequest struct {
+	Cursor string
+	Limit  int
+	// EmailLike keeps only users whose email matches the SQL LIKE pattern,
+	// see query.Query.WhereEmailLike. Send the same pattern with every
+	// cursor of a listing.
+	EmailLike string
+}
+
+// Page holds one page of results. NextCursor is empty on the last page.
+type Page[T any] struct {
+	Items      []T
+	NextCursor string
 }
 
 type UserService struct {
-	store UserStorer
+	store     UserStorer
+	logger    *slog.Logger
+	clock     Clock
+	ids       IDGenerator
+	validator Validator
+	hooks     hooks
+	outbox    bool
+	audit     audit.Sink
+	passwords credentials.Store
+	hasher    credentials.Hasher
+	decoy     func() string
+	tracer    trace.Tracer
+	flags     Flags
+	lifecycle *fsm.Machine[Status, LifecycleEvent, *User]
+	// createBudget splits CreateUser's deadline, see CreateUserSteps
+	createBudget *budget.Plan
 }
 
-func NewUserService(s UserStorer) *UserService {
-	return &UserService{
-		store: s,
+func NewUserService(s UserStorer, opts ...Option) *UserService {
+	u := &UserService{
+		store:     s,
+		logger:    discardLogger(),
+		clock:     systemClock{},
+		validator: defaultValidator(),
+		tracer:    defaultTracer(),
+		flags:     noFlags{},
+		// a deadline from a request is on the wall clock, whatever the
+		// service stamps users with
+		createBudget: budget.NewPlan(CreateUserSteps()),
 	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	// the guards depend on the options, WithPasswords in particular
+	u.lifecycle = newLifecycle(u)
+	return u
 }
 
-func (u *UserService) CreateUser(ctx context.Context, user *User) error {
-	err := u.store.Insert(user)
+func (u *UserService) CreateUser(ctx context.Context, user *User) (err error) {
+	ctx, span := u.startSpan(ctx, "CreateUser")
+	defer func() { endSpan(span, err) }()
+	u.assignID(user)
+	if user != nil {
+		span.SetAttributes(attribute.String("user.id", user.ID))
+	}
+	b := u.createBudget.Start(ctx)
+	if err := u.createValidate(b, user); err != nil {
+		return errs.Wrap("service.CreateUser", err)
+	}
+	storeCtx, cancel := b.Step("store")
+	err = u.mutate(storeCtx, user, userCreated, func(store UserStorer) error {
+		return store.Insert(storeCtx, user)
+	})
+	cancel()
 	if err != nil {
-		return err
+		return errs.Wrap("service.CreateUser", err)
+	}
+	u.log(ctx).DebugContext(ctx, "user created", slog.String("user_id", user.ID))
+	publishCtx, cancel := b.Step("publish")
+	defer cancel()
+	return errs.Wrap("service.CreateUser", errors.Join(
+		u.record(publishCtx, audit.Created, nil, user),
+		u.hooks.runAfter(publishCtx, afterCreate, user),
+	))
+}
+
+// RetrieveUser returns the concrete *User rather than interface{}.
+// Callers get compile-time checked field access (user.Email) instead of
+// a type assertion that can only fail at runtime:
+//
+//	// Bad: v.(User).Email panics if the store returns something else
+//	v, _ := svc.RetrieveUser(ctx, id)
+//
+//	// Good: the compiler knows the shape of the result
+//	user, _ := svc.RetrieveUser(ctx, id)
+//	fmt.Println(user.Email)
+func (u *UserService) RetrieveUser(ctx context.Context, id string, opts ...ReadOption) (_ *User, err error) {
+	ctx, span := u.startSpan(ctx, "RetrieveUser", attribute.String("user.id", id))
+	defer func() { endSpan(span, err) }()
+	if id == "" {
+		return nil, errs.Wrap("service.RetrieveUser", errs.ErrInvalidInput)
+	}
+	user, err := u.store.Get(ctx, id)
+	if err != nil {
+		return nil, errs.Wrap("service.RetrieveUser", err)
 	}
-	return nil
+	if !readOpts(opts).visible(user) {
+		return nil, errs.Wrap("service.RetrieveUser", errs.ErrNotFound)
+	}
+	return user, nil
 }
 
-func (u *UserService) RetrieveUser(ctx context.Context, id string) (interface{}, error) {
-	var user User
-	err := u.store.Get(id, &user)
+func (u *UserService) RetrieveUserByEmail(ctx context.Context, email string, opts ...ReadOption) (_ *User, err error) {
+	ctx, span := u.startSpan(ctx, "RetrieveUserByEmail")
+	defer func() { endSpan(span, err) }()
+	if email == "" {
+		return nil, errs.Wrap("service.RetrieveUserByEmail", errs.ErrInvalidInput)
+	}
+	finder, ok := u.store.(UserFinder)
+	if !ok {
+		return nil, errs.Wrap("service.RetrieveUserByEmail", errors.ErrUnsupported)
+	}
+	user, err := finder.GetByEmail(ctx, email)
 	if err != nil {
-		return nil, err
+		return nil, errs.Wrap("service.RetrieveUserByEmail", err)
+	}
+	if !readOpts(opts).visible(user) {
+		return nil, errs.Wrap("service.RetrieveUserByEmail", errs.ErrNotFound)
 	}
 	return user, nil
 }
+
+// retrieveConcurrency caps the store calls a single RetrieveUsers makes at
+// once, a request for a thousand IDs must not open a thousand connections.
+const retrieveConcurrency = 8
+
+// RetrieveUsers fetches every id concurrently and returns the users in the
+// same order as ids. The first failure, wrapped in an *ItemError naming the
+// ID, cancels the fetches still in flight and is the only error returned.
+func (u *UserService) RetrieveUsers(ctx context.Context, ids []string, opts ...ReadOption) (_ []*User, err error) {
+	ctx, span := u.startSpan(ctx, "RetrieveUsers", attribute.Int("batch.size", len(ids)))
+	defer func() { endSpan(span, err) }()
+	o := readOpts(opts)
+	users := make([]*User, len(ids))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(retrieveConcurrency)
+	for i, id := range ids {
+		// each goroutine writes only its own index, so users needs no lock
+		g.Go(func() error {
+			if id == "" {
+				return &ItemError{Index: i, ID: id, Err: errs.ErrInvalidInput}
+			}
+			user, err := u.store.Get(gctx, id)
+			if err == nil && !o.visible(user) {
+				err = errs.ErrNotFound
+			}
+			if err != nil {
+				return &ItemError{Index: i, ID: id, Err: err}
+			}
+			users[i] = user
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, errs.Wrap("service.RetrieveUsers", err)
+	}
+	return users, nil
+}
+
+// UpdateUser replaces a live user's mutable fields. Soft deleted users
+// cannot be updated until they are restored.
+//
+// user.Version must be the version the caller last read, otherwise the
+// update fails with errs.ErrVersionConflict rather than silently overwriting
+// a concurrent change. On success user.Version holds the new version.
+func (u *UserService) UpdateUser(ctx context.Context, user *User) (err error) {
+	ctx, span := u.startSpan(ctx, "UpdateUser")
+	defer func() { endSpan(span, err) }()
+	if user != nil {
+		span.SetAttributes(attribute.String("user.id", user.ID))
+	}
+	if err := u.validate(ctx, user); err != nil {
+		return errs.Wrap("service.UpdateUser", err)
+	}
+	existing, err := u.store.Get(ctx, user.ID)
+	if err != nil {
+		return errs.Wrap("service.UpdateUser", err)
+	}
+	if existing.Deleted() {
+		return errs.Wrap("service.UpdateUser", errs.ErrNotFound)
+	}
+	if existing.Version != user.Version {
+		return errs.Wrap("service.UpdateUser", errs.ErrVersionConflict)
+	}
+	// stores keep the original CreatedAt, only UpdatedAt moves
+	user.UpdatedAt = u.clock.Now()
+	user.DeletedAt = time.Time{}
+	user.Status = existing.Status
+	if err := u.hooks.runBefore(ctx, beforeUpdate, user); err != nil {
+		return errs.Wrap("service.UpdateUser", err)
+	}
+	err = u.mutate(ctx, user, userUpdated, func(store UserStorer) error {
+		return store.Update(ctx, user)
+	})
+	if err != nil {
+		return errs.Wrap("service.UpdateUser", err)
+	}
+	u.log(ctx).DebugContext(ctx, "user updated", slog.String("user_id", user.ID))
+	return errs.Wrap("service.UpdateUser", errors.Join(
+		u.record(ctx, audit.Updated, existing, user),
+		u.hooks.runAfter(ctx, afterUpdate, user),
+	))
+}
+
+// DeleteUser soft deletes a user: the record stays in the store with
+// DeletedAt set and is hidden from reads unless IncludeDeleted is passed.
+// Use PurgeUser to remove it for good.
+func (u *UserService) DeleteUser(ctx context.Context, id string) (err error) {
+	ctx, span := u.startSpan(ctx, "DeleteUser", attribute.String("user.id", id))
+	defer func() { endSpan(span, err) }()
+	if id == "" {
+		return errs.Wrap("service.DeleteUser", errs.ErrInvalidInput)
+	}
+	before, user, err := u.modify(ctx, id, userDeleted, func(user *User) error {
+		if user.Deleted() {
+			return errs.ErrNotFound
+		}
+		user.DeletedAt = u.clock.Now()
+		return u.hooks.runBefore(ctx, beforeDelete, user)
+	})
+	if err != nil {
+		return errs.Wrap("service.DeleteUser", err)
+	}
+	u.log(ctx).DebugContext(ctx, "user deleted", slog.String("user_id", id))
+	return errs.Wrap("service.DeleteUser", errors.Join(
+		u.record(ctx, audit.Deleted, before, user),
+		u.hooks.runAfter(ctx, afterDelete, user),
+	))
+}
+
+// RestoreUser undoes DeleteUser. Restoring a live user is a no-op.
+func (u *UserService) RestoreUser(ctx context.Context, id string) (err error) {
+	ctx, span := u.startSpan(ctx, "RestoreUser", attribute.String("user.id", id))
+	defer func() { endSpan(span, err) }()
+	if id == "" {
+		return errs.Wrap("service.RestoreUser", errs.ErrInvalidInput)
+	}
+	before, user, err := u.modify(ctx, id, userUpdated, func(user *User) error {
+		user.DeletedAt = time.Time{}
+		return u.hooks.runBefore(ctx, beforeUpdate, user)
+	})
+	if err != nil {
+		return errs.Wrap("service.RestoreUser", err)
+	}
+	u.log(ctx).DebugContext(ctx, "user restored", slog.String("user_id", id))
+	return errs.Wrap("service.RestoreUser", errors.Join(
+		u.record(ctx, audit.Restored, before, user),
+		u.hooks.runAfter(ctx, afterUpdate, user),
+	))
+}
+
+// PurgeUser permanently removes a user, deleted or not.
+func (u *UserService) PurgeUser(ctx context.Context, id string) (err error) {
+	ctx, span := u.startSpan(ctx, "PurgeUser", attribute.String("user.id", id))
+	defer func() { endSpan(span, err) }()
+	if id == "" {
+		return errs.Wrap("service.PurgeUser", errs.ErrInvalidInput)
+	}
+	// only the audit entry needs the user as it was, skip the read without one
+	var before *User
+	if u.audit != nil {
+		before, _ = u.store.Get(ctx, id)
+	}
+	if err := u.store.Delete(ctx, id); err != nil {
+		return errs.Wrap("service.PurgeUser", err)
+	}
+	u.log(ctx).DebugContext(ctx, "user purged", slog.String("user_id", id))
+	if before == nil {
+		// the read failed, the entry still names the purged ID
+		before = &User{ID: id}
+	}
+	return errs.Wrap("service.PurgeUser", errors.Join(u.dropPassword(ctx, id), u.record(ctx, audit.Purged, before, nil)))
+}
+
+// modify loads a user, lets change alter it, and writes it back inside a
+// transaction when the store supports one, along with its outbox event. It
+// returns the user as loaded and as written.
+func (u *UserService) modify(ctx context.Context, id string, event func(context.Context, *User) events.Event, change func(*User) error) (before, user *User, err error) {
+	err = u.withinTx(ctx, func(store UserStorer) error {
+		var err error
+		user, err = store.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		loaded := *user
+		before = &loaded
+		user.UpdatedAt = u.clock.Now()
+		if err := change(user); err != nil {
+			return err
+		}
+		if err := store.Update(ctx, user); err != nil {
+			return err
+		}
+		return u.appendOutbox(ctx, store, event(ctx, user))
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return before, user, nil
+}
+
+// RenameUser moves a user to a new ID. The insert and delete happen inside
+// one transaction when the store supports it, so a failure part way through
+// never leaves the user under both IDs or neither. Inside one the old row is
+// deleted first, it still holds the email a store may require to be unique;
+// without one the insert goes first so a failed insert loses nothing.
+func (u *UserService) RenameUser(ctx context.Context, oldID, newID string) (err error) {
+	ctx, span := u.startSpan(ctx, "RenameUser", attribute.String("user.id", oldID), attribute.String("user.new_id", newID))
+	defer func() { endSpan(span, err) }()
+	if oldID == "" {
+		return errs.Wrap("service.RenameUser", errs.ErrInvalidInput)
+	}
+	// the new ID is held to the same rules as one given to CreateUser
+	var v validate.Errors
+	v.Required("ID", newID)
+	v.MaxLen("ID", newID, maxIDLen)
+	v.Printable("ID", newID)
+	if err := v.Err(); err != nil {
+		return errs.Wrap("service.RenameUser", err)
+	}
+	_, atomic := u.store.(Transactor)
+	var before, after *User
+	err = u.withinTx(ctx, func(store UserStorer) error {
+		user, err := store.Get(ctx, oldID)
+		if err != nil {
+			return err
+		}
+		loaded := *user
+		before = &loaded
+		user.ID = newID
+		if atomic {
+			if err := store.Delete(ctx, oldID); err != nil {
+				return err
+			}
+		}
+		if err := store.Insert(ctx, user); err != nil {
+			return err
+		}
+		after = user
+		if atomic {
+			return nil
+		}
+		return store.Delete(ctx, oldID)
+	})
+	if err != nil {
+		return errs.Wrap("service.RenameUser", err)
+	}
+	return errs.Wrap("service.RenameUser", errors.Join(u.movePassword(ctx, oldID, newID), u.record(ctx, audit.Renamed, before, after)))
+}
+
+// assignID fills in a missing ID when the service has a generator.
+func (u *UserService) assignID(user *User) {
+	if user != nil && user.ID == "" && u.ids != nil {
+		user.ID = u.ids.NewID()
+	}
+}
+
+// log prefers the request-scoped logger, which carries the request ID.
+func (u *UserService) log(ctx context.Context) *slog.Logger {
+	return ctxutil.Logger(ctx, u.logger)
+}
+
+// withinTx runs fn in a transaction if the store is a Transactor, otherwise
+// it runs fn directly against the store without atomicity guarantees. The
+// outbox cannot do without the transaction, so WithOutbox makes that an error.
+func (u *UserService) withinTx(ctx context.Context, fn func(UserStorer) error) error {
+	if tx, ok := u.store.(Transactor); ok {
+		return tx.WithinTx(ctx, fn)
+	}
+	if u.outbox {
+		return errs.Wrap("service.outbox", errors.ErrUnsupported)
+	}
+	return fn(u.store)
+}
+
+// createValidate is CreateUser's first step, validation and the before
+// hooks, within the step's share of the deadline.
+func (u *UserService) createValidate(b *budget.Budget, user *User) error {
+	ctx, cancel := b.Step("validate")
+	defer cancel()
+	if err := u.validate(ctx, user); err != nil {
+		return err
+	}
+	now := u.clock.Now()
+	user.CreatedAt, user.UpdatedAt = now, now
+	user.Version = 1
+	user.Status = StatusPending
+	return u.hooks.runBefore(ctx, beforeCreate, user)
+}
+
+// CreateUsers inserts every user it can and reports the rest in Failed.
+// The returned error is only non-nil when the batch as a whole could not run,
+// e.g. the context was canceled, individual failures never abort the batch.
+// A user whose OnUserCreated hook or audit entry fails was still stored, so
+// it is listed in Created and that error in Failed.
+func (u *UserService) CreateUsers(ctx context.Context, users []*User) (_ BatchResult, err error) {
+	ctx, span := u.startSpan(ctx, "CreateUsers", attribute.Int("batch.size", len(users)))
+	defer func() { endSpan(span, err) }()
+	var result BatchResult
+	if err := ctx.Err(); err != nil {
+		return result, errs.Wrap("service.CreateUsers", err)
+	}
+
+	// invalid items are rejected up front and never reach the store
+	now := u.clock.Now()
+	valid := make([]*User, 0, len(users))
+	index := make([]int, 0, len(users))
+	for i, user := range users {
+		u.assignID(user)
+		if err := u.validate(ctx, user); err != nil {
+			id := ""
+			if user != nil {
+				id = user.ID
+			}
+			result.Failed = append(result.Failed, &ItemError{Index: i, ID: id, Err: err})
+			continue
+		}
+		user.CreatedAt, user.UpdatedAt = now, now
+		user.Version = 1
+		user.Status = StatusPending
+		if err := u.hooks.runBefore(ctx, beforeCreate, user); err != nil {
+			result.Failed = append(result.Failed, &ItemError{Index: i, ID: user.ID, Err: err})
+			continue
+		}
+		valid = append(valid, user)
+		index = append(index, i)
+	}
+
+	// the outbox needs a transaction per user, so it skips the bulk insert
+	var insertErrs []error
+	if b, ok := u.store.(BatchInserter); ok && !u.outbox {
+		insertErrs = b.InsertMany(ctx, valid)
+	} else {
+		insertErrs = make([]error, len(valid))
+		for i, user := range valid {
+			insertErrs[i] = u.mutate(ctx, user, userCreated, func(store UserStorer) error {
+				return store.Insert(ctx, user)
+			})
+		}
+	}
+
+	for i, err := range insertErrs {
+		if err != nil {
+			result.Failed = append(result.Failed, &ItemError{Index: index[i], ID: valid[i].ID, Err: err})
+			continue
+		}
+		result.Created = append(result.Created, valid[i])
+		err := errors.Join(u.record(ctx, audit.Created, nil, valid[i]), u.hooks.runAfter(ctx, afterCreate, valid[i]))
+		if err != nil {
+			result.Failed = append(result.Failed, &ItemError{Index: index[i], ID: valid[i].ID, Err: err})
+		}
+	}
+	sort.Slice(result.Failed, func(i, j int) bool {
+		return result.Failed[i].Index < result.Failed[j].Index
+	})
+	return result, nil
+}
+
+// ListUsers returns one page of users ordered by ID. Cursors are opaque and
+// keyed on the last ID returned rather than an offset, so inserts and deletes
+// between calls never cause a page to skip or repeat users.
+//
+// The page is built as a query.Query. A UserQuerier runs it in one call,
+// other stores are read until a full page of matching users is collected.
+// Soft deleted users are skipped unless IncludeDeleted is passed.
+func (u *UserService) ListUsers(ctx context.Context, req PageRequest, opts ...ReadOption) (_ Page[User], err error) {
+	ctx, span := u.startSpan(ctx, "ListUsers", attribute.Int("page.limit", req.Limit))
+	defer func() { endSpan(span, err) }()
+	after, err := cursor.Decode(req.Cursor)
+	if err != nil {
+		return Page[User]{}, errs.Wrap("service.ListUsers", err)
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	q := query.Users().OrderBy(query.ID)
+	if req.EmailLike != "" {
+		var v validate.Errors
+		v.MaxLen("EmailLike", req.EmailLike, maxEmailLen)
+		v.Printable("EmailLike", req.EmailLike)
+		if err := v.Err(); err != nil {
+			return Page[User]{}, errs.Wrap("service.ListUsers", err)
+		}
+		q = q.WhereEmailLike(req.EmailLike)
+	}
+	if !readOpts(opts).includeDeleted {
+		q = q.ExcludeDeleted()
+	}
+
+	// one extra row tells us whether another page exists
+	users, err := u.queryUsers(ctx, q, after, limit+1)
+	if err != nil {
+		return Page[User]{}, errs.Wrap("service.ListUsers", err)
+	}
+	page := Page[User]{Items: make([]User, 0, min(len(users), limit))}
+	for i, user := range users {
+		if i == limit {
+			page.NextCursor = cursor.Encode(users[i-1].ID)
+			break
+		}
+		page.Items = append(page.Items, *user)
+	}
+	return page, nil
+}
+
+// queryUsers returns up to limit users matching q whose IDs sort after the
+// given one. q must be in ID order: a store without Query is read page by
+// page with List, which only knows that order.
+func (u *UserService) queryUsers(ctx context.Context, q query.Query, after string, limit int) ([]*User, error) {
+	if querier, ok := u.store.(UserQuerier); ok {
+		users, err := querier.Query(ctx, q.WhereIDAfter(after).Limit(limit))
+		// decorators have Query whether the store underneath does or not
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return users, err
+		}
+	}
+	lister, ok := u.store.(UserLister)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	var users []*User
+	for len(users) < limit {
+		batch, err := lister.List(ctx, after, limit)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range batch {
+			if q.Match(user.Record()) {
+				users = append(users, user)
+			}
+		}
+		if len(batch) < limit {
+			break
+		}
+		after = batch[len(batch)-1].ID
+	}
+	return users[:min(len(users), limit)], nil
+}
@@ -0,0 +1,138 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/fake"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// TestCRUDLifecycle walks one user through create, retrieve, update,
+// delete, and purge against the fake, checking the service's answers and
+// the store calls it made for them.
+func TestCRUDLifecycle(t *testing.T) {
+	ctx := context.Background()
+	store := fake.New()
+	users := service.NewUserService(store)
+
+	ada := &service.User{ID: "ada", Email: "ada@example.com", Name: "Ada"}
+	if err := users.CreateUser(ctx, ada); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	store.AssertCalled(t, "Insert", fake.Match(func(u *service.User) bool { return u.ID == "ada" }))
+
+	got, err := users.RetrieveUser(ctx, "ada")
+	if err != nil {
+		t.Fatalf("RetrieveUser: %v", err)
+	}
+	if got.Email != "ada@example.com" || got.Version != 1 {
+		t.Fatalf("RetrieveUser = %+v, want ada at version 1", got)
+	}
+
+	got.Email = "ada@lovelace.example"
+	if err := users.UpdateUser(ctx, got); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if got.Version != 2 {
+		t.Errorf("after UpdateUser Version = %d, want 2", got.Version)
+	}
+	store.AssertCalled(t, "Update", fake.Match(func(u *service.User) bool { return u.Email == "ada@lovelace.example" }))
+
+	if err := users.DeleteUser(ctx, "ada"); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if _, err := users.RetrieveUser(ctx, "ada"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("RetrieveUser after DeleteUser: err = %v, want ErrNotFound", err)
+	}
+	if got, err := users.RetrieveUser(ctx, "ada", service.IncludeDeleted()); err != nil || !got.Deleted() {
+		t.Errorf("RetrieveUser(IncludeDeleted) = %+v, %v, want the deleted user", got, err)
+	}
+	// a soft delete is an update, the row stays
+	store.AssertNotCalled(t, "Delete")
+
+	if err := users.PurgeUser(ctx, "ada"); err != nil {
+		t.Fatalf("PurgeUser: %v", err)
+	}
+	store.AssertCalled(t, "Delete", "ada")
+	if _, err := users.RetrieveUser(ctx, "ada", service.IncludeDeleted()); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("RetrieveUser after PurgeUser: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestUpdateAndDeleteErrors(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		call func(*service.UserService) error
+		want error
+	}{
+		{
+			name: "update a missing user",
+			call: func(u *service.UserService) error {
+				return u.UpdateUser(ctx, &service.User{ID: "nobody", Email: "nobody@example.com", Version: 1})
+			},
+			want: errs.ErrNotFound,
+		},
+		{
+			name: "update an invalid user",
+			call: func(u *service.UserService) error {
+				return u.UpdateUser(ctx, &service.User{ID: "ada", Email: "not an email", Version: 1})
+			},
+			want: errs.ErrInvalidInput,
+		},
+		{
+			name: "delete without an ID",
+			call: func(u *service.UserService) error { return u.DeleteUser(ctx, "") },
+			want: errs.ErrInvalidInput,
+		},
+		{
+			name: "delete a missing user",
+			call: func(u *service.UserService) error { return u.DeleteUser(ctx, "nobody") },
+			want: errs.ErrNotFound,
+		},
+		{
+			name: "delete twice",
+			call: func(u *service.UserService) error {
+				if err := u.DeleteUser(ctx, "ada"); err != nil {
+					return err
+				}
+				return u.DeleteUser(ctx, "ada")
+			},
+			want: errs.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := fake.New()
+			users := service.NewUserService(store)
+			if err := users.CreateUser(ctx, &service.User{ID: "ada", Email: "ada@example.com"}); err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+			if err := tt.call(users); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestStoreErrorsPassThrough scripts the store to fail and checks the
+// service reports the store's error, not one of its own.
+func TestStoreErrorsPassThrough(t *testing.T) {
+	ctx := context.Background()
+	store := fake.New()
+	users := service.NewUserService(store)
+	if err := users.CreateUser(ctx, &service.User{ID: "ada", Email: "ada@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	store.Queue("Get", fake.Return{Err: errs.ErrUnavailable})
+	if _, err := users.RetrieveUser(ctx, "ada"); !errors.Is(err, errs.ErrUnavailable) {
+		t.Errorf("RetrieveUser: err = %v, want ErrUnavailable", err)
+	}
+	store.FailWith("Update", errs.ErrUnavailable)
+	if err := users.DeleteUser(ctx, "ada"); !errors.Is(err, errs.ErrUnavailable) {
+		t.Errorf("DeleteUser: err = %v, want ErrUnavailable", err)
+	}
+}
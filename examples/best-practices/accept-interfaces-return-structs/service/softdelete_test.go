@@ -0,0 +1,89 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	ctx := context.Background()
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			c := clock.NewFake(at)
+			users := service.NewUserService(store, service.WithClock(c))
+			for _, u := range []*service.User{{ID: "ada", Email: "ada@example.com"}, {ID: "bob", Email: "bob@example.com"}} {
+				if err := users.CreateUser(ctx, u); err != nil {
+					t.Fatalf("CreateUser: %v", err)
+				}
+			}
+			c.Advance(time.Hour)
+			if err := users.DeleteUser(ctx, "ada"); err != nil {
+				t.Fatalf("DeleteUser: %v", err)
+			}
+
+			// hidden from every read by default
+			if _, err := users.RetrieveUser(ctx, "ada"); !errors.Is(err, errs.ErrNotFound) {
+				t.Errorf("RetrieveUser: err = %v, want ErrNotFound", err)
+			}
+			if _, err := users.RetrieveUserByEmail(ctx, "ada@example.com"); !errors.Is(err, errs.ErrNotFound) {
+				t.Errorf("RetrieveUserByEmail: err = %v, want ErrNotFound", err)
+			}
+			if got := listed(t, users); len(got) != 1 || got[0] != "bob" {
+				t.Errorf("ListUsers = %v, want [bob]", got)
+			}
+			if err := users.DeleteUser(ctx, "ada"); !errors.Is(err, errs.ErrNotFound) {
+				t.Errorf("DeleteUser twice: err = %v, want ErrNotFound", err)
+			}
+
+			// and still there when asked for
+			ada, err := users.RetrieveUser(ctx, "ada", service.IncludeDeleted())
+			if err != nil {
+				t.Fatalf("RetrieveUser(IncludeDeleted): %v", err)
+			}
+			if want := at.Add(time.Hour); !ada.DeletedAt.Equal(want) {
+				t.Errorf("DeletedAt = %v, want %v", ada.DeletedAt, want)
+			}
+			if got := listed(t, users, service.IncludeDeleted()); len(got) != 2 {
+				t.Errorf("ListUsers(IncludeDeleted) = %v, want ada and bob", got)
+			}
+
+			if err := users.RestoreUser(ctx, "ada"); err != nil {
+				t.Fatalf("RestoreUser: %v", err)
+			}
+			ada, err = users.RetrieveUser(ctx, "ada")
+			if err != nil {
+				t.Fatalf("RetrieveUser after restore: %v", err)
+			}
+			if ada.Deleted() {
+				t.Errorf("DeletedAt = %v after restore, want zero", ada.DeletedAt)
+			}
+			if err := users.RestoreUser(ctx, "ada"); err != nil {
+				t.Errorf("RestoreUser of a live user: %v, want a no-op", err)
+			}
+			if err := users.RestoreUser(ctx, "nobody"); !errors.Is(err, errs.ErrNotFound) {
+				t.Errorf("RestoreUser of a missing user: err = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+// listed returns the IDs on the first page of ListUsers.
+func listed(t *testing.T, users *service.UserService, opts ...service.ReadOption) []string {
+	t.Helper()
+	page, err := users.ListUsers(context.Background(), service.PageRequest{}, opts...)
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	var got []string
+	for _, u := range page.Items {
+		got = append(got, u.ID)
+	}
+	return got
+}
@@ -0,0 +1,47 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/audit"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/tenanted"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/events"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// TestTenantEvents checks events and audit entries name the tenant.
+func TestTenantEvents(t *testing.T) {
+	ctx := context.Background()
+	bus := events.NewBus()
+	var published []events.Event
+	bus.Subscribe(func(_ context.Context, event events.Event) error {
+		published = append(published, event)
+		return nil
+	})
+	trail := audit.NewMemory()
+	users := service.NewUserService(tenanted.New(db.NewMemoryStore()), service.WithPublisher(bus), service.WithAuditSink(trail))
+	tenants := []string{"acme", "globex"}
+	for _, tenant := range tenants {
+		if err := users.CreateUser(ctxutil.WithTenant(ctx, tenant), &service.User{ID: "ada", Email: "ada@example.com"}); err != nil {
+			t.Fatalf("CreateUser in %s: %v", tenant, err)
+		}
+	}
+	if len(published) != len(tenants) {
+		t.Fatalf("%d events, want %d", len(published), len(tenants))
+	}
+	for i, tenant := range tenants {
+		if created, ok := published[i].(events.UserCreated); !ok || created.Tenant != tenant {
+			t.Errorf("event %d = %+v, want a UserCreated in %s", i, published[i], tenant)
+		}
+		entries, err := trail.ByUser(ctxutil.WithTenant(ctx, tenant), "ada")
+		if err != nil || len(entries) != 1 || entries[0].Tenant != tenant {
+			t.Errorf("%s's audit trail for ada = %+v, %v, want its one create", tenant, entries, err)
+		}
+	}
+	if entries, err := trail.ByUser(ctx, "ada"); err != nil || len(entries) != 0 {
+		t.Errorf("the default tenant's audit trail for ada = %d entries, %v, want none", len(entries), err)
+	}
+}
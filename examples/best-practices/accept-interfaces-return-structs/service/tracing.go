@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the service's spans to the tracing backend.
+const instrumentationName = "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+
+// WithTracerProvider sets where the service's spans go. The default is the
+// global provider, which is a no-op until otel.SetTracerProvider is called,
+// see the tracing package.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(u *UserService) {
+		u.tracer = tp.Tracer(instrumentationName)
+	}
+}
+
+func defaultTracer() trace.Tracer {
+	return otel.GetTracerProvider().Tracer(instrumentationName)
+}
+
+// startSpan opens a span named after the method, the store decorator in
+// db/traced nests its spans under it through the returned context.
+func (u *UserService) startSpan(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return u.tracer.Start(ctx, "UserService."+method, trace.WithAttributes(attrs...))
+}
+
+// endSpan records err on span and ends it. Methods defer it with their named
+// error result so every return path is covered.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,109 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+func TestStaleUpdate(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			users := service.NewUserService(store)
+			if err := users.CreateUser(ctx, &service.User{ID: "ada", Email: "ada@example.com"}); err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+			first, _ := users.RetrieveUser(ctx, "ada")
+			second, _ := users.RetrieveUser(ctx, "ada")
+			first.Name = "first"
+			if err := users.UpdateUser(ctx, first); err != nil {
+				t.Fatalf("UpdateUser: %v", err)
+			}
+			second.Name = "second"
+			err := users.UpdateUser(ctx, second)
+			if !errors.Is(err, errs.ErrVersionConflict) || !errors.Is(err, errs.ErrConflict) {
+				t.Fatalf("UpdateUser from a stale read: err = %v, want ErrVersionConflict", err)
+			}
+			got, _ := users.RetrieveUser(ctx, "ada")
+			if got.Name != "first" || got.Version != 2 {
+				t.Errorf("stored %q at version %d, want first at 2", got.Name, got.Version)
+			}
+
+			// the store checks too, not just the service's read before it
+			stale := *got
+			stale.Version = 1
+			if err := store.Update(ctx, &stale); !errors.Is(err, errs.ErrVersionConflict) {
+				t.Errorf("store.Update at an old version: err = %v, want ErrVersionConflict", err)
+			}
+		})
+	}
+}
+
+// TestConcurrentUpdaters has each goroutine append to the user's name in a
+// read, modify, write loop that retries on a version conflict. If two
+// writes from the same version were ever both accepted one append would
+// be lost, so the final name holds exactly one letter per update.
+func TestConcurrentUpdaters(t *testing.T) {
+	const (
+		writers = 8
+		each    = 10
+	)
+	ctx := context.Background()
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			users := service.NewUserService(store)
+			if err := users.CreateUser(ctx, &service.User{ID: "ada", Email: "ada@example.com"}); err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+			var (
+				wg    sync.WaitGroup
+				start = make(chan struct{})
+			)
+			for w := range writers {
+				letter := string(rune('a' + w))
+				wg.Go(func() {
+					<-start
+					for range each {
+						for {
+							user, err := users.RetrieveUser(ctx, "ada")
+							if err != nil {
+								t.Errorf("RetrieveUser: %v", err)
+								return
+							}
+							user.Name += letter
+							err = users.UpdateUser(ctx, user)
+							if err == nil {
+								break
+							}
+							if !errors.Is(err, errs.ErrVersionConflict) {
+								t.Errorf("UpdateUser: %v", err)
+								return
+							}
+						}
+					}
+				})
+			}
+			close(start)
+			wg.Wait()
+
+			got, err := users.RetrieveUser(ctx, "ada")
+			if err != nil {
+				t.Fatalf("RetrieveUser: %v", err)
+			}
+			if len(got.Name) != writers*each || got.Version != 1+writers*each {
+				t.Errorf("name has %d letters at version %d, want %d at %d", len(got.Name), got.Version, writers*each, 1+writers*each)
+			}
+			for w := range writers {
+				if n := strings.Count(got.Name, string(rune('a'+w))); n != each {
+					t.Errorf("writer %c landed %d updates, want %d", 'a'+w, n, each)
+				}
+			}
+		})
+	}
+}
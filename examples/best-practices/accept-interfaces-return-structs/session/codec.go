@@ -0,0 +1,80 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// ErrInvalidCookie means a cookie did not decrypt under any key: it was
+// forged, tampered with, made for another cookie name, or made with a key
+// that has since been retired.
+var ErrInvalidCookie = errors.New("invalid cookie")
+
+// Codec seals cookie values with AES-256-GCM, which encrypts and
+// authenticates in one step, so a value is both unreadable and
+// unforgeable without the key. The cookie's name is bound in as associated
+// data, a value sealed for one cookie does not open as another.
+//
+// The first key seals, every key opens. Rotate by putting a new key first
+// and keeping the old one until the cookies it sealed have expired.
+type Codec struct {
+	aeads []cipher.AEAD
+}
+
+// NewCodec takes one or more 32 byte keys from a secure random source.
+func NewCodec(keys ...[]byte) (*Codec, error) {
+	if len(keys) == 0 {
+		return nil, errs.Wrap("session.NewCodec", fmt.Errorf("no keys: %w", errs.ErrInvalidInput))
+	}
+	c := &Codec{}
+	for i, key := range keys {
+		if len(key) != 32 {
+			return nil, errs.Wrap("session.NewCodec", fmt.Errorf("key %d is %d bytes, want 32: %w", i, len(key), errs.ErrInvalidInput))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, errs.Wrap("session.NewCodec", err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, errs.Wrap("session.NewCodec", err)
+		}
+		c.aeads = append(c.aeads, aead)
+	}
+	return c, nil
+}
+
+// Encode seals value for the cookie called name.
+func (c *Codec) Encode(name string, value []byte) (string, error) {
+	aead := c.aeads[0]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(value)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", errs.Wrap("session.Codec.Encode", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, value, []byte(name))), nil
+}
+
+// Decode opens a value Encode sealed for the cookie called name, failing
+// with ErrInvalidCookie for anything else.
+func (c *Codec) Decode(name, encoded string) ([]byte, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCookie
+	}
+	for _, aead := range c.aeads {
+		if len(sealed) < aead.NonceSize() {
+			continue
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		if value, err := aead.Open(nil, nonce, ciphertext, []byte(name)); err == nil {
+			return value, nil
+		}
+	}
+	return nil, ErrInvalidCookie
+}
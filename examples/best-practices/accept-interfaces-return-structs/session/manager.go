@@ -0,0 +1,219 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/middleware"
+)
+
+const (
+	defaultCookieName      = "session"
+	defaultIdleTimeout     = 30 * time.Minute
+	defaultAbsoluteTimeout = 12 * time.Hour
+)
+
+type Option func(*Manager)
+
+// WithCookieName renames the cookie from "session".
+func WithCookieName(name string) Option {
+	return func(m *Manager) {
+		m.name = name
+	}
+}
+
+// WithIdleTimeout ends a session after d without a request, 30 minutes by
+// default.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(m *Manager) {
+		m.idle = d
+	}
+}
+
+// WithAbsoluteTimeout ends a session d after it started however active it
+// is, 12 hours by default. It bounds how long a stolen cookie is any use.
+func WithAbsoluteTimeout(d time.Duration) Option {
+	return func(m *Manager) {
+		m.absolute = d
+	}
+}
+
+// WithNow swaps the clock the timeouts are measured with.
+func WithNow(now func() time.Time) Option {
+	return func(m *Manager) {
+		m.now = now
+	}
+}
+
+// WithLogger is where the middleware reports sessions it failed to save.
+func WithLogger(logger *slog.Logger) Option {
+	return func(m *Manager) {
+		m.logger = logger
+	}
+}
+
+// Manager starts, loads, and ends sessions kept in a Store.
+type Manager struct {
+	store    Store
+	codec    *Codec
+	name     string
+	idle     time.Duration
+	absolute time.Duration
+	now      func() time.Time
+	logger   *slog.Logger
+}
+
+func New(store Store, codec *Codec, opts ...Option) *Manager {
+	m := &Manager{
+		store:    store,
+		codec:    codec,
+		name:     defaultCookieName,
+		idle:     defaultIdleTimeout,
+		absolute: defaultAbsoluteTimeout,
+		now:      time.Now,
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Codec is the codec the manager seals its cookie with, for other cookies
+// that need the same protection.
+func (m *Manager) Codec() *Codec {
+	return m.codec
+}
+
+// Start logs userID in with roles: it ends any session the request already carries
+// and starts a new one under a new ID. Never reusing the ID from before
+// the login is what defeats session fixation, where an attacker plants a
+// known ID in the victim's browser and waits for them to log in with it.
+func (m *Manager) Start(w http.ResponseWriter, r *http.Request, userID string, roles ...string) (*Session, error) {
+	if old, err := m.Load(r); err == nil {
+		if err := m.store.Delete(r.Context(), old.ID); err != nil {
+			return nil, errs.Wrap("session.Manager.Start", err)
+		}
+	}
+	now := m.now()
+	s := &Session{ID: rand.Text(), UserID: userID, Roles: roles, CreatedAt: now, LastSeen: now}
+	if err := m.store.Save(r.Context(), s, m.ttl(s, now)); err != nil {
+		return nil, errs.Wrap("session.Manager.Start", err)
+	}
+	value, err := m.codec.Encode(m.name, []byte(s.ID))
+	if err != nil {
+		return nil, errs.Wrap("session.Manager.Start", err)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.name,
+		Value:    value,
+		Path:     "/",
+		Expires:  s.CreatedAt.Add(m.absolute),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return s, nil
+}
+
+// Load returns the request's session. It fails with errs.ErrNotFound when
+// there is none, the cookie does not open, or the session has expired,
+// which also deletes it.
+func (m *Manager) Load(r *http.Request) (*Session, error) {
+	c, err := r.Cookie(m.name)
+	if err != nil {
+		return nil, errs.Wrap("session.Manager.Load", errs.ErrNotFound)
+	}
+	id, err := m.codec.Decode(m.name, c.Value)
+	if err != nil {
+		return nil, errs.Wrap("session.Manager.Load", errors.Join(errs.ErrNotFound, err))
+	}
+	s, err := m.store.Get(r.Context(), string(id))
+	if err != nil {
+		return nil, errs.Wrap("session.Manager.Load", err)
+	}
+	if m.ttl(s, m.now()) <= 0 {
+		// the store is told the TTL but need not honor it to the second
+		if err := m.store.Delete(r.Context(), s.ID); err != nil {
+			return nil, errs.Wrap("session.Manager.Load", err)
+		}
+		return nil, errs.Wrap("session.Manager.Load", errs.ErrNotFound)
+	}
+	return s, nil
+}
+
+// Save stores changes to s made outside the middleware, it saves those
+// made by the handlers behind it.
+func (m *Manager) Save(ctx context.Context, s *Session) error {
+	s.dirty = false
+	return errs.Wrap("session.Manager.Save", m.store.Save(ctx, s, m.ttl(s, m.now())))
+}
+
+// End logs the request's session out, deleting it from the store so a
+// copy of the cookie is worthless too, and clears the cookie.
+func (m *Manager) End(w http.ResponseWriter, r *http.Request) error {
+	http.SetCookie(w, &http.Cookie{Name: m.name, Path: "/", MaxAge: -1, HttpOnly: true, Secure: true, SameSite: http.SameSiteLaxMode})
+	s, err := m.Load(r)
+	if errors.Is(err, errs.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return errs.Wrap("session.Manager.End", err)
+	}
+	return errs.Wrap("session.Manager.End", m.store.Delete(r.Context(), s.ID))
+}
+
+// Middleware loads the request's session for From, and its user as the
+// ctxutil.Principal, so authz and the audit log see who is calling.
+// Requests without a live session pass through with neither, guarding
+// routes is the handlers' or authz's job.
+//
+// Every request counts as activity: the session is saved with its idle
+// timeout pushed back before the handler runs, and again after it if the
+// handler changed its values.
+func (m *Manager) Middleware() middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s, err := m.Load(r)
+			if err != nil {
+				if !errors.Is(err, errs.ErrNotFound) {
+					m.logger.ErrorContext(r.Context(), "session load failed", slog.String("error", err.Error()))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			s.LastSeen = m.now()
+			if err := m.Save(r.Context(), s); err != nil {
+				m.logger.ErrorContext(r.Context(), "session save failed", slog.String("error", err.Error()))
+			}
+			ctx := sessionKey.With(r.Context(), s)
+			ctx = ctxutil.WithPrincipal(ctx, ctxutil.Principal{Subject: s.UserID, ExpiresAt: m.expiresAt(s), Roles: s.Roles})
+			next.ServeHTTP(w, r.WithContext(ctx))
+			if s.dirty {
+				if err := m.Save(r.Context(), s); err != nil {
+					m.logger.ErrorContext(r.Context(), "session save failed", slog.String("error", err.Error()))
+				}
+			}
+		})
+	}
+}
+
+// expiresAt is the earlier of the idle and absolute deadlines.
+func (m *Manager) expiresAt(s *Session) time.Time {
+	idle := s.LastSeen.Add(m.idle)
+	if absolute := s.CreatedAt.Add(m.absolute); absolute.Before(idle) {
+		return absolute
+	}
+	return idle
+}
+
+func (m *Manager) ttl(s *Session, now time.Time) time.Duration {
+	return m.expiresAt(s).Sub(now)
+}
@@ -0,0 +1,70 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+type entry struct {
+	session   *Session
+	expiresAt time.Time
+}
+
+// Memory is a Store for tests and single instance deployments, sessions
+// are lost on restart and not shared between instances. Expired sessions
+// are dropped when next read, or by a Save or Delete of any session.
+type Memory struct {
+	mu       sync.Mutex
+	sessions map[string]entry
+	now      func() time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{sessions: make(map[string]entry), now: time.Now}
+}
+
+func (m *Memory) Get(ctx context.Context, id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.sessions[id]
+	if !ok || !m.now().Before(e.expiresAt) {
+		delete(m.sessions, id)
+		return nil, errs.Wrap("session.Memory.Get", errs.ErrNotFound)
+	}
+	return e.session.Clone(), nil
+}
+
+func (m *Memory) Save(ctx context.Context, s *Session, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep()
+	m.sessions[s.ID] = entry{session: s.Clone(), expiresAt: m.now().Add(ttl)}
+	return nil
+}
+
+func (m *Memory) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep()
+	delete(m.sessions, id)
+	return nil
+}
+
+// Len is the number of sessions held, expired ones not yet dropped included.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sessions)
+}
+
+func (m *Memory) sweep() {
+	now := m.now()
+	for id, e := range m.sessions {
+		if !now.Before(e.expiresAt) {
+			delete(m.sessions, id)
+		}
+	}
+}
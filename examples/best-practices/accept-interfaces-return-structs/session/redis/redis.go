@@ -0,0 +1,63 @@
+// Package redis is a session.Store in Redis, for sessions shared by every
+// instance of a service and kept across restarts.
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/session"
+)
+
+const keyPrefix = "session:"
+
+// Store keeps each session as a JSON string under "session:<id>", with the
+// ttl it is saved with as the key's expiry, so Redis drops abandoned
+// sessions on its own.
+type Store struct {
+	client goredis.Cmdable
+}
+
+func New(client goredis.Cmdable) *Store {
+	return &Store{client: client}
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*session.Session, error) {
+	b, err := s.client.Get(ctx, key(id)).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil, errs.Wrap("redis.Store.Get", errs.ErrNotFound)
+	}
+	if err != nil {
+		return nil, errs.Wrap("redis.Store.Get", err)
+	}
+	var sess session.Session
+	if err := json.Unmarshal(b, &sess); err != nil {
+		return nil, errs.Wrap("redis.Store.Get", err)
+	}
+	return &sess, nil
+}
+
+func (s *Store) Save(ctx context.Context, sess *session.Session, ttl time.Duration) error {
+	// an expiry of 0 is no expiry to Redis, not an expired session
+	if ttl <= 0 {
+		return errs.Wrap("redis.Store.Save", s.Delete(ctx, sess.ID))
+	}
+	b, err := json.Marshal(sess)
+	if err != nil {
+		return errs.Wrap("redis.Store.Save", err)
+	}
+	return errs.Wrap("redis.Store.Save", s.client.Set(ctx, key(sess.ID), b, ttl).Err())
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	return errs.Wrap("redis.Store.Delete", s.client.Del(ctx, key(id)).Err())
+}
+
+func key(id string) string {
+	return keyPrefix + id
+}
@@ -0,0 +1,83 @@
+// Package session keeps logged in users' sessions on the server, with only
+// an encrypted, authenticated session ID in the browser's cookie:
+//
+//	codec, _ := session.NewCodec(key)
+//	sessions := session.New(session.NewMemory(), codec)
+//	mux.Handle("/", sessions.Middleware()(app))
+//
+// Start makes a session at login and sets the cookie, End deletes it at
+// logout, and the middleware loads it on every request for From to read.
+// Keeping the session server side is what lets End revoke it outright, a
+// copied cookie stops working the moment the session is gone.
+//
+// A session expires after the idle timeout without a request, and after
+// the absolute timeout however active it is, whichever comes first. Stores
+// are handed the time left, so one with expiry of its own such as Redis
+// cleans up after abandoned sessions.
+package session
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+)
+
+// Session is one user's login. It is not safe for concurrent use, it
+// belongs to the request that loaded it.
+type Session struct {
+	ID        string            `json:"id"`
+	UserID    string            `json:"user_id"`
+	Roles     []string          `json:"roles,omitempty"`
+	Values    map[string]string `json:"values,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	LastSeen  time.Time         `json:"last_seen"`
+
+	// changed since it was loaded, the middleware saves it after the handler
+	dirty bool
+}
+
+func (s *Session) Get(key string) string {
+	return s.Values[key]
+}
+
+func (s *Session) Set(key, value string) {
+	if s.Values == nil {
+		s.Values = make(map[string]string)
+	}
+	s.Values[key] = value
+	s.dirty = true
+}
+
+func (s *Session) Delete(key string) {
+	delete(s.Values, key)
+	s.dirty = true
+}
+
+// Clone copies s, Roles and Values included, for stores that keep sessions in memory.
+func (s *Session) Clone() *Session {
+	c := *s
+	c.Roles = slices.Clone(s.Roles)
+	c.Values = maps.Clone(s.Values)
+	c.dirty = false
+	return &c
+}
+
+// Store keeps sessions by ID.
+type Store interface {
+	// Get fails with errs.ErrNotFound for an unknown or expired session.
+	Get(ctx context.Context, id string) (*Session, error)
+	// Save creates or replaces s, which may be dropped once ttl has passed.
+	Save(ctx context.Context, s *Session, ttl time.Duration) error
+	// Delete is a no-op for an unknown session.
+	Delete(ctx context.Context, id string) error
+}
+
+var sessionKey = ctxutil.NewKey[*Session]("session")
+
+// From returns the session the middleware loaded for the request.
+func From(ctx context.Context) (*Session, bool) {
+	return sessionKey.From(ctx)
+}
@@ -0,0 +1,169 @@
+// Package shutdown collects a main's cleanup work in one place. Components
+// register a cleanup as they are set up, Wait blocks until SIGINT or
+// SIGTERM and then runs the cleanups in reverse order: what started last
+// stops first, so the HTTP server drains before the store it writes to is
+// closed, and the store closes before the tracer flushes.
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Func is one cleanup. It should return by the time its context is done.
+type Func func(ctx context.Context) error
+
+// Closer adapts an io.Closer, a store or a client, to a Func.
+func Closer(c io.Closer) Func {
+	return func(context.Context) error { return c.Close() }
+}
+
+// Option configures a Stack.
+type Option func(*Stack)
+
+// WithTimeout bounds the whole cleanup, the default is 15 seconds. The
+// cleanups' context ends then, and a process still cleaning up a second
+// later is killed.
+func WithTimeout(d time.Duration) Option {
+	return func(s *Stack) {
+		s.timeout = d
+	}
+}
+
+// WithSignals sets the signals Wait listens for, the default is SIGINT and
+// SIGTERM.
+func WithSignals(signals ...os.Signal) Option {
+	return func(s *Stack) {
+		s.signals = signals
+	}
+}
+
+// WithLogger sets the logger for the cleanup progress, the default is
+// slog.Default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Stack) {
+		s.logger = logger
+	}
+}
+
+// killGrace is how long past the timeout a stuck cleanup is tolerated
+// before the process exits.
+const killGrace = time.Second
+
+// Stack is safe for concurrent use.
+type Stack struct {
+	timeout time.Duration
+	signals []os.Signal
+	logger  *slog.Logger
+	// exit ends the process on a second signal or a stuck cleanup
+	exit func(code int)
+
+	mu      sync.Mutex
+	cleanup []entry
+	once    sync.Once
+	err     error
+}
+
+type entry struct {
+	name string
+	fn   Func
+}
+
+func New(opts ...Option) *Stack {
+	s := &Stack{
+		timeout: 15 * time.Second,
+		signals: []os.Signal{syscall.SIGINT, syscall.SIGTERM},
+		logger:  slog.Default(),
+		exit:    os.Exit,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Add pushes a cleanup, it runs before every cleanup added earlier.
+func (s *Stack) Add(name string, fn Func) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cleanup = append(s.cleanup, entry{name: name, fn: fn})
+}
+
+// Wait blocks until one of the signals arrives or ctx is done, then runs
+// Shutdown. A second signal while cleaning up exits the process at once
+// with status 1, for an operator who has stopped waiting.
+func (s *Stack) Wait(ctx context.Context) error {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, s.signals...)
+	defer signal.Stop(sigs)
+	select {
+	case sig := <-sigs:
+		s.logger.Info("shutdown signal received", slog.String("signal", sig.String()))
+	case <-ctx.Done():
+		s.logger.Info("shutdown requested", slog.String("reason", ctx.Err().Error()))
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case sig := <-sigs:
+			s.logger.Error("second signal, exiting now", slog.String("signal", sig.String()))
+			s.exit(1)
+		case <-done:
+		}
+	}()
+	return s.Shutdown()
+}
+
+// Shutdown runs every cleanup once, last added first, even when an earlier
+// one fails, and returns their errors joined. Cleanups still running past
+// the timeout plus a second's grace end the process with status 1. Calls
+// after the first return the same result.
+func (s *Stack) Shutdown() error {
+	s.once.Do(func() {
+		s.mu.Lock()
+		cleanup := s.cleanup
+		s.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		defer cancel()
+		kill := time.AfterFunc(s.timeout+killGrace, func() {
+			s.logger.Error("cleanup did not finish, exiting", slog.Duration("timeout", s.timeout))
+			s.exit(1)
+		})
+		defer kill.Stop()
+
+		var errs []error
+		for i := len(cleanup) - 1; i >= 0; i-- {
+			e := cleanup[i]
+			start := time.Now()
+			if err := run(ctx, e.fn); err != nil {
+				s.logger.Error("cleanup failed", slog.String("name", e.name), slog.String("error", err.Error()))
+				errs = append(errs, fmt.Errorf("shutdown: %s: %w", e.name, err))
+				continue
+			}
+			s.logger.Info("cleanup done", slog.String("name", e.name), slog.Duration("duration", time.Since(start)))
+		}
+		s.err = errors.Join(errs...)
+	})
+	return s.err
+}
+
+// run calls fn, recovering a panic so the cleanups after it still run.
+func run(ctx context.Context, fn Func) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
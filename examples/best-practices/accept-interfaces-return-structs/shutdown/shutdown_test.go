@@ -0,0 +1,118 @@
+package shutdown_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/shutdown"
+)
+
+// TestMain fails the package if Wait leaves its signal goroutine behind.
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+var errBoom = errors.New("boom")
+
+func newStack(opts ...shutdown.Option) *shutdown.Stack {
+	return shutdown.New(append([]shutdown.Option{shutdown.WithLogger(slog.New(slog.DiscardHandler))}, opts...)...)
+}
+
+// trace records the order the cleanups ran in.
+type trace struct {
+	ran []string
+}
+
+func (tr *trace) step(name string, err error) shutdown.Func {
+	return func(context.Context) error {
+		tr.ran = append(tr.ran, name)
+		return err
+	}
+}
+
+type closer struct{ closed bool }
+
+func (c *closer) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestReverseOrder(t *testing.T) {
+	s := newStack()
+	tr := &trace{}
+	db := &closer{}
+	s.Add("tracer", tr.step("tracer", nil))
+	s.Add("store", shutdown.Closer(db))
+	s.Add("cache", tr.step("cache", errBoom))
+	s.Add("broken", func(context.Context) error { panic("broken") })
+	s.Add("server", tr.step("server", nil))
+
+	err := s.Shutdown()
+	if want := []string{"server", "cache", "tracer"}; !slices.Equal(tr.ran, want) {
+		t.Errorf("ran %v, want %v", tr.ran, want)
+	}
+	if !db.closed {
+		t.Error("store not closed")
+	}
+	if !errors.Is(err, errBoom) || !strings.Contains(err.Error(), "shutdown: cache: boom") || !strings.Contains(err.Error(), "shutdown: broken: panic: broken") {
+		t.Errorf("Shutdown: err = %v, want cache's boom and broken's panic", err)
+	}
+
+	// once only, the same result again
+	if again := s.Shutdown(); again != err || len(tr.ran) != 3 {
+		t.Errorf("second Shutdown = %v after %d cleanups, want %v and no reruns", again, len(tr.ran), err)
+	}
+}
+
+// TestDeadline gives every cleanup a context ending at the timeout, a
+// cleanup waiting on it returns then and the ones after it still run.
+func TestDeadline(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	s := newStack(shutdown.WithTimeout(timeout))
+	tr := &trace{}
+	s.Add("after", tr.step("after", nil))
+	var deadline time.Time
+	var hasDeadline bool
+	s.Add("slow", func(ctx context.Context) error {
+		deadline, hasDeadline = ctx.Deadline()
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	start := time.Now()
+	err := s.Shutdown()
+	elapsed := time.Since(start)
+	if d := deadline.Sub(start); !hasDeadline || d < timeout || d > timeout+100*time.Millisecond {
+		t.Errorf("cleanup deadline %v after the start, want about %v", d, timeout)
+	}
+	if elapsed < timeout || elapsed > timeout+500*time.Millisecond {
+		t.Errorf("Shutdown took %v, want about %v", elapsed, timeout)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown: err = %v, want slow's deadline", err)
+	}
+	if !slices.Equal(tr.ran, []string{"after"}) {
+		t.Errorf("ran %v, want the cleanup after the slow one too", tr.ran)
+	}
+}
+
+// TestWait runs the cleanups once ctx ends. A signal is not sent: one
+// arriving before Wait listens, or a second one, would end the test binary.
+func TestWait(t *testing.T) {
+	s := newStack(shutdown.WithSignals(syscall.SIGUSR1))
+	tr := &trace{}
+	s.Add("server", tr.step("server", nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.Wait(ctx); err != nil || !slices.Equal(tr.ran, []string{"server"}) {
+		t.Errorf("Wait = %v after %v, want nil after server", err, tr.ran)
+	}
+}
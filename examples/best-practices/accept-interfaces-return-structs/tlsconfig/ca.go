@@ -0,0 +1,149 @@
+package tlsconfig
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// certTTL is short on purpose, these certificates are for tests and local
+// development and should not outlive the run that made them.
+const certTTL = 24 * time.Hour
+
+// CA is a throwaway certificate authority that lives in memory, for tests
+// and local development. Production certificates come from a real CA or
+// an internal PKI, never from code like this.
+type CA struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// NewCA makes a self-signed root that can sign leaf certificates but no
+// intermediates.
+func NewCA(commonName string) (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errs.Wrap("tlsconfig.NewCA", err)
+	}
+	tmpl, err := template(commonName)
+	if err != nil {
+		return nil, errs.Wrap("tlsconfig.NewCA", err)
+	}
+	tmpl.IsCA = true
+	tmpl.BasicConstraintsValid = true
+	tmpl.MaxPathLenZero = true
+	tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return nil, errs.Wrap("tlsconfig.NewCA", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, errs.Wrap("tlsconfig.NewCA", err)
+	}
+	return &CA{cert: cert, key: key}, nil
+}
+
+// Pool holds just this CA, for tls.Config.RootCAs on clients and ClientCAs
+// on servers.
+func (ca *CA) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+// CertPEM is the CA certificate in PEM, what curl --cacert reads.
+func (ca *CA) CertPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+}
+
+// IssueServer signs a certificate for hosts, each a DNS name or an IP
+// address. Clients check the name they dialed against these subject
+// alternative names, the common name has not counted since Go 1.15.
+func (ca *CA) IssueServer(hosts ...string) (tls.Certificate, error) {
+	if len(hosts) == 0 {
+		return tls.Certificate{}, errs.Wrap("tlsconfig.CA.IssueServer", errs.ErrInvalidInput)
+	}
+	tmpl, err := template(hosts[0])
+	if err != nil {
+		return tls.Certificate{}, errs.Wrap("tlsconfig.CA.IssueServer", err)
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	cert, err := ca.issue(tmpl)
+	return cert, errs.Wrap("tlsconfig.CA.IssueServer", err)
+}
+
+// IssueClient signs a certificate a client presents for mutual TLS,
+// identified by commonName. It is only good for client authentication, a
+// server refuses a server certificate offered in its place.
+func (ca *CA) IssueClient(commonName string) (tls.Certificate, error) {
+	tmpl, err := template(commonName)
+	if err != nil {
+		return tls.Certificate{}, errs.Wrap("tlsconfig.CA.IssueClient", err)
+	}
+	tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	cert, err := ca.issue(tmpl)
+	return cert, errs.Wrap("tlsconfig.CA.IssueClient", err)
+}
+
+func (ca *CA) issue(tmpl *x509.Certificate) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+func template(commonName string) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		// a little slack for clocks that are slightly behind
+		NotBefore: now.Add(-time.Minute),
+		NotAfter:  now.Add(certTTL),
+	}, nil
+}
+
+// EncodePEM returns cert's chain and private key in PEM, to hand to tools
+// such as curl --cert and --key.
+func EncodePEM(cert tls.Certificate) (certPEM, keyPEM []byte, err error) {
+	for _, der := range cert.Certificate {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		return nil, nil, errs.Wrap("tlsconfig.EncodePEM", err)
+	}
+	return certPEM, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
@@ -0,0 +1,165 @@
+package tlsconfig_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/tlsconfig"
+)
+
+// certs is a CA with a server and a client certificate, and a client
+// certificate from another CA.
+type certs struct {
+	ca             *tlsconfig.CA
+	server, client tls.Certificate
+	stranger       tls.Certificate
+}
+
+func newCerts(t *testing.T) certs {
+	t.Helper()
+	var c certs
+	var err error
+	must := func(cert tls.Certificate, err error) tls.Certificate {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	if c.ca, err = tlsconfig.NewCA("users-api dev CA"); err != nil {
+		t.Fatalf("NewCA: %v", err)
+	}
+	other, err := tlsconfig.NewCA("someone else's CA")
+	if err != nil {
+		t.Fatalf("NewCA: %v", err)
+	}
+	c.server = must(c.ca.IssueServer("localhost", "127.0.0.1"))
+	c.client = must(c.ca.IssueClient("billing-service"))
+	c.stranger = must(other.IssueClient("billing-service"))
+	return c
+}
+
+// serve serves whoami, the caller ClientCertPrincipal found, over config
+// and returns its URL on localhost.
+func serve(t *testing.T, config *tls.Config) string {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(tlsconfig.ClientCertPrincipal()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := ctxutil.PrincipalFrom(r.Context())
+		if !ok {
+			io.WriteString(w, "anonymous")
+			return
+		}
+		io.WriteString(w, p.Subject)
+	})))
+	srv.TLS = config
+	// the refused handshakes are the point, not worth logging
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	return "https://localhost:" + port
+}
+
+func whoami(config *tls.Config, url string) (string, error) {
+	resp, err := tlsconfig.HTTPClient(config).Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s = %s", url, resp.Status)
+	}
+	return strings.TrimSpace(string(body)), err
+}
+
+// TestTLS checks only clients that pin the CA, dial a name in the
+// certificate, and speak TLS 1.2 or later get through.
+func TestTLS(t *testing.T) {
+	c := newCerts(t)
+	url := serve(t, tlsconfig.Server(c.server))
+	if who, err := whoami(tlsconfig.Client(c.ca.Pool()), url); err != nil || who != "anonymous" {
+		t.Errorf("pinning the CA: whoami = %q, %v, want anonymous", who, err)
+	}
+	for _, tt := range []struct {
+		name   string
+		config *tls.Config
+		// want is the error refusing it, nil for any
+		want any
+	}{
+		{"the system roots", &tls.Config{MinVersion: tls.VersionTLS12}, &x509.UnknownAuthorityError{}},
+		{"a name missing from the certificate", tlsconfig.Client(c.ca.Pool(), tlsconfig.WithServerName("users.example.com")), &x509.HostnameError{}},
+		{"TLS 1.1", &tls.Config{RootCAs: c.ca.Pool(), MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS11}, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := whoami(tt.config, url)
+			if err == nil {
+				t.Fatal("connected, want a refusal")
+			}
+			if tt.want != nil && !errors.As(err, tt.want) {
+				t.Errorf("refused with %v, want a %T", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestMutualTLS checks the server only lets in clients with a client
+// certificate from its CA, and names them after it.
+func TestMutualTLS(t *testing.T) {
+	c := newCerts(t)
+	url := serve(t, tlsconfig.Server(c.server, tlsconfig.RequireClientCert(c.ca.Pool())))
+	if who, err := whoami(tlsconfig.Client(c.ca.Pool(), tlsconfig.WithClientCert(c.client)), url); err != nil || who != "billing-service" {
+		t.Errorf("whoami = %q, %v, want billing-service", who, err)
+	}
+
+	// Go's client only sends a certificate from a CA the server named,
+	// GetClientCertificate sends this one regardless so the server decides
+	stranger := tlsconfig.Client(c.ca.Pool())
+	stranger.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return &c.stranger, nil
+	}
+	for _, tt := range []struct {
+		name   string
+		config *tls.Config
+	}{
+		{"no certificate", tlsconfig.Client(c.ca.Pool())},
+		{"a certificate from another CA", stranger},
+		{"a server certificate", tlsconfig.Client(c.ca.Pool(), tlsconfig.WithClientCert(c.server))},
+	} {
+		if _, err := whoami(tt.config, url); err == nil {
+			t.Errorf("%s: connected, want a refusal", tt.name)
+		}
+	}
+}
+
+func TestEncodePEM(t *testing.T) {
+	c := newCerts(t)
+	certPEM, keyPEM, err := tlsconfig.EncodePEM(c.client)
+	if err != nil {
+		t.Fatalf("EncodePEM: %v", err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("X509KeyPair: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{Roots: c.ca.Pool(), KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+		t.Errorf("the decoded certificate does not verify against the CA: %v", err)
+	}
+	if !x509.NewCertPool().AppendCertsFromPEM(c.ca.CertPEM()) {
+		t.Error("CertPEM is not a PEM certificate")
+	}
+}
@@ -0,0 +1,116 @@
+// Package analyzer is a go/analysis analyzer for the rule this module is
+// named after: accept interfaces, return structs. It reports exported
+// constructors, functions named New or New followed by an upper case
+// letter, that return an interface. A caller handed an interface can only
+// use the methods it names, and the package can never add one without
+// breaking every other implementation. Returning the concrete type costs
+// nothing, it still satisfies the interface wherever one is accepted.
+//
+// error is exempt, and so is a constructor whose doc comment has a line
+//
+//	//returnstructs:allow <why>
+//
+// for the times an interface is the point: a factory choosing the
+// implementation at run time, or a type that must stay hidden.
+//
+// cmd/returnstructs runs it on its own or as go vet's -vettool.
+package analyzer
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// allowDirective opts a constructor out, followed by the reason.
+const allowDirective = "//returnstructs:allow"
+
+// Analyzer reports exported constructors returning an interface.
+var Analyzer = &analysis.Analyzer{
+	Name: "returnstructs",
+	Doc:  "report exported constructors that return interfaces instead of concrete types",
+	Run:  run,
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	if pass.Pkg.Name() == "main" {
+		// nothing outside can call a main package's constructors
+		return nil, nil
+	}
+	for _, file := range pass.Files {
+		if ast.IsGenerated(file) {
+			continue
+		}
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || !isConstructor(fn.Name.Name) || allowed(fn.Doc) {
+				continue
+			}
+			obj, ok := pass.TypesInfo.Defs[fn.Name].(*types.Func)
+			if !ok {
+				continue
+			}
+			results := obj.Type().(*types.Signature).Results()
+			for i := range results.Len() {
+				t := results.At(i).Type()
+				if !returnsInterface(t) {
+					continue
+				}
+				pass.Reportf(fn.Name.Pos(), "exported constructor %s returns interface %s, return the concrete type",
+					fn.Name.Name, types.TypeString(t, qualifier(pass.Pkg)))
+				break
+			}
+		}
+	}
+	return nil, nil
+}
+
+// isConstructor reports whether name is New or New followed by an upper
+// case letter, so NewUser but not Newline.
+func isConstructor(name string) bool {
+	rest, ok := strings.CutPrefix(name, "New")
+	if !ok {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(rest)
+	return rest == "" || unicode.IsUpper(r)
+}
+
+// returnsInterface is true for interfaces other than error. A type
+// parameter is the caller's choice of type, not an interface handed back.
+func returnsInterface(t types.Type) bool {
+	if _, ok := t.(*types.TypeParam); ok {
+		return false
+	}
+	if types.Identical(t, types.Universe.Lookup("error").Type()) {
+		return false
+	}
+	return types.IsInterface(t)
+}
+
+// qualifier names other packages by name rather than path, as the source
+// does.
+func qualifier(pkg *types.Package) types.Qualifier {
+	return func(other *types.Package) string {
+		if other == pkg {
+			return ""
+		}
+		return other.Name()
+	}
+}
+
+func allowed(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if c.Text == allowDirective || strings.HasPrefix(c.Text, allowDirective+" ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+package analyzer_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/tools/analyzer"
+)
+
+// TestAnalyzer runs the cases in testdata/src, whose want comments are the
+// diagnostics that must be reported and where.
+func TestAnalyzer(t *testing.T) {
+	for _, tt := range []struct {
+		name, pkg string
+	}{
+		{"constructors returning interfaces", "a"},
+		{"main package not checked", "b"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			analysistest.Run(t, analysistest.TestData(), analyzer.Analyzer, tt.pkg)
+		})
+	}
+}
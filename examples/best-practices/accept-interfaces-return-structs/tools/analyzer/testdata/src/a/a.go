@@ -0,0 +1,48 @@
+package a
+
+import (
+	"io"
+	"strings"
+)
+
+type Store interface {
+	Get(id string) (string, error)
+}
+
+type memory struct{}
+
+func (memory) Get(id string) (string, error) { return "", nil }
+
+type Memory struct{}
+
+func (Memory) Get(id string) (string, error) { return "", nil }
+
+func NewStore() Store { return memory{} } // want `exported constructor NewStore returns interface Store, return the concrete type`
+
+func NewStoreOrError() (Store, error) { return memory{}, nil } // want `exported constructor NewStoreOrError returns interface Store`
+
+func NewReader(s string) io.Reader { return strings.NewReader(s) } // want `exported constructor NewReader returns interface io.Reader`
+
+func New() any { return Memory{} } // want `exported constructor New returns interface any`
+
+// the second result counts too
+func NewPair() (*Memory, Store) { return &Memory{}, memory{} } // want `exported constructor NewPair returns interface Store`
+
+// NewFactory picks the implementation at run time.
+//
+//returnstructs:allow the implementation depends on the kind
+func NewFactory(kind string) Store { return memory{} }
+
+func NewMemory() *Memory { return &Memory{} }
+
+func NewMemoryOrError() (Memory, error) { return Memory{}, nil }
+
+func NewOf[T Store](t T) T { return t }
+
+func newStore() Store { return memory{} }
+
+func Newline() Store { return memory{} }
+
+func Open() Store { return memory{} }
+
+func (Memory) NewStore() Store { return memory{} }
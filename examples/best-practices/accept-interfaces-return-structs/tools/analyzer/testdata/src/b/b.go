@@ -0,0 +1,14 @@
+// a main package is nobody's dependency, its constructors are not checked
+package main
+
+type Store interface {
+	Get(id string) (string, error)
+}
+
+type memory struct{}
+
+func (memory) Get(id string) (string, error) { return "", nil }
+
+func NewStore() Store { return memory{} }
+
+func main() {}
@@ -0,0 +1,62 @@
+// Package tracing sets up OpenTelemetry for the example binaries: a
+// TracerProvider exporting to stdout or an OTLP collector, registered
+// globally together with the W3C trace context propagator so otelhttp and
+// otelgrpc continue traces started by the caller.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// Exporter names where spans are sent.
+type Exporter string
+
+const (
+	// None keeps the global no-op provider, spans cost next to nothing.
+	None Exporter = ""
+	// Stdout pretty prints each span as JSON, handy for a quick look.
+	Stdout Exporter = "stdout"
+	// OTLP sends spans over HTTP to a collector. The endpoint comes from
+	// OTEL_EXPORTER_OTLP_ENDPOINT, localhost:4318 when unset.
+	OTLP Exporter = "otlp"
+)
+
+// Setup installs a global TracerProvider for service and returns a shutdown
+// func that flushes buffered spans, call it before the process exits.
+func Setup(ctx context.Context, service string, exporter Exporter) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	var exp sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case None:
+		return func(context.Context) error { return nil }, nil
+	case Stdout:
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	case OTLP:
+		exp, err = otlptracehttp.New(ctx)
+	default:
+		return nil, errs.Wrap("tracing.Setup", fmt.Errorf("unknown exporter %q: %w", exporter, errs.ErrInvalidInput))
+	}
+	if err != nil {
+		return nil, errs.Wrap("tracing.Setup", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(service)))
+	if err != nil {
+		return nil, errs.Wrap("tracing.Setup", err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
@@ -0,0 +1,219 @@
+// Package graphqltransport serves UserService over GraphQL with
+// graph-gophers/graphql-go, which binds schema.graphql to plain Go resolver
+// methods by reflection, no code generation step. Resolvers wrap
+// service.User rather than exposing it, the same separation the HTTP and
+// gRPC transports keep with their DTOs.
+package graphqltransport
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+//go:embed schema.graphql
+var schema string
+
+// UserService is what the resolvers need from *service.UserService.
+type UserService interface {
+	CreateUser(ctx context.Context, user *service.User) error
+	RetrieveUser(ctx context.Context, id string, opts ...service.ReadOption) (*service.User, error)
+	UpdateUser(ctx context.Context, user *service.User) error
+	ListUsers(ctx context.Context, req service.PageRequest, opts ...service.ReadOption) (service.Page[service.User], error)
+}
+
+const (
+	loaderWait     = 2 * time.Millisecond
+	loaderMaxBatch = 100
+)
+
+// NewHandler parses the schema against the resolvers, failing at startup
+// rather than on the first query if the two disagree, and serves it at
+// whatever path the caller mounts it on. Every request gets a fresh Loader.
+func NewHandler(users UserService) (http.HandlerFunc, error) {
+	s, err := graphql.ParseSchema(schema, &resolver{users: users})
+	if err != nil {
+		return nil, errs.Wrap("graphqltransport.NewHandler", err)
+	}
+	h := &relay.Handler{Schema: s}
+	return func(w http.ResponseWriter, r *http.Request) {
+		loader := NewLoader(batchRetrieve(users), loaderWait, loaderMaxBatch)
+		h.ServeHTTP(w, r.WithContext(loaderKey.With(r.Context(), loader)))
+	}, nil
+}
+
+var loaderKey = ctxutil.NewKey[*Loader[string, *service.User]]("graphql.loader")
+
+func loaderFrom(ctx context.Context) *Loader[string, *service.User] {
+	loader, _ := loaderKey.From(ctx)
+	return loader
+}
+
+// batchRetrieve is the loader's BatchFunc. The keys arrive deduplicated, a
+// store with a multi-get would take them in one query here, UserService only
+// has RetrieveUser so this still costs one call per distinct ID.
+func batchRetrieve(users UserService) BatchFunc[string, *service.User] {
+	return func(ctx context.Context, ids []string) ([]*service.User, []error) {
+		found := make([]*service.User, len(ids))
+		failed := make([]error, len(ids))
+		for i, id := range ids {
+			found[i], failed[i] = users.RetrieveUser(ctx, id)
+		}
+		return found, failed
+	}
+}
+
+type resolver struct {
+	users UserService
+}
+
+func (r *resolver) User(ctx context.Context, args struct{ ID graphql.ID }) (*userResolver, error) {
+	var user *service.User
+	var err error
+	if loader := loaderFrom(ctx); loader != nil {
+		user, err = loader.Load(ctx, string(args.ID))
+	} else {
+		user, err = r.users.RetrieveUser(ctx, string(args.ID))
+	}
+	if errors.Is(err, errs.ErrNotFound) {
+		// a missing user is a null field, not a failed query
+		return nil, nil
+	}
+	if err != nil {
+		return nil, toError(err)
+	}
+	return &userResolver{user: user}, nil
+}
+
+func (r *resolver) Users(ctx context.Context, args struct {
+	First *int32
+	After *string
+}) (*connectionResolver, error) {
+	req := service.PageRequest{}
+	if args.First != nil {
+		req.Limit = int(*args.First)
+	}
+	if args.After != nil {
+		req.Cursor = *args.After
+	}
+	page, err := r.users.ListUsers(ctx, req)
+	if err != nil {
+		return nil, toError(err)
+	}
+	// prime the loader so user(id:) lookups later in the same query are free
+	loader := loaderFrom(ctx)
+	conn := &connectionResolver{nodes: make([]*userResolver, len(page.Items)), next: page.NextCursor}
+	for i := range page.Items {
+		user := &page.Items[i]
+		if loader != nil {
+			loader.Prime(user.ID, user)
+		}
+		conn.nodes[i] = &userResolver{user: user}
+	}
+	return conn, nil
+}
+
+type createUserInput struct {
+	ID    *graphql.ID
+	Email string
+}
+
+func (r *resolver) CreateUser(ctx context.Context, args struct{ Input createUserInput }) (*userResolver, error) {
+	user := &service.User{Email: args.Input.Email}
+	if args.Input.ID != nil {
+		user.ID = string(*args.Input.ID)
+	}
+	if err := r.users.CreateUser(ctx, user); err != nil {
+		return nil, toError(err)
+	}
+	return &userResolver{user: user}, nil
+}
+
+type updateUserInput struct {
+	ID      graphql.ID
+	Email   string
+	Version int32
+}
+
+func (r *resolver) UpdateUser(ctx context.Context, args struct{ Input updateUserInput }) (*userResolver, error) {
+	user := &service.User{ID: string(args.Input.ID), Email: args.Input.Email, Version: int64(args.Input.Version)}
+	if err := r.users.UpdateUser(ctx, user); err != nil {
+		return nil, toError(err)
+	}
+	stored, err := r.users.RetrieveUser(ctx, user.ID)
+	if err != nil {
+		return nil, toError(err)
+	}
+	return &userResolver{user: stored}, nil
+}
+
+type userResolver struct {
+	user *service.User
+}
+
+func (u *userResolver) ID() graphql.ID { return graphql.ID(u.user.ID) }
+func (u *userResolver) Email() string  { return u.user.Email }
+
+// Version is an Int, GraphQL's only integer type is 32 bits.
+func (u *userResolver) Version() int32 { return int32(u.user.Version) }
+
+func (u *userResolver) CreatedAt() graphql.Time { return graphql.Time{Time: u.user.CreatedAt} }
+func (u *userResolver) UpdatedAt() graphql.Time { return graphql.Time{Time: u.user.UpdatedAt} }
+
+type connectionResolver struct {
+	nodes []*userResolver
+	next  string
+}
+
+func (c *connectionResolver) Nodes() []*userResolver { return c.nodes }
+
+func (c *connectionResolver) NextCursor() *string {
+	if c.next == "" {
+		return nil
+	}
+	return &c.next
+}
+
+// Error carries the errs kind to clients as extensions.code, GraphQL's
+// stand-in for a status code since every response is a 200.
+type Error struct {
+	err  error
+	code string
+}
+
+func (e *Error) Error() string { return e.err.Error() }
+func (e *Error) Unwrap() error { return e.err }
+
+func (e *Error) Extensions() map[string]any {
+	return map[string]any{"code": e.code}
+}
+
+func toError(err error) error {
+	code := "INTERNAL"
+	switch {
+	case errors.Is(err, errs.ErrNotFound):
+		code = "NOT_FOUND"
+	case errors.Is(err, errs.ErrVersionConflict):
+		code = "VERSION_CONFLICT"
+	case errors.Is(err, errs.ErrConflict):
+		code = "CONFLICT"
+	case errors.Is(err, errs.ErrInvalidInput):
+		code = "INVALID_INPUT"
+	case errors.Is(err, errs.ErrUnauthenticated):
+		code = "UNAUTHENTICATED"
+	case errors.Is(err, errs.ErrForbidden):
+		code = "FORBIDDEN"
+	case errors.Is(err, errs.ErrUnavailable):
+		code = "UNAVAILABLE"
+	}
+	return &Error{err: err, code: code}
+}
@@ -0,0 +1,91 @@
+package graphqltransport_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	graphqltransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/graphql"
+)
+
+// countingStore counts the Gets that reach it and fails the user "broken".
+type countingStore struct {
+	*db.MemoryStore
+	gets atomic.Int64
+}
+
+func (s *countingStore) Get(ctx context.Context, id string) (*service.User, error) {
+	s.gets.Add(1)
+	if id == "broken" {
+		return nil, errs.Wrap("countingStore.Get", errs.ErrUnavailable)
+	}
+	return s.MemoryStore.Get(ctx, id)
+}
+
+type response struct {
+	Data   map[string]*struct{ Email string }
+	Errors []struct {
+		Message    string
+		Path       []string
+		Extensions struct{ Code string }
+	}
+}
+
+func query(t *testing.T, h http.Handler, q string) response {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"query": q})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body))))
+	var resp response
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return resp
+}
+
+// TestLookupsBatched resolves several user(id:) fields in one query: one
+// Get per distinct ID, and a failed or missing ID only costs its own field.
+func TestLookupsBatched(t *testing.T) {
+	store := &countingStore{MemoryStore: db.NewMemoryStore()}
+	users := service.NewUserService(store)
+	for _, u := range []*service.User{{ID: "1", Email: "ada@example.com"}, {ID: "2", Email: "grace@example.com"}} {
+		if err := users.CreateUser(t.Context(), u); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+	h, err := graphqltransport.NewHandler(users)
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+
+	resp := query(t, h, `{
+		a: user(id: "1") { email }
+		b: user(id: "1") { email }
+		c: user(id: "2") { email }
+		d: user(id: "missing") { email }
+		e: user(id: "broken") { email }
+	}`)
+	if n := store.gets.Load(); n != 4 {
+		t.Errorf("%d Gets for 5 lookups of 4 IDs, want 4", n)
+	}
+	for alias, want := range map[string]string{"a": "ada@example.com", "b": "ada@example.com", "c": "grace@example.com"} {
+		if got := resp.Data[alias]; got == nil || got.Email != want {
+			t.Errorf("%s = %+v, want %s", alias, got, want)
+		}
+	}
+	for _, alias := range []string{"d", "e"} {
+		if got, ok := resp.Data[alias]; !ok || got != nil {
+			t.Errorf("%s = %+v, want null", alias, got)
+		}
+	}
+	if len(resp.Errors) != 1 || strings.Join(resp.Errors[0].Path, ".") != "e" || resp.Errors[0].Extensions.Code != "UNAVAILABLE" {
+		t.Errorf("errors = %+v, want one UNAVAILABLE at e", resp.Errors)
+	}
+}
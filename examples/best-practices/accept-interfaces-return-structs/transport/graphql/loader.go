@@ -0,0 +1,117 @@
+package graphqltransport
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BatchFunc loads many keys in one call. It returns one value and one error
+// per key, in the order of keys.
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) ([]V, []error)
+
+// Loader is a per-request dataloader. GraphQL resolves sibling fields
+// concurrently and each resolver asks for one key, without a loader every
+// `user(id:)` in a query is its own store round trip. Load instead parks
+// the caller for a short window, deduplicates the keys that arrived in it,
+// and resolves them all with one BatchFunc call. Results are cached for the
+// life of the loader, which is why a loader must never outlive its request.
+type Loader[K comparable, V any] struct {
+	fetch    BatchFunc[K, V]
+	wait     time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	cache   map[K]*result[V]
+	pending *batch[K, V]
+}
+
+type result[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+type batch[K comparable, V any] struct {
+	once    sync.Once
+	keys    []K
+	results []*result[V]
+}
+
+// NewLoader batches keys that arrive within wait of the first one, or
+// maxBatch keys, whichever comes first.
+func NewLoader[K comparable, V any](fetch BatchFunc[K, V], wait time.Duration, maxBatch int) *Loader[K, V] {
+	return &Loader[K, V]{
+		fetch:    fetch,
+		wait:     wait,
+		maxBatch: maxBatch,
+		cache:    make(map[K]*result[V]),
+	}
+}
+
+// Load returns the value for key, fetching it with the next batch unless an
+// earlier Load or Prime already has.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+	res, ok := l.cache[key]
+	if !ok {
+		res = &result[V]{done: make(chan struct{})}
+		l.cache[key] = res
+		if l.pending == nil {
+			l.pending = &batch[K, V]{}
+			b := l.pending
+			time.AfterFunc(l.wait, func() { l.dispatch(ctx, b) })
+		}
+		l.pending.keys = append(l.pending.keys, key)
+		l.pending.results = append(l.pending.results, res)
+		if len(l.pending.keys) >= l.maxBatch {
+			b := l.pending
+			l.pending = nil
+			go l.dispatch(ctx, b)
+		}
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-res.done:
+		return res.value, res.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// Prime caches a value fetched some other way, such as a list query, so
+// later Loads for it are free.
+func (l *Loader[K, V]) Prime(key K, value V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.cache[key]; ok {
+		return
+	}
+	res := &result[V]{done: make(chan struct{}), value: value}
+	close(res.done)
+	l.cache[key] = res
+}
+
+// dispatch runs b once, whether the timer or the size limit got there first.
+func (l *Loader[K, V]) dispatch(ctx context.Context, b *batch[K, V]) {
+	b.once.Do(func() {
+		l.mu.Lock()
+		if l.pending == b {
+			l.pending = nil
+		}
+		l.mu.Unlock()
+
+		values, errs := l.fetch(ctx, b.keys)
+		for i, res := range b.results {
+			if i < len(values) {
+				res.value = values[i]
+			}
+			if i < len(errs) {
+				res.err = errs[i]
+			}
+			close(res.done)
+		}
+	})
+}
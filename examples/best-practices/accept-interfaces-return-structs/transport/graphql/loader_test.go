@@ -0,0 +1,153 @@
+package graphqltransport_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	graphqltransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/graphql"
+)
+
+var errOdd = errors.New("odd key")
+
+// batches records every batch the loader makes and fails the odd keys.
+type batches struct {
+	mu    sync.Mutex
+	calls [][]int
+}
+
+func (b *batches) fetch(_ context.Context, keys []int) ([]string, []error) {
+	b.mu.Lock()
+	b.calls = append(b.calls, slices.Clone(keys))
+	b.mu.Unlock()
+	values := make([]string, len(keys))
+	failed := make([]error, len(keys))
+	for i, k := range keys {
+		if k%2 == 1 {
+			failed[i] = fmt.Errorf("key %d: %w", k, errOdd)
+			continue
+		}
+		values[i] = fmt.Sprint("v", k)
+	}
+	return values, failed
+}
+
+func (b *batches) got() [][]int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Clone(b.calls)
+}
+
+// loadAll calls Load for every key at once and returns the results in the
+// order of keys.
+func loadAll(ctx context.Context, loader *graphqltransport.Loader[int, string], keys []int) ([]string, []error) {
+	values := make([]string, len(keys))
+	failed := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i, k := range keys {
+		wg.Go(func() {
+			values[i], failed[i] = loader.Load(ctx, k)
+		})
+	}
+	wg.Wait()
+	return values, failed
+}
+
+func wantResults(t *testing.T, keys []int, values []string, failed []error) {
+	t.Helper()
+	for i, k := range keys {
+		if k%2 == 1 {
+			if !errors.Is(failed[i], errOdd) || values[i] != "" {
+				t.Errorf("Load(%d) = %q, %v, want errOdd", k, values[i], failed[i])
+			}
+			continue
+		}
+		if want := fmt.Sprint("v", k); failed[i] != nil || values[i] != want {
+			t.Errorf("Load(%d) = %q, %v, want %s", k, values[i], failed[i], want)
+		}
+	}
+}
+
+// TestLoaderBatches loads N keys at once, each twice: one batch call with
+// every key once, and each caller gets its own key's value or error.
+func TestLoaderBatches(t *testing.T) {
+	const n = 20
+	b := &batches{}
+	// the window is long, the batch goes out when the last distinct key fills it
+	loader := graphqltransport.NewLoader(b.fetch, time.Minute, n)
+	var keys, distinct []int
+	for k := range n {
+		keys = append(keys, k, k)
+		distinct = append(distinct, k)
+	}
+	values, failed := loadAll(t.Context(), loader, keys)
+
+	calls := b.got()
+	if len(calls) != 1 {
+		t.Fatalf("%d batch calls, want 1: %v", len(calls), calls)
+	}
+	if got := slices.Sorted(slices.Values(calls[0])); !slices.Equal(got, distinct) {
+		t.Errorf("batch keys = %v, want each of %v once", got, distinct)
+	}
+	wantResults(t, keys, values, failed)
+}
+
+// TestLoaderWindow sends a batch smaller than the limit when the window
+// ends, and answers later Loads of the same keys from the cache.
+func TestLoaderWindow(t *testing.T) {
+	b := &batches{}
+	loader := graphqltransport.NewLoader(b.fetch, 5*time.Millisecond, 100)
+	keys := []int{1, 2, 3, 2}
+	values, failed := loadAll(t.Context(), loader, keys)
+	wantResults(t, keys, values, failed)
+	values, failed = loadAll(t.Context(), loader, keys)
+	wantResults(t, keys, values, failed)
+
+	calls := b.got()
+	keysFetched := 0
+	for _, c := range calls {
+		keysFetched += len(c)
+	}
+	if keysFetched != 3 {
+		t.Errorf("batches %v, want 1, 2 and 3 fetched once each", calls)
+	}
+}
+
+func TestLoaderMaxBatch(t *testing.T) {
+	b := &batches{}
+	loader := graphqltransport.NewLoader(b.fetch, time.Minute, 2)
+	keys := []int{0, 2, 4, 6}
+	values, failed := loadAll(t.Context(), loader, keys)
+	wantResults(t, keys, values, failed)
+	if calls := b.got(); len(calls) != 2 {
+		t.Errorf("batches %v, want two of two keys", calls)
+	}
+}
+
+func TestLoaderPrime(t *testing.T) {
+	b := &batches{}
+	loader := graphqltransport.NewLoader(b.fetch, time.Millisecond, 100)
+	loader.Prime(2, "primed")
+	if v, err := loader.Load(t.Context(), 2); err != nil || v != "primed" {
+		t.Errorf("Load(2) = %q, %v, want primed", v, err)
+	}
+	if calls := b.got(); len(calls) != 0 {
+		t.Errorf("batches %v, want none for a primed key", calls)
+	}
+}
+
+// TestLoaderCanceled returns the caller to its context without waiting for
+// the batch.
+func TestLoaderCanceled(t *testing.T) {
+	b := &batches{}
+	loader := graphqltransport.NewLoader(b.fetch, time.Minute, 100)
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := loader.Load(ctx, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Load: err = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,124 @@
+// Package grpctransport serves UserService over gRPC. The generated
+// userspb types stop at this package: requests are converted to
+// service.User on the way in and back to userspb.User on the way out with
+// the mapping package, so neither the service nor the stores ever import
+// protobuf.
+package grpctransport
+
+//go:generate buf generate
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/mapping"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/grpc/userspb"
+)
+
+// UserService is what the server needs from *service.UserService.
+type UserService interface {
+	CreateUser(ctx context.Context, user *service.User) error
+	RetrieveUser(ctx context.Context, id string, opts ...service.ReadOption) (*service.User, error)
+	UpdateUser(ctx context.Context, user *service.User) error
+	DeleteUser(ctx context.Context, id string) error
+	ListUsers(ctx context.Context, req service.PageRequest, opts ...service.ReadOption) (service.Page[service.User], error)
+}
+
+// Server implements userspb.UsersServer over a UserService.
+type Server struct {
+	userspb.UnimplementedUsersServer
+	users UserService
+}
+
+func NewServer(users UserService) *Server {
+	return &Server{users: users}
+}
+
+func (s *Server) CreateUser(ctx context.Context, req *userspb.CreateUserRequest) (*userspb.User, error) {
+	user := mapping.CreateRequestToUser(req)
+	if err := s.users.CreateUser(ctx, user); err != nil {
+		return nil, toStatus(err)
+	}
+	return mapping.UserToProto(user), nil
+}
+
+func (s *Server) GetUser(ctx context.Context, req *userspb.GetUserRequest) (*userspb.User, error) {
+	user, err := s.users.RetrieveUser(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return mapping.UserToProto(user), nil
+}
+
+func (s *Server) UpdateUser(ctx context.Context, req *userspb.UpdateUserRequest) (*userspb.User, error) {
+	user := mapping.UpdateRequestToUser(req)
+	if err := s.users.UpdateUser(ctx, user); err != nil {
+		return nil, toStatus(err)
+	}
+	// re-read so the response carries the stored CreatedAt as well
+	stored, err := s.users.RetrieveUser(ctx, user.ID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return mapping.UserToProto(stored), nil
+}
+
+func (s *Server) DeleteUser(ctx context.Context, req *userspb.DeleteUserRequest) (*userspb.DeleteUserResponse, error) {
+	if err := s.users.DeleteUser(ctx, req.GetId()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &userspb.DeleteUserResponse{}, nil
+}
+
+func (s *Server) ListUsers(ctx context.Context, req *userspb.ListUsersRequest) (*userspb.ListUsersResponse, error) {
+	page, err := s.users.ListUsers(ctx, service.PageRequest{Cursor: req.GetPageToken(), Limit: int(req.GetPageSize())})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &userspb.ListUsersResponse{
+		Users:         make([]*userspb.User, len(page.Items)),
+		NextPageToken: page.NextCursor,
+	}
+	for i := range page.Items {
+		resp.Users[i] = mapping.UserToProto(&page.Items[i])
+	}
+	return resp, nil
+}
+
+// toStatus maps the errs taxonomy onto gRPC codes, the counterpart of
+// status in transport/http. A version conflict is ABORTED, which tells the
+// client to re-read and retry, rather than ALREADY_EXISTS.
+func toStatus(err error) error {
+	var code codes.Code
+	switch {
+	case errors.Is(err, errs.ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, errs.ErrVersionConflict):
+		code = codes.Aborted
+	case errors.Is(err, errs.ErrConflict):
+		code = codes.AlreadyExists
+	case errors.Is(err, errs.ErrInvalidInput):
+		code = codes.InvalidArgument
+	case errors.Is(err, errs.ErrUnauthenticated):
+		code = codes.Unauthenticated
+	case errors.Is(err, errs.ErrForbidden):
+		code = codes.PermissionDenied
+	case errors.Is(err, errs.ErrUnavailable):
+		code = codes.Unavailable
+	case errors.Is(err, errors.ErrUnsupported):
+		code = codes.Unimplemented
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	default:
+		// the operation chain is for logs, see LoggingInterceptor
+		return status.Error(codes.Internal, "internal error")
+	}
+	return status.Error(code, err.Error())
+}
@@ -0,0 +1,58 @@
+package httptransport
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/archive"
+)
+
+// exportArchive serves every user as an archive in format, users.csv and
+// a JSON file per user, written to the client as it is built.
+//
+// Like exportNDJSON, the status goes with the first byte. An error before
+// it gets the usual error response, one after it aborts the connection.
+// A truncated archive would not pass for a whole one anyway, a zip has its
+// directory at the end, but the client learns of it from the connection
+// rather than from its unarchiver. A client that stops reading is cut off
+// after stallTimeout without taking a write.
+func (h *Handler) exportArchive(format archive.Format) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		out := &archiveResponse{w: w, rc: http.NewResponseController(w), format: format}
+		err := archive.Write(r.Context(), out, h.users, format)
+		switch {
+		case err == nil:
+		case !out.started:
+			h.error(w, r, err)
+		default:
+			if r.Context().Err() == nil {
+				h.logger.ErrorContext(r.Context(), "export failed part way", slog.String("error", err.Error()))
+			}
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
+
+// archiveResponse holds off on the headers until the first write, as
+// csvResponse does, and gives each write its own deadline.
+type archiveResponse struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	format  archive.Format
+	started bool
+}
+
+func (a *archiveResponse) Write(p []byte) (int, error) {
+	if !a.started {
+		a.started = true
+		a.w.Header().Set("Content-Type", a.format.ContentType())
+		a.w.Header().Set("Content-Disposition", `attachment; filename="`+a.format.Filename()+`"`)
+		a.w.WriteHeader(http.StatusOK)
+	}
+	if err := a.rc.SetWriteDeadline(time.Now().Add(stallTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return 0, err
+	}
+	return a.w.Write(p)
+}
@@ -0,0 +1,167 @@
+package httptransport_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/archive"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	httptransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/http"
+)
+
+// archiveUsers is how many users the archive tests store, archivePages
+// how many pages an archive of them reads.
+const (
+	archiveUsers = 500
+	archivePages = 2 * (archiveUsers/100 + 1)
+)
+
+// archiveServer serves v1 and, under /v2, v2 over archiveUsers users.
+func archiveServer(t *testing.T) (*httptest.Server, *countingStore) {
+	t.Helper()
+	store := &countingStore{MemoryStore: db.NewMemoryStore()}
+	users := service.NewUserService(store)
+	batch := make([]*service.User, 0, archiveUsers)
+	for i := range archiveUsers {
+		id := fmt.Sprintf("user-%04d", i)
+		batch = append(batch, &service.User{ID: id, Email: id + "@example.com"})
+	}
+	if _, err := users.CreateUsers(context.Background(), batch); err != nil {
+		t.Fatalf("CreateUsers: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/v2/", http.StripPrefix("/v2", httptransport.NewV2Handler(users, quiet)))
+	mux.Handle("/", httptransport.NewHandler(users, quiet))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, store
+}
+
+// download GETs url and returns the response, the body, and the error
+// reading it.
+func download(t *testing.T, url string) (*http.Response, []byte, error) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return resp, body, err
+}
+
+// entries counts the files in an archive of format.
+func entries(format archive.Format, b []byte) (int, error) {
+	if format == archive.Zip {
+		zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			return 0, err
+		}
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			if err != nil {
+				return 0, err
+			}
+			_, err = io.Copy(io.Discard, rc)
+			rc.Close()
+			if err != nil {
+				return 0, err
+			}
+		}
+		return len(zr.File), nil
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return 0, err
+	}
+	tr := tar.NewReader(gz)
+	for n := 0; ; n++ {
+		if _, err := tr.Next(); errors.Is(err, io.EOF) {
+			return n, nil
+		} else if err != nil {
+			return 0, err
+		}
+		if _, err := io.Copy(io.Discard, tr); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func TestArchiveDownload(t *testing.T) {
+	srv, _ := archiveServer(t)
+	for _, tt := range []struct {
+		name, path string
+		format     archive.Format
+	}{
+		{"zip", "/users/export.zip", archive.Zip},
+		{"tar.gz", "/users/export.tar.gz", archive.TarGz},
+		{"v2", "/v2/users/export.zip", archive.Zip},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body, err := download(t, srv.URL+tt.path)
+			if err != nil {
+				t.Fatalf("reading the body: %v", err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status %d: %s", resp.StatusCode, body)
+			}
+			if got := resp.Header.Get("Content-Type"); got != tt.format.ContentType() {
+				t.Errorf("Content-Type = %q, want %q", got, tt.format.ContentType())
+			}
+			if got, want := resp.Header.Get("Content-Disposition"), `attachment; filename="`+tt.format.Filename()+`"`; got != want {
+				t.Errorf("Content-Disposition = %q, want %q", got, want)
+			}
+			if n, err := entries(tt.format, body); err != nil || n != archiveUsers+1 {
+				t.Errorf("unpacked %d files, %v, want users.csv and %d users", n, err, archiveUsers)
+			}
+		})
+	}
+}
+
+// TestArchiveFailurePartWay checks a store failing after the 200 has gone
+// out breaks the body off, rather than ending it like a complete archive.
+func TestArchiveFailurePartWay(t *testing.T) {
+	srv, store := archiveServer(t)
+	for _, format := range []archive.Format{archive.Zip, archive.TarGz} {
+		t.Run(string(format), func(t *testing.T) {
+			store.pages.Store(0)
+			// past users.csv, into the JSON files
+			store.failAfter.Store(archivePages/2 + 3)
+			defer store.failAfter.Store(0)
+			resp, body, err := download(t, srv.URL+"/users/export."+string(format))
+			if err == nil {
+				t.Fatalf("status %d and a whole body of %d bytes", resp.StatusCode, len(body))
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status %d, want the 200 sent before the failure", resp.StatusCode)
+			}
+			if _, err := entries(format, body); err == nil {
+				t.Error("the truncated archive unpacks")
+			}
+		})
+	}
+}
+
+// TestArchiveCannotList checks a failure before the first byte is still
+// an error status.
+func TestArchiveCannotList(t *testing.T) {
+	srv := httptest.NewServer(httptransport.NewHandler(service.NewUserService(noLister{db.NewMemoryStore()}), quiet))
+	defer srv.Close()
+	resp, body, err := download(t, srv.URL+"/users/export.zip")
+	if err != nil {
+		t.Fatalf("reading the body: %v", err)
+	}
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("status %d: %s, want 501", resp.StatusCode, body)
+	}
+}
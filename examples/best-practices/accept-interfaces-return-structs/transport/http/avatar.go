@@ -0,0 +1,201 @@
+package httptransport
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/blob"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+const (
+	// maxAvatarBytes bounds the image itself.
+	maxAvatarBytes = 1 << 20
+	// maxAvatarBodyBytes bounds the whole multipart body: the image, its
+	// part headers, the boundaries, and any other small fields a form
+	// sends along.
+	maxAvatarBodyBytes = maxAvatarBytes + 16<<10
+	// avatarField is the form field the image is uploaded in.
+	avatarField = "avatar"
+)
+
+// avatarTypes are the image types accepted, as http.DetectContentType
+// names them. SVG is left out on purpose, it is a document that can carry
+// script, not an image to serve back on the API's origin.
+var avatarTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// errAvatarTooLarge is an image over maxAvatarBytes, which may still fit
+// in the body limit.
+var errAvatarTooLarge = errors.New("avatar too large")
+
+// BlobStore is where avatars are kept, *blob.Disk and *blob.Memory have
+// it. Put must store nothing when r fails, an upload cut off by a limit
+// leaves the previous avatar in place.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, blob.Info, error)
+	Delete(ctx context.Context, key string) error
+}
+
+type HandlerOption func(*Handler)
+
+// WithAvatars serves avatars from blobs under /users/{id}/avatar:
+//
+//	PUT    /users/{id}/avatar  multipart/form-data, the image in the avatar field, 204
+//	GET    /users/{id}/avatar  the image
+//	DELETE /users/{id}/avatar  204
+//
+// Without it those routes are not registered.
+func WithAvatars(blobs BlobStore) HandlerOption {
+	return func(h *Handler) {
+		h.avatars = blobs
+	}
+}
+
+func avatarKey(id string) string {
+	return "avatars/" + id
+}
+
+// uploadAvatar streams the image part of the form straight into the blob
+// store, r.MultipartReader rather than r.ParseMultipartForm, which would
+// buffer the file to memory or a temporary file first. The type is
+// sniffed from the image's first bytes, the part's own Content-Type is
+// whatever the client says and is ignored.
+func (h *Handler) uploadAvatar(w http.ResponseWriter, r *http.Request) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "multipart/form-data" {
+		code := http.StatusUnsupportedMediaType
+		h.respond(w, r, code, errorResponse{Error: http.StatusText(code)})
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxAvatarBodyBytes)
+	user, err := h.users.RetrieveUser(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		h.error(w, r, errs.Wrap("httptransport.uploadAvatar", fmt.Errorf("%w: %s", errs.ErrInvalidInput, err)))
+		return
+	}
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			h.error(w, r, errs.Wrap("httptransport.uploadAvatar", fmt.Errorf("%w: no %s field", errs.ErrInvalidInput, avatarField)))
+			return
+		}
+		if err != nil {
+			h.bodyFailed(w, r, err)
+			return
+		}
+		if part.FormName() != avatarField {
+			// another field, NextPart skips what is left of it
+			continue
+		}
+		limited := &limitReader{r: part, n: maxAvatarBytes}
+		image := bufio.NewReaderSize(limited, 512)
+		// DetectContentType looks at no more than 512 bytes, a short
+		// Peek is an image smaller than that
+		head, err := image.Peek(512)
+		if err != nil && !errors.Is(err, io.EOF) {
+			h.bodyFailed(w, r, err)
+			return
+		}
+		if len(head) == 0 {
+			h.error(w, r, errs.Wrap("httptransport.uploadAvatar", fmt.Errorf("%w: empty %s", errs.ErrInvalidInput, avatarField)))
+			return
+		}
+		contentType := http.DetectContentType(head)
+		if !avatarTypes[contentType] {
+			code := http.StatusUnsupportedMediaType
+			h.respond(w, r, code, errorResponse{Error: http.StatusText(code)})
+			return
+		}
+		if err := h.avatars.Put(r.Context(), avatarKey(user.ID), image, contentType); err != nil {
+			if limited.err != nil {
+				// the body failed the store, not the other way around
+				h.bodyFailed(w, r, limited.err)
+				return
+			}
+			h.error(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+}
+
+// bodyFailed maps a failure reading the body: over either limit is a 413,
+// anything else a body that is not valid multipart or was cut short.
+func (h *Handler) bodyFailed(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) || errors.Is(err, errAvatarTooLarge) {
+		code := http.StatusRequestEntityTooLarge
+		h.respond(w, r, code, errorResponse{Error: http.StatusText(code)})
+		return
+	}
+	h.error(w, r, errs.Wrap("httptransport.uploadAvatar", fmt.Errorf("%w: malformed multipart body: %s", errs.ErrInvalidInput, err)))
+}
+
+func (h *Handler) retrieveAvatar(w http.ResponseWriter, r *http.Request) {
+	body, info, err := h.avatars.Get(r.Context(), avatarKey(r.PathValue("id")))
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+	defer body.Close()
+	w.Header().Set("Content-Type", info.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	// the type was sniffed on the way in, browsers must not guess another
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+	if _, err := io.Copy(w, body); err != nil {
+		h.logger.WarnContext(r.Context(), "send avatar", "error", err)
+	}
+}
+
+func (h *Handler) deleteAvatar(w http.ResponseWriter, r *http.Request) {
+	if err := h.avatars.Delete(r.Context(), avatarKey(r.PathValue("id"))); err != nil {
+		h.error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// limitReader fails with errAvatarTooLarge once more than n bytes are
+// read, where an io.LimitReader would end early as if the image did. err
+// keeps the first failure, so a failed Put can tell the body's fault from
+// the store's.
+type limitReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	if l.n -= int64(n); l.n < 0 {
+		err = errAvatarTooLarge
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		l.err = err
+	}
+	return n, err
+}
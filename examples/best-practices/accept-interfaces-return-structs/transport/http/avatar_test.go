@@ -0,0 +1,270 @@
+package httptransport_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/blob"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	httptransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/http"
+)
+
+// avatars serves the v2 API with avatars stored in blobs, and user ada.
+type avatars struct {
+	t   *testing.T
+	srv *httptest.Server
+}
+
+// forEachBlobStore runs fn against avatars on blob.Memory and on
+// blob.Disk. files lists what Disk left in its directory, nil for Memory.
+func forEachBlobStore(t *testing.T, fn func(t *testing.T, a *avatars, files func() []string)) {
+	t.Run("memory", func(t *testing.T) { fn(t, newAvatars(t, blob.NewMemory()), nil) })
+	t.Run("disk", func(t *testing.T) {
+		dir := t.TempDir()
+		d, err := blob.NewDisk(dir)
+		if err != nil {
+			t.Fatalf("NewDisk: %v", err)
+		}
+		t.Cleanup(func() { d.Close() })
+		fn(t, newAvatars(t, d), func() []string {
+			var files []string
+			filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					rel, _ := filepath.Rel(dir, path)
+					files = append(files, filepath.ToSlash(rel))
+				}
+				return err
+			})
+			return files
+		})
+	})
+}
+
+func newAvatars(t *testing.T, blobs httptransport.BlobStore) *avatars {
+	t.Helper()
+	users := service.NewUserService(db.NewMemoryStore())
+	if err := users.CreateUser(context.Background(), &service.User{ID: "ada", Email: "ada@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	srv := httptest.NewServer(httptransport.NewV2Handler(users, quiet, httptransport.WithAvatars(blobs)))
+	t.Cleanup(srv.Close)
+	return &avatars{t: t, srv: srv}
+}
+
+// send sends a request for path and returns the response and its body.
+func (a *avatars) send(method, path, contentType string, body io.Reader) (*http.Response, []byte) {
+	a.t.Helper()
+	req, err := http.NewRequest(method, a.srv.URL+path, body)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		a.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		a.t.Fatalf("%s %s: reading the body: %v", method, path, err)
+	}
+	return resp, b
+}
+
+// put uploads parts as a multipart form to id's avatar and returns the
+// status.
+func (a *avatars) put(id string, parts ...part) int {
+	a.t.Helper()
+	body, contentType := form(parts...)
+	resp, _ := a.send(http.MethodPut, "/users/"+id+"/avatar", contentType, body)
+	return resp.StatusCode
+}
+
+// current checks ada's avatar is exactly data.
+func (a *avatars) current(data []byte, contentType string) {
+	a.t.Helper()
+	resp, got := a.send(http.MethodGet, "/users/ada/avatar", "", nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != contentType || !bytes.Equal(got, data) {
+		a.t.Errorf("the avatar is %s %s, %d bytes, want %s, %d bytes", resp.Status, resp.Header.Get("Content-Type"), len(got), contentType, len(data))
+	}
+}
+
+// part is one field of a multipart form, a file when filename is set.
+type part struct {
+	field, filename string
+	data            []byte
+}
+
+// form encodes parts as multipart/form-data, streamed through a pipe the
+// way a client uploading a file would send it.
+func form(parts ...part) (io.Reader, string) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		for _, p := range parts {
+			var w io.Writer
+			var err error
+			if p.filename != "" {
+				w, err = mw.CreateFormFile(p.field, p.filename)
+			} else {
+				w, err = mw.CreateFormField(p.field)
+			}
+			if err == nil {
+				_, err = w.Write(p.data)
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.CloseWithError(mw.Close())
+	}()
+	return pr, mw.FormDataContentType()
+}
+
+func gradient() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	for x := range 32 {
+		for y := range 32 {
+			img.Set(x, y, color.RGBA{R: uint8(x * 8), G: uint8(y * 8), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+var (
+	pngData = func() []byte {
+		var buf bytes.Buffer
+		png.Encode(&buf, gradient())
+		return buf.Bytes()
+	}()
+	jpegData = func() []byte {
+		var buf bytes.Buffer
+		jpeg.Encode(&buf, gradient(), nil)
+		return buf.Bytes()
+	}()
+)
+
+func TestAvatarStored(t *testing.T) {
+	forEachBlobStore(t, func(t *testing.T, a *avatars, _ func() []string) {
+		if code := a.put("ada", part{"avatar", "me.png", pngData}); code != http.StatusNoContent {
+			t.Fatalf("PUT a PNG = %d, want 204", code)
+		}
+		a.current(pngData, "image/png")
+		if resp, _ := a.send(http.MethodGet, "/users/ada/avatar", "", nil); resp.Header.Get("X-Content-Type-Options") != "nosniff" {
+			t.Error("no X-Content-Type-Options: nosniff")
+		}
+
+		// a JPEG replaces it, fields before it are skipped
+		if code := a.put("ada", part{"caption", "", []byte("hello")}, part{"avatar", "me.jpg", jpegData}); code != http.StatusNoContent {
+			t.Fatalf("PUT a JPEG = %d, want 204", code)
+		}
+		a.current(jpegData, "image/jpeg")
+
+		// the type is sniffed, not taken from the filename
+		gif := []byte("GIF89a not really")
+		if code := a.put("ada", part{"avatar", "me.png", gif}); code != http.StatusNoContent {
+			t.Fatalf("PUT a GIF named .png = %d, want 204", code)
+		}
+		a.current(gif, "image/gif")
+	})
+}
+
+// TestAvatarRefused checks each way an upload can be too big, malformed,
+// or not an image, and that every one leaves the avatar there was.
+func TestAvatarRefused(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		code  int
+		parts []part
+		raw   func() (io.Reader, string)
+	}{
+		{name: "image over 1 MiB", code: http.StatusRequestEntityTooLarge,
+			parts: []part{{"avatar", "big.png", append(slices.Clone(pngData), make([]byte, 1<<20)...)}}},
+		{name: "body over the limit before the image", code: http.StatusRequestEntityTooLarge,
+			parts: []part{{"caption", "", make([]byte, 2<<20)}, {"avatar", "me.png", pngData}}},
+		{name: "truncated body", code: http.StatusBadRequest, raw: func() (io.Reader, string) {
+			body, contentType := form(part{"avatar", "me.png", pngData})
+			b, _ := io.ReadAll(body)
+			return bytes.NewReader(b[:len(b)-100]), contentType
+		}},
+		{name: "not multipart at all", code: http.StatusBadRequest, raw: func() (io.Reader, string) {
+			return strings.NewReader("just some text"), "multipart/form-data; boundary=xyz"
+		}},
+		{name: "no boundary", code: http.StatusBadRequest, raw: func() (io.Reader, string) {
+			return bytes.NewReader(pngData), "multipart/form-data"
+		}},
+		{name: "raw image body", code: http.StatusUnsupportedMediaType, raw: func() (io.Reader, string) {
+			return bytes.NewReader(pngData), "image/png"
+		}},
+		{name: "no avatar field", code: http.StatusBadRequest, parts: []part{{"caption", "", []byte("hello")}}},
+		{name: "empty avatar", code: http.StatusBadRequest, parts: []part{{"avatar", "empty.png", nil}}},
+		{name: "text", code: http.StatusUnsupportedMediaType, parts: []part{{"avatar", "me.png", []byte("definitely an image")}}},
+		{name: "SVG", code: http.StatusUnsupportedMediaType,
+			parts: []part{{"avatar", "me.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`)}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBlobStore(t, func(t *testing.T, a *avatars, files func() []string) {
+				if code := a.put("ada", part{"avatar", "me.jpg", jpegData}); code != http.StatusNoContent {
+					t.Fatalf("PUT a JPEG = %d, want 204", code)
+				}
+				var code int
+				if tt.raw != nil {
+					body, contentType := tt.raw()
+					resp, _ := a.send(http.MethodPut, "/users/ada/avatar", contentType, body)
+					code = resp.StatusCode
+				} else {
+					code = a.put("ada", tt.parts...)
+				}
+				if code != tt.code {
+					t.Errorf("PUT = %d, want %d", code, tt.code)
+				}
+				a.current(jpegData, "image/jpeg")
+				// nor any temporary files
+				if files != nil {
+					if got := files(); !slices.Equal(got, []string{"avatars/ada"}) {
+						t.Errorf("files = %v, want only avatars/ada", got)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestAvatarUnknownUser(t *testing.T) {
+	forEachBlobStore(t, func(t *testing.T, a *avatars, _ func() []string) {
+		if code := a.put("nobody", part{"avatar", "me.png", pngData}); code != http.StatusNotFound {
+			t.Errorf("PUT = %d, want 404", code)
+		}
+	})
+}
+
+func TestAvatarDeleted(t *testing.T) {
+	forEachBlobStore(t, func(t *testing.T, a *avatars, _ func() []string) {
+		if code := a.put("ada", part{"avatar", "me.png", pngData}); code != http.StatusNoContent {
+			t.Fatalf("PUT = %d, want 204", code)
+		}
+		if resp, _ := a.send(http.MethodDelete, "/users/ada/avatar", "", nil); resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("DELETE = %s, want 204", resp.Status)
+		}
+		if resp, _ := a.send(http.MethodGet, "/users/ada/avatar", "", nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("GET after DELETE = %s, want 404", resp.Status)
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package httptransport
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// maxWait bounds a long poll's ?wait=, longer than most proxies keep an
+// idle request open.
+const maxWait = time.Minute
+
+// ChangeNotifier tells a long poll when to look again. The channel Changed
+// returns is closed by the next change in the tenant of ctx, see
+// ctxutil.Tenant, and once the notifier itself is closed it returns that
+// same closed channel every time. *EventStream has it, a change being any
+// event of that tenant on the bus.
+type ChangeNotifier interface {
+	Changed(ctx context.Context) <-chan struct{}
+}
+
+// WithLongPoll lets a GET /users with If-None-Match and ?wait= block until
+// the page no longer matches the ETag or the wait is up:
+//
+//	GET /users?wait=30s
+//	If-None-Match: "..."
+//
+// It is answered with the new page as soon as a change makes it differ, or
+// 304 Not Modified when the wait ends first. changes says when to list the
+// page again, a change somewhere else leaves this page, and the poll,
+// where they were. Without the option ?wait= is accepted and ignored.
+func WithLongPoll(changes ChangeNotifier) HandlerOption {
+	return func(h *Handler) {
+		h.changes = changes
+	}
+}
+
+// encodeTagged is body as respond writes it, and its ETag: a strong
+// validator, the hash of those bytes, so it changes with anything that
+// shows in the response and with nothing that does not.
+func encodeTagged(body any) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(body); err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return buf.Bytes(), `"` + base64.RawURLEncoding.EncodeToString(sum[:18]) + `"`, nil
+}
+
+// noneMatch reports whether r's If-None-Match lists etag, or is "*". The
+// comparison is the weak one RFC 9110 asks of If-None-Match, a W/ prefix
+// on either side is ignored.
+func noneMatch(r *http.Request, etag string) bool {
+	for _, header := range r.Header.Values("If-None-Match") {
+		for tag := range strings.SplitSeq(header, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// respondTagged is respond for a 200 a client may cache, with its ETag, or
+// a bodiless 304 when If-None-Match says the client has it already.
+func (h *Handler) respondTagged(w http.ResponseWriter, r *http.Request, body any) {
+	data, etag, err := encodeTagged(body)
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	if noneMatch(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// pollTagged is respondTagged over load, waiting first when the request
+// is a long poll, see WithLongPoll. load is called again after every
+// change until what it returns differs from If-None-Match.
+func (h *Handler) pollTagged(w http.ResponseWriter, r *http.Request, load func(context.Context) (any, error)) {
+	wait, err := waitParam(r)
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+	var timeout <-chan time.Time
+	if wait > 0 && h.changes != nil && r.Header.Get("If-None-Match") != "" {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	notModified := func(etag string) {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+	}
+	var changed <-chan struct{}
+	for {
+		// asked for before loading, a change while load runs is not missed
+		var next <-chan struct{}
+		if timeout != nil {
+			next = h.changes.Changed(r.Context())
+		}
+		body, err := load(r.Context())
+		if err != nil {
+			h.error(w, r, err)
+			return
+		}
+		data, etag, err := encodeTagged(body)
+		if err != nil {
+			h.error(w, r, err)
+			return
+		}
+		if !noneMatch(r, etag) {
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write(data)
+			return
+		}
+		// the same channel twice is a closed notifier, nothing will change
+		if timeout == nil || next == changed {
+			notModified(etag)
+			return
+		}
+		changed = next
+		select {
+		case <-changed:
+		case <-timeout:
+			notModified(etag)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// waitParam reads ?wait=, a duration like 30s, capped at maxWait.
+func waitParam(r *http.Request) (time.Duration, error) {
+	v := r.URL.Query().Get("wait")
+	if v == "" {
+		return 0, nil
+	}
+	wait, err := time.ParseDuration(v)
+	if err != nil || wait < 0 {
+		return 0, errs.Wrap("httptransport.waitParam", fmt.Errorf("wait: %w", errs.ErrInvalidInput))
+	}
+	return min(wait, maxWait), nil
+}
@@ -0,0 +1,93 @@
+package httptransport
+
+import (
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// maxImportBytes bounds an import's body. The service streams it, so this
+// is a limit on how long one request may keep the server busy rather than
+// on memory.
+const maxImportBytes = 64 << 20
+
+type importUsersResponse struct {
+	Created int              `json:"created"`
+	Failed  int              `json:"failed"`
+	Errors  []importRowError `json:"errors,omitempty"`
+}
+
+type importRowError struct {
+	Line   int          `json:"line"`
+	ID     string       `json:"id,omitempty"`
+	Error  string       `json:"error"`
+	Fields []fieldError `json:"fields,omitempty"`
+}
+
+func newImportUsersResponse(result service.ImportResult) importUsersResponse {
+	resp := importUsersResponse{Created: result.Created, Failed: result.Failed}
+	for _, e := range result.Errors {
+		errResp := newErrorResponse(status(e.Err), e.Err)
+		resp.Errors = append(resp.Errors, importRowError{Line: e.Line, ID: e.ID, Error: errResp.Error, Fields: errResp.Fields})
+	}
+	return resp
+}
+
+// importUsers creates users from a text/csv body, see
+// service.ImportUsersCSV for the columns. Rows that fail are listed in a
+// 200 response, a file that cannot be read is a 400 with the rows before
+// the fault created.
+func (h *Handler) importUsers(w http.ResponseWriter, r *http.Request) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "text/csv" {
+		code := http.StatusUnsupportedMediaType
+		h.respond(w, r, code, errorResponse{Error: http.StatusText(code)})
+		return
+	}
+	result, err := h.users.ImportUsersCSV(r.Context(), http.MaxBytesReader(w, r.Body, maxImportBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		code := http.StatusRequestEntityTooLarge
+		h.respond(w, r, code, errorResponse{Error: http.StatusText(code)})
+		return
+	}
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+	h.respond(w, r, http.StatusOK, newImportUsersResponse(result))
+}
+
+// exportUsers streams every user as text/csv. Once the first rows are sent
+// the status is too, a later failure can only be logged and the client
+// sees a truncated file.
+func (h *Handler) exportUsers(w http.ResponseWriter, r *http.Request) {
+	out := &csvResponse{w: w}
+	err := h.users.ExportUsersCSV(r.Context(), out)
+	if err != nil && !out.started {
+		h.error(w, r, err)
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "export failed part way", slog.String("error", err.Error()))
+	}
+}
+
+// csvResponse holds off on the headers until the first write, so an export
+// that fails before writing anything still gets an error status.
+type csvResponse struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (c *csvResponse) Write(p []byte) (int, error) {
+	if !c.started {
+		c.started = true
+		c.w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		c.w.Header().Set("Content-Disposition", `attachment; filename="users.csv"`)
+		c.w.WriteHeader(http.StatusOK)
+	}
+	return c.w.Write(p)
+}
@@ -0,0 +1,83 @@
+package httptransport
+
+import (
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/mapper"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// The request and response bodies are their own types rather than
+// service.User with json tags. The wire format can then change (or stay put)
+// independently of the domain model, and clients can never set fields such
+// as CreatedAt that the service owns.
+//
+// The fields a DTO shares with service.User are copied by a mapper.Mapper
+// per direction, compiled as the package loads, so a field added to both
+// needs no new assignment. Only what differs, like DeletedAt's zero value
+// becoming a missing field, is written out.
+
+var (
+	createUserMapper   = mapper.MustCompile[createUserRequest, service.User]()
+	updateUserMapper   = mapper.MustCompile[updateUserRequest, service.User]()
+	userResponseMapper = mapper.MustCompile[service.User, userResponse]()
+)
+
+type createUserRequest struct {
+	// ID is optional when the service has an IDGenerator.
+	ID    string `json:"id,omitempty"`
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+func (r createUserRequest) toUser() *service.User {
+	user := createUserMapper.Map(&r)
+	return &user
+}
+
+type updateUserRequest struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+	// Version is the version the client last read, see service.UpdateUser.
+	Version int64 `json:"version"`
+}
+
+func (r updateUserRequest) toUser(id string) *service.User {
+	user := updateUserMapper.Map(&r)
+	user.ID = id
+	return &user
+}
+
+type userResponse struct {
+	ID        string     `json:"id"`
+	Email     string     `json:"email"`
+	Name      string     `json:"name,omitempty"`
+	Version   int64      `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" mapper:"-"`
+}
+
+func newUserResponse(user *service.User) userResponse {
+	resp := userResponseMapper.Map(user)
+	if user.Deleted() {
+		deletedAt := user.DeletedAt
+		resp.DeletedAt = &deletedAt
+	}
+	return resp
+}
+
+type listUsersResponse struct {
+	Users      []userResponse `json:"users"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+type errorResponse struct {
+	Error  string       `json:"error"`
+	Fields []fieldError `json:"fields,omitempty"`
+}
+
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
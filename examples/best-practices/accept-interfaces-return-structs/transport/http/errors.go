@@ -0,0 +1,61 @@
+package httptransport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/validate"
+)
+
+// statusClientClosed is the non-standard code nginx logs when the client
+// goes away before the response, nobody is left to read it anyway.
+const statusClientClosed = 499
+
+// status maps the errs taxonomy onto HTTP status codes. It is the only
+// place the transport looks inside an error, handlers just pass them on.
+func status(err error) int {
+	switch {
+	case errors.Is(err, errKeyReused):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, errs.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errs.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, errs.ErrInvalidInput):
+		return http.StatusBadRequest
+	case errors.Is(err, errs.ErrUnauthenticated):
+		return http.StatusUnauthorized
+	case errors.Is(err, errs.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, errs.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, errors.ErrUnsupported):
+		return http.StatusNotImplemented
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return statusClientClosed
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// newErrorResponse hides the wrapped operation chain of unexpected errors,
+// which is for logs, not clients. Expected errors are reported as their kind,
+// plus the individual fields for a validation failure.
+func newErrorResponse(code int, err error) errorResponse {
+	resp := errorResponse{Error: http.StatusText(code)}
+	if code == statusClientClosed {
+		resp.Error = "client closed request"
+	}
+	var verr *validate.ValidationError
+	if errors.As(err, &verr) {
+		resp.Fields = make([]fieldError, len(verr.Fields))
+		for i, f := range verr.Fields {
+			resp.Fields[i] = fieldError{Field: f.Field, Message: f.Message}
+		}
+	}
+	return resp
+}
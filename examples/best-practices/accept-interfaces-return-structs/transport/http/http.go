@@ -0,0 +1,230 @@
+package httptransport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/archive"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// maxBodyBytes bounds request bodies, a user is a couple of short strings.
+const maxBodyBytes = 1 << 20
+
+// UserService is what the handlers need from *service.UserService. Declaring
+// it here, on the consuming side, keeps the transport testable with a fake
+// and documents exactly which operations are exposed over HTTP.
+type UserService interface {
+	CreateUser(ctx context.Context, user *service.User) error
+	RetrieveUser(ctx context.Context, id string, opts ...service.ReadOption) (*service.User, error)
+	UpdateUser(ctx context.Context, user *service.User) error
+	DeleteUser(ctx context.Context, id string) error
+	ListUsers(ctx context.Context, req service.PageRequest, opts ...service.ReadOption) (service.Page[service.User], error)
+	ListAll(ctx context.Context, opts ...service.ReadOption) iter.Seq2[*service.User, error]
+	ImportUsersCSV(ctx context.Context, r io.Reader) (service.ImportResult, error)
+	ExportUsersCSV(ctx context.Context, w io.Writer) error
+}
+
+// Handler serves the users API. NewHandler gives the v1 shapes, NewV2Handler
+// the v2 ones, both over the same routes:
+//
+//	POST   /users                create, 201 with the stored user
+//	GET    /users                list, ?cursor=&limit=, and ?wait= given WithLongPoll
+//	GET    /users/export         every user as newline-delimited JSON, streamed
+//	GET    /users/export.zip     users.csv and a JSON file per user, zipped
+//	GET    /users/export.tar.gz  the same as a gzipped tar
+//	GET    /users/{id}           retrieve
+//	PUT    /users/{id}           update, the body carries the version last read
+//	DELETE /users/{id}           soft delete, 204
+//	POST   /users.csv            import a text/csv body, 200 with the rows that failed
+//	GET    /users.csv            export every user as text/csv
+//
+// and, given WithAvatars, /users/{id}/avatar.
+//
+// Given WithIdempotency, a create with an Idempotency-Key is safe to retry.
+//
+// Retrieve and list send an ETag and answer a matching If-None-Match with
+// 304 Not Modified.
+//
+// /users/export and its archives take precedence over /users/{id}, a user
+// with the ID "export" or "export.zip" is only reachable through the list
+// and the exports over GET.
+type Handler struct {
+	users   UserService
+	logger  *slog.Logger
+	mux     *http.ServeMux
+	avatars BlobStore
+	changes ChangeNotifier
+
+	idempotency    IdempotencyStore
+	idempotencyTTL time.Duration
+}
+
+func NewHandler(users UserService, logger *slog.Logger, opts ...HandlerOption) *Handler {
+	h := newHandler(users, logger, opts)
+	h.mux.HandleFunc("POST /users", h.idempotent(h.createUser))
+	h.mux.HandleFunc("GET /users", h.listUsers)
+	h.mux.HandleFunc("GET /users/export", exportNDJSON(h, newUserResponse))
+	h.mux.HandleFunc("GET /users/export.zip", h.exportArchive(archive.Zip))
+	h.mux.HandleFunc("GET /users/export.tar.gz", h.exportArchive(archive.TarGz))
+	h.mux.HandleFunc("GET /users/{id}", h.retrieveUser)
+	h.mux.HandleFunc("PUT /users/{id}", h.updateUser)
+	h.mux.HandleFunc("DELETE /users/{id}", h.deleteUser)
+	h.mux.HandleFunc("POST /users.csv", h.importUsers)
+	h.mux.HandleFunc("GET /users.csv", h.exportUsers)
+	return h
+}
+
+// newHandler registers the routes both versions share.
+func newHandler(users UserService, logger *slog.Logger, opts []HandlerOption) *Handler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	h := &Handler{
+		users:  users,
+		logger: logger,
+		mux:    http.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.avatars != nil {
+		h.mux.HandleFunc("PUT /users/{id}/avatar", h.uploadAvatar)
+		h.mux.HandleFunc("GET /users/{id}/avatar", h.retrieveAvatar)
+		h.mux.HandleFunc("DELETE /users/{id}/avatar", h.deleteAvatar)
+	}
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) createUser(w http.ResponseWriter, r *http.Request) {
+	var req createUserRequest
+	if err := decode(w, r, &req); err != nil {
+		h.error(w, r, err)
+		return
+	}
+	user := req.toUser()
+	if err := h.users.CreateUser(r.Context(), user); err != nil {
+		h.error(w, r, err)
+		return
+	}
+	w.Header().Set("Location", "/users/"+user.ID)
+	h.respond(w, r, http.StatusCreated, newUserResponse(user))
+}
+
+func (h *Handler) retrieveUser(w http.ResponseWriter, r *http.Request) {
+	user, err := h.users.RetrieveUser(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+	h.respondTagged(w, r, newUserResponse(user))
+}
+
+func (h *Handler) listUsers(w http.ResponseWriter, r *http.Request) {
+	req, err := pageRequest(r)
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+	h.pollTagged(w, r, func(ctx context.Context) (any, error) {
+		page, err := h.users.ListUsers(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		resp := listUsersResponse{Users: make([]userResponse, len(page.Items)), NextCursor: page.NextCursor}
+		for i := range page.Items {
+			resp.Users[i] = newUserResponse(&page.Items[i])
+		}
+		return resp, nil
+	})
+}
+
+func (h *Handler) updateUser(w http.ResponseWriter, r *http.Request) {
+	var req updateUserRequest
+	if err := decode(w, r, &req); err != nil {
+		h.error(w, r, err)
+		return
+	}
+	user := req.toUser(r.PathValue("id"))
+	if err := h.users.UpdateUser(r.Context(), user); err != nil {
+		h.error(w, r, err)
+		return
+	}
+	// re-read so the response carries the stored CreatedAt as well
+	stored, err := h.users.RetrieveUser(r.Context(), user.ID)
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+	h.respond(w, r, http.StatusOK, newUserResponse(stored))
+}
+
+func (h *Handler) deleteUser(w http.ResponseWriter, r *http.Request) {
+	if err := h.users.DeleteUser(r.Context(), r.PathValue("id")); err != nil {
+		h.error(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pageRequest reads the ?cursor= and ?limit= query parameters.
+func pageRequest(r *http.Request) (service.PageRequest, error) {
+	req := service.PageRequest{Cursor: r.URL.Query().Get("cursor")}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return req, errs.Wrap("httptransport.pageRequest", fmt.Errorf("limit: %w", errs.ErrInvalidInput))
+		}
+		req.Limit = n
+	}
+	return req, nil
+}
+
+// decode reads a single JSON object into dst, rejecting unknown fields so a
+// typo in a client shows up as a 400 instead of a silently ignored value.
+func decode(w http.ResponseWriter, r *http.Request, dst any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return errs.Wrap("httptransport.decode", fmt.Errorf("%w: %s", errs.ErrInvalidInput, err))
+	}
+	if !errors.Is(dec.Decode(&struct{}{}), io.EOF) {
+		return errs.Wrap("httptransport.decode", fmt.Errorf("%w: body must be a single JSON object", errs.ErrInvalidInput))
+	}
+	return nil
+}
+
+func (h *Handler) respond(w http.ResponseWriter, r *http.Request, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		h.logger.ErrorContext(r.Context(), "encode response", slog.String("error", err.Error()))
+	}
+}
+
+// error logs server side failures with the full operation chain and sends
+// the client only the mapped status, see newErrorResponse.
+func (h *Handler) error(w http.ResponseWriter, r *http.Request, err error) {
+	code := status(err)
+	if code >= http.StatusInternalServerError {
+		h.logger.ErrorContext(r.Context(), "request failed",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("error", err.Error()),
+		)
+	}
+	h.respond(w, r, code, newErrorResponse(code, err))
+}
@@ -0,0 +1,157 @@
+package httptransport_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/fake"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/idgen"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	httptransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/http"
+)
+
+// quiet discards the handler's logs of the 5xx responses tests provoke.
+var quiet = slog.New(slog.DiscardHandler)
+
+// newServer serves the v1 API over a memory store, minting IDs u1, u2, ...
+func newServer(t *testing.T, opts ...httptransport.HandlerOption) *httptest.Server {
+	t.Helper()
+	users := service.NewUserService(db.NewMemoryStore(), service.WithIDGenerator(&idgen.Sequence{Prefix: "u"}))
+	srv := httptest.NewServer(httptransport.NewHandler(users, quiet, opts...))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// do sends body, if any, as JSON and decodes a JSON response into a map.
+func do(t *testing.T, srv *httptest.Server, method, path, body string) (*http.Response, map[string]any) {
+	t.Helper()
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, srv.URL+path, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	var got map[string]any
+	if len(data) > 0 && strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("%s %s: body %q: %v", method, path, data, err)
+		}
+	}
+	return resp, got
+}
+
+func TestCRUD(t *testing.T) {
+	srv := newServer(t)
+
+	resp, body := do(t, srv, "POST", "/users", `{"email":"ada@example.com","name":"Ada"}`)
+	if resp.StatusCode != http.StatusCreated || body["id"] != "u1" || body["version"] != 1.0 {
+		t.Fatalf("create = %d %v, want 201 with u1 at version 1", resp.StatusCode, body)
+	}
+	if loc := resp.Header.Get("Location"); loc != "/users/u1" {
+		t.Errorf("Location = %q, want /users/u1", loc)
+	}
+	if _, ok := body["created_at"]; !ok {
+		t.Errorf("create response %v has no created_at", body)
+	}
+
+	resp, body = do(t, srv, "GET", "/users/u1", "")
+	if resp.StatusCode != http.StatusOK || body["email"] != "ada@example.com" {
+		t.Fatalf("retrieve = %d %v, want 200 with ada", resp.StatusCode, body)
+	}
+
+	resp, body = do(t, srv, "PUT", "/users/u1", `{"email":"ada@lovelace.example","version":1}`)
+	if resp.StatusCode != http.StatusOK || body["email"] != "ada@lovelace.example" || body["version"] != 2.0 {
+		t.Fatalf("update = %d %v, want 200 with the new email at version 2", resp.StatusCode, body)
+	}
+
+	resp, body = do(t, srv, "GET", "/users", "")
+	if list, _ := body["users"].([]any); resp.StatusCode != http.StatusOK || len(list) != 1 {
+		t.Fatalf("list = %d %v, want 200 with one user", resp.StatusCode, body)
+	}
+
+	if resp, _ := do(t, srv, "DELETE", "/users/u1", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete = %d, want 204", resp.StatusCode)
+	}
+	if resp, _ := do(t, srv, "GET", "/users/u1", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("retrieve after delete = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	srv := newServer(t)
+	do(t, srv, "POST", "/users", `{"email":"ada@example.com"}`)
+
+	for _, tt := range []struct {
+		name, method, path, body string
+		want                     int
+	}{
+		{"missing user", "GET", "/users/nobody", "", http.StatusNotFound},
+		{"duplicate email", "POST", "/users", `{"email":"ada@example.com"}`, http.StatusConflict},
+		{"stale version", "PUT", "/users/u1", `{"email":"ada@example.com","version":7}`, http.StatusConflict},
+		{"invalid email", "POST", "/users", `{"email":"not an email"}`, http.StatusBadRequest},
+		{"unknown field", "POST", "/users", `{"email":"bob@example.com","admin":true}`, http.StatusBadRequest},
+		{"two objects", "POST", "/users", `{"email":"bob@example.com"} {}`, http.StatusBadRequest},
+		{"not JSON", "POST", "/users", `email=bob`, http.StatusBadRequest},
+		{"bad limit", "GET", "/users?limit=ten", "", http.StatusBadRequest},
+		{"bad cursor", "GET", "/users?cursor=!!!", "", http.StatusBadRequest},
+		{"wrong method", "PATCH", "/users/u1", `{}`, http.StatusMethodNotAllowed},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := do(t, srv, tt.method, tt.path, tt.body)
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d %v, want %d", resp.StatusCode, body, tt.want)
+			}
+			if body != nil && body["error"] != http.StatusText(tt.want) {
+				t.Errorf("error = %v, want %q", body["error"], http.StatusText(tt.want))
+			}
+		})
+	}
+}
+
+func TestValidationFields(t *testing.T) {
+	srv := newServer(t)
+	resp, body := do(t, srv, "POST", "/users", `{"email":""}`)
+	fields, _ := body["fields"].([]any)
+	if resp.StatusCode != http.StatusBadRequest || len(fields) == 0 {
+		t.Fatalf("create with no email = %d %v, want 400 naming the fields", resp.StatusCode, body)
+	}
+	if f := fields[0].(map[string]any); f["field"] != "Email" || f["message"] == "" {
+		t.Errorf("fields[0] = %v, want the Email field with a message", f)
+	}
+}
+
+// TestServerErrorsHidden checks a store failure reaches the client as its
+// status alone, the wrapped operation chain stays in the logs.
+func TestServerErrorsHidden(t *testing.T) {
+	store := fake.New()
+	store.FailWith("Get", errs.ErrUnavailable)
+	srv := httptest.NewServer(httptransport.NewHandler(service.NewUserService(store), quiet))
+	defer srv.Close()
+
+	resp, body := do(t, srv, "GET", "/users/ada", "")
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", resp.StatusCode)
+	}
+	if len(body) != 1 || body["error"] != http.StatusText(http.StatusServiceUnavailable) {
+		t.Errorf("body = %v, want only the status text", body)
+	}
+}
@@ -0,0 +1,232 @@
+package httptransport
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/idempotency"
+)
+
+const (
+	// IdempotencyKeyHeader names the key a client sends to make a create
+	// safe to retry.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// ReplayedHeader is set on a response that is a stored one sent again.
+	ReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLen bounds a key, it is stored for the whole TTL.
+	maxIdempotencyKeyLen = 255
+	// idempotencyLease is the longest a claim is held for a request that
+	// is still running. A replica that dies mid-request leaves its claim,
+	// and retries get 409 until the lease is up rather than forever.
+	idempotencyLease = 5 * time.Minute
+)
+
+// replayedHeaders are the response headers kept with a record, the ones a
+// handler sets. Everything else, a request ID, a trace, belongs to the
+// request that is being answered now.
+var replayedHeaders = []string{"Content-Type", "Location"}
+
+var (
+	// errKeyInFlight is a retry that arrives while the first request with
+	// its key is still running.
+	errKeyInFlight = fmt.Errorf("idempotency key in use by a request still running: %w", errs.ErrConflict)
+	// errKeyReused is a key sent again with a different request, status
+	// answers it 422.
+	errKeyReused = errors.New("idempotency key already used for another request")
+)
+
+// IdempotencyStore keeps what each Idempotency-Key has done,
+// *idempotency.Memory has it. Claim must be atomic: of any number of
+// concurrent calls for one key that nothing is kept for, exactly one
+// reports true, the rest get its record. A record must disappear when its
+// TTL is up.
+type IdempotencyStore interface {
+	Claim(ctx context.Context, key string, rec idempotency.Record, ttl time.Duration) (idempotency.Record, bool, error)
+	Complete(ctx context.Context, key string, rec idempotency.Record, ttl time.Duration) error
+	Release(ctx context.Context, key string) error
+}
+
+// WithIdempotency makes POST /users safe to retry: a request with an
+// Idempotency-Key header is carried out once, its response kept in store
+// for ttl, and a retry with the same key and body gets that response again,
+// with Idempotent-Replayed: true, instead of a second user. A retry
+// while the first is still running is answered 409 with a Retry-After, one
+// with the same key but a different method, path, or body 422.
+//
+// A response is kept unless it is a 5xx or the client went away first,
+// those are released so a retry runs again. Keys are per tenant and per
+// authenticated caller, two clients that pick the same key do not see each
+// other's responses. Without the option, or without the header, requests
+// run as they always did.
+func WithIdempotency(store IdempotencyStore, ttl time.Duration) HandlerOption {
+	if ttl <= 0 {
+		panic("httptransport: WithIdempotency needs ttl > 0")
+	}
+	return func(h *Handler) {
+		h.idempotency = store
+		h.idempotencyTTL = ttl
+	}
+}
+
+// idempotent runs next at most once per Idempotency-Key, see
+// WithIdempotency.
+func (h *Handler) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if h.idempotency == nil || key == "" {
+			next(w, r)
+			return
+		}
+		if !validIdempotencyKey(key) {
+			h.error(w, r, errs.Wrap("httptransport.idempotent", fmt.Errorf("%s: %w", IdempotencyKeyHeader, errs.ErrInvalidInput)))
+			return
+		}
+		// the body is read here to fingerprint it, and handed on as it was
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if err != nil {
+			h.error(w, r, errs.Wrap("httptransport.idempotent", fmt.Errorf("%w: %s", errs.ErrInvalidInput, err)))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		key = idempotencyScope(r.Context()) + key
+		claim := idempotency.Record{Fingerprint: fingerprint(r, body)}
+		held, claimed, err := h.idempotency.Claim(r.Context(), key, claim, min(idempotencyLease, h.idempotencyTTL))
+		if err != nil {
+			h.error(w, r, errs.Wrap("httptransport.idempotent", err))
+			return
+		}
+		if !claimed {
+			switch {
+			case held.Fingerprint != claim.Fingerprint:
+				h.error(w, r, errs.Wrap("httptransport.idempotent", errKeyReused))
+			case !held.Done():
+				w.Header().Set("Retry-After", "1")
+				h.error(w, r, errs.Wrap("httptransport.idempotent", errKeyInFlight))
+			default:
+				replay(w, held)
+			}
+			return
+		}
+
+		// the claim outlives the request, a client that hangs up must not
+		// leave it held
+		ctx := context.WithoutCancel(r.Context())
+		settled := false
+		defer func() {
+			// next panicked or its response is not worth keeping
+			if !settled {
+				if err := h.idempotency.Release(ctx, key); err != nil {
+					h.logger.ErrorContext(ctx, "release idempotency key", slog.String("error", err.Error()))
+				}
+			}
+		}()
+		rec := &recorder{ResponseWriter: w}
+		next(rec, r)
+		code := rec.code()
+		if code >= http.StatusInternalServerError || code == statusClientClosed {
+			return
+		}
+		// set before Complete: when Complete fails the user may well exist,
+		// and a release would let a retry create another. The claim stays
+		// until its lease is up.
+		settled = true
+		done := idempotency.Record{Fingerprint: claim.Fingerprint, Status: code, Header: http.Header{}, Body: rec.body.Bytes()}
+		for _, name := range replayedHeaders {
+			if v := rec.Header().Values(name); len(v) > 0 {
+				done.Header[name] = v
+			}
+		}
+		if err := h.idempotency.Complete(ctx, key, done, h.idempotencyTTL); err != nil {
+			h.logger.ErrorContext(ctx, "complete idempotency key", slog.String("error", err.Error()))
+		}
+	}
+}
+
+// validIdempotencyKey accepts up to maxIdempotencyKeyLen printable ASCII
+// characters, a UUID or anything else a client is likely to send.
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLen {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// idempotencyScope prefixes a key with the tenant and the caller, each
+// length prefixed so no pair of them can pass for another.
+func idempotencyScope(ctx context.Context) string {
+	tenant, _ := ctxutil.Tenant(ctx)
+	p, _ := ctxutil.PrincipalFrom(ctx)
+	return fmt.Sprintf("%d:%s%d:%s", len(tenant), tenant, len(p.Subject), p.Subject)
+}
+
+// fingerprint identifies the request a key was first sent with: its
+// method, path, and body byte for byte. The same JSON spaced differently
+// is a different request.
+func fingerprint(r *http.Request, body []byte) string {
+	sum := sha256.New()
+	fmt.Fprintf(sum, "%s\x00%s\x00", r.Method, r.URL.Path)
+	sum.Write(body)
+	return base64.RawURLEncoding.EncodeToString(sum.Sum(nil))
+}
+
+// replay sends a completed record as the response.
+func replay(w http.ResponseWriter, rec idempotency.Record) {
+	for name, v := range rec.Header {
+		w.Header()[name] = v
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(rec.Status)
+	w.Write(rec.Body)
+}
+
+// recorder passes a response through and keeps a copy of its status and
+// body.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *recorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the writer underneath.
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// code is the status written, 200 when next wrote nothing at all.
+func (rec *recorder) code() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
@@ -0,0 +1,290 @@
+package httptransport_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/idempotency"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/idgen"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/middleware"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	httptransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/http"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ttlcache"
+)
+
+// slowFlaky is the service with two knobs: every create waits delay
+// first, and the next fail creates fail outright, which the handler
+// answers 500.
+type slowFlaky struct {
+	httptransport.UserService
+	delay atomic.Int64
+	fail  atomic.Int32
+}
+
+func (s *slowFlaky) CreateUser(ctx context.Context, user *service.User) error {
+	time.Sleep(time.Duration(s.delay.Load()))
+	if s.fail.Add(-1) >= 0 {
+		return errors.New("database on fire")
+	}
+	return s.UserService.CreateUser(ctx, user)
+}
+
+// idempotent serves POST /users with Idempotency-Key over a real HTTP
+// server, behind the tenant middleware. Keys are kept 24h on a fake
+// clock and swept by hand, so expiry follows the clock alone.
+type idempotent struct {
+	t     *testing.T
+	url   string
+	clock *clock.Fake
+	store *idempotency.Memory
+	users *service.UserService
+	svc   *slowFlaky
+}
+
+func newIdempotent(t *testing.T) *idempotent {
+	t.Helper()
+	ip := &idempotent{
+		t:     t,
+		clock: clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)),
+		users: service.NewUserService(db.NewMemoryStore(), service.WithIDGenerator(idgen.UUIDv7{})),
+	}
+	ip.store = idempotency.NewMemory(ttlcache.WithClock(ip.clock), ttlcache.WithSweepInterval(0))
+	ip.svc = &slowFlaky{UserService: ip.users}
+	handler := httptransport.NewHandler(ip.svc, quiet, httptransport.WithIdempotency(ip.store, 24*time.Hour))
+	srv := httptest.NewServer(middleware.Tenant()(handler))
+	ip.url = srv.URL + "/users"
+	t.Cleanup(func() {
+		srv.Close()
+		ip.store.Close()
+	})
+	return ip
+}
+
+// created is what a create reads back.
+type created struct {
+	status     int
+	location   string
+	retryAfter string
+	replayed   bool
+	body       string
+}
+
+// post sends a create for tenant, with key as its Idempotency-Key unless
+// it is empty.
+func (ip *idempotent) post(tenant, key, body string) (created, error) {
+	req, err := http.NewRequest(http.MethodPost, ip.url, strings.NewReader(body))
+	if err != nil {
+		return created{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.TenantHeader, tenant)
+	if key != "" {
+		req.Header.Set(httptransport.IdempotencyKeyHeader, key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return created{}, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return created{}, err
+	}
+	return created{
+		status:     resp.StatusCode,
+		location:   resp.Header.Get("Location"),
+		retryAfter: resp.Header.Get("Retry-After"),
+		replayed:   resp.Header.Get(httptransport.ReplayedHeader) == "true",
+		body:       string(data),
+	}, nil
+}
+
+// must is post failing the test on a transport error.
+func (ip *idempotent) must(tenant, key, body string) created {
+	ip.t.Helper()
+	resp, err := ip.post(tenant, key, body)
+	if err != nil {
+		ip.t.Fatalf("POST /users: %v", err)
+	}
+	return resp
+}
+
+// count is how many users tenant has.
+func (ip *idempotent) count(tenant string) int {
+	ip.t.Helper()
+	page, err := ip.users.ListUsers(ctxutil.WithTenant(context.Background(), tenant), service.PageRequest{Limit: 100})
+	if err != nil {
+		ip.t.Fatalf("ListUsers: %v", err)
+	}
+	return len(page.Items)
+}
+
+const adaBody = `{"email":"ada@example.com","name":"Ada Lovelace"}`
+
+func TestIdempotentRetry(t *testing.T) {
+	ip := newIdempotent(t)
+	first := ip.must("acme", "create-ada", adaBody)
+	if first.status != http.StatusCreated || first.replayed || first.location == "" {
+		t.Fatalf("first = %d, replayed %t, Location %q, want a fresh 201", first.status, first.replayed, first.location)
+	}
+	for range 3 {
+		retry := ip.must("acme", "create-ada", adaBody)
+		if retry.status != first.status || !retry.replayed || retry.body != first.body || retry.location != first.location {
+			t.Errorf("retry = %d, replayed %t, %s, want the first response replayed", retry.status, retry.replayed, retry.body)
+		}
+	}
+	if n := ip.count("acme"); n != 1 {
+		t.Errorf("%d users, want 1", n)
+	}
+}
+
+func TestIdempotentNoKey(t *testing.T) {
+	ip := newIdempotent(t)
+	for i, want := range []int{http.StatusCreated, http.StatusConflict} {
+		resp := ip.must("acme", "", `{"email":"bob@example.com"}`)
+		if resp.status != want || resp.replayed {
+			t.Errorf("try %d = %d, replayed %t, want %d", i+1, resp.status, resp.replayed, want)
+		}
+	}
+}
+
+func TestIdempotentOtherBody(t *testing.T) {
+	ip := newIdempotent(t)
+	ip.must("acme", "create-ada", adaBody)
+	resp := ip.must("acme", "create-ada", `{"email":"eve@example.com"}`)
+	if resp.status != http.StatusUnprocessableEntity || resp.replayed {
+		t.Errorf("POST = %d, replayed %t, want 422", resp.status, resp.replayed)
+	}
+	if n := ip.count("acme"); n != 1 {
+		t.Errorf("%d users, want ada only", n)
+	}
+}
+
+func TestIdempotentBadKey(t *testing.T) {
+	ip := newIdempotent(t)
+	for _, tt := range []struct {
+		name string
+		key  string
+	}{
+		{"too long", strings.Repeat("k", 256)},
+		{"unprintable", "tab\tkey"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := ip.must("acme", tt.key, `{"email":"mallory@example.com"}`); resp.status != http.StatusBadRequest {
+				t.Errorf("POST = %d, want 400", resp.status)
+			}
+		})
+	}
+	if n := ip.count("acme"); n != 0 {
+		t.Errorf("%d users, want none", n)
+	}
+}
+
+// TestIdempotentConcurrent sends the same create many times at once
+// while the first is slow: one runs, the others are told to retry while
+// it is in flight or get its response replayed.
+func TestIdempotentConcurrent(t *testing.T) {
+	const n = 20
+	ip := newIdempotent(t)
+	ip.svc.delay.Store(int64(200 * time.Millisecond))
+	body := `{"email":"grace@example.com","name":"Grace Hopper"}`
+	var (
+		wg       sync.WaitGroup
+		start    = make(chan struct{})
+		results  = make([]created, n)
+		failures = make([]error, n)
+	)
+	for i := range n {
+		wg.Go(func() {
+			<-start
+			results[i], failures[i] = ip.post("acme", "create-grace", body)
+		})
+	}
+	close(start)
+	wg.Wait()
+	ip.svc.delay.Store(0)
+
+	fresh, inFlight := 0, 0
+	for i, resp := range results {
+		if failures[i] != nil {
+			t.Fatalf("POST /users: %v", failures[i])
+		}
+		switch {
+		case resp.status == http.StatusCreated && !resp.replayed:
+			fresh++
+		case resp.status == http.StatusConflict && resp.retryAfter != "":
+			inFlight++
+		case resp.status != http.StatusCreated:
+			t.Errorf("a retry = %d %s", resp.status, resp.body)
+		}
+	}
+	if fresh != 1 || inFlight == 0 {
+		t.Errorf("%d fresh 201s and %d 409s, want one and some", fresh, inFlight)
+	}
+	t.Logf("%d of %d answered 409 while the first ran", inFlight, n)
+	if got := ip.count("acme"); got != 1 {
+		t.Errorf("%d users, want 1", got)
+	}
+	if again := ip.must("acme", "create-grace", body); again.status != http.StatusCreated || !again.replayed {
+		t.Errorf("once settled = %d, replayed %t, want the 201 replayed", again.status, again.replayed)
+	}
+}
+
+func TestIdempotentFailureReleased(t *testing.T) {
+	ip := newIdempotent(t)
+	ip.svc.fail.Store(1)
+	body := `{"email":"linus@example.com"}`
+	if first := ip.must("acme", "create-linus", body); first.status != http.StatusInternalServerError {
+		t.Fatalf("first = %d, want 500", first.status)
+	}
+	if retry := ip.must("acme", "create-linus", body); retry.status != http.StatusCreated || retry.replayed {
+		t.Errorf("retry = %d, replayed %t, want a fresh 201", retry.status, retry.replayed)
+	}
+}
+
+func TestIdempotentPerTenant(t *testing.T) {
+	ip := newIdempotent(t)
+	ip.must("acme", "create-ada", adaBody)
+	resp := ip.must("globex", "create-ada", adaBody)
+	if resp.status != http.StatusCreated || resp.replayed {
+		t.Errorf("POST = %d, replayed %t, want a fresh 201", resp.status, resp.replayed)
+	}
+	if n := ip.count("globex"); n != 1 {
+		t.Errorf("%d users in globex, want 1", n)
+	}
+}
+
+// TestIdempotentExpiry checks a key is kept for its TTL and no longer:
+// once it is swept the create runs again for real.
+func TestIdempotentExpiry(t *testing.T) {
+	ip := newIdempotent(t)
+	ip.must("acme", "create-ada", adaBody)
+	ip.must("acme", "create-bob", `{"email":"bob@example.com"}`)
+
+	ip.clock.Advance(23 * time.Hour)
+	if dropped := ip.store.DeleteExpired(); dropped != 0 {
+		t.Fatalf("after 23h dropped %d, want none", dropped)
+	}
+	if resp := ip.must("acme", "create-ada", adaBody); !resp.replayed {
+		t.Errorf("after 23h = %d, not replayed, want the first 201", resp.status)
+	}
+	ip.clock.Advance(time.Hour)
+	if dropped := ip.store.DeleteExpired(); dropped != 2 || ip.store.Len() != 0 {
+		t.Fatalf("after 24h dropped %d of 2, %d left", dropped, ip.store.Len())
+	}
+	// ada exists, so it is the create's own 409
+	if resp := ip.must("acme", "create-ada", adaBody); resp.status != http.StatusConflict || resp.replayed {
+		t.Errorf("after 24h = %d, replayed %t, want the create's own 409", resp.status, resp.replayed)
+	}
+}
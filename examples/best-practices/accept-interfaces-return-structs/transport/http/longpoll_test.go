@@ -0,0 +1,264 @@
+package httptransport_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/eventbus"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	httptransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/http"
+)
+
+// longPoll serves the v1 handler and the v2 handler with long polls over
+// a real HTTP server, counting the requests in flight.
+type longPoll struct {
+	t        *testing.T
+	srv      *httptest.Server
+	users    *service.UserService
+	stream   *httptransport.EventStream
+	inFlight atomic.Int32
+}
+
+// newLongPoll serves users ada and bob, so the first page of one already
+// has a next_cursor.
+func newLongPoll(t *testing.T) *longPoll {
+	t.Helper()
+	bus := eventbus.New()
+	lp := &longPoll{
+		t:      t,
+		stream: httptransport.NewEventStream(bus, httptransport.WithStreamLogger(quiet)),
+		users:  service.NewUserService(db.NewMemoryStore(), service.WithPublisher(bus)),
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/v2/", http.StripPrefix("/v2", httptransport.NewV2Handler(lp.users, quiet, httptransport.WithLongPoll(lp.stream))))
+	mux.Handle("/", httptransport.NewHandler(lp.users, quiet))
+	lp.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lp.inFlight.Add(1)
+		defer lp.inFlight.Add(-1)
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(func() {
+		lp.stream.Close()
+		lp.srv.Close()
+	})
+	lp.create("ada", "Ada Lovelace")
+	lp.create("bob", "")
+	return lp
+}
+
+func (lp *longPoll) create(id, name string) {
+	lp.t.Helper()
+	if err := lp.users.CreateUser(context.Background(), &service.User{ID: id, Email: id + "@example.com", Name: name}); err != nil {
+		lp.t.Fatalf("CreateUser: %v", err)
+	}
+}
+
+// conditional is what a GET reads back: the status, the ETag, the body,
+// and how long the server held the request.
+type conditional struct {
+	status int
+	etag   string
+	body   string
+	took   time.Duration
+}
+
+// get sends a GET for path with If-None-Match set to each of etags.
+func (lp *longPoll) get(ctx context.Context, path string, etags ...string) (conditional, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, lp.srv.URL+path, nil)
+	if err != nil {
+		return conditional{}, err
+	}
+	for _, etag := range etags {
+		req.Header.Add("If-None-Match", etag)
+	}
+	start := time.Now()
+	resp, err := lp.srv.Client().Do(req)
+	if err != nil {
+		return conditional{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return conditional{}, err
+	}
+	return conditional{status: resp.StatusCode, etag: resp.Header.Get("ETag"), body: string(body), took: time.Since(start)}, nil
+}
+
+// must is get failing the test on a transport error.
+func (lp *longPoll) must(path string, etags ...string) conditional {
+	lp.t.Helper()
+	r, err := lp.get(context.Background(), path, etags...)
+	if err != nil {
+		lp.t.Fatalf("GET %s: %v", path, err)
+	}
+	return r
+}
+
+// TestETag checks a user's ETag gets a 304 until the user changes.
+func TestETag(t *testing.T) {
+	lp := newLongPoll(t)
+	first := lp.must("/v2/users/ada")
+	if first.status != http.StatusOK || !strings.HasPrefix(first.etag, `"`) {
+		t.Fatalf("GET = %d with ETag %q, want 200 with a strong ETag", first.status, first.etag)
+	}
+	for _, tt := range []struct {
+		name  string
+		etags []string
+	}{
+		{"same", []string{first.etag}},
+		{"weak", []string{"W/" + first.etag}},
+		{"in a list", []string{`"other", ` + first.etag}},
+		{"in a second header", []string{`"other"`, first.etag}},
+		{"any", []string{"*"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			again := lp.must("/v2/users/ada", tt.etags...)
+			if again.status != http.StatusNotModified || again.body != "" || again.etag != first.etag {
+				t.Errorf("If-None-Match %q = %d with %q and body %q, want a bare 304", tt.etags, again.status, again.etag, again.body)
+			}
+		})
+	}
+
+	var ada struct{ Version int64 }
+	if err := json.Unmarshal([]byte(first.body), &ada); err != nil {
+		t.Fatal(err)
+	}
+	if err := lp.users.UpdateUser(context.Background(), &service.User{ID: "ada", Email: "ada@lovelace.example", Name: "Ada Lovelace", Version: ada.Version}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	changed := lp.must("/v2/users/ada", first.etag)
+	if changed.status != http.StatusOK || changed.etag == first.etag || !strings.Contains(changed.body, "ada@lovelace.example") {
+		t.Errorf("after an update = %d with ETag %q, want 200 and a new ETag", changed.status, changed.etag)
+	}
+}
+
+// TestETagPerVersion checks each API version's representation has its own
+// ETag.
+func TestETagPerVersion(t *testing.T) {
+	lp := newLongPoll(t)
+	v1, v2 := lp.must("/users/ada"), lp.must("/v2/users/ada")
+	if v1.etag == "" || v1.etag == v2.etag {
+		t.Errorf("v1 ETag %q, v2 %q, want two", v1.etag, v2.etag)
+	}
+	if cross := lp.must("/users/ada", v2.etag); cross.status != http.StatusOK {
+		t.Errorf("v1 with the v2 ETag = %d, want 200", cross.status)
+	}
+	if missing := lp.must("/v2/users/nobody", "*"); missing.status != http.StatusNotFound || missing.etag != "" {
+		t.Errorf("a missing user = %d with ETag %q, want a 404 without", missing.status, missing.etag)
+	}
+}
+
+// TestListETag checks a list without ?wait= answers a matching ETag at
+// once.
+func TestListETag(t *testing.T) {
+	lp := newLongPoll(t)
+	list := lp.must("/v2/users?limit=1")
+	if again := lp.must("/v2/users?limit=1", list.etag); again.status != http.StatusNotModified || again.took > time.Second {
+		t.Errorf("= %d after %s, want an immediate 304", again.status, again.took)
+	}
+}
+
+// TestLongPollTimeout checks a poll with nothing changing ends in a 304
+// when the wait is up.
+func TestLongPollTimeout(t *testing.T) {
+	lp := newLongPoll(t)
+	list := lp.must("/v2/users?limit=1")
+	poll := lp.must("/v2/users?limit=1&wait=200ms", list.etag)
+	if poll.status != http.StatusNotModified || poll.took < 200*time.Millisecond || poll.etag != list.etag {
+		t.Errorf("= %d with %q after %s, want 304 after 200ms", poll.status, poll.etag, poll.took)
+	}
+}
+
+// TestLongPollOtherPage checks a change on another page does not end the
+// poll.
+func TestLongPollOtherPage(t *testing.T) {
+	lp := newLongPoll(t)
+	list := lp.must("/v2/users?limit=1")
+	done := make(chan error, 1)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		// after ada and bob in ID order, off the first page
+		done <- lp.users.CreateUser(context.Background(), &service.User{ID: "zed", Email: "zed@example.com"})
+	}()
+	poll := lp.must("/v2/users?limit=1&wait=300ms", list.etag)
+	if err := <-done; err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if poll.status != http.StatusNotModified || poll.took < 300*time.Millisecond {
+		t.Errorf("= %d after %s, want 304 once the wait was up", poll.status, poll.took)
+	}
+}
+
+// TestLongPollChange checks a poll answers as soon as its page changes.
+func TestLongPollChange(t *testing.T) {
+	lp := newLongPoll(t)
+	all := lp.must("/v2/users")
+	done := make(chan error, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		done <- lp.users.CreateUser(context.Background(), &service.User{ID: "grace", Email: "grace@example.com", Name: "Grace Hopper"})
+	}()
+	poll := lp.must("/v2/users?wait=30s", all.etag)
+	if err := <-done; err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if poll.status != http.StatusOK || !strings.Contains(poll.body, `"grace"`) || poll.etag == all.etag {
+		t.Errorf("= %d with %s, want grace in a new page", poll.status, poll.body)
+	}
+	if poll.took < 100*time.Millisecond || poll.took > 5*time.Second {
+		t.Errorf("answered after %s, want just after the create", poll.took)
+	}
+}
+
+// TestLongPollHangUp checks a client that hangs up ends its poll.
+func TestLongPollHangUp(t *testing.T) {
+	lp := newLongPoll(t)
+	all := lp.must("/v2/users")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := lp.get(ctx, "/v2/users?wait=30s", all.etag); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the client's own deadline", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); lp.inFlight.Load() > 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the handler is still waiting")
+		}
+	}
+}
+
+func TestLongPollBadWait(t *testing.T) {
+	lp := newLongPoll(t)
+	list := lp.must("/v2/users?limit=1")
+	for _, wait := range []string{"soon", "-1s"} {
+		if bad := lp.must("/v2/users?wait="+wait, list.etag); bad.status != http.StatusBadRequest {
+			t.Errorf("wait=%s = %d, want 400", wait, bad.status)
+		}
+	}
+}
+
+// TestLongPollStreamClosed checks a poll does not wait once the stream is
+// closed.
+func TestLongPollStreamClosed(t *testing.T) {
+	lp := newLongPoll(t)
+	all := lp.must("/v2/users")
+	lp.stream.Close()
+	// Close returns before the stream has wound down, and the channel
+	// closes when it has
+	select {
+	case <-lp.stream.Changed(context.Background()):
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stream's Changed was never closed")
+	}
+	if poll := lp.must("/v2/users?wait=30s", all.etag); poll.status != http.StatusNotModified || poll.took > time.Second {
+		t.Errorf("= %d after %s, want an immediate 304", poll.status, poll.took)
+	}
+}
@@ -0,0 +1,77 @@
+package httptransport
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// stallTimeout is how long one line may take to reach a client that has
+// stopped reading before the export gives up on it.
+const stallTimeout = 30 * time.Second
+
+// exportNDJSON streams every user as newline-delimited JSON, one user per
+// line in ID order, each the DTO convert makes of it.
+//
+// Nothing is read ahead of the client. Users come from ListAll a page at a
+// time and each line is flushed as it is written, so a slow client blocks
+// the write and, through it, the next page fetch. A client that hangs up
+// cancels the request context, which ends ListAll before its next page, and
+// one that stops reading without hanging up is cut off after stallTimeout.
+//
+// The status is sent with the first line. An error before it gets the
+// usual error response, one after it aborts the connection, so the client
+// sees a broken stream rather than a short one that looks complete.
+//
+// Every line is converted into the one line variable and encoded through a
+// pointer to it. Encoding a fresh DTO per line boxed it into an interface
+// and encoding/json then copied it to make it addressable, two allocations
+// a line for what is garbage once the line is written. encoding/json pools
+// the buffer it encodes into already, there is nothing for bufpool to add.
+func exportNDJSON[T any](h *Handler, convert func(*service.User) T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		enc := json.NewEncoder(w)
+		var line T
+		started := false
+		start := func() {
+			started = true
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("X-Accel-Buffering", "no")
+			w.WriteHeader(http.StatusOK)
+		}
+		for user, err := range h.users.ListAll(r.Context()) {
+			if err != nil {
+				if !started {
+					h.error(w, r, err)
+					return
+				}
+				if r.Context().Err() == nil {
+					h.logger.ErrorContext(r.Context(), "export failed part way", slog.String("error", err.Error()))
+				}
+				panic(http.ErrAbortHandler)
+			}
+			if !started {
+				start()
+			}
+			// a deadline per line, a client may take as long as it likes in all
+			if err := rc.SetWriteDeadline(time.Now().Add(stallTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return
+			}
+			line = convert(user)
+			if err := enc.Encode(&line); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+		if !started {
+			start()
+		}
+	}
+}
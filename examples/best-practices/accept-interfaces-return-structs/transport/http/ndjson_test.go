@@ -0,0 +1,228 @@
+package httptransport_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/query"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	httptransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/http"
+)
+
+// countingStore counts the pages the export reads, and fails the page after
+// failAfter when that is set.
+type countingStore struct {
+	*db.MemoryStore
+	pages     atomic.Int64
+	failAfter atomic.Int64
+}
+
+func (s *countingStore) Query(ctx context.Context, q query.Query) ([]*service.User, error) {
+	n := s.pages.Add(1)
+	if f := s.failAfter.Load(); f > 0 && n > f {
+		return nil, errors.New("store went away")
+	}
+	return s.MemoryStore.Query(ctx, q)
+}
+
+// noLister hides MemoryStore.Query and List, the service then cannot list
+// at all.
+type noLister struct {
+	service.UserStorer
+}
+
+type exported struct {
+	ID        string `json:"id"`
+	FirstName string `json:"first_name"`
+}
+
+// exportUsers reads GET /users/export one line at a time and hands each
+// user to fn as it arrives. Returning an error from fn, or canceling ctx,
+// hangs up.
+func exportUsers(ctx context.Context, url string, fn func(exported) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"/users/export", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET /users/export = %d", resp.StatusCode)
+	}
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var u exported
+		err := dec.Decode(&u)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("export broken off: %w", err)
+		}
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+}
+
+// TestExportNDJSON streams users out of GET /users/export. The store
+// counts the pages read, which shows the server only reads as fast as the
+// client does. The long names make the export several MiB, more than the
+// socket buffers between the two hold.
+func TestExportNDJSON(t *testing.T) {
+	const (
+		total = 20_000
+		// pages is how many pages ListAll reads for total users
+		pages = total / 100
+	)
+	name := "Ada " + strings.Repeat("L", 196)
+	ctx := context.Background()
+	store := &countingStore{MemoryStore: db.NewMemoryStore()}
+	users := service.NewUserService(store)
+	batch := make([]*service.User, 0, total)
+	for i := range total {
+		id := fmt.Sprintf("user-%06d", i)
+		batch = append(batch, &service.User{ID: id, Email: id + "@example.com", Name: name})
+	}
+	if _, err := users.CreateUsers(ctx, batch); err != nil {
+		t.Fatalf("CreateUsers: %v", err)
+	}
+
+	// done is signaled as each export's handler returns
+	done := make(chan struct{}, 1)
+	mux := http.NewServeMux()
+	mux.Handle("/v2/", http.StripPrefix("/v2", httptransport.NewV2Handler(users, quiet)))
+	mux.Handle("/", httptransport.NewHandler(users, quiet))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() { done <- struct{}{} }()
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	// waitDone waits for the export's handler to return, then starts the
+	// page count over for the next subtest
+	waitDone := func(t *testing.T) {
+		t.Helper()
+		defer store.pages.Store(0)
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the export is still running")
+		}
+	}
+
+	t.Run("every user in ID order", func(t *testing.T) {
+		n, last := 0, ""
+		err := exportUsers(ctx, srv.URL, func(u exported) error {
+			if u.ID <= last {
+				return fmt.Errorf("%s after %s", u.ID, last)
+			}
+			n, last = n+1, u.ID
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		waitDone(t)
+		if n != total {
+			t.Errorf("%d users, want %d", n, total)
+		}
+	})
+
+	t.Run("a paused client holds the export up", func(t *testing.T) {
+		stop := errors.New("stop")
+		err := exportUsers(ctx, srv.URL, func(exported) error {
+			// the server writes until the socket buffers fill, then waits
+			time.Sleep(500 * time.Millisecond)
+			if n := store.pages.Load(); n >= pages {
+				t.Errorf("the server read all %d pages ahead of the client", n)
+			}
+			return stop
+		})
+		if !errors.Is(err, stop) {
+			t.Fatal(err)
+		}
+		waitDone(t)
+	})
+
+	t.Run("a client that hangs up stops the export", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		seen := 0
+		err := exportUsers(ctx, srv.URL, func(exported) error {
+			if seen++; seen == 10 {
+				cancel()
+				return context.Canceled
+			}
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("export: err = %v, want context.Canceled", err)
+		}
+		pagesRead := store.pages.Load()
+		waitDone(t)
+		if pagesRead >= pages {
+			t.Errorf("the server went on to read all %d pages", pagesRead)
+		}
+	})
+
+	t.Run("v2 streams the v2 shape", func(t *testing.T) {
+		var first exported
+		stop := errors.New("stop")
+		err := exportUsers(ctx, srv.URL+"/v2", func(u exported) error {
+			first = u
+			return stop
+		})
+		if !errors.Is(err, stop) {
+			t.Fatal(err)
+		}
+		waitDone(t)
+		if first.FirstName != "Ada" {
+			t.Errorf("first_name = %q, want Ada", first.FirstName)
+		}
+	})
+
+	t.Run("a failure part way breaks the stream", func(t *testing.T) {
+		store.failAfter.Store(3)
+		defer store.failAfter.Store(0)
+		n := 0
+		err := exportUsers(ctx, srv.URL, func(exported) error {
+			n++
+			return nil
+		})
+		if err == nil {
+			t.Fatalf("export ended cleanly after %d users", n)
+		}
+		waitDone(t)
+		if n != 300 {
+			t.Errorf("%d users before the break, want the 300 from 3 pages", n)
+		}
+	})
+}
+
+// TestExportNDJSONNoLister checks a failure before the first line is still
+// an error status, there is nothing streamed yet to break off.
+func TestExportNDJSONNoLister(t *testing.T) {
+	srv := httptest.NewServer(httptransport.NewHandler(service.NewUserService(noLister{db.NewMemoryStore()}), quiet))
+	t.Cleanup(srv.Close)
+	resp, err := http.Get(srv.URL + "/users/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("GET /users/export = %d, want 501", resp.StatusCode)
+	}
+}
@@ -0,0 +1,157 @@
+package httptransport
+
+import (
+	"net/http"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/openapi"
+)
+
+// OpenAPI describes the routes NewHandler registers. The schemas come from
+// the DTO types themselves, only the routes and status codes are written
+// out by hand here and must be kept in step with NewHandler.
+func OpenAPI() *openapi.Document {
+	idParam := openapi.Parameter{Name: "id", In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}}
+	user := openapi.Response{Description: "the user", Content: openapi.JSON(openapi.Ref("User"))}
+	ifNoneMatch := openapi.Parameter{Name: "If-None-Match", In: "header", Description: "the ETag of the copy the client has", Schema: &openapi.Schema{Type: "string"}}
+	csv := map[string]openapi.MediaType{"text/csv": {Schema: &openapi.Schema{Type: "string"}}}
+	binary := func(mediaType string) map[string]openapi.MediaType {
+		return map[string]openapi.MediaType{mediaType: {Schema: &openapi.Schema{Type: "string", Format: "binary"}}}
+	}
+	failure := func(description string) openapi.Response {
+		return openapi.Response{Description: description, Content: openapi.JSON(openapi.Ref("Error"))}
+	}
+
+	return &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info:    openapi.Info{Title: "Users API", Version: "1.0.0"},
+		Paths: map[string]openapi.PathItem{
+			"/users": {
+				Get: &openapi.Operation{
+					OperationID: "listUsers",
+					Summary:     "List users in ID order",
+					Parameters: []openapi.Parameter{
+						{Name: "cursor", In: "query", Description: "next_cursor from the previous page", Schema: &openapi.Schema{Type: "string"}},
+						{Name: "limit", In: "query", Schema: &openapi.Schema{Type: "integer", Format: "int32"}},
+						{Name: "wait", In: "query", Description: "with If-None-Match, how long to wait for the page to change, like 30s, at most 1m", Schema: &openapi.Schema{Type: "string"}},
+						ifNoneMatch,
+					},
+					Responses: map[string]openapi.Response{
+						"200": {Description: "one page of users", Content: openapi.JSON(openapi.Ref("UserList"))},
+						"304": {Description: "the page still matches If-None-Match, after the wait if one was asked for"},
+						"400": failure("bad cursor, limit, or wait"),
+					},
+				},
+				Post: &openapi.Operation{
+					OperationID: "createUser",
+					Summary:     "Create a user",
+					Parameters: []openapi.Parameter{
+						{Name: "Idempotency-Key", In: "header", Description: "makes the create safe to retry, a retry with the same key and body gets the first response again", Schema: &openapi.Schema{Type: "string"}},
+					},
+					RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(openapi.Ref("CreateUser"))},
+					Responses: map[string]openapi.Response{
+						"201": user,
+						"400": failure("validation failed"),
+						"409": failure("ID or email already taken, or a request with the same Idempotency-Key is still running"),
+						"422": failure("the Idempotency-Key was used for a different request"),
+					},
+				},
+			},
+			"/users/export": {
+				Get: &openapi.Operation{
+					OperationID: "exportUsersNDJSON",
+					Summary:     "Stream every user in ID order, one JSON object per line",
+					Responses: map[string]openapi.Response{
+						"200": {Description: "newline-delimited users, a broken stream means the export failed part way", Content: map[string]openapi.MediaType{"application/x-ndjson": {Schema: openapi.Ref("User")}}},
+					},
+				},
+			},
+			"/users/export.zip": {
+				Get: &openapi.Operation{
+					OperationID: "exportUsersZip",
+					Summary:     "Download users.csv and a users/<id>.json per user as a zip",
+					Responses: map[string]openapi.Response{
+						"200": {Description: "the archive, a broken stream means the export failed part way", Content: binary("application/zip")},
+					},
+				},
+			},
+			"/users/export.tar.gz": {
+				Get: &openapi.Operation{
+					OperationID: "exportUsersTarGz",
+					Summary:     "Download users.csv and a users/<id>.json per user as a gzipped tar",
+					Responses: map[string]openapi.Response{
+						"200": {Description: "the archive, a broken stream means the export failed part way", Content: binary("application/gzip")},
+					},
+				},
+			},
+			"/users.csv": {
+				Get: &openapi.Operation{
+					OperationID: "exportUsers",
+					Summary:     "Export every user as CSV",
+					Responses: map[string]openapi.Response{
+						"200": {Description: "id,email,name,created_at,updated_at,version rows under a header", Content: csv},
+					},
+				},
+				Post: &openapi.Operation{
+					OperationID: "importUsers",
+					Summary:     "Create a user for every row of a CSV file",
+					RequestBody: &openapi.RequestBody{Required: true, Content: csv},
+					Responses: map[string]openapi.Response{
+						"200": {Description: "how many rows were created, and why the rest failed", Content: openapi.JSON(openapi.Ref("ImportResult"))},
+						"400": failure("bad header or malformed CSV, the rows before it were created"),
+						"413": failure("body over 64 MiB"),
+						"415": failure("body is not text/csv"),
+					},
+				},
+			},
+			"/users/{id}": {
+				Get: &openapi.Operation{
+					OperationID: "getUser",
+					Summary:     "Retrieve a user",
+					Parameters:  []openapi.Parameter{idParam, ifNoneMatch},
+					Responses: map[string]openapi.Response{
+						"200": user,
+						"304": {Description: "the user still matches If-None-Match"},
+						"404": failure("no such user"),
+					},
+				},
+				Put: &openapi.Operation{
+					OperationID: "updateUser",
+					Summary:     "Update a user at the version last read",
+					Parameters:  []openapi.Parameter{idParam},
+					RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(openapi.Ref("UpdateUser"))},
+					Responses: map[string]openapi.Response{
+						"200": user,
+						"400": failure("validation failed"),
+						"404": failure("no such user"),
+						"409": failure("stale version or email already taken"),
+					},
+				},
+				Delete: &openapi.Operation{
+					OperationID: "deleteUser",
+					Summary:     "Soft delete a user",
+					Parameters:  []openapi.Parameter{idParam},
+					Responses: map[string]openapi.Response{
+						"204": {Description: "deleted"},
+						"404": failure("no such user"),
+					},
+				},
+			},
+		},
+		Components: openapi.Components{Schemas: map[string]*openapi.Schema{
+			"User":         openapi.SchemaOf(userResponse{}),
+			"UserList":     openapi.SchemaOf(listUsersResponse{}),
+			"CreateUser":   openapi.SchemaOf(createUserRequest{}),
+			"UpdateUser":   openapi.SchemaOf(updateUserRequest{}),
+			"Error":        openapi.SchemaOf(errorResponse{}),
+			"ImportResult": openapi.SchemaOf(importUsersResponse{}),
+		}},
+	}
+}
+
+// DocsHandler serves the document at /openapi.json and Swagger UI at /docs.
+func DocsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /openapi.json", openapi.Handler(OpenAPI()))
+	mux.Handle("GET /docs", openapi.SwaggerUI("/openapi.json"))
+	return mux
+}
@@ -0,0 +1,234 @@
+package httptransport_test
+
+import (
+	"encoding/json"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/openapi"
+	httptransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/http"
+)
+
+// operations lists the document's operations by method.
+func operations(item openapi.PathItem) map[string]*openapi.Operation {
+	ops := map[string]*openapi.Operation{}
+	for method, op := range map[string]*openapi.Operation{"GET": item.Get, "POST": item.Post, "PUT": item.Put, "DELETE": item.Delete} {
+		if op != nil {
+			ops[method] = op
+		}
+	}
+	return ops
+}
+
+// exampleBodies are valid request bodies for each operation that takes one.
+var exampleBodies = map[string]struct{ contentType, body string }{
+	"createUser":  {"application/json", `{"email":"new@example.com"}`},
+	"updateUser":  {"application/json", `{"email":"ada@example.com","name":"Ada","version":1}`},
+	"importUsers": {"text/csv", "id,email,name\nbob,bob@example.com,Bob\n"},
+}
+
+// TestOpenAPIMatchesHandler sends a request to every operation in the
+// document and checks the handler answers with a status the document
+// lists, in the content type it lists, with a JSON body that has the
+// fields the schema requires and no others.
+func TestOpenAPIMatchesHandler(t *testing.T) {
+	doc := httptransport.OpenAPI()
+	for path, item := range doc.Paths {
+		for method, op := range operations(item) {
+			t.Run(op.OperationID, func(t *testing.T) {
+				srv := newServer(t)
+				resp, _ := do(t, srv, "POST", "/users", `{"email":"ada@example.com"}`)
+				if resp.StatusCode != http.StatusCreated {
+					t.Fatalf("seed create = %d", resp.StatusCode)
+				}
+
+				var body io.Reader
+				example, hasBody := exampleBodies[op.OperationID]
+				if (op.RequestBody != nil) != hasBody {
+					t.Fatalf("request body documented: %t, example given: %t", op.RequestBody != nil, hasBody)
+				}
+				if hasBody {
+					if _, ok := op.RequestBody.Content[example.contentType]; !ok {
+						t.Fatalf("example is %s, the document takes %v", example.contentType, slices.Collect(maps.Keys(op.RequestBody.Content)))
+					}
+					body = strings.NewReader(example.body)
+				}
+				req, err := http.NewRequest(method, srv.URL+strings.ReplaceAll(path, "{id}", "u1"), body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if hasBody {
+					req.Header.Set("Content-Type", example.contentType)
+				}
+				resp, err = srv.Client().Do(req)
+				if err != nil {
+					t.Fatalf("%s %s: %v", method, path, err)
+				}
+				defer resp.Body.Close()
+				data, err := io.ReadAll(resp.Body)
+				if err != nil {
+					t.Fatalf("read body: %v", err)
+				}
+
+				documented, ok := op.Responses[strconv.Itoa(resp.StatusCode)]
+				if !ok {
+					t.Fatalf("%s %s = %d, which the document does not list: %v", method, path, resp.StatusCode, slices.Sorted(maps.Keys(op.Responses)))
+				}
+				if len(documented.Content) == 0 {
+					if len(data) != 0 {
+						t.Errorf("documented without a body, got %q", data)
+					}
+					return
+				}
+				mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+				content, ok := documented.Content[mediaType]
+				if !ok {
+					t.Fatalf("Content-Type %q, the document lists %v", mediaType, slices.Collect(maps.Keys(documented.Content)))
+				}
+				if mediaType != "application/json" {
+					return
+				}
+				var got any
+				if err := json.Unmarshal(data, &got); err != nil {
+					t.Fatalf("body %q: %v", data, err)
+				}
+				conforms(t, doc, content.Schema, got, "body")
+			})
+		}
+	}
+}
+
+// conforms checks v against s, resolving references in doc.
+func conforms(t *testing.T, doc *openapi.Document, s *openapi.Schema, v any, at string) {
+	t.Helper()
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		ref, ok := doc.Components.Schemas[name]
+		if !ok {
+			t.Fatalf("%s: %s is not in components", at, s.Ref)
+		}
+		s = ref
+	}
+	if v == nil {
+		if !s.Nullable && s.Type != "" {
+			t.Errorf("%s is null, the schema is not nullable", at)
+		}
+		return
+	}
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			t.Errorf("%s = %v, want an object", at, v)
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				t.Errorf("%s has no %q, the schema requires it", at, name)
+			}
+		}
+		for name, field := range obj {
+			prop, ok := s.Properties[name]
+			if !ok && s.Properties != nil {
+				t.Errorf("%s has %q, which the schema does not describe", at, name)
+				continue
+			}
+			if ok {
+				conforms(t, doc, prop, field, at+"."+name)
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			t.Errorf("%s = %v, want an array", at, v)
+			return
+		}
+		for i, item := range arr {
+			conforms(t, doc, s.Items, item, at+"["+strconv.Itoa(i)+"]")
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			t.Errorf("%s = %v, want a string", at, v)
+		}
+	case "integer", "number":
+		if _, ok := v.(float64); !ok {
+			t.Errorf("%s = %v, want a number", at, v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			t.Errorf("%s = %v, want a boolean", at, v)
+		}
+	}
+}
+
+// TestOpenAPIListsEveryRoute tries the methods the document leaves out of
+// each path: the handler must not answer any of them, or the document is
+// missing a route.
+func TestOpenAPIListsEveryRoute(t *testing.T) {
+	doc := httptransport.OpenAPI()
+	srv := newServer(t)
+	for path, item := range doc.Paths {
+		ops := operations(item)
+		for _, method := range []string{"GET", "POST", "PUT", "DELETE", "PATCH"} {
+			concrete := strings.ReplaceAll(path, "{id}", "u1")
+			if ops[method] != nil || documentedElsewhere(doc, method, concrete) {
+				continue
+			}
+			resp, _ := do(t, srv, method, concrete, "")
+			if resp.StatusCode != http.StatusMethodNotAllowed {
+				t.Errorf("%s %s = %d, undocumented but served", method, path, resp.StatusCode)
+			}
+		}
+	}
+}
+
+// documentedElsewhere reports whether another path template of doc, such
+// as /users/{id} for /users/export, covers method on path.
+func documentedElsewhere(doc *openapi.Document, method, path string) bool {
+	segments := strings.Split(path, "/")
+	for template, item := range doc.Paths {
+		if operations(item)[method] == nil {
+			continue
+		}
+		parts := strings.Split(template, "/")
+		if len(parts) != len(segments) {
+			continue
+		}
+		match := true
+		for i, part := range parts {
+			if part != segments[i] && !strings.HasPrefix(part, "{") {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDocsHandler(t *testing.T) {
+	srv := httptest.NewServer(httptransport.DocsHandler())
+	defer srv.Close()
+	resp, body := do(t, srv, "GET", "/openapi.json", "")
+	if resp.StatusCode != http.StatusOK || body["openapi"] != openapi.Version {
+		t.Fatalf("/openapi.json = %d %v, want the document", resp.StatusCode, body["openapi"])
+	}
+	resp, err := srv.Client().Get(srv.URL + "/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(page), "/openapi.json") {
+		t.Errorf("/docs does not load /openapi.json: %s", page)
+	}
+}
@@ -0,0 +1,152 @@
+package httptransport
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const defaultShutdownTimeout = 10 * time.Second
+
+// Server runs an http.Server until the process gets SIGINT or SIGTERM or
+// its context is canceled, then shuts down in order: stop accepting, let
+// in-flight requests finish within the shutdown timeout, and only then close
+// the store and anything else registered with WithCloser. Closing the store
+// first would fail the very requests being drained.
+type Server struct {
+	srv             *http.Server
+	logger          *slog.Logger
+	shutdownTimeout time.Duration
+	closers         []io.Closer
+}
+
+type ServerOption func(*Server)
+
+// WithShutdownTimeout bounds how long Run waits for in-flight requests,
+// requests still running after it are cut off. The default is 10 seconds.
+func WithShutdownTimeout(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.shutdownTimeout = d
+	}
+}
+
+// WithCloser registers c to be closed after the server has drained, in the
+// order registered. Pass the store here.
+func WithCloser(c io.Closer) ServerOption {
+	return func(s *Server) {
+		s.closers = append(s.closers, c)
+	}
+}
+
+// WithOnShutdown runs f as soon as shutdown starts, while requests are
+// still draining. Long lived responses such as an EventStream never finish
+// on their own, use it to end them so the drain does not wait out the timeout.
+func WithOnShutdown(f func()) ServerOption {
+	return func(s *Server) {
+		s.srv.RegisterOnShutdown(f)
+	}
+}
+
+// WithReadHeaderTimeout bounds how long a client may take to send the
+// request headers, the default is 5 seconds.
+func WithReadHeaderTimeout(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.srv.ReadHeaderTimeout = d
+	}
+}
+
+// WithTLS serves HTTPS with config, see the tlsconfig package for one built
+// with sound defaults.
+func WithTLS(config *tls.Config) ServerOption {
+	return func(s *Server) {
+		s.srv.TLSConfig = config
+	}
+}
+
+func WithServerLogger(logger *slog.Logger) ServerOption {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+func NewServer(addr string, handler http.Handler, opts ...ServerOption) *Server {
+	s := &Server{
+		srv: &http.Server{
+			Addr:              addr,
+			Handler:           handler,
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+		logger:          slog.Default(),
+		shutdownTimeout: defaultShutdownTimeout,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	// the server's own errors, such as failed TLS handshakes, go to logger too
+	s.srv.ErrorLog = slog.NewLogLogger(s.logger.Handler(), slog.LevelWarn)
+	return s
+}
+
+// Run listens on the server's address and blocks until shutdown completes.
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve is Run on an existing listener, which lets callers pick a free port
+// with ":0" and learn it from ln.Addr before serving.
+//
+// The returned error joins a failed serve, an incomplete drain
+// (context.DeadlineExceeded), and any closer errors.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// read once up front, http.Server.Serve writes TLSConfig as it starts
+	useTLS := s.srv.TLSConfig != nil
+	serveErr := make(chan error, 1)
+	go func() {
+		if useTLS {
+			// the certificates are in TLSConfig, not files
+			serveErr <- s.srv.ServeTLS(ln, "", "")
+			return
+		}
+		serveErr <- s.srv.Serve(ln)
+	}()
+	s.logger.Info("http server started", slog.String("addr", ln.Addr().String()), slog.Bool("tls", useTLS))
+
+	var err error
+	select {
+	case err = <-serveErr:
+		// the listener failed before any shutdown was asked for
+	case <-ctx.Done():
+		// a second signal now kills the process instead of waiting for the drain
+		stop()
+		s.logger.Info("http server shutting down", slog.Duration("timeout", s.shutdownTimeout))
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+		defer cancel()
+		err = s.srv.Shutdown(shutdownCtx)
+		if err != nil {
+			// drain timed out, drop the stragglers
+			s.srv.Close()
+		}
+		if serr := <-serveErr; !errors.Is(serr, http.ErrServerClosed) {
+			err = errors.Join(err, serr)
+		}
+	}
+	for _, c := range s.closers {
+		err = errors.Join(err, c.Close())
+	}
+	s.logger.Info("http server stopped")
+	return err
+}
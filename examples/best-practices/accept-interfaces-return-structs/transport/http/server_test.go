@@ -0,0 +1,132 @@
+package httptransport_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	httptransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/http"
+)
+
+// closerFunc records when the server closes what it was given.
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+// slowServer serves a handler that signals entered and then waits for
+// release, and returns the URL and Serve's result once it stops.
+func slowServer(t *testing.T, ctx context.Context, entered chan<- struct{}, release <-chan struct{}, opts ...httptransport.ServerOption) (string, <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+		io.WriteString(w, "done")
+	})
+	srv := httptransport.NewServer(ln.Addr().String(), handler, append(opts, httptransport.WithServerLogger(quiet))...)
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ctx, ln) }()
+	return "http://" + ln.Addr().String(), served
+}
+
+// TestShutdownDrains cancels the server's context while a request is in
+// flight: the request still completes, Serve waits for it, and the store
+// is closed only afterwards.
+func TestShutdownDrains(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	entered, release := make(chan struct{}), make(chan struct{})
+	var finished, closedAfter atomic.Bool
+	closer := closerFunc(func() error {
+		closedAfter.Store(finished.Load())
+		return nil
+	})
+	url, served := slowServer(t, ctx, entered, release, httptransport.WithCloser(closer))
+
+	got := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err == nil {
+			var body []byte
+			body, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err == nil && string(body) != "done" {
+				err = errors.New("body " + string(body))
+			}
+		}
+		finished.Store(err == nil)
+		got <- err
+	}()
+	<-entered
+	cancel()
+
+	select {
+	case err := <-served:
+		t.Fatalf("Serve returned %v with a request in flight", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err := <-got; err != nil {
+		t.Fatalf("in-flight request: %v", err)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	if !closedAfter.Load() {
+		t.Error("the closer ran before the in-flight request finished")
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Error("the server still accepts requests after shutdown")
+	}
+}
+
+// TestShutdownTimeout leaves a request hanging past the shutdown timeout:
+// Serve cuts it off and reports the incomplete drain.
+func TestShutdownTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	entered, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	closed := false
+	url, served := slowServer(t, ctx, entered, release,
+		httptransport.WithShutdownTimeout(20*time.Millisecond),
+		httptransport.WithCloser(closerFunc(func() error { closed = true; return nil })),
+	)
+
+	got := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		got <- err
+	}()
+	<-entered
+	cancel()
+	if err := <-served; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Serve: err = %v, want DeadlineExceeded", err)
+	}
+	if err := <-got; err == nil {
+		t.Error("the hanging request got a response, want it cut off")
+	}
+	if !closed {
+		t.Error("the closer was not run after a timed out drain")
+	}
+}
+
+func TestCloserErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	boom := errors.New("boom")
+	_, served := slowServer(t, ctx, nil, nil, httptransport.WithCloser(closerFunc(func() error { return boom })))
+	cancel()
+	if err := <-served; !errors.Is(err, boom) {
+		t.Errorf("Serve: err = %v, want the closer's error", err)
+	}
+}
@@ -0,0 +1,262 @@
+package httptransport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ctxutil"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/eventbus"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/events"
+)
+
+const (
+	defaultHeartbeat = 15 * time.Second
+	defaultHistory   = 256
+	clientBuffer     = 16
+	// retryMillis is the reconnect delay suggested to EventSource clients
+	retryMillis = 3000
+)
+
+// EventStream serves the user events on the bus as Server-Sent Events.
+//
+// A single bus subscription numbers every event and keeps the last few in
+// memory, so a client that reconnects with Last-Event-ID (browsers send it
+// automatically) first gets whatever it missed. A client too slow to keep up
+// is disconnected instead of holding back the others, it reconnects and
+// catches up from the history the same way.
+//
+// Each request reads the events of its own tenant, ctxutil.Tenant of its
+// context, and nobody else's: the replay, the live events, and the long
+// polls woken by Changed are all per tenant. The IDs are shared, so a
+// client sees gaps where other tenants' events went.
+type EventStream struct {
+	sub       *eventbus.Subscription
+	logger    *slog.Logger
+	heartbeat time.Duration
+	size      int
+
+	mu      sync.Mutex
+	nextID  uint64
+	history []sseEvent
+	// clients maps each client to the tenant it reads
+	clients map[chan sseEvent]string
+	// changed holds, per tenant, the channel its next event closes, see
+	// Changed; closed is the one returned for every tenant after Close
+	changed map[string]chan struct{}
+	closed  chan struct{}
+}
+
+type sseEvent struct {
+	id     uint64
+	tenant string
+	name   string
+	data   []byte
+}
+
+type EventStreamOption func(*EventStream)
+
+// WithHeartbeat sets how often an idle stream sends a comment line, which
+// keeps proxies from timing the connection out. The default is 15 seconds.
+func WithHeartbeat(d time.Duration) EventStreamOption {
+	return func(s *EventStream) {
+		s.heartbeat = d
+	}
+}
+
+// WithHistory sets how many past events are kept for reconnecting clients.
+func WithHistory(n int) EventStreamOption {
+	return func(s *EventStream) {
+		s.size = n
+	}
+}
+
+func WithStreamLogger(logger *slog.Logger) EventStreamOption {
+	return func(s *EventStream) {
+		s.logger = logger
+	}
+}
+
+// NewEventStream subscribes to every topic on bus. Call Close to unsubscribe.
+func NewEventStream(bus *eventbus.Bus, opts ...EventStreamOption) *EventStream {
+	s := &EventStream{
+		logger:    slog.Default(),
+		heartbeat: defaultHeartbeat,
+		size:      defaultHistory,
+		clients:   make(map[chan sseEvent]string),
+		changed:   make(map[string]chan struct{}),
+		closed:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.sub = bus.Subscribe(eventbus.AllTopics, eventbus.WithBuffer(s.size))
+	go s.run()
+	return s
+}
+
+// Close unsubscribes from the bus and ends every open stream.
+func (s *EventStream) Close() error {
+	s.sub.Unsubscribe()
+	return nil
+}
+
+func (s *EventStream) run() {
+	for event := range s.sub.Events() {
+		s.record(event)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		delete(s.clients, c)
+		close(c)
+	}
+	for tenant, changed := range s.changed {
+		delete(s.changed, tenant)
+		close(changed)
+	}
+	// kept closed, a long poll on a closed stream does not wait
+	close(s.closed)
+}
+
+// eventTenant is the tenant event belongs to, the default one for an
+// event that names none.
+func eventTenant(event events.Event) string {
+	switch e := event.(type) {
+	case events.UserCreated:
+		return e.Tenant
+	case events.UserUpdated:
+		return e.Tenant
+	case events.UserDeleted:
+		return e.Tenant
+	}
+	return ""
+}
+
+func (s *EventStream) record(event events.Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		s.logger.Error("encode event", slog.String("event", event.Name()), slog.String("error", err.Error()))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	e := sseEvent{id: s.nextID, tenant: eventTenant(event), name: event.Name(), data: data}
+	s.history = append(s.history, e)
+	if len(s.history) > s.size {
+		s.history = s.history[len(s.history)-s.size:]
+	}
+	if changed, ok := s.changed[e.tenant]; ok {
+		delete(s.changed, e.tenant)
+		close(changed)
+	}
+	for c, tenant := range s.clients {
+		if tenant != e.tenant {
+			continue
+		}
+		select {
+		case c <- e:
+		default:
+			// too slow, cut it loose rather than block everyone else
+			delete(s.clients, c)
+			close(c)
+		}
+	}
+}
+
+// Changed returns a channel the next event of ctx's tenant closes, making
+// the stream a ChangeNotifier for WithLongPoll. After Close it is always
+// closed.
+func (s *EventStream) Changed(ctx context.Context) <-chan struct{} {
+	tenant, _ := ctxutil.Tenant(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.closed:
+		return s.closed
+	default:
+	}
+	changed, ok := s.changed[tenant]
+	if !ok {
+		changed = make(chan struct{})
+		s.changed[tenant] = changed
+	}
+	return changed
+}
+
+// connect registers a client of tenant and returns that tenant's history
+// after lastID. Both happen under one lock, so no event falls between the
+// replay and the live stream.
+func (s *EventStream) connect(tenant string, lastID uint64) (chan sseEvent, []sseEvent) {
+	c := make(chan sseEvent, clientBuffer)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var missed []sseEvent
+	for _, e := range s.history {
+		if e.id > lastID && e.tenant == tenant {
+			missed = append(missed, e)
+		}
+	}
+	s.clients[c] = tenant
+	return c, missed
+}
+
+func (s *EventStream) disconnect(c chan sseEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.clients[c]; ok {
+		delete(s.clients, c)
+		close(c)
+	}
+}
+
+func (s *EventStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	lastID, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+	tenant, _ := ctxutil.Tenant(r.Context())
+	c, missed := s.connect(tenant, lastID)
+	// the request context ends when the client goes away, which is the
+	// only signal a half-open SSE connection gives
+	defer s.disconnect(c)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", retryMillis)
+	for _, e := range missed {
+		writeEvent(w, e)
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(s.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-c:
+			if !ok {
+				return
+			}
+			writeEvent(w, e)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, e sseEvent) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.id, e.name, e.data)
+}
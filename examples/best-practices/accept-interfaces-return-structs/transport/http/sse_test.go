@@ -0,0 +1,150 @@
+package httptransport_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/eventbus"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/events"
+	httptransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/http"
+)
+
+// streamServer serves an EventStream over bus. served receives once each
+// time a ServeHTTP call returns.
+func streamServer(t *testing.T, bus *eventbus.Bus, opts ...httptransport.EventStreamOption) (*httptest.Server, *httptransport.EventStream, <-chan struct{}) {
+	t.Helper()
+	stream := httptransport.NewEventStream(bus, append(opts, httptransport.WithStreamLogger(quiet))...)
+	served := make(chan struct{}, 8)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stream.ServeHTTP(w, r)
+		served <- struct{}{}
+	}))
+	t.Cleanup(func() {
+		stream.Close()
+		srv.Close()
+	})
+	return srv, stream, served
+}
+
+// subscribe opens a stream, sending lastID as Last-Event-ID unless empty,
+// and returns a function reading the next frame, "" once the stream ends.
+func subscribe(t *testing.T, ctx context.Context, srv *httptest.Server, lastID string) func() string {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+	br := bufio.NewReader(resp.Body)
+	return func() string {
+		var frame strings.Builder
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return frame.String()
+			}
+			if line == "\n" {
+				return frame.String()
+			}
+			frame.WriteString(line)
+		}
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	bus := eventbus.New()
+	srv, _, _ := streamServer(t, bus)
+	next := subscribe(t, context.Background(), srv, "")
+	if got := next(); got != "retry: 3000\n" {
+		t.Fatalf("first frame = %q, want the retry hint", got)
+	}
+	bus.Publish(context.Background(), events.UserCreated{UserID: "ada"})
+	bus.Publish(context.Background(), events.UserDeleted{UserID: "ada"})
+	for _, want := range []string{"id: 1\nevent: " + events.NameUserCreated + "\n", "id: 2\nevent: " + events.NameUserDeleted + "\n"} {
+		got := next()
+		if !strings.HasPrefix(got, want) || !strings.Contains(got, `"ada"`) {
+			t.Errorf("frame = %q, want %q and ada's data", got, want)
+		}
+	}
+}
+
+func TestEventStreamHeartbeat(t *testing.T) {
+	srv, _, _ := streamServer(t, eventbus.New(), httptransport.WithHeartbeat(10*time.Millisecond))
+	next := subscribe(t, context.Background(), srv, "")
+	next()
+	if got := next(); got != ": ping\n" {
+		t.Errorf("idle frame = %q, want a ping comment", got)
+	}
+}
+
+// TestEventStreamReconnect drops a client after the first event and has
+// it reconnect with that event's ID: it is sent the rest, then live ones.
+func TestEventStreamReconnect(t *testing.T) {
+	ctx := context.Background()
+	bus := eventbus.New()
+	srv, _, _ := streamServer(t, bus)
+	first := subscribe(t, ctx, srv, "")
+	first()
+	for _, id := range []string{"ada", "bob", "grace"} {
+		bus.Publish(ctx, events.UserCreated{UserID: id})
+	}
+	for range 3 {
+		first()
+	}
+
+	again := subscribe(t, ctx, srv, "1")
+	if got := again(); got != "retry: 3000\n" {
+		t.Fatalf("first frame = %q, want the retry hint", got)
+	}
+	for _, want := range []string{"id: 2\n", "id: 3\n"} {
+		if got := again(); !strings.HasPrefix(got, want) {
+			t.Errorf("replayed frame = %q, want %q", got, want)
+		}
+	}
+	bus.Publish(ctx, events.UserCreated{UserID: "linus"})
+	if got := again(); !strings.HasPrefix(got, "id: 4\n") {
+		t.Errorf("live frame after the replay = %q, want id 4", got)
+	}
+}
+
+// TestEventStreamDisconnect checks a client going away ends its handler
+// rather than leaving it parked on the subscription, and Close ends the
+// streams still open.
+func TestEventStreamDisconnect(t *testing.T) {
+	srv, stream, served := streamServer(t, eventbus.New())
+	ctx, cancel := context.WithCancel(context.Background())
+	subscribe(t, ctx, srv, "")()
+	cancel()
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the handler kept running after its client disconnected")
+	}
+
+	next := subscribe(t, context.Background(), srv, "")
+	next()
+	stream.Close()
+	if got := next(); got != "" {
+		t.Errorf("frame after Close = %q, want the stream ended", got)
+	}
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the handler kept running after Close")
+	}
+}
@@ -0,0 +1,173 @@
+package httptransport_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/tenanted"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/eventbus"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/idgen"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/middleware"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	httptransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/http"
+)
+
+// tenantServer serves the event stream and the v2 API with long polls
+// behind middleware.Tenant, over a store that keeps tenants apart, the
+// way app wires them.
+func tenantServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	bus := eventbus.New()
+	stream := httptransport.NewEventStream(bus, httptransport.WithStreamLogger(quiet))
+	users := service.NewUserService(tenanted.New(db.NewMemoryStore()), service.WithPublisher(bus), service.WithIDGenerator(idgen.UUIDv7{}))
+	mux := http.NewServeMux()
+	mux.Handle("GET /users/events", stream)
+	mux.Handle("/v2/", http.StripPrefix("/v2", httptransport.NewV2Handler(users, quiet, httptransport.WithLongPoll(stream))))
+	srv := httptest.NewServer(middleware.Tenant()(mux))
+	t.Cleanup(func() {
+		stream.Close()
+		srv.Close()
+	})
+	return srv
+}
+
+// send makes a request as tenant, with headers set in pairs after it.
+func send(t *testing.T, ctx context.Context, srv *httptest.Server, tenant, method, path, body string, headers ...string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(middleware.TenantHeader, tenant)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	return resp
+}
+
+// createAs creates the user with email in tenant.
+func createAs(t *testing.T, srv *httptest.Server, tenant, email string) {
+	t.Helper()
+	resp := send(t, context.Background(), srv, tenant, http.MethodPost, "/v2/users", `{"email":"`+email+`"}`, "Content-Type", "application/json")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create %s in %s = %d, want 201", email, tenant, resp.StatusCode)
+	}
+}
+
+// listen opens tenant's stream and returns a function reading its next
+// frame, skipping the retry hint.
+func listen(t *testing.T, srv *httptest.Server, tenant, lastID string) func() string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	headers := []string{"Accept", "text/event-stream"}
+	if lastID != "" {
+		headers = append(headers, "Last-Event-ID", lastID)
+	}
+	resp := send(t, ctx, srv, tenant, http.MethodGet, "/users/events", "", headers...)
+	t.Cleanup(func() { resp.Body.Close() })
+	br := bufio.NewReader(resp.Body)
+	next := func() string {
+		var frame strings.Builder
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil || line == "\n" {
+				return frame.String()
+			}
+			frame.WriteString(line)
+		}
+	}
+	next()
+	return next
+}
+
+// TestTenantStream checks a tenant's stream carries its own events and
+// nobody else's, live or replayed.
+func TestTenantStream(t *testing.T) {
+	srv := tenantServer(t)
+	acme := listen(t, srv, "acme", "")
+	createAs(t, srv, "globex", "hank@globex.example")
+	createAs(t, srv, "acme", "ada@acme.example")
+	if got := acme(); !strings.Contains(got, "ada@acme.example") || strings.Contains(got, "globex") {
+		t.Errorf("acme's first frame = %q, want ada's create and nothing of globex", got)
+	}
+
+	// a reconnect from the start replays acme's history alone
+	replay := listen(t, srv, "acme", "0")
+	if got := replay(); !strings.Contains(got, "ada@acme.example") {
+		t.Errorf("acme's replay = %q, want ada's create", got)
+	}
+	createAs(t, srv, "globex", "frank@globex.example")
+	createAs(t, srv, "acme", "bob@acme.example")
+	for name, next := range map[string]func() string{"live": acme, "replayed": replay} {
+		if got := next(); !strings.Contains(got, "bob@acme.example") {
+			t.Errorf("%s stream's next frame = %q, want bob's create", name, got)
+		}
+	}
+	globex := listen(t, srv, "globex", "0")
+	for _, want := range []string{"hank@globex.example", "frank@globex.example"} {
+		if got := globex(); !strings.Contains(got, want) {
+			t.Errorf("globex's replay = %q, want %s", got, want)
+		}
+	}
+}
+
+// TestTenantLongPoll checks a long poll is not woken by another tenant's
+// change, and is by its own.
+func TestTenantLongPoll(t *testing.T) {
+	srv := tenantServer(t)
+	createAs(t, srv, "acme", "ada@acme.example")
+	list := send(t, context.Background(), srv, "acme", http.MethodGet, "/v2/users", "")
+	list.Body.Close()
+	etag := list.Header.Get("ETag")
+
+	poll := func(wait time.Duration, change func()) (int, time.Duration) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/v2/users?wait="+wait.String(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(middleware.TenantHeader, "acme")
+		req.Header.Set("If-None-Match", etag)
+		type result struct {
+			resp *http.Response
+			err  error
+		}
+		done := make(chan result, 1)
+		start := time.Now()
+		go func() {
+			resp, err := srv.Client().Do(req)
+			done <- result{resp, err}
+		}()
+		// the poll is waiting by the time the change is made
+		time.Sleep(50 * time.Millisecond)
+		change()
+		r := <-done
+		if r.err != nil {
+			t.Fatalf("GET /v2/users: %v", r.err)
+		}
+		r.resp.Body.Close()
+		return r.resp.StatusCode, time.Since(start)
+	}
+
+	const wait = 300 * time.Millisecond
+	status, took := poll(wait, func() { createAs(t, srv, "globex", "hank@globex.example") })
+	if status != http.StatusNotModified || took < wait {
+		t.Errorf("acme's poll across globex's create = %d after %s, want a 304 after the full %s", status, took, wait)
+	}
+	status, took = poll(10*time.Second, func() { createAs(t, srv, "acme", "bob@acme.example") })
+	if status != http.StatusOK || took > 5*time.Second {
+		t.Errorf("acme's poll across its own create = %d after %s, want a 200 as soon as it changed", status, took)
+	}
+}
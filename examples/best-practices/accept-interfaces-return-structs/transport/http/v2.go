@@ -0,0 +1,168 @@
+package httptransport
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/archive"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/mapper"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// v2 splits the display name into first_name and last_name. The domain
+// keeps a single Name, so these DTOs are an adapter: v2 requests are joined
+// into a Name on the way in and split back out on the way out. Both versions
+// read and write the same users, which is what lets v1 be retired gradually.
+// The rest of each DTO is copied by a mapper, as in v1.
+
+var (
+	createUserV2Mapper   = mapper.MustCompile[createUserRequestV2, service.User]()
+	updateUserV2Mapper   = mapper.MustCompile[updateUserRequestV2, service.User]()
+	userResponseV2Mapper = mapper.MustCompile[service.User, userResponseV2]()
+)
+
+type createUserRequestV2 struct {
+	ID        string `json:"id,omitempty"`
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+func (r createUserRequestV2) toUser() *service.User {
+	user := createUserV2Mapper.Map(&r)
+	user.Name = joinName(r.FirstName, r.LastName)
+	return &user
+}
+
+type updateUserRequestV2 struct {
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Version   int64  `json:"version"`
+}
+
+func (r updateUserRequestV2) toUser(id string) *service.User {
+	user := updateUserV2Mapper.Map(&r)
+	user.ID = id
+	user.Name = joinName(r.FirstName, r.LastName)
+	return &user
+}
+
+type userResponseV2 struct {
+	ID        string     `json:"id"`
+	Email     string     `json:"email"`
+	FirstName string     `json:"first_name"`
+	LastName  string     `json:"last_name"`
+	Version   int64      `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" mapper:"-"`
+}
+
+func newUserResponseV2(user *service.User) userResponseV2 {
+	resp := userResponseV2Mapper.Map(user)
+	resp.FirstName, resp.LastName = splitName(user.Name)
+	if user.Deleted() {
+		deletedAt := user.DeletedAt
+		resp.DeletedAt = &deletedAt
+	}
+	return resp
+}
+
+type listUsersResponseV2 struct {
+	Users      []userResponseV2 `json:"users"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// joinName and splitName round trip for any name whose first part has no
+// space. "Mary Ann Smith" written through v1 reads back from v2 as
+// first "Mary", last "Ann Smith", the lossy edge every such split has.
+func joinName(first, last string) string {
+	return strings.TrimSpace(strings.TrimSpace(first) + " " + strings.TrimSpace(last))
+}
+
+func splitName(name string) (first, last string) {
+	first, last, _ = strings.Cut(name, " ")
+	return first, last
+}
+
+func NewV2Handler(users UserService, logger *slog.Logger, opts ...HandlerOption) *Handler {
+	h := newHandler(users, logger, opts)
+	h.mux.HandleFunc("POST /users", h.idempotent(h.createUserV2))
+	h.mux.HandleFunc("GET /users", h.listUsersV2)
+	h.mux.HandleFunc("GET /users/export", exportNDJSON(h, newUserResponseV2))
+	h.mux.HandleFunc("GET /users/export.zip", h.exportArchive(archive.Zip))
+	h.mux.HandleFunc("GET /users/export.tar.gz", h.exportArchive(archive.TarGz))
+	h.mux.HandleFunc("GET /users/{id}", h.retrieveUserV2)
+	h.mux.HandleFunc("PUT /users/{id}", h.updateUserV2)
+	h.mux.HandleFunc("DELETE /users/{id}", h.deleteUser)
+	// CSV has one name column in either version
+	h.mux.HandleFunc("POST /users.csv", h.importUsers)
+	h.mux.HandleFunc("GET /users.csv", h.exportUsers)
+	return h
+}
+
+func (h *Handler) createUserV2(w http.ResponseWriter, r *http.Request) {
+	var req createUserRequestV2
+	if err := decode(w, r, &req); err != nil {
+		h.error(w, r, err)
+		return
+	}
+	user := req.toUser()
+	if err := h.users.CreateUser(r.Context(), user); err != nil {
+		h.error(w, r, err)
+		return
+	}
+	w.Header().Set("Location", "/v2/users/"+user.ID)
+	h.respond(w, r, http.StatusCreated, newUserResponseV2(user))
+}
+
+func (h *Handler) retrieveUserV2(w http.ResponseWriter, r *http.Request) {
+	user, err := h.users.RetrieveUser(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+	h.respondTagged(w, r, newUserResponseV2(user))
+}
+
+func (h *Handler) listUsersV2(w http.ResponseWriter, r *http.Request) {
+	req, err := pageRequest(r)
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+	h.pollTagged(w, r, func(ctx context.Context) (any, error) {
+		page, err := h.users.ListUsers(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		resp := listUsersResponseV2{Users: make([]userResponseV2, len(page.Items)), NextCursor: page.NextCursor}
+		for i := range page.Items {
+			resp.Users[i] = newUserResponseV2(&page.Items[i])
+		}
+		return resp, nil
+	})
+}
+
+func (h *Handler) updateUserV2(w http.ResponseWriter, r *http.Request) {
+	var req updateUserRequestV2
+	if err := decode(w, r, &req); err != nil {
+		h.error(w, r, err)
+		return
+	}
+	user := req.toUser(r.PathValue("id"))
+	if err := h.users.UpdateUser(r.Context(), user); err != nil {
+		h.error(w, r, err)
+		return
+	}
+	stored, err := h.users.RetrieveUser(r.Context(), user.ID)
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+	h.respond(w, r, http.StatusOK, newUserResponseV2(stored))
+}
@@ -0,0 +1,19 @@
+//go:build !dev
+
+package webtransport
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// dev is false in production builds, see assets_dev.go.
+const dev = false
+
+// files is compiled into the binary, which then needs nothing from the
+// source tree to run.
+//
+//go:embed templates static
+var files embed.FS
+
+func assets() fs.FS { return files }
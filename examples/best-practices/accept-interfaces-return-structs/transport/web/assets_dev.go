@@ -0,0 +1,24 @@
+//go:build dev
+
+package webtransport
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// dev is true with -tags dev: templates and assets are read from the
+// source tree on every request, so edits show without a rebuild.
+const dev = true
+
+// sourceDir is this package's directory as it was compiled, where the
+// templates and static directories are being edited. It only exists on
+// the machine that built the binary, which for a dev build is the point.
+var sourceDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+func assets() fs.FS { return os.DirFS(sourceDir) }
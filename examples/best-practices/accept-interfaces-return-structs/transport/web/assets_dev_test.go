@@ -0,0 +1,7 @@
+//go:build dev
+
+package webtransport_test
+
+// cacheControl is what a dev build sends with the static files, so an
+// edit shows on the next reload.
+const cacheControl = "no-store"
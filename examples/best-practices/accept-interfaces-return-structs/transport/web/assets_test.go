@@ -0,0 +1,6 @@
+//go:build !dev
+
+package webtransport_test
+
+// cacheControl is what a production build sends with the static files.
+const cacheControl = "public, max-age=86400"
@@ -0,0 +1,226 @@
+// Package webtransport serves an HTML page listing users, rendered with
+// html/template from files embedded in the binary with embed.FS, along
+// with the page's stylesheet:
+//
+//	GET /               the user list, ?email= searches, ?cursor= pages
+//	GET /static/{file}  the stylesheet and any other static assets
+//
+// A production build is one self-contained binary: the templates are
+// parsed once, when the Handler is made, so a broken template fails
+// startup instead of a request, and assets are cached by browsers for a
+// day, their URLs carry a hash of the contents so a new build is fetched
+// anyway. Built with -tags dev the same files are read from the source
+// tree instead, templates are parsed again on every request and nothing
+// is cached, so an edit shows on the next reload without a rebuild.
+package webtransport
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/bufpool"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/query"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+)
+
+// pageSize is how many users one page lists.
+const pageSize = 20
+
+// UserService is what the page needs from the service.
+type UserService interface {
+	ListUsers(ctx context.Context, req service.PageRequest, opts ...service.ReadOption) (service.Page[service.User], error)
+}
+
+type Handler struct {
+	users  UserService
+	logger *slog.Logger
+	mux    *http.ServeMux
+	// pages are the parsed templates, nil in dev builds, which parse anew
+	pages map[string]*template.Template
+	// version fingerprints the static assets for their URLs
+	version string
+}
+
+// NewHandler fails if the templates do not parse. In a dev build they are
+// parsed here too, only to report a broken one as early.
+func NewHandler(users UserService, logger *slog.Logger) (*Handler, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	h := &Handler{users: users, logger: logger, mux: http.NewServeMux(), version: "dev"}
+	pages, err := parsePages()
+	if err != nil {
+		return nil, errs.Wrap("webtransport.NewHandler", err)
+	}
+	if !dev {
+		h.pages = pages
+		if h.version, err = fingerprint(); err != nil {
+			return nil, errs.Wrap("webtransport.NewHandler", err)
+		}
+	}
+	static, err := fs.Sub(assets(), "static")
+	if err != nil {
+		return nil, errs.Wrap("webtransport.NewHandler", err)
+	}
+	h.mux.HandleFunc("GET /{$}", h.listUsers)
+	h.mux.Handle("GET /static/", http.StripPrefix("/static/", cacheControl(http.FileServerFS(static))))
+	return h, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// layout is what layout.html needs from every page.
+type layout struct {
+	// Version goes on asset URLs, see fingerprint
+	Version string
+	Dev     bool
+}
+
+// listPage is what users.html renders.
+type listPage struct {
+	layout
+	Users []service.User
+	Email string
+	Next  string
+}
+
+func (h *Handler) listUsers(w http.ResponseWriter, r *http.Request) {
+	email := r.URL.Query().Get("email")
+	req := service.PageRequest{Cursor: r.URL.Query().Get("cursor"), Limit: pageSize}
+	if email != "" {
+		req.EmailLike = "%" + query.EscapeLike(email) + "%"
+	}
+	page, err := h.users.ListUsers(r.Context(), req)
+	if err != nil {
+		h.renderError(w, r, err)
+		return
+	}
+	h.render(w, r, http.StatusOK, "users.html", listPage{
+		layout: h.layout(),
+		Users:  page.Items,
+		Email:  email,
+		Next:   page.NextCursor,
+	})
+}
+
+// render executes into a buffer first, a template failing halfway through
+// would otherwise have sent a 200 and half a page already.
+func (h *Handler) render(w http.ResponseWriter, r *http.Request, code int, name string, data any) {
+	pages := h.pages
+	if pages == nil {
+		var err error
+		if pages, err = parsePages(); err != nil {
+			// dev only, production parsed them in NewHandler
+			h.logger.ErrorContext(r.Context(), "parse templates", slog.Any("error", err))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	buf := bufpool.GetBuffer()
+	defer bufpool.PutBuffer(buf)
+	if err := pages[name].ExecuteTemplate(buf, "layout", data); err != nil {
+		h.logger.ErrorContext(r.Context(), "render "+name, slog.Any("error", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	buf.WriteTo(w)
+}
+
+// errorPage is what error.html renders.
+type errorPage struct {
+	layout
+	Code    int
+	Message string
+}
+
+func (h *Handler) renderError(w http.ResponseWriter, r *http.Request, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, errs.ErrInvalidInput):
+		code = http.StatusBadRequest
+	case errors.Is(err, errs.ErrUnavailable):
+		code = http.StatusServiceUnavailable
+	default:
+		h.logger.ErrorContext(r.Context(), "list users", slog.Any("error", err))
+	}
+	h.render(w, r, code, "error.html", errorPage{layout: h.layout(), Code: code, Message: http.StatusText(code)})
+}
+
+func (h *Handler) layout() layout {
+	return layout{Version: h.version, Dev: dev}
+}
+
+var funcs = template.FuncMap{
+	"date": func(t time.Time) string { return t.Format(time.DateOnly) },
+}
+
+// parsePages parses each page in templates with layout.html. Every page
+// defines the same "title" and "content" blocks for the layout, so each
+// needs a set of its own: in one set, the last page parsed would win.
+func parsePages() (map[string]*template.Template, error) {
+	names, err := fs.Glob(assets(), "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+	pages := make(map[string]*template.Template)
+	for _, name := range names {
+		if name == "templates/layout.html" {
+			continue
+		}
+		t, err := template.New(name).Funcs(funcs).ParseFS(assets(), "templates/layout.html", name)
+		if err != nil {
+			return nil, err
+		}
+		pages[path.Base(name)] = t
+	}
+	return pages, nil
+}
+
+// fingerprint hashes every static asset, so their URLs change with any of
+// them and a day of browser caching never serves a stale one.
+func fingerprint() (string, error) {
+	sum := sha256.New()
+	err := fs.WalkDir(assets(), "static", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := fs.ReadFile(assets(), path)
+		if err != nil {
+			return err
+		}
+		sum.Write([]byte(path))
+		sum.Write(b)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum.Sum(nil))[:12], nil
+}
+
+// cacheControl caches assets for a day, or not at all in a dev build.
+// Embedded files have no modification time, so there is no Last-Modified
+// for a browser to revalidate with, the fingerprint does that job.
+func cacheControl(next http.Handler) http.Handler {
+	value := "public, max-age=86400"
+	if dev {
+		value = "no-store"
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", value)
+		next.ServeHTTP(w, r)
+	})
+}
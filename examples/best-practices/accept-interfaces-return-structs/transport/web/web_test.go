@@ -0,0 +1,143 @@
+package webtransport_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	webtransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/web"
+)
+
+// handler serves the page over 45 users, the first with a script tag
+// for a name.
+func handler(t *testing.T) http.Handler {
+	t.Helper()
+	ctx := context.Background()
+	users := service.NewUserService(db.NewMemoryStore())
+	if err := users.CreateUser(ctx, &service.User{ID: "user-00", Email: "mallory@example.com", Name: "<script>alert(1)</script>"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	for i := 1; i < 45; i++ {
+		if err := users.CreateUser(ctx, &service.User{ID: fmt.Sprintf("user-%02d", i), Email: fmt.Sprintf("user%d@example.com", i), Name: fmt.Sprintf("User %d", i)}); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+	h, err := webtransport.NewHandler(users, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	return h
+}
+
+// get serves a GET of target and returns the response and its body.
+func get(h http.Handler, target string) (*http.Response, string) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	resp := rec.Result()
+	b, _ := io.ReadAll(resp.Body)
+	return resp, string(b)
+}
+
+// rows counts the users listed on page.
+func rows(page string) int {
+	return strings.Count(page, `<td class="id">`)
+}
+
+func TestFirstPage(t *testing.T) {
+	resp, page := get(handler(t), "/")
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Fatalf("GET / = %s %s, want 200 text/html", resp.Status, resp.Header.Get("Content-Type"))
+	}
+	if n := rows(page); n != 20 {
+		t.Errorf("%d rows, want 20", n)
+	}
+	if !strings.Contains(page, "user1@example.com") || !strings.Contains(page, "<title>Users</title>") {
+		t.Error("the page is missing the users or the layout")
+	}
+	if strings.Contains(page, "<script>") || !strings.Contains(page, "&lt;script&gt;") {
+		t.Error("the name with a script tag was not escaped")
+	}
+}
+
+// TestNextLinks follows the next page links, which must keep the search.
+func TestNextLinks(t *testing.T) {
+	h := handler(t)
+	next := regexp.MustCompile(`rel="next" href="([^"]+)"`)
+	seen := 0
+	target := "/?email=example"
+	for range 5 {
+		_, page := get(h, target)
+		seen += rows(page)
+		m := next.FindStringSubmatch(page)
+		if m == nil {
+			break
+		}
+		target = strings.ReplaceAll(m[1], "&amp;", "&")
+		if !strings.Contains(target, "email=example") {
+			t.Fatalf("next link %s lost the search", target)
+		}
+	}
+	if seen != 45 {
+		t.Errorf("%d users over every page, want 45", seen)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	h := handler(t)
+	_, page := get(h, "/?email=user4")
+	if n := rows(page); n != 6 {
+		t.Errorf("%d rows for user4, want 6: user4 and user40 to user44", n)
+	}
+	if !strings.Contains(page, `value="user4"`) {
+		t.Error("the search box does not keep the search")
+	}
+	if _, page := get(h, "/?email=nobody"); !strings.Contains(page, "No users match") {
+		t.Error("no empty state for a search with no matches")
+	}
+}
+
+func TestBadCursor(t *testing.T) {
+	resp, page := get(handler(t), "/?cursor=garbage")
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(page, "<title>400 Bad Request</title>") {
+		t.Errorf("GET with a bad cursor = %s, want a 400 page in the layout\n%s", resp.Status, page)
+	}
+}
+
+func TestStylesheet(t *testing.T) {
+	h := handler(t)
+	_, page := get(h, "/")
+	m := regexp.MustCompile(`href="(/static/style\.css\?v=[^"]+)"`).FindStringSubmatch(page)
+	if m == nil {
+		t.Fatal("no versioned stylesheet link")
+	}
+	resp, _ := get(h, m[1])
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/css") {
+		t.Errorf("GET %s = %s %s, want 200 text/css", m[1], resp.Status, resp.Header.Get("Content-Type"))
+	}
+	if got := resp.Header.Get("Cache-Control"); got != cacheControl {
+		t.Errorf("GET %s: Cache-Control = %q, want %q", m[1], got, cacheControl)
+	}
+}
+
+// TestOnlyEmbedded checks nothing but the embedded static files is served.
+func TestOnlyEmbedded(t *testing.T) {
+	h := handler(t)
+	for _, target := range []string{"/static/missing.css", "/static/../web.go", "/templates/layout.html", "/users"} {
+		resp, _ := get(h, target)
+		if resp.StatusCode == http.StatusTemporaryRedirect {
+			// the mux cleans the .. away first
+			resp, _ = get(h, resp.Header.Get("Location"))
+		}
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s = %s, want 404", target, resp.Status)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package wstransport
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait = 10 * time.Second
+	// pongWait is how long a silent peer is given before it counts as gone,
+	// pings go out a bit more often than that so a healthy peer never is.
+	pongWait       = 60 * time.Second
+	pingPeriod     = pongWait * 9 / 10
+	maxMessageSize = 4096
+	sendBuffer     = 32
+)
+
+// Client is one websocket connection in one room.
+type Client struct {
+	hub  *Hub
+	conn *websocket.Conn
+	room string
+	send chan []byte
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// Handler upgrades requests to websockets and joins them to the room named
+// by the ?room= query parameter. Text a client sends is broadcast to the
+// room when chat is true, a read-only feed such as the user events ignores it.
+func Handler(hub *Hub, chat bool, logger *slog.Logger) http.Handler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		room := r.URL.Query().Get("room")
+		if room == "" {
+			http.Error(w, "room is required", http.StatusBadRequest)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgrade has already written the error response
+			logger.WarnContext(r.Context(), "websocket upgrade", slog.String("error", err.Error()))
+			return
+		}
+		c := &Client{hub: hub, conn: conn, room: room, send: make(chan []byte, sendBuffer)}
+		if !hub.join(c) {
+			conn.Close()
+			return
+		}
+		go c.writePump()
+		go c.readPump(chat)
+	})
+}
+
+// readPump is the only reader of the connection. It must keep running even
+// for a read-only feed, gorilla processes pongs and close frames while reading.
+func (c *Client) readPump(chat bool) {
+	defer func() {
+		c.hub.leave(c)
+		c.conn.Close()
+	}()
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if chat {
+			c.hub.Broadcast(context.Background(), Message{Room: c.room, Data: data})
+		}
+	}
+}
+
+// writePump is the only writer of the connection, gorilla allows one
+// concurrent writer. It sends queued messages and the keepalive pings.
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+	for {
+		select {
+		case data, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// the hub dropped us, tell the peer why
+				c.conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
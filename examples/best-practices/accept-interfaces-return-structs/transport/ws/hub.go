@@ -0,0 +1,124 @@
+// Package wstransport broadcasts messages to websocket clients grouped in
+// rooms. It follows the hub design from the gorilla/websocket chat example:
+// one goroutine owns the room membership, and every connection gets a read
+// pump and a write pump, so no map is shared and no lock is needed.
+package wstransport
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrStopped is returned by Broadcast once Run has returned.
+var ErrStopped = errors.New("wstransport: hub stopped")
+
+// Message is one broadcast to every client in Room.
+type Message struct {
+	Room string
+	Data []byte
+}
+
+// Hub owns the rooms. Everything that changes them goes through a channel
+// and is applied by Run, one at a time.
+type Hub struct {
+	rooms      map[string]map[*Client]struct{}
+	register   chan *Client
+	unregister chan *Client
+	broadcast  chan Message
+	// done is closed when Run returns, so senders never block on a dead hub
+	done chan struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		rooms:      make(map[string]map[*Client]struct{}),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		broadcast:  make(chan Message, 64),
+		done:       make(chan struct{}),
+	}
+}
+
+// Broadcast queues msg for delivery, blocking only while the hub's own
+// queue is full. Slow clients never block it, see Run.
+func (h *Hub) Broadcast(ctx context.Context, msg Message) error {
+	select {
+	case h.broadcast <- msg:
+		return nil
+	case <-h.done:
+		return ErrStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (h *Hub) join(c *Client) bool {
+	select {
+	case h.register <- c:
+		return true
+	case <-h.done:
+		return false
+	}
+}
+
+func (h *Hub) leave(c *Client) {
+	select {
+	case h.unregister <- c:
+	case <-h.done:
+	}
+}
+
+// Run applies registrations and fans out broadcasts until ctx is done, then
+// disconnects every client.
+//
+// Backpressure is per client: each has a buffered send queue, and a client
+// whose queue is full when a message arrives is dropped on the spot. One
+// stalled browser tab would otherwise hold up the whole room.
+func (h *Hub) Run(ctx context.Context) {
+	defer close(h.done)
+	for {
+		select {
+		case c := <-h.register:
+			room := h.rooms[c.room]
+			if room == nil {
+				room = make(map[*Client]struct{})
+				h.rooms[c.room] = room
+			}
+			room[c] = struct{}{}
+		case c := <-h.unregister:
+			h.remove(c)
+		case msg := <-h.broadcast:
+			for c := range h.rooms[msg.Room] {
+				select {
+				case c.send <- msg.Data:
+				default:
+					h.remove(c)
+				}
+			}
+		case <-ctx.Done():
+			for _, room := range h.rooms {
+				for c := range room {
+					h.remove(c)
+				}
+			}
+			return
+		}
+	}
+}
+
+// remove closes c.send, which tells the write pump to say goodbye and hang
+// up. Only Run calls it, so a client is never closed twice.
+func (h *Hub) remove(c *Client) {
+	room, ok := h.rooms[c.room]
+	if !ok {
+		return
+	}
+	if _, ok := room[c]; !ok {
+		return
+	}
+	delete(room, c)
+	close(c.send)
+	if len(room) == 0 {
+		delete(h.rooms, c.room)
+	}
+}
@@ -0,0 +1,341 @@
+// Package ttlcache is a generic cache bounded by time rather than size:
+// every entry has a TTL of its own, and nothing else removes it. Where
+// cache.LRU answers "which entry goes when there is no room", this answers
+// "how long is a value still true", for results that go stale at a known
+// rate, like an external API's verdicts.
+//
+// Entries expire two ways. Lazily, a Get past the deadline finds nothing
+// and drops the entry then. And in the background, a sweeper removes what
+// has expired every SweepInterval, so keys that are never asked for again
+// do not stay in memory forever. The sweep costs only the entries it
+// removes: deadlines are kept in a min-heap, and it stops at the first one
+// still in the future.
+package ttlcache
+
+import (
+	"container/heap"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+)
+
+// DefaultSweepInterval is how often the sweeper runs when WithSweepInterval
+// is not given.
+const DefaultSweepInterval = time.Minute
+
+// Reason says why an entry left the cache.
+type Reason int
+
+const (
+	// Expired entries outlived their TTL, found by a Get or the sweeper.
+	Expired Reason = iota
+	// Removed entries were deleted by Delete or Purge, or replaced by Set.
+	Removed
+)
+
+func (r Reason) String() string {
+	switch r {
+	case Expired:
+		return "expired"
+	case Removed:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// Option configures a Cache.
+type Option func(*options)
+
+type options struct {
+	sweep time.Duration
+	clock clock.Clock
+}
+
+// WithSweepInterval sets how often expired entries are swept, zero or less
+// turns the sweeper off and leaves expiry to Get and DeleteExpired.
+func WithSweepInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.sweep = d
+	}
+}
+
+// WithClock sets the time source for TTLs and the sweeper, the default is
+// clock.Real.
+func WithClock(c clock.Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// Stats counts what the cache has done since New.
+type Stats struct {
+	// Hits and Misses count Get calls, a Get of an expired entry is a miss.
+	Hits, Misses uint64
+	// Expirations counts entries dropped for their TTL.
+	Expirations uint64
+	// Len is the entries held now.
+	Len int
+}
+
+// HitRate is Hits over all Gets, zero before the first.
+func (s Stats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+	// index is the entry's place in the deadline heap, -1 for an entry
+	// without a TTL, which is never in it
+	index int
+}
+
+type eviction[K comparable, V any] struct {
+	key    K
+	value  V
+	reason Reason
+}
+
+// Cache is safe for concurrent use. Close it to stop the sweeper.
+type Cache[K comparable, V any] struct {
+	ttl   time.Duration
+	clock clock.Clock
+
+	mu        sync.Mutex
+	items     map[K]*entry[K, V]
+	deadlines deadlines[K, V]
+	onEvicted []func(K, V, Reason)
+
+	hits, misses, expirations atomic.Uint64
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// New creates a cache whose entries live for ttl unless set with a TTL of
+// their own, zero or less means they never expire. The sweeper starts
+// right away.
+func New[K comparable, V any](ttl time.Duration, opts ...Option) *Cache[K, V] {
+	o := options{sweep: DefaultSweepInterval, clock: clock.Real}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	c := &Cache[K, V]{
+		ttl:   ttl,
+		clock: o.clock,
+		items: make(map[K]*entry[K, V]),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if o.sweep > 0 {
+		go c.sweep(o.sweep)
+	} else {
+		close(c.done)
+	}
+	return c
+}
+
+// OnEvicted adds fn to the callbacks run for every entry that leaves the
+// cache, in the order they were added. They run after the cache's lock is
+// released, so they may call back into the cache, on the goroutine that
+// removed the entry, which for the sweeper is its own.
+func (c *Cache[K, V]) OnEvicted(fn func(key K, value V, reason Reason)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onEvicted = append(c.onEvicted, fn)
+}
+
+// Get returns the value for key, an expired entry is dropped and reported
+// as missing.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	var zero V
+	c.mu.Lock()
+	e, ok := c.items[key]
+	if !ok {
+		c.mu.Unlock()
+		c.misses.Add(1)
+		return zero, false
+	}
+	if c.expired(e, c.clock.Now()) {
+		evicted := c.remove(e, Expired)
+		c.mu.Unlock()
+		c.misses.Add(1)
+		c.notify(evicted)
+		return zero, false
+	}
+	c.mu.Unlock()
+	c.hits.Add(1)
+	return e.value, true
+}
+
+// Set adds or replaces key with the cache's TTL.
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+// SetWithTTL adds or replaces key, expiring it after ttl, zero or less
+// means never.
+func (c *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	var expires time.Time
+	if ttl > 0 {
+		expires = c.clock.Now().Add(ttl)
+	}
+	var evicted []eviction[K, V]
+	c.mu.Lock()
+	if old, ok := c.items[key]; ok {
+		evicted = c.remove(old, Removed)
+	}
+	e := &entry[K, V]{key: key, value: value, expires: expires, index: -1}
+	c.items[key] = e
+	if ttl > 0 {
+		heap.Push(&c.deadlines, e)
+	}
+	c.mu.Unlock()
+	c.notify(evicted)
+}
+
+// Delete removes key, reporting whether it was there, expired or not.
+func (c *Cache[K, V]) Delete(key K) bool {
+	c.mu.Lock()
+	e, ok := c.items[key]
+	if !ok {
+		c.mu.Unlock()
+		return false
+	}
+	evicted := c.remove(e, Removed)
+	c.mu.Unlock()
+	c.notify(evicted)
+	return true
+}
+
+// DeleteExpired removes every entry past its TTL and returns how many it
+// removed, what the sweeper runs on each tick.
+func (c *Cache[K, V]) DeleteExpired() int {
+	var evicted []eviction[K, V]
+	now := c.clock.Now()
+	c.mu.Lock()
+	for len(c.deadlines) > 0 && c.expired(c.deadlines[0], now) {
+		evicted = append(evicted, c.remove(c.deadlines[0], Expired)...)
+	}
+	c.mu.Unlock()
+	c.notify(evicted)
+	return len(evicted)
+}
+
+// Purge empties the cache.
+func (c *Cache[K, V]) Purge() {
+	var evicted []eviction[K, V]
+	c.mu.Lock()
+	for _, e := range c.items {
+		evicted = append(evicted, eviction[K, V]{e.key, e.value, Removed})
+	}
+	c.items = make(map[K]*entry[K, V])
+	c.deadlines = nil
+	c.mu.Unlock()
+	c.notify(evicted)
+}
+
+// Len counts the entries held, including expired ones not yet dropped.
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// Stats returns the cache's counters. Each is read on its own, so under
+// concurrent use they need not add up to one moment.
+func (c *Cache[K, V]) Stats() Stats {
+	return Stats{
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Expirations: c.expirations.Load(),
+		Len:         c.Len(),
+	}
+}
+
+// Close stops the sweeper and waits for a sweep in progress to finish. The
+// cache still works afterwards, expiring lazily.
+func (c *Cache[K, V]) Close() {
+	c.closeOnce.Do(func() { close(c.stop) })
+	<-c.done
+}
+
+func (c *Cache[K, V]) sweep(interval time.Duration) {
+	defer close(c.done)
+	t := c.clock.NewTimer(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-t.C():
+			c.DeleteExpired()
+			t.Reset(interval)
+		}
+	}
+}
+
+// expired must be called with c.mu held.
+func (c *Cache[K, V]) expired(e *entry[K, V], now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// remove must be called with c.mu held.
+func (c *Cache[K, V]) remove(e *entry[K, V], reason Reason) []eviction[K, V] {
+	delete(c.items, e.key)
+	if e.index >= 0 {
+		heap.Remove(&c.deadlines, e.index)
+	}
+	if reason == Expired {
+		c.expirations.Add(1)
+	}
+	return []eviction[K, V]{{e.key, e.value, reason}}
+}
+
+func (c *Cache[K, V]) notify(evicted []eviction[K, V]) {
+	if len(evicted) == 0 {
+		return
+	}
+	c.mu.Lock()
+	fns := c.onEvicted
+	c.mu.Unlock()
+	for _, ev := range evicted {
+		for _, fn := range fns {
+			fn(ev.key, ev.value, ev.reason)
+		}
+	}
+}
+
+// deadlines is a container/heap of the entries with a TTL, soonest first.
+type deadlines[K comparable, V any] []*entry[K, V]
+
+func (d deadlines[K, V]) Len() int           { return len(d) }
+func (d deadlines[K, V]) Less(i, j int) bool { return d[i].expires.Before(d[j].expires) }
+
+func (d deadlines[K, V]) Swap(i, j int) {
+	d[i], d[j] = d[j], d[i]
+	d[i].index = i
+	d[j].index = j
+}
+
+func (d *deadlines[K, V]) Push(x any) {
+	e := x.(*entry[K, V])
+	e.index = len(*d)
+	*d = append(*d, e)
+}
+
+func (d *deadlines[K, V]) Pop() any {
+	old := *d
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	e.index = -1
+	*d = old[:len(old)-1]
+	return e
+}
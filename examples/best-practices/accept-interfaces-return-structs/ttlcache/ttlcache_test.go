@@ -0,0 +1,158 @@
+package ttlcache_test
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/clock"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/ttlcache"
+)
+
+var start = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// waitForSweeper blocks until the sweeper has its timer set, so an Advance
+// after it is one the sweeper sees.
+func waitForSweeper(fake *clock.Fake) {
+	for fake.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLazyExpiry(t *testing.T) {
+	fake := clock.NewFake(start)
+	c := ttlcache.New[string, int](time.Minute, ttlcache.WithClock(fake), ttlcache.WithSweepInterval(0))
+	var reasons []ttlcache.Reason
+	c.OnEvicted(func(_ string, _ int, r ttlcache.Reason) { reasons = append(reasons, r) })
+	c.Set("a", 1)
+	fake.Advance(time.Minute - time.Second)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("missing a second before its TTL")
+	}
+	fake.Advance(time.Second)
+	if c.Len() != 1 {
+		t.Errorf("Len = %d before the Get, want the expired entry still held", c.Len())
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("still there at its TTL")
+	}
+	if stats := c.Stats(); stats != (ttlcache.Stats{Hits: 1, Misses: 1, Expirations: 1}) {
+		t.Errorf("Stats = %+v, want one hit, one miss, one expiry", stats)
+	}
+	if want := []ttlcache.Reason{ttlcache.Expired}; !slices.Equal(reasons, want) {
+		t.Errorf("evicted %v, want %v", reasons, want)
+	}
+}
+
+func TestPerEntryTTL(t *testing.T) {
+	fake := clock.NewFake(start)
+	c := ttlcache.New[string, int](time.Hour, ttlcache.WithClock(fake), ttlcache.WithSweepInterval(0))
+	c.SetWithTTL("short", 1, time.Second)
+	c.Set("default", 2)
+	c.SetWithTTL("forever", 3, 0)
+	for _, tt := range []struct {
+		after time.Duration
+		alive []string
+	}{
+		{0, []string{"short", "default", "forever"}},
+		{time.Second, []string{"default", "forever"}},
+		{time.Hour, []string{"forever"}},
+		{1000 * time.Hour, []string{"forever"}},
+	} {
+		fake.Advance(tt.after)
+		var alive []string
+		for _, k := range []string{"short", "default", "forever"} {
+			if _, ok := c.Get(k); ok {
+				alive = append(alive, k)
+			}
+		}
+		if !slices.Equal(alive, tt.alive) {
+			t.Errorf("%v later alive = %v, want %v", tt.after, alive, tt.alive)
+		}
+	}
+}
+
+// TestSweeper checks the sweeper removes entries nobody asks for.
+func TestSweeper(t *testing.T) {
+	fake := clock.NewFake(start)
+	c := ttlcache.New[string, int](30*time.Second, ttlcache.WithClock(fake), ttlcache.WithSweepInterval(time.Minute))
+	t.Cleanup(c.Close)
+	swept := make(chan string, 10)
+	c.OnEvicted(func(k string, _ int, r ttlcache.Reason) {
+		if r == ttlcache.Expired {
+			swept <- k
+		}
+	})
+	for i := range 3 {
+		c.Set("key-"+strconv.Itoa(i), i)
+	}
+	c.SetWithTTL("later", 9, 2*time.Minute)
+	waitForSweeper(fake)
+	fake.Advance(time.Minute)
+	for range 3 {
+		select {
+		case <-swept:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the sweep did not run")
+		}
+	}
+	if c.Len() != 1 {
+		t.Errorf("Len = %d after the sweep, want only later left", c.Len())
+	}
+	if stats := c.Stats(); stats.Hits+stats.Misses != 0 || stats.Expirations != 3 {
+		t.Errorf("Stats = %+v, want three expirations and no Gets", stats)
+	}
+}
+
+// TestDeleteExpired checks a sweep removes only what has expired.
+func TestDeleteExpired(t *testing.T) {
+	fake := clock.NewFake(start)
+	c := ttlcache.New[int, int](time.Hour, ttlcache.WithClock(fake), ttlcache.WithSweepInterval(0))
+	for i := range 10_000 {
+		c.Set(i, i)
+	}
+	for i := range 10 {
+		c.SetWithTTL(-i-1, i, time.Duration(i+1)*time.Second)
+	}
+	fake.Advance(5 * time.Second)
+	if n := c.DeleteExpired(); n != 5 || c.Len() != 10_005 {
+		t.Errorf("DeleteExpired removed %d leaving %d, want 5 leaving 10005", n, c.Len())
+	}
+}
+
+// TestRemoved checks replacing and deleting are reported as removed.
+func TestRemoved(t *testing.T) {
+	c := ttlcache.New[string, int](time.Minute, ttlcache.WithSweepInterval(0))
+	var got []string
+	c.OnEvicted(func(k string, v int, r ttlcache.Reason) { got = append(got, fmt.Sprintf("%s=%d %s", k, v, r)) })
+	// a callback may call back into the cache, it runs unlocked
+	c.OnEvicted(func(string, int, ttlcache.Reason) { c.Len() })
+	c.Set("a", 1)
+	c.Set("a", 2)
+	c.Delete("a")
+	c.Set("b", 3)
+	c.Purge()
+	if want := []string{"a=1 removed", "a=2 removed", "b=3 removed"}; !slices.Equal(got, want) {
+		t.Errorf("evicted %q, want %q", got, want)
+	}
+}
+
+// TestClose checks Close stops the sweeper and the cache still expires
+// lazily.
+func TestClose(t *testing.T) {
+	fake := clock.NewFake(start)
+	c := ttlcache.New[string, int](time.Second, ttlcache.WithClock(fake), ttlcache.WithSweepInterval(time.Minute))
+	waitForSweeper(fake)
+	c.Close()
+	c.Close()
+	if n := fake.Waiters(); n != 0 {
+		t.Errorf("%d timers still pending after Close", n)
+	}
+	c.Set("a", 1)
+	fake.Advance(time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Error("a outlived its TTL after Close")
+	}
+}
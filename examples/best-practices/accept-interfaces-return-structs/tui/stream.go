@@ -0,0 +1,182 @@
+package tui
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/httpclient"
+)
+
+// defaultRetry is the reconnect delay until the server suggests one, the
+// same 3 seconds an EventSource starts with.
+const defaultRetry = 3 * time.Second
+
+// Event is one user event from the stream. The server sends the events
+// package's types as JSON, which have no tags, hence the field names.
+type Event struct {
+	ID      string    `json:"-"`
+	Name    string    `json:"-"`
+	UserID  string    `json:"UserID"`
+	Email   string    `json:"Email"`
+	Version int64     `json:"Version"`
+	At      time.Time `json:"At"`
+}
+
+// State is the stream's connection, Err is why it was lost.
+type State struct {
+	Connected bool
+	Err       error
+}
+
+// Stream follows the users API's Server-Sent Events at /users/events the
+// way a browser's EventSource does: it reconnects after the delay the
+// server asked for and sends Last-Event-ID, so the server replays what
+// was missed in between.
+//
+// The Doer must not time out whole requests, a stream is one request that
+// lasts as long as the program, so a plain *http.Client rather than an
+// httpclient.Client.
+type Stream struct {
+	doer   httpclient.Doer
+	url    string
+	tenant string
+}
+
+type StreamOption func(*Stream)
+
+// WithTenant follows the events of tenant id, sent as X-Tenant-ID.
+func WithTenant(id string) StreamOption {
+	return func(s *Stream) {
+		s.tenant = id
+	}
+}
+
+// NewStream follows the stream of the API at endpoint, its base URL.
+func NewStream(doer httpclient.Doer, endpoint string, opts ...StreamOption) *Stream {
+	s := &Stream{doer: doer, url: strings.TrimSuffix(endpoint, "/") + "/users/events"}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Watch connects in the background and returns the events and the
+// connection's changes of state. Both channels are closed once ctx is done
+// and the connection with it. They are unbuffered: Watch waits on the
+// reader, which is how a slow one ends up disconnected and caught up by
+// the server rather than buffered here without bound.
+func (s *Stream) Watch(ctx context.Context) (<-chan Event, <-chan State) {
+	events := make(chan Event)
+	states := make(chan State)
+	go func() {
+		defer close(events)
+		defer close(states)
+		lastID, retry := "", defaultRetry
+		for {
+			err := s.connect(ctx, lastID, &retry, func(e Event) bool {
+				lastID = e.ID
+				select {
+				case events <- e:
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			}, func() bool {
+				select {
+				case states <- State{Connected: true}:
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			})
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case states <- State{Err: err}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case <-time.After(retry):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, states
+}
+
+// connect reads one connection until it ends, passing each event to emit.
+// The error is why it ended, the server closing the stream included.
+func (s *Stream) connect(ctx context.Context, lastID string, retry *time.Duration, emit func(Event) bool, connected func() bool) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	if s.tenant != "" {
+		req.Header.Set("X-Tenant-ID", s.tenant)
+	}
+	resp, err := s.doer.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("event stream: %s", resp.Status)
+	}
+	if !connected() {
+		return ctx.Err()
+	}
+
+	var e Event
+	var data strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			// a blank line ends the event, one without data is nothing
+			if data.Len() > 0 {
+				if err := json.Unmarshal([]byte(data.String()), &e); err == nil && !emit(e) {
+					return ctx.Err()
+				}
+			}
+			e = Event{ID: e.ID}
+			data.Reset()
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "":
+			// a comment, the server's heartbeat
+		case "id":
+			e.ID = value
+		case "event":
+			e.Name = value
+		case "data":
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms > 0 {
+				*retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("event stream: closed by the server")
+}
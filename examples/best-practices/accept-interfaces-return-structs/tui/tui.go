@@ -0,0 +1,351 @@
+// Package tui is an interactive terminal browser for the users API, built
+// on bubbletea: a list of every user kept current by the API's event
+// stream, a search over it, and a detail view of one user.
+//
+// Bubbletea is the Elm architecture. The Model is a value, Update returns
+// the next one for each message, and View renders it. Anything slow or
+// concurrent happens in a tea.Cmd, a function bubbletea runs on its own
+// goroutine whose result comes back as a message. The event stream is a
+// goroutine of its own feeding channels, and a Cmd that waits on them is
+// reissued after every message it delivers, so at most one is waiting at
+// a time and the stream is consumed as fast as the UI handles it.
+package tui
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/userctl"
+)
+
+const (
+	// pageSize is how many users each list request asks for.
+	pageSize = 100
+	// maxActivity is how many recent events the activity pane keeps.
+	maxActivity = 5
+	// requestTimeout bounds each API call a Cmd makes.
+	requestTimeout = 5 * time.Second
+)
+
+// Users is what the browser needs from the API, *userctl.Client has it.
+type Users interface {
+	ListUsers(ctx context.Context, cursor string, limit int) (userctl.Page, error)
+	GetUser(ctx context.Context, id string) (userctl.User, error)
+}
+
+// The messages Update handles besides bubbletea's own.
+type (
+	loadedMsg struct {
+		users []userctl.User
+		err   error
+	}
+	fetchedMsg struct {
+		id   string
+		user userctl.User
+		err  error
+	}
+	eventMsg Event
+	stateMsg State
+	// streamClosedMsg is the stream's channels closing, the program is
+	// on its way out
+	streamClosedMsg struct{}
+)
+
+var (
+	titleStyle    = lipgloss.NewStyle().Bold(true)
+	selectedStyle = lipgloss.NewStyle().Reverse(true)
+	changedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	dimStyle      = lipgloss.NewStyle().Faint(true)
+	errorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	labelStyle    = lipgloss.NewStyle().Faint(true).Width(10)
+)
+
+// Model is the browser's whole state. Make one with New.
+type Model struct {
+	ctx    context.Context
+	users  Users
+	events <-chan Event
+	states <-chan State
+
+	all      []userctl.User // every user, sorted by ID
+	loading  bool
+	err      error
+	cursor   int // index into visible()
+	search   string
+	typing   bool // the search box has the keyboard
+	detail   string
+	gone     map[string]bool // users deleted while the browser watched
+	changed  string          // the user the latest event was about
+	activity []string
+	live     bool
+	// listening is set once the first load is in, see Init
+	listening bool
+
+	width, height int
+}
+
+// New returns a browser over users, kept current by stream. ctx ends the
+// stream and the requests in flight, cancel it when the program exits.
+func New(ctx context.Context, users Users, stream *Stream) Model {
+	events, states := stream.Watch(ctx)
+	return Model{ctx: ctx, users: users, events: events, states: states, loading: true, gone: make(map[string]bool)}
+}
+
+// Init loads the users and only then starts on the stream. The server
+// replays its recent history to a new connection, so nothing that happened
+// during the load is missed, and events the load already reflects are told
+// apart by their versions.
+func (m Model) Init() tea.Cmd {
+	return m.load()
+}
+
+// load fetches every page of users. It runs in a Cmd, off the UI's
+// goroutine, and touches nothing of m but its client.
+func (m Model) load() tea.Cmd {
+	users, ctx := m.users, m.ctx
+	return func() tea.Msg {
+		var all []userctl.User
+		cursor := ""
+		for {
+			reqCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+			page, err := users.ListUsers(reqCtx, cursor, pageSize)
+			cancel()
+			if err != nil {
+				return loadedMsg{err: err}
+			}
+			all = append(all, page.Users...)
+			if cursor = page.NextCursor; cursor == "" {
+				return loadedMsg{users: all}
+			}
+		}
+	}
+}
+
+func (m Model) fetch(id string) tea.Cmd {
+	users, ctx := m.users, m.ctx
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+		defer cancel()
+		user, err := users.GetUser(ctx, id)
+		return fetchedMsg{id: id, user: user, err: err}
+	}
+}
+
+// listen waits for the stream's next event or change of state.
+func (m Model) listen() tea.Cmd {
+	events, states := m.events, m.states
+	return func() tea.Msg {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return streamClosedMsg{}
+			}
+			return eventMsg(e)
+		case s, ok := <-states:
+			if !ok {
+				return streamClosedMsg{}
+			}
+			return stateMsg(s)
+		}
+	}
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+	case tea.KeyMsg:
+		return m.key(msg)
+	case loadedMsg:
+		m.loading, m.err = false, msg.err
+		if msg.err == nil {
+			m.all = msg.users
+			slices.SortFunc(m.all, func(a, b userctl.User) int { return strings.Compare(a.ID, b.ID) })
+			m.clamp()
+		}
+		if !m.listening {
+			// a failed load is retried once the stream connects
+			m.listening = true
+			return m, m.listen()
+		}
+	case fetchedMsg:
+		if m.gone[msg.id] {
+			// deleted while the fetch was in flight, the fetch lost
+			break
+		}
+		if msg.err != nil {
+			m.note("fetch %s: %s", msg.id, msg.err)
+			break
+		}
+		m.upsert(msg.user)
+	case eventMsg:
+		e := Event(msg)
+		cmds := []tea.Cmd{m.listen()}
+		i := m.index(e.UserID)
+		switch {
+		case e.Name == "user.deleted":
+			m.gone[e.UserID] = true
+			if i < 0 {
+				// replayed, or about a user deleted before the load
+				return m, tea.Batch(cmds...)
+			}
+			m.remove(e.UserID)
+		case i >= 0 && m.all[i].Version >= e.Version:
+			// replayed, the load already has this version or a later one
+			return m, tea.Batch(cmds...)
+		default:
+			// the event has the email and version but not the name, ask
+			// for the whole user, which is back if it had been deleted
+			delete(m.gone, e.UserID)
+			cmds = append(cmds, m.fetch(e.UserID))
+		}
+		m.changed = e.UserID
+		m.note("%s %s %s", e.At.Local().Format(time.TimeOnly), e.Name, e.UserID)
+		return m, tea.Batch(cmds...)
+	case stateMsg:
+		if m.live = msg.Connected; msg.Err != nil {
+			m.note("stream: %s", msg.Err)
+		}
+		var cmds []tea.Cmd
+		if msg.Connected && m.err != nil {
+			// the API is back, retry the load that failed
+			m.loading, m.err = true, nil
+			cmds = append(cmds, m.load())
+		}
+		return m, tea.Batch(append(cmds, m.listen())...)
+	case streamClosedMsg:
+		m.live = false
+	}
+	return m, nil
+}
+
+func (m Model) key(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if msg.Type == tea.KeyCtrlC {
+		return m, tea.Quit
+	}
+	if m.typing {
+		switch msg.Type {
+		case tea.KeyEnter:
+			m.typing = false
+		case tea.KeyEsc:
+			m.typing, m.search = false, ""
+		case tea.KeyBackspace:
+			if r := []rune(m.search); len(r) > 0 {
+				m.search = string(r[:len(r)-1])
+			}
+		case tea.KeyRunes, tea.KeySpace:
+			m.search += string(msg.Runes)
+		}
+		m.cursor = 0
+		m.clamp()
+		return m, nil
+	}
+	if m.detail != "" {
+		switch msg.String() {
+		case "esc", "backspace", "left", "h":
+			m.detail = ""
+		case "q":
+			return m, tea.Quit
+		}
+		return m, nil
+	}
+	visible := m.visible()
+	switch msg.String() {
+	case "q":
+		return m, tea.Quit
+	case "up", "k":
+		m.cursor--
+	case "down", "j":
+		m.cursor++
+	case "pgup":
+		m.cursor -= m.rows()
+	case "pgdown":
+		m.cursor += m.rows()
+	case "home", "g":
+		m.cursor = 0
+	case "end", "G":
+		m.cursor = len(visible) - 1
+	case "/":
+		m.typing = true
+	case "esc":
+		m.search = ""
+	case "enter", "right", "l":
+		if m.cursor < len(visible) {
+			m.detail = visible[m.cursor].ID
+			return m, m.fetch(m.detail)
+		}
+	case "r":
+		m.loading = true
+		return m, m.load()
+	}
+	m.clamp()
+	return m, nil
+}
+
+// visible is the users matching the search, in ID order.
+func (m Model) visible() []userctl.User {
+	if m.search == "" {
+		return m.all
+	}
+	q := strings.ToLower(m.search)
+	var out []userctl.User
+	for _, u := range m.all {
+		if strings.Contains(strings.ToLower(u.ID+" "+u.Email+" "+u.FirstName+" "+u.LastName), q) {
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+// index is where the user with id is in m.all, -1 when it is not.
+func (m Model) index(id string) int {
+	i, found := slices.BinarySearchFunc(m.all, id, func(u userctl.User, id string) int { return strings.Compare(u.ID, id) })
+	if !found {
+		return -1
+	}
+	return i
+}
+
+// upsert stores user unless m.all has a later version, a fetch can finish
+// after the one a later event started.
+func (m *Model) upsert(user userctl.User) {
+	i, found := slices.BinarySearchFunc(m.all, user.ID, func(u userctl.User, id string) int { return strings.Compare(u.ID, id) })
+	switch {
+	case !found:
+		m.all = slices.Insert(m.all, i, user)
+	case m.all[i].Version < user.Version:
+		m.all[i] = user
+	}
+}
+
+func (m *Model) remove(id string) {
+	m.all = slices.DeleteFunc(m.all, func(u userctl.User) bool { return u.ID == id })
+	m.clamp()
+}
+
+func (m *Model) note(format string, args ...any) {
+	m.activity = append(m.activity, fmt.Sprintf(format, args...))
+	if len(m.activity) > maxActivity {
+		m.activity = m.activity[len(m.activity)-maxActivity:]
+	}
+}
+
+func (m *Model) clamp() {
+	m.cursor = max(0, min(m.cursor, len(m.visible())-1))
+}
+
+// rows is how many users fit on screen. The rest is the header and a
+// blank line, the column names, the search line and a blank line, the
+// activity pane, and the help line.
+func (m Model) rows() int {
+	if m.height == 0 {
+		return 20
+	}
+	return max(1, m.height-maxActivity-7)
+}
@@ -0,0 +1,142 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/userctl"
+)
+
+// View is exactly the terminal's height, rows() users between the header
+// and the activity pane, so bubbletea never has to cut lines off the top.
+func (m Model) View() string {
+	body := m.listView()
+	if m.detail != "" {
+		body = m.detailView()
+	}
+	return strings.Join([]string{m.header(), "", body, "", m.activityView(), dimStyle.Render(m.help())}, "\n")
+}
+
+func (m Model) header() string {
+	stream := changedStyle.Render("● live")
+	if !m.live {
+		stream = errorStyle.Render("○ offline")
+	}
+	count := fmt.Sprintf("%d users", len(m.all))
+	if m.search != "" {
+		count = fmt.Sprintf("%d of %d users", len(m.visible()), len(m.all))
+	}
+	return titleStyle.Render("users") + "  " + count + "  " + stream
+}
+
+func (m Model) listView() string {
+	width := max(m.width, 60)
+	lines := []string{titleStyle.Render(row(width, "ID", "EMAIL", "NAME", "VERSION"))}
+	switch {
+	case m.loading && len(m.all) == 0:
+		lines = append(lines, dimStyle.Render("loading..."))
+	case m.err != nil:
+		lines = append(lines, errorStyle.Render("error: "+m.err.Error()))
+	}
+	visible := m.visible()
+	rows := m.rows() - len(lines) + 1
+	// scroll so the cursor stays on screen
+	start := max(0, m.cursor-rows+1)
+	end := min(len(visible), start+rows)
+	for i := start; i < end; i++ {
+		u := visible[i]
+		line := row(width, u.ID, u.Email, strings.TrimSpace(u.FirstName+" "+u.LastName), fmt.Sprint(u.Version))
+		switch {
+		case i == m.cursor:
+			line = selectedStyle.Render(line)
+		case u.ID == m.changed:
+			line = changedStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	for i := end - start; i < rows; i++ {
+		lines = append(lines, "")
+	}
+	search := ""
+	if m.typing || m.search != "" {
+		search = "/" + m.search
+		if m.typing {
+			search += "█"
+		}
+	}
+	return strings.Join(append(lines, search), "\n")
+}
+
+// row lays out the list's columns to fit width.
+func row(width int, id, email, name, version string) string {
+	idW, versionW := 16, 7
+	emailW := (width - idW - versionW - 3) * 3 / 5
+	nameW := width - idW - versionW - emailW - 3
+	return fit(id, idW) + " " + fit(email, emailW) + " " + fit(name, nameW) + " " + fit(version, versionW)
+}
+
+func fit(s string, width int) string {
+	r := []rune(s)
+	if len(r) > width {
+		return string(r[:max(0, width-1)]) + "…"
+	}
+	return s + strings.Repeat(" ", width-len(r))
+}
+
+// detailView takes the same lines as listView, the header and search
+// line included.
+func (m Model) detailView() string {
+	var lines []string
+	i := slices.IndexFunc(m.all, func(u userctl.User) bool { return u.ID == m.detail })
+	if i < 0 {
+		if m.gone[m.detail] {
+			lines = append(lines, errorStyle.Render(m.detail+" was deleted"))
+		} else {
+			lines = append(lines, dimStyle.Render("loading "+m.detail+"..."))
+		}
+	} else {
+		u := m.all[i]
+		field := func(label, value string) {
+			lines = append(lines, labelStyle.Render(label)+value)
+		}
+		field("id", u.ID)
+		field("email", u.Email)
+		field("first", u.FirstName)
+		field("last", u.LastName)
+		field("version", fmt.Sprint(u.Version))
+		field("created", u.CreatedAt.Local().Format(time.DateTime))
+		field("updated", u.UpdatedAt.Local().Format(time.DateTime))
+		if u.ID == m.changed {
+			lines = append(lines, "", changedStyle.Render("changed by the latest event"))
+		}
+	}
+	for len(lines) < m.rows()+2 {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m Model) activityView() string {
+	lines := []string{titleStyle.Render("activity")}
+	for i := range maxActivity {
+		line := ""
+		if i < len(m.activity) {
+			line = dimStyle.Render(fit(m.activity[i], max(m.width, 60)))
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m Model) help() string {
+	switch {
+	case m.typing:
+		return "type to search · enter keep · esc clear"
+	case m.detail != "":
+		return "esc back · q quit"
+	default:
+		return "↑/↓ move · enter details · / search · r reload · q quit"
+	}
+}
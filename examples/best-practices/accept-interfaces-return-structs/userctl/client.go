@@ -0,0 +1,237 @@
+package userctl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/httpclient"
+)
+
+// maxResponseBytes bounds what is read of a response body, a page of users
+// is well under it.
+const maxResponseBytes = 8 << 20
+
+// User is the v2 API's user. The client declares its own types rather than
+// importing the server's, it only knows the API by its wire format.
+type User struct {
+	ID        string     `json:"id"`
+	Email     string     `json:"email"`
+	FirstName string     `json:"first_name"`
+	LastName  string     `json:"last_name"`
+	Version   int64      `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// NewUser is the body of a create, ID may be empty when the server
+// generates them.
+type NewUser struct {
+	ID        string `json:"id,omitempty"`
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+// Page is one page of a list, NextCursor is empty on the last.
+type Page struct {
+	Users      []User `json:"users"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ImportResult is the server's report on a CSV import.
+type ImportResult struct {
+	Created int              `json:"created"`
+	Failed  int              `json:"failed"`
+	Errors  []ImportRowError `json:"errors,omitempty"`
+}
+
+type ImportRowError struct {
+	Line   int          `json:"line"`
+	ID     string       `json:"id,omitempty"`
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// APIError is a response the API answered with an error status. It
+// unwraps to the errs sentinel for the status, the reverse of the
+// server's mapping, so callers match on errs.ErrNotFound whichever side
+// of the wire the error came from.
+type APIError struct {
+	StatusCode int
+	Message    string
+	Fields     []FieldError
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%d %s", e.StatusCode, e.Message)
+	for _, f := range e.Fields {
+		msg += fmt.Sprintf(", %s %s", f.Field, f.Message)
+	}
+	return msg
+}
+
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return errs.ErrNotFound
+	case http.StatusConflict:
+		return errs.ErrConflict
+	case http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusUnsupportedMediaType, http.StatusRequestEntityTooLarge:
+		return errs.ErrInvalidInput
+	case http.StatusUnauthorized:
+		return errs.ErrUnauthenticated
+	case http.StatusForbidden:
+		return errs.ErrForbidden
+	case http.StatusNotImplemented:
+		return errors.ErrUnsupported
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		// only reaches here through a Doer that does not make them a
+		// httpclient.StatusError
+		return errs.ErrUnavailable
+	}
+	return nil
+}
+
+// Client calls the users v2 REST API. It takes an httpclient.Doer, so the
+// retries are the caller's and a test can point it at an httptest.Server.
+type Client struct {
+	doer     httpclient.Doer
+	endpoint *url.URL
+	apiKey   string
+	tenant   string
+}
+
+type ClientOption func(*Client)
+
+// WithTenant sends id as X-Tenant-ID, the server answers a request
+// without one 400.
+func WithTenant(id string) ClientOption {
+	return func(c *Client) {
+		c.tenant = id
+	}
+}
+
+// NewClient returns a client for the API at endpoint, the server's base
+// URL like "http://localhost:8080". apiKey, when not empty, is sent as
+// X-API-Key, which the server rate limits by.
+func NewClient(doer httpclient.Doer, endpoint, apiKey string, opts ...ClientOption) (*Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errs.Wrap("userctl.NewClient", fmt.Errorf("endpoint %q must be an http or https URL: %w", endpoint, errs.ErrInvalidInput))
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/v2"
+	c := &Client{doer: doer, endpoint: u, apiKey: apiKey}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+func (c *Client) CreateUser(ctx context.Context, user NewUser) (User, error) {
+	var created User
+	body, err := json.Marshal(user)
+	if err != nil {
+		return created, errs.Wrap("userctl.Client.CreateUser", err)
+	}
+	err = c.do(ctx, http.MethodPost, "/users", nil, "application/json", bytes.NewReader(body), http.StatusCreated, &created)
+	return created, errs.Wrap("userctl.Client.CreateUser", err)
+}
+
+func (c *Client) GetUser(ctx context.Context, id string) (User, error) {
+	var user User
+	err := c.do(ctx, http.MethodGet, "/users/"+url.PathEscape(id), nil, "", nil, http.StatusOK, &user)
+	return user, errs.Wrap("userctl.Client.GetUser", err)
+}
+
+// ListUsers returns the page after cursor, the first page for "". limit
+// is the page size, the server's default when 0.
+func (c *Client) ListUsers(ctx context.Context, cursor string, limit int) (Page, error) {
+	var page Page
+	query := url.Values{}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	err := c.do(ctx, http.MethodGet, "/users", query, "", nil, http.StatusOK, &page)
+	return page, errs.Wrap("userctl.Client.ListUsers", err)
+}
+
+func (c *Client) DeleteUser(ctx context.Context, id string) error {
+	return errs.Wrap("userctl.Client.DeleteUser", c.do(ctx, http.MethodDelete, "/users/"+url.PathEscape(id), nil, "", nil, http.StatusNoContent, nil))
+}
+
+// ImportCSV posts r as a text/csv import. Rows the server refused are in
+// the result, not the error.
+func (c *Client) ImportCSV(ctx context.Context, r io.Reader) (ImportResult, error) {
+	var result ImportResult
+	err := c.do(ctx, http.MethodPost, "/users.csv", nil, "text/csv", r, http.StatusOK, &result)
+	return result, errs.Wrap("userctl.Client.ImportCSV", err)
+}
+
+// do sends one request and decodes a want response into dst. A request
+// that got no response at all is errs.ErrUnavailable, as is a 429 or 5xx,
+// which httpclient turns into a StatusError.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, contentType string, body io.Reader, want int, dst any) error {
+	u := *c.endpoint
+	u.Path += path
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.tenant != "" {
+		req.Header.Set("X-Tenant-ID", c.tenant)
+	}
+	resp, err := c.doer.Do(req)
+	if err != nil {
+		if ctx.Err() == nil && !errors.Is(err, errs.ErrUnavailable) {
+			err = fmt.Errorf("%w: %w", errs.ErrUnavailable, err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes))
+	if resp.StatusCode != want {
+		apiErr := &APIError{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+		var errResp struct {
+			Error  string       `json:"error"`
+			Fields []FieldError `json:"fields"`
+		}
+		if dec.Decode(&errResp) == nil && errResp.Error != "" {
+			apiErr.Message, apiErr.Fields = errResp.Error, errResp.Fields
+		}
+		return apiErr
+	}
+	if dst == nil {
+		return nil
+	}
+	if err := dec.Decode(dst); err != nil {
+		return fmt.Errorf("decode %s response: %w", resp.Status, err)
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+package userctl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/httpclient"
+)
+
+type Option func(*cli)
+
+// WithDoer replaces the httpclient.Client the commands would build from
+// the config's timeout, to talk to an httptest.Server.
+func WithDoer(doer httpclient.Doer) Option {
+	return func(c *cli) {
+		c.doer = doer
+	}
+}
+
+// WithLookupEnv replaces os.LookupEnv, so a test can set USERCTL_*
+// variables without touching the process environment.
+func WithLookupEnv(lookupEnv func(string) (string, bool)) Option {
+	return func(c *cli) {
+		c.lookupEnv = lookupEnv
+	}
+}
+
+// cli is what every command shares: the config and client connect builds
+// before a command that calls the API runs.
+type cli struct {
+	doer       httpclient.Doer
+	lookupEnv  func(string) (string, bool)
+	configPath string
+
+	cfg    Config
+	client *Client
+}
+
+// usageError is an error in how userctl was called. It prints as the
+// error it wraps and matches ErrUsage.
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string   { return e.err.Error() }
+func (e *usageError) Unwrap() []error { return []error{ErrUsage, e.err} }
+
+func usage(format string, args ...any) error {
+	return &usageError{fmt.Errorf(format, args...)}
+}
+
+// args wraps a cobra argument check so its failure is a usage error.
+func args(check cobra.PositionalArgs) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if err := check(cmd, args); err != nil {
+			return &usageError{err}
+		}
+		return nil
+	}
+}
+
+// NewCommand returns the userctl command tree. Run it with Execute, or
+// with SetArgs, SetOut, and SetErr first to run it in process.
+func NewCommand(opts ...Option) *cobra.Command {
+	c := &cli{lookupEnv: os.LookupEnv}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	root := &cobra.Command{
+		Use:   "userctl",
+		Short: "Administer users through the users REST API",
+		Long: `userctl administers users through the users REST API.
+
+It finds the API from, each overriding the one before it, a config file
+(--config, USERCTL_CONFIG, or userctl/config.yaml in the user config
+directory), the USERCTL_ENDPOINT, USERCTL_API_KEY, USERCTL_OUTPUT, and
+USERCTL_TIMEOUT environment variables, and the flags below.`,
+		// a name that is not a command lands here rather than in cobra's
+		// own unknown command error, which would not be a usage error
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				return usage("unknown command %q for %q", args[0], cmd.CommandPath())
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	root.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &usageError{err}
+	})
+	pf := root.PersistentFlags()
+	pf.StringVar(&c.configPath, "config", "", "JSON or YAML config file (USERCTL_CONFIG)")
+	pf.String("endpoint", "", "base URL of the users API (USERCTL_ENDPOINT)")
+	pf.String("tenant", "", "tenant whose users to work on (USERCTL_TENANT)")
+	pf.StringP("output", "o", "", "table or json (USERCTL_OUTPUT)")
+	pf.Duration("timeout", 0, "per request timeout (USERCTL_TIMEOUT)")
+
+	root.AddCommand(c.createCommand(), c.getCommand(), c.listCommand(), c.deleteCommand(), c.importCommand(), c.migrateCommand())
+	return root
+}
+
+// connect loads the config and builds the client. It is each API
+// command's PreRunE rather than the root's PersistentPreRunE, so help and
+// completion work whatever state the config is in.
+func (c *cli) connect(cmd *cobra.Command, args []string) error {
+	if c.client != nil {
+		return nil
+	}
+	if err := c.configure(cmd, args); err != nil {
+		return err
+	}
+	doer := c.doer
+	if doer == nil {
+		doer = httpclient.New(httpclient.WithTimeout(c.cfg.Timeout.Duration))
+	}
+	client, err := NewClient(doer, c.cfg.Endpoint, c.cfg.APIKey.Reveal(), WithTenant(c.cfg.Tenant))
+	if err != nil {
+		return &usageError{err}
+	}
+	c.client = client
+	return nil
+}
+
+// configure loads the config alone, for the commands that do not call
+// the API.
+func (c *cli) configure(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	cfg, err := loadConfig(c.configPath, func(cfg *Config) { applyFlags(flags, cfg) }, c.lookupEnv)
+	if err != nil {
+		return &usageError{err}
+	}
+	c.cfg = cfg
+	return nil
+}
+
+// applyFlags copies the global flags set on the command line into cfg.
+func applyFlags(flags *pflag.FlagSet, cfg *Config) {
+	if flags.Changed("endpoint") {
+		cfg.Endpoint, _ = flags.GetString("endpoint")
+	}
+	if flags.Changed("tenant") {
+		cfg.Tenant, _ = flags.GetString("tenant")
+	}
+	if flags.Changed("output") {
+		cfg.Output, _ = flags.GetString("output")
+	}
+	if flags.Changed("timeout") {
+		cfg.Timeout.Duration, _ = flags.GetDuration("timeout")
+	}
+}
+
+func (c *cli) createCommand() *cobra.Command {
+	var user NewUser
+	cmd := &cobra.Command{
+		Use:     "create --email address [--id id] [--first-name name] [--last-name name]",
+		Short:   "Create a user",
+		Args:    args(cobra.NoArgs),
+		PreRunE: c.connect,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if strings.TrimSpace(user.Email) == "" {
+				return usage("--email is required")
+			}
+			created, err := c.client.CreateUser(cmd.Context(), user)
+			if err != nil {
+				return err
+			}
+			return c.printUser(cmd.OutOrStdout(), created)
+		},
+	}
+	cmd.Flags().StringVar(&user.Email, "email", "", "email address, required")
+	cmd.Flags().StringVar(&user.ID, "id", "", "user ID, generated by the server when empty")
+	cmd.Flags().StringVar(&user.FirstName, "first-name", "", "first name")
+	cmd.Flags().StringVar(&user.LastName, "last-name", "", "last name")
+	return cmd
+}
+
+func (c *cli) getCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:     "get id",
+		Short:   "Show a user",
+		Args:    args(cobra.ExactArgs(1)),
+		PreRunE: c.connect,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			user, err := c.client.GetUser(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			return c.printUser(cmd.OutOrStdout(), user)
+		},
+	}
+}
+
+func (c *cli) listCommand() *cobra.Command {
+	var limit, pageSize int
+	cmd := &cobra.Command{
+		Use:     "list [--limit n]",
+		Short:   "List users in ID order, following the pages",
+		Args:    args(cobra.NoArgs),
+		PreRunE: c.connect,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if limit < 0 || pageSize < 0 {
+				return usage("--limit and --page-size cannot be negative")
+			}
+			var users []User
+			cursor := ""
+			for {
+				size := pageSize
+				if limit > 0 && (size == 0 || limit-len(users) < size) {
+					size = limit - len(users)
+				}
+				page, err := c.client.ListUsers(cmd.Context(), cursor, size)
+				if err != nil {
+					return err
+				}
+				users = append(users, page.Users...)
+				cursor = page.NextCursor
+				if cursor == "" || (limit > 0 && len(users) >= limit) {
+					break
+				}
+			}
+			if limit > 0 && len(users) > limit {
+				users = users[:limit]
+			}
+			return c.printUsers(cmd.OutOrStdout(), users)
+		},
+	}
+	cmd.Flags().IntVar(&limit, "limit", 0, "list at most n users, every user when 0")
+	cmd.Flags().IntVar(&pageSize, "page-size", 0, "users per request, the server's default when 0")
+	return cmd
+}
+
+func (c *cli) deleteCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:     "delete id...",
+		Short:   "Soft delete users, stopping at the first that fails",
+		Args:    args(cobra.MinimumNArgs(1)),
+		PreRunE: c.connect,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, id := range args {
+				if err := c.client.DeleteUser(cmd.Context(), id); err != nil {
+					return fmt.Errorf("delete %s: %w", id, err)
+				}
+				if c.cfg.Output == "table" {
+					fmt.Fprintf(cmd.OutOrStdout(), "deleted %s\n", id)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func (c *cli) importCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "import-csv file",
+		Short: "Create users from a CSV file, - for stdin",
+		Long: `Create users from a CSV file with an id,email,name header,
+email required, - reads stdin.
+
+Rows the server refuses are listed and the exit code is 8, the rows before
+and after them are still created.`,
+		Args:    args(cobra.ExactArgs(1)),
+		PreRunE: c.connect,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var r io.Reader = cmd.InOrStdin()
+			if args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				r = f
+			}
+			result, err := c.client.ImportCSV(cmd.Context(), r)
+			if err != nil {
+				return err
+			}
+			if err := c.printImport(cmd.OutOrStdout(), result); err != nil {
+				return err
+			}
+			if result.Failed > 0 {
+				return ErrPartial
+			}
+			return nil
+		},
+	}
+}
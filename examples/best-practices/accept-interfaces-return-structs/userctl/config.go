@@ -0,0 +1,135 @@
+package userctl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/config"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/secret"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/validate"
+)
+
+// Config is where userctl finds the API. It comes from, each overriding
+// the one before it, the defaults, a config file, USERCTL_* environment
+// variables, and the global flags, like the server's own config.
+type Config struct {
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	// APIKey is sent as X-API-Key. It has no flag, a key on the command
+	// line ends up in shell history and ps.
+	APIKey secret.String `json:"api_key" yaml:"api_key"`
+	// Tenant is sent as X-Tenant-ID, the tenant whose users the commands
+	// work on.
+	Tenant  string          `json:"tenant" yaml:"tenant"`
+	Output  string          `json:"output" yaml:"output"`
+	Timeout config.Duration `json:"timeout" yaml:"timeout"`
+}
+
+// DefaultConfig talks to a users API on this machine's default port.
+func DefaultConfig() Config {
+	return Config{
+		Endpoint: "http://localhost:8080",
+		Tenant:   "default",
+		Output:   "table",
+		Timeout:  config.Duration{Duration: 10 * time.Second},
+	}
+}
+
+// defaultConfigPath is read when neither --config nor USERCTL_CONFIG
+// names a file, and skipped when it does not exist.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "userctl", "config.yaml")
+}
+
+// loadConfig builds the configuration. path is --config, flags the global
+// flags that were set on the command line.
+func loadConfig(path string, flags func(*Config), lookupEnv func(string) (string, bool)) (Config, error) {
+	cfg := DefaultConfig()
+	explicit := path != ""
+	if !explicit {
+		path, explicit = lookupEnv("USERCTL_CONFIG")
+	}
+	if !explicit {
+		path = defaultConfigPath()
+	}
+	if path != "" {
+		err := cfg.loadFile(path)
+		if err != nil && (explicit || !errors.Is(err, fs.ErrNotExist)) {
+			return cfg, errs.Wrap("userctl.loadConfig", err)
+		}
+	}
+	for _, env := range []struct {
+		name string
+		set  func(string) error
+	}{
+		{"USERCTL_ENDPOINT", func(v string) error { cfg.Endpoint = v; return nil }},
+		{"USERCTL_API_KEY", func(v string) error { cfg.APIKey = secret.New(v); return nil }},
+		{"USERCTL_TENANT", func(v string) error { cfg.Tenant = v; return nil }},
+		{"USERCTL_OUTPUT", func(v string) error { cfg.Output = v; return nil }},
+		{"USERCTL_TIMEOUT", func(v string) error { return cfg.Timeout.UnmarshalText([]byte(v)) }},
+	} {
+		if v, ok := lookupEnv(env.name); ok {
+			if err := env.set(v); err != nil {
+				return cfg, errs.Wrap("userctl.loadConfig", fmt.Errorf("%s: %w", env.name, err))
+			}
+		}
+	}
+	flags(&cfg)
+	return cfg, errs.Wrap("userctl.loadConfig", cfg.Validate())
+}
+
+// loadFile decodes path over c by its extension, unknown keys are an error.
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		dec := json.NewDecoder(f)
+		dec.DisallowUnknownFields()
+		err = dec.Decode(c)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(f)
+		dec.KnownFields(true)
+		err = dec.Decode(c)
+		if errors.Is(err, io.EOF) {
+			// a file of comments, or one with every key commented out
+			err = nil
+		}
+	default:
+		return fmt.Errorf("%s: unsupported config format %q: %w", path, ext, errs.ErrInvalidInput)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once as a *validate.ValidationError.
+func (c Config) Validate() error {
+	var v validate.Errors
+	v.Required("endpoint", c.Endpoint)
+	v.Required("tenant", c.Tenant)
+	if c.Output != "table" && c.Output != "json" {
+		v.Add("output", "must be table or json")
+	}
+	if c.Timeout.Duration <= 0 {
+		v.Add("timeout", "must be positive")
+	}
+	return v.Err()
+}
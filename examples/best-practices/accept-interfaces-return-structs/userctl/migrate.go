@@ -0,0 +1,209 @@
+package userctl
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/spf13/cobra"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/postgres"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db/sqlite"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/migrate"
+)
+
+// migrators are the stores migrate works on by --driver: the database/sql
+// driver that opens the DSN and the store's own migrations.
+var migrators = map[string]struct {
+	sqlDriver string
+	migrator  func(*sql.DB) (*migrate.Migrator, error)
+}{
+	"sqlite":   {"sqlite", sqlite.NewMigrator},
+	"postgres": {"pgx", postgres.NewMigrator},
+}
+
+// migration is a version as migrate prints it in JSON.
+type migration struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	Unknown   bool       `json:"unknown,omitempty"`
+}
+
+func (c *cli) migrateCommand() *cobra.Command {
+	var driver, dsn string
+	cmd := &cobra.Command{
+		Use:   "migrate up|down|status --driver name --dsn source",
+		Short: "Apply, roll back, or list a store's schema migrations",
+		Long: `Apply, roll back, or list the schema migrations of the sqlite or
+postgres store, on the database itself rather than through the API.
+
+The DSN is a file for sqlite and a URL for postgres. USERCTL_DSN is read
+when --dsn is not given, a password in a flag ends up in shell history.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				return usage("unknown command %q for %q", args[0], cmd.CommandPath())
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	f := cmd.PersistentFlags()
+	f.StringVar(&driver, "driver", "sqlite", "store: "+strings.Join(slices.Sorted(maps.Keys(migrators)), " or "))
+	f.StringVar(&dsn, "dsn", "", "database to migrate (USERCTL_DSN)")
+
+	// open is each subcommand's: the config, for the output format, and
+	// the migrator on a database that the returned close releases.
+	open := func(cmd *cobra.Command, args []string) (*migrate.Migrator, func() error, error) {
+		if err := c.configure(cmd, args); err != nil {
+			return nil, nil, err
+		}
+		m, ok := migrators[driver]
+		if !ok {
+			return nil, nil, usage("unknown --driver %q, want %s", driver, strings.Join(slices.Sorted(maps.Keys(migrators)), " or "))
+		}
+		if dsn == "" {
+			dsn, _ = c.lookupEnv("USERCTL_DSN")
+		}
+		if dsn == "" {
+			return nil, nil, usage("--dsn or USERCTL_DSN is required")
+		}
+		db, err := sql.Open(m.sqlDriver, dsn)
+		if err != nil {
+			return nil, nil, err
+		}
+		migrator, err := m.migrator(db)
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		return migrator, db.Close, nil
+	}
+
+	var to int
+	up := &cobra.Command{
+		Use:   "up [--to version]",
+		Short: "Apply the pending migrations",
+		Args:  args(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, closeDB, err := open(cmd, args)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+			var applied []migrate.Migration
+			if to > 0 {
+				applied, err = m.UpTo(cmd.Context(), to)
+			} else {
+				applied, err = m.Up(cmd.Context())
+			}
+			// the ones applied before a failure are still printed
+			if printErr := c.printMigrations(cmd.OutOrStdout(), "applied", applied); err == nil {
+				err = printErr
+			}
+			return err
+		},
+	}
+	up.Flags().IntVar(&to, "to", 0, "apply the versions up to this one, all of them when 0")
+
+	var steps int
+	down := &cobra.Command{
+		Use:   "down [--steps n]",
+		Short: "Roll back the latest migrations",
+		Args:  args(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if steps < 1 {
+				return usage("--steps must be at least 1")
+			}
+			m, closeDB, err := open(cmd, args)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+			reverted, err := m.Down(cmd.Context(), steps)
+			if printErr := c.printMigrations(cmd.OutOrStdout(), "rolled back", reverted); err == nil {
+				err = printErr
+			}
+			return err
+		},
+	}
+	down.Flags().IntVar(&steps, "steps", 1, "how many versions to roll back")
+
+	status := &cobra.Command{
+		Use:   "status",
+		Short: "List every version and when it was applied",
+		Args:  args(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, closeDB, err := open(cmd, args)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+			statuses, err := m.Status(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return c.printMigrationStatus(cmd.OutOrStdout(), statuses)
+		},
+	}
+
+	cmd.AddCommand(up, down, status)
+	return cmd
+}
+
+// printMigrations writes what up or down did, a line each, or as an array
+// in JSON even when it is empty.
+func (c *cli) printMigrations(w io.Writer, did string, migrations []migrate.Migration) error {
+	if c.cfg.Output == "json" {
+		out := make([]migration, 0, len(migrations))
+		for _, m := range migrations {
+			out = append(out, migration{Version: m.Version, Name: m.Name})
+		}
+		return printJSON(w, out)
+	}
+	if len(migrations) == 0 {
+		_, err := fmt.Fprintln(w, "nothing to do")
+		return err
+	}
+	for _, m := range migrations {
+		if _, err := fmt.Fprintf(w, "%s %04d %s\n", did, m.Version, m.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *cli) printMigrationStatus(w io.Writer, statuses []migrate.Status) error {
+	if c.cfg.Output == "json" {
+		out := make([]migration, 0, len(statuses))
+		for _, s := range statuses {
+			m := migration{Version: s.Version, Name: s.Name, Unknown: s.Unknown}
+			if !s.AppliedAt.IsZero() {
+				m.AppliedAt = &s.AppliedAt
+			}
+			out = append(out, m)
+		}
+		return printJSON(w, out)
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+	for _, s := range statuses {
+		applied := "pending"
+		if !s.AppliedAt.IsZero() {
+			applied = s.AppliedAt.Format(time.RFC3339)
+		}
+		if s.Unknown {
+			applied += ", no file"
+		}
+		fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,70 @@
+package userctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// printUser writes the user create or get returned, as an object in JSON.
+func (c *cli) printUser(w io.Writer, user User) error {
+	if c.cfg.Output == "json" {
+		return printJSON(w, user)
+	}
+	return printTable(w, []User{user})
+}
+
+// printUsers writes a list, as an array in JSON even when it is empty, so
+// a script piping to jq never has to special case null.
+func (c *cli) printUsers(w io.Writer, users []User) error {
+	if c.cfg.Output == "json" {
+		if users == nil {
+			users = []User{}
+		}
+		return printJSON(w, users)
+	}
+	return printTable(w, users)
+}
+
+func printTable(w io.Writer, users []User) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tEMAIL\tNAME\tVERSION\tCREATED\tDELETED")
+	for _, u := range users {
+		deleted := ""
+		if u.DeletedAt != nil {
+			deleted = u.DeletedAt.Format(time.RFC3339)
+		}
+		name := strings.TrimSpace(u.FirstName + " " + u.LastName)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n", u.ID, u.Email, name, u.Version, u.CreatedAt.Format(time.RFC3339), deleted)
+	}
+	return tw.Flush()
+}
+
+func (c *cli) printImport(w io.Writer, result ImportResult) error {
+	if c.cfg.Output == "json" {
+		return printJSON(w, result)
+	}
+	fmt.Fprintf(w, "created %d, failed %d\n", result.Created, result.Failed)
+	if len(result.Errors) == 0 {
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "LINE\tID\tERROR")
+	for _, e := range result.Errors {
+		msg := e.Error
+		for _, f := range e.Fields {
+			msg += fmt.Sprintf(", %s %s", f.Field, f.Message)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", e.Line, e.ID, msg)
+	}
+	return tw.Flush()
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,113 @@
+// Package userctl is the users admin CLI, a cobra command tree over a
+// client for the v2 REST API:
+//
+//	userctl create --email ada@example.com --first-name Ada
+//	userctl get ada
+//	userctl list --limit 20 -o json
+//	userctl delete ada grace
+//	userctl import-csv users.csv
+//
+// and, on the database itself rather than through the API, the sqlite
+// and postgres stores' schema migrations:
+//
+//	userctl migrate up --driver sqlite --dsn users.db
+//	userctl migrate down --steps 1
+//	userctl migrate status -o json
+//
+// The tree is built by NewCommand and run by Execute, which turns the
+// error into an exit code, so cmd/userctl is two lines and a test can run
+// any command in process against an httptest.Server.
+//
+// Exit codes, for scripts to branch on:
+//
+//	0  success
+//	1  anything else: a 500, a response that would not decode
+//	2  usage: an unknown command or flag, a missing argument, a bad config
+//	3  not found
+//	4  conflict: a duplicate ID or email
+//	5  invalid input, the server refused the request's values
+//	6  unavailable: no response, a timeout, a 429 or 503 after retries
+//	7  unauthenticated or forbidden
+//	8  partial: an import that created some rows and refused others
+package userctl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+const (
+	ExitOK          = 0
+	ExitFailure     = 1
+	ExitUsage       = 2
+	ExitNotFound    = 3
+	ExitConflict    = 4
+	ExitInvalid     = 5
+	ExitUnavailable = 6
+	ExitDenied      = 7
+	ExitPartial     = 8
+)
+
+// ErrUsage marks an error in how userctl was called rather than in what
+// the API said.
+var ErrUsage = errors.New("usage")
+
+// ErrPartial is an import that finished with rows refused. The rows are
+// printed, the error only sets the exit code.
+var ErrPartial = errors.New("some rows failed")
+
+// ExitCode maps an error from a command to its exit code.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, ErrUsage):
+		return ExitUsage
+	case errors.Is(err, ErrPartial):
+		return ExitPartial
+	case errors.Is(err, errs.ErrNotFound):
+		return ExitNotFound
+	case errors.Is(err, errs.ErrConflict):
+		return ExitConflict
+	case errors.Is(err, errs.ErrInvalidInput):
+		return ExitInvalid
+	case errors.Is(err, errs.ErrUnavailable), errors.Is(err, context.DeadlineExceeded):
+		return ExitUnavailable
+	case errors.Is(err, errs.ErrUnauthenticated), errors.Is(err, errs.ErrForbidden):
+		return ExitDenied
+	default:
+		return ExitFailure
+	}
+}
+
+// Execute runs cmd, prints its error to cmd's stderr, and returns the exit
+// code. An error the API answered is printed as the API said it, without
+// the chain of operations that carried it back.
+func Execute(ctx context.Context, cmd *cobra.Command) int {
+	err := cmd.ExecuteContext(ctx)
+	if err == nil {
+		return ExitOK
+	}
+	code := ExitCode(err)
+	if code != ExitPartial {
+		printError(cmd.ErrOrStderr(), cmd, err)
+	}
+	return code
+}
+
+func printError(w io.Writer, cmd *cobra.Command, err error) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		err = apiErr
+	}
+	fmt.Fprintf(w, "Error: %s\n", err)
+	if errors.Is(err, ErrUsage) {
+		fmt.Fprintf(w, "Run '%s --help' for usage.\n", cmd.CommandPath())
+	}
+}
@@ -0,0 +1,393 @@
+package userctl_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/userctl"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/userctl/userctltest"
+)
+
+// cli runs userctl in process against a fake users API.
+type cli struct {
+	t   *testing.T
+	srv *userctltest.Server
+	// env is the environment every run sees, USERCTL_ENDPOINT pointing
+	// at srv and USERCTL_CONFIG at an empty file, so a config in the
+	// user's own config directory cannot change what the tests see
+	env map[string]string
+}
+
+func newCLI(t *testing.T) *cli {
+	t.Helper()
+	srv := userctltest.NewServer()
+	t.Cleanup(srv.Close)
+	noConfig := filepath.Join(t.TempDir(), "none.yaml")
+	if err := os.WriteFile(noConfig, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return &cli{t: t, srv: srv, env: map[string]string{"USERCTL_ENDPOINT": srv.URL, "USERCTL_CONFIG": noConfig}}
+}
+
+// result is one run of userctl.
+type result struct {
+	code           int
+	stdout, stderr string
+}
+
+// run runs userctl with args and stdin, env on top of c.env.
+func (c *cli) run(env map[string]string, stdin string, args ...string) result {
+	vars := maps.Clone(c.env)
+	maps.Copy(vars, env)
+	cmd := userctl.NewCommand(userctl.WithLookupEnv(func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}))
+	var stdout, stderr bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetIn(strings.NewReader(stdin))
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+	code := userctl.Execute(context.Background(), cmd)
+	return result{code, stdout.String(), stderr.String()}
+}
+
+// want fails the test unless r exited with code, with nothing on stderr
+// when that is success.
+func (c *cli) want(r result, code int) {
+	c.t.Helper()
+	if r.code != code {
+		c.t.Fatalf("exit code %d, want %d\nstdout: %q\nstderr: %q", r.code, code, r.stdout, r.stderr)
+	}
+	if code == userctl.ExitOK && r.stderr != "" {
+		c.t.Errorf("stderr on success: %q", r.stderr)
+	}
+}
+
+// seed creates n users straight through the server's service, and
+// forgets nothing was requested.
+func (c *cli) seed(n int) {
+	c.t.Helper()
+	for i := range n {
+		id := fmt.Sprintf("bulk-%02d", i)
+		if err := c.srv.Users.CreateUser(context.Background(), &service.User{ID: id, Email: id + "@example.com"}); err != nil {
+			c.t.Fatalf("CreateUser: %v", err)
+		}
+	}
+}
+
+// decode decodes r's stdout as JSON into v.
+func decode[T any](t *testing.T, r result) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal([]byte(r.stdout), &v); err != nil {
+		t.Fatalf("%v in %q", err, r.stdout)
+	}
+	return v
+}
+
+func TestCreate(t *testing.T) {
+	c := newCLI(t)
+	r := c.run(nil, "", "create", "--id", "ada", "--email", "ada@example.com", "--first-name", "Ada", "--last-name", "Lovelace")
+	c.want(r, userctl.ExitOK)
+	lines := strings.Split(strings.TrimSpace(r.stdout), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "ID") || !strings.Contains(lines[1], "ada@example.com") || !strings.Contains(lines[1], "Ada Lovelace") {
+		t.Errorf("printed %q, want a header and ada's row", r.stdout)
+	}
+	if reqs := c.srv.Requests(); len(reqs) != 1 || reqs[0].Method != http.MethodPost || reqs[0].Path != "/v2/users" {
+		t.Errorf("requests = %+v, want one POST /v2/users", reqs)
+	}
+
+	// without an ID the server picks one
+	r = c.run(nil, "", "create", "--email", "grace@example.com", "-o", "json")
+	c.want(r, userctl.ExitOK)
+	if user := decode[userctl.User](t, r); user.ID != "user-1" || user.Version != 1 {
+		t.Errorf("created %+v, want user-1 at version 1", user)
+	}
+}
+
+func TestGetJSON(t *testing.T) {
+	c := newCLI(t)
+	c.want(c.run(nil, "", "create", "--id", "ada", "--email", "ada@example.com", "--first-name", "Ada"), userctl.ExitOK)
+	r := c.run(nil, "", "get", "ada", "-o", "json")
+	c.want(r, userctl.ExitOK)
+	if user := decode[userctl.User](t, r); user.ID != "ada" || user.FirstName != "Ada" || user.CreatedAt.IsZero() {
+		t.Errorf("got %+v, want ada", user)
+	}
+}
+
+func TestList(t *testing.T) {
+	c := newCLI(t)
+	c.seed(25)
+	c.srv.Requests()
+
+	t.Run("follows the pages to the end", func(t *testing.T) {
+		r := c.run(nil, "", "list", "--page-size", "10", "-o", "json")
+		c.want(r, userctl.ExitOK)
+		if users := decode[[]userctl.User](t, r); len(users) != 25 || users[0].ID != "bulk-00" || users[24].ID != "bulk-24" {
+			t.Errorf("listed %d users, want bulk-00..bulk-24", len(users))
+		}
+		if reqs := c.srv.Requests(); len(reqs) != 3 {
+			t.Errorf("%d requests, want 3 pages", len(reqs))
+		}
+	})
+
+	t.Run("stops asking at the limit", func(t *testing.T) {
+		r := c.run(nil, "", "list", "--limit", "5")
+		c.want(r, userctl.ExitOK)
+		if rows := strings.Count(r.stdout, "\n") - 1; rows != 5 {
+			t.Errorf("%d rows, want 5", rows)
+		}
+		if reqs := c.srv.Requests(); len(reqs) != 1 || !strings.Contains(reqs[0].Path, "limit=5") {
+			t.Errorf("requests = %+v, want one asking for 5", reqs)
+		}
+	})
+
+	t.Run("empty is [] in JSON", func(t *testing.T) {
+		empty := userctltest.NewServer()
+		defer empty.Close()
+		r := c.run(map[string]string{"USERCTL_ENDPOINT": empty.URL}, "", "list", "-o", "json")
+		c.want(r, userctl.ExitOK)
+		if got := strings.TrimSpace(r.stdout); got != "[]" {
+			t.Errorf("printed %q, want []", got)
+		}
+	})
+}
+
+func TestDelete(t *testing.T) {
+	c := newCLI(t)
+	c.seed(3)
+	r := c.run(nil, "", "delete", "bulk-00", "bulk-01")
+	c.want(r, userctl.ExitOK)
+	if r.stdout != "deleted bulk-00\ndeleted bulk-01\n" {
+		t.Errorf("printed %q, want a line per user", r.stdout)
+	}
+	r = c.run(nil, "", "get", "bulk-00")
+	c.want(r, userctl.ExitNotFound)
+	if r.stderr != "Error: 404 Not Found\n" || r.stdout != "" {
+		t.Errorf("stderr %q, stdout %q, want only the 404 on stderr", r.stderr, r.stdout)
+	}
+
+	// the first failure stops the rest
+	c.srv.Requests()
+	r = c.run(nil, "", "delete", "nobody", "bulk-02")
+	c.want(r, userctl.ExitNotFound)
+	if !strings.Contains(r.stderr, "404") {
+		t.Errorf("stderr = %q, want the 404", r.stderr)
+	}
+	if reqs := c.srv.Requests(); len(reqs) != 1 {
+		t.Errorf("%d requests, want none after the failure", len(reqs))
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	c := newCLI(t)
+	c.want(c.run(nil, "", "create", "--id", "ada", "--email", "ada@example.com"), userctl.ExitOK)
+	for _, tt := range []struct {
+		name string
+		args []string
+		code int
+		says string
+	}{
+		{"conflict", []string{"create", "--id", "ada", "--email", "ada2@example.com"}, userctl.ExitConflict, "409 Conflict"},
+		{"invalid", []string{"create", "--email", "not an address"}, userctl.ExitInvalid, "Email"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := c.run(nil, "", tt.args...)
+			c.want(r, tt.code)
+			if !strings.Contains(r.stderr, tt.says) {
+				t.Errorf("stderr = %q, want it to say %q", r.stderr, tt.says)
+			}
+		})
+	}
+}
+
+// TestUsageErrors checks mistakes in the invocation exit 2 with a hint,
+// before anything is sent.
+func TestUsageErrors(t *testing.T) {
+	c := newCLI(t)
+	for _, args := range [][]string{
+		{"create", "--first-name", "Ada"},
+		{"get"},
+		{"get", "a", "b"},
+		{"list", "--limit", "many"},
+		{"list", "--bogus"},
+		{"frobnicate"},
+		{"list", "-o", "yaml"},
+	} {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			r := c.run(nil, "", args...)
+			c.want(r, userctl.ExitUsage)
+			if !strings.Contains(r.stderr, "--help' for usage") {
+				t.Errorf("stderr = %q, want the --help hint", r.stderr)
+			}
+		})
+	}
+	if reqs := c.srv.Requests(); len(reqs) != 0 {
+		t.Errorf("%d requests sent, want none", len(reqs))
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	c := newCLI(t)
+	t.Run("refused rows exit 8", func(t *testing.T) {
+		csv := "id,email,name\nlin,lin@example.com,Lin Lee\nbad,nope,Bad Row\nmo,mo@example.com,\n"
+		r := c.run(nil, csv, "import-csv", "-")
+		c.want(r, userctl.ExitPartial)
+		if !strings.HasPrefix(r.stdout, "created 2, failed 1\n") || !strings.Contains(r.stdout, "bad") || r.stderr != "" {
+			t.Errorf("stdout %q, stderr %q, want the counts and the refused row on stdout", r.stdout, r.stderr)
+		}
+	})
+	t.Run("from a file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ok.csv")
+		if err := os.WriteFile(path, []byte("email\nzed@example.com\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		r := c.run(nil, "", "import-csv", path, "-o", "json")
+		c.want(r, userctl.ExitOK)
+		if result := decode[userctl.ImportResult](t, r); result.Created != 1 {
+			t.Errorf("result = %+v, want 1 created", result)
+		}
+	})
+}
+
+// TestConfigPrecedence checks flags beat the environment, which beats the
+// config file.
+func TestConfigPrecedence(t *testing.T) {
+	c := newCLI(t)
+	c.want(c.run(nil, "", "create", "--id", "ada", "--email", "ada@example.com"), userctl.ExitOK)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("endpoint: http://file.invalid\napi_key: from-file\noutput: json\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c.srv.Requests()
+
+	// the file's endpoint is overridden twice, its key and output are not
+	env := map[string]string{"USERCTL_CONFIG": path, "USERCTL_ENDPOINT": "http://env.invalid"}
+	r := c.run(env, "", "get", "ada", "--endpoint", c.srv.URL)
+	c.want(r, userctl.ExitOK)
+	if !json.Valid([]byte(r.stdout)) {
+		t.Errorf("printed %q, want the file's json output", r.stdout)
+	}
+	if reqs := c.srv.Requests(); len(reqs) != 1 || reqs[0].APIKey != "from-file" {
+		t.Errorf("requests = %+v, want one with the file's key", reqs)
+	}
+
+	env["USERCTL_ENDPOINT"], env["USERCTL_API_KEY"] = c.srv.URL, "from-env"
+	c.want(c.run(env, "", "get", "ada"), userctl.ExitOK)
+	if reqs := c.srv.Requests(); len(reqs) != 1 || reqs[0].APIKey != "from-env" || reqs[0].Tenant != "default" {
+		t.Errorf("requests = %+v, want one with the environment's key in the default tenant", reqs)
+	}
+
+	env["USERCTL_TENANT"] = "globex"
+	c.want(c.run(env, "", "get", "ada", "--tenant", "acme"), userctl.ExitOK)
+	if reqs := c.srv.Requests(); len(reqs) != 1 || reqs[0].Tenant != "acme" {
+		t.Errorf("requests = %+v, want one for the flag's tenant", reqs)
+	}
+}
+
+// TestBrokenConfig checks a bad config file is a usage error, and that
+// help still works with one.
+func TestBrokenConfig(t *testing.T) {
+	c := newCLI(t)
+	path := filepath.Join(t.TempDir(), "typo.yaml")
+	if err := os.WriteFile(path, []byte("endpoint: "+c.srv.URL+"\noutptu: json\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{"USERCTL_CONFIG": path}
+	c.want(c.run(env, "", "list"), userctl.ExitUsage)
+	c.want(c.run(map[string]string{"USERCTL_CONFIG": filepath.Join(t.TempDir(), "nowhere.yaml")}, "", "list"), userctl.ExitUsage)
+	r := c.run(env, "", "list", "--help")
+	c.want(r, userctl.ExitOK)
+	if !strings.Contains(r.stdout, "--page-size") {
+		t.Errorf("help = %q, want list's flags", r.stdout)
+	}
+}
+
+func TestUnavailable(t *testing.T) {
+	c := newCLI(t)
+	t.Run("down", func(t *testing.T) {
+		c.srv.Fail(http.StatusServiceUnavailable)
+		defer c.srv.Fail(0)
+		c.want(c.run(nil, "", "get", "ada"), userctl.ExitUnavailable)
+		// GET is safe to repeat, the client tried more than once
+		if reqs := c.srv.Requests(); len(reqs) < 2 {
+			t.Errorf("%d attempts, want retries", len(reqs))
+		}
+	})
+	t.Run("unreachable", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		closed := "http://" + ln.Addr().String()
+		ln.Close()
+		c.want(c.run(map[string]string{"USERCTL_ENDPOINT": closed}, "", "list", "--timeout", "2s"), userctl.ExitUnavailable)
+	})
+}
+
+func TestDenied(t *testing.T) {
+	c := newCLI(t)
+	for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			c.srv.Fail(status)
+			defer c.srv.Fail(0)
+			c.want(c.run(nil, "", "delete", "ada"), userctl.ExitDenied)
+		})
+	}
+}
+
+// TestExitCode checks the code follows the error however it was wrapped.
+func TestExitCode(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"wrapped API error", fmt.Errorf("delete ada: %w", &userctl.APIError{StatusCode: http.StatusNotFound}), userctl.ExitNotFound},
+		{"anything else", errors.New("boom"), userctl.ExitFailure},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := userctl.ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMigrate runs migrate up, status and down against a sqlite file
+// named by USERCTL_DSN.
+func TestMigrate(t *testing.T) {
+	c := newCLI(t)
+	dsn := map[string]string{"USERCTL_DSN": filepath.Join(t.TempDir(), "users.db")}
+	r := c.run(dsn, "", "migrate", "up", "--to", "2")
+	c.want(r, userctl.ExitOK)
+	if want := "applied 0001 create_users\napplied 0002 unique_email\n"; r.stdout != want {
+		t.Errorf("up --to 2 printed %q, want %q", r.stdout, want)
+	}
+	r = c.run(dsn, "", "migrate", "status")
+	c.want(r, userctl.ExitOK)
+	if !strings.HasPrefix(r.stdout, "VERSION  NAME") || !strings.Contains(r.stdout, "0003     timestamps    pending") {
+		t.Errorf("status printed %q, want a table with 0003 pending", r.stdout)
+	}
+	r = c.run(dsn, "", "migrate", "down", "--steps", "1", "-o", "json")
+	c.want(r, userctl.ExitOK)
+	if !strings.Contains(r.stdout, `"name": "unique_email"`) {
+		t.Errorf("down printed %q, want unique_email rolled back", r.stdout)
+	}
+	c.want(c.run(dsn, "", "migrate", "up", "--driver", "mysql"), userctl.ExitUsage)
+	if reqs := c.srv.Requests(); len(reqs) != 0 {
+		t.Errorf("requests = %+v, want migrate to leave the API alone", reqs)
+	}
+}
@@ -0,0 +1,77 @@
+// Package userctltest fakes the users API for userctl's command tests. It
+// is the real v2 handler over a memory store, so the commands meet the
+// server's own status codes and error bodies, with a switch in front to
+// answer every request with a chosen status instead, and a record of what
+// reached it.
+package userctltest
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/db"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/idgen"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	httptransport "github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/transport/http"
+)
+
+// Request is one request as the server saw it.
+type Request struct {
+	Method string
+	// Path includes the query string.
+	Path   string
+	APIKey string
+	Tenant string
+}
+
+// Server serves the API under /v2 like app.NewHandler. Generated IDs are
+// "user-1", "user-2", and so on.
+type Server struct {
+	*httptest.Server
+	Users *service.UserService
+
+	mu       sync.Mutex
+	status   int
+	requests []Request
+}
+
+func NewServer() *Server {
+	users := service.NewUserService(db.NewMemoryStore(), service.WithIDGenerator(&idgen.Sequence{Prefix: "user-"}))
+	discard := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mux := http.NewServeMux()
+	mux.Handle("/v2/", http.StripPrefix("/v2", httptransport.NewV2Handler(users, discard)))
+	s := &Server{Users: users}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.RequestURI(), APIKey: r.Header.Get("X-API-Key"), Tenant: r.Header.Get("X-Tenant-ID")})
+		status := s.status
+		s.mu.Unlock()
+		if status != 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			io.WriteString(w, `{"error":"`+http.StatusText(status)+`"}`)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	return s
+}
+
+// Fail answers every request with status until Fail(0).
+func (s *Server) Fail(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+// Requests returns every request so far, in order, and forgets them.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := s.requests
+	s.requests = nil
+	return requests
+}
@@ -0,0 +1,159 @@
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/errs"
+)
+
+// FieldError is a single problem with a single field.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (f FieldError) String() string {
+	return f.Field + " " + f.Message
+}
+
+// ValidationError lists every field problem found, not just the first, so a
+// client can fix all of them in one round trip. It unwraps to
+// errs.ErrInvalidInput for callers that only care about the kind.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.String()
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return errs.ErrInvalidInput
+}
+
+// Errors collects field problems for hand-rolled validators.
+//
+//	var v validate.Errors
+//	v.Required("ID", user.ID)
+//	v.Email("Email", user.Email)
+//	return v.Err()
+type Errors struct {
+	fields []FieldError
+}
+
+// Add records msg against field.
+func (v *Errors) Add(field, msg string) {
+	v.fields = append(v.fields, FieldError{Field: field, Message: msg})
+}
+
+func (v *Errors) Required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.Add(field, "is required")
+	}
+}
+
+func (v *Errors) MaxLen(field, value string, max int) {
+	if utf8.RuneCountInString(value) > max {
+		v.Add(field, fmt.Sprintf("must be at most %d characters", max))
+	}
+}
+
+// Printable records a problem for a value that is not valid UTF-8 or holds
+// control characters, neither survives a URL path, a cursor, or a Postgres
+// TEXT column intact.
+func (v *Errors) Printable(field, value string) {
+	if !utf8.ValidString(value) || strings.IndexFunc(value, unicode.IsControl) >= 0 {
+		v.Add(field, "must be printable UTF-8")
+	}
+}
+
+// Email records a problem for a non-empty value that is not a bare address,
+// pair it with Required when the field must be present.
+func (v *Errors) Email(field, value string) {
+	if value != "" && !IsEmail(value) {
+		v.Add(field, "must be a valid email address")
+	}
+}
+
+// Err returns a *ValidationError if any problem was recorded, nil otherwise.
+func (v *Errors) Err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: v.fields}
+}
+
+// IsEmail reports whether s is a bare address like "gopher@example.com",
+// display names such as "Gopher <gopher@example.com>" are rejected. So are
+// control characters, which ParseAddress lets through in a UTF-8 domain.
+func IsEmail(s string) bool {
+	if strings.IndexFunc(s, unicode.IsControl) >= 0 {
+		return false
+	}
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s && addr.Name == ""
+}
+
+// Struct validates the exported string fields of the struct v points to
+// using `validate` tags, a comma separated list of rules:
+//
+//	required   the field must not be blank
+//	email      a non-empty field must be a valid address
+//	max=N      the field must be at most N characters
+//	printable  the field must be valid UTF-8 without control characters
+//
+// It trades compile-time safety for brevity: a misspelt rule is only caught
+// when Struct runs, where the hand-rolled Errors version would not compile.
+func Struct(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return &ValidationError{Fields: []FieldError{{Field: "", Message: "is required"}}}
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("validate: Struct expects a struct, got %s", rv.Kind())
+	}
+
+	var found Errors
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag, ok := field.Tag.Lookup("validate")
+		if !ok || !field.IsExported() || field.Type.Kind() != reflect.String {
+			continue
+		}
+		value := rv.Field(i).String()
+		for _, rule := range strings.Split(tag, ",") {
+			name, arg, _ := strings.Cut(rule, "=")
+			switch name {
+			case "required":
+				found.Required(field.Name, value)
+			case "email":
+				found.Email(field.Name, value)
+			case "printable":
+				found.Printable(field.Name, value)
+			case "max":
+				n, err := strconv.Atoi(arg)
+				if err != nil {
+					return fmt.Errorf("validate: bad max rule %q on %s", rule, field.Name)
+				}
+				found.MaxLen(field.Name, value, n)
+			default:
+				return fmt.Errorf("validate: unknown rule %q on %s", rule, field.Name)
+			}
+		}
+	}
+	return found.Err()
+}
@@ -0,0 +1,57 @@
+package validate_test
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/service"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/validate"
+)
+
+func TestIsEmail(t *testing.T) {
+	for _, tt := range []struct {
+		email string
+		want  bool
+	}{
+		{"gopher@example.com", true},
+		{"grace+news@example.com", true},
+		{"", false},
+		{"gopher", false},
+		{"Gopher <gopher@example.com>", false},
+		{" gopher@example.com", false},
+		{"a@b\x00.com", false},
+		{"\xffa@example.com", false},
+		// found by FuzzValidateEmail, a C1 control character in the domain
+		{"0@0\u0084", false},
+	} {
+		if got := validate.IsEmail(tt.email); got != tt.want {
+			t.Errorf("IsEmail(%q) = %t, want %t", tt.email, got, tt.want)
+		}
+	}
+}
+
+// FuzzValidateEmail checks anything accepted as an email is printable,
+// trimmed UTF-8, and that User.Validate and validate.Struct agree on it.
+func FuzzValidateEmail(f *testing.F) {
+	for _, seed := range []string{
+		"gopher@example.com", "Gopher <gopher@example.com>", "", "a@b",
+		`"a b"@example.com`, "a@[127.0.0.1]", "a@b\x00.com", "é@example.com",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, email string) {
+		user := &service.User{ID: "1", Email: email}
+		hand, tags := user.Validate(), validate.Struct(user)
+		if (hand == nil) != (tags == nil) {
+			t.Fatalf("Validate = %v, Struct = %v", hand, tags)
+		}
+		if !validate.IsEmail(email) {
+			return
+		}
+		if !utf8.ValidString(email) || strings.IndexFunc(email, unicode.IsControl) >= 0 || strings.TrimSpace(email) != email {
+			t.Fatalf("IsEmail accepted %q", email)
+		}
+	})
+}
@@ -0,0 +1,173 @@
+// Package workerpool runs a function over submitted jobs with a fixed
+// number of goroutines. Every submitted job produces exactly one Result,
+// whether it ran, failed, panicked, or was skipped because the pool's
+// context was canceled, so a caller counting results never waits forever.
+// A panic is the job's error, a *safe.PanicError with the stack.
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/safe"
+)
+
+// ErrClosed is returned by Submit after Close.
+var ErrClosed = errors.New("workerpool: closed")
+
+// Result is the outcome of one job. Seq is the job's submission order,
+// starting at 0, results arrive in completion order.
+type Result[In, Out any] struct {
+	Seq   int
+	Job   In
+	Value Out
+	Err   error
+}
+
+type job[In any] struct {
+	seq int
+	in  In
+}
+
+// Pool fans jobs out to a fixed set of workers. Results must be read
+// concurrently with Submit: once the results buffer fills, workers block
+// and Submit blocks behind them.
+type Pool[In, Out any] struct {
+	ctx     context.Context
+	fn      func(context.Context, In) (Out, error)
+	jobs    chan job[In]
+	results chan Result[In, Out]
+
+	// mu serializes Submit, so sequence numbers have no gaps, and orders
+	// Close after it, a send on a closed jobs channel would panic
+	mu     sync.Mutex
+	seq    int
+	closed bool
+	// stop unregisters the Close that canceling ctx would run
+	stop func() bool
+}
+
+// New starts workers goroutines calling fn. Canceling ctx stops new jobs
+// from starting, those still queued are reported with ctx.Err(), and closes
+// the pool, so the workers exit even if Close is never called.
+func New[In, Out any](ctx context.Context, workers int, fn func(context.Context, In) (Out, error)) *Pool[In, Out] {
+	workers = max(workers, 1)
+	p := &Pool[In, Out]{
+		ctx:     ctx,
+		fn:      fn,
+		jobs:    make(chan job[In], workers),
+		results: make(chan Result[In, Out], workers),
+	}
+	// under mu, an already done ctx runs Close straight away
+	p.mu.Lock()
+	p.stop = context.AfterFunc(ctx, p.Close)
+	p.mu.Unlock()
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range p.jobs {
+				p.results <- p.run(j)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(p.results)
+	}()
+	return p
+}
+
+// Submit queues in, blocking while every worker is busy and the queue is
+// full. It fails with ErrClosed after Close, or with the context error when
+// ctx or the pool's context is done first.
+func (p *Pool[In, Out]) Submit(ctx context.Context, in In) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		// closed by the pool's context rather than by Close
+		if err := p.ctx.Err(); err != nil {
+			return err
+		}
+		return ErrClosed
+	}
+	select {
+	case p.jobs <- job[In]{seq: p.seq, in: in}:
+		p.seq++
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+}
+
+// Results delivers one Result per submitted job and is closed once Close
+// has been called and every queued job is done, so ranging over it is the
+// graceful drain.
+func (p *Pool[In, Out]) Results() <-chan Result[In, Out] {
+	return p.results
+}
+
+// Close stops accepting jobs, the workers finish what is queued and exit
+// once its results are read. It is safe to call more than once.
+func (p *Pool[In, Out]) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stop()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+	}
+}
+
+func (p *Pool[In, Out]) run(j job[In]) (res Result[In, Out]) {
+	res = Result[In, Out]{Seq: j.seq, Job: j.in}
+	if err := p.ctx.Err(); err != nil {
+		res.Err = err
+		return res
+	}
+	// one bad job must not take down its worker and strand the queue
+	err := safe.Do(func() error {
+		var err error
+		res.Value, err = p.fn(p.ctx, j.in)
+		return err
+	})
+	if pe, ok := err.(*safe.PanicError); ok {
+		err = fmt.Errorf("workerpool: job %d: %w", j.seq, pe)
+	}
+	res.Err = err
+	return res
+}
+
+// Map runs fn over inputs on workers goroutines and returns the results in
+// input order.
+func Map[In, Out any](ctx context.Context, workers int, inputs []In, fn func(context.Context, In) (Out, error)) []Result[In, Out] {
+	p := New(ctx, workers, fn)
+	unsubmitted, submitErr := len(inputs), error(nil)
+	submitted := make(chan struct{})
+	go func() {
+		defer close(submitted)
+		defer p.Close()
+		for i, in := range inputs {
+			if err := p.Submit(ctx, in); err != nil {
+				unsubmitted, submitErr = i, err
+				return
+			}
+		}
+	}()
+	results := make([]Result[In, Out], len(inputs))
+	for res := range p.Results() {
+		results[res.Seq] = res
+	}
+	// the rest never reached a worker, report them here instead. Canceling
+	// ctx closes the pool, so Results can end before Submit has returned.
+	<-submitted
+	for j := unsubmitted; j < len(inputs); j++ {
+		results[j] = Result[In, Out]{Seq: j, Job: inputs[j], Err: submitErr}
+	}
+	return results
+}
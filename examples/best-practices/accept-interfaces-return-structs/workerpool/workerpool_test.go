@@ -0,0 +1,153 @@
+package workerpool_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/safe"
+	"github.com/mjyocca/golang-notebook/best-practices/accept-interfaces-return-structs/workerpool"
+)
+
+// TestMain fails the package if any test leaves a worker running.
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+func TestMap(t *testing.T) {
+	inputs := []int{1, 2, 3, 4, 5, 6, 7, 8}
+	results := workerpool.Map(context.Background(), 3, inputs, func(_ context.Context, n int) (int, error) {
+		if n == 5 {
+			panic("five")
+		}
+		return n * n, nil
+	})
+	for i, res := range results {
+		if res.Seq != i || res.Job != inputs[i] {
+			t.Fatalf("results[%d] = job %d seq %d, want input order", i, res.Job, res.Seq)
+		}
+		if res.Job == 5 {
+			var pe *safe.PanicError
+			if !errors.As(res.Err, &pe) {
+				t.Errorf("the panicking job: err = %v, want a PanicError", res.Err)
+			}
+			continue
+		}
+		if res.Err != nil || res.Value != res.Job*res.Job {
+			t.Errorf("job %d = %d, %v, want its square", res.Job, res.Value, res.Err)
+		}
+	}
+}
+
+var errBoom = errors.New("boom")
+
+func explode() { panic(errBoom) }
+
+// TestMapPanic checks a panicking job fails alone, reported once, with the
+// stack it panicked on.
+func TestMapPanic(t *testing.T) {
+	reported := 0
+	t.Cleanup(safe.OnPanic(func(*safe.PanicError) { reported++ }))
+	results := workerpool.Map(context.Background(), 2, []int{1, 2, 3}, func(_ context.Context, n int) (int, error) {
+		if n == 2 {
+			explode()
+		}
+		return n * 10, nil
+	})
+	for _, res := range results {
+		if res.Job != 2 {
+			if res.Err != nil || res.Value != res.Job*10 {
+				t.Errorf("job %d = %d, %v, want it unaffected", res.Job, res.Value, res.Err)
+			}
+			continue
+		}
+		var pe *safe.PanicError
+		if !errors.As(res.Err, &pe) || !errors.Is(res.Err, errBoom) {
+			t.Fatalf("job 2: err = %v, want a *safe.PanicError of errBoom", res.Err)
+		}
+		if want := "workerpool: job 1: panic: boom"; res.Err.Error() != want {
+			t.Errorf("job 2: err = %q, want %q", res.Err, want)
+		}
+		if top := strings.SplitN(string(pe.Stack), "\n", 3)[1]; !strings.Contains(top, "workerpool_test.explode(") {
+			t.Errorf("top frame %q, want explode\n%s", top, pe.Stack)
+		}
+	}
+	if reported != 1 {
+		t.Errorf("%d panics reported, want 1", reported)
+	}
+}
+
+// TestMapCutShort checks every input gets a result when the context ends
+// part way, the ones that never ran with the context's error.
+func TestMapCutShort(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	results := workerpool.Map(ctx, 2, make([]int, 100), func(ctx context.Context, n int) (int, error) {
+		time.Sleep(5 * time.Millisecond)
+		return n, nil
+	})
+	if len(results) != 100 {
+		t.Fatalf("%d results, want 100", len(results))
+	}
+	if err := results[len(results)-1].Err; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("last result: err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+// TestCancelWithoutClose checks canceling the pool's context is enough to
+// stop it: Results closes and the workers exit, with no Close.
+func TestCancelWithoutClose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var started atomic.Int64
+	pool := workerpool.New(ctx, 4, func(ctx context.Context, n int) (int, error) {
+		started.Add(1)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	for i := range 8 {
+		if err := pool.Submit(ctx, i); err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+	}
+	cancel()
+	n := 0
+	for res := range pool.Results() {
+		n++
+		if !errors.Is(res.Err, context.Canceled) {
+			t.Errorf("job %d: err = %v, want context.Canceled", res.Job, res.Err)
+		}
+	}
+	if n != 8 {
+		t.Errorf("%d results for 8 jobs", n)
+	}
+	if err := pool.Submit(context.Background(), 9); err == nil {
+		t.Error("Submit after the context ended succeeded")
+	}
+}
+
+func TestClose(t *testing.T) {
+	pool := workerpool.New(context.Background(), 2, func(_ context.Context, n int) (int, error) { return n, nil })
+	go func() {
+		for i := range 5 {
+			pool.Submit(context.Background(), i)
+		}
+		pool.Close()
+		pool.Close()
+	}()
+	n := 0
+	for range pool.Results() {
+		n++
+	}
+	if n != 5 {
+		t.Errorf("%d results for 5 jobs", n)
+	}
+	if err := pool.Submit(context.Background(), 6); !errors.Is(err, workerpool.ErrClosed) {
+		t.Errorf("Submit after Close: err = %v, want ErrClosed", err)
+	}
+}
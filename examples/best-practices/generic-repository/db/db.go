@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrNotFound = errors.New("db: item not found")
+
+// Store is an in-memory Repository for any type. The key func is how the
+// store learns the ID of an item without knowing anything else about T.
+type Store[T any] struct {
+	mu    sync.RWMutex
+	key   func(T) string
+	items map[string]T
+	order []string
+}
+
+func NewStore[T any](key func(T) string) *Store[T] {
+	return &Store[T]{
+		key:   key,
+		items: make(map[string]T),
+	}
+}
+
+func (s *Store[T]) Insert(ctx context.Context, item T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.key(item)
+	if _, ok := s.items[id]; !ok {
+		s.order = append(s.order, id)
+	}
+	s.items[id] = item
+	return nil
+}
+
+func (s *Store[T]) Get(ctx context.Context, id string) (T, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, ok := s.items[id]
+	if !ok {
+		var zero T
+		return zero, ErrNotFound
+	}
+	return item, nil
+}
+
+func (s *Store[T]) List(ctx context.Context) ([]T, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	items := make([]T, 0, len(s.order))
+	for _, id := range s.order {
+		items = append(items, s.items[id])
+	}
+	return items, nil
+}
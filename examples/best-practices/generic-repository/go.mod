@@ -0,0 +1,3 @@
+module github.com/mjyocca/golang-notebook/best-practices/generic-repository
+
+go 1.20
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mjyocca/golang-notebook/best-practices/generic-repository/db"
+	"github.com/mjyocca/golang-notebook/best-practices/generic-repository/service"
+)
+
+// Runs the user service against one generic Repository[User]. The store is
+// written once for any type, and each method still returns a service.User
+// with no type assertion.
+//
+//	go run .
+func main() {
+	ctx := context.Background()
+	// store is instantiated for the User type, satisfying Repository[User]
+	store := db.NewStore(func(u service.User) string { return u.ID })
+	userService := service.NewUserService(store)
+
+	if err := userService.CreateUser(ctx, service.User{ID: "1", Email: "gopher@example.com"}); err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+
+	// no type assertion, RetrieveUser returns a service.User
+	user, err := userService.RetrieveUser(ctx, "1")
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	fmt.Printf("User retrieved: %+v\n", user)
+
+	users, err := userService.ListUsers(ctx)
+	if err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		return
+	}
+	fmt.Printf("Users: %d\n", len(users))
+}
@@ -0,0 +1,51 @@
+package service
+
+import "context"
+
+// Repository is the generic counterpart to the interface{} based UserStorer.
+// The type parameter removes the need for the caller to pass a pointer to be
+// filled in, and for the service to type assert on the way out.
+type Repository[T any] interface {
+	Insert(ctx context.Context, item T) error
+	Get(ctx context.Context, id string) (T, error)
+	List(ctx context.Context) ([]T, error)
+}
+
+type User struct {
+	ID    string
+	Email string
+}
+
+type UserService struct {
+	repo Repository[User]
+}
+
+func NewUserService(r Repository[User]) *UserService {
+	return &UserService{
+		repo: r,
+	}
+}
+
+func (u *UserService) CreateUser(ctx context.Context, user User) error {
+	err := u.repo.Insert(ctx, user)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (u *UserService) RetrieveUser(ctx context.Context, id string) (User, error) {
+	user, err := u.repo.Get(ctx, id)
+	if err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+func (u *UserService) ListUsers(ctx context.Context) ([]User, error) {
+	users, err := u.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}